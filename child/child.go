@@ -11,6 +11,7 @@ import (
 	"math/rand"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"sync"
 	"syscall"
@@ -73,6 +74,11 @@ type Child struct {
 
 	// a logger that can be used for messages pertinent to this child process
 	logger *log.Logger
+
+	// whether to log the output of the child instead of writing it to stdout
+	// and stderr, and the name to log it under
+	logOutput bool
+	name      string
 }
 
 // NewInput is input to the NewChild function.
@@ -128,6 +134,12 @@ type NewInput struct {
 
 	// an optional logger that can be used for messages pertinent to the child process
 	Logger *log.Logger
+
+	// LogOutput, if set, sends each line the child process writes to its
+	// stdout and stderr to the logger instead of Stdout and Stderr, prefixed
+	// with Name. Name defaults to the base name of the command.
+	LogOutput bool
+	Name      string
 }
 
 // New creates a new child process for management with high-level APIs for
@@ -146,6 +158,10 @@ func New(i *NewInput) (*Child, error) {
 		i.Logger = log.Default()
 	}
 
+	if i.Name == "" {
+		i.Name = filepath.Base(i.Command)
+	}
+
 	child := &Child{
 		stdin:        i.Stdin,
 		stdout:       i.Stdout,
@@ -162,6 +178,8 @@ func New(i *NewInput) (*Child, error) {
 		setpgid:      i.Setpgid && !i.Setsid,
 		setsid:       i.Setsid,
 		logger:       i.Logger,
+		logOutput:    i.LogOutput,
+		name:         i.Name,
 	}
 
 	return child, nil
@@ -246,6 +264,21 @@ func (c *Child) Reload() error {
 	return c.reload()
 }
 
+// Restart stops the child process and starts it again with the given
+// environment, even when a reload signal was provided. A running process
+// cannot pick up changes to its environment, so this is used for them instead
+// of Reload.
+func (c *Child) Restart(env []string) error {
+	c.logger.Printf("[INFO] (child) restarting process with a new environment")
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.env = env
+	c.kill(false)
+	return c.start()
+}
+
 // Kill sends the kill signal to the child process and waits for successful
 // termination. If no kill signal is defined, the process is killed with the
 // most aggressive kill signal. If the process does not gracefully stop within
@@ -298,6 +331,16 @@ func (c *Child) start() error {
 	cmd.Stdin = c.stdin
 	cmd.Stdout = c.stdout
 	cmd.Stderr = c.stderr
+
+	// The output is copied to the loggers until the process exits, so they are
+	// only flushed once it has.
+	var outputLoggers []*outputLogger
+	if c.logOutput {
+		stdout := newOutputLogger(c.logger, c.name, "stdout")
+		stderr := newOutputLogger(c.logger, c.name, "stderr")
+		cmd.Stdout, cmd.Stderr = stdout, stderr
+		outputLoggers = append(outputLoggers, stdout, stderr)
+	}
 	cmd.Env = c.env
 	setSysProcAttr(cmd, c.setpgid, c.setsid)
 	if err := cmd.Start(); err != nil {
@@ -311,6 +354,9 @@ func (c *Child) start() error {
 	go func() {
 		var code int
 		err := cmd.Wait()
+		for _, l := range outputLoggers {
+			l.Flush()
+		}
 		if err == nil {
 			code = ExitCodeOK
 		} else {
@@ -446,10 +492,13 @@ func (c *Child) kill(immediately bool) {
 		return
 	}
 
+	// The process is captured here since cmd is cleared once this returns,
+	// while the wait may still be running.
+	p := c.cmd.Process
 	killCh := make(chan struct{}, 1)
 	go func() {
 		defer close(killCh)
-		c.cmd.Process.Wait()
+		p.Wait()
 	}()
 
 	select {
@@ -457,6 +506,8 @@ func (c *Child) kill(immediately bool) {
 	case <-killCh:
 		exited = true
 	case <-time.After(c.killTimeout):
+		c.logger.Printf("[WARN] (child) process did not exit within kill timeout "+
+			"(%s); sending SIGKILL", c.killTimeout)
 	}
 }
 
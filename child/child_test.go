@@ -338,6 +338,48 @@ func TestReload_noSignal(t *testing.T) {
 	}
 }
 
+func TestRestart(t *testing.T) {
+	f, err := os.CreateTemp("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+
+	c := testChild(t)
+	c.command = "sh"
+	c.args = []string{"-c", `echo "$TOKEN" >> ` + f.Name() + `; while true; do sleep 0.2; done`}
+	c.env = []string{"TOKEN=a"}
+	c.killTimeout = 10 * time.Millisecond
+	c.reloadSignal = syscall.SIGUSR1
+
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	time.Sleep(fileWaitSleepDelay)
+	opid := c.cmd.Process.Pid
+
+	// The reload signal is ignored, the process is started again.
+	if err := c.Restart([]string{"TOKEN=b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(fileWaitSleepDelay)
+	if npid := c.cmd.Process.Pid; opid == npid {
+		t.Error("expected new process to restart")
+	}
+
+	b, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "a\nb\n", string(b); act != exp {
+		t.Errorf("expected %q to be %q", act, exp)
+	}
+}
+
 func TestReload_noProcess(t *testing.T) {
 	c := testChild(t)
 	c.reloadSignal = syscall.SIGUSR1
@@ -399,6 +441,43 @@ func TestKill_noSignal(t *testing.T) {
 	}
 }
 
+func TestKill_timeout(t *testing.T) {
+	buf := gatedio.NewByteBuffer()
+	c := testChild(t)
+	c.command = "sh"
+	c.args = []string{"-c", "trap '' TERM; while true; do sleep 0.2; done"}
+	c.killSignal = syscall.SIGTERM
+	c.killTimeout = 100 * time.Millisecond
+	c.logger = log.New(buf, "", 0)
+
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	// For some reason bash doesn't start immediately
+	time.Sleep(fileWaitSleepDelay)
+
+	exitCh := c.ExitCh()
+	start := time.Now()
+	c.Kill()
+	if elapsed := time.Since(start); elapsed < c.killTimeout {
+		t.Errorf("expected kill to wait %s for the process, waited %s",
+			c.killTimeout, elapsed)
+	}
+
+	select {
+	case <-exitCh:
+	case <-time.After(time.Second):
+		t.Fatal("expected process to be force-killed")
+	}
+
+	expected := "[WARN] (child) process did not exit within kill timeout (100ms); sending SIGKILL"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected log %q to contain %q", buf.String(), expected)
+	}
+}
+
 func TestKill_noProcess(t *testing.T) {
 	c := testChild(t)
 	c.killSignal = syscall.SIGUSR1
@@ -637,3 +716,40 @@ func TestCustomLogger(t *testing.T) {
 		t.Fatalf("Expected '%s' to be '%s'", actual, expected)
 	}
 }
+
+func TestLogOutput(t *testing.T) {
+	var buf bytes.Buffer
+	stdout := gatedio.NewByteBuffer()
+
+	c := testChild(t)
+	c.stdout = stdout
+	c.command = "sh"
+	c.args = []string{"-c", "echo hello; echo oops >&2; printf partial"}
+	c.logger = log.New(&buf, "", 0)
+	c.logOutput = true
+	c.name = "web"
+
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	select {
+	case <-c.ExitCh():
+	case <-time.After(2 * time.Second):
+		t.Fatal("process should have exited")
+	}
+
+	for _, exp := range []string{
+		"[INFO] (child) web (stdout): hello\n",
+		"[INFO] (child) web (stderr): oops\n",
+		"[INFO] (child) web (stdout): partial\n",
+	} {
+		if !strings.Contains(buf.String(), exp) {
+			t.Errorf("expected %q to contain %q", buf.String(), exp)
+		}
+	}
+	if stdout.String() != "" {
+		t.Errorf("expected output to not be written to stdout, got %q", stdout.String())
+	}
+}
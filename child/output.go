@@ -0,0 +1,65 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package child
+
+import (
+	"bytes"
+	"log"
+	"strings"
+)
+
+// maxOutputLineLength is the longest line of output logged at once. Longer
+// lines, like those of binary output, are logged in pieces so the buffered
+// output stays bounded.
+const maxOutputLineLength = 16 * 1024
+
+// outputLogger is an io.Writer that logs each line written to it, prefixed
+// with the name of the child and the stream the line came from.
+type outputLogger struct {
+	logger *log.Logger
+	prefix string
+	buf    []byte
+}
+
+// newOutputLogger returns an outputLogger for the given stream of the child.
+func newOutputLogger(logger *log.Logger, name, stream string) *outputLogger {
+	return &outputLogger{
+		logger: logger,
+		prefix: "[INFO] (child) " + name + " (" + stream + "): ",
+	}
+}
+
+// Write logs every complete line in p and buffers the rest until the next
+// newline, the buffer is full, or Flush is called.
+func (l *outputLogger) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		room := maxOutputLineLength - len(l.buf)
+		switch {
+		case i >= 0 && i <= room:
+			l.buf = append(l.buf, p[:i]...)
+			l.Flush()
+			p = p[i+1:]
+		case i < 0 && len(p) < room:
+			l.buf = append(l.buf, p...)
+			p = nil
+		default:
+			l.buf = append(l.buf, p[:room]...)
+			l.Flush()
+			p = p[room:]
+		}
+	}
+	return n, nil
+}
+
+// Flush logs any buffered output that did not end in a newline.
+func (l *outputLogger) Flush() {
+	if len(l.buf) == 0 {
+		return
+	}
+	line := strings.ToValidUTF8(string(bytes.TrimSuffix(l.buf, []byte("\r"))), "\uFFFD")
+	l.logger.Print(l.prefix + line)
+	l.buf = l.buf[:0]
+}
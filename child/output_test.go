@@ -0,0 +1,71 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package child
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestOutputLogger(t *testing.T) {
+	long := strings.Repeat("a", maxOutputLineLength)
+
+	cases := []struct {
+		name   string
+		writes []string
+		exp    []string
+	}{
+		{
+			"lines",
+			[]string{"one\ntwo\r\n", "\nthree"},
+			[]string{"one", "two", "three"},
+		},
+		{
+			"split_writes",
+			[]string{"on", "e\ntw", "o\n"},
+			[]string{"one", "two"},
+		},
+		{
+			"long_line",
+			[]string{long + "bb\n"},
+			[]string{long, "bb"},
+		},
+		{
+			"long_line_no_newline",
+			[]string{long[:10], long + "bb"},
+			[]string{long, long[:10] + "bb"},
+		},
+		{
+			"binary",
+			[]string{"\xff\xfeok\n"},
+			[]string{"�ok"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l := newOutputLogger(log.New(&buf, "", 0), "web", "stdout")
+			for _, w := range tc.writes {
+				if n, err := l.Write([]byte(w)); err != nil || n != len(w) {
+					t.Fatalf("expected to write %d bytes, wrote %d: %v", len(w), n, err)
+				}
+			}
+			l.Flush()
+
+			var exp string
+			for _, line := range tc.exp {
+				exp += "[INFO] (child) web (stdout): " + line + "\n"
+			}
+			if buf.String() != exp {
+				t.Errorf("expected %q to be %q", buf.String(), exp)
+			}
+			if len(l.buf) != 0 {
+				t.Errorf("expected the buffer to be empty, got %d bytes", len(l.buf))
+			}
+		})
+	}
+}
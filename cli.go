@@ -4,13 +4,16 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/hashicorp/consul-template/config"
@@ -18,6 +21,7 @@ import (
 	"github.com/hashicorp/consul-template/manager"
 	"github.com/hashicorp/consul-template/service_os"
 	"github.com/hashicorp/consul-template/signals"
+	"github.com/hashicorp/consul-template/telemetry"
 	"github.com/hashicorp/consul-template/version"
 )
 
@@ -82,11 +86,21 @@ func (cli *CLI) Run(args []string) int {
 	// Load configuration paths, with CLI taking precedence
 	config, err = loadConfigs(paths, cliConfig)
 	if err != nil {
+		if cliConfig.ConfigCheck {
+			fmt.Fprintf(cli.errStream, "Configuration is invalid: %s\n", err)
+		}
 		return logError(err, ExitCodeConfigError)
 	}
 
 	config.Finalize()
 
+	// The configuration was parsed strictly, so it is valid. Report what it
+	// contains and exit before setting up logging or connecting to anything.
+	if config.ConfigCheck {
+		fmt.Fprint(cli.outStream, configSummary(config))
+		return ExitCodeOK
+	}
+
 	// Setup the config and logging
 	config, err = cli.setup(config)
 	if err != nil {
@@ -105,6 +119,21 @@ func (cli *CLI) Run(args []string) int {
 		return ExitCodeOK
 	}
 
+	// Setup tracing. It is not changed by reloads, since spans that are still
+	// being exported would be lost.
+	shutdownTracing, err := telemetry.Setup(&telemetry.Config{
+		Enabled:  *config.Telemetry.OTel.Enabled,
+		Endpoint: *config.Telemetry.OTel.Endpoint,
+	})
+	if err != nil {
+		return logError(err, ExitCodeConfigError)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Printf("[WARN] (cli) failed to flush traces: %s", err)
+		}
+	}()
+
 	// Initial runner
 	runner, err := manager.NewRunner(config, dry)
 	if err != nil {
@@ -166,9 +195,17 @@ func (cli *CLI) Run(args []string) int {
 				go runner.Start()
 			case *config.KillSignal:
 				fmt.Fprintf(cli.errStream, "Cleaning up...\n")
-				runner.StopImmediately()
+				shutdown(runner, config)
 				return ExitCodeInterrupt
 			default:
+				// With drain_on_shutdown, SIGTERM also gracefully terminates so the
+				// final render happens when running under an orchestrator.
+				if s == syscall.SIGTERM && *config.DrainOnShutdown {
+					fmt.Fprintf(cli.errStream, "Cleaning up...\n")
+					shutdown(runner, config)
+					return ExitCodeInterrupt
+				}
+
 				// Propagate the signal to the child process
 				runner.Signal(s)
 			}
@@ -178,6 +215,16 @@ func (cli *CLI) Run(args []string) int {
 	}
 }
 
+// shutdown stops the runner, first rendering all templates a final time if
+// drain_on_shutdown is enabled.
+func shutdown(runner *manager.Runner, c *config.Config) {
+	if *c.DrainOnShutdown {
+		runner.Drain(*c.DrainTimeout)
+		return
+	}
+	runner.StopImmediately()
+}
+
 // stop is used internally to shutdown a running CLI
 func (cli *CLI) stop() {
 	cli.Lock()
@@ -223,6 +270,11 @@ func (cli *CLI) ParseFlags(args []string) (
 		return nil
 	}), "config", "")
 
+	flags.Var((funcBoolVar)(func(b bool) error {
+		c.ConfigCheck = b
+		return nil
+	}), "config-check", "")
+
 	flags.Var((funcVar)(func(s string) error {
 		c.Consul.Address = config.String(s)
 		return nil
@@ -317,6 +369,26 @@ func (cli *CLI) ParseFlags(args []string) (
 		return nil
 	}), "consul-transport-disable-keep-alives", "")
 
+	flags.Var((funcBoolVar)(func(b bool) error {
+		c.Consul.Transport.EnableHTTP2 = config.Bool(b)
+		return nil
+	}), "consul-transport-enable-http2", "")
+
+	flags.Var((funcDurationVar)(func(d time.Duration) error {
+		c.Consul.Transport.IdleConnTimeout = config.TimeDuration(d)
+		return nil
+	}), "consul-transport-idle-conn-timeout", "")
+
+	flags.Var((funcIntVar)(func(i int) error {
+		c.Consul.Transport.MaxConnsPerHost = config.Int(i)
+		return nil
+	}), "consul-transport-max-conns-per-host", "")
+
+	flags.Var((funcIntVar)(func(i int) error {
+		c.Consul.Transport.MaxIdleConns = config.Int(i)
+		return nil
+	}), "consul-transport-max-idle-conns", "")
+
 	flags.Var((funcIntVar)(func(i int) error {
 		c.Consul.Transport.MaxIdleConnsPerHost = config.Int(i)
 		return nil
@@ -332,6 +404,16 @@ func (cli *CLI) ParseFlags(args []string) (
 		return nil
 	}), "dedup", "")
 
+	flags.Var((funcVar)(func(s string) error {
+		c.Dedup.Prefix = config.String(s)
+		return nil
+	}), "dedup-prefix", "")
+
+	flags.Var((funcDurationVar)(func(d time.Duration) error {
+		c.Dedup.TTL = config.TimeDuration(d)
+		return nil
+	}), "dedup-ttl", "")
+
 	flags.Var((funcVar)(func(s string) error {
 		c.DefaultDelims.Left = config.String(s)
 		return nil
@@ -342,6 +424,16 @@ func (cli *CLI) ParseFlags(args []string) (
 		return nil
 	}), "default-right-delimiter", "")
 
+	flags.Var((funcBoolVar)(func(b bool) error {
+		c.DrainOnShutdown = config.Bool(b)
+		return nil
+	}), "drain-on-shutdown", "")
+
+	flags.Var((funcDurationVar)(func(d time.Duration) error {
+		c.DrainTimeout = config.TimeDuration(d)
+		return nil
+	}), "drain-timeout", "")
+
 	flags.BoolVar(&dry, "dry", false, "")
 
 	flags.Var((funcVar)(func(s string) error {
@@ -437,6 +529,11 @@ func (cli *CLI) ParseFlags(args []string) (
 		return nil
 	}), "max-stale", "")
 
+	flags.Var((funcIntVar)(func(i int) error {
+		c.MaxRenderConcurrency = config.Int(i)
+		return nil
+	}), "max-render-concurrency", "")
+
 	flags.Var((funcBoolVar)(func(b bool) error {
 		c.Once = *(config.Bool(b))
 		return nil
@@ -452,6 +549,11 @@ func (cli *CLI) ParseFlags(args []string) (
 		return nil
 	}), "pid-file", "")
 
+	flags.Var((funcBoolVar)(func(b bool) error {
+		c.ReapZombies = config.Bool(b)
+		return nil
+	}), "reap-zombies", "")
+
 	flags.Var((funcVar)(func(s string) error {
 		sig, err := signals.Parse(s)
 		if err != nil {
@@ -495,6 +597,11 @@ func (cli *CLI) ParseFlags(args []string) (
 		return nil
 	}), "template-error-fatal", "")
 
+	flags.Var((funcBoolVar)(func(b bool) error {
+		c.TemplateErrMissingKey = config.Bool(b)
+		return nil
+	}), "template-error-on-missing-key", "")
+
 	flags.Var((funcVar)(func(s string) error {
 		c.Vault.Address = config.String(s)
 		return nil
@@ -624,6 +731,11 @@ func (cli *CLI) ParseFlags(args []string) (
 		return nil
 	}), "wait", "")
 
+	flags.Var((funcDurationVar)(func(d time.Duration) error {
+		c.WaitForReady = config.TimeDuration(d)
+		return nil
+	}), "wait-for-ready", "")
+
 	flags.BoolVar(&isVersion, "v", false, "")
 	flags.BoolVar(&isVersion, "version", false, "")
 
@@ -663,10 +775,40 @@ func loadConfigs(paths []string, o *config.Config) (*config.Config, error) {
 			tmpl.ErrFatal = o.TemplateErrFatal
 		}
 	}
+	if o.TemplateErrMissingKey != nil {
+		for _, tmpl := range *finalC.Templates {
+			tmpl.ErrMissingKey = o.TemplateErrMissingKey
+		}
+	}
 	finalC.Finalize()
 	return finalC, nil
 }
 
+// configSummary returns a human-readable summary of the backends and templates
+// in the given finalized configuration.
+func configSummary(c *config.Config) string {
+	var b strings.Builder
+	b.WriteString("Configuration is valid.\n")
+
+	fmt.Fprintf(&b, "consul: address=%q\n", config.StringVal(c.Consul.Address))
+	if config.BoolVal(c.Vault.Enabled) {
+		fmt.Fprintf(&b, "vault: address=%q\n", config.StringVal(c.Vault.Address))
+	} else {
+		b.WriteString("vault: disabled\n")
+	}
+	if config.BoolVal(c.Nomad.Enabled) {
+		fmt.Fprintf(&b, "nomad: address=%q\n", config.StringVal(c.Nomad.Address))
+	} else {
+		b.WriteString("nomad: disabled\n")
+	}
+
+	fmt.Fprintf(&b, "templates: %d\n", len(*c.Templates))
+	for _, t := range *c.Templates {
+		fmt.Fprintf(&b, "  %s\n", t.Display())
+	}
+	return b.String()
+}
+
 // logError logs an error message and then returns the given status.
 func logError(err error, status int) int {
 	log.Printf("[ERR] (cli) %s", err)
@@ -705,6 +847,11 @@ Options:
       values are given, they are merged left-to-right, and CLI arguments take
       the top-most precedence.
 
+  -config-check
+      Parse and validate the configuration strictly, erroring on unknown keys
+      and invalid values, print a summary of it and exit without connecting to
+      anything
+
   -consul-addr=<address>
       Sets the address of the Consul instance
 
@@ -762,6 +909,18 @@ Options:
   -consul-transport-disable-keep-alives
       Disables keep-alives (this will impact performance)
 
+  -consul-transport-enable-http2
+      Attempts to negotiate HTTP/2 with the Consul agent
+
+  -consul-transport-idle-conn-timeout=<duration>
+      Sets the amount of time an idle connection is kept open
+
+  -consul-transport-max-conns-per-host=<int>
+      Sets the maximum number of connections to permit per host (0 is unlimited)
+
+  -consul-transport-max-idle-conns=<int>
+      Sets the maximum number of idle connections across all hosts
+
   -consul-transport-max-idle-conns-per-host=<int>
       Sets the maximum number of idle connections to permit per host
 
@@ -772,12 +931,27 @@ Options:
       Enable de-duplication mode - reduces load on Consul when many instances of
       Consul Template are rendering a common template
 
+  -dedup-prefix=<prefix>
+      Sets the prefix of the Consul KV path where de-duplication data is
+      stored, which also enables de-duplication mode
+
+  -dedup-ttl=<duration>
+      Sets the TTL of the de-duplication session, after which the data written
+      by a leader that stopped renewing it is deleted
+
   -default-left-delimiter
       The default left delimiter for templating
 
   -default-right-delimiter
       The default right delimiter for templating
 
+  -drain-on-shutdown
+      Render all templates and run their commands a final time when receiving
+      SIGTERM or the kill signal, before exiting
+
+  -drain-timeout=<duration>
+      Deadline for the final render when draining on shutdown
+
   -dry
       Print generated templates to stdout instead of rendering
 
@@ -829,6 +1003,10 @@ Options:
       Set the maximum staleness and allow stale queries to Consul which will
       distribute work among all servers instead of just the leader
 
+  -max-render-concurrency=<int>
+      Set the maximum number of templates rendered in parallel; the default of
+      1 renders them one after the other
+
   -once
       Do not run the process as a daemon. This disables wait/quiescence timers.
 
@@ -838,6 +1016,10 @@ Options:
   -pid-file=<path>
       Path on disk to write the PID of the process
 
+  -reap-zombies
+      Reap orphaned processes left behind by commands, for running as PID 1
+      in a container (Linux only)
+
   -reload-signal=<signal>
       Signal to listen to reload configuration
 
@@ -865,6 +1047,11 @@ Options:
       Control whether template errors cause consul-template to immediately exit.
       This overrides the per-template setting.
 
+  -template-error-on-missing-key=<bool>
+      Control whether accessing a map key that does not exist causes a
+      template error instead of rendering "<no value>". This overrides the
+      per-template setting.
+
   -vault-addr=<address>
       Sets the address of the Vault server
 
@@ -943,6 +1130,11 @@ Options:
       Sets the 'min(:max)' amount of time to wait before writing a template (and
       triggering a command)
 
+  -wait-for-ready=<duration>
+      Keep retrying dependency errors for this long after starting, even once
+      the configured retries are exhausted, instead of failing. Useful with
+      -once when upstreams may not be reachable yet
+
   -v, -version
       Print the version of this daemon
 `
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"reflect"
@@ -266,6 +267,54 @@ func TestCLI_ParseFlags(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"consul-transport-enable-http2",
+			[]string{"-consul-transport-enable-http2"},
+			&config.Config{
+				Consul: &config.ConsulConfig{
+					Transport: &config.TransportConfig{
+						EnableHTTP2: config.Bool(true),
+					},
+				},
+			},
+			false,
+		},
+		{
+			"consul-transport-idle-conn-timeout",
+			[]string{"-consul-transport-idle-conn-timeout", "30s"},
+			&config.Config{
+				Consul: &config.ConsulConfig{
+					Transport: &config.TransportConfig{
+						IdleConnTimeout: config.TimeDuration(30 * time.Second),
+					},
+				},
+			},
+			false,
+		},
+		{
+			"consul-transport-max-conns-per-host",
+			[]string{"-consul-transport-max-conns-per-host", "50"},
+			&config.Config{
+				Consul: &config.ConsulConfig{
+					Transport: &config.TransportConfig{
+						MaxConnsPerHost: config.Int(50),
+					},
+				},
+			},
+			false,
+		},
+		{
+			"consul-transport-max-idle-conns",
+			[]string{"-consul-transport-max-idle-conns", "200"},
+			&config.Config{
+				Consul: &config.ConsulConfig{
+					Transport: &config.TransportConfig{
+						MaxIdleConns: config.Int(200),
+					},
+				},
+			},
+			false,
+		},
 		{
 			"consul-transport-max-idle-conns-per-host",
 			[]string{"-consul-transport-max-idle-conns-per-host", "100"},
@@ -300,6 +349,42 @@ func TestCLI_ParseFlags(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"dedup-prefix",
+			[]string{"-dedup-prefix", "ct/dedup/"},
+			&config.Config{
+				Dedup: &config.DedupConfig{
+					Prefix: config.String("ct/dedup/"),
+				},
+			},
+			false,
+		},
+		{
+			"dedup-ttl",
+			[]string{"-dedup-ttl", "30s"},
+			&config.Config{
+				Dedup: &config.DedupConfig{
+					TTL: config.TimeDuration(30 * time.Second),
+				},
+			},
+			false,
+		},
+		{
+			"drain-on-shutdown",
+			[]string{"-drain-on-shutdown"},
+			&config.Config{
+				DrainOnShutdown: config.Bool(true),
+			},
+			false,
+		},
+		{
+			"drain-timeout",
+			[]string{"-drain-timeout", "30s"},
+			&config.Config{
+				DrainTimeout: config.TimeDuration(30 * time.Second),
+			},
+			false,
+		},
 		{
 			"exec",
 			[]string{"-exec", "command"},
@@ -525,6 +610,22 @@ func TestCLI_ParseFlags(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"max-render-concurrency",
+			[]string{"-max-render-concurrency", "8"},
+			&config.Config{
+				MaxRenderConcurrency: config.Int(8),
+			},
+			false,
+		},
+		{
+			"reap-zombies",
+			[]string{"-reap-zombies"},
+			&config.Config{
+				ReapZombies: config.Bool(true),
+			},
+			false,
+		},
 		{
 			"reload-signal",
 			[]string{"-reload-signal", "SIGUSR1"},
@@ -585,6 +686,20 @@ func TestCLI_ParseFlags(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"template-error-on-missing-key",
+			[]string{"-template", "/tmp/in.tpl", "-template-error-on-missing-key"},
+			&config.Config{
+				TemplateErrMissingKey: config.Bool(true),
+				Templates: &config.TemplateConfigs{
+					&config.TemplateConfig{
+						Source:        config.String("/tmp/in.tpl"),
+						ErrMissingKey: config.Bool(true),
+					},
+				},
+			},
+			false,
+		},
 		{
 			"vault-addr",
 			[]string{"-vault-addr", "vault_addr"},
@@ -882,6 +997,18 @@ func TestCLI_ParseFlags(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"once-wait-for-ready",
+			[]string{"-once", "-wait-for-ready", "30s"},
+			&config.Config{
+				WaitForReady: config.TimeDuration(30 * time.Second),
+				Wait: &config.WaitConfig{
+					Enabled: config.Bool(false),
+				},
+				Once: true,
+			},
+			false,
+		},
 		{
 			"parse-only",
 			[]string{"-parse-only"},
@@ -890,6 +1017,14 @@ func TestCLI_ParseFlags(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"config-check",
+			[]string{"-config-check"},
+			&config.Config{
+				ConfigCheck: true,
+			},
+			false,
+		},
 	}
 
 	for i, tc := range cases {
@@ -965,6 +1100,80 @@ func TestCLI_Run(t *testing.T) {
 		})
 	}
 
+	t.Run("config_check", func(t *testing.T) {
+		cases := []struct {
+			name   string
+			config string
+			code   int
+			out    []string
+		}{
+			{
+				"valid",
+				`consul {
+					address = "consul.example.com:8500"
+				}
+				vault {
+					address = "https://vault.example.com:8200"
+					renew_token = false
+				}
+				template {
+					source      = "in.ctmpl"
+					destination = "out.txt"
+				}`,
+				ExitCodeOK,
+				[]string{
+					"Configuration is valid.",
+					`consul: address="consul.example.com:8500"`,
+					`vault: address="https://vault.example.com:8200"`,
+					"nomad: disabled",
+					"templates: 1",
+					`"in.ctmpl" => "out.txt"`,
+				},
+			},
+			{
+				"unknown_key",
+				`template {
+					source     = "in.ctmpl"
+					destiantion = "out.txt"
+				}`,
+				ExitCodeConfigError,
+				[]string{"Configuration is invalid", "destiantion"},
+			},
+			{
+				"type_mismatch",
+				`max_stale = "soon"`,
+				ExitCodeConfigError,
+				[]string{"Configuration is invalid", "max_stale"},
+			},
+		}
+
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				f, err := os.CreateTemp("", "")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer os.Remove(f.Name())
+				if _, err := f.WriteString(tc.config); err != nil {
+					t.Fatal(err)
+				}
+
+				out := gatedio.NewByteBuffer()
+				cli := NewCLI(out, out)
+
+				code := cli.Run([]string{"consul-template", "-config-check", "-config", f.Name()})
+				if code != tc.code {
+					t.Errorf("\nexp: %#v\nact: %#v\nout: %s", tc.code, code, out.String())
+				}
+				for _, exp := range tc.out {
+					if !strings.Contains(out.String(), exp) {
+						t.Errorf("\nexp: %q\nact: %q", exp, out.String())
+					}
+				}
+			})
+		}
+	})
+
 	t.Run("once", func(t *testing.T) {
 		f, err := os.CreateTemp("", "")
 		if err != nil {
@@ -1066,4 +1275,67 @@ func TestCLI_Run(t *testing.T) {
 			t.Errorf("timeout: %q", out.String())
 		}
 	})
+
+	t.Run("drain_on_shutdown", func(t *testing.T) {
+		f, err := os.CreateTemp("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+		// The template has no dependencies, so after the initial render it is
+		// only rendered again by the final run on shutdown.
+		if _, err := f.WriteString(`{{ timestamp "2006-01-02T15:04:05.000000000" }}`); err != nil {
+			t.Fatal(err)
+		}
+
+		dest, err := os.CreateTemp("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(dest.Name())
+		defer os.Remove(dest.Name())
+
+		out := gatedio.NewByteBuffer()
+		cli := NewCLI(out, out)
+		defer cli.stop()
+
+		ch := make(chan int, 1)
+		go func() {
+			ch <- cli.Run([]string{
+				"consul-template",
+				"-drain-on-shutdown",
+				"-template", f.Name() + ":" + dest.Name(),
+			})
+		}()
+
+		// Wait for the initial render
+		var initial []byte
+		deadline := time.Now().Add(2 * time.Second)
+		for len(initial) == 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+			initial, _ = os.ReadFile(dest.Name())
+		}
+		if len(initial) == 0 {
+			t.Fatalf("timeout waiting for initial render: %q", out.String())
+		}
+
+		cli.signalCh <- syscall.SIGTERM
+
+		select {
+		case status := <-ch:
+			if status != ExitCodeInterrupt {
+				t.Errorf("\nexp: %#v\nact: %#v", ExitCodeInterrupt, status)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout: %q", out.String())
+		}
+
+		final, err := os.ReadFile(dest.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Equal(initial, final) {
+			t.Errorf("expected a final render on SIGTERM, contents unchanged: %q", final)
+		}
+	})
 }
@@ -4,6 +4,7 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
@@ -16,6 +17,8 @@ import (
 
 	"github.com/hashicorp/consul-template/signals"
 	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	jsonparser "github.com/hashicorp/hcl/json/parser"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/mitchellh/mapstructure"
 
@@ -30,6 +33,10 @@ const (
 	// queries by default for performance reasons.
 	DefaultMaxStale = 2 * time.Second
 
+	// DefaultMaxRenderConcurrency is the default number of templates rendered
+	// in parallel. Templates are rendered one after the other by default.
+	DefaultMaxRenderConcurrency = 1
+
 	// DefaultReloadSignal is the default signal for reload.
 	DefaultReloadSignal = syscall.SIGHUP
 
@@ -38,6 +45,10 @@ const (
 
 	// DefaultBlockQueryWaitTime is amount of time in seconds to do a blocking query for
 	DefaultBlockQueryWaitTime = 60 * time.Second
+
+	// DefaultDrainTimeout is the default deadline for the final render when
+	// draining on shutdown.
+	DefaultDrainTimeout = 10 * time.Second
 )
 
 // homePath is the location to the user's home directory.
@@ -54,9 +65,22 @@ type Config struct {
 	// DefaultDelims is used to configure the default delimiters for templates
 	DefaultDelims *DefaultDelims `mapstructure:"default_delimiters"`
 
+	// DrainOnShutdown, when enabled, makes the process render all templates one
+	// last time with the current data, running any commands, when it receives
+	// SIGTERM or the kill signal, before shutting down.
+	DrainOnShutdown *bool `mapstructure:"drain_on_shutdown"`
+
+	// DrainTimeout is the deadline for the final render when draining on
+	// shutdown. The process exits anyway once it elapses.
+	DrainTimeout *time.Duration `mapstructure:"drain_timeout"`
+
 	// Exec is the configuration for exec/supervise mode.
 	Exec *ExecConfig `mapstructure:"exec"`
 
+	// HealthEndpoint is the configuration for the HTTP endpoint reporting the
+	// liveness and readiness of the process.
+	HealthEndpoint *HealthEndpointConfig `mapstructure:"health_endpoint"`
+
 	// KillSignal is the signal to listen for a graceful terminate event.
 	KillSignal *os.Signal `mapstructure:"kill_signal"`
 
@@ -71,16 +95,29 @@ type Config struct {
 	// of just the leader.
 	MaxStale *time.Duration `mapstructure:"max_stale"`
 
+	// MaxRenderConcurrency is the maximum number of templates rendered in
+	// parallel on each run. The others wait for a free slot, in the order of
+	// the templates.
+	MaxRenderConcurrency *int `mapstructure:"max_render_concurrency"`
+
 	// PidFile is the path on disk where a PID file should be written containing
 	// this processes PID.
 	PidFile *string `mapstructure:"pid_file"`
 
+	// ReapZombies makes the process a child subreaper that reaps orphaned
+	// processes left behind by commands, for use as PID 1 in a container. It
+	// is only supported on Linux.
+	ReapZombies *bool `mapstructure:"reap_zombies"`
+
 	// ReloadSignal is the signal to listen for a reload event.
 	ReloadSignal *os.Signal `mapstructure:"reload_signal"`
 
 	// Syslog is the configuration for syslog.
 	Syslog *SyslogConfig `mapstructure:"syslog"`
 
+	// Telemetry is the configuration for telemetry.
+	Telemetry *TelemetryConfig `mapstructure:"telemetry"`
+
 	// Templates is the list of templates.
 	Templates *TemplateConfigs `mapstructure:"template"`
 
@@ -88,6 +125,10 @@ type Config struct {
 	// process to exit, or just log and continue.
 	TemplateErrFatal *bool `mapstructure:"template_error_fatal"`
 
+	// TemplateErrMissingKey is the default for the error_on_missing_key option
+	// of templates that do not set it themselves.
+	TemplateErrMissingKey *bool `mapstructure:"template_error_on_missing_key"`
+
 	// Vault is the configuration for connecting to a vault server.
 	Vault *VaultConfig `mapstructure:"vault"`
 
@@ -97,6 +138,12 @@ type Config struct {
 	// Wait is the quiescence timers.
 	Wait *WaitConfig `mapstructure:"wait"`
 
+	// WaitForReady is how long dependency errors keep being retried after
+	// startup, even once the configured retries are exhausted. It lets the
+	// first render, typically with -once, wait for upstreams that are briefly
+	// unavailable instead of failing on the first error. Zero disables it.
+	WaitForReady *time.Duration `mapstructure:"wait_for_ready"`
+
 	// Additional command line options
 	// Run once, executing each template exactly once, and exit
 	Once bool
@@ -105,9 +152,23 @@ type Config struct {
 	// checking well formedness.
 	ParseOnly bool
 
+	// ConfigCheck only parses and validates the configuration, prints a
+	// summary of it and exits without connecting to anything.
+	ConfigCheck bool
+
 	// BlockQueryWaitTime is amount of time in seconds to do a blocking query for
 	BlockQueryWaitTime *time.Duration `mapstructure:"block_query_wait"`
 
+	// BlockQueryStallTimeout is how long a blocking query may go without a
+	// response beyond BlockQueryWaitTime before it is considered stalled, for
+	// example on a dead connection, and sent again. Zero disables the check.
+	BlockQueryStallTimeout *time.Duration `mapstructure:"block_query_stall_timeout"`
+
+	// DependencyGracePeriod is how long a dependency that was not used in the
+	// latest render, like one only used in a branch that is no longer taken,
+	// stays watched before it is released. Zero releases it at once.
+	DependencyGracePeriod *time.Duration `mapstructure:"dependency_grace_period"`
+
 	// ErrOnFailedLookup, when enabled, will trigger an error if a dependency
 	// fails to return a value.
 	ErrOnFailedLookup bool `mapstructure:"err_on_failed_lookup"`
@@ -135,17 +196,28 @@ func (c *Config) Copy() *Config {
 		o.DefaultDelims = c.DefaultDelims.Copy()
 	}
 
+	o.DrainOnShutdown = c.DrainOnShutdown
+
+	o.DrainTimeout = c.DrainTimeout
+
 	if c.Exec != nil {
 		o.Exec = c.Exec.Copy()
 	}
 
+	if c.HealthEndpoint != nil {
+		o.HealthEndpoint = c.HealthEndpoint.Copy()
+	}
+
 	o.KillSignal = c.KillSignal
 
 	o.LogLevel = c.LogLevel
 
 	o.MaxStale = c.MaxStale
 
+	o.MaxRenderConcurrency = c.MaxRenderConcurrency
+
 	o.PidFile = c.PidFile
+	o.ReapZombies = c.ReapZombies
 
 	o.ReloadSignal = c.ReloadSignal
 
@@ -157,6 +229,10 @@ func (c *Config) Copy() *Config {
 		o.Syslog = c.Syslog.Copy()
 	}
 
+	if c.Telemetry != nil {
+		o.Telemetry = c.Telemetry.Copy()
+	}
+
 	if c.Templates != nil {
 		o.Templates = c.Templates.Copy()
 	}
@@ -165,6 +241,10 @@ func (c *Config) Copy() *Config {
 		o.TemplateErrFatal = c.TemplateErrFatal
 	}
 
+	if c.TemplateErrMissingKey != nil {
+		o.TemplateErrMissingKey = c.TemplateErrMissingKey
+	}
+
 	if c.Vault != nil {
 		o.Vault = c.Vault.Copy()
 	}
@@ -173,10 +253,15 @@ func (c *Config) Copy() *Config {
 		o.Wait = c.Wait.Copy()
 	}
 
+	o.WaitForReady = c.WaitForReady
+
 	o.Once = c.Once
 	o.ParseOnly = c.ParseOnly
+	o.ConfigCheck = c.ConfigCheck
 	o.ErrOnFailedLookup = c.ErrOnFailedLookup
 	o.BlockQueryWaitTime = c.BlockQueryWaitTime
+	o.BlockQueryStallTimeout = c.BlockQueryStallTimeout
+	o.DependencyGracePeriod = c.DependencyGracePeriod
 
 	if c.Nomad != nil {
 		o.Nomad = c.Nomad.Copy()
@@ -213,10 +298,22 @@ func (c *Config) Merge(o *Config) *Config {
 		r.DefaultDelims = r.DefaultDelims.Merge(o.DefaultDelims)
 	}
 
+	if o.DrainOnShutdown != nil {
+		r.DrainOnShutdown = o.DrainOnShutdown
+	}
+
+	if o.DrainTimeout != nil {
+		r.DrainTimeout = o.DrainTimeout
+	}
+
 	if o.Exec != nil {
 		r.Exec = r.Exec.Merge(o.Exec)
 	}
 
+	if o.HealthEndpoint != nil {
+		r.HealthEndpoint = r.HealthEndpoint.Merge(o.HealthEndpoint)
+	}
+
 	if o.KillSignal != nil {
 		r.KillSignal = o.KillSignal
 	}
@@ -229,10 +326,18 @@ func (c *Config) Merge(o *Config) *Config {
 		r.MaxStale = o.MaxStale
 	}
 
+	if o.MaxRenderConcurrency != nil {
+		r.MaxRenderConcurrency = o.MaxRenderConcurrency
+	}
+
 	if o.PidFile != nil {
 		r.PidFile = o.PidFile
 	}
 
+	if o.ReapZombies != nil {
+		r.ReapZombies = o.ReapZombies
+	}
+
 	if o.ReloadSignal != nil {
 		r.ReloadSignal = o.ReloadSignal
 	}
@@ -245,6 +350,10 @@ func (c *Config) Merge(o *Config) *Config {
 		r.Syslog = r.Syslog.Merge(o.Syslog)
 	}
 
+	if o.Telemetry != nil {
+		r.Telemetry = r.Telemetry.Merge(o.Telemetry)
+	}
+
 	if o.Templates != nil {
 		r.Templates = r.Templates.Merge(o.Templates)
 	}
@@ -253,6 +362,10 @@ func (c *Config) Merge(o *Config) *Config {
 		r.TemplateErrFatal = o.TemplateErrFatal
 	}
 
+	if o.TemplateErrMissingKey != nil {
+		r.TemplateErrMissingKey = o.TemplateErrMissingKey
+	}
+
 	if o.Vault != nil {
 		r.Vault = r.Vault.Merge(o.Vault)
 	}
@@ -261,12 +374,25 @@ func (c *Config) Merge(o *Config) *Config {
 		r.Wait = r.Wait.Merge(o.Wait)
 	}
 
+	if o.WaitForReady != nil {
+		r.WaitForReady = o.WaitForReady
+	}
+
 	if o.BlockQueryWaitTime != nil {
 		r.BlockQueryWaitTime = o.BlockQueryWaitTime
 	}
 
+	if o.BlockQueryStallTimeout != nil {
+		r.BlockQueryStallTimeout = o.BlockQueryStallTimeout
+	}
+
+	if o.DependencyGracePeriod != nil {
+		r.DependencyGracePeriod = o.DependencyGracePeriod
+	}
+
 	r.Once = o.Once
 	r.ParseOnly = o.ParseOnly
+	r.ConfigCheck = o.ConfigCheck
 	if o.ErrOnFailedLookup {
 		r.ErrOnFailedLookup = o.ErrOnFailedLookup
 	}
@@ -278,10 +404,41 @@ func (c *Config) Merge(o *Config) *Config {
 	return r
 }
 
-// Parse parses the given string contents as a config
+// Parse parses the given string contents as a config. The contents are HCL,
+// or JSON if they start with a "{".
 func Parse(s string) (*Config, error) {
+	format := "HCL"
+	if strings.HasPrefix(strings.TrimSpace(s), "{") {
+		format = "JSON"
+	}
+	root, err := hcl.ParseString(s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error decoding %s config", format)
+	}
+	return parseRoot(root)
+}
+
+// ParseJSON parses the given string contents as a JSON config, which has the
+// same structure as the HCL config.
+func ParseJSON(s string) (*Config, error) {
+	// The HCL JSON parser accepts some malformed JSON, like a missing closing
+	// brace, so check the syntax first.
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return nil, errors.Wrap(err, "error decoding JSON config")
+	}
+
+	root, err := jsonparser.Parse([]byte(s))
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding JSON config")
+	}
+	return parseRoot(root)
+}
+
+// parseRoot decodes a parsed HCL or JSON config.
+func parseRoot(root *ast.File) (*Config, error) {
 	var shadow interface{}
-	if err := hcl.Decode(&shadow, s); err != nil {
+	if err := hcl.DecodeObject(&shadow, root); err != nil {
 		return nil, errors.Wrap(err, "error decoding config")
 	}
 
@@ -295,6 +452,7 @@ func Parse(s string) (*Config, error) {
 		"auth",
 		"consul",
 		"consul.auth",
+		"consul.rate_limit",
 		"consul.retry",
 		"consul.ssl",
 		"consul.transport",
@@ -303,13 +461,19 @@ func Parse(s string) (*Config, error) {
 		"env",
 		"exec",
 		"exec.env",
+		"health_endpoint",
 		"log_file",
 		"nomad",
 		"nomad.ssl",
 		"nomad.transport",
 		"ssl",
 		"syslog",
+		"telemetry",
+		"telemetry.otel",
 		"vault",
+		"vault.auth",
+		"vault.auth.config",
+		"vault.rate_limit",
 		"vault.retry",
 		"vault.ssl",
 		"vault.transport",
@@ -354,6 +518,15 @@ func Parse(s string) (*Config, error) {
 		return nil, errors.Wrap(err, "mapstructure decode failed")
 	}
 
+	// Expand directory-mode templates into one template per file.
+	if err := c.Templates.expandDirs(); err != nil {
+		return nil, err
+	}
+
+	if err := c.Vault.validate(); err != nil {
+		return nil, err
+	}
+
 	return &c, nil
 }
 
@@ -376,14 +549,19 @@ func TestConfig(c *Config) *Config {
 }
 
 // FromFile reads the configuration file at the given path and returns a new
-// Config struct with the data populated.
+// Config struct with the data populated. Files with a ".json" extension are
+// parsed as JSON, other files as HCL.
 func FromFile(path string) (*Config, error) {
 	c, err := os.ReadFile(path)
 	if err != nil {
 		return nil, errors.Wrap(err, "from file: "+path)
 	}
 
-	config, err := Parse(string(c))
+	parse := Parse
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		parse = ParseJSON
+	}
+	config, err := parse(string(c))
 	if err != nil {
 		return nil, errors.Wrap(err, "from file: "+path)
 	}
@@ -459,39 +637,59 @@ func (c *Config) GoString() string {
 		"Consul:%#v, "+
 		"Dedup:%#v, "+
 		"DefaultDelims:%#v, "+
+		"DrainOnShutdown:%s, "+
+		"DrainTimeout:%s, "+
 		"Exec:%#v, "+
+		"HealthEndpoint:%#v, "+
 		"KillSignal:%s, "+
 		"LogLevel:%s, "+
 		"MaxStale:%s, "+
+		"MaxRenderConcurrency:%s, "+
 		"PidFile:%s, "+
+		"ReapZombies:%s, "+
 		"ReloadSignal:%s, "+
 		"FileLog:%#v, "+
 		"Syslog:%#v, "+
+		"Telemetry:%#v, "+
 		"Templates:%#v, "+
-		"TemplateErrFatal:%#v"+
+		"TemplateErrFatal:%#v, "+
+		"TemplateErrMissingKey:%s, "+
 		"Vault:%#v, "+
 		"Wait:%#v, "+
+		"WaitForReady:%s, "+
 		"Once:%#v, "+
 		"BlockQueryWaitTime:%#v, "+
+		"BlockQueryStallTimeout:%#v, "+
+		"DependencyGracePeriod:%#v, "+
 		"ErrOnFailedLookup:%#v"+
 		"}",
 		c.Consul,
 		c.Dedup,
 		c.DefaultDelims,
+		BoolGoString(c.DrainOnShutdown),
+		TimeDurationGoString(c.DrainTimeout),
 		c.Exec,
+		c.HealthEndpoint,
 		SignalGoString(c.KillSignal),
 		StringGoString(c.LogLevel),
 		TimeDurationGoString(c.MaxStale),
+		IntGoString(c.MaxRenderConcurrency),
 		StringGoString(c.PidFile),
+		BoolGoString(c.ReapZombies),
 		SignalGoString(c.ReloadSignal),
 		c.FileLog,
 		c.Syslog,
+		c.Telemetry,
 		c.Templates,
 		c.TemplateErrFatal,
+		BoolGoString(c.TemplateErrMissingKey),
 		c.Vault,
 		c.Wait,
+		TimeDurationGoString(c.WaitForReady),
 		c.Once,
 		TimeDurationGoString(c.BlockQueryWaitTime),
+		TimeDurationGoString(c.BlockQueryStallTimeout),
+		TimeDurationGoString(c.DependencyGracePeriod),
 		c.ErrOnFailedLookup,
 	)
 }
@@ -527,16 +725,18 @@ func (expected *Config) Diff(actual *Config) string {
 // variables may be set which control the values for the default configuration.
 func DefaultConfig() *Config {
 	return &Config{
-		Consul:        DefaultConsulConfig(),
-		Dedup:         DefaultDedupConfig(),
-		DefaultDelims: DefaultDefaultDelims(),
-		Exec:          DefaultExecConfig(),
-		FileLog:       DefaultLogFileConfig(),
-		Nomad:         DefaultNomadConfig(),
-		Syslog:        DefaultSyslogConfig(),
-		Templates:     DefaultTemplateConfigs(),
-		Vault:         DefaultVaultConfig(),
-		Wait:          DefaultWaitConfig(),
+		Consul:         DefaultConsulConfig(),
+		Dedup:          DefaultDedupConfig(),
+		DefaultDelims:  DefaultDefaultDelims(),
+		Exec:           DefaultExecConfig(),
+		HealthEndpoint: DefaultHealthEndpointConfig(),
+		FileLog:        DefaultLogFileConfig(),
+		Nomad:          DefaultNomadConfig(),
+		Syslog:         DefaultSyslogConfig(),
+		Telemetry:      DefaultTelemetryConfig(),
+		Templates:      DefaultTemplateConfigs(),
+		Vault:          DefaultVaultConfig(),
+		Wait:           DefaultWaitConfig(),
 	}
 }
 
@@ -563,11 +763,24 @@ func (c *Config) Finalize() {
 		c.DefaultDelims = DefaultDefaultDelims()
 	}
 
+	if c.DrainOnShutdown == nil {
+		c.DrainOnShutdown = Bool(false)
+	}
+
+	if c.DrainTimeout == nil {
+		c.DrainTimeout = TimeDuration(DefaultDrainTimeout)
+	}
+
 	if c.Exec == nil {
 		c.Exec = DefaultExecConfig()
 	}
 	c.Exec.Finalize()
 
+	if c.HealthEndpoint == nil {
+		c.HealthEndpoint = DefaultHealthEndpointConfig()
+	}
+	c.HealthEndpoint.Finalize()
+
 	if c.KillSignal == nil {
 		c.KillSignal = Signal(DefaultKillSignal)
 	}
@@ -584,10 +797,18 @@ func (c *Config) Finalize() {
 		c.MaxStale = TimeDuration(DefaultMaxStale)
 	}
 
+	if c.MaxRenderConcurrency == nil {
+		c.MaxRenderConcurrency = Int(DefaultMaxRenderConcurrency)
+	}
+
 	if c.PidFile == nil {
 		c.PidFile = String("")
 	}
 
+	if c.ReapZombies == nil {
+		c.ReapZombies = Bool(false)
+	}
+
 	if c.ReloadSignal == nil {
 		c.ReloadSignal = Signal(DefaultReloadSignal)
 	}
@@ -607,6 +828,11 @@ func (c *Config) Finalize() {
 	}
 	c.Syslog.Finalize()
 
+	if c.Telemetry == nil {
+		c.Telemetry = DefaultTelemetryConfig()
+	}
+	c.Telemetry.Finalize()
+
 	if c.Templates == nil {
 		c.Templates = DefaultTemplateConfigs()
 	}
@@ -614,6 +840,9 @@ func (c *Config) Finalize() {
 		if tmpl.ErrFatal == nil {
 			tmpl.ErrFatal = c.TemplateErrFatal
 		}
+		if tmpl.ErrMissingKey == nil {
+			tmpl.ErrMissingKey = c.TemplateErrMissingKey
+		}
 	}
 	c.Templates.Finalize()
 
@@ -632,10 +861,22 @@ func (c *Config) Finalize() {
 		c.Wait = &WaitConfig{Enabled: Bool(false)}
 	}
 
+	if c.WaitForReady == nil {
+		c.WaitForReady = TimeDuration(0)
+	}
+
 	// defaults WaitTime to 60 seconds
 	if c.BlockQueryWaitTime == nil {
 		c.BlockQueryWaitTime = TimeDuration(DefaultBlockQueryWaitTime)
 	}
+
+	if c.BlockQueryStallTimeout == nil {
+		c.BlockQueryStallTimeout = TimeDuration(0)
+	}
+
+	if c.DependencyGracePeriod == nil {
+		c.DependencyGracePeriod = TimeDuration(0)
+	}
 }
 
 func stringFromEnv(list []string, def string) *string {
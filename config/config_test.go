@@ -6,8 +6,10 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
@@ -61,6 +63,42 @@ func TestParse(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"consul_datacenter",
+			`consul {
+				datacenter = "dc2"
+			}`,
+			&Config{
+				Consul: &ConsulConfig{
+					Datacenter: String("dc2"),
+				},
+			},
+			false,
+		},
+		{
+			"consul_partition",
+			`consul {
+				partition = "team1"
+			}`,
+			&Config{
+				Consul: &ConsulConfig{
+					Partition: String("team1"),
+				},
+			},
+			false,
+		},
+		{
+			"consul_proxy",
+			`consul {
+				proxy = "http://proxy.internal:3128"
+			}`,
+			&Config{
+				Consul: &ConsulConfig{
+					Proxy: String("http://proxy.internal:3128"),
+				},
+			},
+			false,
+		},
 		{
 			"consul_auth",
 			`consul {
@@ -79,6 +117,24 @@ func TestParse(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"consul_rate_limit",
+			`consul {
+				rate_limit {
+					qps   = 2.5
+					burst = 5
+				}
+			}`,
+			&Config{
+				Consul: &ConsulConfig{
+					RateLimit: &RateLimitConfig{
+						QPS:   Float64(2.5),
+						Burst: Int(5),
+					},
+				},
+			},
+			false,
+		},
 		{
 			"consul_retry",
 			`consul {
@@ -281,6 +337,38 @@ func TestParse(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"consul_transport_enable_http2",
+			`consul {
+				transport {
+					enable_http2 = true
+				}
+			}`,
+			&Config{
+				Consul: &ConsulConfig{
+					Transport: &TransportConfig{
+						EnableHTTP2: Bool(true),
+					},
+				},
+			},
+			false,
+		},
+		{
+			"consul_transport_max_conns_per_host",
+			`consul {
+				transport {
+					max_conns_per_host = 50
+				}
+			}`,
+			&Config{
+				Consul: &ConsulConfig{
+					Transport: &TransportConfig{
+						MaxConnsPerHost: Int(50),
+					},
+				},
+			},
+			false,
+		},
 		{
 			"consul_transport_max_idle_conns_per_host",
 			`consul {
@@ -513,6 +601,18 @@ func TestParse(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"exec_log_child_output",
+			`exec {
+				log_child_output = true
+			 }`,
+			&Config{
+				Exec: &ExecConfig{
+					LogChildOutput: Bool(true),
+				},
+			},
+			false,
+		},
 		{
 			"exec_splay",
 			`exec {
@@ -537,6 +637,22 @@ func TestParse(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"drain_on_shutdown",
+			`drain_on_shutdown = true`,
+			&Config{
+				DrainOnShutdown: Bool(true),
+			},
+			false,
+		},
+		{
+			"drain_timeout",
+			`drain_timeout = "30s"`,
+			&Config{
+				DrainTimeout: TimeDuration(30 * time.Second),
+			},
+			false,
+		},
 		{
 			"kill_signal",
 			`kill_signal = "SIGUSR1"`,
@@ -637,6 +753,22 @@ func TestParse(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"block_query_stall_timeout",
+			`block_query_stall_timeout = "30s"`,
+			&Config{
+				BlockQueryStallTimeout: TimeDuration(30 * time.Second),
+			},
+			false,
+		},
+		{
+			"dependency_grace_period",
+			`dependency_grace_period = "5m"`,
+			&Config{
+				DependencyGracePeriod: TimeDuration(5 * time.Minute),
+			},
+			false,
+		},
 		{
 			"pid_file",
 			`pid_file = "/var/pid"`,
@@ -645,6 +777,22 @@ func TestParse(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"max_render_concurrency",
+			`max_render_concurrency = 8`,
+			&Config{
+				MaxRenderConcurrency: Int(8),
+			},
+			false,
+		},
+		{
+			"reap_zombies",
+			`reap_zombies = true`,
+			&Config{
+				ReapZombies: Bool(true),
+			},
+			false,
+		},
 		{
 			"reload_signal",
 			`reload_signal = "SIGUSR1"`,
@@ -749,6 +897,46 @@ func TestParse(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"syslog_tag",
+			`syslog {
+				tag = "tag"
+			}`,
+			&Config{
+				Syslog: &SyslogConfig{
+					Tag: String("tag"),
+				},
+			},
+			false,
+		},
+		{
+			"health_endpoint",
+			`health_endpoint {
+				address = "127.0.0.1:8080"
+			}`,
+			&Config{
+				HealthEndpoint: &HealthEndpointConfig{
+					Address: String("127.0.0.1:8080"),
+				},
+			},
+			false,
+		},
+		{
+			"telemetry_otel",
+			`telemetry {
+				otel {
+					endpoint = "http://localhost:4318"
+				}
+			}`,
+			&Config{
+				Telemetry: &TelemetryConfig{
+					OTel: &OTelConfig{
+						Endpoint: String("http://localhost:4318"),
+					},
+				},
+			},
+			false,
+		},
 		{
 			"template",
 			`template {}`,
@@ -1246,6 +1434,138 @@ func TestParse(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"template_generation_file",
+			`template {
+				generation_file = "/var/lib/ct/out.gen"
+			}`,
+			&Config{
+				Templates: &TemplateConfigs{
+					&TemplateConfig{
+						GenerationFile: String("/var/lib/ct/out.gen"),
+					},
+				},
+			},
+			false,
+		},
+		{
+			"template_stream",
+			`template {
+				stream = true
+			}`,
+			&Config{
+				Templates: &TemplateConfigs{
+					&TemplateConfig{
+						Stream: Bool(true),
+					},
+				},
+			},
+			false,
+		},
+		{
+			"template_line_ending_bom",
+			`template {
+				line_ending = "crlf"
+				bom = true
+			}`,
+			&Config{
+				Templates: &TemplateConfigs{
+					&TemplateConfig{
+						LineEnding: String("crlf"),
+						BOM:        Bool(true),
+					},
+				},
+			},
+			false,
+		},
+		{
+			"template_validate",
+			`template {
+				validate = "yaml"
+			}`,
+			&Config{
+				Templates: &TemplateConfigs{
+					&TemplateConfig{
+						Validate: String("yaml"),
+					},
+				},
+			},
+			false,
+		},
+		{
+			"template_env_var",
+			`template {
+				contents = "{{ key \"token\" }}"
+				env_var = "APP_TOKEN"
+			}`,
+			&Config{
+				Templates: &TemplateConfigs{
+					&TemplateConfig{
+						Contents:                 String(`{{ key "token" }}`),
+						MapToEnvironmentVariable: String("APP_TOKEN"),
+					},
+				},
+			},
+			false,
+		},
+		{
+			"template_fan_out",
+			`template {
+				destination = "/etc/services.d"
+				fan_out = true
+			}`,
+			&Config{
+				Templates: &TemplateConfigs{
+					&TemplateConfig{
+						Destination: String("/etc/services.d"),
+						FanOut:      Bool(true),
+					},
+				},
+			},
+			false,
+		},
+		{
+			"template_render_once",
+			`template {
+				render_once = true
+			}`,
+			&Config{
+				Templates: &TemplateConfigs{
+					&TemplateConfig{
+						RenderOnce: Bool(true),
+					},
+				},
+			},
+			false,
+		},
+		{
+			"template_ignore_dep_errors",
+			`template {
+				ignore_dep_errors = ["vault.read(secret/team-*/db)", "vault.list(secret/team-*)"]
+			}`,
+			&Config{
+				Templates: &TemplateConfigs{
+					&TemplateConfig{
+						IgnoreDepErrors: []string{"vault.read(secret/team-*/db)", "vault.list(secret/team-*)"},
+					},
+				},
+			},
+			false,
+		},
+		{
+			"template_command_trigger",
+			`template {
+				command_trigger = ["key(service/web/replicas)", "vault.read(secret/web/*)"]
+			}`,
+			&Config{
+				Templates: &TemplateConfigs{
+					&TemplateConfig{
+						CommandTrigger: []string{"key(service/web/replicas)", "vault.read(secret/web/*)"},
+					},
+				},
+			},
+			false,
+		},
 		{
 			"template_wait",
 			`template {
@@ -1311,6 +1631,14 @@ func TestParse(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"template_error_on_missing_key",
+			`template_error_on_missing_key = true`,
+			&Config{
+				TemplateErrMissingKey: Bool(true),
+			},
+			false,
+		},
 		{
 			"vault",
 			`vault {}`,
@@ -1343,6 +1671,96 @@ func TestParse(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"vault_auth",
+			`vault {
+				auth {
+					method = "approle"
+					mount  = "ci"
+					config {
+						role_id        = "role"
+						secret_id_file = "/run/secret-id"
+					}
+				}
+			}`,
+			&Config{
+				Vault: &VaultConfig{
+					Auth: &VaultAuthConfig{
+						Method: String("approle"),
+						Mount:  String("ci"),
+						Config: map[string]string{
+							"role_id":        "role",
+							"secret_id_file": "/run/secret-id",
+						},
+					},
+				},
+			},
+			false,
+		},
+		{
+			"vault_proxy",
+			`vault {
+				proxy = "socks5://proxy.internal:1080"
+			}`,
+			&Config{
+				Vault: &VaultConfig{
+					Proxy: String("socks5://proxy.internal:1080"),
+				},
+			},
+			false,
+		},
+		{
+			"vault_rate_limit",
+			`vault {
+				rate_limit {
+					qps = 10
+				}
+			}`,
+			&Config{
+				Vault: &VaultConfig{
+					RateLimit: &RateLimitConfig{
+						QPS: Float64(10),
+					},
+				},
+			},
+			false,
+		},
+		{
+			"vault_pki_renew_threshold",
+			`vault {
+				pki_renew_threshold = 0.8
+			}`,
+			&Config{
+				Vault: &VaultConfig{
+					PKIRenewThreshold: Float64(0.8),
+				},
+			},
+			false,
+		},
+		{
+			"vault_pki_renew_threshold_zero",
+			`vault {
+				pki_renew_threshold = 0
+			}`,
+			nil,
+			true,
+		},
+		{
+			"vault_pki_renew_threshold_one",
+			`vault {
+				pki_renew_threshold = 1
+			}`,
+			nil,
+			true,
+		},
+		{
+			"vault_pki_renew_threshold_above_one",
+			`vault {
+				pki_renew_threshold = 1.5
+			}`,
+			nil,
+			true,
+		},
 		{
 			"vault_user_agent",
 			`vault {
@@ -1726,6 +2144,14 @@ func TestParse(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"wait_for_ready",
+			`wait_for_ready = "30s"`,
+			&Config{
+				WaitForReady: TimeDuration(30 * time.Second),
+			},
+			false,
+		},
 
 		// Parse JSON file permissions as a string. There is a mapstructure
 		// function for testing this, but this is double-tested because it has
@@ -2046,6 +2472,54 @@ func TestFinalize(t *testing.T) {
 				},
 			},
 		},
+		{
+			"template_error_on_missing_key",
+			func(act, exp *Config) (bool, error) {
+				for i, tA := range *act.Templates {
+					tE := (*exp.Templates)[i]
+					if BoolVal(tE.ErrMissingKey) != BoolVal(tA.ErrMissingKey) {
+						return false, fmt.Errorf("template %d\nexp: %#v\nact: %#v",
+							i, BoolVal(tE.ErrMissingKey), BoolVal(tA.ErrMissingKey))
+					}
+				}
+				return true, nil
+			},
+			&Config{
+				TemplateErrMissingKey: Bool(true),
+				Templates: &TemplateConfigs{
+					&TemplateConfig{},
+					&TemplateConfig{
+						ErrMissingKey: Bool(false),
+					},
+				},
+			},
+			&Config{
+				Templates: &TemplateConfigs{
+					&TemplateConfig{
+						ErrMissingKey: Bool(true),
+					},
+					&TemplateConfig{
+						ErrMissingKey: Bool(false),
+					},
+				},
+			},
+		},
+		{
+			"template_error_on_missing_key_default",
+			func(act, exp *Config) (bool, error) {
+				tA := (*act.Templates)[0]
+				if BoolVal(tA.ErrMissingKey) {
+					return false, fmt.Errorf("expected missing keys to be allowed by default")
+				}
+				return true, nil
+			},
+			&Config{
+				Templates: &TemplateConfigs{
+					&TemplateConfig{},
+				},
+			},
+			nil,
+		},
 	}
 
 	for i, tc := range cases {
@@ -2150,6 +2624,30 @@ func TestConfig_Merge(t *testing.T) {
 				},
 			},
 		},
+		{
+			"drain_on_shutdown",
+			&Config{
+				DrainOnShutdown: Bool(false),
+			},
+			&Config{
+				DrainOnShutdown: Bool(true),
+			},
+			&Config{
+				DrainOnShutdown: Bool(true),
+			},
+		},
+		{
+			"drain_timeout",
+			&Config{
+				DrainTimeout: TimeDuration(10 * time.Second),
+			},
+			&Config{
+				DrainTimeout: TimeDuration(30 * time.Second),
+			},
+			&Config{
+				DrainTimeout: TimeDuration(30 * time.Second),
+			},
+		},
 		{
 			"kill_signal",
 			&Config{
@@ -2228,6 +2726,30 @@ func TestConfig_Merge(t *testing.T) {
 				BlockQueryWaitTime: TimeDuration(1 * time.Second),
 			},
 		},
+		{
+			"block_query_stall_timeout",
+			&Config{
+				BlockQueryStallTimeout: TimeDuration(10 * time.Second),
+			},
+			&Config{
+				BlockQueryStallTimeout: TimeDuration(30 * time.Second),
+			},
+			&Config{
+				BlockQueryStallTimeout: TimeDuration(30 * time.Second),
+			},
+		},
+		{
+			"dependency_grace_period",
+			&Config{
+				DependencyGracePeriod: TimeDuration(10 * time.Second),
+			},
+			&Config{
+				DependencyGracePeriod: TimeDuration(30 * time.Second),
+			},
+			&Config{
+				DependencyGracePeriod: TimeDuration(30 * time.Second),
+			},
+		},
 		{
 			"pid_file",
 			&Config{
@@ -2240,6 +2762,30 @@ func TestConfig_Merge(t *testing.T) {
 				PidFile: String("pid_file-diff"),
 			},
 		},
+		{
+			"max_render_concurrency",
+			&Config{
+				MaxRenderConcurrency: Int(1),
+			},
+			&Config{
+				MaxRenderConcurrency: Int(8),
+			},
+			&Config{
+				MaxRenderConcurrency: Int(8),
+			},
+		},
+		{
+			"reap_zombies",
+			&Config{
+				ReapZombies: Bool(false),
+			},
+			&Config{
+				ReapZombies: Bool(true),
+			},
+			&Config{
+				ReapZombies: Bool(true),
+			},
+		},
 		{
 			"reload_signal",
 			&Config{
@@ -2297,6 +2843,18 @@ func TestConfig_Merge(t *testing.T) {
 				},
 			},
 		},
+		{
+			"template_error_on_missing_key",
+			&Config{
+				TemplateErrMissingKey: Bool(false),
+			},
+			&Config{
+				TemplateErrMissingKey: Bool(true),
+			},
+			&Config{
+				TemplateErrMissingKey: Bool(true),
+			},
+		},
 		{
 			"vault",
 			&Config{
@@ -2336,6 +2894,18 @@ func TestConfig_Merge(t *testing.T) {
 				},
 			},
 		},
+		{
+			"wait_for_ready",
+			&Config{
+				WaitForReady: TimeDuration(10 * time.Second),
+			},
+			&Config{
+				WaitForReady: TimeDuration(30 * time.Second),
+			},
+			&Config{
+				WaitForReady: TimeDuration(30 * time.Second),
+			},
+		},
 		{
 			"parse-only",
 			&Config{
@@ -2348,6 +2918,18 @@ func TestConfig_Merge(t *testing.T) {
 				ParseOnly: true,
 			},
 		},
+		{
+			"config-check",
+			&Config{
+				ConfigCheck: false,
+			},
+			&Config{
+				ConfigCheck: true,
+			},
+			&Config{
+				ConfigCheck: true,
+			},
+		},
 	}
 
 	for i, tc := range cases {
@@ -2453,6 +3035,160 @@ func TestFromPath(t *testing.T) {
 	}
 }
 
+func TestFromFile_json(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	hclPath := write("config.hcl", `
+		log_level = "debug"
+		max_stale = "10s"
+		consul {
+			address = "127.0.0.1:8500"
+			retry {
+				attempts = 5
+			}
+		}
+		vault {
+			address = "https://vault.service.consul:8200"
+			rate_limit {
+				qps = 10
+			}
+			ssl {
+				verify = false
+			}
+		}
+		wait {
+			min = "5s"
+			max = "10s"
+		}
+		template {
+			source      = "in.ctmpl"
+			destination = "out.txt"
+			command     = ["systemctl", "reload", "nginx"]
+			exec {
+				env {
+					allowlist = ["PATH"]
+				}
+			}
+		}
+		template {
+			contents    = "{{ key \"foo\" }}"
+			destination = "foo.txt"
+			perms       = 0600
+		}
+	`)
+	jsonPath := write("config.json", `{
+		"log_level": "debug",
+		"max_stale": "10s",
+		"consul": {
+			"address": "127.0.0.1:8500",
+			"retry": {
+				"attempts": 5
+			}
+		},
+		"vault": {
+			"address": "https://vault.service.consul:8200",
+			"rate_limit": {
+				"qps": 10
+			},
+			"ssl": {
+				"verify": false
+			}
+		},
+		"wait": {
+			"min": "5s",
+			"max": "10s"
+		},
+		"template": [
+			{
+				"source": "in.ctmpl",
+				"destination": "out.txt",
+				"command": ["systemctl", "reload", "nginx"],
+				"exec": {
+					"env": {
+						"allowlist": ["PATH"]
+					}
+				}
+			},
+			{
+				"contents": "{{ key \"foo\" }}",
+				"destination": "foo.txt",
+				"perms": 384
+			}
+		]
+	}`)
+
+	exp, err := FromFile(hclPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	act, err := FromFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if act.Templates == nil || len(*act.Templates) != 2 {
+		t.Fatalf("expected 2 templates, got %#v", act.Templates)
+	}
+	if !reflect.DeepEqual(exp, act) {
+		t.Errorf("JSON config differs from HCL config:%s", exp.Diff(act))
+	}
+
+	t.Run("merged_with_hcl", func(t *testing.T) {
+		mergeDir := filepath.Join(dir, "merge")
+		if err := os.Mkdir(mergeDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		write("merge/a.hcl", `consul { address = "1.2.3.4" }`)
+		write("merge/b.json", `{"consul": {"token": "token"}}`)
+
+		c, err := FromPath(mergeDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := &Config{
+			Consul: &ConsulConfig{
+				Address: String("1.2.3.4"),
+				Token:   String("token"),
+			},
+		}
+		if !reflect.DeepEqual(e, c) {
+			t.Errorf("\nexp: %#v\nact: %#v", e, c)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		cases := []struct {
+			name     string
+			contents string
+			format   string
+		}{
+			{"bad.json", `{"consul": {"address": "1.2.3.4"}`, "JSON"},
+			// The extension decides the format, so HCL in a .json file fails.
+			{"hcl.json", `consul { address = "1.2.3.4" }`, "JSON"},
+			{"bad.hcl", `consul { address = }`, "HCL"},
+		}
+		for _, tc := range cases {
+			path := write(tc.name, tc.contents)
+			_, err := FromFile(path)
+			if err == nil {
+				t.Fatalf("%s: expected an error", tc.name)
+			}
+			if !strings.Contains(err.Error(), path) {
+				t.Errorf("%s: expected the file in the error, got %q", tc.name, err)
+			}
+			if !strings.Contains(err.Error(), tc.format+" config") {
+				t.Errorf("%s: expected the %s format in the error, got %q", tc.name, tc.format, err)
+			}
+		}
+	})
+}
+
 func TestDefaultConfig(t *testing.T) {
 	// Can't use t.Parallel() as this sets/unsets environment variables
 	cases := []struct {
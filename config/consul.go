@@ -11,10 +11,28 @@ type ConsulConfig struct {
 	// Address is the address of the Consul server. It may be an IP or FQDN.
 	Address *string
 
+	// Datacenter is the datacenter of the queries that do not name one with
+	// "@<DATACENTER>". If empty, the datacenter of the Consul agent is used.
+	Datacenter *string `mapstructure:"datacenter"`
+
 	// Namespace is the Consul namespace to use for reading/writing. This can
 	// also be set via the CONSUL_NAMESPACE environment variable.
 	Namespace *string `mapstructure:"namespace"`
 
+	// Partition is the Consul Enterprise admin partition of the queries that do
+	// not name one with "?partition=". This can also be set via the
+	// CONSUL_PARTITION environment variable.
+	Partition *string `mapstructure:"partition"`
+
+	// Proxy is the URL of the HTTP(S) or SOCKS5 proxy to use to reach Consul,
+	// e.g. "socks5://127.0.0.1:1080". If empty, the proxy from the environment
+	// is used.
+	Proxy *string `mapstructure:"proxy"`
+
+	// RateLimit caps the rate of requests to Consul. Requests over the limit wait
+	// instead of failing.
+	RateLimit *RateLimitConfig `mapstructure:"rate_limit"`
+
 	// Auth is the HTTP basic authentication for communicating with Consul.
 	Auth *AuthConfig `mapstructure:"auth"`
 
@@ -40,6 +58,7 @@ type ConsulConfig struct {
 func DefaultConsulConfig() *ConsulConfig {
 	return &ConsulConfig{
 		Auth:      DefaultAuthConfig(),
+		RateLimit: DefaultRateLimitConfig(),
 		Retry:     DefaultRetryConfig(),
 		SSL:       DefaultSSLConfig(),
 		Transport: DefaultTransportConfig(),
@@ -56,8 +75,18 @@ func (c *ConsulConfig) Copy() *ConsulConfig {
 
 	o.Address = c.Address
 
+	o.Datacenter = c.Datacenter
+
 	o.Namespace = c.Namespace
 
+	o.Partition = c.Partition
+
+	o.Proxy = c.Proxy
+
+	if c.RateLimit != nil {
+		o.RateLimit = c.RateLimit.Copy()
+	}
+
 	if c.Auth != nil {
 		o.Auth = c.Auth.Copy()
 	}
@@ -102,10 +131,26 @@ func (c *ConsulConfig) Merge(o *ConsulConfig) *ConsulConfig {
 		r.Address = o.Address
 	}
 
+	if o.Datacenter != nil {
+		r.Datacenter = o.Datacenter
+	}
+
 	if o.Namespace != nil {
 		r.Namespace = o.Namespace
 	}
 
+	if o.Partition != nil {
+		r.Partition = o.Partition
+	}
+
+	if o.Proxy != nil {
+		r.Proxy = o.Proxy
+	}
+
+	if o.RateLimit != nil {
+		r.RateLimit = r.RateLimit.Merge(o.RateLimit)
+	}
+
 	if o.Auth != nil {
 		r.Auth = r.Auth.Merge(o.Auth)
 	}
@@ -141,10 +186,27 @@ func (c *ConsulConfig) Finalize() {
 		}, "")
 	}
 
+	if c.Datacenter == nil {
+		c.Datacenter = String("")
+	}
+
 	if c.Namespace == nil {
 		c.Namespace = stringFromEnv([]string{"CONSUL_NAMESPACE"}, "")
 	}
 
+	if c.Partition == nil {
+		c.Partition = stringFromEnv([]string{"CONSUL_PARTITION"}, "")
+	}
+
+	if c.Proxy == nil {
+		c.Proxy = String("")
+	}
+
+	if c.RateLimit == nil {
+		c.RateLimit = DefaultRateLimitConfig()
+	}
+	c.RateLimit.Finalize()
+
 	if c.Auth == nil {
 		c.Auth = DefaultAuthConfig()
 	}
@@ -188,7 +250,11 @@ func (c *ConsulConfig) GoString() string {
 
 	return fmt.Sprintf("&ConsulConfig{"+
 		"Address:%s, "+
+		"Datacenter:%s, "+
 		"Namespace:%s, "+
+		"Partition:%s, "+
+		"Proxy:%s, "+
+		"RateLimit:%#v, "+
 		"Auth:%#v, "+
 		"Retry:%#v, "+
 		"SSL:%#v, "+
@@ -197,7 +263,11 @@ func (c *ConsulConfig) GoString() string {
 		"Transport:%#v"+
 		"}",
 		StringGoString(c.Address),
+		StringGoString(c.Datacenter),
 		StringGoString(c.Namespace),
+		StringGoString(c.Partition),
+		StringGoString(c.Proxy),
+		c.RateLimit,
 		c.Auth,
 		c.Retry,
 		c.SSL,
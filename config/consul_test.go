@@ -26,13 +26,16 @@ func TestConsulConfig_Copy(t *testing.T) {
 		{
 			"same_enabled",
 			&ConsulConfig{
-				Address:   String("1.2.3.4"),
-				Namespace: String("foo"),
-				Auth:      &AuthConfig{Enabled: Bool(true)},
-				Retry:     &RetryConfig{Enabled: Bool(true)},
-				SSL:       &SSLConfig{Enabled: Bool(true)},
-				Token:     String("abcd1234"),
-				TokenFile: String("/a/very/secret/path"),
+				Address:    String("1.2.3.4"),
+				Datacenter: String("dc2"),
+				Namespace:  String("foo"),
+				Partition:  String("bar"),
+				Auth:       &AuthConfig{Enabled: Bool(true)},
+				RateLimit:  &RateLimitConfig{QPS: Float64(5)},
+				Retry:      &RetryConfig{Enabled: Bool(true)},
+				SSL:        &SSLConfig{Enabled: Bool(true)},
+				Token:      String("abcd1234"),
+				TokenFile:  String("/a/very/secret/path"),
 				Transport: &TransportConfig{
 					DialKeepAlive: TimeDuration(20 * time.Second),
 				},
@@ -105,6 +108,18 @@ func TestConsulConfig_Merge(t *testing.T) {
 			&ConsulConfig{Address: String("same")},
 			&ConsulConfig{Address: String("same")},
 		},
+		{
+			"datacenter_overrides",
+			&ConsulConfig{Datacenter: String("dc1")},
+			&ConsulConfig{Datacenter: String("dc2")},
+			&ConsulConfig{Datacenter: String("dc2")},
+		},
+		{
+			"datacenter_empty_one",
+			&ConsulConfig{Datacenter: String("dc1")},
+			&ConsulConfig{},
+			&ConsulConfig{Datacenter: String("dc1")},
+		},
 		{
 			"namespace_overrides",
 			&ConsulConfig{Namespace: String("foo")},
@@ -129,6 +144,42 @@ func TestConsulConfig_Merge(t *testing.T) {
 			&ConsulConfig{Namespace: String("foo")},
 			&ConsulConfig{Namespace: String("foo")},
 		},
+		{
+			"partition_overrides",
+			&ConsulConfig{Partition: String("foo")},
+			&ConsulConfig{Partition: String("bar")},
+			&ConsulConfig{Partition: String("bar")},
+		},
+		{
+			"partition_empty_one",
+			&ConsulConfig{Partition: String("foo")},
+			&ConsulConfig{},
+			&ConsulConfig{Partition: String("foo")},
+		},
+		{
+			"partition_empty_two",
+			&ConsulConfig{},
+			&ConsulConfig{Partition: String("bar")},
+			&ConsulConfig{Partition: String("bar")},
+		},
+		{
+			"proxy_overrides",
+			&ConsulConfig{Proxy: String("http://foo:3128")},
+			&ConsulConfig{Proxy: String("socks5://bar:1080")},
+			&ConsulConfig{Proxy: String("socks5://bar:1080")},
+		},
+		{
+			"proxy_empty_one",
+			&ConsulConfig{Proxy: String("http://foo:3128")},
+			&ConsulConfig{},
+			&ConsulConfig{Proxy: String("http://foo:3128")},
+		},
+		{
+			"proxy_empty_two",
+			&ConsulConfig{},
+			&ConsulConfig{Proxy: String("socks5://bar:1080")},
+			&ConsulConfig{Proxy: String("socks5://bar:1080")},
+		},
 		{
 			"auth_overrides",
 			&ConsulConfig{Auth: &AuthConfig{Enabled: Bool(true)}},
@@ -153,6 +204,24 @@ func TestConsulConfig_Merge(t *testing.T) {
 			&ConsulConfig{Auth: &AuthConfig{Enabled: Bool(true)}},
 			&ConsulConfig{Auth: &AuthConfig{Enabled: Bool(true)}},
 		},
+		{
+			"rate_limit_overrides",
+			&ConsulConfig{RateLimit: &RateLimitConfig{QPS: Float64(5)}},
+			&ConsulConfig{RateLimit: &RateLimitConfig{QPS: Float64(10)}},
+			&ConsulConfig{RateLimit: &RateLimitConfig{QPS: Float64(10)}},
+		},
+		{
+			"rate_limit_empty_one",
+			&ConsulConfig{RateLimit: &RateLimitConfig{QPS: Float64(5)}},
+			&ConsulConfig{},
+			&ConsulConfig{RateLimit: &RateLimitConfig{QPS: Float64(5)}},
+		},
+		{
+			"rate_limit_empty_two",
+			&ConsulConfig{},
+			&ConsulConfig{RateLimit: &RateLimitConfig{QPS: Float64(5)}},
+			&ConsulConfig{RateLimit: &RateLimitConfig{QPS: Float64(5)}},
+		},
 		{
 			"retry_overrides",
 			&ConsulConfig{Retry: &RetryConfig{Enabled: Bool(true)}},
@@ -295,8 +364,16 @@ func TestConsulConfig_Finalize(t *testing.T) {
 			"empty",
 			&ConsulConfig{},
 			&ConsulConfig{
-				Address:   String(""),
-				Namespace: String(""),
+				Address:    String(""),
+				Datacenter: String(""),
+				Namespace:  String(""),
+				Partition:  String(""),
+				Proxy:      String(""),
+				RateLimit: &RateLimitConfig{
+					Enabled: Bool(false),
+					QPS:     Float64(0),
+					Burst:   Int(1),
+				},
 				Auth: &AuthConfig{
 					Enabled:  Bool(false),
 					Username: String(""),
@@ -324,6 +401,7 @@ func TestConsulConfig_Finalize(t *testing.T) {
 					DialKeepAlive:       TimeDuration(DefaultDialKeepAlive),
 					DialTimeout:         TimeDuration(DefaultDialTimeout),
 					DisableKeepAlives:   Bool(false),
+					EnableHTTP2:         Bool(false),
 					IdleConnTimeout:     TimeDuration(DefaultIdleConnTimeout),
 					MaxIdleConns:        Int(DefaultMaxIdleConns),
 					MaxIdleConnsPerHost: Int(DefaultMaxIdleConnsPerHost),
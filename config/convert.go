@@ -76,6 +76,15 @@ func Float64(f float64) *float64 {
 	return &f
 }
 
+// Float64Val returns the value of the float64 at the pointer, or 0 if the
+// pointer is nil.
+func Float64Val(f *float64) float64 {
+	if f == nil {
+		return 0
+	}
+	return *f
+}
+
 // FloatGoString returns the value of the float for printing in a string.
 func FloatGoString(f *float64) string {
 	if f == nil {
@@ -48,6 +48,11 @@ type ExecConfig struct {
 	// hard-killing it.
 	KillTimeout *time.Duration `mapstructure:"kill_timeout"`
 
+	// LogChildOutput sends each line the command writes to its stdout and
+	// stderr to the logger, prefixed with the name of the command, instead of
+	// writing it to the stdout and stderr of consul-template.
+	LogChildOutput *bool `mapstructure:"log_child_output"`
+
 	// ReloadSignal is the signal to send to the child process when a template
 	// changes. This tells the child process that templates have
 	ReloadSignal *os.Signal `mapstructure:"reload_signal"`
@@ -97,6 +102,8 @@ func (c *ExecConfig) Copy() *ExecConfig {
 
 	o.KillTimeout = c.KillTimeout
 
+	o.LogChildOutput = c.LogChildOutput
+
 	o.ReloadSignal = c.ReloadSignal
 
 	o.Splay = c.Splay
@@ -144,6 +151,10 @@ func (c *ExecConfig) Merge(o *ExecConfig) *ExecConfig {
 		r.KillTimeout = o.KillTimeout
 	}
 
+	if o.LogChildOutput != nil {
+		r.LogChildOutput = o.LogChildOutput
+	}
+
 	if o.ReloadSignal != nil {
 		r.ReloadSignal = o.ReloadSignal
 	}
@@ -182,6 +193,10 @@ func (c *ExecConfig) Finalize() {
 		c.KillTimeout = TimeDuration(DefaultExecKillTimeout)
 	}
 
+	if c.LogChildOutput == nil {
+		c.LogChildOutput = Bool(false)
+	}
+
 	if c.ReloadSignal == nil {
 		c.ReloadSignal = Signal(DefaultExecReloadSignal)
 	}
@@ -207,6 +222,7 @@ func (c *ExecConfig) GoString() string {
 		"Env:%#v, "+
 		"KillSignal:%s, "+
 		"KillTimeout:%s, "+
+		"LogChildOutput:%s, "+
 		"ReloadSignal:%s, "+
 		"Splay:%s, "+
 		"Timeout:%s"+
@@ -216,6 +232,7 @@ func (c *ExecConfig) GoString() string {
 		c.Env,
 		SignalGoString(c.KillSignal),
 		TimeDurationGoString(c.KillTimeout),
+		BoolGoString(c.LogChildOutput),
 		SignalGoString(c.ReloadSignal),
 		TimeDurationGoString(c.Splay),
 		TimeDurationGoString(c.Timeout),
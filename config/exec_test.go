@@ -27,14 +27,15 @@ func TestExecConfig_Copy(t *testing.T) {
 		{
 			"copy",
 			&ExecConfig{
-				Command:      []string{"command"},
-				Enabled:      Bool(true),
-				Env:          &EnvConfig{Pristine: Bool(true)},
-				KillSignal:   Signal(syscall.SIGINT),
-				KillTimeout:  TimeDuration(10 * time.Second),
-				ReloadSignal: Signal(syscall.SIGINT),
-				Splay:        TimeDuration(10 * time.Second),
-				Timeout:      TimeDuration(10 * time.Second),
+				Command:        []string{"command"},
+				Enabled:        Bool(true),
+				Env:            &EnvConfig{Pristine: Bool(true)},
+				KillSignal:     Signal(syscall.SIGINT),
+				KillTimeout:    TimeDuration(10 * time.Second),
+				LogChildOutput: Bool(true),
+				ReloadSignal:   Signal(syscall.SIGINT),
+				Splay:          TimeDuration(10 * time.Second),
+				Timeout:        TimeDuration(10 * time.Second),
 			},
 		},
 	}
@@ -200,6 +201,30 @@ func TestExecConfig_Merge(t *testing.T) {
 			&ExecConfig{KillTimeout: TimeDuration(10 * time.Second)},
 			&ExecConfig{KillTimeout: TimeDuration(10 * time.Second)},
 		},
+		{
+			"log_child_output_overrides",
+			&ExecConfig{LogChildOutput: Bool(true)},
+			&ExecConfig{LogChildOutput: Bool(false)},
+			&ExecConfig{LogChildOutput: Bool(false)},
+		},
+		{
+			"log_child_output_empty_one",
+			&ExecConfig{LogChildOutput: Bool(true)},
+			&ExecConfig{},
+			&ExecConfig{LogChildOutput: Bool(true)},
+		},
+		{
+			"log_child_output_empty_two",
+			&ExecConfig{},
+			&ExecConfig{LogChildOutput: Bool(true)},
+			&ExecConfig{LogChildOutput: Bool(true)},
+		},
+		{
+			"log_child_output_same",
+			&ExecConfig{LogChildOutput: Bool(true)},
+			&ExecConfig{LogChildOutput: Bool(true)},
+			&ExecConfig{LogChildOutput: Bool(true)},
+		},
 		{
 			"reload_signal_overrides",
 			&ExecConfig{ReloadSignal: Signal(syscall.SIGINT)},
@@ -304,11 +329,12 @@ func TestExecConfig_Finalize(t *testing.T) {
 					Denylist:            []string{},
 					DenylistDeprecated:  []string{},
 				},
-				KillSignal:   Signal(DefaultExecKillSignal),
-				KillTimeout:  TimeDuration(DefaultExecKillTimeout),
-				ReloadSignal: Signal(DefaultExecReloadSignal),
-				Splay:        TimeDuration(0 * time.Second),
-				Timeout:      TimeDuration(DefaultExecTimeout),
+				KillSignal:     Signal(DefaultExecKillSignal),
+				KillTimeout:    TimeDuration(DefaultExecKillTimeout),
+				LogChildOutput: Bool(false),
+				ReloadSignal:   Signal(DefaultExecReloadSignal),
+				Splay:          TimeDuration(0 * time.Second),
+				Timeout:        TimeDuration(DefaultExecTimeout),
 			},
 		},
 		{
@@ -327,11 +353,12 @@ func TestExecConfig_Finalize(t *testing.T) {
 					Allowlist:           []string{},
 					AllowlistDeprecated: []string{},
 				},
-				KillSignal:   Signal(DefaultExecKillSignal),
-				KillTimeout:  TimeDuration(DefaultExecKillTimeout),
-				ReloadSignal: Signal(DefaultExecReloadSignal),
-				Splay:        TimeDuration(0 * time.Second),
-				Timeout:      TimeDuration(DefaultExecTimeout),
+				KillSignal:     Signal(DefaultExecKillSignal),
+				KillTimeout:    TimeDuration(DefaultExecKillTimeout),
+				LogChildOutput: Bool(false),
+				ReloadSignal:   Signal(DefaultExecReloadSignal),
+				Splay:          TimeDuration(0 * time.Second),
+				Timeout:        TimeDuration(DefaultExecTimeout),
 			},
 		},
 		{
@@ -350,11 +377,12 @@ func TestExecConfig_Finalize(t *testing.T) {
 					Allowlist:           []string{},
 					AllowlistDeprecated: []string{},
 				},
-				KillSignal:   Signal(DefaultExecKillSignal),
-				KillTimeout:  TimeDuration(DefaultExecKillTimeout),
-				ReloadSignal: Signal(DefaultExecReloadSignal),
-				Splay:        TimeDuration(0 * time.Second),
-				Timeout:      TimeDuration(DefaultExecTimeout),
+				KillSignal:     Signal(DefaultExecKillSignal),
+				KillTimeout:    TimeDuration(DefaultExecKillTimeout),
+				LogChildOutput: Bool(false),
+				ReloadSignal:   Signal(DefaultExecReloadSignal),
+				Splay:          TimeDuration(0 * time.Second),
+				Timeout:        TimeDuration(DefaultExecTimeout),
 			},
 		},
 		{
@@ -373,11 +401,12 @@ func TestExecConfig_Finalize(t *testing.T) {
 					Allowlist:           []string{},
 					AllowlistDeprecated: []string{},
 				},
-				KillSignal:   Signal(DefaultExecKillSignal),
-				KillTimeout:  TimeDuration(DefaultExecKillTimeout),
-				ReloadSignal: Signal(DefaultExecReloadSignal),
-				Splay:        TimeDuration(0 * time.Second),
-				Timeout:      TimeDuration(DefaultExecTimeout),
+				KillSignal:     Signal(DefaultExecKillSignal),
+				KillTimeout:    TimeDuration(DefaultExecKillTimeout),
+				LogChildOutput: Bool(false),
+				ReloadSignal:   Signal(DefaultExecReloadSignal),
+				Splay:          TimeDuration(0 * time.Second),
+				Timeout:        TimeDuration(DefaultExecTimeout),
 			},
 		},
 	}
@@ -0,0 +1,91 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import "fmt"
+
+// HealthEndpointConfig is the configuration for the HTTP endpoint reporting
+// the liveness and readiness of the process.
+type HealthEndpointConfig struct {
+	// Enabled controls whether the endpoint is served. It defaults to true when
+	// an address is set.
+	Enabled *bool `mapstructure:"enabled"`
+
+	// Address is the address the endpoint listens on, e.g. "127.0.0.1:8080" or
+	// ":8080".
+	Address *string `mapstructure:"address"`
+}
+
+// DefaultHealthEndpointConfig returns a configuration that is populated with
+// the default values.
+func DefaultHealthEndpointConfig() *HealthEndpointConfig {
+	return &HealthEndpointConfig{}
+}
+
+// Copy returns a deep copy of this configuration.
+func (c *HealthEndpointConfig) Copy() *HealthEndpointConfig {
+	if c == nil {
+		return nil
+	}
+
+	var o HealthEndpointConfig
+	o.Enabled = c.Enabled
+	o.Address = c.Address
+	return &o
+}
+
+// Merge combines all values in this configuration with the values in the other
+// configuration, with values in the other configuration taking precedence.
+// Maps and slices are merged, most other values are overwritten. Complex
+// structs define their own merge functionality.
+func (c *HealthEndpointConfig) Merge(o *HealthEndpointConfig) *HealthEndpointConfig {
+	if c == nil {
+		if o == nil {
+			return nil
+		}
+		return o.Copy()
+	}
+
+	if o == nil {
+		return c.Copy()
+	}
+
+	r := c.Copy()
+
+	if o.Enabled != nil {
+		r.Enabled = o.Enabled
+	}
+
+	if o.Address != nil {
+		r.Address = o.Address
+	}
+
+	return r
+}
+
+// Finalize ensures there no nil pointers.
+func (c *HealthEndpointConfig) Finalize() {
+	if c.Enabled == nil {
+		c.Enabled = Bool(StringPresent(c.Address))
+	}
+
+	if c.Address == nil {
+		c.Address = String("")
+	}
+}
+
+// GoString defines the printable version of this struct.
+func (c *HealthEndpointConfig) GoString() string {
+	if c == nil {
+		return "(*HealthEndpointConfig)(nil)"
+	}
+
+	return fmt.Sprintf("&HealthEndpointConfig{"+
+		"Enabled:%s, "+
+		"Address:%s"+
+		"}",
+		BoolGoString(c.Enabled),
+		StringGoString(c.Address),
+	)
+}
@@ -0,0 +1,156 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestHealthEndpointConfig_Copy(t *testing.T) {
+	cases := []struct {
+		name string
+		a    *HealthEndpointConfig
+	}{
+		{
+			"nil",
+			nil,
+		},
+		{
+			"empty",
+			&HealthEndpointConfig{},
+		},
+		{
+			"same_enabled",
+			&HealthEndpointConfig{
+				Enabled: Bool(true),
+				Address: String("127.0.0.1:8080"),
+			},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			r := tc.a.Copy()
+			if !reflect.DeepEqual(tc.a, r) {
+				t.Errorf("\nexp: %#v\nact: %#v", tc.a, r)
+			}
+		})
+	}
+}
+
+func TestHealthEndpointConfig_Merge(t *testing.T) {
+	cases := []struct {
+		name string
+		a    *HealthEndpointConfig
+		b    *HealthEndpointConfig
+		r    *HealthEndpointConfig
+	}{
+		{
+			"nil_a",
+			nil,
+			&HealthEndpointConfig{},
+			&HealthEndpointConfig{},
+		},
+		{
+			"nil_b",
+			&HealthEndpointConfig{},
+			nil,
+			&HealthEndpointConfig{},
+		},
+		{
+			"nil_both",
+			nil,
+			nil,
+			nil,
+		},
+		{
+			"empty",
+			&HealthEndpointConfig{},
+			&HealthEndpointConfig{},
+			&HealthEndpointConfig{},
+		},
+		{
+			"enabled_overrides",
+			&HealthEndpointConfig{Enabled: Bool(true)},
+			&HealthEndpointConfig{Enabled: Bool(false)},
+			&HealthEndpointConfig{Enabled: Bool(false)},
+		},
+		{
+			"address_overrides",
+			&HealthEndpointConfig{Address: String(":8080")},
+			&HealthEndpointConfig{Address: String(":9090")},
+			&HealthEndpointConfig{Address: String(":9090")},
+		},
+		{
+			"address_empty_one",
+			&HealthEndpointConfig{Address: String(":8080")},
+			&HealthEndpointConfig{},
+			&HealthEndpointConfig{Address: String(":8080")},
+		},
+		{
+			"address_empty_two",
+			&HealthEndpointConfig{},
+			&HealthEndpointConfig{Address: String(":9090")},
+			&HealthEndpointConfig{Address: String(":9090")},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			r := tc.a.Merge(tc.b)
+			if !reflect.DeepEqual(tc.r, r) {
+				t.Errorf("\nexp: %#v\nact: %#v", tc.r, r)
+			}
+		})
+	}
+}
+
+func TestHealthEndpointConfig_Finalize(t *testing.T) {
+	cases := []struct {
+		name string
+		i    *HealthEndpointConfig
+		r    *HealthEndpointConfig
+	}{
+		{
+			"empty",
+			&HealthEndpointConfig{},
+			&HealthEndpointConfig{
+				Enabled: Bool(false),
+				Address: String(""),
+			},
+		},
+		{
+			"with_address",
+			&HealthEndpointConfig{
+				Address: String(":8080"),
+			},
+			&HealthEndpointConfig{
+				Enabled: Bool(true),
+				Address: String(":8080"),
+			},
+		},
+		{
+			"disabled_with_address",
+			&HealthEndpointConfig{
+				Enabled: Bool(false),
+				Address: String(":8080"),
+			},
+			&HealthEndpointConfig{
+				Enabled: Bool(false),
+				Address: String(":8080"),
+			},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			tc.i.Finalize()
+			if !reflect.DeepEqual(tc.r, tc.i) {
+				t.Errorf("\nexp: %#v\nact: %#v", tc.r, tc.i)
+			}
+		})
+	}
+}
@@ -244,6 +244,7 @@ func TestNomadConfig_Finalize(t *testing.T) {
 					DialKeepAlive:       TimeDuration(DefaultDialKeepAlive),
 					DialTimeout:         TimeDuration(DefaultDialTimeout),
 					DisableKeepAlives:   Bool(false),
+					EnableHTTP2:         Bool(false),
 					IdleConnTimeout:     TimeDuration(DefaultIdleConnTimeout),
 					MaxIdleConns:        Int(DefaultMaxIdleConns),
 					MaxIdleConnsPerHost: Int(DefaultMaxIdleConnsPerHost),
@@ -284,6 +285,7 @@ func TestNomadConfig_Finalize(t *testing.T) {
 					DialKeepAlive:       TimeDuration(DefaultDialKeepAlive),
 					DialTimeout:         TimeDuration(DefaultDialTimeout),
 					DisableKeepAlives:   Bool(false),
+					EnableHTTP2:         Bool(false),
 					IdleConnTimeout:     TimeDuration(DefaultIdleConnTimeout),
 					MaxIdleConns:        Int(DefaultMaxIdleConns),
 					MaxIdleConnsPerHost: Int(DefaultMaxIdleConnsPerHost),
@@ -331,6 +333,7 @@ func TestNomadConfig_Finalize(t *testing.T) {
 					DialKeepAlive:       TimeDuration(DefaultDialKeepAlive),
 					DialTimeout:         TimeDuration(DefaultDialTimeout),
 					DisableKeepAlives:   Bool(false),
+					EnableHTTP2:         Bool(false),
 					IdleConnTimeout:     TimeDuration(DefaultIdleConnTimeout),
 					MaxIdleConns:        Int(DefaultMaxIdleConns),
 					MaxIdleConnsPerHost: Int(DefaultMaxIdleConnsPerHost),
@@ -373,6 +376,7 @@ func TestNomadConfig_Finalize(t *testing.T) {
 					DialKeepAlive:       TimeDuration(DefaultDialKeepAlive),
 					DialTimeout:         TimeDuration(DefaultDialTimeout),
 					DisableKeepAlives:   Bool(false),
+					EnableHTTP2:         Bool(false),
 					IdleConnTimeout:     TimeDuration(DefaultIdleConnTimeout),
 					MaxIdleConns:        Int(DefaultMaxIdleConns),
 					MaxIdleConnsPerHost: Int(DefaultMaxIdleConnsPerHost),
@@ -419,6 +423,7 @@ func TestNomadConfig_Finalize(t *testing.T) {
 					DialKeepAlive:       TimeDuration(DefaultDialKeepAlive),
 					DialTimeout:         TimeDuration(DefaultDialTimeout),
 					DisableKeepAlives:   Bool(false),
+					EnableHTTP2:         Bool(false),
 					IdleConnTimeout:     TimeDuration(DefaultIdleConnTimeout),
 					MaxIdleConns:        Int(DefaultMaxIdleConns),
 					MaxIdleConnsPerHost: Int(DefaultMaxIdleConnsPerHost),
@@ -0,0 +1,112 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"fmt"
+	"math"
+)
+
+// RateLimitConfig is a shared configuration for upstreams that support capping
+// the rate of outbound requests.
+type RateLimitConfig struct {
+	// Enabled signals if this rate limit is enabled.
+	Enabled *bool
+
+	// QPS is the sustained number of requests per second allowed to the
+	// upstream. Requests over the limit wait for their turn instead of failing.
+	QPS *float64 `mapstructure:"qps"`
+
+	// Burst is the number of requests allowed at once on top of the sustained
+	// rate. It defaults to the QPS, rounded up.
+	Burst *int `mapstructure:"burst"`
+}
+
+// DefaultRateLimitConfig returns a configuration that is populated with the
+// default values.
+func DefaultRateLimitConfig() *RateLimitConfig {
+	return &RateLimitConfig{}
+}
+
+// Copy returns a deep copy of this configuration.
+func (c *RateLimitConfig) Copy() *RateLimitConfig {
+	if c == nil {
+		return nil
+	}
+
+	var o RateLimitConfig
+
+	o.Enabled = c.Enabled
+
+	o.QPS = c.QPS
+
+	o.Burst = c.Burst
+
+	return &o
+}
+
+// Merge combines all values in this configuration with the values in the other
+// configuration, with values in the other configuration taking precedence.
+// Maps and slices are merged, most other values are overwritten. Complex
+// structs define their own merge functionality.
+func (c *RateLimitConfig) Merge(o *RateLimitConfig) *RateLimitConfig {
+	if c == nil {
+		if o == nil {
+			return nil
+		}
+		return o.Copy()
+	}
+
+	if o == nil {
+		return c.Copy()
+	}
+
+	r := c.Copy()
+
+	if o.Enabled != nil {
+		r.Enabled = o.Enabled
+	}
+
+	if o.QPS != nil {
+		r.QPS = o.QPS
+	}
+
+	if o.Burst != nil {
+		r.Burst = o.Burst
+	}
+
+	return r
+}
+
+// Finalize ensures there no nil pointers.
+func (c *RateLimitConfig) Finalize() {
+	if c.Enabled == nil {
+		c.Enabled = Bool(Float64Val(c.QPS) > 0)
+	}
+
+	if c.QPS == nil {
+		c.QPS = Float64(0)
+	}
+
+	if c.Burst == nil {
+		c.Burst = Int(int(math.Max(1, math.Ceil(*c.QPS))))
+	}
+}
+
+// GoString defines the printable version of this struct.
+func (c *RateLimitConfig) GoString() string {
+	if c == nil {
+		return "(*RateLimitConfig)(nil)"
+	}
+
+	return fmt.Sprintf("&RateLimitConfig{"+
+		"Enabled:%s, "+
+		"QPS:%s, "+
+		"Burst:%s"+
+		"}",
+		BoolGoString(c.Enabled),
+		FloatGoString(c.QPS),
+		IntGoString(c.Burst),
+	)
+}
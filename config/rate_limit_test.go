@@ -0,0 +1,196 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRateLimitConfig_Copy(t *testing.T) {
+	cases := []struct {
+		name string
+		a    *RateLimitConfig
+	}{
+		{
+			"nil",
+			nil,
+		},
+		{
+			"empty",
+			&RateLimitConfig{},
+		},
+		{
+			"same_enabled",
+			&RateLimitConfig{
+				Enabled: Bool(true),
+				QPS:     Float64(2.5),
+				Burst:   Int(5),
+			},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			r := tc.a.Copy()
+			if !reflect.DeepEqual(tc.a, r) {
+				t.Errorf("\nexp: %#v\nact: %#v", tc.a, r)
+			}
+		})
+	}
+}
+
+func TestRateLimitConfig_Merge(t *testing.T) {
+	cases := []struct {
+		name string
+		a    *RateLimitConfig
+		b    *RateLimitConfig
+		r    *RateLimitConfig
+	}{
+		{
+			"nil_a",
+			nil,
+			&RateLimitConfig{},
+			&RateLimitConfig{},
+		},
+		{
+			"nil_b",
+			&RateLimitConfig{},
+			nil,
+			&RateLimitConfig{},
+		},
+		{
+			"nil_both",
+			nil,
+			nil,
+			nil,
+		},
+		{
+			"empty",
+			&RateLimitConfig{},
+			&RateLimitConfig{},
+			&RateLimitConfig{},
+		},
+		{
+			"enabled_overrides",
+			&RateLimitConfig{Enabled: Bool(true)},
+			&RateLimitConfig{Enabled: Bool(false)},
+			&RateLimitConfig{Enabled: Bool(false)},
+		},
+		{
+			"enabled_empty_one",
+			&RateLimitConfig{Enabled: Bool(true)},
+			&RateLimitConfig{},
+			&RateLimitConfig{Enabled: Bool(true)},
+		},
+		{
+			"qps_overrides",
+			&RateLimitConfig{QPS: Float64(1)},
+			&RateLimitConfig{QPS: Float64(2)},
+			&RateLimitConfig{QPS: Float64(2)},
+		},
+		{
+			"qps_empty_one",
+			&RateLimitConfig{QPS: Float64(1)},
+			&RateLimitConfig{},
+			&RateLimitConfig{QPS: Float64(1)},
+		},
+		{
+			"qps_empty_two",
+			&RateLimitConfig{},
+			&RateLimitConfig{QPS: Float64(1)},
+			&RateLimitConfig{QPS: Float64(1)},
+		},
+		{
+			"burst_overrides",
+			&RateLimitConfig{Burst: Int(1)},
+			&RateLimitConfig{Burst: Int(2)},
+			&RateLimitConfig{Burst: Int(2)},
+		},
+		{
+			"burst_empty_one",
+			&RateLimitConfig{Burst: Int(1)},
+			&RateLimitConfig{},
+			&RateLimitConfig{Burst: Int(1)},
+		},
+		{
+			"burst_empty_two",
+			&RateLimitConfig{},
+			&RateLimitConfig{Burst: Int(1)},
+			&RateLimitConfig{Burst: Int(1)},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			r := tc.a.Merge(tc.b)
+			if !reflect.DeepEqual(tc.r, r) {
+				t.Errorf("\nexp: %#v\nact: %#v", tc.r, r)
+			}
+		})
+	}
+}
+
+func TestRateLimitConfig_Finalize(t *testing.T) {
+	cases := []struct {
+		name string
+		i    *RateLimitConfig
+		r    *RateLimitConfig
+	}{
+		{
+			"empty",
+			&RateLimitConfig{},
+			&RateLimitConfig{
+				Enabled: Bool(false),
+				QPS:     Float64(0),
+				Burst:   Int(1),
+			},
+		},
+		{
+			"with_qps",
+			&RateLimitConfig{
+				QPS: Float64(2.5),
+			},
+			&RateLimitConfig{
+				Enabled: Bool(true),
+				QPS:     Float64(2.5),
+				Burst:   Int(3),
+			},
+		},
+		{
+			"with_burst",
+			&RateLimitConfig{
+				QPS:   Float64(10),
+				Burst: Int(1),
+			},
+			&RateLimitConfig{
+				Enabled: Bool(true),
+				QPS:     Float64(10),
+				Burst:   Int(1),
+			},
+		},
+		{
+			"disabled",
+			&RateLimitConfig{
+				Enabled: Bool(false),
+				QPS:     Float64(10),
+			},
+			&RateLimitConfig{
+				Enabled: Bool(false),
+				QPS:     Float64(10),
+				Burst:   Int(10),
+			},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			tc.i.Finalize()
+			if !reflect.DeepEqual(tc.r, tc.i) {
+				t.Errorf("\nexp: %#v\nact: %#v", tc.r, tc.i)
+			}
+		})
+	}
+}
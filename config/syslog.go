@@ -22,6 +22,10 @@ type SyslogConfig struct {
 	Enabled  *bool   `mapstructure:"enabled"`
 	Facility *string `mapstructure:"facility"`
 	Name     *string `mapstructure:"name"`
+
+	// Tag is an alias of Name, the tag syslog messages are sent with. Name
+	// takes precedence when both are set.
+	Tag *string `mapstructure:"tag"`
 }
 
 // DefaultSyslogConfig returns a configuration that is populated with the
@@ -40,6 +44,7 @@ func (c *SyslogConfig) Copy() *SyslogConfig {
 	o.Enabled = c.Enabled
 	o.Facility = c.Facility
 	o.Name = c.Name
+	o.Tag = c.Tag
 	return &o
 }
 
@@ -73,22 +78,33 @@ func (c *SyslogConfig) Merge(o *SyslogConfig) *SyslogConfig {
 		r.Name = o.Name
 	}
 
+	if o.Tag != nil {
+		r.Tag = o.Tag
+	}
+
 	return r
 }
 
 // Finalize ensures there no nil pointers.
 func (c *SyslogConfig) Finalize() {
 	if c.Enabled == nil {
-		c.Enabled = Bool(StringPresent(c.Facility) || StringPresent(c.Name))
+		c.Enabled = Bool(StringPresent(c.Facility) || StringPresent(c.Name) ||
+			StringPresent(c.Tag))
 	}
 
 	if c.Facility == nil {
 		c.Facility = String(DefaultSyslogFacility)
 	}
 
+	if c.Name == nil {
+		c.Name = c.Tag
+	}
+
 	if c.Name == nil {
 		c.Name = String(DefaultSyslogName)
 	}
+
+	c.Tag = c.Name
 }
 
 // GoString defines the printable version of this struct.
@@ -101,9 +117,11 @@ func (c *SyslogConfig) GoString() string {
 		"Enabled:%s, "+
 		"Facility:%s"+
 		"Name:%s"+
+		"Tag:%s"+
 		"}",
 		BoolGoString(c.Enabled),
 		StringGoString(c.Facility),
 		StringGoString(c.Name),
+		StringGoString(c.Tag),
 	)
 }
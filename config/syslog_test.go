@@ -28,6 +28,7 @@ func TestSyslogConfig_Copy(t *testing.T) {
 				Enabled:  Bool(true),
 				Facility: String("facility"),
 				Name:     String("name"),
+				Tag:      String("tag"),
 			},
 		},
 	}
@@ -145,6 +146,18 @@ func TestSyslogConfig_Merge(t *testing.T) {
 			&SyslogConfig{Name: String("name")},
 			&SyslogConfig{Name: String("name")},
 		},
+		{
+			"tag_overrides",
+			&SyslogConfig{Tag: String("tag")},
+			&SyslogConfig{Tag: String("")},
+			&SyslogConfig{Tag: String("")},
+		},
+		{
+			"tag_empty_one",
+			&SyslogConfig{Tag: String("tag")},
+			&SyslogConfig{},
+			&SyslogConfig{Tag: String("tag")},
+		},
 	}
 
 	for i, tc := range cases {
@@ -170,6 +183,7 @@ func TestSyslogConfig_Finalize(t *testing.T) {
 				Enabled:  Bool(false),
 				Facility: String(DefaultSyslogFacility),
 				Name:     String(DefaultSyslogName),
+				Tag:      String(DefaultSyslogName),
 			},
 		},
 		{
@@ -181,6 +195,7 @@ func TestSyslogConfig_Finalize(t *testing.T) {
 				Enabled:  Bool(true),
 				Facility: String("facility"),
 				Name:     String(DefaultSyslogName),
+				Tag:      String(DefaultSyslogName),
 			},
 		},
 		{
@@ -192,6 +207,32 @@ func TestSyslogConfig_Finalize(t *testing.T) {
 				Enabled:  Bool(true),
 				Facility: String(DefaultSyslogFacility),
 				Name:     String("name"),
+				Tag:      String("name"),
+			},
+		},
+		{
+			"with_tag",
+			&SyslogConfig{
+				Tag: String("tag"),
+			},
+			&SyslogConfig{
+				Enabled:  Bool(true),
+				Facility: String(DefaultSyslogFacility),
+				Name:     String("tag"),
+				Tag:      String("tag"),
+			},
+		},
+		{
+			"name_over_tag",
+			&SyslogConfig{
+				Name: String("name"),
+				Tag:  String("tag"),
+			},
+			&SyslogConfig{
+				Enabled:  Bool(true),
+				Facility: String(DefaultSyslogFacility),
+				Name:     String("name"),
+				Tag:      String("name"),
 			},
 		},
 	}
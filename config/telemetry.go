@@ -0,0 +1,164 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import "fmt"
+
+// TelemetryConfig is the configuration for telemetry.
+type TelemetryConfig struct {
+	// OTel is the configuration for exporting OpenTelemetry traces.
+	OTel *OTelConfig `mapstructure:"otel"`
+}
+
+// DefaultTelemetryConfig returns a configuration that is populated with the
+// default values.
+func DefaultTelemetryConfig() *TelemetryConfig {
+	return &TelemetryConfig{
+		OTel: DefaultOTelConfig(),
+	}
+}
+
+// Copy returns a deep copy of this configuration.
+func (c *TelemetryConfig) Copy() *TelemetryConfig {
+	if c == nil {
+		return nil
+	}
+
+	var o TelemetryConfig
+	if c.OTel != nil {
+		o.OTel = c.OTel.Copy()
+	}
+	return &o
+}
+
+// Merge combines all values in this configuration with the values in the other
+// configuration, with values in the other configuration taking precedence.
+// Maps and slices are merged, most other values are overwritten. Complex
+// structs define their own merge functionality.
+func (c *TelemetryConfig) Merge(o *TelemetryConfig) *TelemetryConfig {
+	if c == nil {
+		if o == nil {
+			return nil
+		}
+		return o.Copy()
+	}
+
+	if o == nil {
+		return c.Copy()
+	}
+
+	r := c.Copy()
+
+	if o.OTel != nil {
+		r.OTel = r.OTel.Merge(o.OTel)
+	}
+
+	return r
+}
+
+// Finalize ensures there no nil pointers.
+func (c *TelemetryConfig) Finalize() {
+	if c.OTel == nil {
+		c.OTel = DefaultOTelConfig()
+	}
+	c.OTel.Finalize()
+}
+
+// GoString defines the printable version of this struct.
+func (c *TelemetryConfig) GoString() string {
+	if c == nil {
+		return "(*TelemetryConfig)(nil)"
+	}
+
+	return fmt.Sprintf("&TelemetryConfig{"+
+		"OTel:%#v"+
+		"}",
+		c.OTel,
+	)
+}
+
+// OTelConfig is the configuration for exporting OpenTelemetry traces.
+type OTelConfig struct {
+	// Enabled controls whether traces are exported. It defaults to true when
+	// an endpoint is set.
+	Enabled *bool `mapstructure:"enabled"`
+
+	// Endpoint is the URL of the OTLP/HTTP collector the traces are sent to,
+	// e.g. "http://localhost:4318". Plain "http" endpoints are used without
+	// TLS.
+	Endpoint *string `mapstructure:"endpoint"`
+}
+
+// DefaultOTelConfig returns a configuration that is populated with the
+// default values.
+func DefaultOTelConfig() *OTelConfig {
+	return &OTelConfig{}
+}
+
+// Copy returns a deep copy of this configuration.
+func (c *OTelConfig) Copy() *OTelConfig {
+	if c == nil {
+		return nil
+	}
+
+	var o OTelConfig
+	o.Enabled = c.Enabled
+	o.Endpoint = c.Endpoint
+	return &o
+}
+
+// Merge combines all values in this configuration with the values in the other
+// configuration, with values in the other configuration taking precedence.
+// Maps and slices are merged, most other values are overwritten. Complex
+// structs define their own merge functionality.
+func (c *OTelConfig) Merge(o *OTelConfig) *OTelConfig {
+	if c == nil {
+		if o == nil {
+			return nil
+		}
+		return o.Copy()
+	}
+
+	if o == nil {
+		return c.Copy()
+	}
+
+	r := c.Copy()
+
+	if o.Enabled != nil {
+		r.Enabled = o.Enabled
+	}
+
+	if o.Endpoint != nil {
+		r.Endpoint = o.Endpoint
+	}
+
+	return r
+}
+
+// Finalize ensures there no nil pointers.
+func (c *OTelConfig) Finalize() {
+	if c.Enabled == nil {
+		c.Enabled = Bool(StringPresent(c.Endpoint))
+	}
+
+	if c.Endpoint == nil {
+		c.Endpoint = String("")
+	}
+}
+
+// GoString defines the printable version of this struct.
+func (c *OTelConfig) GoString() string {
+	if c == nil {
+		return "(*OTelConfig)(nil)"
+	}
+
+	return fmt.Sprintf("&OTelConfig{"+
+		"Enabled:%s, "+
+		"Endpoint:%s"+
+		"}",
+		BoolGoString(c.Enabled),
+		StringGoString(c.Endpoint),
+	)
+}
@@ -0,0 +1,168 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTelemetryConfig_Copy(t *testing.T) {
+	cases := []struct {
+		name string
+		a    *TelemetryConfig
+	}{
+		{
+			"nil",
+			nil,
+		},
+		{
+			"empty",
+			&TelemetryConfig{},
+		},
+		{
+			"same_enabled",
+			&TelemetryConfig{
+				OTel: &OTelConfig{
+					Enabled:  Bool(true),
+					Endpoint: String("http://localhost:4318"),
+				},
+			},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			r := tc.a.Copy()
+			if !reflect.DeepEqual(tc.a, r) {
+				t.Errorf("\nexp: %#v\nact: %#v", tc.a, r)
+			}
+		})
+	}
+}
+
+func TestTelemetryConfig_Merge(t *testing.T) {
+	cases := []struct {
+		name string
+		a    *TelemetryConfig
+		b    *TelemetryConfig
+		r    *TelemetryConfig
+	}{
+		{
+			"nil_a",
+			nil,
+			&TelemetryConfig{},
+			&TelemetryConfig{},
+		},
+		{
+			"nil_b",
+			&TelemetryConfig{},
+			nil,
+			&TelemetryConfig{},
+		},
+		{
+			"nil_both",
+			nil,
+			nil,
+			nil,
+		},
+		{
+			"empty",
+			&TelemetryConfig{},
+			&TelemetryConfig{},
+			&TelemetryConfig{},
+		},
+		{
+			"otel_enabled_overrides",
+			&TelemetryConfig{OTel: &OTelConfig{Enabled: Bool(true)}},
+			&TelemetryConfig{OTel: &OTelConfig{Enabled: Bool(false)}},
+			&TelemetryConfig{OTel: &OTelConfig{Enabled: Bool(false)}},
+		},
+		{
+			"otel_endpoint_overrides",
+			&TelemetryConfig{OTel: &OTelConfig{Endpoint: String("http://a:4318")}},
+			&TelemetryConfig{OTel: &OTelConfig{Endpoint: String("http://b:4318")}},
+			&TelemetryConfig{OTel: &OTelConfig{Endpoint: String("http://b:4318")}},
+		},
+		{
+			"otel_endpoint_empty_one",
+			&TelemetryConfig{OTel: &OTelConfig{Endpoint: String("http://a:4318")}},
+			&TelemetryConfig{OTel: &OTelConfig{}},
+			&TelemetryConfig{OTel: &OTelConfig{Endpoint: String("http://a:4318")}},
+		},
+		{
+			"otel_endpoint_empty_two",
+			&TelemetryConfig{OTel: &OTelConfig{}},
+			&TelemetryConfig{OTel: &OTelConfig{Endpoint: String("http://b:4318")}},
+			&TelemetryConfig{OTel: &OTelConfig{Endpoint: String("http://b:4318")}},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			r := tc.a.Merge(tc.b)
+			if !reflect.DeepEqual(tc.r, r) {
+				t.Errorf("\nexp: %#v\nact: %#v", tc.r, r)
+			}
+		})
+	}
+}
+
+func TestTelemetryConfig_Finalize(t *testing.T) {
+	cases := []struct {
+		name string
+		i    *TelemetryConfig
+		r    *TelemetryConfig
+	}{
+		{
+			"empty",
+			&TelemetryConfig{},
+			&TelemetryConfig{
+				OTel: &OTelConfig{
+					Enabled:  Bool(false),
+					Endpoint: String(""),
+				},
+			},
+		},
+		{
+			"with_endpoint",
+			&TelemetryConfig{
+				OTel: &OTelConfig{
+					Endpoint: String("http://localhost:4318"),
+				},
+			},
+			&TelemetryConfig{
+				OTel: &OTelConfig{
+					Enabled:  Bool(true),
+					Endpoint: String("http://localhost:4318"),
+				},
+			},
+		},
+		{
+			"disabled_with_endpoint",
+			&TelemetryConfig{
+				OTel: &OTelConfig{
+					Enabled:  Bool(false),
+					Endpoint: String("http://localhost:4318"),
+				},
+			},
+			&TelemetryConfig{
+				OTel: &OTelConfig{
+					Enabled:  Bool(false),
+					Endpoint: String("http://localhost:4318"),
+				},
+			},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			tc.i.Finalize()
+			if !reflect.DeepEqual(tc.r, tc.i) {
+				t.Errorf("\nexp: %#v\nact: %#v", tc.r, tc.i)
+			}
+		})
+	}
+}
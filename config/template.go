@@ -6,7 +6,9 @@ package config
 import (
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -20,6 +22,10 @@ const (
 	// DefaultTemplateCommandTimeout is the amount of time to wait for a command
 	// to return.
 	DefaultTemplateCommandTimeout = 30 * time.Second
+
+	// TemplateDirSuffix is the file extension of the templates rendered from a
+	// source_dir.
+	TemplateDirSuffix = ".tpl"
 )
 
 var (
@@ -46,6 +52,13 @@ type TemplateConfig struct {
 	// before force-killing it. This is DEPRECATED. Use Exec instead.
 	CommandTimeout *time.Duration `mapstructure:"command_timeout"`
 
+	// CommandTrigger is a list of glob patterns matched against the
+	// dependencies of this template, like "key(service/web/replicas)". When
+	// set, the command only runs if the template rendered after a matching
+	// dependency changed. Changes to other dependencies still render the
+	// template, but do not run the command.
+	CommandTrigger []string `mapstructure:"command_trigger"`
+
 	// Contents are the raw template contents to evaluate. Either this or Source
 	// must be specified, but not both.
 	Contents *string `mapstructure:"contents"`
@@ -66,10 +79,21 @@ type TemplateConfig struct {
 	// exit, or just log and continue.
 	ErrFatal *bool `mapstructure:"error_fatal"`
 
+	// IgnoreDepErrors is a list of glob patterns matched against the
+	// dependencies of this template, like "vault.read(secret/team-*)". When a
+	// matching dependency fails to fetch, it has no data instead of the error
+	// stopping Consul Template.
+	IgnoreDepErrors []string `mapstructure:"ignore_dep_errors"`
+
 	// Exec is the configuration for the command to run when the template renders
 	// successfully.
 	Exec *ExecConfig `mapstructure:"exec"`
 
+	// GenerationFile is the path on disk where the render generation of this
+	// template is persisted, so it keeps increasing across restarts. When
+	// empty, the generation starts over from zero on each start.
+	GenerationFile *string `mapstructure:"generation_file"`
+
 	// Perms are the file system permissions to use when creating the file on
 	// disk. This is useful for when files contain sensitive information, such as
 	// secrets from Vault.
@@ -99,6 +123,46 @@ type TemplateConfig struct {
 	// this or Contents should be specified, but not both.
 	Source *string `mapstructure:"source"`
 
+	// SourceDir and DestDir render every ".tpl" file under SourceDir to the
+	// same relative path under DestDir, without the suffix. Parse replaces
+	// such a template with one template per file, with Source and Destination
+	// set and the other options copied.
+	SourceDir *string `mapstructure:"source_dir"`
+	DestDir   *string `mapstructure:"dest_dir"`
+
+	// Stream writes the rendered output straight to a temporary file next to
+	// the destination instead of buffering it in memory. This bounds memory
+	// use for very large templates. The default value is false.
+	Stream *bool `mapstructure:"stream"`
+
+	// LineEnding is the line ending of the rendered output, "lf" or "crlf".
+	// With "crlf", every line feed that is not already preceded by a carriage
+	// return gets one. The default value is "lf", which leaves the output
+	// unchanged.
+	LineEnding *string `mapstructure:"line_ending"`
+
+	// BOM prefixes the rendered output with a UTF-8 byte order mark. The
+	// default value is false.
+	BOM *bool `mapstructure:"bom"`
+
+	// Validate checks that the rendered output is well-formed "json" or
+	// "yaml" before it is written. Output that fails the check is not
+	// written and its command is not run, so the existing file is kept. The
+	// default value is "", which does not check the output.
+	Validate *string `mapstructure:"validate"`
+
+	// FanOut renders the files the template writes with the `emit` function
+	// instead of its output. Destination is then a directory, and files that
+	// were emitted by the previous render but not the current one are removed
+	// from it. The default value is false.
+	FanOut *bool `mapstructure:"fan_out"`
+
+	// RenderOnce renders the template a single time and then stops watching
+	// its dependencies, for templates whose data does not change while
+	// Consul Template runs, like those only reading the environment. The
+	// template is rendered again on reload. The default value is false.
+	RenderOnce *bool `mapstructure:"render_once"`
+
 	// Wait configures per-template quiescence timers.
 	Wait *WaitConfig `mapstructure:"wait"`
 
@@ -129,11 +193,12 @@ type TemplateConfig struct {
 	SandboxPath *string `mapstructure:"sandbox_path"`
 
 	// MapToEnvironmentVariable is the name of the environment variable this
-	// template should map to. It is currently only used by Vault Agent and
-	// will be ignored otherwise. When specified, Vault Agent will render the
-	// contents of this template to the given environment variable instead
-	// of a file. This field is mutually exclusive with `Destination`.
-	MapToEnvironmentVariable *string `mapstructure:"-"`
+	// template should map to. In exec mode, the contents, which must be a
+	// single line, are set in the environment of the child process instead of
+	// written to a file, and the child is restarted when they change. Vault
+	// Agent also renders such templates to environment variables. This field
+	// is mutually exclusive with `Destination`.
+	MapToEnvironmentVariable *string `mapstructure:"env_var"`
 }
 
 // DefaultTemplateConfig returns a configuration that is populated with the
@@ -170,14 +235,35 @@ func (c *TemplateConfig) Copy() *TemplateConfig {
 
 	o.ErrFatal = c.ErrFatal
 
+	o.IgnoreDepErrors = append(o.IgnoreDepErrors, c.IgnoreDepErrors...)
+
+	o.CommandTrigger = append(o.CommandTrigger, c.CommandTrigger...)
+
 	if c.Exec != nil {
 		o.Exec = c.Exec.Copy()
 	}
 
+	o.GenerationFile = c.GenerationFile
+
 	o.Perms = c.Perms
 
 	o.Source = c.Source
 
+	o.SourceDir = c.SourceDir
+	o.DestDir = c.DestDir
+
+	o.Stream = c.Stream
+
+	o.LineEnding = c.LineEnding
+
+	o.BOM = c.BOM
+
+	o.Validate = c.Validate
+
+	o.FanOut = c.FanOut
+
+	o.RenderOnce = c.RenderOnce
+
 	o.User = c.User
 	o.Group = c.Group
 
@@ -256,10 +342,18 @@ func (c *TemplateConfig) Merge(o *TemplateConfig) *TemplateConfig {
 		r.ErrFatal = o.ErrFatal
 	}
 
+	r.IgnoreDepErrors = append(r.IgnoreDepErrors, o.IgnoreDepErrors...)
+
+	r.CommandTrigger = append(r.CommandTrigger, o.CommandTrigger...)
+
 	if o.Exec != nil {
 		r.Exec = r.Exec.Merge(o.Exec)
 	}
 
+	if o.GenerationFile != nil {
+		r.GenerationFile = o.GenerationFile
+	}
+
 	if o.Perms != nil {
 		r.Perms = o.Perms
 	}
@@ -268,6 +362,38 @@ func (c *TemplateConfig) Merge(o *TemplateConfig) *TemplateConfig {
 		r.Source = o.Source
 	}
 
+	if o.SourceDir != nil {
+		r.SourceDir = o.SourceDir
+	}
+
+	if o.DestDir != nil {
+		r.DestDir = o.DestDir
+	}
+
+	if o.Stream != nil {
+		r.Stream = o.Stream
+	}
+
+	if o.LineEnding != nil {
+		r.LineEnding = o.LineEnding
+	}
+
+	if o.BOM != nil {
+		r.BOM = o.BOM
+	}
+
+	if o.Validate != nil {
+		r.Validate = o.Validate
+	}
+
+	if o.FanOut != nil {
+		r.FanOut = o.FanOut
+	}
+
+	if o.RenderOnce != nil {
+		r.RenderOnce = o.RenderOnce
+	}
+
 	if o.User != nil {
 		r.User = o.User
 	}
@@ -353,6 +479,14 @@ func (c *TemplateConfig) Finalize() {
 		c.ErrFatal = Bool(true)
 	}
 
+	if c.IgnoreDepErrors == nil {
+		c.IgnoreDepErrors = []string{}
+	}
+
+	if c.CommandTrigger == nil {
+		c.CommandTrigger = []string{}
+	}
+
 	// Backwards compatibility for uid
 	if c.User == nil && c.Uid != nil {
 		uStr := strconv.Itoa(*c.Uid)
@@ -382,6 +516,10 @@ func (c *TemplateConfig) Finalize() {
 	}
 	c.Exec.Finalize()
 
+	if c.GenerationFile == nil {
+		c.GenerationFile = String("")
+	}
+
 	if c.Perms == nil {
 		c.Perms = FileMode(0)
 	}
@@ -390,6 +528,38 @@ func (c *TemplateConfig) Finalize() {
 		c.Source = String("")
 	}
 
+	if c.SourceDir == nil {
+		c.SourceDir = String("")
+	}
+
+	if c.DestDir == nil {
+		c.DestDir = String("")
+	}
+
+	if c.Stream == nil {
+		c.Stream = Bool(false)
+	}
+
+	if c.LineEnding == nil {
+		c.LineEnding = String("lf")
+	}
+
+	if c.BOM == nil {
+		c.BOM = Bool(false)
+	}
+
+	if c.Validate == nil {
+		c.Validate = String("")
+	}
+
+	if c.FanOut == nil {
+		c.FanOut = Bool(false)
+	}
+
+	if c.RenderOnce == nil {
+		c.RenderOnce = Bool(false)
+	}
+
 	if c.Wait == nil {
 		c.Wait = DefaultWaitConfig()
 	}
@@ -438,9 +608,20 @@ func (c *TemplateConfig) GoString() string {
 		"Destination:%s, "+
 		"ErrMissingKey:%s, "+
 		"ErrFatal:%s, "+
+		"IgnoreDepErrors:%s, "+
+		"CommandTrigger:%s, "+
 		"Exec:%#v, "+
+		"GenerationFile:%s, "+
 		"Perms:%s, "+
 		"Source:%s, "+
+		"SourceDir:%s, "+
+		"DestDir:%s, "+
+		"Stream:%s, "+
+		"LineEnding:%s, "+
+		"BOM:%s, "+
+		"Validate:%s, "+
+		"FanOut:%s, "+
+		"RenderOnce:%s, "+
 		"Wait:%#v, "+
 		"LeftDelim:%s, "+
 		"RightDelim:%s, "+
@@ -457,9 +638,20 @@ func (c *TemplateConfig) GoString() string {
 		StringGoString(c.Destination),
 		BoolGoString(c.ErrMissingKey),
 		BoolGoString(c.ErrFatal),
+		c.IgnoreDepErrors,
+		c.CommandTrigger,
 		c.Exec,
+		StringGoString(c.GenerationFile),
 		FileModeGoString(c.Perms),
 		StringGoString(c.Source),
+		StringGoString(c.SourceDir),
+		StringGoString(c.DestDir),
+		BoolGoString(c.Stream),
+		StringGoString(c.LineEnding),
+		BoolGoString(c.BOM),
+		StringGoString(c.Validate),
+		BoolGoString(c.FanOut),
+		BoolGoString(c.RenderOnce),
 		c.Wait,
 		StringGoString(c.LeftDelim),
 		StringGoString(c.RightDelim),
@@ -547,6 +739,61 @@ func (c *TemplateConfigs) Finalize() {
 	}
 }
 
+// expandDirs replaces every template with a source_dir by one template per
+// ".tpl" file found under it, in lexical order. The other options of the
+// template apply to each of them.
+func (c *TemplateConfigs) expandDirs() error {
+	if c == nil {
+		return nil
+	}
+
+	expanded := make(TemplateConfigs, 0, len(*c))
+	for _, t := range *c {
+		if t == nil || (!StringPresent(t.SourceDir) && !StringPresent(t.DestDir)) {
+			expanded = append(expanded, t)
+			continue
+		}
+
+		sourceDir, destDir := StringVal(t.SourceDir), StringVal(t.DestDir)
+		switch {
+		case sourceDir == "":
+			return fmt.Errorf("template: dest_dir %q requires source_dir", destDir)
+		case destDir == "":
+			return fmt.Errorf("template: source_dir %q requires dest_dir", sourceDir)
+		case StringPresent(t.Source) || StringPresent(t.Contents) || StringPresent(t.Destination):
+			return fmt.Errorf("template: source_dir %q cannot be combined with "+
+				"source, contents or destination", sourceDir)
+		}
+
+		err := filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.HasSuffix(d.Name(), TemplateDirSuffix) {
+				return nil
+			}
+
+			rel, err := filepath.Rel(sourceDir, path)
+			if err != nil {
+				return err
+			}
+
+			f := t.Copy()
+			f.SourceDir, f.DestDir = nil, nil
+			f.Source = String(path)
+			f.Destination = String(filepath.Join(destDir, strings.TrimSuffix(rel, TemplateDirSuffix)))
+			expanded = append(expanded, f)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("template: source_dir %q: %w", sourceDir, err)
+		}
+	}
+
+	*c = expanded
+	return nil
+}
+
 // GoString defines the printable version of this struct.
 func (c *TemplateConfigs) GoString() string {
 	if c == nil {
@@ -5,6 +5,8 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"text/template"
@@ -34,8 +36,18 @@ func TestTemplateConfig_Copy(t *testing.T) {
 				CreateDestDirs:           Bool(true),
 				Destination:              String("destination"),
 				Exec:                     &ExecConfig{Command: []string{"command"}},
+				IgnoreDepErrors:          []string{"vault.read(secret/*)"},
+				CommandTrigger:           []string{"key(foo)"},
 				Perms:                    FileMode(0o600),
 				Source:                   String("source"),
+				SourceDir:                String("templates"),
+				DestDir:                  String("rendered"),
+				Stream:                   Bool(true),
+				LineEnding:               String("crlf"),
+				BOM:                      Bool(true),
+				Validate:                 String("json"),
+				FanOut:                   Bool(true),
+				RenderOnce:               Bool(true),
 				Wait:                     &WaitConfig{Min: TimeDuration(10)},
 				LeftDelim:                String("left_delim"),
 				RightDelim:               String("right_delim"),
@@ -325,6 +337,138 @@ func TestTemplateConfig_Merge(t *testing.T) {
 			&TemplateConfig{Source: String("source")},
 			&TemplateConfig{Source: String("source")},
 		},
+		{
+			"source_dir_overrides",
+			&TemplateConfig{SourceDir: String("a"), DestDir: String("b")},
+			&TemplateConfig{SourceDir: String("c"), DestDir: String("d")},
+			&TemplateConfig{SourceDir: String("c"), DestDir: String("d")},
+		},
+		{
+			"source_dir_empty_one",
+			&TemplateConfig{SourceDir: String("a"), DestDir: String("b")},
+			&TemplateConfig{},
+			&TemplateConfig{SourceDir: String("a"), DestDir: String("b")},
+		},
+		{
+			"source_dir_empty_two",
+			&TemplateConfig{},
+			&TemplateConfig{SourceDir: String("a"), DestDir: String("b")},
+			&TemplateConfig{SourceDir: String("a"), DestDir: String("b")},
+		},
+		{
+			"generation_file_overrides",
+			&TemplateConfig{GenerationFile: String("a")},
+			&TemplateConfig{GenerationFile: String("b")},
+			&TemplateConfig{GenerationFile: String("b")},
+		},
+		{
+			"generation_file_empty_one",
+			&TemplateConfig{GenerationFile: String("a")},
+			&TemplateConfig{},
+			&TemplateConfig{GenerationFile: String("a")},
+		},
+		{
+			"generation_file_empty_two",
+			&TemplateConfig{},
+			&TemplateConfig{GenerationFile: String("a")},
+			&TemplateConfig{GenerationFile: String("a")},
+		},
+		{
+			"stream_overrides",
+			&TemplateConfig{Stream: Bool(true)},
+			&TemplateConfig{Stream: Bool(false)},
+			&TemplateConfig{Stream: Bool(false)},
+		},
+		{
+			"stream_empty_one",
+			&TemplateConfig{Stream: Bool(true)},
+			&TemplateConfig{},
+			&TemplateConfig{Stream: Bool(true)},
+		},
+		{
+			"stream_empty_two",
+			&TemplateConfig{},
+			&TemplateConfig{Stream: Bool(true)},
+			&TemplateConfig{Stream: Bool(true)},
+		},
+		{
+			"line_ending_overrides",
+			&TemplateConfig{LineEnding: String("crlf")},
+			&TemplateConfig{LineEnding: String("lf")},
+			&TemplateConfig{LineEnding: String("lf")},
+		},
+		{
+			"line_ending_empty_one",
+			&TemplateConfig{LineEnding: String("crlf")},
+			&TemplateConfig{},
+			&TemplateConfig{LineEnding: String("crlf")},
+		},
+		{
+			"bom_overrides",
+			&TemplateConfig{BOM: Bool(true)},
+			&TemplateConfig{BOM: Bool(false)},
+			&TemplateConfig{BOM: Bool(false)},
+		},
+		{
+			"bom_empty_two",
+			&TemplateConfig{},
+			&TemplateConfig{BOM: Bool(true)},
+			&TemplateConfig{BOM: Bool(true)},
+		},
+		{
+			"validate_overrides",
+			&TemplateConfig{Validate: String("json")},
+			&TemplateConfig{Validate: String("yaml")},
+			&TemplateConfig{Validate: String("yaml")},
+		},
+		{
+			"validate_empty_one",
+			&TemplateConfig{Validate: String("json")},
+			&TemplateConfig{},
+			&TemplateConfig{Validate: String("json")},
+		},
+		{
+			"fan_out_overrides",
+			&TemplateConfig{FanOut: Bool(true)},
+			&TemplateConfig{FanOut: Bool(false)},
+			&TemplateConfig{FanOut: Bool(false)},
+		},
+		{
+			"fan_out_empty_one",
+			&TemplateConfig{FanOut: Bool(true)},
+			&TemplateConfig{},
+			&TemplateConfig{FanOut: Bool(true)},
+		},
+		{
+			"render_once_overrides",
+			&TemplateConfig{RenderOnce: Bool(true)},
+			&TemplateConfig{RenderOnce: Bool(false)},
+			&TemplateConfig{RenderOnce: Bool(false)},
+		},
+		{
+			"render_once_empty_one",
+			&TemplateConfig{RenderOnce: Bool(true)},
+			&TemplateConfig{},
+			&TemplateConfig{RenderOnce: Bool(true)},
+		},
+		{
+			"ignore_dep_errors_merges",
+			&TemplateConfig{IgnoreDepErrors: []string{"a"}},
+			&TemplateConfig{IgnoreDepErrors: []string{"b"}},
+			&TemplateConfig{IgnoreDepErrors: []string{"a", "b"}},
+		},
+		{
+			"ignore_dep_errors_empty_one",
+			&TemplateConfig{IgnoreDepErrors: []string{"a"}},
+			&TemplateConfig{},
+			&TemplateConfig{IgnoreDepErrors: []string{"a"}},
+		},
+		{
+			"command_trigger_merges",
+			&TemplateConfig{CommandTrigger: []string{"a"}},
+			&TemplateConfig{CommandTrigger: []string{"b"}},
+			&TemplateConfig{CommandTrigger: []string{"a", "b"}},
+		},
 		{
 			"wait_overrides",
 			&TemplateConfig{Wait: &WaitConfig{Min: TimeDuration(10)}},
@@ -437,14 +581,16 @@ func TestTemplateConfig_Finalize(t *testing.T) {
 			"empty",
 			&TemplateConfig{},
 			&TemplateConfig{
-				Backup:         Bool(false),
-				Command:        []string{},
-				CommandTimeout: TimeDuration(DefaultTemplateCommandTimeout),
-				Contents:       String(""),
-				CreateDestDirs: Bool(true),
-				Destination:    String(""),
-				ErrMissingKey:  Bool(false),
-				ErrFatal:       Bool(true),
+				Backup:          Bool(false),
+				Command:         []string{},
+				CommandTimeout:  TimeDuration(DefaultTemplateCommandTimeout),
+				Contents:        String(""),
+				CreateDestDirs:  Bool(true),
+				Destination:     String(""),
+				ErrMissingKey:   Bool(false),
+				ErrFatal:        Bool(true),
+				IgnoreDepErrors: []string{},
+				CommandTrigger:  []string{},
 				Exec: &ExecConfig{
 					Command: []string{},
 					Enabled: Bool(false),
@@ -456,14 +602,24 @@ func TestTemplateConfig_Finalize(t *testing.T) {
 						Allowlist:           []string{},
 						AllowlistDeprecated: []string{},
 					},
-					KillSignal:   Signal(DefaultExecKillSignal),
-					KillTimeout:  TimeDuration(DefaultExecKillTimeout),
-					ReloadSignal: Signal(DefaultExecReloadSignal),
-					Splay:        TimeDuration(0 * time.Second),
-					Timeout:      TimeDuration(DefaultTemplateCommandTimeout),
+					KillSignal:     Signal(DefaultExecKillSignal),
+					KillTimeout:    TimeDuration(DefaultExecKillTimeout),
+					LogChildOutput: Bool(false),
+					ReloadSignal:   Signal(DefaultExecReloadSignal),
+					Splay:          TimeDuration(0 * time.Second),
+					Timeout:        TimeDuration(DefaultTemplateCommandTimeout),
 				},
-				Perms:  FileMode(0),
-				Source: String(""),
+				GenerationFile: String(""),
+				Perms:          FileMode(0),
+				Source:         String(""),
+				SourceDir:      String(""),
+				DestDir:        String(""),
+				Stream:         Bool(false),
+				LineEnding:     String("lf"),
+				BOM:            Bool(false),
+				Validate:       String(""),
+				FanOut:         Bool(false),
+				RenderOnce:     Bool(false),
 				Wait: &WaitConfig{
 					Enabled: Bool(false),
 					Max:     TimeDuration(0 * time.Second),
@@ -629,3 +785,116 @@ func TestParseTemplateConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestTemplateConfigs_expandDirs(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range []string{
+		"app.conf.tpl",
+		"README.md",
+		"nested/db.yaml.tpl",
+		"nested/deeper/cert.pem.tpl",
+		"nested/deeper/notes.txt",
+	} {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("{{ key \"foo\" }}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dest := filepath.Join(t.TempDir(), "out")
+
+	t.Run("nested", func(t *testing.T) {
+		c, err := Parse(fmt.Sprintf(`
+			template {
+				source_dir = %q
+				dest_dir   = %q
+				perms      = "0600"
+			}
+			template {
+				source      = "other.tpl"
+				destination = "other"
+			}
+		`, src, dest))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		exp := &TemplateConfigs{
+			{
+				Source:      String(filepath.Join(src, "app.conf.tpl")),
+				Destination: String(filepath.Join(dest, "app.conf")),
+				Perms:       FileMode(0o600),
+			},
+			{
+				Source:      String(filepath.Join(src, "nested", "db.yaml.tpl")),
+				Destination: String(filepath.Join(dest, "nested", "db.yaml")),
+				Perms:       FileMode(0o600),
+			},
+			{
+				Source:      String(filepath.Join(src, "nested", "deeper", "cert.pem.tpl")),
+				Destination: String(filepath.Join(dest, "nested", "deeper", "cert.pem")),
+				Perms:       FileMode(0o600),
+			},
+			{
+				Source:      String("other.tpl"),
+				Destination: String("other"),
+			},
+		}
+		if !reflect.DeepEqual(exp, c.Templates) {
+			t.Errorf("\nexp: %#v\nact: %#v", exp, c.Templates)
+		}
+	})
+
+	t.Run("empty_dir", func(t *testing.T) {
+		c, err := Parse(fmt.Sprintf(`
+			template {
+				source_dir = %q
+				dest_dir   = %q
+			}
+		`, t.TempDir(), dest))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(*c.Templates) != 0 {
+			t.Errorf("expected no templates, got %#v", c.Templates)
+		}
+	})
+
+	errCases := []struct {
+		name string
+		i    string
+	}{
+		{
+			"missing_dest_dir",
+			fmt.Sprintf(`template { source_dir = %q }`, src),
+		},
+		{
+			"missing_source_dir",
+			fmt.Sprintf(`template { dest_dir = %q }`, dest),
+		},
+		{
+			"with_source",
+			fmt.Sprintf(`template {
+				source_dir = %q
+				dest_dir   = %q
+				source     = "other.tpl"
+			}`, src, dest),
+		},
+		{
+			"missing_dir",
+			fmt.Sprintf(`template {
+				source_dir = %q
+				dest_dir   = %q
+			}`, filepath.Join(src, "missing"), dest),
+		},
+	}
+	for i, tc := range errCases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			if _, err := Parse(tc.i); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
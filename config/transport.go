@@ -61,6 +61,10 @@ type TransportConfig struct {
 	// significantly decreases performance.
 	DisableKeepAlives *bool `mapstructure:"disable_keep_alives"`
 
+	// EnableHTTP2 makes the transport attempt to negotiate HTTP/2 with the
+	// server, which multiplexes requests over fewer connections.
+	EnableHTTP2 *bool `mapstructure:"enable_http2"`
+
 	// IdleConnTimeout is the timeout for idle connections.
 	IdleConnTimeout *time.Duration `mapstructure:"idle_conn_timeout"`
 
@@ -97,6 +101,7 @@ func (c *TransportConfig) Copy() *TransportConfig {
 	o.DialKeepAlive = c.DialKeepAlive
 	o.DialTimeout = c.DialTimeout
 	o.DisableKeepAlives = c.DisableKeepAlives
+	o.EnableHTTP2 = c.EnableHTTP2
 	o.IdleConnTimeout = c.IdleConnTimeout
 	o.MaxIdleConns = c.MaxIdleConns
 	o.MaxConnsPerHost = c.MaxConnsPerHost
@@ -140,6 +145,10 @@ func (c *TransportConfig) Merge(o *TransportConfig) *TransportConfig {
 		r.DisableKeepAlives = o.DisableKeepAlives
 	}
 
+	if o.EnableHTTP2 != nil {
+		r.EnableHTTP2 = o.EnableHTTP2
+	}
+
 	if o.IdleConnTimeout != nil {
 		r.IdleConnTimeout = o.IdleConnTimeout
 	}
@@ -177,6 +186,10 @@ func (c *TransportConfig) Finalize() {
 		c.DisableKeepAlives = Bool(false)
 	}
 
+	if c.EnableHTTP2 == nil {
+		c.EnableHTTP2 = Bool(false)
+	}
+
 	if c.IdleConnTimeout == nil {
 		c.IdleConnTimeout = TimeDuration(DefaultIdleConnTimeout)
 	}
@@ -208,6 +221,9 @@ func (c *TransportConfig) GoString() string {
 		"DialKeepAlive:%s, "+
 		"DialTimeout:%s, "+
 		"DisableKeepAlives:%t, "+
+		"EnableHTTP2:%t, "+
+		"IdleConnTimeout:%s, "+
+		"MaxIdleConns:%d, "+
 		"MaxIdleConnsPerHost:%d, "+
 		"TLSHandshakeTimeout:%s,"+
 		"MaxConnsPerHost:%d"+
@@ -215,6 +231,9 @@ func (c *TransportConfig) GoString() string {
 		TimeDurationVal(c.DialKeepAlive),
 		TimeDurationVal(c.DialTimeout),
 		BoolVal(c.DisableKeepAlives),
+		BoolVal(c.EnableHTTP2),
+		TimeDurationVal(c.IdleConnTimeout),
+		IntVal(c.MaxIdleConns),
 		IntVal(c.MaxIdleConnsPerHost),
 		TimeDurationVal(c.TLSHandshakeTimeout),
 		IntVal(c.MaxConnsPerHost),
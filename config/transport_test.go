@@ -30,6 +30,7 @@ func TestTransportConfig_Copy(t *testing.T) {
 				DialKeepAlive:       TimeDuration(10 * time.Second),
 				DialTimeout:         TimeDuration(20 * time.Second),
 				DisableKeepAlives:   Bool(true),
+				EnableHTTP2:         Bool(true),
 				IdleConnTimeout:     TimeDuration(40 * time.Second),
 				MaxIdleConns:        Int(150),
 				MaxIdleConnsPerHost: Int(15),
@@ -164,6 +165,24 @@ func TestTransportConfig_Merge(t *testing.T) {
 			&TransportConfig{DisableKeepAlives: Bool(true)},
 			&TransportConfig{DisableKeepAlives: Bool(true)},
 		},
+		{
+			"enable_http2_overrides",
+			&TransportConfig{EnableHTTP2: Bool(true)},
+			&TransportConfig{EnableHTTP2: Bool(false)},
+			&TransportConfig{EnableHTTP2: Bool(false)},
+		},
+		{
+			"enable_http2_empty_one",
+			&TransportConfig{EnableHTTP2: Bool(true)},
+			&TransportConfig{},
+			&TransportConfig{EnableHTTP2: Bool(true)},
+		},
+		{
+			"enable_http2_empty_two",
+			&TransportConfig{},
+			&TransportConfig{EnableHTTP2: Bool(true)},
+			&TransportConfig{EnableHTTP2: Bool(true)},
+		},
 		{
 			"idle_conn_timeout_overrides",
 			&TransportConfig{IdleConnTimeout: TimeDuration(150 * time.Second)},
@@ -333,6 +352,7 @@ func TestTransportConfig_Finalize(t *testing.T) {
 				DialKeepAlive:       TimeDuration(DefaultDialKeepAlive),
 				DialTimeout:         TimeDuration(DefaultDialTimeout),
 				DisableKeepAlives:   Bool(false),
+				EnableHTTP2:         Bool(false),
 				IdleConnTimeout:     TimeDuration(DefaultIdleConnTimeout),
 				MaxIdleConns:        Int(DefaultMaxIdleConns),
 				MaxConnsPerHost:     Int(DefaultMaxConnsPerHost),
@@ -38,6 +38,10 @@ const (
 	// lease to wait for before refreshing
 	DefaultLeaseRenewalThreshold = .90
 
+	// DefaultPKIRenewThreshold is the default fraction of a PKI certificate's
+	// lifetime to wait for before issuing a new certificate.
+	DefaultPKIRenewThreshold = .90
+
 	// DefaultK8SServiceAccountTokenPath is a default path to a file
 	// with service token for the k8s auth method.
 	DefaultK8SServiceAccountTokenPath = "/run/secrets/kubernetes.io/serviceaccount/token"
@@ -52,6 +56,11 @@ type VaultConfig struct {
 	// Address is the URI to the Vault server.
 	Address *string `mapstructure:"address"`
 
+	// Auth logs in to Vault with an auth method when no Token is given. The
+	// resulting token is renewed, and a new one is obtained by logging in again
+	// once it can no longer be renewed.
+	Auth *VaultAuthConfig `mapstructure:"auth"`
+
 	// Enabled controls whether the Vault integration is active.
 	Enabled *bool `mapstructure:"enabled"`
 
@@ -59,6 +68,15 @@ type VaultConfig struct {
 	// also be set via the VAULT_NAMESPACE environment variable.
 	Namespace *string `mapstructure:"namespace"`
 
+	// Proxy is the URL of the HTTP(S) or SOCKS5 proxy to use to reach Vault,
+	// e.g. "socks5://127.0.0.1:1080". If empty, the proxy from the environment
+	// is used.
+	Proxy *string `mapstructure:"proxy"`
+
+	// RateLimit caps the rate of requests to Vault. Requests over the limit wait
+	// instead of failing.
+	RateLimit *RateLimitConfig `mapstructure:"rate_limit"`
+
 	// RenewToken renews the Vault token.
 	RenewToken *bool `mapstructure:"renew_token"`
 
@@ -99,6 +117,11 @@ type VaultConfig struct {
 	// duration.
 	LeaseRenewalThreshold *float64 `mapstructure:"lease_renewal_threshold"`
 
+	// PKIRenewThreshold configures how much of a PKI certificate's lifetime,
+	// measured as a fraction from when it was issued, should elapse before
+	// Consul Template issues a new certificate.
+	PKIRenewThreshold *float64 `mapstructure:"pki_renew_threshold"`
+
 	// If Token is empty and K8SAuthRoleName is set, it means to use
 	// k8s vault auth method.
 	//
@@ -132,6 +155,7 @@ type VaultConfig struct {
 // default values.
 func DefaultVaultConfig() *VaultConfig {
 	v := &VaultConfig{
+		RateLimit: DefaultRateLimitConfig(),
 		Retry:     DefaultRetryConfig(),
 		SSL:       DefaultSSLConfig(),
 		Transport: DefaultTransportConfig(),
@@ -152,10 +176,20 @@ func (c *VaultConfig) Copy() *VaultConfig {
 	var o VaultConfig
 	o.Address = c.Address
 
+	if c.Auth != nil {
+		o.Auth = c.Auth.Copy()
+	}
+
 	o.Enabled = c.Enabled
 
 	o.Namespace = c.Namespace
 
+	o.Proxy = c.Proxy
+
+	if c.RateLimit != nil {
+		o.RateLimit = c.RateLimit.Copy()
+	}
+
 	o.RenewToken = c.RenewToken
 
 	if c.Retry != nil {
@@ -178,6 +212,7 @@ func (c *VaultConfig) Copy() *VaultConfig {
 
 	o.DefaultLeaseDuration = c.DefaultLeaseDuration
 	o.LeaseRenewalThreshold = c.LeaseRenewalThreshold
+	o.PKIRenewThreshold = c.PKIRenewThreshold
 
 	o.K8SAuthRoleName = c.K8SAuthRoleName
 	o.K8SServiceAccountToken = c.K8SServiceAccountToken
@@ -209,6 +244,10 @@ func (c *VaultConfig) Merge(o *VaultConfig) *VaultConfig {
 		r.Address = o.Address
 	}
 
+	if o.Auth != nil {
+		r.Auth = r.Auth.Merge(o.Auth)
+	}
+
 	if o.Enabled != nil {
 		r.Enabled = o.Enabled
 	}
@@ -217,6 +256,14 @@ func (c *VaultConfig) Merge(o *VaultConfig) *VaultConfig {
 		r.Namespace = o.Namespace
 	}
 
+	if o.Proxy != nil {
+		r.Proxy = o.Proxy
+	}
+
+	if o.RateLimit != nil {
+		r.RateLimit = r.RateLimit.Merge(o.RateLimit)
+	}
+
 	if o.RenewToken != nil {
 		r.RenewToken = o.RenewToken
 	}
@@ -257,6 +304,10 @@ func (c *VaultConfig) Merge(o *VaultConfig) *VaultConfig {
 		r.LeaseRenewalThreshold = o.LeaseRenewalThreshold
 	}
 
+	if o.PKIRenewThreshold != nil {
+		r.PKIRenewThreshold = o.PKIRenewThreshold
+	}
+
 	if o.K8SAuthRoleName != nil {
 		r.K8SAuthRoleName = o.K8SAuthRoleName
 	}
@@ -284,10 +335,24 @@ func (c *VaultConfig) Finalize() {
 		}, "")
 	}
 
+	if c.Auth == nil {
+		c.Auth = DefaultVaultAuthConfig()
+	}
+	c.Auth.Finalize()
+
 	if c.Namespace == nil {
 		c.Namespace = stringFromEnv([]string{"VAULT_NAMESPACE"}, "")
 	}
 
+	if c.Proxy == nil {
+		c.Proxy = String("")
+	}
+
+	if c.RateLimit == nil {
+		c.RateLimit = DefaultRateLimitConfig()
+	}
+	c.RateLimit.Finalize()
+
 	if c.Retry == nil {
 		c.Retry = DefaultRetryConfig()
 	}
@@ -383,6 +448,10 @@ func (c *VaultConfig) Finalize() {
 		c.LeaseRenewalThreshold = Float64(DefaultLeaseRenewalThreshold)
 	}
 
+	if c.PKIRenewThreshold == nil {
+		c.PKIRenewThreshold = Float64(DefaultPKIRenewThreshold)
+	}
+
 	if c.K8SAuthRoleName == nil {
 		c.K8SAuthRoleName = stringFromEnv([]string{
 			"VAULT_K8S_AUTH_ROLE_NAME",
@@ -405,6 +474,21 @@ func (c *VaultConfig) Finalize() {
 	}
 }
 
+// validate returns an error if an option is set to a value Consul Template
+// cannot use.
+func (c *VaultConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+
+	if c.PKIRenewThreshold != nil {
+		if t := *c.PKIRenewThreshold; t <= 0 || t >= 1 {
+			return fmt.Errorf("vault: pki_renew_threshold must be between 0 and 1, got %v", t)
+		}
+	}
+	return nil
+}
+
 // GoString defines the printable version of this struct.
 func (c *VaultConfig) GoString() string {
 	if c == nil {
@@ -413,8 +497,11 @@ func (c *VaultConfig) GoString() string {
 
 	return fmt.Sprintf("&VaultConfig{"+
 		"Address:%s, "+
+		"Auth:%#v, "+
 		"Enabled:%s, "+
 		"Namespace:%s,"+
+		"Proxy:%s, "+
+		"RateLimit:%#v, "+
 		"RenewToken:%s, "+
 		"Retry:%#v, "+
 		"SSL:%#v, "+
@@ -424,14 +511,18 @@ func (c *VaultConfig) GoString() string {
 		"UnwrapToken:%s, "+
 		"DefaultLeaseDuration:%s, "+
 		"LeaseRenewalThreshold:%s, "+
+		"PKIRenewThreshold:%s, "+
 		"K8SAuthRoleName:%s, "+
 		"K8SServiceAccountToken:%s, "+
 		"K8SServiceAccountTokenPath:%s, "+
 		"K8SServiceMountPath:%s, "+
 		"}",
 		StringGoString(c.Address),
+		c.Auth,
 		BoolGoString(c.Enabled),
 		StringGoString(c.Namespace),
+		StringGoString(c.Proxy),
+		c.RateLimit,
 		BoolGoString(c.RenewToken),
 		c.Retry,
 		c.SSL,
@@ -441,6 +532,7 @@ func (c *VaultConfig) GoString() string {
 		BoolGoString(c.UnwrapToken),
 		TimeDurationGoString(c.DefaultLeaseDuration),
 		FloatGoString(c.LeaseRenewalThreshold),
+		FloatGoString(c.PKIRenewThreshold),
 		StringGoString(c.K8SAuthRoleName),
 		StringGoString(c.K8SServiceAccountToken),
 		StringGoString(c.K8SServiceAccountTokenPath),
@@ -0,0 +1,155 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// VaultAuthConfig is the configuration for logging in to Vault with an auth
+// method instead of supplying a token.
+type VaultAuthConfig struct {
+	// Enabled signals if logging in with an auth method is enabled.
+	Enabled *bool `mapstructure:"enabled"`
+
+	// Method is the type of the auth method, like "approle" or "kubernetes".
+	Method *string `mapstructure:"method"`
+
+	// Mount is the path the auth method is mounted at, so the login path is
+	// "auth/<mount>/login". It defaults to the method.
+	Mount *string `mapstructure:"mount"`
+
+	// Config are the parameters of the login request, like the role_id and
+	// secret_id of the approle method. A parameter with the "_file" suffix is
+	// read from that file instead, without the suffix, e.g. secret_id_file.
+	Config map[string]string `mapstructure:"config" json:"-"`
+}
+
+// DefaultVaultAuthConfig returns a configuration that is populated with the
+// default values.
+func DefaultVaultAuthConfig() *VaultAuthConfig {
+	return &VaultAuthConfig{}
+}
+
+// Copy returns a deep copy of this configuration.
+func (c *VaultAuthConfig) Copy() *VaultAuthConfig {
+	if c == nil {
+		return nil
+	}
+
+	var o VaultAuthConfig
+
+	o.Enabled = c.Enabled
+
+	o.Method = c.Method
+
+	o.Mount = c.Mount
+
+	if c.Config != nil {
+		o.Config = make(map[string]string, len(c.Config))
+		for k, v := range c.Config {
+			o.Config[k] = v
+		}
+	}
+
+	return &o
+}
+
+// Merge combines all values in this configuration with the values in the other
+// configuration, with values in the other configuration taking precedence.
+// Maps and slices are merged, most other values are overwritten. Complex
+// structs define their own merge functionality.
+func (c *VaultAuthConfig) Merge(o *VaultAuthConfig) *VaultAuthConfig {
+	if c == nil {
+		if o == nil {
+			return nil
+		}
+		return o.Copy()
+	}
+
+	if o == nil {
+		return c.Copy()
+	}
+
+	r := c.Copy()
+
+	if o.Enabled != nil {
+		r.Enabled = o.Enabled
+	}
+
+	if o.Method != nil {
+		r.Method = o.Method
+	}
+
+	if o.Mount != nil {
+		r.Mount = o.Mount
+	}
+
+	if o.Config != nil {
+		if r.Config == nil {
+			r.Config = make(map[string]string, len(o.Config))
+		}
+		for k, v := range o.Config {
+			r.Config[k] = v
+		}
+	}
+
+	return r
+}
+
+// Finalize ensures there no nil pointers.
+func (c *VaultAuthConfig) Finalize() {
+	if c.Enabled == nil {
+		c.Enabled = Bool(StringPresent(c.Method))
+	}
+
+	if c.Method == nil {
+		c.Method = String("")
+	}
+
+	if c.Mount == nil {
+		c.Mount = String(*c.Method)
+	}
+
+	if c.Config == nil {
+		c.Config = make(map[string]string)
+	}
+
+	// Like k8s_auth_role_name, the kubernetes method logs in with the service
+	// account token of the pod unless another JWT is given.
+	if *c.Method == "kubernetes" {
+		_, jwt := c.Config["jwt"]
+		_, jwtFile := c.Config["jwt_file"]
+		if !jwt && !jwtFile {
+			c.Config["jwt_file"] = DefaultK8SServiceAccountTokenPath
+		}
+	}
+}
+
+// GoString defines the printable version of this struct. Only the names of
+// the config parameters are printed, since their values are credentials.
+func (c *VaultAuthConfig) GoString() string {
+	if c == nil {
+		return "(*VaultAuthConfig)(nil)"
+	}
+
+	keys := make([]string, 0, len(c.Config))
+	for k := range c.Config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return fmt.Sprintf("&VaultAuthConfig{"+
+		"Enabled:%s, "+
+		"Method:%s, "+
+		"Mount:%s, "+
+		"Config:%q"+
+		"}",
+		BoolGoString(c.Enabled),
+		StringGoString(c.Method),
+		StringGoString(c.Mount),
+		keys,
+	)
+}
@@ -0,0 +1,194 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestVaultAuthConfig_Copy(t *testing.T) {
+	cases := []struct {
+		name string
+		a    *VaultAuthConfig
+	}{
+		{
+			"nil",
+			nil,
+		},
+		{
+			"empty",
+			&VaultAuthConfig{},
+		},
+		{
+			"same_enabled",
+			&VaultAuthConfig{
+				Enabled: Bool(true),
+				Method:  String("approle"),
+				Mount:   String("ci"),
+				Config:  map[string]string{"role_id": "role"},
+			},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			r := tc.a.Copy()
+			if !reflect.DeepEqual(tc.a, r) {
+				t.Errorf("\nexp: %#v\nact: %#v", tc.a, r)
+			}
+		})
+	}
+}
+
+func TestVaultAuthConfig_Merge(t *testing.T) {
+	cases := []struct {
+		name string
+		a    *VaultAuthConfig
+		b    *VaultAuthConfig
+		r    *VaultAuthConfig
+	}{
+		{
+			"nil_a",
+			nil,
+			&VaultAuthConfig{},
+			&VaultAuthConfig{},
+		},
+		{
+			"nil_b",
+			&VaultAuthConfig{},
+			nil,
+			&VaultAuthConfig{},
+		},
+		{
+			"nil_both",
+			nil,
+			nil,
+			nil,
+		},
+		{
+			"empty",
+			&VaultAuthConfig{},
+			&VaultAuthConfig{},
+			&VaultAuthConfig{},
+		},
+		{
+			"method_overrides",
+			&VaultAuthConfig{Method: String("approle")},
+			&VaultAuthConfig{Method: String("kubernetes")},
+			&VaultAuthConfig{Method: String("kubernetes")},
+		},
+		{
+			"mount_empty_one",
+			&VaultAuthConfig{Mount: String("ci")},
+			&VaultAuthConfig{},
+			&VaultAuthConfig{Mount: String("ci")},
+		},
+		{
+			"config_merges",
+			&VaultAuthConfig{Config: map[string]string{"role_id": "a", "secret_id": "b"}},
+			&VaultAuthConfig{Config: map[string]string{"secret_id": "c"}},
+			&VaultAuthConfig{Config: map[string]string{"role_id": "a", "secret_id": "c"}},
+		},
+		{
+			"config_empty_one",
+			&VaultAuthConfig{},
+			&VaultAuthConfig{Config: map[string]string{"role_id": "a"}},
+			&VaultAuthConfig{Config: map[string]string{"role_id": "a"}},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			r := tc.a.Merge(tc.b)
+			if !reflect.DeepEqual(tc.r, r) {
+				t.Errorf("\nexp: %#v\nact: %#v", tc.r, r)
+			}
+		})
+	}
+}
+
+func TestVaultAuthConfig_Finalize(t *testing.T) {
+	cases := []struct {
+		name string
+		i    *VaultAuthConfig
+		r    *VaultAuthConfig
+	}{
+		{
+			"empty",
+			&VaultAuthConfig{},
+			&VaultAuthConfig{
+				Enabled: Bool(false),
+				Method:  String(""),
+				Mount:   String(""),
+				Config:  map[string]string{},
+			},
+		},
+		{
+			"with_method",
+			&VaultAuthConfig{
+				Method: String("approle"),
+				Config: map[string]string{"role_id": "role"},
+			},
+			&VaultAuthConfig{
+				Enabled: Bool(true),
+				Method:  String("approle"),
+				Mount:   String("approle"),
+				Config:  map[string]string{"role_id": "role"},
+			},
+		},
+		{
+			"with_mount",
+			&VaultAuthConfig{
+				Method: String("approle"),
+				Mount:  String("ci"),
+			},
+			&VaultAuthConfig{
+				Enabled: Bool(true),
+				Method:  String("approle"),
+				Mount:   String("ci"),
+				Config:  map[string]string{},
+			},
+		},
+		{
+			"kubernetes_default_jwt",
+			&VaultAuthConfig{
+				Method: String("kubernetes"),
+				Config: map[string]string{"role": "app"},
+			},
+			&VaultAuthConfig{
+				Enabled: Bool(true),
+				Method:  String("kubernetes"),
+				Mount:   String("kubernetes"),
+				Config: map[string]string{
+					"role":     "app",
+					"jwt_file": DefaultK8SServiceAccountTokenPath,
+				},
+			},
+		},
+		{
+			"kubernetes_jwt",
+			&VaultAuthConfig{
+				Method: String("kubernetes"),
+				Config: map[string]string{"role": "app", "jwt": "jwt"},
+			},
+			&VaultAuthConfig{
+				Enabled: Bool(true),
+				Method:  String("kubernetes"),
+				Mount:   String("kubernetes"),
+				Config:  map[string]string{"role": "app", "jwt": "jwt"},
+			},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			tc.i.Finalize()
+			if !reflect.DeepEqual(tc.r, tc.i) {
+				t.Errorf("\nexp: %#v\nact: %#v", tc.r, tc.i)
+			}
+		})
+	}
+}
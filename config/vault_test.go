@@ -29,8 +29,10 @@ func TestVaultConfig_Copy(t *testing.T) {
 			"same_enabled",
 			&VaultConfig{
 				Address:    String("address"),
+				Auth:       &VaultAuthConfig{Method: String("approle")},
 				Enabled:    Bool(true),
 				Namespace:  String("foo"),
+				RateLimit:  &RateLimitConfig{QPS: Float64(5)},
 				RenewToken: Bool(true),
 				Retry:      &RetryConfig{Enabled: Bool(true)},
 				SSL:        &SSLConfig{Enabled: Bool(true)},
@@ -42,6 +44,7 @@ func TestVaultConfig_Copy(t *testing.T) {
 				VaultAgentTokenFile:        String("/tmp/vault/agent/token"),
 				DefaultLeaseDuration:       TimeDuration(5 * time.Minute),
 				LeaseRenewalThreshold:      Float64(0.70),
+				PKIRenewThreshold:          Float64(0.80),
 				K8SAuthRoleName:            String("default"),
 				K8SServiceAccountTokenPath: String("account_token_path"),
 				K8SServiceAccountToken:     String("account_token"),
@@ -170,6 +173,24 @@ func TestVaultConfig_Merge(t *testing.T) {
 			&VaultConfig{Namespace: String("foo")},
 			&VaultConfig{Namespace: String("foo")},
 		},
+		{
+			"proxy_overrides",
+			&VaultConfig{Proxy: String("http://foo:3128")},
+			&VaultConfig{Proxy: String("socks5://bar:1080")},
+			&VaultConfig{Proxy: String("socks5://bar:1080")},
+		},
+		{
+			"proxy_empty_one",
+			&VaultConfig{Proxy: String("http://foo:3128")},
+			&VaultConfig{},
+			&VaultConfig{Proxy: String("http://foo:3128")},
+		},
+		{
+			"proxy_empty_two",
+			&VaultConfig{},
+			&VaultConfig{Proxy: String("socks5://bar:1080")},
+			&VaultConfig{Proxy: String("socks5://bar:1080")},
+		},
 		{
 			"token_overrides",
 			&VaultConfig{Token: String("token")},
@@ -242,6 +263,30 @@ func TestVaultConfig_Merge(t *testing.T) {
 			&VaultConfig{RenewToken: Bool(true)},
 			&VaultConfig{RenewToken: Bool(true)},
 		},
+		{
+			"rate_limit_overrides",
+			&VaultConfig{RateLimit: &RateLimitConfig{QPS: Float64(5)}},
+			&VaultConfig{RateLimit: &RateLimitConfig{QPS: Float64(10)}},
+			&VaultConfig{RateLimit: &RateLimitConfig{QPS: Float64(10)}},
+		},
+		{
+			"auth_merges",
+			&VaultConfig{Auth: &VaultAuthConfig{Method: String("approle")}},
+			&VaultConfig{Auth: &VaultAuthConfig{Mount: String("ci")}},
+			&VaultConfig{Auth: &VaultAuthConfig{Method: String("approle"), Mount: String("ci")}},
+		},
+		{
+			"rate_limit_empty_one",
+			&VaultConfig{RateLimit: &RateLimitConfig{QPS: Float64(5)}},
+			&VaultConfig{},
+			&VaultConfig{RateLimit: &RateLimitConfig{QPS: Float64(5)}},
+		},
+		{
+			"rate_limit_empty_two",
+			&VaultConfig{},
+			&VaultConfig{RateLimit: &RateLimitConfig{QPS: Float64(5)}},
+			&VaultConfig{RateLimit: &RateLimitConfig{QPS: Float64(5)}},
+		},
 		{
 			"retry_overrides",
 			&VaultConfig{Retry: &RetryConfig{Enabled: Bool(true)}},
@@ -362,6 +407,24 @@ func TestVaultConfig_Merge(t *testing.T) {
 			&VaultConfig{LeaseRenewalThreshold: Float64(0.7)},
 			&VaultConfig{LeaseRenewalThreshold: Float64(0.7)},
 		},
+		{
+			"pki_renew_threshold_overrides",
+			&VaultConfig{PKIRenewThreshold: Float64(0.8)},
+			&VaultConfig{PKIRenewThreshold: Float64(0.7)},
+			&VaultConfig{PKIRenewThreshold: Float64(0.7)},
+		},
+		{
+			"pki_renew_threshold_empty_one",
+			&VaultConfig{PKIRenewThreshold: Float64(0.7)},
+			&VaultConfig{},
+			&VaultConfig{PKIRenewThreshold: Float64(0.7)},
+		},
+		{
+			"pki_renew_threshold_empty_two",
+			&VaultConfig{},
+			&VaultConfig{PKIRenewThreshold: Float64(0.7)},
+			&VaultConfig{PKIRenewThreshold: Float64(0.7)},
+		},
 		{
 			"k8s_auth_role_name_overrides",
 			&VaultConfig{K8SAuthRoleName: String("first")},
@@ -482,9 +545,21 @@ func TestVaultConfig_Finalize(t *testing.T) {
 			nil,
 			&VaultConfig{},
 			&VaultConfig{
-				Address:    String(""),
-				Enabled:    Bool(false),
-				Namespace:  String(""),
+				Address:   String(""),
+				Enabled:   Bool(false),
+				Namespace: String(""),
+				Proxy:     String(""),
+				Auth: &VaultAuthConfig{
+					Enabled: Bool(false),
+					Method:  String(""),
+					Mount:   String(""),
+					Config:  map[string]string{},
+				},
+				RateLimit: &RateLimitConfig{
+					Enabled: Bool(false),
+					QPS:     Float64(0),
+					Burst:   Int(1),
+				},
 				RenewToken: Bool(false),
 				Retry: &RetryConfig{
 					Backoff:    TimeDuration(DefaultRetryBackoff),
@@ -507,6 +582,7 @@ func TestVaultConfig_Finalize(t *testing.T) {
 					DialKeepAlive:       TimeDuration(DefaultDialKeepAlive),
 					DialTimeout:         TimeDuration(DefaultDialTimeout),
 					DisableKeepAlives:   Bool(false),
+					EnableHTTP2:         Bool(false),
 					IdleConnTimeout:     TimeDuration(DefaultIdleConnTimeout),
 					MaxIdleConns:        Int(DefaultMaxIdleConns),
 					MaxIdleConnsPerHost: Int(DefaultMaxIdleConnsPerHost),
@@ -516,6 +592,7 @@ func TestVaultConfig_Finalize(t *testing.T) {
 				UnwrapToken:                Bool(DefaultVaultUnwrapToken),
 				DefaultLeaseDuration:       TimeDuration(DefaultVaultLeaseDuration),
 				LeaseRenewalThreshold:      Float64(DefaultLeaseRenewalThreshold),
+				PKIRenewThreshold:          Float64(DefaultPKIRenewThreshold),
 				K8SAuthRoleName:            String(""),
 				K8SServiceAccountTokenPath: String(DefaultK8SServiceAccountTokenPath),
 				K8SServiceAccountToken:     String(""),
@@ -529,9 +606,21 @@ func TestVaultConfig_Finalize(t *testing.T) {
 				Address: String("address"),
 			},
 			&VaultConfig{
-				Address:    String("address"),
-				Enabled:    Bool(true),
-				Namespace:  String(""),
+				Address:   String("address"),
+				Enabled:   Bool(true),
+				Namespace: String(""),
+				Proxy:     String(""),
+				Auth: &VaultAuthConfig{
+					Enabled: Bool(false),
+					Method:  String(""),
+					Mount:   String(""),
+					Config:  map[string]string{},
+				},
+				RateLimit: &RateLimitConfig{
+					Enabled: Bool(false),
+					QPS:     Float64(0),
+					Burst:   Int(1),
+				},
 				RenewToken: Bool(false),
 				Retry: &RetryConfig{
 					Backoff:    TimeDuration(DefaultRetryBackoff),
@@ -554,6 +643,7 @@ func TestVaultConfig_Finalize(t *testing.T) {
 					DialKeepAlive:       TimeDuration(DefaultDialKeepAlive),
 					DialTimeout:         TimeDuration(DefaultDialTimeout),
 					DisableKeepAlives:   Bool(false),
+					EnableHTTP2:         Bool(false),
 					IdleConnTimeout:     TimeDuration(DefaultIdleConnTimeout),
 					MaxIdleConns:        Int(DefaultMaxIdleConns),
 					MaxIdleConnsPerHost: Int(DefaultMaxIdleConnsPerHost),
@@ -563,6 +653,7 @@ func TestVaultConfig_Finalize(t *testing.T) {
 				UnwrapToken:                Bool(DefaultVaultUnwrapToken),
 				DefaultLeaseDuration:       TimeDuration(DefaultVaultLeaseDuration),
 				LeaseRenewalThreshold:      Float64(DefaultLeaseRenewalThreshold),
+				PKIRenewThreshold:          Float64(DefaultPKIRenewThreshold),
 				K8SAuthRoleName:            String(""),
 				K8SServiceAccountTokenPath: String(DefaultK8SServiceAccountTokenPath),
 				K8SServiceAccountToken:     String(""),
@@ -581,9 +672,21 @@ func TestVaultConfig_Finalize(t *testing.T) {
 				},
 			},
 			&VaultConfig{
-				Address:    String("address"),
-				Enabled:    Bool(true),
-				Namespace:  String(""),
+				Address:   String("address"),
+				Enabled:   Bool(true),
+				Namespace: String(""),
+				Proxy:     String(""),
+				Auth: &VaultAuthConfig{
+					Enabled: Bool(false),
+					Method:  String(""),
+					Mount:   String(""),
+					Config:  map[string]string{},
+				},
+				RateLimit: &RateLimitConfig{
+					Enabled: Bool(false),
+					QPS:     Float64(0),
+					Burst:   Int(1),
+				},
 				RenewToken: Bool(false),
 				Retry: &RetryConfig{
 					Backoff:    TimeDuration(DefaultRetryBackoff),
@@ -606,6 +709,7 @@ func TestVaultConfig_Finalize(t *testing.T) {
 					DialKeepAlive:       TimeDuration(DefaultDialKeepAlive),
 					DialTimeout:         TimeDuration(DefaultDialTimeout),
 					DisableKeepAlives:   Bool(false),
+					EnableHTTP2:         Bool(false),
 					IdleConnTimeout:     TimeDuration(DefaultIdleConnTimeout),
 					MaxIdleConns:        Int(20),
 					MaxIdleConnsPerHost: Int(5),
@@ -615,6 +719,7 @@ func TestVaultConfig_Finalize(t *testing.T) {
 				UnwrapToken:                Bool(DefaultVaultUnwrapToken),
 				DefaultLeaseDuration:       TimeDuration(DefaultVaultLeaseDuration),
 				LeaseRenewalThreshold:      Float64(DefaultLeaseRenewalThreshold),
+				PKIRenewThreshold:          Float64(DefaultPKIRenewThreshold),
 				K8SAuthRoleName:            String(""),
 				K8SServiceAccountTokenPath: String(DefaultK8SServiceAccountTokenPath),
 				K8SServiceAccountToken:     String(""),
@@ -638,9 +743,21 @@ func TestVaultConfig_Finalize(t *testing.T) {
 				},
 			},
 			&VaultConfig{
-				Address:    String("address"),
-				Enabled:    Bool(true),
-				Namespace:  String(""),
+				Address:   String("address"),
+				Enabled:   Bool(true),
+				Namespace: String(""),
+				Proxy:     String(""),
+				Auth: &VaultAuthConfig{
+					Enabled: Bool(false),
+					Method:  String(""),
+					Mount:   String(""),
+					Config:  map[string]string{},
+				},
+				RateLimit: &RateLimitConfig{
+					Enabled: Bool(false),
+					QPS:     Float64(0),
+					Burst:   Int(1),
+				},
 				RenewToken: Bool(false),
 				Retry: &RetryConfig{
 					Backoff:    TimeDuration(DefaultRetryBackoff),
@@ -663,6 +780,7 @@ func TestVaultConfig_Finalize(t *testing.T) {
 					DialKeepAlive:       TimeDuration(DefaultDialKeepAlive),
 					DialTimeout:         TimeDuration(DefaultDialTimeout),
 					DisableKeepAlives:   Bool(false),
+					EnableHTTP2:         Bool(false),
 					IdleConnTimeout:     TimeDuration(DefaultIdleConnTimeout),
 					MaxIdleConns:        Int(DefaultMaxIdleConns),
 					MaxIdleConnsPerHost: Int(DefaultMaxIdleConnsPerHost),
@@ -672,6 +790,7 @@ func TestVaultConfig_Finalize(t *testing.T) {
 				UnwrapToken:                Bool(DefaultVaultUnwrapToken),
 				DefaultLeaseDuration:       TimeDuration(DefaultVaultLeaseDuration),
 				LeaseRenewalThreshold:      Float64(DefaultLeaseRenewalThreshold),
+				PKIRenewThreshold:          Float64(DefaultPKIRenewThreshold),
 				K8SAuthRoleName:            String(""),
 				K8SServiceAccountTokenPath: String(DefaultK8SServiceAccountTokenPath),
 				K8SServiceAccountToken:     String(""),
@@ -693,9 +812,21 @@ func TestVaultConfig_Finalize(t *testing.T) {
 				Address: String("address"),
 			},
 			&VaultConfig{
-				Address:    String("address"),
-				Enabled:    Bool(true),
-				Namespace:  String(""),
+				Address:   String("address"),
+				Enabled:   Bool(true),
+				Namespace: String(""),
+				Proxy:     String(""),
+				Auth: &VaultAuthConfig{
+					Enabled: Bool(false),
+					Method:  String(""),
+					Mount:   String(""),
+					Config:  map[string]string{},
+				},
+				RateLimit: &RateLimitConfig{
+					Enabled: Bool(false),
+					QPS:     Float64(0),
+					Burst:   Int(1),
+				},
 				RenewToken: Bool(false),
 				Retry: &RetryConfig{
 					Backoff:    TimeDuration(DefaultRetryBackoff),
@@ -718,6 +849,7 @@ func TestVaultConfig_Finalize(t *testing.T) {
 					DialKeepAlive:       TimeDuration(DefaultDialKeepAlive),
 					DialTimeout:         TimeDuration(DefaultDialTimeout),
 					DisableKeepAlives:   Bool(false),
+					EnableHTTP2:         Bool(false),
 					IdleConnTimeout:     TimeDuration(DefaultIdleConnTimeout),
 					MaxIdleConns:        Int(DefaultMaxIdleConns),
 					MaxIdleConnsPerHost: Int(DefaultMaxIdleConnsPerHost),
@@ -727,6 +859,7 @@ func TestVaultConfig_Finalize(t *testing.T) {
 				UnwrapToken:                Bool(DefaultVaultUnwrapToken),
 				DefaultLeaseDuration:       TimeDuration(DefaultVaultLeaseDuration),
 				LeaseRenewalThreshold:      Float64(DefaultLeaseRenewalThreshold),
+				PKIRenewThreshold:          Float64(DefaultPKIRenewThreshold),
 				K8SAuthRoleName:            String(""),
 				K8SServiceAccountTokenPath: String(DefaultK8SServiceAccountTokenPath),
 				K8SServiceAccountToken:     String(""),
@@ -741,9 +874,21 @@ func TestVaultConfig_Finalize(t *testing.T) {
 				DefaultLeaseDuration: TimeDuration(1 * time.Minute),
 			},
 			&VaultConfig{
-				Address:    String("address"),
-				Enabled:    Bool(true),
-				Namespace:  String(""),
+				Address:   String("address"),
+				Enabled:   Bool(true),
+				Namespace: String(""),
+				Proxy:     String(""),
+				Auth: &VaultAuthConfig{
+					Enabled: Bool(false),
+					Method:  String(""),
+					Mount:   String(""),
+					Config:  map[string]string{},
+				},
+				RateLimit: &RateLimitConfig{
+					Enabled: Bool(false),
+					QPS:     Float64(0),
+					Burst:   Int(1),
+				},
 				RenewToken: Bool(false),
 				Retry: &RetryConfig{
 					Backoff:    TimeDuration(DefaultRetryBackoff),
@@ -766,6 +911,7 @@ func TestVaultConfig_Finalize(t *testing.T) {
 					DialKeepAlive:       TimeDuration(DefaultDialKeepAlive),
 					DialTimeout:         TimeDuration(DefaultDialTimeout),
 					DisableKeepAlives:   Bool(false),
+					EnableHTTP2:         Bool(false),
 					IdleConnTimeout:     TimeDuration(DefaultIdleConnTimeout),
 					MaxIdleConns:        Int(DefaultMaxIdleConns),
 					MaxIdleConnsPerHost: Int(DefaultMaxIdleConnsPerHost),
@@ -775,6 +921,7 @@ func TestVaultConfig_Finalize(t *testing.T) {
 				UnwrapToken:                Bool(DefaultVaultUnwrapToken),
 				DefaultLeaseDuration:       TimeDuration(1 * time.Minute),
 				LeaseRenewalThreshold:      Float64(DefaultLeaseRenewalThreshold),
+				PKIRenewThreshold:          Float64(DefaultPKIRenewThreshold),
 				K8SAuthRoleName:            String(""),
 				K8SServiceAccountTokenPath: String(DefaultK8SServiceAccountTokenPath),
 				K8SServiceAccountToken:     String(""),
@@ -789,9 +936,21 @@ func TestVaultConfig_Finalize(t *testing.T) {
 				LeaseRenewalThreshold: Float64(0.70),
 			},
 			&VaultConfig{
-				Address:    String("address"),
-				Enabled:    Bool(true),
-				Namespace:  String(""),
+				Address:   String("address"),
+				Enabled:   Bool(true),
+				Namespace: String(""),
+				Proxy:     String(""),
+				Auth: &VaultAuthConfig{
+					Enabled: Bool(false),
+					Method:  String(""),
+					Mount:   String(""),
+					Config:  map[string]string{},
+				},
+				RateLimit: &RateLimitConfig{
+					Enabled: Bool(false),
+					QPS:     Float64(0),
+					Burst:   Int(1),
+				},
 				RenewToken: Bool(false),
 				Retry: &RetryConfig{
 					Backoff:    TimeDuration(DefaultRetryBackoff),
@@ -814,6 +973,7 @@ func TestVaultConfig_Finalize(t *testing.T) {
 					DialKeepAlive:       TimeDuration(DefaultDialKeepAlive),
 					DialTimeout:         TimeDuration(DefaultDialTimeout),
 					DisableKeepAlives:   Bool(false),
+					EnableHTTP2:         Bool(false),
 					IdleConnTimeout:     TimeDuration(DefaultIdleConnTimeout),
 					MaxIdleConns:        Int(DefaultMaxIdleConns),
 					MaxIdleConnsPerHost: Int(DefaultMaxIdleConnsPerHost),
@@ -823,6 +983,7 @@ func TestVaultConfig_Finalize(t *testing.T) {
 				UnwrapToken:                Bool(DefaultVaultUnwrapToken),
 				DefaultLeaseDuration:       TimeDuration(DefaultVaultLeaseDuration),
 				LeaseRenewalThreshold:      Float64(0.70),
+				PKIRenewThreshold:          Float64(DefaultPKIRenewThreshold),
 				K8SAuthRoleName:            String(""),
 				K8SServiceAccountTokenPath: String(DefaultK8SServiceAccountTokenPath),
 				K8SServiceAccountToken:     String(""),
@@ -839,9 +1000,21 @@ func TestVaultConfig_Finalize(t *testing.T) {
 				K8SServiceMountPath:        String("K8SServiceMountPath"),
 			},
 			&VaultConfig{
-				Address:    String(""),
-				Enabled:    Bool(false),
-				Namespace:  String(""),
+				Address:   String(""),
+				Enabled:   Bool(false),
+				Namespace: String(""),
+				Proxy:     String(""),
+				Auth: &VaultAuthConfig{
+					Enabled: Bool(false),
+					Method:  String(""),
+					Mount:   String(""),
+					Config:  map[string]string{},
+				},
+				RateLimit: &RateLimitConfig{
+					Enabled: Bool(false),
+					QPS:     Float64(0),
+					Burst:   Int(1),
+				},
 				RenewToken: Bool(false),
 				Retry: &RetryConfig{
 					Backoff:    TimeDuration(DefaultRetryBackoff),
@@ -864,6 +1037,7 @@ func TestVaultConfig_Finalize(t *testing.T) {
 					DialKeepAlive:       TimeDuration(DefaultDialKeepAlive),
 					DialTimeout:         TimeDuration(DefaultDialTimeout),
 					DisableKeepAlives:   Bool(false),
+					EnableHTTP2:         Bool(false),
 					IdleConnTimeout:     TimeDuration(DefaultIdleConnTimeout),
 					MaxIdleConns:        Int(DefaultMaxIdleConns),
 					MaxIdleConnsPerHost: Int(DefaultMaxIdleConnsPerHost),
@@ -873,6 +1047,7 @@ func TestVaultConfig_Finalize(t *testing.T) {
 				UnwrapToken:                Bool(DefaultVaultUnwrapToken),
 				DefaultLeaseDuration:       TimeDuration(DefaultVaultLeaseDuration),
 				LeaseRenewalThreshold:      Float64(0.90),
+				PKIRenewThreshold:          Float64(DefaultPKIRenewThreshold),
 				K8SAuthRoleName:            String("K8SAuthRoleName"),
 				K8SServiceAccountTokenPath: String("K8SServiceAccountTokenPath"),
 				K8SServiceAccountToken:     String("K8SServiceAccountToken"),
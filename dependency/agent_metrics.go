@@ -0,0 +1,179 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dependency
+
+import (
+	"encoding/gob"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/pkg/errors"
+)
+
+var (
+	// Ensure implements
+	_ Dependency = (*AgentMetricsQuery)(nil)
+
+	// AgentMetricsQuerySleepTime is the amount of time to sleep between
+	// queries, since the endpoint does not support blocking queries.
+	AgentMetricsQuerySleepTime = 15 * time.Second
+)
+
+func init() {
+	gob.Register(&AgentMetrics{})
+}
+
+// AgentMetrics is a snapshot of the metrics of the local Consul agent, as
+// kept by its in-memory sink for the current interval.
+type AgentMetrics struct {
+	Timestamp string
+	Gauges    []*MetricGauge
+	Points    []*MetricPoints
+	Counters  []*MetricSample
+	Samples   []*MetricSample
+}
+
+// MetricGauge is the current value of a gauge, like the memory allocated.
+type MetricGauge struct {
+	Name   string
+	Value  float32
+	Labels map[string]string
+}
+
+// MetricPoints is the series of points of a metric.
+type MetricPoints struct {
+	Name   string
+	Points []float32
+}
+
+// MetricSample is the aggregate of a counter or a timer over the interval.
+type MetricSample struct {
+	Name   string
+	Count  int
+	Sum    float64
+	Min    float64
+	Max    float64
+	Mean   float64
+	Stddev float64
+	Labels map[string]string
+}
+
+// AgentMetricsQuery is the dependency to query the metrics of the local
+// agent.
+type AgentMetricsQuery struct {
+	stopCh chan struct{}
+}
+
+// NewAgentMetricsQuery creates a new agent metrics dependency. There is only
+// the one agent, so every instance is the same query.
+func NewAgentMetricsQuery() (*AgentMetricsQuery, error) {
+	return &AgentMetricsQuery{
+		stopCh: make(chan struct{}, 1),
+	}, nil
+}
+
+// Fetch queries the Consul API defined by the given client and returns the
+// current metrics of the local agent. Agents that do not serve metrics return
+// an empty snapshot.
+func (d *AgentMetricsQuery) Fetch(clients *ClientSet, opts *QueryOptions) (interface{}, *ResponseMetadata, error) {
+	opts = opts.Merge(&QueryOptions{})
+
+	log.Printf("[TRACE] %s: GET %s", d, &url.URL{
+		Path:     "/v1/agent/metrics",
+		RawQuery: opts.String(),
+	})
+
+	// The agent metrics endpoint does not support blocking queries, so like
+	// the datacenters query, poll it once a LastIndex is known.
+	if opts.WaitIndex != 0 {
+		log.Printf("[TRACE] %s: long polling for %s", d, AgentMetricsQuerySleepTime)
+
+		select {
+		case <-d.stopCh:
+			return nil, nil, ErrStopped
+		case <-time.After(AgentMetricsQuerySleepTime):
+		}
+	}
+
+	info, err := clients.Consul().Agent().Metrics()
+	var statusErr api.StatusError
+	if errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound {
+		log.Printf("[TRACE] %s: metrics are not available", d)
+		info, err = nil, nil
+	}
+	if err != nil {
+		return nil, nil, errors.Wrap(err, d.String())
+	}
+
+	result := &AgentMetrics{
+		Gauges:   []*MetricGauge{},
+		Points:   []*MetricPoints{},
+		Counters: []*MetricSample{},
+		Samples:  []*MetricSample{},
+	}
+	if info != nil {
+		result.Timestamp = info.Timestamp
+		for _, g := range info.Gauges {
+			result.Gauges = append(result.Gauges, &MetricGauge{
+				Name:   g.Name,
+				Value:  g.Value,
+				Labels: g.Labels,
+			})
+		}
+		for _, p := range info.Points {
+			result.Points = append(result.Points, &MetricPoints{
+				Name:   p.Name,
+				Points: p.Points,
+			})
+		}
+		result.Counters = metricSamples(info.Counters)
+		result.Samples = metricSamples(info.Samples)
+	}
+
+	log.Printf("[TRACE] %s: returned %d gauges, %d counters and %d samples",
+		d, len(result.Gauges), len(result.Counters), len(result.Samples))
+
+	return respWithMetadata(result)
+}
+
+// metricSamples converts the sampled values of the API into MetricSamples.
+func metricSamples(values []api.SampledValue) []*MetricSample {
+	samples := make([]*MetricSample, 0, len(values))
+	for _, v := range values {
+		samples = append(samples, &MetricSample{
+			Name:   v.Name,
+			Count:  v.Count,
+			Sum:    v.Sum,
+			Min:    v.Min,
+			Max:    v.Max,
+			Mean:   v.Mean,
+			Stddev: v.Stddev,
+			Labels: v.Labels,
+		})
+	}
+	return samples
+}
+
+// CanShare returns if this dependency is shareable.
+func (d *AgentMetricsQuery) CanShare() bool {
+	return true
+}
+
+// String returns the human-friendly version of this dependency.
+func (d *AgentMetricsQuery) String() string {
+	return "agent.metrics"
+}
+
+// Stop terminates this dependency's fetch.
+func (d *AgentMetricsQuery) Stop() {
+	close(d.stopCh)
+}
+
+// Type returns the type of this dependency.
+func (d *AgentMetricsQuery) Type() Type {
+	return TypeConsul
+}
@@ -0,0 +1,167 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dependency
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	AgentMetricsQuerySleepTime = 50 * time.Millisecond
+}
+
+func TestNewAgentMetricsQuery(t *testing.T) {
+	act, err := NewAgentMetricsQuery()
+	if err != nil {
+		t.Fatal(err)
+	}
+	act.stopCh = nil
+	assert.Equal(t, &AgentMetricsQuery{}, act)
+}
+
+func TestAgentMetricsQuery_Fetch(t *testing.T) {
+	// metricsClients returns clients for a Consul agent that answers the
+	// metrics endpoint with the given handler.
+	metricsClients := func(t *testing.T, handler http.HandlerFunc) *ClientSet {
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+
+		clients := NewClientSet()
+		if err := clients.CreateConsulClient(&CreateConsulClientInput{
+			Address: server.URL,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return clients
+	}
+
+	t.Run("dev_agent", func(t *testing.T) {
+		d, err := NewAgentMetricsQuery()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		act, _, err := d.Fetch(testClients, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		metrics := act.(*AgentMetrics)
+		assert.NotEmpty(t, metrics.Timestamp)
+		assert.NotEmpty(t, metrics.Gauges)
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		clients := metricsClients(t, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/agent/metrics", r.URL.Path)
+			fmt.Fprint(w, `{
+				"Timestamp": "2026-10-14 07:00:00 +0000 UTC",
+				"Gauges": [{"Name": "consul.runtime.alloc_bytes", "Value": 1024, "Labels": {}}],
+				"Points": [],
+				"Counters": [{"Name": "consul.rpc.request", "Count": 3, "Sum": 3, "Min": 1, "Max": 1, "Mean": 1, "Stddev": 0, "Labels": {"dc": "dc1"}}],
+				"Samples": [{"Name": "consul.http.GET.v1.kv", "Count": 2, "Sum": 1.5, "Min": 0.5, "Max": 1, "Mean": 0.75, "Stddev": 0.25, "Labels": {}}]
+			}`)
+		})
+
+		d, err := NewAgentMetricsQuery()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		act, _, err := d.Fetch(clients, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, &AgentMetrics{
+			Timestamp: "2026-10-14 07:00:00 +0000 UTC",
+			Gauges: []*MetricGauge{
+				{Name: "consul.runtime.alloc_bytes", Value: 1024, Labels: map[string]string{}},
+			},
+			Points: []*MetricPoints{},
+			Counters: []*MetricSample{
+				{Name: "consul.rpc.request", Count: 3, Sum: 3, Min: 1, Max: 1, Mean: 1, Labels: map[string]string{"dc": "dc1"}},
+			},
+			Samples: []*MetricSample{
+				{Name: "consul.http.GET.v1.kv", Count: 2, Sum: 1.5, Min: 0.5, Max: 1, Mean: 0.75, Stddev: 0.25, Labels: map[string]string{}},
+			},
+		}, act)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		clients := metricsClients(t, func(w http.ResponseWriter, r *http.Request) {
+			http.NotFound(w, r)
+		})
+
+		d, err := NewAgentMetricsQuery()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		act, _, err := d.Fetch(clients, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, &AgentMetrics{
+			Gauges:   []*MetricGauge{},
+			Points:   []*MetricPoints{},
+			Counters: []*MetricSample{},
+			Samples:  []*MetricSample{},
+		}, act)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		clients := metricsClients(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		})
+
+		d, err := NewAgentMetricsQuery()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := d.Fetch(clients, nil); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("stops", func(t *testing.T) {
+		d, err := NewAgentMetricsQuery()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		errCh := make(chan error, 1)
+		go func() {
+			_, _, err := d.Fetch(nil, &QueryOptions{WaitIndex: 10})
+			errCh <- err
+		}()
+
+		d.Stop()
+
+		select {
+		case err := <-errCh:
+			if err != ErrStopped {
+				t.Fatal(err)
+			}
+		case <-time.After(100 * time.Millisecond):
+			t.Errorf("did not stop")
+		}
+	})
+}
+
+func TestAgentMetricsQuery_String(t *testing.T) {
+	d, err := NewAgentMetricsQuery()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "agent.metrics", d.String())
+}
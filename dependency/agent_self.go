@@ -0,0 +1,132 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dependency
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// Ensure implements
+	_ Dependency = (*AgentSelfQuery)(nil)
+
+	// AgentSelfQuerySleepTime is the amount of time to sleep between queries,
+	// since the endpoint does not support blocking queries.
+	AgentSelfQuerySleepTime = 15 * time.Second
+)
+
+func init() {
+	gob.Register(&AgentSelf{})
+}
+
+// AgentSelf is the network placement of the local Consul agent.
+type AgentSelf struct {
+	// Segment is the network segment the agent belongs to. It is empty for the
+	// default segment, which is the only one on Consul CE clusters.
+	Segment string
+
+	// Coordinate is the LAN network coordinate of the agent.
+	Coordinate *NodeCoordinate
+}
+
+// NodeCoordinate is a network coordinate in Vivaldi space, as described in
+// https://developer.hashicorp.com/consul/docs/architecture/coordinates.
+type NodeCoordinate struct {
+	Vec        []float64
+	Error      float64
+	Adjustment float64
+	Height     float64
+}
+
+// AgentSelfQuery is the dependency to query the local agent's own
+// configuration and member information.
+type AgentSelfQuery struct {
+	stopCh chan struct{}
+}
+
+// NewAgentSelfQuery creates a new agent self dependency. There is only the one
+// agent, so every instance is the same query.
+func NewAgentSelfQuery() (*AgentSelfQuery, error) {
+	return &AgentSelfQuery{
+		stopCh: make(chan struct{}, 1),
+	}, nil
+}
+
+// Fetch queries the Consul API defined by the given client and returns the
+// segment and coordinate of the local agent.
+func (d *AgentSelfQuery) Fetch(clients *ClientSet, opts *QueryOptions) (interface{}, *ResponseMetadata, error) {
+	opts = opts.Merge(&QueryOptions{})
+
+	log.Printf("[TRACE] %s: GET %s", d, &url.URL{
+		Path:     "/v1/agent/self",
+		RawQuery: opts.String(),
+	})
+
+	// The agent self endpoint does not support blocking queries, so like the
+	// datacenters query, poll it once a LastIndex is known.
+	if opts.WaitIndex != 0 {
+		log.Printf("[TRACE] %s: long polling for %s", d, AgentSelfQuerySleepTime)
+
+		select {
+		case <-d.stopCh:
+			return nil, nil, ErrStopped
+		case <-time.After(AgentSelfQuerySleepTime):
+		}
+	}
+
+	self, err := clients.Consul().Agent().Self()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, d.String())
+	}
+
+	result := &AgentSelf{
+		Coordinate: &NodeCoordinate{},
+	}
+
+	// Segments are an Enterprise feature, advertised in the agent's LAN serf
+	// tags. The tag is absent on Consul CE.
+	if tags, ok := self["Member"]["Tags"].(map[string]interface{}); ok {
+		result.Segment, _ = tags["segment"].(string)
+	}
+
+	if coord, ok := self["Coord"]; ok && coord != nil {
+		b, err := json.Marshal(coord)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, d.String())
+		}
+		if err := json.Unmarshal(b, result.Coordinate); err != nil {
+			return nil, nil, errors.Wrap(err, d.String())
+		}
+	}
+
+	log.Printf("[TRACE] %s: returned segment %q", d, result.Segment)
+
+	return respWithMetadata(result)
+}
+
+// CanShare returns if this dependency is shareable.
+func (d *AgentSelfQuery) CanShare() bool {
+	return true
+}
+
+// String returns the human-friendly version of this dependency.
+func (d *AgentSelfQuery) String() string {
+	return "agent.self"
+}
+
+// Stop terminates this dependency's fetch.
+func (d *AgentSelfQuery) Stop() {
+	close(d.stopCh)
+}
+
+// Type returns the type of this dependency.
+func (d *AgentSelfQuery) Type() Type {
+	return TypeConsul
+}
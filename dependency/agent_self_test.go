@@ -0,0 +1,117 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dependency
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	AgentSelfQuerySleepTime = 50 * time.Millisecond
+}
+
+func TestNewAgentSelfQuery(t *testing.T) {
+	act, err := NewAgentSelfQuery()
+	if err != nil {
+		t.Fatal(err)
+	}
+	act.stopCh = nil
+	assert.Equal(t, &AgentSelfQuery{}, act)
+}
+
+func TestAgentSelfQuery_Fetch(t *testing.T) {
+	t.Run("dev_agent", func(t *testing.T) {
+		d, err := NewAgentSelfQuery()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		act, _, err := d.Fetch(testClients, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The test agent is Consul CE, which has no segments.
+		self := act.(*AgentSelf)
+		assert.Equal(t, "", self.Segment)
+		assert.NotEmpty(t, self.Coordinate.Vec)
+	})
+
+	t.Run("segment", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/agent/self", r.URL.Path)
+			fmt.Fprint(w, `{
+				"Config": {"NodeName": "node1"},
+				"Coord": {"Vec": [0.1, -0.2], "Error": 0.5, "Adjustment": 0.01, "Height": 0.0001},
+				"Member": {"Name": "node1", "Tags": {"role": "node", "segment": "alpha"}}
+			}`)
+		}))
+		defer server.Close()
+
+		clients := NewClientSet()
+		if err := clients.CreateConsulClient(&CreateConsulClientInput{
+			Address: server.URL,
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		d, err := NewAgentSelfQuery()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		act, _, err := d.Fetch(clients, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, &AgentSelf{
+			Segment: "alpha",
+			Coordinate: &NodeCoordinate{
+				Vec:        []float64{0.1, -0.2},
+				Error:      0.5,
+				Adjustment: 0.01,
+				Height:     0.0001,
+			},
+		}, act)
+	})
+
+	t.Run("stops", func(t *testing.T) {
+		d, err := NewAgentSelfQuery()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		errCh := make(chan error, 1)
+		go func() {
+			_, _, err := d.Fetch(nil, &QueryOptions{WaitIndex: 10})
+			errCh <- err
+		}()
+
+		d.Stop()
+
+		select {
+		case err := <-errCh:
+			if err != ErrStopped {
+				t.Fatal(err)
+			}
+		case <-time.After(100 * time.Millisecond):
+			t.Errorf("did not stop")
+		}
+	})
+}
+
+func TestAgentSelfQuery_String(t *testing.T) {
+	d, err := NewAgentSelfQuery()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "agent.self", d.String())
+}
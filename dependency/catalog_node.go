@@ -9,11 +9,20 @@ import (
 	"log"
 	"net/url"
 	"regexp"
+	"slices"
 	"sort"
+	"strings"
 
 	"github.com/pkg/errors"
 )
 
+const (
+	// QueryTag and QueryMeta are the catalog.node query params used to filter
+	// the services on the node by tag or by meta "key:value" pair.
+	QueryTag  = "tag"
+	QueryMeta = "meta"
+)
+
 var (
 	// Ensure implements
 	_ Dependency = (*CatalogNodeQuery)(nil)
@@ -35,6 +44,13 @@ type CatalogNodeQuery struct {
 	name      string
 	namespace string
 	partition string
+
+	// tags and meta filter the services returned for the node. A service must
+	// have every tag and every meta pair to be included.
+	tags []string
+	meta map[string]string
+
+	readMode string
 }
 
 // CatalogNode is a wrapper around the node and its services.
@@ -62,17 +78,43 @@ func NewCatalogNodeQuery(s string) (*CatalogNodeQuery, error) {
 	}
 
 	m := regexpMatch(CatalogNodeQueryRe, s)
-	queryParams, err := GetConsulQueryOpts(m, "catalog.node")
+	queryParams, err := GetConsulQueryOpts(m, "catalog.node", QueryTag, QueryMeta, QueryStale, QueryConsistent)
+	if err != nil {
+		return nil, err
+	}
+
+	readMode, err := GetConsulReadMode(queryParams, "catalog.node")
 	if err != nil {
 		return nil, err
 	}
 
+	var tags []string
+	if t := queryParams[QueryTag]; len(t) > 0 {
+		tags = deepCopyAndSortTags(t)
+	}
+
+	var meta map[string]string
+	for _, pair := range queryParams[QueryMeta] {
+		k, v, ok := strings.Cut(pair, ":")
+		if !ok || k == "" {
+			return nil, fmt.Errorf(
+				"catalog.node: invalid meta filter %q in %q: expected key:value", pair, s)
+		}
+		if meta == nil {
+			meta = make(map[string]string)
+		}
+		meta[k] = v
+	}
+
 	return &CatalogNodeQuery{
 		dc:        m["dc"],
 		name:      m["name"],
 		stopCh:    make(chan struct{}, 1),
 		namespace: queryParams.Get(QueryNamespace),
 		partition: queryParams.Get(QueryPartition),
+		tags:      tags,
+		meta:      meta,
+		readMode:  readMode,
 	}, nil
 }
 
@@ -89,7 +131,7 @@ func (d *CatalogNodeQuery) Fetch(clients *ClientSet, opts *QueryOptions) (interf
 		Datacenter:      d.dc,
 		ConsulPartition: d.partition,
 		ConsulNamespace: d.namespace,
-	})
+	}).setReadMode(d.readMode)
 
 	// Grab the name
 	name := d.name
@@ -127,6 +169,9 @@ func (d *CatalogNodeQuery) Fetch(clients *ClientSet, opts *QueryOptions) (interf
 
 	services := make([]*CatalogNodeService, 0, len(node.Services))
 	for _, v := range node.Services {
+		if !d.acceptService(v.Tags, v.Meta) {
+			continue
+		}
 		services = append(services, &CatalogNodeService{
 			ID:                v.ID,
 			Service:           v.Service,
@@ -139,6 +184,10 @@ func (d *CatalogNodeQuery) Fetch(clients *ClientSet, opts *QueryOptions) (interf
 	}
 	sort.Stable(ByService(services))
 
+	if len(d.tags) > 0 || len(d.meta) > 0 {
+		log.Printf("[TRACE] %s: returned %d services after filtering", d, len(services))
+	}
+
 	detail := &CatalogNode{
 		Node: &Node{
 			ID:              node.Node.ID,
@@ -162,6 +211,9 @@ func (d *CatalogNodeQuery) CanShare() bool {
 // String returns the human-friendly version of this dependency.
 func (d *CatalogNodeQuery) String() string {
 	name := d.name
+	if filters := d.filterString(); filters != "" {
+		name = name + "?" + filters
+	}
 	if d.dc != "" {
 		name = name + "@" + d.dc
 	}
@@ -172,6 +224,43 @@ func (d *CatalogNodeQuery) String() string {
 	return fmt.Sprintf("catalog.node(%s)", name)
 }
 
+// filterString returns the tag and meta filters and the read mode in a stable
+// query string form.
+func (d *CatalogNodeQuery) filterString() string {
+	parts := make([]string, 0, len(d.tags)+len(d.meta))
+	for _, t := range d.tags {
+		parts = append(parts, QueryTag+"="+t)
+	}
+	keys := make([]string, 0, len(d.meta))
+	for k := range d.meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts = append(parts, QueryMeta+"="+k+":"+d.meta[k])
+	}
+	if d.readMode != "" {
+		parts = append(parts, d.readMode)
+	}
+	return strings.Join(parts, "&")
+}
+
+// acceptService reports whether a service with the given tags and meta passes
+// the tag and meta filters of this query.
+func (d *CatalogNodeQuery) acceptService(tags []string, meta map[string]string) bool {
+	for _, t := range d.tags {
+		if !slices.Contains(tags, t) {
+			return false
+		}
+	}
+	for k, v := range d.meta {
+		if mv, ok := meta[k]; !ok || mv != v {
+			return false
+		}
+	}
+	return true
+}
+
 // Stop halts the dependency's fetch function.
 func (d *CatalogNodeQuery) Stop() {
 	close(d.stopCh)
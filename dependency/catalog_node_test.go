@@ -75,6 +75,47 @@ func TestNewCatalogNodeQuery(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"tag",
+			"node?tag=web@dc1",
+			&CatalogNodeQuery{
+				name: "node",
+				dc:   "dc1",
+				tags: []string{"web"},
+			},
+			false,
+		},
+		{
+			"multiple_tags_sorted",
+			"node?tag=web&tag=api",
+			&CatalogNodeQuery{
+				name: "node",
+				tags: []string{"api", "web"},
+			},
+			false,
+		},
+		{
+			"meta",
+			"node?meta=env:prod&ns=foo",
+			&CatalogNodeQuery{
+				name:      "node",
+				namespace: "foo",
+				meta:      map[string]string{"env": "prod"},
+			},
+			false,
+		},
+		{
+			"meta_missing_value_separator",
+			"node?meta=env",
+			nil,
+			true,
+		},
+		{
+			"meta_empty_key",
+			"node?meta=:prod",
+			nil,
+			true,
+		},
 		{
 			"periods",
 			"node.bar.com@dc1",
@@ -193,6 +234,62 @@ func TestCatalogNodeQuery_Fetch(t *testing.T) {
 	}
 }
 
+func TestCatalogNodeQuery_FetchFiltered(t *testing.T) {
+	node := testConsul.Config.NodeName
+
+	cases := []struct {
+		name string
+		i    string
+		exp  []string
+	}{
+		{
+			"tag",
+			node + "?tag=tag1",
+			[]string{"service-meta"},
+		},
+		{
+			"meta",
+			node + "?meta=meta1:value1",
+			[]string{"service-meta"},
+		},
+		{
+			"tag_and_meta",
+			node + "?tag=tag1&meta=meta1:value1",
+			[]string{"service-meta"},
+		},
+		{
+			"meta_wrong_value",
+			node + "?meta=meta1:nope",
+			[]string{},
+		},
+		{
+			"unknown_tag",
+			node + "?tag=nope",
+			[]string{},
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			d, err := NewCatalogNodeQuery(tc.i)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			act, _, err := d.Fetch(testClients, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ids := []string{}
+			for _, s := range act.(*CatalogNode).Services {
+				ids = append(ids, s.ID)
+			}
+			assert.Equal(t, tc.exp, ids)
+		})
+	}
+}
+
 func TestCatalogNodeQuery_String(t *testing.T) {
 	cases := []struct {
 		name string
@@ -214,6 +311,16 @@ func TestCatalogNodeQuery_String(t *testing.T) {
 			"node1@dc1",
 			"catalog.node(node1@dc1)",
 		},
+		{
+			"filters",
+			"node1?meta=b:2&tag=web&meta=a:1&tag=api@dc1",
+			"catalog.node(node1?tag=api&tag=web&meta=a:1&meta=b:2@dc1)",
+		},
+		{
+			"filters_read_mode",
+			"node1?consistent&tag=web",
+			"catalog.node(node1?tag=web&consistent)",
+		},
 	}
 
 	for i, tc := range cases {
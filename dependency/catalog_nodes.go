@@ -44,6 +44,7 @@ type CatalogNodesQuery struct {
 	near      string
 	namespace string
 	partition string
+	readMode  string
 }
 
 // NewCatalogNodesQuery parses the given string into a dependency. If the name is
@@ -54,7 +55,12 @@ func NewCatalogNodesQuery(s string) (*CatalogNodesQuery, error) {
 	}
 
 	m := regexpMatch(CatalogNodesQueryRe, s)
-	queryParams, err := GetConsulQueryOpts(m, "catalog.nodes")
+	queryParams, err := GetConsulQueryOpts(m, "catalog.nodes", QueryStale, QueryConsistent)
+	if err != nil {
+		return nil, err
+	}
+
+	readMode, err := GetConsulReadMode(queryParams, "catalog.nodes")
 	if err != nil {
 		return nil, err
 	}
@@ -65,6 +71,7 @@ func NewCatalogNodesQuery(s string) (*CatalogNodesQuery, error) {
 		stopCh:    make(chan struct{}, 1),
 		namespace: queryParams.Get(QueryNamespace),
 		partition: queryParams.Get(QueryPartition),
+		readMode:  readMode,
 	}, nil
 }
 
@@ -82,7 +89,7 @@ func (d *CatalogNodesQuery) Fetch(clients *ClientSet, opts *QueryOptions) (inter
 		Near:            d.near,
 		ConsulPartition: d.partition,
 		ConsulNamespace: d.namespace,
-	})
+	}).setReadMode(d.readMode)
 
 	log.Printf("[TRACE] %s: GET %s", d, &url.URL{
 		Path:     "/v1/catalog/nodes",
@@ -128,6 +135,9 @@ func (d *CatalogNodesQuery) CanShare() bool {
 // String returns the human-friendly version of this dependency.
 func (d *CatalogNodesQuery) String() string {
 	name := ""
+	if d.readMode != "" {
+		name = "?" + d.readMode
+	}
 	if d.dc != "" {
 		name = name + "@" + d.dc
 	}
@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"regexp"
 
+	"github.com/hashicorp/consul/api"
 	"github.com/pkg/errors"
 )
 
@@ -39,6 +40,18 @@ type CatalogService struct {
 	ServiceTags     ServiceTags
 	ServiceMeta     map[string]string
 	ServicePort     int
+
+	// ServiceKind is the kind of the service, e.g. "connect-proxy" or
+	// "mesh-gateway". It is empty for typical services.
+	ServiceKind string
+}
+
+// catalogServiceEntry is a catalog service as returned by the Consul API. The
+// API client's CatalogService does not decode the ServiceKind field, so it is
+// added here.
+type catalogServiceEntry struct {
+	api.CatalogService
+	ServiceKind api.ServiceKind
 }
 
 // CatalogServiceQuery is the representation of a requested catalog services
@@ -52,6 +65,8 @@ type CatalogServiceQuery struct {
 	tag       string
 	namespace string
 	partition string
+	readMode  string
+	cache     *ConsulCache
 }
 
 // NewCatalogServiceQuery parses a string into a CatalogServiceQuery.
@@ -61,7 +76,18 @@ func NewCatalogServiceQuery(s string) (*CatalogServiceQuery, error) {
 	}
 
 	m := regexpMatch(CatalogServiceQueryRe, s)
-	queryParams, err := GetConsulQueryOpts(m, "catalog.service")
+	queryParams, err := GetConsulQueryOpts(m, "catalog.service", QueryStale, QueryConsistent,
+		QueryCached, QueryMaxAge, QueryStaleIfError)
+	if err != nil {
+		return nil, err
+	}
+
+	readMode, err := GetConsulReadMode(queryParams, "catalog.service")
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := GetConsulCache(queryParams, "catalog.service")
 	if err != nil {
 		return nil, err
 	}
@@ -74,6 +100,8 @@ func NewCatalogServiceQuery(s string) (*CatalogServiceQuery, error) {
 		tag:       m["tag"],
 		namespace: queryParams.Get(QueryNamespace),
 		partition: queryParams.Get(QueryPartition),
+		readMode:  readMode,
+		cache:     cache,
 	}, nil
 }
 
@@ -91,7 +119,7 @@ func (d *CatalogServiceQuery) Fetch(clients *ClientSet, opts *QueryOptions) (int
 		Near:            d.near,
 		ConsulPartition: d.partition,
 		ConsulNamespace: d.namespace,
-	})
+	}).setReadMode(d.readMode).setCache(d.cache)
 
 	u := &url.URL{
 		Path:     "/v1/catalog/service/" + d.name,
@@ -104,7 +132,14 @@ func (d *CatalogServiceQuery) Fetch(clients *ClientSet, opts *QueryOptions) (int
 	}
 	log.Printf("[TRACE] %s: GET %s", d, u)
 
-	entries, qm, err := clients.Consul().Catalog().Service(d.name, d.tag, opts.ToConsulOpts())
+	// The catalog is queried through the raw API so the ServiceKind is decoded.
+	// The raw API takes no tag parameter, so the tag is matched with a filter.
+	consulOpts := opts.ToConsulOpts()
+	if d.tag != "" {
+		consulOpts.Filter = fmt.Sprintf("%q in ServiceTags", d.tag)
+	}
+	var entries []*catalogServiceEntry
+	qm, err := clients.Consul().Raw().Query("/v1/catalog/service/"+d.name, &entries, consulOpts)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, d.String())
 	}
@@ -126,6 +161,7 @@ func (d *CatalogServiceQuery) Fetch(clients *ClientSet, opts *QueryOptions) (int
 			ServiceTags:     ServiceTags(deepCopyAndSortTags(s.ServiceTags)),
 			ServiceMeta:     s.ServiceMeta,
 			ServicePort:     s.ServicePort,
+			ServiceKind:     string(s.ServiceKind),
 		})
 	}
 
@@ -148,6 +184,9 @@ func (d *CatalogServiceQuery) String() string {
 	if d.tag != "" {
 		name = d.tag + "." + name
 	}
+	if q := joinQueryParams(d.readMode, d.cache.String()); q != "" {
+		name = name + "?" + q
+	}
 	if d.dc != "" {
 		name = name + "@" + d.dc
 	}
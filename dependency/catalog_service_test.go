@@ -5,8 +5,11 @@ package dependency
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/hashicorp/consul/api"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -108,6 +111,31 @@ func TestNewCatalogServiceQuery(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"name_near_agent",
+			"name~_agent",
+			&CatalogServiceQuery{
+				name: "name",
+				near: "_agent",
+			},
+			false,
+		},
+		{
+			"name_near_ip",
+			"name~_ip",
+			&CatalogServiceQuery{
+				name: "name",
+				near: "_ip",
+			},
+			false,
+		},
+		{
+			// Consul only sorts by the IP the request came from.
+			"name_near_ip_address",
+			"name~_ip=1.2.3.4",
+			nil,
+			true,
+		},
 		{
 			"tag_name",
 			"tag.name",
@@ -265,6 +293,106 @@ func TestCatalogServiceQuery_Fetch(t *testing.T) {
 	}
 }
 
+func TestCatalogServiceQuery_FetchKind(t *testing.T) {
+	reg := &api.CatalogRegistration{
+		Node:    testConsul.Config.NodeName,
+		Address: testConsul.Config.Bind,
+		Service: &api.AgentService{
+			ID:      "kind-proxy",
+			Service: "kind-proxy",
+			Kind:    api.ServiceKindConnectProxy,
+			Port:    21000,
+			Proxy: &api.AgentServiceConnectProxyConfig{
+				DestinationServiceName: "service-meta",
+			},
+		},
+		SkipNodeUpdate: true,
+	}
+	if _, err := testClients.Consul().Catalog().Register(reg, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer testClients.Consul().Catalog().Deregister(&api.CatalogDeregistration{
+		Node:      testConsul.Config.NodeName,
+		ServiceID: "kind-proxy",
+	}, nil)
+
+	cases := []struct {
+		name string
+		i    string
+		exp  string
+	}{
+		{
+			"connect_proxy",
+			"kind-proxy",
+			string(api.ServiceKindConnectProxy),
+		},
+		{
+			"typical",
+			"service-meta",
+			"",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			d, err := NewCatalogServiceQuery(tc.i)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			act, _, err := d.Fetch(testClients, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			services := act.([]*CatalogService)
+			if len(services) != 1 {
+				t.Fatalf("expected 1 service, got %d", len(services))
+			}
+			assert.Equal(t, tc.exp, services[0].ServiceKind)
+		})
+	}
+}
+
+// newConsulNearServer starts a fake Consul agent that answers every request
+// with an empty list and records the near parameter of the last one.
+func newConsulNearServer(t *testing.T) (*ClientSet, *string) {
+	t.Helper()
+
+	var near string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		near = r.URL.Query().Get("near")
+		w.Header().Set("X-Consul-Index", "1")
+		fmt.Fprint(w, "[]")
+	}))
+	t.Cleanup(server.Close)
+
+	clients := NewClientSet()
+	if err := clients.CreateConsulClient(&CreateConsulClientInput{
+		Address: server.URL,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return clients, &near
+}
+
+func TestCatalogServiceQuery_Fetch_near(t *testing.T) {
+	clients, near := newConsulNearServer(t)
+
+	for _, exp := range []string{"_agent", "_ip", "node1"} {
+		t.Run(exp, func(t *testing.T) {
+			d, err := NewCatalogServiceQuery("consul~" + exp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := d.Fetch(clients, nil); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, exp, *near)
+		})
+	}
+}
+
 func TestCatalogServiceQuery_String(t *testing.T) {
 	cases := []struct {
 		name string
@@ -291,6 +419,16 @@ func TestCatalogServiceQuery_String(t *testing.T) {
 			"name@dc~near",
 			"catalog.service(name@dc~near)",
 		},
+		{
+			"name_near_agent",
+			"name~_agent",
+			"catalog.service(name~_agent)",
+		},
+		{
+			"name_near_ip",
+			"name~_ip",
+			"catalog.service(name~_ip)",
+		},
 		{
 			"tag_name",
 			"tag.name",
@@ -311,6 +449,11 @@ func TestCatalogServiceQuery_String(t *testing.T) {
 			"tag.name@dc~near",
 			"catalog.service(tag.name@dc~near)",
 		},
+		{
+			"name_cached_dc",
+			"name?cached@dc",
+			"catalog.service(name?cached@dc)",
+		},
 	}
 
 	for i, tc := range cases {
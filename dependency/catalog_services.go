@@ -19,9 +19,15 @@ var (
 	_ Dependency = (*CatalogServicesQuery)(nil)
 
 	// CatalogServicesQueryRe is the regular expression to use for CatalogNodesQuery.
-	CatalogServicesQueryRe = regexp.MustCompile(`\A` + queryRe + dcRe + `\z`)
+	// The query takes any characters but "@", so it can hold a filter
+	// expression.
+	CatalogServicesQueryRe = regexp.MustCompile(`\A(\?(?P<query>[^@]+))?` + dcRe + `\z`)
 )
 
+// QueryFilter is the catalog.services query param holding a Consul filter
+// expression.
+const QueryFilter = "filter"
+
 func init() {
 	gob.Register([]*CatalogSnippet{})
 }
@@ -32,6 +38,13 @@ type CatalogSnippet struct {
 	Tags ServiceTags
 }
 
+// CatalogServicesDelta is the set of service names added to and removed from
+// the catalog between two successive results of a catalog services query.
+type CatalogServicesDelta struct {
+	Added   []string
+	Removed []string
+}
+
 // CatalogServicesQuery is the representation of a requested catalog service
 // dependency from inside a template.
 type CatalogServicesQuery struct {
@@ -40,6 +53,9 @@ type CatalogServicesQuery struct {
 	dc        string
 	namespace string
 	partition string
+	readMode  string
+	cache     *ConsulCache
+	filter    string
 }
 
 // NewCatalogServicesQuery parses a string of the format @dc.
@@ -49,7 +65,22 @@ func NewCatalogServicesQuery(s string) (*CatalogServicesQuery, error) {
 	}
 
 	m := regexpMatch(CatalogServicesQueryRe, s)
-	queryParams, err := GetConsulQueryOpts(m, "catalog.services")
+	queryParams, err := GetConsulQueryOpts(m, "catalog.services", QueryStale, QueryConsistent,
+		QueryCached, QueryMaxAge, QueryStaleIfError, QueryFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := queryParams[QueryFilter]; ok && queryParams.Get(QueryFilter) == "" {
+		return nil, fmt.Errorf("catalog.services: filter cannot be empty in %q", s)
+	}
+
+	readMode, err := GetConsulReadMode(queryParams, "catalog.services")
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := GetConsulCache(queryParams, "catalog.services")
 	if err != nil {
 		return nil, err
 	}
@@ -59,6 +90,9 @@ func NewCatalogServicesQuery(s string) (*CatalogServicesQuery, error) {
 		dc:        m["dc"],
 		namespace: queryParams.Get(QueryNamespace),
 		partition: queryParams.Get(QueryPartition),
+		readMode:  readMode,
+		cache:     cache,
+		filter:    queryParams.Get(QueryFilter),
 	}, nil
 }
 
@@ -81,14 +115,22 @@ func (d *CatalogServicesQuery) Fetch(clients *ClientSet, opts *QueryOptions) (in
 		ConsulNamespace: d.namespace,
 	}
 
-	opts = defaultOpts.Merge(opts)
+	opts = defaultOpts.Merge(opts).setReadMode(d.readMode).setCache(d.cache)
 
-	log.Printf("[TRACE] %s: GET %s", d, &url.URL{
+	u := &url.URL{
 		Path:     "/v1/catalog/services",
 		RawQuery: opts.String(),
-	})
+	}
+	consulOpts := opts.ToConsulOpts()
+	if d.filter != "" {
+		q := u.Query()
+		q.Set(QueryFilter, d.filter)
+		u.RawQuery = q.Encode()
+		consulOpts.Filter = d.filter
+	}
+	log.Printf("[TRACE] %s: GET %s", d, u)
 
-	entries, qm, err := clients.Consul().Catalog().Services(opts.ToConsulOpts())
+	entries, qm, err := clients.Consul().Catalog().Services(consulOpts)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, d.String())
 	}
@@ -120,10 +162,22 @@ func (d *CatalogServicesQuery) CanShare() bool {
 
 // String returns the human-friendly version of this dependency.
 func (d *CatalogServicesQuery) String() string {
+	name := ""
+	var filter string
+	if d.filter != "" {
+		filter = QueryFilter + "=" + url.QueryEscape(d.filter)
+	}
+	if q := joinQueryParams(d.readMode, d.cache.String(), filter); q != "" {
+		name = "?" + q
+	}
 	if d.dc != "" {
-		return fmt.Sprintf("catalog.services(@%s)", d.dc)
+		name = name + "@" + d.dc
+	}
+
+	if name == "" {
+		return "catalog.services"
 	}
-	return "catalog.services"
+	return fmt.Sprintf("catalog.services(%s)", name)
 }
 
 // Stop halts the dependency's fetch function.
@@ -5,6 +5,8 @@ package dependency
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -78,6 +80,44 @@ func TestNewCatalogServicesQuery(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"filter",
+			`?filter="prod" in ServiceTags`,
+			&CatalogServicesQuery{
+				filter: `"prod" in ServiceTags`,
+			},
+			false,
+		},
+		{
+			"filter_and_dc",
+			"?filter=ServiceMeta.env == prod@dc1",
+			&CatalogServicesQuery{
+				dc:     "dc1",
+				filter: "ServiceMeta.env == prod",
+			},
+			false,
+		},
+		{
+			"filter_escaped_and_namespace",
+			"?ns=foo&filter=ServiceMeta.team%20%3D%3D%20a%26b",
+			&CatalogServicesQuery{
+				namespace: "foo",
+				filter:    "ServiceMeta.team == a&b",
+			},
+			false,
+		},
+		{
+			"filter_empty",
+			"?filter=",
+			nil,
+			true,
+		},
+		{
+			"filter_no_value",
+			"?filter@dc1",
+			nil,
+			true,
+		},
 	}
 
 	for i, tc := range cases {
@@ -152,6 +192,34 @@ func TestCatalogServicesQuery_Fetch(t *testing.T) {
 	}
 }
 
+func TestCatalogServicesQuery_Fetch_filter(t *testing.T) {
+	var filter string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filter = r.URL.Query().Get("filter")
+		w.Header().Set("X-Consul-Index", "1")
+		fmt.Fprint(w, `{"web": ["prod"]}`)
+	}))
+	defer server.Close()
+
+	clients := NewClientSet()
+	if err := clients.CreateConsulClient(&CreateConsulClientInput{
+		Address: server.URL,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := NewCatalogServicesQuery(`?filter="prod" in ServiceTags@dc1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	act, _, err := d.Fetch(clients, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `"prod" in ServiceTags`, filter)
+	assert.Equal(t, []*CatalogSnippet{{Name: "web", Tags: ServiceTags{"prod"}}}, act)
+}
+
 func TestCatalogServicesQuery_String(t *testing.T) {
 	cases := []struct {
 		name string
@@ -168,6 +236,21 @@ func TestCatalogServicesQuery_String(t *testing.T) {
 			"@dc1",
 			"catalog.services(@dc1)",
 		},
+		{
+			"read_mode",
+			"?stale@dc1",
+			"catalog.services(?stale@dc1)",
+		},
+		{
+			"cached",
+			"?cached&max-age=10s@dc1",
+			"catalog.services(?cached&max-age=10s@dc1)",
+		},
+		{
+			"filter",
+			`?stale&filter="prod" in ServiceTags@dc1`,
+			"catalog.services(?stale&filter=%22prod%22+in+ServiceTags@dc1)",
+		},
 	}
 
 	for i, tc := range cases {
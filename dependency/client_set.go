@@ -10,7 +10,9 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	consulapi "github.com/hashicorp/consul/api"
@@ -18,6 +20,7 @@ import (
 	nomadapi "github.com/hashicorp/nomad/api"
 	vaultapi "github.com/hashicorp/vault/api"
 	vaultkubernetesauth "github.com/hashicorp/vault/api/auth/kubernetes"
+	"golang.org/x/time/rate"
 )
 
 // ClientSet is a collection of clients that dependencies use to communicate
@@ -28,18 +31,30 @@ type ClientSet struct {
 	vault  *vaultClient
 	consul *consulClient
 	nomad  *nomadClient
+
+	// kvMounts caches the KV version of the Vault mounts.
+	kvMounts kvMountCache
 }
 
 // consulClient is a wrapper around a real Consul API client.
 type consulClient struct {
 	client    *consulapi.Client
 	transport *http.Transport
+
+	// tokenTransport sends the token read from the token file, if one is
+	// configured.
+	tokenTransport *consulTokenTransport
 }
 
 // vaultClient is a wrapper around a real Vault API client.
 type vaultClient struct {
 	client     *vaultapi.Client
 	httpClient *http.Client
+
+	// auth is the auth method to log in with, and authSecret the result of the
+	// login when the client was created. Both are nil when a token is given.
+	auth       *VaultAuth
+	authSecret *vaultapi.Secret
 }
 
 // nomadClient is a wrapper around a real Nomad API client.
@@ -62,6 +77,7 @@ type TransportDialer interface {
 type CreateConsulClientInput struct {
 	Address      string
 	Namespace    string
+	Partition    string
 	Token        string
 	TokenFile    string
 	AuthEnabled  bool
@@ -75,12 +91,23 @@ type CreateConsulClientInput struct {
 	SSLCAPath    string
 	ServerName   string
 
+	// Proxy is the URL of the HTTP or SOCKS5 proxy to reach Consul through. If
+	// empty, the proxy is taken from the environment.
+	Proxy string
+
+	// RateLimitQPS caps the requests per second to Consul, allowing bursts of
+	// RateLimitBurst requests. Zero disables the limit.
+	RateLimitQPS   float64
+	RateLimitBurst int
+
 	TransportDialKeepAlive       time.Duration
 	TransportDialTimeout         time.Duration
 	TransportDisableKeepAlives   bool
+	TransportEnableHTTP2         bool
 	TransportIdleConnTimeout     time.Duration
 	TransportMaxIdleConns        int
 	TransportMaxIdleConnsPerHost int
+	TransportMaxConnsPerHost     int
 	TransportTLSHandshakeTimeout time.Duration
 }
 
@@ -100,15 +127,29 @@ type CreateVaultClientInput struct {
 	ServerName      string
 	ClientUserAgent string
 
+	// Proxy is the URL of the HTTP or SOCKS5 proxy to reach Vault through. If
+	// empty, the proxy is taken from the environment.
+	Proxy string
+
+	// RateLimitQPS caps the requests per second to Vault, allowing bursts of
+	// RateLimitBurst requests. Zero disables the limit.
+	RateLimitQPS   float64
+	RateLimitBurst int
+
 	K8SAuthRoleName            string
 	K8SServiceAccountTokenPath string
 	K8SServiceAccountToken     string
 	K8SServiceMountPath        string
 
+	// Auth, when set and Token is empty, logs in to Vault with this auth
+	// method to obtain the token.
+	Auth *VaultAuth
+
 	TransportCustomDialer        TransportDialer
 	TransportDialKeepAlive       time.Duration
 	TransportDialTimeout         time.Duration
 	TransportDisableKeepAlives   bool
+	TransportEnableHTTP2         bool
 	TransportIdleConnTimeout     time.Duration
 	TransportMaxIdleConns        int
 	TransportMaxIdleConnsPerHost int
@@ -135,6 +176,7 @@ type CreateNomadClientInput struct {
 	TransportDialKeepAlive       time.Duration
 	TransportDialTimeout         time.Duration
 	TransportDisableKeepAlives   bool
+	TransportEnableHTTP2         bool
 	TransportIdleConnTimeout     time.Duration
 	TransportMaxIdleConns        int
 	TransportMaxIdleConnsPerHost int
@@ -146,6 +188,74 @@ func NewClientSet() *ClientSet {
 	return &ClientSet{}
 }
 
+// transportProxy returns the transport proxy function for the given proxy URL.
+// Both HTTP(S) and SOCKS5 proxies are supported. An empty URL uses the proxy
+// from the environment.
+func transportProxy(proxy string) (func(*http.Request) (*url.URL, error), error) {
+	if proxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %s", proxy, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy %q: unsupported scheme %q", proxy, u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q: missing host", proxy)
+	}
+	return http.ProxyURL(u), nil
+}
+
+// newRateLimiter returns a token bucket of the given rate and burst, or nil if
+// the rate is not positive.
+func newRateLimiter(qps float64, burst int) *rate.Limiter {
+	if qps <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return rate.NewLimiter(rate.Limit(qps), burst)
+}
+
+// rateLimitTransport is an http.RoundTripper that waits for the limiter before
+// sending each request, so requests over the limit are delayed rather than
+// failed.
+type rateLimitTransport struct {
+	limiter *rate.Limiter
+	next    http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
+
+// consulTokenTransport is an http.RoundTripper that sends the current token
+// with each request. The API client only reads its token file once, so this
+// lets a rotated token be used without creating a new client.
+type consulTokenTransport struct {
+	token atomic.Value // string
+	next  http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *consulTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if token, _ := t.token.Load().(string); token != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("X-Consul-Token", token)
+	}
+	return t.next.RoundTrip(req)
+}
+
 // CreateConsulClient creates a new Consul API client from the given input.
 func (c *ClientSet) CreateConsulClient(i *CreateConsulClientInput) error {
 	consulConfig := consulapi.DefaultConfig()
@@ -158,6 +268,10 @@ func (c *ClientSet) CreateConsulClient(i *CreateConsulClientInput) error {
 		consulConfig.Namespace = i.Namespace
 	}
 
+	if i.Partition != "" {
+		consulConfig.Partition = i.Partition
+	}
+
 	if i.Token != "" {
 		consulConfig.Token = i.Token
 	}
@@ -173,17 +287,24 @@ func (c *ClientSet) CreateConsulClient(i *CreateConsulClientInput) error {
 		}
 	}
 
+	proxy, err := transportProxy(i.Proxy)
+	if err != nil {
+		return fmt.Errorf("client set: consul: %s", err)
+	}
+
 	// This transport will attempt to keep connections open to the Consul server.
 	transport := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
+		Proxy: proxy,
 		Dial: (&net.Dialer{
 			Timeout:   i.TransportDialTimeout,
 			KeepAlive: i.TransportDialKeepAlive,
 		}).Dial,
 		DisableKeepAlives:   i.TransportDisableKeepAlives,
+		ForceAttemptHTTP2:   i.TransportEnableHTTP2,
 		MaxIdleConns:        i.TransportMaxIdleConns,
 		IdleConnTimeout:     i.TransportIdleConnTimeout,
 		MaxIdleConnsPerHost: i.TransportMaxIdleConnsPerHost,
+		MaxConnsPerHost:     i.TransportMaxConnsPerHost,
 		TLSHandshakeTimeout: i.TransportTLSHandshakeTimeout,
 	}
 
@@ -245,11 +366,29 @@ func (c *ClientSet) CreateConsulClient(i *CreateConsulClientInput) error {
 		return fmt.Errorf("client set: consul: %s", err)
 	}
 
+	// The API client only accepts an *http.Transport, so the rate limit wraps
+	// the HTTP client it built around it, which it shares with the config.
+	if limiter := newRateLimiter(i.RateLimitQPS, i.RateLimitBurst); limiter != nil {
+		consulConfig.HttpClient.Transport = &rateLimitTransport{
+			limiter: limiter,
+			next:    consulConfig.HttpClient.Transport,
+		}
+	}
+
+	// A token file may be rotated, so its token is set on each request instead
+	// of once on the client.
+	var tokenTransport *consulTokenTransport
+	if i.TokenFile != "" {
+		tokenTransport = &consulTokenTransport{next: consulConfig.HttpClient.Transport}
+		consulConfig.HttpClient.Transport = tokenTransport
+	}
+
 	// Save the data on ourselves
 	c.Lock()
 	c.consul = &consulClient{
-		client:    client,
-		transport: transport,
+		client:         client,
+		transport:      transport,
+		tokenTransport: tokenTransport,
 	}
 	c.Unlock()
 
@@ -274,10 +413,16 @@ func (c *ClientSet) CreateVaultClient(i *CreateVaultClientInput) error {
 		dialer = i.TransportCustomDialer
 	}
 
+	proxy, err := transportProxy(i.Proxy)
+	if err != nil {
+		return fmt.Errorf("client set: vault: %s", err)
+	}
+
 	transport := &http.Transport{
-		Proxy:               http.ProxyFromEnvironment,
+		Proxy:               proxy,
 		Dial:                dialer.Dial,
 		DisableKeepAlives:   i.TransportDisableKeepAlives,
+		ForceAttemptHTTP2:   i.TransportEnableHTTP2,
 		MaxIdleConns:        i.TransportMaxIdleConns,
 		IdleConnTimeout:     i.TransportIdleConnTimeout,
 		MaxIdleConnsPerHost: i.TransportMaxIdleConnsPerHost,
@@ -336,6 +481,11 @@ func (c *ClientSet) CreateVaultClient(i *CreateVaultClientInput) error {
 	// Setup the new transport
 	vaultConfig.HttpClient.Transport = transport
 
+	// The Vault API client waits for its own limiter before each request.
+	if limiter := newRateLimiter(i.RateLimitQPS, i.RateLimitBurst); limiter != nil {
+		vaultConfig.Limiter = limiter
+	}
+
 	// Create the client
 	client, err := vaultapi.NewClient(vaultConfig)
 	if err != nil {
@@ -360,6 +510,18 @@ func (c *ClientSet) CreateVaultClient(i *CreateVaultClientInput) error {
 		}
 	}
 
+	// Log in with the auth method, which is renewed by the VaultAuthQuery.
+	var auth *VaultAuth
+	var authSecret *vaultapi.Secret
+	if i.Auth != nil && i.Token == "" {
+		auth = i.Auth
+		authSecret, err = vaultLogin(client, auth)
+		if err != nil {
+			return fmt.Errorf("client set: vault: %w", err)
+		}
+		i.Token = authSecret.Auth.ClientToken
+	}
+
 	if i.Token != "" {
 		client.SetToken(i.Token)
 	}
@@ -369,6 +531,8 @@ func (c *ClientSet) CreateVaultClient(i *CreateVaultClientInput) error {
 	c.vault = &vaultClient{
 		client:     client,
 		httpClient: vaultConfig.HttpClient,
+		auth:       auth,
+		authSecret: authSecret,
 	}
 	c.Unlock()
 
@@ -413,6 +577,7 @@ func (c *ClientSet) CreateNomadClient(i *CreateNomadClientInput) error {
 		Proxy:               http.ProxyFromEnvironment,
 		Dial:                dialer.Dial,
 		DisableKeepAlives:   i.TransportDisableKeepAlives,
+		ForceAttemptHTTP2:   i.TransportEnableHTTP2,
 		MaxIdleConns:        i.TransportMaxIdleConns,
 		IdleConnTimeout:     i.TransportIdleConnTimeout,
 		MaxIdleConnsPerHost: i.TransportMaxIdleConnsPerHost,
@@ -494,6 +659,20 @@ func (c *ClientSet) Consul() *consulapi.Client {
 	return c.consul.client
 }
 
+// SetConsulToken replaces the token sent with each Consul request. An empty
+// token sends the one the client was created with. It returns false if the
+// Consul client was not created with a token file, which is the only case the
+// token can change.
+func (c *ClientSet) SetConsulToken(token string) bool {
+	c.RLock()
+	defer c.RUnlock()
+	if c.consul == nil || c.consul.tokenTransport == nil {
+		return false
+	}
+	c.consul.tokenTransport.token.Store(token)
+	return true
+}
+
 // Vault returns the Vault client for this set.
 func (c *ClientSet) Vault() *vaultapi.Client {
 	c.RLock()
@@ -501,6 +680,35 @@ func (c *ClientSet) Vault() *vaultapi.Client {
 	return c.vault.client
 }
 
+// VaultAuthSecret returns the result of logging in with the configured auth
+// method when the Vault client was created, or nil if no auth method is used.
+func (c *ClientSet) VaultAuthSecret() *vaultapi.Secret {
+	c.RLock()
+	defer c.RUnlock()
+	if c.vault == nil {
+		return nil
+	}
+	return c.vault.authSecret
+}
+
+// VaultLogin logs in to Vault again with the configured auth method and
+// switches the Vault client to the new token.
+func (c *ClientSet) VaultLogin() (*vaultapi.Secret, error) {
+	c.RLock()
+	vault := c.vault
+	c.RUnlock()
+	if vault == nil || vault.auth == nil {
+		return nil, fmt.Errorf("vault auth: no auth method configured")
+	}
+
+	secret, err := vaultLogin(vault.client, vault.auth)
+	if err != nil {
+		return nil, err
+	}
+	vault.client.SetToken(secret.Auth.ClientToken)
+	return secret, nil
+}
+
 // Nomad returns the Nomad client for this set.
 func (c *ClientSet) Nomad() *nomadapi.Client {
 	c.RLock()
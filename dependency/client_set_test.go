@@ -4,12 +4,18 @@
 package dependency
 
 import (
+	"crypto/tls"
 	"encoding/json"
+	"encoding/pem"
 	"net/http"
 	"net/http/httptest"
 	"net/http/httputil"
 	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/consul-template/test"
 	"github.com/hashicorp/vault/api"
@@ -19,6 +25,299 @@ import (
 
 const userAgent = "my-user-agent"
 
+func TestClientSet_ConsulTransport(t *testing.T) {
+	t.Parallel()
+
+	t.Run("defaults", func(t *testing.T) {
+		clientSet := NewClientSet()
+		err := clientSet.CreateConsulClient(&CreateConsulClientInput{
+			Address: "127.0.0.1:8500",
+		})
+		require.NoError(t, err)
+
+		transport := clientSet.consul.transport
+		assert.False(t, transport.ForceAttemptHTTP2)
+		assert.Equal(t, 0, transport.MaxConnsPerHost)
+		assert.Equal(t, 0, transport.MaxIdleConns)
+		assert.Equal(t, time.Duration(0), transport.IdleConnTimeout)
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		clientSet := NewClientSet()
+		err := clientSet.CreateConsulClient(&CreateConsulClientInput{
+			Address:                      "127.0.0.1:8500",
+			TransportEnableHTTP2:         true,
+			TransportIdleConnTimeout:     30 * time.Second,
+			TransportMaxIdleConns:        200,
+			TransportMaxIdleConnsPerHost: 20,
+			TransportMaxConnsPerHost:     50,
+		})
+		require.NoError(t, err)
+
+		transport := clientSet.consul.transport
+		assert.True(t, transport.ForceAttemptHTTP2)
+		assert.Equal(t, 30*time.Second, transport.IdleConnTimeout)
+		assert.Equal(t, 200, transport.MaxIdleConns)
+		assert.Equal(t, 20, transport.MaxIdleConnsPerHost)
+		assert.Equal(t, 50, transport.MaxConnsPerHost)
+	})
+}
+
+func TestClientSet_Proxy(t *testing.T) {
+	t.Parallel()
+
+	req, err := http.NewRequest(http.MethodGet, "http://127.0.0.1:8500/v1/status/leader", nil)
+	require.NoError(t, err)
+
+	t.Run("consul", func(t *testing.T) {
+		clientSet := NewClientSet()
+		err := clientSet.CreateConsulClient(&CreateConsulClientInput{
+			Address: "127.0.0.1:8500",
+			Proxy:   "http://proxy.internal:3128",
+		})
+		require.NoError(t, err)
+
+		u, err := clientSet.consul.transport.Proxy(req)
+		require.NoError(t, err)
+		assert.Equal(t, "http://proxy.internal:3128", u.String())
+	})
+
+	t.Run("vault", func(t *testing.T) {
+		clientSet := NewClientSet()
+		err := clientSet.CreateVaultClient(&CreateVaultClientInput{
+			Address: "http://127.0.0.1:8200",
+			Proxy:   "socks5://proxy.internal:1080",
+		})
+		require.NoError(t, err)
+
+		transport, ok := clientSet.vault.httpClient.Transport.(*http.Transport)
+		require.True(t, ok)
+		u, err := transport.Proxy(req)
+		require.NoError(t, err)
+		assert.Equal(t, "socks5://proxy.internal:1080", u.String())
+	})
+
+	t.Run("invalid_scheme", func(t *testing.T) {
+		clientSet := NewClientSet()
+		err := clientSet.CreateConsulClient(&CreateConsulClientInput{
+			Address: "127.0.0.1:8500",
+			Proxy:   "ftp://proxy.internal:21",
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unsupported scheme")
+
+		err = clientSet.CreateVaultClient(&CreateVaultClientInput{
+			Address: "http://127.0.0.1:8200",
+			Proxy:   "proxy.internal",
+		})
+		require.Error(t, err)
+	})
+}
+
+func TestClientSet_RateLimit(t *testing.T) {
+	t.Parallel()
+
+	// With a burst of 2 at 20 requests per second, the first two requests go
+	// out right away and each of the other four waits 50ms for a token.
+	const (
+		qps      = 20
+		burst    = 2
+		requests = 6
+		minWait  = (requests - burst) * time.Second / qps
+	)
+
+	newServer := func(t *testing.T, body string) (*httptest.Server, *int) {
+		var lock sync.Mutex
+		var n int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			n++
+			lock.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(body))
+		}))
+		t.Cleanup(server.Close)
+		return server, &n
+	}
+
+	t.Run("consul", func(t *testing.T) {
+		t.Parallel()
+
+		server, n := newServer(t, `"127.0.0.1:8300"`)
+		clientSet := NewClientSet()
+		err := clientSet.CreateConsulClient(&CreateConsulClientInput{
+			Address:        server.URL,
+			RateLimitQPS:   qps,
+			RateLimitBurst: burst,
+		})
+		require.NoError(t, err)
+
+		start := time.Now()
+		for i := 0; i < requests; i++ {
+			_, err := clientSet.Consul().Status().Leader()
+			require.NoError(t, err)
+		}
+		assert.GreaterOrEqual(t, time.Since(start), minWait-10*time.Millisecond)
+		assert.Equal(t, requests, *n)
+	})
+
+	t.Run("vault", func(t *testing.T) {
+		t.Parallel()
+
+		server, n := newServer(t, `{"data": {"foo": "bar"}}`)
+		clientSet := NewClientSet()
+		err := clientSet.CreateVaultClient(&CreateVaultClientInput{
+			Address:        server.URL,
+			RateLimitQPS:   qps,
+			RateLimitBurst: burst,
+		})
+		require.NoError(t, err)
+
+		start := time.Now()
+		for i := 0; i < requests; i++ {
+			_, err := clientSet.Vault().Logical().Read("secret/foo")
+			require.NoError(t, err)
+		}
+		assert.GreaterOrEqual(t, time.Since(start), minWait-10*time.Millisecond)
+		assert.Equal(t, requests, *n)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Parallel()
+
+		server, n := newServer(t, `"127.0.0.1:8300"`)
+		clientSet := NewClientSet()
+		err := clientSet.CreateConsulClient(&CreateConsulClientInput{
+			Address: server.URL,
+		})
+		require.NoError(t, err)
+
+		start := time.Now()
+		for i := 0; i < requests; i++ {
+			_, err := clientSet.Consul().Status().Leader()
+			require.NoError(t, err)
+		}
+		assert.Less(t, time.Since(start), minWait)
+		assert.Equal(t, requests, *n)
+	})
+}
+
+func TestClientSet_ServerName(t *testing.T) {
+	t.Parallel()
+
+	// The test server records the server name each client sent with SNI. Its
+	// certificate is only valid for example.com, not the address the clients
+	// connect to.
+	var mu sync.Mutex
+	var sni string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			mu.Lock()
+			sni = hello.ServerName
+			mu.Unlock()
+			return nil, nil
+		},
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	err := os.WriteFile(caCert, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: srv.Certificate().Raw,
+	}), 0o600)
+	require.NoError(t, err)
+
+	// expectServerName checks the TLS config of the transport and that the
+	// server name is sent, and verified, when connecting to the test server.
+	expectServerName := func(t *testing.T, transport http.RoundTripper, name string) {
+		t.Helper()
+
+		tr, ok := transport.(*http.Transport)
+		require.True(t, ok)
+		require.NotNil(t, tr.TLSClientConfig)
+		assert.Equal(t, name, tr.TLSClientConfig.ServerName)
+
+		resp, err := (&http.Client{Transport: tr}).Get(srv.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, name, sni)
+		sni = ""
+	}
+
+	t.Run("consul", func(t *testing.T) {
+		clientSet := NewClientSet()
+		err := clientSet.CreateConsulClient(&CreateConsulClientInput{
+			Address:    srv.Listener.Addr().String(),
+			SSLEnabled: true,
+			SSLVerify:  true,
+			SSLCACert:  caCert,
+			ServerName: "example.com",
+		})
+		require.NoError(t, err)
+		expectServerName(t, clientSet.consul.transport, "example.com")
+	})
+
+	t.Run("vault", func(t *testing.T) {
+		clientSet := NewClientSet()
+		err := clientSet.CreateVaultClient(&CreateVaultClientInput{
+			Address:    srv.URL,
+			SSLEnabled: true,
+			SSLVerify:  true,
+			SSLCACert:  caCert,
+			ServerName: "example.com",
+		})
+		require.NoError(t, err)
+		expectServerName(t, clientSet.vault.httpClient.Transport, "example.com")
+	})
+
+	t.Run("nomad", func(t *testing.T) {
+		clientSet := NewClientSet()
+		err := clientSet.CreateNomadClient(&CreateNomadClientInput{
+			Address:    srv.URL,
+			SSLEnabled: true,
+			SSLVerify:  true,
+			SSLCACert:  caCert,
+			ServerName: "example.com",
+		})
+		require.NoError(t, err)
+		expectServerName(t, clientSet.nomad.httpClient.Transport, "example.com")
+	})
+
+	t.Run("verify_disabled", func(t *testing.T) {
+		clientSet := NewClientSet()
+		err := clientSet.CreateConsulClient(&CreateConsulClientInput{
+			Address:    srv.Listener.Addr().String(),
+			SSLEnabled: true,
+			SSLVerify:  false,
+			ServerName: "backend.internal",
+		})
+		require.NoError(t, err)
+		expectServerName(t, clientSet.consul.transport, "backend.internal")
+		assert.True(t, clientSet.consul.transport.TLSClientConfig.InsecureSkipVerify)
+	})
+
+	t.Run("verify_mismatch", func(t *testing.T) {
+		clientSet := NewClientSet()
+		err := clientSet.CreateConsulClient(&CreateConsulClientInput{
+			Address:    srv.Listener.Addr().String(),
+			SSLEnabled: true,
+			SSLVerify:  true,
+			SSLCACert:  caCert,
+			ServerName: "backend.internal",
+		})
+		require.NoError(t, err)
+
+		_, err = (&http.Client{Transport: clientSet.consul.transport}).Get(srv.URL)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "backend.internal")
+	})
+}
+
 func TestClientSet_K8SServiceTokenAuth(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,176 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dependency
+
+import (
+	"encoding/gob"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/pkg/errors"
+)
+
+var (
+	// Ensure implements
+	_ Dependency = (*ConnectIntentionsQuery)(nil)
+
+	// ConnectIntentionsQueryRe is the regular expression to use.
+	ConnectIntentionsQueryRe = regexp.MustCompile(`\A` + serviceNameRe + queryRe + dcRe + `\z`)
+)
+
+func init() {
+	gob.Register([]*Intention{})
+}
+
+// Intention is a service intention in Consul that applies to a destination
+// service. Action is "allow" or "deny", or empty for intentions that decide
+// with L7 permissions instead, which Permissions counts.
+type Intention struct {
+	SourceName           string
+	SourceNS             string
+	SourcePartition      string
+	SourcePeer           string
+	DestinationName      string
+	DestinationNS        string
+	DestinationPartition string
+	Action               string
+	Permissions          int
+	Precedence           int
+	Description          string
+	Meta                 map[string]string
+}
+
+// ConnectIntentionsQuery is the representation of the intentions that apply to
+// a destination service.
+type ConnectIntentionsQuery struct {
+	stopCh chan struct{}
+
+	name      string
+	dc        string
+	namespace string
+	partition string
+	readMode  string
+}
+
+// NewConnectIntentionsQuery parses a string of the format service?query@dc
+// into the intentions of the destination service.
+func NewConnectIntentionsQuery(s string) (*ConnectIntentionsQuery, error) {
+	if !ConnectIntentionsQueryRe.MatchString(s) {
+		return nil, fmt.Errorf("connect.intentions: invalid format: %q", s)
+	}
+
+	m := regexpMatch(ConnectIntentionsQueryRe, s)
+	queryParams, err := GetConsulQueryOpts(m, "connect.intentions", QueryStale, QueryConsistent)
+	if err != nil {
+		return nil, err
+	}
+
+	readMode, err := GetConsulReadMode(queryParams, "connect.intentions")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConnectIntentionsQuery{
+		stopCh:    make(chan struct{}, 1),
+		name:      m["name"],
+		dc:        m["dc"],
+		namespace: queryParams.Get(QueryNamespace),
+		partition: queryParams.Get(QueryPartition),
+		readMode:  readMode,
+	}, nil
+}
+
+// Fetch queries the Consul API defined by the given client and returns a slice
+// of Intention objects, ordered by precedence from highest to lowest as Consul
+// evaluates them.
+func (d *ConnectIntentionsQuery) Fetch(clients *ClientSet, opts *QueryOptions) (interface{}, *ResponseMetadata, error) {
+	select {
+	case <-d.stopCh:
+		return nil, nil, ErrStopped
+	default:
+	}
+
+	opts = opts.Merge(&QueryOptions{
+		Datacenter:      d.dc,
+		ConsulPartition: d.partition,
+		ConsulNamespace: d.namespace,
+	}).setReadMode(d.readMode)
+
+	log.Printf("[TRACE] %s: GET %s", d, &url.URL{
+		Path:     "/v1/connect/intentions/match",
+		RawQuery: opts.String(),
+	})
+	matches, qm, err := clients.Consul().Connect().IntentionMatch(&api.IntentionMatch{
+		By:    api.IntentionMatchDestination,
+		Names: []string{d.name},
+	}, opts.ToConsulOpts())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, d.String())
+	}
+
+	entries := matches[d.name]
+	log.Printf("[TRACE] %s: returned %d results", d, len(entries))
+
+	intentions := make([]*Intention, 0, len(entries))
+	for _, entry := range entries {
+		intentions = append(intentions, &Intention{
+			SourceName:           entry.SourceName,
+			SourceNS:             entry.SourceNS,
+			SourcePartition:      entry.SourcePartition,
+			SourcePeer:           entry.SourcePeer,
+			DestinationName:      entry.DestinationName,
+			DestinationNS:        entry.DestinationNS,
+			DestinationPartition: entry.DestinationPartition,
+			Action:               string(entry.Action),
+			Permissions:          len(entry.Permissions),
+			Precedence:           entry.Precedence,
+			Description:          entry.Description,
+			Meta:                 entry.Meta,
+		})
+	}
+
+	rm := &ResponseMetadata{
+		LastIndex:   qm.LastIndex,
+		LastContact: qm.LastContact,
+	}
+
+	return intentions, rm, nil
+}
+
+// CanShare returns a boolean if this dependency is shareable.
+func (d *ConnectIntentionsQuery) CanShare() bool {
+	return true
+}
+
+// String returns the human-friendly version of this dependency.
+func (d *ConnectIntentionsQuery) String() string {
+	name := d.name
+	var ns, partition string
+	if d.namespace != "" {
+		ns = QueryNamespace + "=" + d.namespace
+	}
+	if d.partition != "" {
+		partition = QueryPartition + "=" + d.partition
+	}
+	if q := joinQueryParams(d.readMode, ns, partition); q != "" {
+		name = name + "?" + q
+	}
+	if d.dc != "" {
+		name = name + "@" + d.dc
+	}
+	return fmt.Sprintf("connect.intentions(%s)", name)
+}
+
+// Stop halts the dependency's fetch function.
+func (d *ConnectIntentionsQuery) Stop() {
+	close(d.stopCh)
+}
+
+// Type returns the type of this dependency.
+func (d *ConnectIntentionsQuery) Type() Type {
+	return TypeConsul
+}
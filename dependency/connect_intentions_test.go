@@ -0,0 +1,176 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dependency
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewConnectIntentionsQuery(t *testing.T) {
+	cases := []struct {
+		name string
+		i    string
+		exp  *ConnectIntentionsQuery
+		err  bool
+	}{
+		{
+			"empty",
+			"",
+			nil,
+			true,
+		},
+		{
+			"invalid query param (unsupported key)",
+			"web?unsupported=foo",
+			nil,
+			true,
+		},
+		{
+			"name",
+			"web",
+			&ConnectIntentionsQuery{
+				name: "web",
+			},
+			false,
+		},
+		{
+			"dc",
+			"web@dc1",
+			&ConnectIntentionsQuery{
+				name: "web",
+				dc:   "dc1",
+			},
+			false,
+		},
+		{
+			"every_option",
+			"web?ns=foo&partition=bar&consistent@dc1",
+			&ConnectIntentionsQuery{
+				name:      "web",
+				dc:        "dc1",
+				namespace: "foo",
+				partition: "bar",
+				readMode:  QueryConsistent,
+			},
+			false,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			act, err := NewConnectIntentionsQuery(tc.i)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+
+			if act != nil {
+				act.stopCh = nil
+			}
+
+			assert.Equal(t, tc.exp, act)
+		})
+	}
+}
+
+func TestConnectIntentionsQuery_Fetch(t *testing.T) {
+	var path, by, name, dc, ns string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		by = r.URL.Query().Get("by")
+		name = r.URL.Query().Get("name")
+		dc = r.URL.Query().Get("dc")
+		ns = r.URL.Query().Get("ns")
+		w.Header().Set("X-Consul-Index", "1")
+		fmt.Fprint(w, `{"web": [
+			{"SourceName": "api", "SourceNS": "foo", "DestinationName": "web",
+			 "DestinationNS": "foo", "Action": "deny", "Precedence": 9,
+			 "Description": "no api", "Meta": {"owner": "team-a"}},
+			{"SourceName": "*", "SourceNS": "foo", "DestinationName": "web",
+			 "DestinationNS": "foo", "Precedence": 8,
+			 "Permissions": [{"Action": "allow", "HTTP": {"PathPrefix": "/public"}}]}
+		]}`)
+	}))
+	defer server.Close()
+
+	clients := NewClientSet()
+	if err := clients.CreateConsulClient(&CreateConsulClientInput{
+		Address: server.URL,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := NewConnectIntentionsQuery("web?ns=foo@dc1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	act, _, err := d.Fetch(clients, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "/v1/connect/intentions/match", path)
+	assert.Equal(t, "destination", by)
+	assert.Equal(t, "web", name)
+	assert.Equal(t, "dc1", dc)
+	assert.Equal(t, "foo", ns)
+	assert.Equal(t, []*Intention{
+		{
+			SourceName:      "api",
+			SourceNS:        "foo",
+			DestinationName: "web",
+			DestinationNS:   "foo",
+			Action:          "deny",
+			Precedence:      9,
+			Description:     "no api",
+			Meta:            map[string]string{"owner": "team-a"},
+		},
+		{
+			SourceName:      "*",
+			SourceNS:        "foo",
+			DestinationName: "web",
+			DestinationNS:   "foo",
+			Permissions:     1,
+			Precedence:      8,
+		},
+	}, act)
+}
+
+func TestConnectIntentionsQuery_String(t *testing.T) {
+	cases := []struct {
+		name string
+		i    string
+		exp  string
+	}{
+		{
+			"name",
+			"web",
+			"connect.intentions(web)",
+		},
+		{
+			"datacenter",
+			"web@dc1",
+			"connect.intentions(web@dc1)",
+		},
+		{
+			"every_option",
+			"web?stale&partition=bar&ns=foo@dc1",
+			"connect.intentions(web?stale&ns=foo&partition=bar@dc1)",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			d, err := NewConnectIntentionsQuery(tc.i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.exp, d.String())
+		})
+	}
+}
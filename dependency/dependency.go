@@ -7,8 +7,10 @@ import (
 	"fmt"
 	"net/url"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	consulapi "github.com/hashicorp/consul/api"
@@ -20,7 +22,7 @@ const (
 	keyRe          = `/?(?P<key>[^@\?]+)`
 	filterRe       = `(\|(?P<filter>[[:word:]\,]+))?`
 	serviceNameRe  = `(?P<name>[[:word:]\-\_]+)`
-	queryRe        = `(\?(?P<query>[[:word:]\-\_\=\&]+))?`
+	queryRe        = `(\?(?P<query>[[:word:]\-\_\=\&\:\.]+))?`
 	nodeNameRe     = `(?P<name>[[:word:]\.\-\_]+)`
 	nearRe         = `(~(?P<near>[[:word:]\.\-\_]+))?`
 	prefixRe       = `/?(?P<prefix>[^@\?]+)`
@@ -76,6 +78,9 @@ type QueryOptions struct {
 	ConsulPeer        string
 	ConsulPartition   string
 	ConsulNamespace   string
+	UseCache          bool
+	MaxAge            time.Duration
+	StaleIfError      time.Duration
 }
 
 func (q *QueryOptions) Merge(o *QueryOptions) *QueryOptions {
@@ -139,9 +144,46 @@ func (q *QueryOptions) Merge(o *QueryOptions) *QueryOptions {
 		r.ConsulPeer = o.ConsulPeer
 	}
 
+	if o.UseCache {
+		r.UseCache = o.UseCache
+	}
+
+	if o.MaxAge != 0 {
+		r.MaxAge = o.MaxAge
+	}
+
+	if o.StaleIfError != 0 {
+		r.StaleIfError = o.StaleIfError
+	}
+
 	return &r
 }
 
+// setReadMode overrides the stale and consistent options with the given read
+// mode. An empty mode leaves the options unchanged.
+func (q *QueryOptions) setReadMode(mode string) *QueryOptions {
+	switch mode {
+	case QueryStale:
+		q.AllowStale = true
+		q.RequireConsistent = false
+	case QueryConsistent:
+		q.AllowStale = false
+		q.RequireConsistent = true
+	}
+	return q
+}
+
+// setCache enables the Consul agent cache with the given settings. A nil cache
+// leaves the options unchanged.
+func (q *QueryOptions) setCache(c *ConsulCache) *QueryOptions {
+	if c != nil {
+		q.UseCache = true
+		q.MaxAge = c.MaxAge
+		q.StaleIfError = c.StaleIfError
+	}
+	return q
+}
+
 func (q *QueryOptions) ToConsulOpts() *consulapi.QueryOptions {
 	return &consulapi.QueryOptions{
 		AllowStale:        q.AllowStale,
@@ -151,14 +193,18 @@ func (q *QueryOptions) ToConsulOpts() *consulapi.QueryOptions {
 		Peer:              q.ConsulPeer,
 		Near:              q.Near,
 		RequireConsistent: q.RequireConsistent,
+		UseCache:          q.UseCache,
+		MaxAge:            q.MaxAge,
+		StaleIfError:      q.StaleIfError,
 		WaitIndex:         q.WaitIndex,
 		WaitTime:          q.WaitTime,
 	}
 }
 
 // GetConsulQueryOpts parses optional consul query params into key pairs.
-// supports namespace, peer and partition params
-func GetConsulQueryOpts(queryMap map[string]string, endpointLabel string) (url.Values, error) {
+// supports namespace, peer and partition params, plus any endpoint specific
+// keys given in extraKeys.
+func GetConsulQueryOpts(queryMap map[string]string, endpointLabel string, extraKeys ...string) (url.Values, error) {
 	queryParams := url.Values{}
 
 	if queryRaw := queryMap["query"]; queryRaw != "" {
@@ -169,14 +215,11 @@ func GetConsulQueryOpts(queryMap map[string]string, endpointLabel string) (url.V
 				"%s: invalid query: %q: %s", endpointLabel, queryRaw, err)
 		}
 		// Validate keys.
+		supported := append([]string{QueryNamespace, QueryPeer, QueryPartition}, extraKeys...)
 		for key := range queryParams {
-			switch key {
-			case QueryNamespace,
-				QueryPeer,
-				QueryPartition:
-			default:
+			if !slices.Contains(supported, key) {
 				return nil,
-					fmt.Errorf("%s: invalid query parameter key %q in query %q: supported keys: %s,%s,%s", endpointLabel, key, queryRaw, QueryNamespace, QueryPeer, QueryPartition)
+					fmt.Errorf("%s: invalid query parameter key %q in query %q: supported keys: %s", endpointLabel, key, queryRaw, strings.Join(supported, ","))
 			}
 		}
 	}
@@ -184,6 +227,102 @@ func GetConsulQueryOpts(queryMap map[string]string, endpointLabel string) (url.V
 	return queryParams, nil
 }
 
+// GetConsulReadMode returns the read mode set by the stale or consistent
+// query params, or an empty string to use the global default. The params are
+// flags and must not have a value.
+func GetConsulReadMode(queryParams url.Values, endpointLabel string) (string, error) {
+	var mode string
+	for _, key := range []string{QueryStale, QueryConsistent} {
+		v, ok := queryParams[key]
+		if !ok {
+			continue
+		}
+		if len(v) != 1 || v[0] != "" {
+			return "", fmt.Errorf("%s: query parameter %q does not take a value", endpointLabel, key)
+		}
+		if mode != "" {
+			return "", fmt.Errorf("%s: query parameters %q and %q are mutually exclusive", endpointLabel, mode, key)
+		}
+		mode = key
+	}
+	return mode, nil
+}
+
+// ConsulCache is the Consul agent cache setting of a query. A zero MaxAge or
+// StaleIfError uses the agent's default.
+type ConsulCache struct {
+	MaxAge       time.Duration
+	StaleIfError time.Duration
+}
+
+// String returns the cache setting in query string form.
+func (c *ConsulCache) String() string {
+	if c == nil {
+		return ""
+	}
+	s := QueryCached
+	if c.MaxAge != 0 {
+		s = s + "&" + QueryMaxAge + "=" + c.MaxAge.String()
+	}
+	if c.StaleIfError != 0 {
+		s = s + "&" + QueryStaleIfError + "=" + c.StaleIfError.String()
+	}
+	return s
+}
+
+// GetConsulCache returns the agent cache setting set by the cached query
+// param, or nil to not use the agent cache. The max-age and stale-if-error
+// params take a duration and require cached. The cache can't be combined with
+// consistent reads.
+func GetConsulCache(queryParams url.Values, endpointLabel string) (*ConsulCache, error) {
+	v, ok := queryParams[QueryCached]
+	if !ok {
+		for _, key := range []string{QueryMaxAge, QueryStaleIfError} {
+			if _, ok := queryParams[key]; ok {
+				return nil, fmt.Errorf("%s: query parameter %q requires %q", endpointLabel, key, QueryCached)
+			}
+		}
+		return nil, nil
+	}
+	if len(v) != 1 || v[0] != "" {
+		return nil, fmt.Errorf("%s: query parameter %q does not take a value", endpointLabel, QueryCached)
+	}
+	if _, ok := queryParams[QueryConsistent]; ok {
+		return nil, fmt.Errorf("%s: query parameters %q and %q are mutually exclusive", endpointLabel, QueryCached, QueryConsistent)
+	}
+
+	var c ConsulCache
+	for _, p := range []struct {
+		key string
+		dst *time.Duration
+	}{
+		{QueryMaxAge, &c.MaxAge},
+		{QueryStaleIfError, &c.StaleIfError},
+	} {
+		v, ok := queryParams[p.key]
+		if !ok {
+			continue
+		}
+		dur, err := time.ParseDuration(v[0])
+		if len(v) != 1 || err != nil || dur <= 0 {
+			return nil, fmt.Errorf("%s: query parameter %q must be a single positive duration", endpointLabel, p.key)
+		}
+		*p.dst = dur
+	}
+	return &c, nil
+}
+
+// joinQueryParams joins the non-empty query string parts with "&".
+func joinQueryParams(parts ...string) string {
+	var nonEmpty []string
+	for _, p := range parts {
+		if p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	return strings.Join(nonEmpty, "&")
+}
+
 func (q *QueryOptions) ToNomadOpts() *nomadapi.QueryOptions {
 	var params map[string]string
 	if q.Choose != "" {
@@ -239,6 +378,10 @@ func (q *QueryOptions) String() string {
 		u.Add("consistent", strconv.FormatBool(q.RequireConsistent))
 	}
 
+	if q.UseCache {
+		u.Add(QueryCached, "")
+	}
+
 	if q.WaitIndex != 0 {
 		u.Add("index", strconv.FormatUint(q.WaitIndex, 10))
 	}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/url"
 	"os"
 	"os/exec"
 	"reflect"
@@ -423,6 +424,106 @@ func TestCanShare(t *testing.T) {
 	}
 }
 
+func TestGetConsulReadMode(t *testing.T) {
+	cases := []struct {
+		name string
+		q    string
+		exp  string
+		err  bool
+	}{
+		{"none", "ns=foo", "", false},
+		{"stale", "stale", QueryStale, false},
+		{"consistent", "consistent&ns=foo", QueryConsistent, false},
+		{"both", "stale&consistent", "", true},
+		{"value", "consistent=true", "", true},
+		{"repeated", "stale&stale", "", true},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			v, err := url.ParseQuery(tc.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			act, err := GetConsulReadMode(v, "test")
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+			if act != tc.exp {
+				t.Errorf("expected %q to be %q", act, tc.exp)
+			}
+		})
+	}
+}
+
+func TestGetConsulCache(t *testing.T) {
+	cases := []struct {
+		name string
+		q    string
+		exp  *ConsulCache
+		err  bool
+	}{
+		{"none", "ns=foo", nil, false},
+		{"cached", "cached", &ConsulCache{}, false},
+		{"max_age", "cached&max-age=30s", &ConsulCache{MaxAge: 30 * time.Second}, false},
+		{"stale_if_error", "cached&stale-if-error=1m", &ConsulCache{StaleIfError: time.Minute}, false},
+		{"stale", "cached&stale", &ConsulCache{}, false},
+		{"max_age_not_cached", "max-age=30s", nil, true},
+		{"value", "cached=true", nil, true},
+		{"consistent", "cached&consistent", nil, true},
+		{"bad_duration", "cached&max-age=soon", nil, true},
+		{"negative_duration", "cached&stale-if-error=-1s", nil, true},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			v, err := url.ParseQuery(tc.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			act, err := GetConsulCache(v, "test")
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(act, tc.exp) {
+				t.Errorf("expected %#v to be %#v", act, tc.exp)
+			}
+		})
+	}
+}
+
+func TestQueryOptions_setCache(t *testing.T) {
+	cached := (&QueryOptions{}).setCache(&ConsulCache{
+		MaxAge:       30 * time.Second,
+		StaleIfError: time.Minute,
+	}).ToConsulOpts()
+	if !cached.UseCache || cached.MaxAge != 30*time.Second || cached.StaleIfError != time.Minute {
+		t.Errorf("expected cached read, got %#v", cached)
+	}
+
+	unchanged := (&QueryOptions{}).setCache(nil).ToConsulOpts()
+	if unchanged.UseCache || unchanged.MaxAge != 0 || unchanged.StaleIfError != 0 {
+		t.Errorf("expected options to be unchanged, got %#v", unchanged)
+	}
+}
+
+func TestQueryOptions_setReadMode(t *testing.T) {
+	stale := (&QueryOptions{RequireConsistent: true}).setReadMode(QueryStale)
+	if !stale.AllowStale || stale.RequireConsistent {
+		t.Errorf("expected stale read, got %#v", stale)
+	}
+
+	consistent := (&QueryOptions{AllowStale: true}).setReadMode(QueryConsistent)
+	if consistent.AllowStale || !consistent.RequireConsistent {
+		t.Errorf("expected consistent read, got %#v", consistent)
+	}
+
+	unchanged := (&QueryOptions{AllowStale: true}).setReadMode("")
+	if !unchanged.AllowStale || unchanged.RequireConsistent {
+		t.Errorf("expected options to be unchanged, got %#v", unchanged)
+	}
+}
+
 func TestDeepCopyAndSortTags(t *testing.T) {
 	tags := []string{"hello", "world", "these", "are", "tags", "foo:bar", "baz=qux"}
 	expected := []string{"are", "baz=qux", "foo:bar", "hello", "tags", "these", "world"}
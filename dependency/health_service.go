@@ -23,9 +23,17 @@ const (
 	HealthCritical = "critical"
 	HealthMaint    = "maintenance"
 
-	QueryNamespace = "ns"
-	QueryPartition = "partition"
-	QueryPeer      = "peer"
+	QueryNamespace  = "ns"
+	QueryPartition  = "partition"
+	QueryPeer       = "peer"
+	QueryStale      = "stale"
+	QueryConsistent = "consistent"
+
+	// QueryCached, QueryMaxAge and QueryStaleIfError are the query params used
+	// to opt in to the Consul agent cache on the endpoints that support it.
+	QueryCached       = "cached"
+	QueryMaxAge       = "max-age"
+	QueryStaleIfError = "stale-if-error"
 
 	NodeMaint    = "_node_maintenance"
 	ServiceMaint = "_service_maintenance:"
@@ -62,6 +70,25 @@ type HealthService struct {
 	Weights                api.AgentWeights
 }
 
+// DefaultSRVPriority is the priority of every SRVRecord. Consul does not have a
+// notion of priority, so all instances share the same one.
+const DefaultSRVPriority = 1
+
+// SRVRecord is a DNS SRV-style record for a single service instance.
+type SRVRecord struct {
+	Target   string
+	Port     int
+	Priority int
+	Weight   int
+}
+
+// PrometheusStaticConfig is a Prometheus scrape static config for a single
+// service instance, with its "host:port" target and its labels.
+type PrometheusStaticConfig struct {
+	Targets []string          `json:"targets" yaml:"targets"`
+	Labels  map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+}
+
 // HealthServiceQuery is the representation of all a service query in Consul.
 type HealthServiceQuery struct {
 	stopCh chan struct{}
@@ -75,6 +102,8 @@ type HealthServiceQuery struct {
 	partition string
 	peer      string
 	namespace string
+	readMode  string
+	cache     *ConsulCache
 }
 
 // NewHealthServiceQuery processes the strings to build a service dependency.
@@ -117,7 +146,18 @@ func healthServiceQuery(s string, connect bool) (*HealthServiceQuery, error) {
 		filters = []string{HealthPassing}
 	}
 
-	queryParams, err := GetConsulQueryOpts(m, "health.service")
+	queryParams, err := GetConsulQueryOpts(m, "health.service", QueryStale, QueryConsistent,
+		QueryCached, QueryMaxAge, QueryStaleIfError)
+	if err != nil {
+		return nil, err
+	}
+
+	readMode, err := GetConsulReadMode(queryParams, "health.service")
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := GetConsulCache(queryParams, "health.service")
 	if err != nil {
 		return nil, err
 	}
@@ -133,6 +173,8 @@ func healthServiceQuery(s string, connect bool) (*HealthServiceQuery, error) {
 		namespace: queryParams.Get(QueryNamespace),
 		peer:      queryParams.Get(QueryPeer),
 		partition: queryParams.Get(QueryPartition),
+		readMode:  readMode,
+		cache:     cache,
 	}, nil
 }
 
@@ -151,7 +193,7 @@ func (d *HealthServiceQuery) Fetch(clients *ClientSet, opts *QueryOptions) (inte
 		ConsulNamespace: d.namespace,
 		ConsulPartition: d.partition,
 		ConsulPeer:      d.peer,
-	})
+	}).setReadMode(d.readMode).setCache(d.cache)
 
 	u := &url.URL{
 		Path:     "/v1/health/service/" + d.name,
@@ -249,6 +291,9 @@ func (d *HealthServiceQuery) String() string {
 	if d.tag != "" {
 		name = d.tag + "." + name
 	}
+	if q := joinQueryParams(d.readMode, d.cache.String()); q != "" {
+		name = name + "?" + q
+	}
 	if d.dc != "" {
 		name = name + "@" + d.dc
 	}
@@ -6,6 +6,7 @@ package dependency
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/consul/api"
 	"github.com/stretchr/testify/assert"
@@ -93,6 +94,33 @@ func TestNewHealthServiceQuery(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"name_near_agent",
+			"name~_agent",
+			&HealthServiceQuery{
+				filters: []string{"passing"},
+				name:    "name",
+				near:    "_agent",
+			},
+			false,
+		},
+		{
+			"name_near_ip",
+			"name~_ip",
+			&HealthServiceQuery{
+				filters: []string{"passing"},
+				name:    "name",
+				near:    "_ip",
+			},
+			false,
+		},
+		{
+			// Consul only sorts by the IP the request came from.
+			"name_near_ip_address",
+			"name~_ip=1.2.3.4",
+			nil,
+			true,
+		},
 		{
 			"tag_name",
 			"tag.name",
@@ -114,6 +142,23 @@ func TestNewHealthServiceQuery(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"name_stale_dc",
+			"name?stale@dc",
+			&HealthServiceQuery{
+				dc:       "dc",
+				filters:  []string{"passing"},
+				name:     "name",
+				readMode: "stale",
+			},
+			false,
+		},
+		{
+			"name_stale_and_consistent",
+			"name?consistent&stale",
+			nil,
+			true,
+		},
 		{
 			"tag_name_near",
 			"tag.name~near",
@@ -216,6 +261,29 @@ func TestNewHealthServiceQuery(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"cached",
+			"name?cached&max-age=30s@dc",
+			&HealthServiceQuery{
+				filters: []string{"passing"},
+				name:    "name",
+				dc:      "dc",
+				cache:   &ConsulCache{MaxAge: 30 * time.Second},
+			},
+			false,
+		},
+		{
+			"cached_consistent",
+			"name?cached&consistent",
+			nil,
+			true,
+		},
+		{
+			"max_age_without_cached",
+			"name?max-age=30s",
+			nil,
+			true,
+		},
 	}
 
 	for i, tc := range cases {
@@ -232,24 +300,29 @@ func TestNewHealthServiceQuery(t *testing.T) {
 			assert.Equal(t, tc.exp, act)
 		})
 	}
-	// Connect
-	// all tests above also test connect, just need to check enabling it
-	t.Run("connect_query", func(t *testing.T) {
-		act, err := NewHealthConnectQuery("name")
-		if err != nil {
-			t.Fatal(err)
-		}
-		if act != nil {
-			act.stopCh = nil
-		}
-		exp := &HealthServiceQuery{
-			filters: []string{"passing"},
-			name:    "name",
-			connect: true,
-		}
 
-		assert.Equal(t, exp, act)
-	})
+	// Connect queries parse the same syntax, they only differ by enabling
+	// connect on the query.
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("connect_%d_%s", i, tc.name), func(t *testing.T) {
+			act, err := NewHealthConnectQuery(tc.i)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+
+			var exp *HealthServiceQuery
+			if tc.exp != nil {
+				e := *tc.exp
+				e.connect = true
+				exp = &e
+			}
+			if act != nil {
+				act.stopCh = nil
+			}
+
+			assert.Equal(t, exp, act)
+		})
+	}
 }
 
 func TestHealthConnectServiceQuery_Fetch(t *testing.T) {
@@ -476,6 +549,23 @@ func TestHealthServiceQuery_Fetch(t *testing.T) {
 	}
 }
 
+func TestHealthServiceQuery_Fetch_near(t *testing.T) {
+	clients, near := newConsulNearServer(t)
+
+	for _, exp := range []string{"_agent", "_ip", "node1"} {
+		t.Run(exp, func(t *testing.T) {
+			d, err := NewHealthServiceQuery("consul~" + exp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := d.Fetch(clients, nil); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, exp, *near)
+		})
+	}
+}
+
 func TestHealthServiceQuery_String(t *testing.T) {
 	cases := []struct {
 		name string
@@ -512,6 +602,16 @@ func TestHealthServiceQuery_String(t *testing.T) {
 			"name~near|any",
 			"health.service(name~near|any)",
 		},
+		{
+			"name_near_agent",
+			"name~_agent",
+			"health.service(name~_agent|passing)",
+		},
+		{
+			"name_near_ip",
+			"name~_ip",
+			"health.service(name~_ip|passing)",
+		},
 		{
 			"name_dc_near",
 			"name@dc~near",
@@ -532,6 +632,21 @@ func TestHealthServiceQuery_String(t *testing.T) {
 			"tag.name@dc",
 			"health.service(tag.name@dc|passing)",
 		},
+		{
+			"tag_name_consistent_dc",
+			"tag.name?consistent@dc",
+			"health.service(tag.name?consistent@dc|passing)",
+		},
+		{
+			"name_cached",
+			"name?cached&max-age=30s",
+			"health.service(name?cached&max-age=30s|passing)",
+		},
+		{
+			"name_stale_cached_dc",
+			"name?stale&cached&stale-if-error=1m@dc",
+			"health.service(name?stale&cached&stale-if-error=1m0s@dc|passing)",
+		},
 		{
 			"tag_name_near",
 			"tag.name~near",
@@ -574,6 +689,18 @@ func TestHealthServiceQueryConnect_String(t *testing.T) {
 			"name",
 			"health.connect(name|passing)",
 		},
+		{
+			"tag_name_dc_near",
+			NewHealthConnectQuery,
+			"tag.name@dc~near",
+			"health.connect(tag.name@dc~near|passing)",
+		},
+		{
+			"name_filter",
+			NewHealthConnectQuery,
+			"name|any",
+			"health.connect(name|any)",
+		},
 	}
 
 	for i, tc := range cases {
@@ -0,0 +1,185 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dependency
+
+import (
+	"encoding/gob"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// Ensure implements
+	_ Dependency = (*HealthStateQuery)(nil)
+
+	// HealthStateQueryRe is the regular expression to use.
+	HealthStateQueryRe = regexp.MustCompile(`\A(?P<state>[[:word:]]+)` + queryRe + dcRe + `\z`)
+)
+
+func init() {
+	gob.Register([]*HealthCheck{})
+}
+
+// HealthCheck is a health check entry in Consul.
+type HealthCheck struct {
+	Node        string
+	CheckID     string
+	Name        string
+	Status      string
+	Notes       string
+	Output      string
+	ServiceID   string
+	ServiceName string
+	ServiceTags ServiceTags
+	Type        string
+}
+
+// HealthStateQuery is the representation of all health checks in a given
+// state across the cluster.
+type HealthStateQuery struct {
+	stopCh chan struct{}
+
+	state     string
+	dc        string
+	namespace string
+	partition string
+	readMode  string
+}
+
+// NewHealthStateQuery parses a string of the format state?query@dc, where
+// state is one of "any", "passing", "warning" or "critical".
+func NewHealthStateQuery(s string) (*HealthStateQuery, error) {
+	if !HealthStateQueryRe.MatchString(s) {
+		return nil, fmt.Errorf("health.state: invalid format: %q", s)
+	}
+
+	m := regexpMatch(HealthStateQueryRe, s)
+	switch m["state"] {
+	case HealthAny, HealthPassing, HealthWarning, HealthCritical:
+	default:
+		return nil, fmt.Errorf("health.state: invalid state: %q in %q", m["state"], s)
+	}
+
+	queryParams, err := GetConsulQueryOpts(m, "health.state", QueryStale, QueryConsistent)
+	if err != nil {
+		return nil, err
+	}
+
+	readMode, err := GetConsulReadMode(queryParams, "health.state")
+	if err != nil {
+		return nil, err
+	}
+
+	return &HealthStateQuery{
+		stopCh:    make(chan struct{}, 1),
+		state:     m["state"],
+		dc:        m["dc"],
+		namespace: queryParams.Get(QueryNamespace),
+		partition: queryParams.Get(QueryPartition),
+		readMode:  readMode,
+	}, nil
+}
+
+// Fetch queries the Consul API defined by the given client and returns a slice
+// of HealthCheck objects.
+func (d *HealthStateQuery) Fetch(clients *ClientSet, opts *QueryOptions) (interface{}, *ResponseMetadata, error) {
+	select {
+	case <-d.stopCh:
+		return nil, nil, ErrStopped
+	default:
+	}
+
+	opts = opts.Merge(&QueryOptions{
+		Datacenter:      d.dc,
+		ConsulPartition: d.partition,
+		ConsulNamespace: d.namespace,
+	}).setReadMode(d.readMode)
+
+	log.Printf("[TRACE] %s: GET %s", d, &url.URL{
+		Path:     "/v1/health/state/" + d.state,
+		RawQuery: opts.String(),
+	})
+	entries, qm, err := clients.Consul().Health().State(d.state, opts.ToConsulOpts())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, d.String())
+	}
+
+	log.Printf("[TRACE] %s: returned %d results", d, len(entries))
+
+	checks := make([]*HealthCheck, 0, len(entries))
+	for _, entry := range entries {
+		checks = append(checks, &HealthCheck{
+			Node:        entry.Node,
+			CheckID:     entry.CheckID,
+			Name:        entry.Name,
+			Status:      entry.Status,
+			Notes:       entry.Notes,
+			Output:      entry.Output,
+			ServiceID:   entry.ServiceID,
+			ServiceName: entry.ServiceName,
+			ServiceTags: ServiceTags(deepCopyAndSortTags(entry.ServiceTags)),
+			Type:        entry.Type,
+		})
+	}
+
+	sort.Stable(ByNodeThenCheckID(checks))
+
+	rm := &ResponseMetadata{
+		LastIndex:   qm.LastIndex,
+		LastContact: qm.LastContact,
+	}
+
+	return checks, rm, nil
+}
+
+// CanShare returns a boolean if this dependency is shareable.
+func (d *HealthStateQuery) CanShare() bool {
+	return true
+}
+
+// String returns the human-friendly version of this dependency.
+func (d *HealthStateQuery) String() string {
+	name := d.state
+	var ns, partition string
+	if d.namespace != "" {
+		ns = QueryNamespace + "=" + d.namespace
+	}
+	if d.partition != "" {
+		partition = QueryPartition + "=" + d.partition
+	}
+	if q := joinQueryParams(d.readMode, ns, partition); q != "" {
+		name = name + "?" + q
+	}
+	if d.dc != "" {
+		name = name + "@" + d.dc
+	}
+	return fmt.Sprintf("health.state(%s)", name)
+}
+
+// Stop halts the dependency's fetch function.
+func (d *HealthStateQuery) Stop() {
+	close(d.stopCh)
+}
+
+// Type returns the type of this dependency.
+func (d *HealthStateQuery) Type() Type {
+	return TypeConsul
+}
+
+// ByNodeThenCheckID is a sortable slice of HealthCheck structs.
+type ByNodeThenCheckID []*HealthCheck
+
+func (s ByNodeThenCheckID) Len() int      { return len(s) }
+func (s ByNodeThenCheckID) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s ByNodeThenCheckID) Less(i, j int) bool {
+	if s[i].Node == s[j].Node {
+		return s[i].CheckID < s[j].CheckID
+	}
+	return s[i].Node < s[j].Node
+}
@@ -0,0 +1,202 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dependency
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHealthStateQuery(t *testing.T) {
+	cases := []struct {
+		name string
+		i    string
+		exp  *HealthStateQuery
+		err  bool
+	}{
+		{
+			"empty",
+			"",
+			nil,
+			true,
+		},
+		{
+			"invalid_state",
+			"failing",
+			nil,
+			true,
+		},
+		{
+			"maintenance",
+			"maintenance",
+			nil,
+			true,
+		},
+		{
+			"invalid query param (unsupported key)",
+			"critical?unsupported=foo",
+			nil,
+			true,
+		},
+		{
+			"critical",
+			"critical",
+			&HealthStateQuery{
+				state: "critical",
+			},
+			false,
+		},
+		{
+			"any",
+			"any",
+			&HealthStateQuery{
+				state: "any",
+			},
+			false,
+		},
+		{
+			"dc",
+			"warning@dc1",
+			&HealthStateQuery{
+				state: "warning",
+				dc:    "dc1",
+			},
+			false,
+		},
+		{
+			"namespace",
+			"critical?ns=foo",
+			&HealthStateQuery{
+				state:     "critical",
+				namespace: "foo",
+			},
+			false,
+		},
+		{
+			"every_option",
+			"passing?ns=foo&partition=bar&stale@dc1",
+			&HealthStateQuery{
+				state:     "passing",
+				dc:        "dc1",
+				namespace: "foo",
+				partition: "bar",
+				readMode:  QueryStale,
+			},
+			false,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			act, err := NewHealthStateQuery(tc.i)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+
+			if act != nil {
+				act.stopCh = nil
+			}
+
+			assert.Equal(t, tc.exp, act)
+		})
+	}
+}
+
+func TestHealthStateQuery_Fetch(t *testing.T) {
+	var path, dc, ns string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		dc = r.URL.Query().Get("dc")
+		ns = r.URL.Query().Get("ns")
+		w.Header().Set("X-Consul-Index", "1")
+		fmt.Fprint(w, `[
+			{"Node": "node2", "CheckID": "service:web", "Name": "web check",
+			 "Status": "critical", "Output": "connection refused",
+			 "ServiceID": "web", "ServiceName": "web", "ServiceTags": ["b", "a"],
+			 "Type": "http"},
+			{"Node": "node1", "CheckID": "serfHealth", "Name": "Serf Health Status",
+			 "Status": "critical", "Output": "Agent not live or unreachable"}
+		]`)
+	}))
+	defer server.Close()
+
+	clients := NewClientSet()
+	if err := clients.CreateConsulClient(&CreateConsulClientInput{
+		Address: server.URL,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := NewHealthStateQuery("critical?ns=foo@dc1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	act, _, err := d.Fetch(clients, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "/v1/health/state/critical", path)
+	assert.Equal(t, "dc1", dc)
+	assert.Equal(t, "foo", ns)
+	assert.Equal(t, []*HealthCheck{
+		{
+			Node:        "node1",
+			CheckID:     "serfHealth",
+			Name:        "Serf Health Status",
+			Status:      "critical",
+			Output:      "Agent not live or unreachable",
+			ServiceTags: ServiceTags{},
+		},
+		{
+			Node:        "node2",
+			CheckID:     "service:web",
+			Name:        "web check",
+			Status:      "critical",
+			Output:      "connection refused",
+			ServiceID:   "web",
+			ServiceName: "web",
+			ServiceTags: ServiceTags{"a", "b"},
+			Type:        "http",
+		},
+	}, act)
+}
+
+func TestHealthStateQuery_String(t *testing.T) {
+	cases := []struct {
+		name string
+		i    string
+		exp  string
+	}{
+		{
+			"state",
+			"critical",
+			"health.state(critical)",
+		},
+		{
+			"datacenter",
+			"critical@dc1",
+			"health.state(critical@dc1)",
+		},
+		{
+			"every_option",
+			"critical?stale&partition=bar&ns=foo@dc1",
+			"health.state(critical?stale&ns=foo&partition=bar@dc1)",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			d, err := NewHealthStateQuery(tc.i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.exp, d.String())
+		})
+	}
+}
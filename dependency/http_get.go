@@ -0,0 +1,174 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dependency
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// Ensure implements
+	_ Dependency = (*HTTPGetQuery)(nil)
+
+	// HTTPGetQuerySleepTime is the amount of time to wait between polls of the
+	// endpoint.
+	HTTPGetQuerySleepTime = 30 * time.Second
+
+	// HTTPGetQueryRetryBackoff is the amount of time to wait before retrying a
+	// failed request. It doubles with each failure, up to HTTPGetQuerySleepTime.
+	HTTPGetQueryRetryBackoff = 1 * time.Second
+
+	// HTTPGetQueryClient is the client used to query the endpoints.
+	HTTPGetQueryClient = &http.Client{Timeout: 30 * time.Second}
+)
+
+// HTTPGetQuery represents a remote HTTP endpoint, polled for changes.
+type HTTPGetQuery struct {
+	stopCh chan struct{}
+
+	url      string
+	redacted string
+
+	// etag and lastModified are the validators of the last response, sent
+	// with the next request so an unchanged body is not downloaded again.
+	etag         string
+	lastModified string
+	fetched      bool
+}
+
+// NewHTTPGetQuery creates an HTTP dependency from the given http or https URL.
+func NewHTTPGetQuery(s string) (*HTTPGetQuery, error) {
+	s = strings.TrimSpace(s)
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("http.get: invalid format: %q", s)
+	}
+
+	return &HTTPGetQuery{
+		stopCh:   make(chan struct{}, 1),
+		url:      s,
+		redacted: u.Redacted(),
+	}, nil
+}
+
+// Fetch retrieves the body of the endpoint. The first call requests it right
+// away, later calls poll it until it changes. Failed requests and non-2xx
+// responses are retried with backoff instead of being returned, so templates
+// wait for the first body and keep the last one until the endpoint recovers.
+func (d *HTTPGetQuery) Fetch(clients *ClientSet, opts *QueryOptions) (interface{}, *ResponseMetadata, error) {
+	var wait time.Duration
+	if d.fetched {
+		wait = HTTPGetQuerySleepTime
+	}
+	backoff := HTTPGetQueryRetryBackoff
+
+	for {
+		select {
+		case <-d.stopCh:
+			log.Printf("[TRACE] %s: stopped", d)
+			return "", nil, ErrStopped
+		case <-time.After(wait):
+		}
+
+		body, changed, err := d.get()
+		if err != nil {
+			select {
+			case <-d.stopCh:
+				log.Printf("[TRACE] %s: stopped", d)
+				return "", nil, ErrStopped
+			default:
+			}
+		}
+
+		switch {
+		case err != nil:
+			log.Printf("[WARN] %s: %s (retry after %q)", d, err, backoff)
+			wait = backoff
+			backoff = min(2*backoff, HTTPGetQuerySleepTime)
+		case !changed:
+			log.Printf("[TRACE] %s: not modified", d)
+			wait = HTTPGetQuerySleepTime
+			backoff = HTTPGetQueryRetryBackoff
+		default:
+			log.Printf("[TRACE] %s: returned %d bytes", d, len(body))
+			d.fetched = true
+			return respWithMetadata(body)
+		}
+	}
+}
+
+// get requests the endpoint once, returning false if the body is unchanged
+// since the last request.
+func (d *HTTPGetQuery) get() (string, bool, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-d.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url, nil)
+	if err != nil {
+		return "", false, err
+	}
+	if d.etag != "" {
+		req.Header.Set("If-None-Match", d.etag)
+	}
+	if d.lastModified != "" {
+		req.Header.Set("If-Modified-Since", d.lastModified)
+	}
+
+	log.Printf("[TRACE] %s: GET %s", d, d.redacted)
+	resp, err := HTTPGetQueryClient.Do(req)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return "", false, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", false, fmt.Errorf("unexpected response code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", false, err
+	}
+
+	d.etag = resp.Header.Get("ETag")
+	d.lastModified = resp.Header.Get("Last-Modified")
+	return string(body), true, nil
+}
+
+// CanShare returns a boolean if this dependency is shareable.
+func (d *HTTPGetQuery) CanShare() bool {
+	return false
+}
+
+// Stop halts the dependency's fetch function.
+func (d *HTTPGetQuery) Stop() {
+	close(d.stopCh)
+}
+
+// String returns the human-friendly version of this dependency.
+func (d *HTTPGetQuery) String() string {
+	return fmt.Sprintf("http.get(%s)", d.redacted)
+}
+
+// Type returns the type of this dependency.
+func (d *HTTPGetQuery) Type() Type {
+	return TypeLocal
+}
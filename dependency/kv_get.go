@@ -8,6 +8,8 @@ import (
 	"log"
 	"net/url"
 	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 )
@@ -20,6 +22,9 @@ var (
 	KVGetQueryRe = regexp.MustCompile(`\A` + keyRe + queryRe + dcRe + `\z`)
 )
 
+// QueryIndex is the query param that pins a KV read to a Consul index.
+const QueryIndex = "index"
+
 // KVGetQuery queries the KV store for a single key.
 type KVGetQuery struct {
 	stopCh chan struct{}
@@ -29,6 +34,12 @@ type KVGetQuery struct {
 	blockOnNil bool
 	namespace  string
 	partition  string
+	readMode   string
+
+	// index pins the read to this Consul index, see Fetch. resolved records
+	// that the pinned value was returned.
+	index    uint64
+	resolved bool
 }
 
 // NewKVGetQuery parses a string into a dependency.
@@ -38,20 +49,42 @@ func NewKVGetQuery(s string) (*KVGetQuery, error) {
 	}
 
 	m := regexpMatch(KVGetQueryRe, s)
-	queryParams, err := GetConsulQueryOpts(m, "kv.get")
+	queryParams, err := GetConsulQueryOpts(m, "kv.get", QueryStale, QueryConsistent, QueryIndex)
 	if err != nil {
 		return nil, err
 	}
+
+	readMode, err := GetConsulReadMode(queryParams, "kv.get")
+	if err != nil {
+		return nil, err
+	}
+
+	var index uint64
+	if queryParams.Has(QueryIndex) {
+		index, err = strconv.ParseUint(queryParams.Get(QueryIndex), 10, 64)
+		if err != nil || index == 0 {
+			return nil, fmt.Errorf("kv.get: invalid index %q: must be a positive integer",
+				queryParams.Get(QueryIndex))
+		}
+	}
 	return &KVGetQuery{
 		stopCh:    make(chan struct{}, 1),
 		dc:        m["dc"],
 		key:       m["key"],
 		namespace: queryParams.Get(QueryNamespace),
 		partition: queryParams.Get(QueryPartition),
+		readMode:  readMode,
+		index:     index,
 	}, nil
 }
 
 // Fetch queries the Consul API defined by the given client.
+//
+// Consul only serves the current value of a key, so a read pinned to an index
+// cannot return historical data. The current value is returned when the key
+// was last modified at or before the index. Otherwise a warning is logged and
+// the current value is returned anyway. Either way, a pinned read never
+// watches for changes once it returned a value.
 func (d *KVGetQuery) Fetch(clients *ClientSet, opts *QueryOptions) (interface{}, *ResponseMetadata, error) {
 	select {
 	case <-d.stopCh:
@@ -59,11 +92,16 @@ func (d *KVGetQuery) Fetch(clients *ClientSet, opts *QueryOptions) (interface{},
 	default:
 	}
 
+	if d.resolved && opts != nil && opts.WaitIndex != 0 {
+		<-d.stopCh
+		return nil, nil, ErrStopped
+	}
+
 	opts = opts.Merge(&QueryOptions{
 		Datacenter:      d.dc,
 		ConsulPartition: d.partition,
 		ConsulNamespace: d.namespace,
-	})
+	}).setReadMode(d.readMode)
 
 	log.Printf("[TRACE] %s: GET %s", d, &url.URL{
 		Path:     "/v1/kv/" + d.key,
@@ -89,6 +127,15 @@ func (d *KVGetQuery) Fetch(clients *ClientSet, opts *QueryOptions) (interface{},
 		return nil, rm, nil
 	}
 
+	if d.index != 0 {
+		if pair.ModifyIndex > d.index {
+			log.Printf("[WARN] %s: Consul does not support point-in-time reads and "+
+				"the key was modified at index %d, after the requested index; "+
+				"using the current value", d, pair.ModifyIndex)
+		}
+		d.resolved = true
+	}
+
 	value := string(pair.Value)
 	log.Printf("[TRACE] %s: returned %q", d, value)
 	return value, rm, nil
@@ -107,6 +154,16 @@ func (d *KVGetQuery) CanShare() bool {
 // String returns the human-friendly version of this dependency.
 func (d *KVGetQuery) String() string {
 	key := d.key
+	var query []string
+	if d.readMode != "" {
+		query = append(query, d.readMode)
+	}
+	if d.index != 0 {
+		query = append(query, QueryIndex+"="+strconv.FormatUint(d.index, 10))
+	}
+	if len(query) > 0 {
+		key = key + "?" + strings.Join(query, "&")
+	}
 	if d.dc != "" {
 		key = key + "@" + d.dc
 	}
@@ -5,6 +5,10 @@ package dependency
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
 	"testing"
 	"time"
 
@@ -51,6 +55,38 @@ func TestNewKVGetQuery(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"stale",
+			"key?stale",
+			&KVGetQuery{
+				key:      "key",
+				readMode: "stale",
+			},
+			false,
+		},
+		{
+			"consistent",
+			"key?consistent&ns=foo@dc1",
+			&KVGetQuery{
+				key:       "key",
+				dc:        "dc1",
+				namespace: "foo",
+				readMode:  "consistent",
+			},
+			false,
+		},
+		{
+			"stale_and_consistent",
+			"key?stale&consistent",
+			nil,
+			true,
+		},
+		{
+			"stale_with_value",
+			"key?stale=false",
+			nil,
+			true,
+		},
 		{
 			"dc",
 			"key@dc1",
@@ -60,6 +96,44 @@ func TestNewKVGetQuery(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"index",
+			"key?index=1234",
+			&KVGetQuery{
+				key:   "key",
+				index: 1234,
+			},
+			false,
+		},
+		{
+			"index_and_read_mode",
+			"key?consistent&index=1234@dc1",
+			&KVGetQuery{
+				key:      "key",
+				dc:       "dc1",
+				readMode: "consistent",
+				index:    1234,
+			},
+			false,
+		},
+		{
+			"index_zero",
+			"key?index=0",
+			nil,
+			true,
+		},
+		{
+			"index_not_a_number",
+			"key?index=latest",
+			nil,
+			true,
+		},
+		{
+			"index_empty",
+			"key?index",
+			nil,
+			true,
+		},
 		{
 			"partition",
 			"key?partition=foo",
@@ -233,6 +307,23 @@ func TestKVGetQuery_Fetch(t *testing.T) {
 		})
 	}
 
+	// The read mode of the dependency overrides the stale and consistent
+	// options passed in by the view.
+	t.Run("read_mode_overrides_opts", func(t *testing.T) {
+		for _, q := range []string{"test-kv-get/key?consistent", "test-kv-get/key?stale"} {
+			d, err := NewKVGetQuery(q)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			act, _, err := d.Fetch(testClients, &QueryOptions{AllowStale: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, "value", act)
+		}
+	})
+
 	t.Run("stops", func(t *testing.T) {
 		d, err := NewKVGetQuery("test-kv-get/key")
 		if err != nil {
@@ -303,6 +394,75 @@ func TestKVGetQuery_Fetch(t *testing.T) {
 	})
 }
 
+func TestKVGetQuery_Fetch_index(t *testing.T) {
+	// The key was last modified at index 10.
+	var mu sync.Mutex
+	var requests []url.Values
+	received := func() []url.Values {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.URL.Query())
+		mu.Unlock()
+		w.Header().Set("X-Consul-Index", "12")
+		fmt.Fprint(w, `[{"Key":"key","Value":"dmFsdWU=","CreateIndex":5,"ModifyIndex":10}]`)
+	}))
+	defer server.Close()
+
+	clients := NewClientSet()
+	if err := clients.CreateConsulClient(&CreateConsulClientInput{
+		Address: server.URL,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, index := range []string{"10", "8"} {
+		t.Run(index, func(t *testing.T) {
+			mu.Lock()
+			requests = nil
+			mu.Unlock()
+			d, err := NewKVGetQuery("key?index=" + index)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Before or after the modification, the current value is returned.
+			act, rm, err := d.Fetch(clients, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, "value", act)
+			assert.Len(t, received(), 1)
+			assert.Empty(t, received()[0].Get("index"))
+
+			// The read does not block for later changes.
+			errCh := make(chan error, 1)
+			go func() {
+				_, _, err := d.Fetch(clients, &QueryOptions{WaitIndex: rm.LastIndex})
+				errCh <- err
+			}()
+
+			select {
+			case err := <-errCh:
+				t.Fatalf("expected the pinned read to wait, got %v", err)
+			case <-time.After(50 * time.Millisecond):
+			}
+			assert.Len(t, received(), 1)
+
+			d.Stop()
+			select {
+			case err := <-errCh:
+				assert.Equal(t, ErrStopped, err)
+			case <-time.After(time.Second):
+				t.Fatal("did not stop")
+			}
+		})
+	}
+}
+
 func TestKVGetQuery_String(t *testing.T) {
 	cases := []struct {
 		name string
@@ -319,6 +479,21 @@ func TestKVGetQuery_String(t *testing.T) {
 			"key@dc1",
 			"kv.get(key@dc1)",
 		},
+		{
+			"read_mode",
+			"key?consistent@dc1",
+			"kv.get(key?consistent@dc1)",
+		},
+		{
+			"index",
+			"key?index=1234",
+			"kv.get(key?index=1234)",
+		},
+		{
+			"index_and_read_mode",
+			"key?index=1234&stale@dc1",
+			"kv.get(key?stale&index=1234@dc1)",
+		},
 	}
 
 	for i, tc := range cases {
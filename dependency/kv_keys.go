@@ -29,6 +29,7 @@ type KVKeysQuery struct {
 	prefix    string
 	namespace string
 	partition string
+	readMode  string
 }
 
 // NewKVKeysQuery parses a string into a dependency.
@@ -38,7 +39,12 @@ func NewKVKeysQuery(s string) (*KVKeysQuery, error) {
 	}
 
 	m := regexpMatch(KVKeysQueryRe, s)
-	queryParams, err := GetConsulQueryOpts(m, "kv.keys")
+	queryParams, err := GetConsulQueryOpts(m, "kv.keys", QueryStale, QueryConsistent)
+	if err != nil {
+		return nil, err
+	}
+
+	readMode, err := GetConsulReadMode(queryParams, "kv.keys")
 	if err != nil {
 		return nil, err
 	}
@@ -49,6 +55,7 @@ func NewKVKeysQuery(s string) (*KVKeysQuery, error) {
 		prefix:    m["prefix"],
 		namespace: queryParams.Get(QueryNamespace),
 		partition: queryParams.Get(QueryPartition),
+		readMode:  readMode,
 	}, nil
 }
 
@@ -64,7 +71,7 @@ func (d *KVKeysQuery) Fetch(clients *ClientSet, opts *QueryOptions) (interface{}
 		Datacenter:      d.dc,
 		ConsulPartition: d.partition,
 		ConsulNamespace: d.namespace,
-	})
+	}).setReadMode(d.readMode)
 
 	log.Printf("[TRACE] %s: GET %s", d, &url.URL{
 		Path:     "/v1/kv/" + d.prefix,
@@ -101,6 +108,9 @@ func (d *KVKeysQuery) CanShare() bool {
 // String returns the human-friendly version of this dependency.
 func (d *KVKeysQuery) String() string {
 	prefix := d.prefix
+	if d.readMode != "" {
+		prefix = prefix + "?" + d.readMode
+	}
 	if d.dc != "" {
 		prefix = prefix + "@" + d.dc
 	}
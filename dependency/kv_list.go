@@ -48,6 +48,7 @@ type KVListQuery struct {
 	prefix    string
 	namespace string
 	partition string
+	readMode  string
 }
 
 // NewKVListQuery parses a string into a dependency.
@@ -57,7 +58,12 @@ func NewKVListQuery(s string) (*KVListQuery, error) {
 	}
 
 	m := regexpMatch(KVListQueryRe, s)
-	queryParams, err := GetConsulQueryOpts(m, "kv.list")
+	queryParams, err := GetConsulQueryOpts(m, "kv.list", QueryStale, QueryConsistent)
+	if err != nil {
+		return nil, err
+	}
+
+	readMode, err := GetConsulReadMode(queryParams, "kv.list")
 	if err != nil {
 		return nil, err
 	}
@@ -68,6 +74,7 @@ func NewKVListQuery(s string) (*KVListQuery, error) {
 		prefix:    m["prefix"],
 		namespace: queryParams.Get(QueryNamespace),
 		partition: queryParams.Get(QueryPartition),
+		readMode:  readMode,
 	}, nil
 }
 
@@ -83,7 +90,7 @@ func (d *KVListQuery) Fetch(clients *ClientSet, opts *QueryOptions) (interface{}
 		Datacenter:      d.dc,
 		ConsulPartition: d.partition,
 		ConsulNamespace: d.namespace,
-	})
+	}).setReadMode(d.readMode)
 
 	log.Printf("[TRACE] %s: GET %s", d, &url.URL{
 		Path:     "/v1/kv/" + d.prefix,
@@ -130,6 +137,9 @@ func (d *KVListQuery) CanShare() bool {
 // String returns the human-friendly version of this dependency.
 func (d *KVListQuery) String() string {
 	prefix := d.prefix
+	if d.readMode != "" {
+		prefix = prefix + "?" + d.readMode
+	}
 	if d.dc != "" {
 		prefix = prefix + "@" + d.dc
 	}
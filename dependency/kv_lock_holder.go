@@ -0,0 +1,143 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dependency
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// Ensure implements
+	_ Dependency = (*KVLockHolderQuery)(nil)
+
+	// KVLockHolderQueryRe is the regular expression to use.
+	KVLockHolderQueryRe = regexp.MustCompile(`\A` + keyRe + queryRe + dcRe + `\z`)
+)
+
+// KVLockHolderQuery queries the KV store for the node holding the lock on a
+// single key.
+type KVLockHolderQuery struct {
+	stopCh chan struct{}
+
+	dc        string
+	key       string
+	namespace string
+	partition string
+}
+
+// NewKVLockHolderQuery parses a string into a dependency.
+func NewKVLockHolderQuery(s string) (*KVLockHolderQuery, error) {
+	if s != "" && !KVLockHolderQueryRe.MatchString(s) {
+		return nil, fmt.Errorf("kv.lockholder: invalid format: %q", s)
+	}
+
+	m := regexpMatch(KVLockHolderQueryRe, s)
+	queryParams, err := GetConsulQueryOpts(m, "kv.lockholder")
+	if err != nil {
+		return nil, err
+	}
+
+	return &KVLockHolderQuery{
+		stopCh:    make(chan struct{}, 1),
+		dc:        m["dc"],
+		key:       m["key"],
+		namespace: queryParams.Get(QueryNamespace),
+		partition: queryParams.Get(QueryPartition),
+	}, nil
+}
+
+// Fetch queries the Consul API defined by the given client and returns the
+// name of the node whose session holds the lock on the key, or an empty
+// string if the key does not exist or is not locked.
+//
+// The query blocks on the key. Acquiring or releasing the lock, including by
+// the session being invalidated, modifies the key, so a change of holder is
+// always seen.
+func (d *KVLockHolderQuery) Fetch(clients *ClientSet, opts *QueryOptions) (interface{}, *ResponseMetadata, error) {
+	select {
+	case <-d.stopCh:
+		return nil, nil, ErrStopped
+	default:
+	}
+
+	opts = opts.Merge(&QueryOptions{
+		Datacenter:      d.dc,
+		ConsulPartition: d.partition,
+		ConsulNamespace: d.namespace,
+	})
+
+	log.Printf("[TRACE] %s: GET %s", d, &url.URL{
+		Path:     "/v1/kv/" + d.key,
+		RawQuery: opts.String(),
+	})
+
+	pair, qm, err := clients.Consul().KV().Get(d.key, opts.ToConsulOpts())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, d.String())
+	}
+
+	rm := &ResponseMetadata{
+		LastIndex:   qm.LastIndex,
+		LastContact: qm.LastContact,
+	}
+
+	if pair == nil || pair.Session == "" {
+		log.Printf("[TRACE] %s: not locked", d)
+		return "", rm, nil
+	}
+
+	// The session is looked up once the key changed, so it must not block.
+	sessionOpts := opts.ToConsulOpts()
+	sessionOpts.WaitIndex = 0
+	sessionOpts.WaitTime = 0
+
+	log.Printf("[TRACE] %s: GET %s", d, &url.URL{
+		Path:     "/v1/session/info/" + pair.Session,
+		RawQuery: opts.String(),
+	})
+
+	session, _, err := clients.Consul().Session().Info(pair.Session, sessionOpts)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, d.String())
+	}
+
+	// The session may have been invalidated since the key was read, in which
+	// case the key is modified again and fetched with its new holder.
+	if session == nil {
+		log.Printf("[TRACE] %s: session %s no longer exists", d, pair.Session)
+		return "", rm, nil
+	}
+
+	log.Printf("[TRACE] %s: returned %q", d, session.Node)
+	return session.Node, rm, nil
+}
+
+// CanShare returns a boolean if this dependency is shareable.
+func (d *KVLockHolderQuery) CanShare() bool {
+	return true
+}
+
+// String returns the human-friendly version of this dependency.
+func (d *KVLockHolderQuery) String() string {
+	key := d.key
+	if d.dc != "" {
+		key = key + "@" + d.dc
+	}
+	return fmt.Sprintf("kv.lockholder(%s)", key)
+}
+
+// Stop halts the dependency's fetch function.
+func (d *KVLockHolderQuery) Stop() {
+	close(d.stopCh)
+}
+
+// Type returns the type of this dependency.
+func (d *KVLockHolderQuery) Type() Type {
+	return TypeConsul
+}
@@ -0,0 +1,172 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dependency
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewKVLockHolderQuery(t *testing.T) {
+	cases := []struct {
+		name string
+		i    string
+		exp  *KVLockHolderQuery
+		err  bool
+	}{
+		{
+			"empty",
+			"",
+			&KVLockHolderQuery{},
+			false,
+		},
+		{
+			"dc_only",
+			"@dc1",
+			nil,
+			true,
+		},
+		{
+			"invalid query param (unsupported key)",
+			"key?stale",
+			nil,
+			true,
+		},
+		{
+			"key",
+			"service/web/leader",
+			&KVLockHolderQuery{
+				key: "service/web/leader",
+			},
+			false,
+		},
+		{
+			"dc",
+			"service/web/leader@dc1",
+			&KVLockHolderQuery{
+				key: "service/web/leader",
+				dc:  "dc1",
+			},
+			false,
+		},
+		{
+			"namespace",
+			"service/web/leader?ns=foo",
+			&KVLockHolderQuery{
+				key:       "service/web/leader",
+				namespace: "foo",
+			},
+			false,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			act, err := NewKVLockHolderQuery(tc.i)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+
+			if act != nil {
+				act.stopCh = nil
+			}
+
+			assert.Equal(t, tc.exp, act)
+		})
+	}
+}
+
+func TestKVLockHolderQuery_Fetch(t *testing.T) {
+	testConsul.SetKVString(t, "test-kv-lock-holder/unlocked", "value")
+
+	session, _, err := testClients.Consul().Session().Create(&api.SessionEntry{
+		Name: "test-kv-lock-holder",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer testClients.Consul().Session().Destroy(session, nil)
+
+	acquired, _, err := testClients.Consul().KV().Acquire(&api.KVPair{
+		Key:     "test-kv-lock-holder/leader",
+		Value:   []byte("value"),
+		Session: session,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !acquired {
+		t.Fatal("expected the lock to be acquired")
+	}
+
+	cases := []struct {
+		name string
+		i    string
+		exp  interface{}
+	}{
+		{
+			"locked",
+			"test-kv-lock-holder/leader",
+			testConsul.Config.NodeName,
+		},
+		{
+			"unlocked",
+			"test-kv-lock-holder/unlocked",
+			"",
+		},
+		{
+			"no_exist",
+			"test-kv-lock-holder/not/a/real/key/like/ever",
+			"",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			d, err := NewKVLockHolderQuery(tc.i)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			act, _, err := d.Fetch(testClients, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tc.exp, act)
+		})
+	}
+}
+
+func TestKVLockHolderQuery_String(t *testing.T) {
+	cases := []struct {
+		name string
+		i    string
+		exp  string
+	}{
+		{
+			"key",
+			"service/web/leader",
+			"kv.lockholder(service/web/leader)",
+		},
+		{
+			"dc",
+			"service/web/leader@dc1",
+			"kv.lockholder(service/web/leader@dc1)",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			d, err := NewKVLockHolderQuery(tc.i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.exp, d.String())
+		})
+	}
+}
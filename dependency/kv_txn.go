@@ -0,0 +1,189 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dependency
+
+import (
+	"encoding/gob"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/pkg/errors"
+)
+
+var (
+	// Ensure implements
+	_ Dependency = (*KVTxnQuery)(nil)
+
+	// KVTxnQueryRe is the regular expression to use for each key.
+	KVTxnQueryRe = regexp.MustCompile(`\A` + keyRe + dcRe + `\z`)
+
+	// KVTxnQuerySleepTime is the amount of time to sleep between queries,
+	// since transactions do not support blocking queries.
+	KVTxnQuerySleepTime = 15 * time.Second
+)
+
+func init() {
+	gob.Register(map[string]string{})
+}
+
+// kvTxnMaxKeys is the maximum number of operations Consul accepts in a single
+// transaction.
+const kvTxnMaxKeys = 64
+
+// KVTxnQuery queries the KV store for several keys at once, in a single
+// read-only transaction, so that all the values are read at the same index.
+type KVTxnQuery struct {
+	stopCh chan struct{}
+
+	dc   string
+	keys []string
+}
+
+// NewKVTxnQuery parses the keys into a dependency. Each key may name a
+// datacenter with "@<DATACENTER>", but all the keys must be in the same one.
+// The keys are sorted and duplicates removed, so that any order of the same
+// keys is the same query.
+func NewKVTxnQuery(keys []string) (*KVTxnQuery, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("kv.txn: no keys given")
+	}
+
+	var dc string
+	set := make(map[string]struct{}, len(keys))
+	for i, s := range keys {
+		if !KVTxnQueryRe.MatchString(s) {
+			return nil, fmt.Errorf("kv.txn: invalid format: %q", s)
+		}
+
+		m := regexpMatch(KVTxnQueryRe, s)
+		if i > 0 && m["dc"] != dc {
+			return nil, fmt.Errorf("kv.txn: keys must be in the same datacenter: %q", s)
+		}
+		dc = m["dc"]
+		set[m["key"]] = struct{}{}
+	}
+	if len(set) > kvTxnMaxKeys {
+		return nil, fmt.Errorf("kv.txn: too many keys, at most %d are supported", kvTxnMaxKeys)
+	}
+
+	sorted := make([]string, 0, len(set))
+	for k := range set {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	return &KVTxnQuery{
+		stopCh: make(chan struct{}, 1),
+		dc:     dc,
+		keys:   sorted,
+	}, nil
+}
+
+// Fetch queries the Consul API defined by the given client and returns a map
+// of the values of the keys, keyed by path. Keys that do not exist are absent
+// from the map.
+func (d *KVTxnQuery) Fetch(clients *ClientSet, opts *QueryOptions) (interface{}, *ResponseMetadata, error) {
+	select {
+	case <-d.stopCh:
+		return nil, nil, ErrStopped
+	default:
+	}
+
+	opts = opts.Merge(&QueryOptions{
+		Datacenter: d.dc,
+	})
+
+	// Transactions do not support blocking queries, so like the datacenters
+	// query, poll once a LastIndex is known.
+	if opts.WaitIndex != 0 {
+		log.Printf("[TRACE] %s: long polling for %s", d, KVTxnQuerySleepTime)
+
+		select {
+		case <-d.stopCh:
+			return nil, nil, ErrStopped
+		case <-time.After(KVTxnQuerySleepTime):
+		}
+	}
+
+	consulOpts := opts.ToConsulOpts()
+	consulOpts.WaitIndex = 0
+	consulOpts.WaitTime = 0
+
+	log.Printf("[TRACE] %s: PUT %s", d, &url.URL{
+		Path:     "/v1/txn",
+		RawQuery: opts.String(),
+	})
+
+	// A "get" fails the whole transaction when the key does not exist, while a
+	// "get-or-empty" returns the key without any indexes instead.
+	ops := make(api.KVTxnOps, 0, len(d.keys))
+	for _, key := range d.keys {
+		ops = append(ops, &api.KVTxnOp{
+			Verb: api.KVGetOrEmpty,
+			Key:  key,
+		})
+	}
+
+	ok, resp, qm, err := clients.Consul().KV().Txn(ops, consulOpts)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, d.String())
+	}
+	if !ok {
+		var errs []string
+		for _, e := range resp.Errors {
+			errs = append(errs, e.What)
+		}
+		return nil, nil, fmt.Errorf("%s: transaction rolled back: %s",
+			d, strings.Join(errs, ", "))
+	}
+
+	values := make(map[string]string, len(resp.Results))
+	for _, pair := range resp.Results {
+		if pair == nil || pair.CreateIndex == 0 {
+			continue
+		}
+		values[pair.Key] = string(pair.Value)
+	}
+
+	log.Printf("[TRACE] %s: returned %d of %d keys", d, len(values), len(d.keys))
+
+	// Read-only transactions do not return an index, so the time of the read
+	// takes its place, like for the other polled queries.
+	rm := &ResponseMetadata{
+		LastIndex:   uint64(time.Now().Unix()),
+		LastContact: qm.LastContact,
+	}
+
+	return values, rm, nil
+}
+
+// CanShare returns a boolean if this dependency is shareable.
+func (d *KVTxnQuery) CanShare() bool {
+	return true
+}
+
+// String returns the human-friendly version of this dependency.
+func (d *KVTxnQuery) String() string {
+	keys := strings.Join(d.keys, ",")
+	if d.dc != "" {
+		keys = keys + "@" + d.dc
+	}
+	return fmt.Sprintf("kv.txn(%s)", keys)
+}
+
+// Stop halts the dependency's fetch function.
+func (d *KVTxnQuery) Stop() {
+	close(d.stopCh)
+}
+
+// Type returns the type of this dependency.
+func (d *KVTxnQuery) Type() Type {
+	return TypeConsul
+}
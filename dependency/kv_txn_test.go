@@ -0,0 +1,230 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dependency
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewKVTxnQuery(t *testing.T) {
+	cases := []struct {
+		name string
+		i    []string
+		exp  *KVTxnQuery
+		err  bool
+	}{
+		{
+			"empty",
+			nil,
+			nil,
+			true,
+		},
+		{
+			"dc_only",
+			[]string{"@dc1"},
+			nil,
+			true,
+		},
+		{
+			"query",
+			[]string{"key?stale"},
+			nil,
+			true,
+		},
+		{
+			"keys",
+			[]string{"b", "/a", "b"},
+			&KVTxnQuery{
+				keys: []string{"a", "b"},
+			},
+			false,
+		},
+		{
+			"dc",
+			[]string{"a@dc1", "b@dc1"},
+			&KVTxnQuery{
+				dc:   "dc1",
+				keys: []string{"a", "b"},
+			},
+			false,
+		},
+		{
+			"different_dc",
+			[]string{"a@dc1", "b"},
+			nil,
+			true,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			act, err := NewKVTxnQuery(tc.i)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+
+			if act != nil {
+				act.stopCh = nil
+			}
+
+			assert.Equal(t, tc.exp, act)
+		})
+	}
+
+	t.Run("too_many_keys", func(t *testing.T) {
+		keys := make([]string, kvTxnMaxKeys+1)
+		for i := range keys {
+			keys[i] = "key" + strconv.Itoa(i)
+		}
+		if _, err := NewKVTxnQuery(keys); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestKVTxnQuery_Fetch(t *testing.T) {
+	testConsul.SetKVString(t, "test-kv-txn/a", "0")
+	testConsul.SetKVString(t, "test-kv-txn/b", "0")
+
+	d, err := NewKVTxnQuery([]string{
+		"test-kv-txn/a",
+		"test-kv-txn/b",
+		"test-kv-txn/not/a/real/key/like/ever",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both keys are always written together, so every consistent read sees
+	// the same value for them.
+	doneCh := make(chan struct{})
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		for i := 1; ; i++ {
+			select {
+			case <-doneCh:
+				return
+			default:
+			}
+
+			value := []byte(strconv.Itoa(i))
+			ok, _, _, err := testClients.Consul().KV().Txn(api.KVTxnOps{
+				{Verb: api.KVSet, Key: "test-kv-txn/a", Value: value},
+				{Verb: api.KVSet, Key: "test-kv-txn/b", Value: value},
+			}, nil)
+			if err != nil || !ok {
+				errCh <- fmt.Errorf("write %d failed: %v", i, err)
+				return
+			}
+		}
+	}()
+	defer close(doneCh)
+
+	for i := 0; i < 50; i++ {
+		act, _, err := d.Fetch(testClients, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		values := act.(map[string]string)
+		if _, ok := values["test-kv-txn/not/a/real/key/like/ever"]; ok || len(values) != 2 {
+			t.Fatalf("expected only the existing keys, got %v", values)
+		}
+		if values["test-kv-txn/a"] != values["test-kv-txn/b"] {
+			t.Fatalf("expected the keys to be read at the same index, got %v", values)
+		}
+	}
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+	}
+}
+
+func TestKVTxnQuery_Fetch_missing(t *testing.T) {
+	var ops []map[string]map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/v1/txn" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// A "get-or-empty" of a missing key returns it without indexes.
+		fmt.Fprint(w, `{"Results":[`+
+			`{"KV":{"Key":"a","Value":"dmFsdWU=","CreateIndex":5,"ModifyIndex":10}},`+
+			`{"KV":{"Key":"b","Value":null,"CreateIndex":0,"ModifyIndex":0}}`+
+			`],"Errors":null}`)
+	}))
+	defer server.Close()
+
+	clients := NewClientSet()
+	if err := clients.CreateConsulClient(&CreateConsulClientInput{
+		Address: server.URL,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := NewKVTxnQuery([]string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	act, _, err := d.Fetch(clients, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]string{"a": "value"}, act)
+
+	if assert.Len(t, ops, 2) {
+		for i, key := range []string{"a", "b"} {
+			assert.Equal(t, string(api.KVGetOrEmpty), ops[i]["KV"]["Verb"])
+			assert.Equal(t, key, ops[i]["KV"]["Key"])
+		}
+	}
+}
+
+func TestKVTxnQuery_String(t *testing.T) {
+	cases := []struct {
+		name string
+		i    []string
+		exp  string
+	}{
+		{
+			"keys",
+			[]string{"b", "a"},
+			"kv.txn(a,b)",
+		},
+		{
+			"dc",
+			[]string{"a@dc1", "b@dc1"},
+			"kv.txn(a,b@dc1)",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			d, err := NewKVTxnQuery(tc.i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.exp, d.String())
+		})
+	}
+}
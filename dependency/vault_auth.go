@@ -0,0 +1,173 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dependency
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+)
+
+// Ensure implements
+var _ Dependency = (*VaultAuthQuery)(nil)
+
+// VaultAuth is an auth method to log in to Vault with.
+type VaultAuth struct {
+	// Method is the type of the auth method, like "approle".
+	Method string
+
+	// Mount is the path the auth method is mounted at. It defaults to the
+	// method.
+	Mount string
+
+	// Config are the parameters of the login request. A parameter with the
+	// "_file" suffix is read from that file and sent without the suffix.
+	Config map[string]string
+}
+
+// vaultLogin logs in with the given auth method and returns the secret
+// holding the new token.
+func vaultLogin(client *api.Client, a *VaultAuth) (*api.Secret, error) {
+	mount := a.Mount
+	if mount == "" {
+		mount = a.Method
+	}
+	if mount == "" {
+		return nil, fmt.Errorf("vault auth: missing method")
+	}
+
+	// The parameters are read on every login, so a rotated secret_id or
+	// service account token in a file is picked up.
+	data := make(map[string]interface{}, len(a.Config))
+	for k, v := range a.Config {
+		if name, ok := strings.CutSuffix(k, "_file"); ok {
+			b, err := os.ReadFile(v)
+			if err != nil {
+				return nil, fmt.Errorf("vault auth: %s: %w", a.Method, err)
+			}
+			data[name] = strings.TrimSpace(string(b))
+			continue
+		}
+		data[k] = v
+	}
+
+	path := "auth/" + strings.Trim(mount, "/") + "/login"
+	log.Printf("[DEBUG] (vault auth) logging in to %s", path)
+
+	secret, err := client.Logical().WriteWithContext(context.TODO(), path, data)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("vault auth: %s: login: %w", a.Method, err)
+	case secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "":
+		return nil, fmt.Errorf("vault auth: %s: login: no token returned", a.Method)
+	}
+	return secret, nil
+}
+
+// VaultAuthQuery is the dependency that keeps the token obtained by logging
+// in with an auth method alive. It renews the token on its lease schedule and
+// logs in again for a new token once it can no longer be renewed. It never
+// returns data, only errors when logging in again fails.
+type VaultAuthQuery struct {
+	stopCh      chan struct{}
+	secret      *Secret
+	vaultSecret *api.Secret
+}
+
+// NewVaultAuthQuery creates a new dependency for the given login result. If
+// secret is nil, the first fetch logs in.
+func NewVaultAuthQuery(secret *api.Secret) (*VaultAuthQuery, error) {
+	d := &VaultAuthQuery{
+		stopCh: make(chan struct{}, 1),
+	}
+	if secret != nil {
+		d.setSecret(secret)
+	}
+	return d, nil
+}
+
+// Fetch renews the token until it can no longer be renewed, then logs in
+// again with the auth method of the clients.
+func (d *VaultAuthQuery) Fetch(clients *ClientSet, opts *QueryOptions,
+) (interface{}, *ResponseMetadata, error) {
+	for {
+		select {
+		case <-d.stopCh:
+			return nil, nil, ErrStopped
+		default:
+		}
+
+		if d.vaultSecret == nil {
+			secret, err := clients.VaultLogin()
+			if err != nil {
+				return nil, nil, errors.Wrap(err, d.String())
+			}
+			log.Printf("[INFO] %s: logged in with a new token", d)
+			d.setSecret(secret)
+		}
+
+		if err := d.renew(clients.Vault()); err != nil {
+			return nil, nil, err
+		}
+
+		log.Printf("[WARN] %s: token can no longer be renewed, logging in again", d)
+		d.secret, d.vaultSecret = nil, nil
+	}
+}
+
+// renew renews the token until its lease is close to expiring, or waits for
+// that if the token is not renewable.
+func (d *VaultAuthQuery) renew(client *api.Client) error {
+	if vaultSecretRenewable(d.secret) {
+		return renewSecret(client, d)
+	}
+
+	dur := leaseCheckWait(d.secret)
+	log.Printf("[TRACE] %s: token is not renewable, logging in again in %s", d, dur)
+	select {
+	case <-d.stopCh:
+		return ErrStopped
+	case <-time.After(dur):
+		return nil
+	}
+}
+
+func (d *VaultAuthQuery) setSecret(secret *api.Secret) {
+	d.vaultSecret = secret
+	d.secret = transformSecret(secret)
+}
+
+func (d *VaultAuthQuery) stopChan() chan struct{} {
+	return d.stopCh
+}
+
+func (d *VaultAuthQuery) secrets() (*Secret, *api.Secret) {
+	return d.secret, d.vaultSecret
+}
+
+// CanShare returns if this dependency is shareable.
+func (d *VaultAuthQuery) CanShare() bool {
+	return false
+}
+
+// Stop halts the dependency's fetch function.
+func (d *VaultAuthQuery) Stop() {
+	close(d.stopCh)
+}
+
+// String returns the human-friendly version of this dependency.
+func (d *VaultAuthQuery) String() string {
+	return "vault.auth"
+}
+
+// Type returns the type of this dependency.
+func (d *VaultAuthQuery) Type() Type {
+	return TypeVault
+}
@@ -0,0 +1,96 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package dependency
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVaultLogin(t *testing.T) {
+	var path string
+	var data map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&data)
+		if data["role_id"] == "no_token" {
+			fmt.Fprint(w, `{"auth":null}`)
+			return
+		}
+		fmt.Fprint(w, `{"auth":{"client_token":"s.token","renewable":true,"lease_duration":60}}`)
+	}))
+	defer srv.Close()
+
+	clients := NewClientSet()
+	if err := clients.CreateVaultClient(&CreateVaultClientInput{
+		Address: srv.URL,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("default_mount", func(t *testing.T) {
+		secret, err := vaultLogin(clients.Vault(), &VaultAuth{
+			Method: "approle",
+			Config: map[string]string{"role_id": "role"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path != "/v1/auth/approle/login" {
+			t.Errorf("expected the approle login path, got %q", path)
+		}
+		if secret.Auth.ClientToken != "s.token" {
+			t.Errorf("expected token %q, got %q", "s.token", secret.Auth.ClientToken)
+		}
+	})
+
+	t.Run("file_param", func(t *testing.T) {
+		f := filepath.Join(t.TempDir(), "secret-id")
+		if err := os.WriteFile(f, []byte("secret\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := vaultLogin(clients.Vault(), &VaultAuth{
+			Method: "approle",
+			Mount:  "ci/",
+			Config: map[string]string{"role_id": "role", "secret_id_file": f},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if path != "/v1/auth/ci/login" {
+			t.Errorf("expected the ci login path, got %q", path)
+		}
+		if data["secret_id"] != "secret" {
+			t.Errorf("expected the secret_id from the file, got %q", data)
+		}
+		if _, ok := data["secret_id_file"]; ok {
+			t.Errorf("expected the file param not to be sent, got %q", data)
+		}
+	})
+
+	t.Run("missing_file", func(t *testing.T) {
+		_, err := vaultLogin(clients.Vault(), &VaultAuth{
+			Method: "approle",
+			Config: map[string]string{"secret_id_file": "/nope"},
+		})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("no_token", func(t *testing.T) {
+		_, err := vaultLogin(clients.Vault(), &VaultAuth{
+			Method: "approle",
+			Config: map[string]string{"role_id": "no_token"},
+		})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
+	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -20,6 +22,8 @@ var (
 	onceVaultDefaultLeaseDuration  sync.Once
 	VaultLeaseRenewalThreshold     float64
 	onceVaultLeaseRenewalThreshold sync.Once
+	VaultPKIRenewThreshold         float64
+	onceVaultPKIRenewThreshold     sync.Once
 )
 
 // Secret is the structure returned for every secret within Vault.
@@ -68,20 +72,56 @@ type SecretWrapInfo struct {
 	Token           string
 	TTL             int
 	CreationTime    time.Time
+	CreationPath    string
 	WrappedAccessor string
 }
 
+// QueryVaultNamespace is the vault.read and vault.list query param used to set
+// the Vault Enterprise namespace for a single query, overriding the namespace
+// configured on the client.
+const QueryVaultNamespace = "namespace"
+
+// vaultNamespaceClient returns the Vault client to use for a query in the given
+// namespace. An empty namespace uses the client's configured default.
+func vaultNamespaceClient(clients *ClientSet, namespace string) *api.Client {
+	client := clients.Vault()
+	if namespace != "" {
+		client = client.WithNamespace(namespace)
+	}
+	return client
+}
+
+// vaultHeadersClient returns a copy of the client that also sends the given
+// headers, or the client itself if there are none.
+func vaultHeadersClient(client *api.Client, headers http.Header) *api.Client {
+	if len(headers) == 0 {
+		return client
+	}
+
+	// WithNamespace returns a copy of the client with its own headers.
+	client = client.WithNamespace(client.Namespace())
+	h := client.Headers()
+	if h == nil {
+		h = make(http.Header, len(headers))
+	}
+	for k, v := range headers {
+		h[k] = v
+	}
+	client.SetHeaders(h)
+	return client
+}
+
 type renewer interface {
 	Dependency
 	stopChan() chan struct{}
 	secrets() (*Secret, *api.Secret)
 }
 
-func renewSecret(clients *ClientSet, d renewer) error {
+func renewSecret(client *api.Client, d renewer) error {
 	log.Printf("[TRACE] %s: starting renewer", d)
 
 	secret, vaultSecret := d.secrets()
-	renewer, err := clients.Vault().NewLifetimeWatcher(&api.LifetimeWatcherInput{
+	renewer, err := client.NewLifetimeWatcher(&api.LifetimeWatcherInput{
 		Secret:        vaultSecret,
 		RenewBehavior: api.RenewBehaviorErrorOnErrors,
 	})
@@ -152,6 +192,12 @@ func leaseCheckWait(s *Secret) time.Duration {
 		}
 	}
 
+	// Handle if this is a response-wrapped secret, whose wrapping token is only
+	// valid for its own TTL
+	if s.WrapInfo != nil && s.WrapInfo.TTL > 0 && s.LeaseID == "" {
+		base = s.WrapInfo.TTL
+	}
+
 	// Ensure we have a lease duration, since sometimes this can be zero.
 	if base <= 0 {
 		base = int(VaultDefaultLeaseDuration.Seconds())
@@ -288,13 +334,67 @@ func updateSecret(ours *Secret, theirs *api.Secret) {
 			ours.WrapInfo.CreationTime = theirs.WrapInfo.CreationTime
 		}
 
+		if theirs.WrapInfo.CreationPath != "" {
+			ours.WrapInfo.CreationPath = theirs.WrapInfo.CreationPath
+		}
+
 		if theirs.WrapInfo.WrappedAccessor != "" {
 			ours.WrapInfo.WrappedAccessor = theirs.WrapInfo.WrappedAccessor
 		}
 	}
 }
 
-func isKVv2(client *api.Client, path string) (string, bool, error) {
+// kvMountCache caches the secret mounts found by isKVv2, so the mount of each
+// path is only looked up once per mount instead of once per secret. Mounts are
+// keyed by Vault address and namespace, since the same path can be mounted
+// differently on each. Each client set has its own, so a reload, which creates
+// new clients, looks the mounts up again.
+type kvMountCache struct {
+	sync.Mutex
+	m map[kvMountKey]bool
+}
+
+type kvMountKey struct {
+	address, namespace, mount string
+}
+
+// isKVv2 returns the path of the mount the given path is on, and whether that
+// mount is a KV v2 secrets engine. Successful lookups are cached per mount in
+// the client set.
+func isKVv2(clients *ClientSet, client *api.Client, path string) (string, bool, error) {
+	address, namespace := client.Address(), client.Namespace()
+	kvMounts := &clients.kvMounts
+
+	kvMounts.Lock()
+	var mountPath string
+	var isV2 bool
+	for k, v := range kvMounts.m {
+		if k.address != address || k.namespace != namespace || len(k.mount) <= len(mountPath) {
+			continue
+		}
+		if strings.HasPrefix(path, k.mount) || path == strings.TrimSuffix(k.mount, "/") {
+			mountPath, isV2 = k.mount, v
+		}
+	}
+	kvMounts.Unlock()
+	if mountPath != "" {
+		return mountPath, isV2, nil
+	}
+
+	mountPath, isV2, err := lookupKVMount(client, path)
+	if err == nil && mountPath != "" {
+		kvMounts.Lock()
+		if kvMounts.m == nil {
+			kvMounts.m = make(map[kvMountKey]bool)
+		}
+		kvMounts.m[kvMountKey{address, namespace, mountPath}] = isV2
+		kvMounts.Unlock()
+	}
+	return mountPath, isV2, err
+}
+
+// lookupKVMount queries Vault for the mount of the given path, see isKVv2.
+func lookupKVMount(client *api.Client, path string) (string, bool, error) {
 	// We don't want to use a wrapping call here so save any custom value and
 	// restore after
 	currentWrappingLookupFunc := client.CurrentWrappingLookupFunc()
@@ -373,3 +473,11 @@ func SetVaultLeaseRenewalThreshold(f float64) {
 	}
 	onceVaultLeaseRenewalThreshold.Do(set)
 }
+
+// Make sure to only set VaultPKIRenewThreshold once
+func SetVaultPKIRenewThreshold(f float64) {
+	set := func() {
+		VaultPKIRenewThreshold = f
+	}
+	onceVaultPKIRenewThreshold.Do(set)
+}
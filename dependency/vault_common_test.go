@@ -5,8 +5,13 @@ package dependency
 
 import (
 	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -71,6 +76,12 @@ func TestVaultRenewDuration(t *testing.T) {
 		t.Fatalf("non renewable certificate duration is not within 80%% to 95%%: %f", nonRenewableCertDur)
 	}
 
+	wrapped := Secret{WrapInfo: &SecretWrapInfo{Token: "foobar", TTL: 100}}
+	wrappedDur := leaseCheckWait(&wrapped).Seconds()
+	if wrappedDur < 80 || wrappedDur > 95 {
+		t.Fatalf("wrapped duration is not within 80%% to 95%% of wrap TTL: %f", wrappedDur)
+	}
+
 	t.Run("secret ID handling", func(t *testing.T) {
 		t.Run("normal case", func(t *testing.T) {
 			// Secret ID TTL handling
@@ -165,3 +176,92 @@ func setupVaultPKI(clients *ClientSet) {
 		panic(err)
 	}
 }
+
+func TestIsKVv2_cache(t *testing.T) {
+	var mu sync.Mutex
+	lookups := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/v1/")
+		mount, ok := strings.CutPrefix(path, "sys/internal/ui/mounts/")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		mu.Lock()
+		lookups[mount]++
+		mu.Unlock()
+		switch {
+		case strings.HasPrefix(mount, "kv2/"):
+			fmt.Fprint(w, `{"data": {"path": "kv2/", "type": "kv", "options": {"version": "2"}}}`)
+		case strings.HasPrefix(mount, "kv1/"):
+			fmt.Fprint(w, `{"data": {"path": "kv1/", "type": "kv", "options": {"version": "1"}}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	clients := NewClientSet()
+	if err := clients.CreateVaultClient(&CreateVaultClientInput{
+		Address: server.URL,
+		Token:   "s.abcd",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		path  string
+		mount string
+		isV2  bool
+	}{
+		{"kv2/app/config", "kv2/", true},
+		{"kv2/app/other", "kv2/", true},
+		{"kv2", "kv2/", true},
+		{"kv1/app/config", "kv1/", false},
+		{"kv1/app/other", "kv1/", false},
+	}
+	for _, tc := range cases {
+		mount, isV2, err := isKVv2(clients, clients.Vault(), tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mount != tc.mount || isV2 != tc.isV2 {
+			t.Errorf("%s: expected mount %q (v2 %t), got %q (v2 %t)",
+				tc.path, tc.mount, tc.isV2, mount, isV2)
+		}
+	}
+
+	// Only the first path on each mount was looked up.
+	exp := map[string]int{"kv2/app/config": 1, "kv1/app/config": 1}
+	if !reflect.DeepEqual(exp, lookups) {
+		t.Errorf("\nexp: %v\nact: %v", exp, lookups)
+	}
+
+	// Paths outside a known mount are still looked up, and failed lookups
+	// are not cached.
+	for i := 0; i < 2; i++ {
+		if mount, isV2, _ := isKVv2(clients, clients.Vault(), "other/app"); mount != "" || isV2 {
+			t.Errorf("expected no mount, got %q (v2 %t)", mount, isV2)
+		}
+	}
+	if lookups["other/app"] != 2 {
+		t.Errorf("expected 2 lookups of other/app, got %d", lookups["other/app"])
+	}
+
+	// New clients, like those created on a reload, look the mounts up again,
+	// so a mount that changed version is seen.
+	reloaded := NewClientSet()
+	if err := reloaded.CreateVaultClient(&CreateVaultClientInput{
+		Address: server.URL,
+		Token:   "s.abcd",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := isKVv2(reloaded, reloaded.Vault(), "kv2/app/config"); err != nil {
+		t.Fatal(err)
+	}
+	if lookups["kv2/app/config"] != 2 {
+		t.Errorf("expected 2 lookups of kv2/app/config, got %d", lookups["kv2/app/config"])
+	}
+}
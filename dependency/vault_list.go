@@ -23,6 +23,9 @@ type VaultListQuery struct {
 	stopCh chan struct{}
 
 	path string
+
+	// namespace overrides the Vault namespace of the client for this query.
+	namespace string
 }
 
 // NewVaultListQuery creates a new datacenter dependency.
@@ -33,9 +36,23 @@ func NewVaultListQuery(s string) (*VaultListQuery, error) {
 		return nil, fmt.Errorf("vault.list: invalid format: %q", s)
 	}
 
+	secretURL, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	queryValues := secretURL.Query()
+	for k := range queryValues {
+		if k != QueryVaultNamespace {
+			return nil, fmt.Errorf("vault.list: invalid query parameter: %q, "+
+				"supported keys: %s", k, QueryVaultNamespace)
+		}
+	}
+
 	return &VaultListQuery{
-		stopCh: make(chan struct{}, 1),
-		path:   s,
+		stopCh:    make(chan struct{}, 1),
+		path:      strings.Trim(secretURL.Path, "/"),
+		namespace: queryValues.Get(QueryVaultNamespace),
 	}, nil
 }
 
@@ -62,10 +79,11 @@ func (d *VaultListQuery) Fetch(clients *ClientSet, opts *QueryOptions) (interfac
 	}
 
 	secretsPath := d.path
+	vaultClient := vaultNamespaceClient(clients, d.namespace)
 
 	// Checking secret engine version. If it's v2, we should shim /metadata/
 	// to secret path if necessary.
-	mountPath, isV2, _ := isKVv2(clients.Vault(), secretsPath)
+	mountPath, isV2, _ := isKVv2(clients, vaultClient, secretsPath)
 	if isV2 {
 		secretsPath = shimKvV2ListPath(secretsPath, mountPath)
 	}
@@ -76,7 +94,7 @@ func (d *VaultListQuery) Fetch(clients *ClientSet, opts *QueryOptions) (interfac
 		Path:     "/v1/" + secretsPath,
 		RawQuery: opts.String(),
 	})
-	secret, err := clients.Vault().Logical().List(secretsPath)
+	secret, err := vaultClient.Logical().List(secretsPath)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, d.String())
 	}
@@ -128,6 +146,9 @@ func (d *VaultListQuery) Stop() {
 
 // String returns the human-friendly version of this dependency.
 func (d *VaultListQuery) String() string {
+	if d.namespace != "" {
+		return fmt.Sprintf("vault.list(%s?%s=%s)", d.path, QueryVaultNamespace, d.namespace)
+	}
 	return fmt.Sprintf("vault.list(%s)", d.path)
 }
 
@@ -48,6 +48,21 @@ func TestNewVaultListQuery(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"namespace",
+			"path/?namespace=team-a",
+			&VaultListQuery{
+				path:      "path",
+				namespace: "team-a",
+			},
+			false,
+		},
+		{
+			"invalid_query",
+			"path?version=3",
+			nil,
+			true,
+		},
 	}
 
 	for i, tc := range cases {
@@ -215,6 +230,11 @@ func TestVaultListQuery_String(t *testing.T) {
 			"path",
 			"vault.list(path)",
 		},
+		{
+			"namespace",
+			"path?namespace=team-a",
+			"vault.list(path?namespace=team-a)",
+		},
 	}
 
 	for i, tc := range cases {
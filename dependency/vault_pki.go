@@ -110,35 +110,48 @@ func (d *VaultPKIQuery) Fetch(clients *ClientSet, opts *QueryOptions) (interface
 	return respWithMetadata(encPems)
 }
 
-// returns time left in ~90% of the original lease and a boolean
-// that returns false if cert needs renewing, true otherwise
+// defaultPKIRenewThreshold is used when VaultPKIRenewThreshold is unset or
+// outside of (0, 1).
+const defaultPKIRenewThreshold = 0.9
+
+// returns the time left until the cert should be renewed and a boolean
+// that returns false if cert needs renewing, true otherwise. The cert needs
+// renewing once VaultPKIRenewThreshold of its lifetime has elapsed.
 func goodFor(cert *x509.Certificate) (time.Duration, bool) {
+	return goodForThreshold(cert, VaultPKIRenewThreshold)
+}
+
+func goodForThreshold(cert *x509.Certificate, threshold float64) (time.Duration, bool) {
 	// If we got called with a cert that doesn't exist, just say there's no
 	// time left, and it needs to be renewed
 	if cert == nil {
 		return 0, false
 	}
-	// These are all int64's with Seconds since the Epoch, handy for the math
-	start, end := cert.NotBefore.Unix(), cert.NotAfter.Unix()
-	now := time.Now().UTC().Unix()
-	if end <= now { // already expired
+	if threshold <= 0 || threshold >= 1 {
+		threshold = defaultPKIRenewThreshold
+	}
+	start, end := cert.NotBefore, cert.NotAfter
+	now := time.Now().UTC()
+	if !end.After(now) { // already expired
 		return 0, false
 	}
-	lifespan := end - start        // full ttl of cert
-	duration := end - now          // duration remaining
-	gooddur := (duration * 9) / 10 // 90% of duration
-	mindur := (lifespan / 10)      // 10% of lifespan
-	if gooddur <= mindur {
-		return 0, false // almost expired, get a new one
+	lifespan := end.Sub(start) // full ttl of cert
+	renewAt := start.Add(time.Duration(float64(lifespan) * threshold))
+	gooddur := renewAt.Sub(now)
+	if gooddur <= 0 {
+		return 0, false // past the threshold, get a new one
 	}
-	if gooddur > 100 { // 100 seconds
+	if gooddur > 100*time.Second {
 		// add jitter if big enough for it to matter
 		r := rand.New(rand.NewSource(time.Now().UnixNano()))
-		// between 87% and 93%
-		gooddur = gooddur + ((gooddur / 100) * int64(r.Intn(6)-3))
+		// between 97% and 103%
+		gooddur = gooddur + ((gooddur / 100) * time.Duration(r.Intn(6)-3))
+		// never sleep past expiry
+		if left := end.Sub(now); gooddur >= left {
+			gooddur = left - left/100
+		}
 	}
-	sleepFor := time.Duration(gooddur * 1e9) // basically: gooddur*time.Second
-	return sleepFor, true
+	return gooddur, true
 }
 
 // loops through all pem encoded blocks in the byte stream
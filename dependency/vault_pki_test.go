@@ -7,10 +7,17 @@ package dependency
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"errors"
+	"math/big"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/consul-template/renderer"
 	"github.com/hashicorp/vault/api"
@@ -53,6 +60,76 @@ func Test_VaultPKI_notGoodFor(t *testing.T) {
 	}
 }
 
+func Test_VaultPKI_goodForThreshold(t *testing.T) {
+	// a short-TTL cert issued 8s ago that expires in 2s
+	now := time.Now().UTC()
+	cert := testShortTTLCert(t, now.Add(-8*time.Second), now.Add(2*time.Second))
+
+	t.Run("past_threshold", func(t *testing.T) {
+		if dur, ok := goodForThreshold(cert, 0.5); ok || dur != 0 {
+			t.Errorf("expected cert to need renewal, got %s, %t", dur, ok)
+		}
+	})
+
+	t.Run("before_threshold", func(t *testing.T) {
+		dur, ok := goodForThreshold(cert, 0.9)
+		if !ok {
+			t.Fatal("expected cert to be good")
+		}
+		// renews at 9s of the 10s lifetime, about 1s from now and before expiry
+		if dur <= 0 || dur > time.Second {
+			t.Errorf("expected renewal within 1s, got %s", dur)
+		}
+		if !now.Add(dur).Before(cert.NotAfter) {
+			t.Errorf("expected renewal before expiry at %s", cert.NotAfter)
+		}
+	})
+
+	t.Run("default_threshold", func(t *testing.T) {
+		fresh := testShortTTLCert(t, now, now.Add(10*time.Second))
+		for _, threshold := range []float64{0, 1.5} {
+			dur, ok := goodForThreshold(fresh, threshold)
+			if !ok || dur > 9*time.Second || dur < 7*time.Second {
+				t.Errorf("%v: expected ~9s with default threshold, got %s, %t",
+					threshold, dur, ok)
+			}
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		old := testShortTTLCert(t, now.Add(-10*time.Second), now.Add(-time.Second))
+		if _, ok := goodForThreshold(old, 0.9); ok {
+			t.Error("expected expired cert to need renewal")
+		}
+	})
+}
+
+// testShortTTLCert returns a self-signed certificate valid between the given
+// times.
+func testShortTTLCert(t *testing.T, notBefore, notAfter time.Time) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "foo.example.com"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
 func Test_VaultPKI_pemsCert(t *testing.T) {
 	// tests w/ valid pems, and having it hidden behind various things
 	want := strings.TrimRight(strings.TrimSpace(validCert), "\n")
@@ -4,10 +4,13 @@
 package dependency
 
 import (
+	"encoding/gob"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/url"
 	"path"
+	"regexp"
 	"strings"
 	"time"
 
@@ -18,6 +21,17 @@ import (
 // Ensure implements
 var _ Dependency = (*VaultReadQuery)(nil)
 
+// VaultStaticCredsRe matches paths that read the credentials of a database
+// static role, e.g. "database/static-creds/app".
+var VaultStaticCredsRe = regexp.MustCompile(`(\A|/)static-creds/[^/]+\z`)
+
+func init() {
+	gob.Register(&Secret{})
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+	gob.Register(json.Number(""))
+}
+
 // VaultReadQuery is the dependency to Vault for a secret
 type VaultReadQuery struct {
 	stopCh  chan struct{}
@@ -29,6 +43,9 @@ type VaultReadQuery struct {
 	isKVv2      *bool
 	secretPath  string
 
+	// namespace overrides the Vault namespace of the client for this query.
+	namespace string
+
 	// vaultSecret is the actual Vault secret which we are renewing
 	vaultSecret *api.Secret
 }
@@ -46,11 +63,16 @@ func NewVaultReadQuery(s string) (*VaultReadQuery, error) {
 		return nil, err
 	}
 
+	queryValues := secretURL.Query()
+	namespace := queryValues.Get(QueryVaultNamespace)
+	queryValues.Del(QueryVaultNamespace)
+
 	return &VaultReadQuery{
 		stopCh:      make(chan struct{}, 1),
 		sleepCh:     make(chan time.Duration, 1),
 		rawPath:     secretURL.Path,
-		queryValues: secretURL.Query(),
+		queryValues: queryValues,
+		namespace:   namespace,
 	}, nil
 }
 
@@ -76,7 +98,7 @@ func (d *VaultReadQuery) Fetch(clients *ClientSet, opts *QueryOptions,
 	firstRun := d.secret == nil
 
 	if !firstRun && vaultSecretRenewable(d.secret) {
-		err := renewSecret(clients, d)
+		err := renewSecret(vaultNamespaceClient(clients, d.namespace), d)
 		if err != nil {
 			return nil, nil, errors.Wrap(err, d.String())
 		}
@@ -115,9 +137,12 @@ func (d *VaultReadQuery) secrets() (*Secret, *api.Secret) {
 	return d.secret, d.vaultSecret
 }
 
-// CanShare returns if this dependency is shareable.
+// CanShare returns if this dependency is shareable. Only static role
+// credentials are shared: Vault rotates them for the whole cluster, so every
+// instance reads the same values. Everything else, in particular dynamic
+// "creds/" reads, is issued per reader and must not be shared.
 func (d *VaultReadQuery) CanShare() bool {
-	return false
+	return VaultStaticCredsRe.MatchString(d.rawPath)
 }
 
 // Stop halts the given dependency's fetch.
@@ -127,10 +152,14 @@ func (d *VaultReadQuery) Stop() {
 
 // String returns the human-friendly version of this dependency.
 func (d *VaultReadQuery) String() string {
+	path := d.rawPath
 	if v := d.queryValues["version"]; len(v) > 0 {
-		return fmt.Sprintf("vault.read(%s.v%s)", d.rawPath, v[0])
+		path = fmt.Sprintf("%s.v%s", path, v[0])
+	}
+	if d.namespace != "" {
+		path = fmt.Sprintf("%s?%s=%s", path, QueryVaultNamespace, d.namespace)
 	}
-	return fmt.Sprintf("vault.read(%s)", d.rawPath)
+	return fmt.Sprintf("vault.read(%s)", path)
 }
 
 // Type returns the type of this dependency.
@@ -139,11 +168,11 @@ func (d *VaultReadQuery) Type() Type {
 }
 
 func (d *VaultReadQuery) readSecret(clients *ClientSet) (*api.Secret, error) {
-	vaultClient := clients.Vault()
+	vaultClient := vaultNamespaceClient(clients, d.namespace)
 
 	// Check whether this secret refers to a KV v2 entry if we haven't yet.
 	if d.isKVv2 == nil {
-		mountPath, isKVv2, err := isKVv2(vaultClient, d.rawPath)
+		mountPath, isKVv2, err := isKVv2(clients, vaultClient, d.rawPath)
 		if err != nil {
 			log.Printf("[WARN] %s: failed to check if %s is KVv2, "+
 				"assume not: %s", d, d.rawPath, err)
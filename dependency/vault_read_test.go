@@ -4,6 +4,9 @@
 package dependency
 
 import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"reflect"
@@ -68,6 +71,18 @@ func TestNewVaultReadQuery(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"namespace",
+			"path?namespace=team-a&version=3",
+			&VaultReadQuery{
+				rawPath: "path",
+				queryValues: url.Values{
+					"version": []string{"3"},
+				},
+				namespace: "team-a",
+			},
+			false,
+		},
 	}
 
 	for i, tc := range cases {
@@ -621,6 +636,53 @@ func TestVaultReadQuery_Fetch_NonSecrets(t *testing.T) {
 	}
 }
 
+func TestVaultReadQuery_Fetch_Namespace(t *testing.T) {
+	vc := testClients.Vault()
+	health, err := vc.Sys().Health()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(health.Version, "+ent") {
+		t.Skip("namespaces require Vault Enterprise, skipping...")
+	}
+
+	if _, err := vc.Logical().Write("sys/namespaces/read-ns", nil); err != nil {
+		t.Fatal(err)
+	}
+	defer vc.Logical().Delete("sys/namespaces/read-ns")
+
+	nsc := vc.WithNamespace("read-ns")
+	if err := nsc.Sys().Mount("secret-ns", &api.MountInput{Type: "kv"}); err != nil {
+		t.Fatal(err)
+	}
+	defer nsc.Sys().Unmount("secret-ns")
+	if _, err := nsc.Logical().Write("secret-ns/foo", map[string]interface{}{
+		"ttl": "100ms",
+		"zip": "zap",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := NewVaultReadQuery("secret-ns/foo?namespace=read-ns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	act, _, err := d.Fetch(testClients, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "zap", act.(*Secret).Data["zip"])
+
+	// The client default namespace must not see the secret.
+	d, err = NewVaultReadQuery("secret-ns/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := d.Fetch(testClients, nil); err == nil {
+		t.Fatal("expected error reading outside of the namespace")
+	}
+}
+
 func TestVaultReadQuery_String(t *testing.T) {
 	cases := []struct {
 		name string
@@ -632,6 +694,21 @@ func TestVaultReadQuery_String(t *testing.T) {
 			"path",
 			"vault.read(path)",
 		},
+		{
+			"version",
+			"path?version=3",
+			"vault.read(path.v3)",
+		},
+		{
+			"namespace",
+			"path?namespace=team-a",
+			"vault.read(path?namespace=team-a)",
+		},
+		{
+			"version_namespace",
+			"path?version=3&namespace=team-a",
+			"vault.read(path.v3?namespace=team-a)",
+		},
 	}
 
 	for i, tc := range cases {
@@ -645,6 +722,81 @@ func TestVaultReadQuery_String(t *testing.T) {
 	}
 }
 
+func TestVaultReadQuery_CanShare(t *testing.T) {
+	cases := []struct {
+		name string
+		i    string
+		exp  bool
+	}{
+		{
+			"static_creds",
+			"database/static-creds/app",
+			true,
+		},
+		{
+			"static_creds_custom_mount",
+			"db/prod/static-creds/app",
+			true,
+		},
+		{
+			"static_creds_namespace",
+			"database/static-creds/app?namespace=team-a",
+			true,
+		},
+		{
+			"dynamic_creds",
+			"database/creds/app",
+			false,
+		},
+		{
+			"static_creds_no_role",
+			"database/static-creds",
+			false,
+		},
+		{
+			"static_role_config",
+			"database/static-roles/app",
+			false,
+		},
+		{
+			"kv",
+			"secret/foo",
+			false,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			d, err := NewVaultReadQuery(tc.i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.exp, d.CanShare())
+		})
+	}
+}
+
+func TestVaultReadQuery_gob(t *testing.T) {
+	// Shared secrets are gob encoded when they are stored for de-duplication.
+	exp := map[string]interface{}{
+		"vault.read(database/static-creds/app)": &Secret{
+			LeaseDuration: 3600,
+			Data: map[string]interface{}{
+				"username": "app",
+				"password": "s3cr3t",
+				"ttl":      json.Number("3600"),
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, gob.NewEncoder(&buf).Encode(exp))
+
+	var act map[string]interface{}
+	require.NoError(t, gob.NewDecoder(&buf).Decode(&act))
+	assert.Equal(t, exp, act)
+}
+
 func TestShimKVv2Path(t *testing.T) {
 	cases := []struct {
 		name      string
@@ -44,7 +44,7 @@ func (d *VaultTokenQuery) Fetch(clients *ClientSet, opts *QueryOptions,
 	}
 
 	if vaultSecretRenewable(d.secret) {
-		err := renewSecret(clients, d)
+		err := renewSecret(clients.Vault(), d)
 		if err != nil {
 			return nil, nil, errors.Wrap(err, d.String())
 		}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"net/url"
 	"sort"
 	"strings"
@@ -20,6 +21,14 @@ import (
 // Ensure implements
 var _ Dependency = (*VaultWriteQuery)(nil)
 
+// vaultReservedHeaders are the request headers managed by the Vault client,
+// which cannot be set on a write query.
+var vaultReservedHeaders = []string{
+	"Authorization",
+	"X-Vault-Namespace",
+	"X-Vault-Token",
+}
+
 // VaultWriteQuery is the dependency to Vault for a secret
 type VaultWriteQuery struct {
 	stopCh  chan struct{}
@@ -30,6 +39,11 @@ type VaultWriteQuery struct {
 	dataHash string
 	secret   *Secret
 
+	// headers are sent with the write request in addition to the headers of
+	// the client, e.g. X-Vault-Wrap-TTL.
+	headers     http.Header
+	headersHash string
+
 	// vaultSecret is the actual Vault secret which we are renewing
 	vaultSecret *api.Secret
 }
@@ -51,6 +65,34 @@ func NewVaultWriteQuery(s string, d map[string]interface{}) (*VaultWriteQuery, e
 	}, nil
 }
 
+// NewVaultWriteQueryWithHeaders creates a new write dependency that sends the
+// given headers with its request. Headers managed by the Vault client, like
+// the token, are rejected.
+func NewVaultWriteQueryWithHeaders(s string, d map[string]interface{}, h map[string]string) (*VaultWriteQuery, error) {
+	q, err := NewVaultWriteQuery(s, d)
+	if err != nil {
+		return nil, err
+	}
+	if len(h) == 0 {
+		return q, nil
+	}
+
+	q.headers = make(http.Header, len(h))
+	hashed := make(map[string]interface{}, len(h))
+	for k, v := range h {
+		k = http.CanonicalHeaderKey(strings.TrimSpace(k))
+		for _, r := range vaultReservedHeaders {
+			if k == r {
+				return nil, fmt.Errorf("vault.write: header %q cannot be set", k)
+			}
+		}
+		q.headers.Set(k, v)
+		hashed[k] = v
+	}
+	q.headersHash = sha1Map(hashed)
+	return q, nil
+}
+
 // Fetch queries the Vault API
 func (d *VaultWriteQuery) Fetch(clients *ClientSet, opts *QueryOptions,
 ) (interface{}, *ResponseMetadata, error) {
@@ -68,7 +110,7 @@ func (d *VaultWriteQuery) Fetch(clients *ClientSet, opts *QueryOptions,
 	firstRun := d.secret == nil
 
 	if !firstRun && vaultSecretRenewable(d.secret) {
-		err := renewSecret(clients, d)
+		err := renewSecret(clients.Vault(), d)
 		if err != nil {
 			return nil, nil, errors.Wrap(err, d.String())
 		}
@@ -120,6 +162,10 @@ func (d *VaultWriteQuery) Stop() {
 
 // String returns the human-friendly version of this dependency.
 func (d *VaultWriteQuery) String() string {
+	if d.headersHash != "" {
+		return fmt.Sprintf("vault.write(%s -> %s, headers -> %s)",
+			d.path, d.dataHash, d.headersHash)
+	}
 	return fmt.Sprintf("vault.write(%s -> %s)", d.path, d.dataHash)
 }
 
@@ -154,13 +200,14 @@ func (d *VaultWriteQuery) writeSecret(clients *ClientSet, opts *QueryOptions) (*
 
 	path := d.path
 	data := d.data
-	mountPath, isv2, _ := isKVv2(clients.Vault(), path)
+	mountPath, isv2, _ := isKVv2(clients, clients.Vault(), path)
 	if isv2 {
 		path = shimKVv2Path(path, mountPath)
 		data = map[string]interface{}{"data": d.data}
 	}
 
-	vaultSecret, err := clients.Vault().Logical().Write(path, data)
+	client := vaultHeadersClient(clients.Vault(), d.headers)
+	vaultSecret, err := client.Logical().Write(path, data)
 	if err != nil {
 		return nil, errors.Wrap(err, d.String())
 	}
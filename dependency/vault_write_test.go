@@ -6,6 +6,8 @@ package dependency
 import (
 	"encoding/base64"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -73,6 +75,137 @@ func TestNewVaultWriteQuery(t *testing.T) {
 	}
 }
 
+func TestNewVaultWriteQueryWithHeaders(t *testing.T) {
+	cases := []struct {
+		name string
+		h    map[string]string
+		exp  http.Header
+		err  bool
+	}{
+		{
+			"none",
+			nil,
+			nil,
+			false,
+		},
+		{
+			"wrap_ttl",
+			map[string]string{"x-vault-wrap-ttl": "60s"},
+			http.Header{"X-Vault-Wrap-Ttl": []string{"60s"}},
+			false,
+		},
+		{
+			"token",
+			map[string]string{"X-Vault-Token": "s.abcd"},
+			nil,
+			true,
+		},
+		{
+			"token_lowercase",
+			map[string]string{"x-vault-token": "s.abcd"},
+			nil,
+			true,
+		},
+		{
+			"namespace",
+			map[string]string{"X-Vault-Namespace": "team-a"},
+			nil,
+			true,
+		},
+		{
+			"authorization",
+			map[string]string{"Authorization": "Bearer abcd"},
+			nil,
+			true,
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			act, err := NewVaultWriteQueryWithHeaders("transit/encrypt/test", nil, tc.h)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+			if act != nil {
+				assert.Equal(t, tc.exp, act.headers)
+			}
+		})
+	}
+}
+
+func TestVaultWriteQuery_Fetch_headers(t *testing.T) {
+	var wrapTTL string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/v1/transit/encrypt/test" {
+			http.NotFound(w, r)
+			return
+		}
+		wrapTTL = r.Header.Get("X-Vault-Wrap-TTL")
+		fmt.Fprint(w, `{"wrap_info": {"token": "wrapped", "ttl": 60, "creation_path": "transit/encrypt/test"}}`)
+	}))
+	defer server.Close()
+
+	clients := NewClientSet()
+	if err := clients.CreateVaultClient(&CreateVaultClientInput{
+		Address: server.URL,
+		Token:   "s.abcd",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := NewVaultWriteQueryWithHeaders("transit/encrypt/test",
+		map[string]interface{}{"plaintext": "dGVzdA=="},
+		map[string]string{"X-Vault-Wrap-TTL": "60s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Stop()
+
+	act, _, err := d.Fetch(clients, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "60s", wrapTTL)
+	assert.Equal(t, "wrapped", act.(*Secret).WrapInfo.Token)
+	assert.Equal(t, "transit/encrypt/test", act.(*Secret).WrapInfo.CreationPath)
+
+	// The headers are only sent by this query, not by the shared client.
+	assert.Empty(t, clients.Vault().Headers().Get("X-Vault-Wrap-TTL"))
+}
+
+func TestVaultWriteQuery_Fetch_wrapped(t *testing.T) {
+	clients := testClients
+
+	d, err := NewVaultWriteQueryWithHeaders("auth/token/create",
+		map[string]interface{}{"policies": "default"},
+		map[string]string{"X-Vault-Wrap-TTL": "5m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Stop()
+
+	act, _, err := d.Fetch(clients, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret := act.(*Secret)
+	if secret.WrapInfo == nil {
+		t.Fatal("expected the response to be wrapped")
+	}
+	assert.NotEmpty(t, secret.WrapInfo.Token)
+	assert.Equal(t, 300, secret.WrapInfo.TTL)
+	assert.Equal(t, "auth/token/create", secret.WrapInfo.CreationPath)
+	assert.Nil(t, secret.Auth)
+
+	// The wrapping token unwraps to the created token.
+	unwrapped, err := clients.Vault().Logical().Unwrap(secret.WrapInfo.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEmpty(t, unwrapped.Auth.ClientToken)
+}
+
 func TestVaultWriteSecretKV_Fetch(t *testing.T) {
 	// previously triggered a nil-pointer-deref panic in wq.Fetch() with KVv1
 	// due to writeSecret() returning nil for vaultSecret
@@ -390,4 +523,12 @@ func TestVaultWriteQuery_String(t *testing.T) {
 			assert.Equal(t, tc.exp, d.String())
 		})
 	}
+	t.Run("headers", func(t *testing.T) {
+		d, err := NewVaultWriteQueryWithHeaders("path", nil,
+			map[string]string{"X-Vault-Wrap-TTL": "60s"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "vault.write(path -> da39a3ee, headers -> ed36a99d)", d.String())
+	})
 }
@@ -13,7 +13,6 @@ import (
 
 	cnf "github.com/hashicorp/consul-template/config"
 
-	gsyslog "github.com/hashicorp/go-syslog"
 	"github.com/hashicorp/logutils"
 )
 
@@ -64,7 +63,8 @@ type Config struct {
 	// Syslog and SyslogFacility are the syslog configuration options.
 	Syslog         bool   `json:"syslog"`
 	SyslogFacility string `json:"syslog_facility"`
-	// SyslogName is the progname as it will appear in syslog output (if enabled).
+	// SyslogName is the progname as it will appear in syslog output (if enabled),
+	// also known as the syslog tag.
 	SyslogName string `json:"name"`
 
 	// Writer is the output where logs should go. If syslog is enabled, data will
@@ -123,13 +123,14 @@ func newWriter(config *Config) (io.Writer, error) {
 	}
 
 	if config.Syslog {
-		log.Printf("[DEBUG] (logging) enabling syslog on %s", config.SyslogFacility)
+		log.Printf("[DEBUG] (logging) enabling syslog on %s with tag %s",
+			config.SyslogFacility, config.SyslogName)
 
-		l, err := gsyslog.NewLogger(gsyslog.LOG_NOTICE, config.SyslogFacility, config.SyslogName)
+		syslog, err := newSyslogWrapper(config.SyslogFacility, config.SyslogName,
+			logOutput.(*logutils.LevelFilter))
 		if err != nil {
 			return nil, fmt.Errorf("error setting up syslog logger: %s", err)
 		}
-		syslog := &SyslogWrapper{l, logOutput.(*logutils.LevelFilter)}
 		logOutput = io.MultiWriter(logOutput, syslog)
 	}
 
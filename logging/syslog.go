@@ -5,11 +5,40 @@ package logging
 
 import (
 	"bytes"
+	"fmt"
+	"runtime"
 
 	"github.com/hashicorp/go-syslog"
 	"github.com/hashicorp/logutils"
 )
 
+// syslogUnsupported are the platforms go-syslog cannot log to syslog on.
+var syslogUnsupported = map[string]bool{
+	"windows": true,
+	"plan9":   true,
+	"nacl":    true,
+}
+
+// To let me replace in tests
+var newSyslogger = func(facility, tag string) (gsyslog.Syslogger, error) {
+	return gsyslog.NewLogger(gsyslog.LOG_NOTICE, facility, tag)
+}
+
+// newSyslogWrapper connects to the local syslog daemon with the given facility
+// and tag. It fails on platforms without syslog, so a misconfiguration is
+// reported at startup instead of the logs being silently dropped.
+func newSyslogWrapper(facility, tag string, filt *logutils.LevelFilter) (*SyslogWrapper, error) {
+	if syslogUnsupported[runtime.GOOS] {
+		return nil, fmt.Errorf("syslog is not supported on %s", runtime.GOOS)
+	}
+
+	l, err := newSyslogger(facility, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogWrapper{l, filt}, nil
+}
+
 // syslogPriorityMap is used to map a log level to a syslog priority level.
 var syslogPriorityMap = map[string]gsyslog.Priority{
 	"DEBUG": gsyslog.LOG_INFO,
@@ -0,0 +1,87 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !windows && !plan9
+
+package logging
+
+import (
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gsyslog "github.com/hashicorp/go-syslog"
+)
+
+func TestNewWriter_syslog(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "syslog.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Send to the fake syslog socket instead of the local syslog daemon.
+	defer func(orig func(string, string) (gsyslog.Syslogger, error)) { newSyslogger = orig }(newSyslogger)
+	newSyslogger = func(facility, tag string) (gsyslog.Syslogger, error) {
+		return gsyslog.DialLogger("unixgram", addr, gsyslog.LOG_NOTICE, facility, tag)
+	}
+
+	w, err := newWriter(&Config{
+		Level:          "INFO",
+		Syslog:         true,
+		SyslogFacility: "LOCAL3",
+		SyslogName:     "ct-test",
+		Writer:         io.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read := func() string {
+		t.Helper()
+		buf := make([]byte, 1024)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(buf[:n])
+	}
+
+	// Filtered out by the log level, nothing is sent. The syslog writer
+	// reports no bytes written for it, which the multi writer calls a short
+	// write, so the error is ignored like the log package does.
+	w.Write([]byte("[DEBUG] (test) skipped\n"))
+
+	if _, err := w.Write([]byte("[WARN] (test) hello\n")); err != nil {
+		t.Fatal(err)
+	}
+	msg := read()
+
+	// LOCAL3 is facility 19 and a warning is severity 4: 19*8 + 4.
+	if !strings.HasPrefix(msg, "<156>") {
+		t.Errorf("expected the LOCAL3 warning priority, got %q", msg)
+	}
+	if !strings.Contains(msg, " ct-test[") {
+		t.Errorf("expected the ct-test tag, got %q", msg)
+	}
+	if !strings.Contains(msg, "(test) hello") || strings.Contains(msg, "[WARN]") {
+		t.Errorf("expected the message without its level, got %q", msg)
+	}
+
+	t.Run("bad_facility", func(t *testing.T) {
+		_, err := newWriter(&Config{
+			Level:          "INFO",
+			Syslog:         true,
+			SyslogFacility: "NOPE",
+			SyslogName:     "ct-test",
+		})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
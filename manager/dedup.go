@@ -95,6 +95,11 @@ type DedupManager struct {
 	lastWrite     map[*template.Template]uint64
 	lastWriteLock sync.RWMutex
 
+	// session is the ID of the current session, which holds the locks and the
+	// data written by this instance
+	session     string
+	sessionLock sync.RWMutex
+
 	// updateCh is used to indicate an update watched data
 	updateCh chan struct{}
 
@@ -169,6 +174,7 @@ START:
 		goto WAIT
 	}
 	log.Printf("[INFO] (dedup) created session %s", id)
+	d.setSession(id)
 
 	// Attempt to lock each template
 	for _, t := range d.templates {
@@ -176,10 +182,13 @@ START:
 		go d.attemptLock(client, id, sessionCh, t)
 	}
 
-	// Renew our session periodically
-	if err := session.RenewPeriodic("15s", id, nil, d.stopCh); err != nil {
+	// Renew our session periodically. The data is deleted along with the
+	// session when it is not renewed within the TTL, so followers do not keep
+	// reading the data of a leader that died.
+	if err := session.RenewPeriodic(ttl, id, nil, d.stopCh); err != nil {
 		log.Printf("[ERR] (dedup) failed to renew session: %v", err)
 	}
+	d.setSession("")
 	close(sessionCh)
 	d.wg.Wait()
 
@@ -192,6 +201,13 @@ WAIT:
 	}
 }
 
+// setSession sets the ID of the current session, or clears it when empty.
+func (d *DedupManager) setSession(id string) {
+	d.sessionLock.Lock()
+	d.session = id
+	d.sessionLock.Unlock()
+}
+
 // IsLeader checks if we are currently the leader instance
 func (d *DedupManager) IsLeader(tmpl *template.Template) bool {
 	d.leaderLock.RLock()
@@ -258,16 +274,28 @@ func (d *DedupManager) UpdateDeps(t *template.Template, deps []dep.Dependency) e
 	}
 	compress.Close()
 
-	// Write the KV update
+	// Write the KV update with our session, which holds the lock, so the data
+	// expires with the session and is never written over that of a new leader
+	d.sessionLock.RLock()
+	session := d.session
+	d.sessionLock.RUnlock()
+	if session == "" {
+		return fmt.Errorf("failed to write '%s': no session", dataPath)
+	}
 	kvPair := consulapi.KVPair{
-		Key:   dataPath,
-		Value: buf.Bytes(),
-		Flags: consulapi.LockFlagValue,
+		Key:     dataPath,
+		Value:   buf.Bytes(),
+		Flags:   consulapi.LockFlagValue,
+		Session: session,
 	}
 	client := d.clients.Consul()
-	if _, err := client.KV().Put(&kvPair, nil); err != nil {
+	acquired, _, err := client.KV().Acquire(&kvPair, nil)
+	if err != nil {
 		return fmt.Errorf("failed to write '%s': %v", dataPath, err)
 	}
+	if !acquired {
+		return fmt.Errorf("failed to write '%s': lock is held by another session", dataPath)
+	}
 	log.Printf("[INFO] (dedup) updated de-duplicate data '%s'", dataPath)
 	d.lastWriteLock.Lock()
 	d.lastWrite[t] = hash
@@ -4,11 +4,14 @@
 package manager
 
 import (
+	"bytes"
 	"testing"
 	"time"
 
+	"github.com/hashicorp/consul-template/config"
 	"github.com/hashicorp/consul-template/dependency"
 	"github.com/hashicorp/consul-template/template"
+	consulapi "github.com/hashicorp/consul/api"
 )
 
 func TestDedup_StartStop(t *testing.T) {
@@ -163,3 +166,177 @@ func TestDedup_FollowerUpdate(t *testing.T) {
 		t.Fatalf("bad: %v", data)
 	}
 }
+
+func TestDedup_Prefix(t *testing.T) {
+	tmpl, err := template.NewTemplate(&template.NewTemplateInput{
+		Contents: `template-4 {{ range service "consul" }}{{ .Node }}{{ end }}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dedup := testDedupManagerConfig(t, &config.DedupConfig{
+		Prefix: config.String("custom/dedup/"),
+		TTL:    config.TimeDuration(10 * time.Second),
+	}, []*template.Template{tmpl})
+	if err := dedup.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer dedup.Stop()
+
+	select {
+	case <-dedup.UpdateCh():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timeout")
+	}
+
+	d, err := dependency.NewHealthServiceQuery("consul")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dedup.brain.Remember(d, 123)
+	if err := dedup.UpdateDeps(tmpl, []dependency.Dependency{d}); err != nil {
+		t.Fatal(err)
+	}
+
+	client := testClients.Consul()
+	pair, _, err := client.KV().Get("custom/dedup/"+tmpl.ID()+"/data", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pair == nil || bytes.Equal(pair.Value, templateNoData()) {
+		t.Fatalf("expected data under the custom prefix, got %#v", pair)
+	}
+	if pair, _, _ := client.KV().Get(config.DefaultDedupPrefix+tmpl.ID()+"/data", nil); pair != nil {
+		t.Errorf("expected no data under the default prefix, got %#v", pair)
+	}
+
+	// The data is held by the session, which is created with the TTL and is
+	// deleted along with it.
+	if pair.Session == "" {
+		t.Fatal("expected the data to be held by the session")
+	}
+	se, _, err := client.Session().Info(pair.Session, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if se == nil || se.Behavior != "delete" {
+		t.Fatalf("expected a session that deletes its keys, got %#v", se)
+	}
+	if ttl, err := time.ParseDuration(se.TTL); err != nil || ttl != 10*time.Second {
+		t.Errorf("expected a session TTL of 10s, got %q", se.TTL)
+	}
+}
+
+func TestDedup_SessionExpired(t *testing.T) {
+	tmpl, err := template.NewTemplate(&template.NewTemplateInput{
+		Contents: `template-5 {{ range service "consul" }}{{ .Node }}{{ end }}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dedup := testDedupManagerConfig(t, &config.DedupConfig{
+		Prefix: config.String("expired/dedup/"),
+	}, []*template.Template{tmpl})
+	if err := dedup.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer dedup.Stop()
+
+	select {
+	case <-dedup.UpdateCh():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timeout")
+	}
+
+	d, err := dependency.NewHealthServiceQuery("consul")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dedup.brain.Remember(d, 123)
+	if err := dedup.UpdateDeps(tmpl, []dependency.Dependency{d}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Invalidate the session as Consul does once the TTL passes without a
+	// renewal, and the leader's data must go with it.
+	client := testClients.Consul()
+	key := "expired/dedup/" + tmpl.ID() + "/data"
+	pair, _, err := client.KV().Get(key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pair == nil || pair.Session == "" {
+		t.Fatalf("expected data held by the session, got %#v", pair)
+	}
+	if _, err := client.Session().Destroy(pair.Session, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pair, _, err := client.KV().Get(key, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// A new session may lock the key again, but without the data.
+		if pair == nil || bytes.Equal(pair.Value, templateNoData()) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the data to expire with the session, got %#v", pair)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestDedup_StopRemovesData(t *testing.T) {
+	tmpl, err := template.NewTemplate(&template.NewTemplateInput{
+		Contents: `template-6 {{ range service "consul" }}{{ .Node }}{{ end }}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The data of a template another instance renders, written without a
+	// session like older versions did.
+	client := testClients.Consul()
+	if _, err := client.KV().Put(&consulapi.KVPair{Key: "stop/dedup/other/data", Value: []byte("other")}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	dedup := testDedupManagerConfig(t, &config.DedupConfig{
+		Prefix: config.String("stop/dedup/"),
+	}, []*template.Template{tmpl})
+	if err := dedup.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-dedup.UpdateCh():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timeout")
+	}
+
+	d, err := dependency.NewHealthServiceQuery("consul")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dedup.brain.Remember(d, 123)
+	if err := dedup.UpdateDeps(tmpl, []dependency.Dependency{d}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stopping, like on a reload that removes the template, destroys the
+	// session and the data it holds with it.
+	dedup.Stop()
+
+	key := "stop/dedup/" + tmpl.ID() + "/data"
+	if pair, _, err := client.KV().Get(key, nil); err != nil || pair != nil {
+		t.Errorf("expected the data to be deleted with the session, got %#v: %v", pair, err)
+	}
+	if pair, _, _ := client.KV().Get("stop/dedup/other/data", nil); pair == nil {
+		t.Errorf("expected the data of other instances to be kept")
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/consul-template/config"
+)
+
+// healthShutdownTimeout is how long stopping the health endpoint waits for
+// the requests in flight.
+const healthShutdownTimeout = 5 * time.Second
+
+// healthStatus is the body of the responses of the health endpoint.
+type healthStatus struct {
+	// Ready is true once every template rendered at least once.
+	Ready bool `json:"ready"`
+
+	Templates []templateStatus `json:"templates"`
+}
+
+// templateStatus is the render state of a single template.
+type templateStatus struct {
+	ID          string `json:"id"`
+	Source      string `json:"source"`
+	Destination string `json:"destination,omitempty"`
+
+	// Rendered is true once the template rendered successfully, whether or not
+	// its destination had to be written.
+	Rendered bool `json:"rendered"`
+
+	// LastRendered is the last time the template rendered successfully.
+	LastRendered *time.Time `json:"last_rendered,omitempty"`
+
+	// Error is the error of the last attempt to render the template, if it
+	// failed.
+	Error string `json:"error,omitempty"`
+}
+
+// healthStatus returns the render state of each template of the runner.
+func (r *Runner) healthStatus() *healthStatus {
+	r.renderEventsLock.RLock()
+	defer r.renderEventsLock.RUnlock()
+
+	status := &healthStatus{
+		Ready:     true,
+		Templates: make([]templateStatus, 0, len(r.templates)),
+	}
+	for _, tmpl := range r.templates {
+		ts := templateStatus{
+			ID:     tmpl.ID(),
+			Source: tmpl.Source(),
+		}
+		if tc := r.templateConfigFor(tmpl); tc != nil {
+			ts.Destination = config.StringVal(tc.Destination)
+		}
+
+		if event, ok := r.renderEvents[tmpl.ID()]; ok {
+			if !event.LastWouldRender.IsZero() {
+				lastRendered := event.LastWouldRender
+				ts.Rendered = true
+				ts.LastRendered = &lastRendered
+			}
+			if event.Error != nil {
+				ts.Error = event.Error.Error()
+			}
+		}
+
+		status.Ready = status.Ready && ts.Rendered
+		status.Templates = append(status.Templates, ts)
+	}
+	return status
+}
+
+// healthHandler returns the handler of the health endpoint. "/healthz"
+// responds 200 while the process is running, and "/readyz" responds 200 once
+// every template rendered at least once and 503 until then. Both report the
+// render state of each template.
+func (r *Runner) healthHandler() http.Handler {
+	respond := func(w http.ResponseWriter, status *healthStatus, code int) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			log.Printf("[WARN] (runner) health endpoint: %s", err)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		respond(w, r.healthStatus(), http.StatusOK)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, _ *http.Request) {
+		status := r.healthStatus()
+		code := http.StatusOK
+		if !status.Ready {
+			code = http.StatusServiceUnavailable
+		}
+		respond(w, status, code)
+	})
+	return mux
+}
+
+// startHealthEndpoint starts serving the health endpoint, if it is enabled.
+func (r *Runner) startHealthEndpoint() error {
+	c := r.config.HealthEndpoint
+	if c == nil || !config.BoolVal(c.Enabled) {
+		return nil
+	}
+
+	r.stopLock.Lock()
+	defer r.stopLock.Unlock()
+	if r.stopped {
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", config.StringVal(c.Address))
+	if err != nil {
+		return err
+	}
+	log.Printf("[INFO] (runner) serving health endpoint on %s", ln.Addr())
+
+	r.healthListener = ln
+	r.healthServer = &http.Server{
+		Handler:           r.healthHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func(srv *http.Server) {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Printf("[ERR] (runner) health endpoint: %s", err)
+		}
+	}(r.healthServer)
+	return nil
+}
+
+// stopHealthEndpoint stops serving the health endpoint, if it was started. It
+// must be called with the stop lock held.
+func (r *Runner) stopHealthEndpoint() {
+	if r.healthServer == nil {
+		return
+	}
+
+	log.Printf("[DEBUG] (runner) stopping health endpoint")
+	ctx, cancel := context.WithTimeout(context.Background(), healthShutdownTimeout)
+	defer cancel()
+	if err := r.healthServer.Shutdown(ctx); err != nil {
+		log.Printf("[WARN] (runner) stopping health endpoint: %s", err)
+	}
+	r.healthServer = nil
+}
@@ -0,0 +1,112 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package manager
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/consul-template/config"
+	dep "github.com/hashicorp/consul-template/dependency"
+)
+
+func TestRunner_healthEndpoint(t *testing.T) {
+	outDir := t.TempDir()
+	data := filepath.Join(outDir, "data")
+
+	d, err := dep.NewFileQuery(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := config.TestConfig(&config.Config{
+		HealthEndpoint: &config.HealthEndpointConfig{
+			Address: config.String("127.0.0.1:0"),
+		},
+		Templates: &config.TemplateConfigs{
+			&config.TemplateConfig{
+				Contents:    config.String(`{{ file "` + data + `" }}`),
+				Destination: config.String(filepath.Join(outDir, "file")),
+			},
+			&config.TemplateConfig{
+				Contents:    config.String(`static`),
+				Destination: config.String(filepath.Join(outDir, "static")),
+			},
+		},
+	})
+	r, err := NewRunner(c, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	if err := r.startHealthEndpoint(); err != nil {
+		t.Fatal(err)
+	}
+	addr := "http://" + r.healthListener.Addr().String()
+
+	// get requests the path of the endpoint and returns the status code and
+	// the decoded body.
+	get := func(t *testing.T, path string) (int, *healthStatus) {
+		t.Helper()
+		resp, err := http.Get(addr + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		var status healthStatus
+		if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, &status
+	}
+
+	// rendered returns whether each template is reported as rendered.
+	rendered := func(status *healthStatus) []bool {
+		var result []bool
+		for _, ts := range status.Templates {
+			result = append(result, ts.Rendered)
+		}
+		return result
+	}
+
+	if code, status := get(t, "/readyz"); code != http.StatusServiceUnavailable || status.Ready {
+		t.Errorf("expected 503 before the first render, got %d: %#v", code, status)
+	}
+
+	// The static template renders, but the other one waits for its data.
+	if err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
+	code, status := get(t, "/readyz")
+	if code != http.StatusServiceUnavailable || status.Ready {
+		t.Errorf("expected 503 until all templates render, got %d: %#v", code, status)
+	}
+	if act := rendered(status); len(act) != 2 || act[0] || !act[1] {
+		t.Errorf("expected only the static template to be rendered, got %v", act)
+	}
+	if code, _ := get(t, "/healthz"); code != http.StatusOK {
+		t.Errorf("expected /healthz to be 200, got %d", code)
+	}
+
+	r.brain.Remember(d, "a")
+	if err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
+	code, status = get(t, "/readyz")
+	if code != http.StatusOK || !status.Ready {
+		t.Errorf("expected 200 once all templates render, got %d: %#v", code, status)
+	}
+	if act := rendered(status); len(act) != 2 || !act[0] || !act[1] {
+		t.Errorf("expected all templates to be rendered, got %v", act)
+	}
+	for _, ts := range status.Templates {
+		if ts.LastRendered == nil {
+			t.Errorf("expected %s to have a last render time", ts.Destination)
+		}
+	}
+}
@@ -72,8 +72,12 @@ func TestMain(m *testing.M) {
 }
 
 func testDedupManager(t *testing.T, tmpls []*template.Template) *DedupManager {
+	return testDedupManagerConfig(t, nil, tmpls)
+}
+
+func testDedupManagerConfig(t *testing.T, c *config.DedupConfig, tmpls []*template.Template) *DedupManager {
 	brain := template.NewBrain()
-	dedupConfig := config.TestConfig(nil).Dedup
+	dedupConfig := config.TestConfig(&config.Config{Dedup: c}).Dedup
 	dedup, err := NewDedupManager(dedupConfig, testClients, brain, tmpls)
 	if err != nil {
 		t.Fatal(err)
@@ -0,0 +1,126 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+// +build linux
+
+package manager
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// reapDelay is how long a zombie child is left alone before it is reaped. The
+// processes started by consul-template itself are waited on as soon as they
+// exit, so a zombie that is still around after this is an orphan nobody waits
+// for.
+const reapDelay = time.Second
+
+// reapZombies makes this process a child subreaper, so orphaned descendants
+// are re-parented to it instead of to init, and reaps them until done is
+// closed.
+func reapZombies(done <-chan struct{}) {
+	if err := unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 1, 0, 0, 0); err != nil {
+		log.Printf("[WARN] (runner) could not become a child subreaper: %s", err)
+		return
+	}
+	defer unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 0, 0, 0, 0)
+	log.Printf("[DEBUG] (runner) reaping orphaned processes")
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGCHLD)
+	defer signal.Stop(sigCh)
+
+	seen := make(map[int]time.Time)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-sigCh:
+		case <-timer.C:
+		}
+
+		if reapOrphans(seen) {
+			timer.Reset(reapDelay)
+		}
+	}
+}
+
+// reapOrphans reaps the zombie children that were already seen at least
+// reapDelay ago. It reports whether zombies are left for a later pass.
+func reapOrphans(seen map[int]time.Time) bool {
+	zombies := zombieChildren()
+	for pid := range seen {
+		if !zombies[pid] {
+			delete(seen, pid)
+		}
+	}
+
+	now := time.Now()
+	pending := false
+	for pid := range zombies {
+		first, ok := seen[pid]
+		if !ok {
+			seen[pid] = now
+		}
+		if !ok || now.Sub(first) < reapDelay {
+			pending = true
+			continue
+		}
+
+		var status unix.WaitStatus
+		if wpid, err := unix.Wait4(pid, &status, unix.WNOHANG, nil); err == nil && wpid == pid {
+			log.Printf("[DEBUG] (runner) reaped orphaned process %d (exit status %d)",
+				pid, status.ExitStatus())
+		}
+		delete(seen, pid)
+	}
+	return pending
+}
+
+// zombieChildren returns the PIDs of the children of this process that have
+// exited but were not waited on yet.
+func zombieChildren() map[int]bool {
+	paths, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil {
+		return nil
+	}
+
+	self := os.Getpid()
+	zombies := make(map[int]bool)
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		// The process name is in parentheses and may contain spaces, the state
+		// and parent PID follow it.
+		i := bytes.LastIndexByte(b, ')')
+		if i < 0 {
+			continue
+		}
+		fields := bytes.Fields(b[i+1:])
+		if len(fields) < 2 || string(fields[0]) != "Z" {
+			continue
+		}
+		if ppid, err := strconv.Atoi(string(fields[1])); err != nil || ppid != self {
+			continue
+		}
+		if pid, err := strconv.Atoi(filepath.Base(filepath.Dir(path))); err == nil {
+			zombies[pid] = true
+		}
+	}
+	return zombies
+}
@@ -0,0 +1,59 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+// +build linux
+
+package manager
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReapZombies(t *testing.T) {
+	done := make(chan struct{})
+	go reapZombies(done)
+	defer close(done)
+
+	// Wait for the subreaper to be set up before orphaning the grandchild.
+	time.Sleep(100 * time.Millisecond)
+
+	// The shell exits right away, orphaning the backgrounded sleep, which is
+	// re-parented to this process and becomes a zombie once it exits.
+	out, err := exec.Command("sh", "-c", "sleep 0.2 >/dev/null & echo $!").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The orphan must have been re-parented to this process, or init would
+	// reap it instead.
+	b, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := strings.Fields(string(b[strings.LastIndexByte(string(b), ')')+1:]))
+	if exp := strconv.Itoa(os.Getpid()); fields[1] != exp {
+		t.Fatalf("expected orphan to be re-parented to %s, got parent %s", exp, fields[1])
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat("/proc/" + strconv.Itoa(pid)); os.IsNotExist(err) {
+			return
+		}
+		if time.Now().After(deadline) {
+			b, _ := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+			t.Fatalf("orphaned process %d was not reaped: %s", pid, b)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
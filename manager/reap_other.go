@@ -0,0 +1,14 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !linux
+// +build !linux
+
+package manager
+
+import "log"
+
+// reapZombies is a no-op, child subreapers are only supported on Linux.
+func reapZombies(<-chan struct{}) {
+	log.Printf("[WARN] (runner) reap_zombies is only supported on Linux, ignoring")
+}
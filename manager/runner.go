@@ -4,13 +4,20 @@
 package manager
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"path"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -20,10 +27,12 @@ import (
 	"github.com/hashicorp/consul-template/config"
 	dep "github.com/hashicorp/consul-template/dependency"
 	"github.com/hashicorp/consul-template/renderer"
+	"github.com/hashicorp/consul-template/telemetry"
 	"github.com/hashicorp/consul-template/template"
 	"github.com/hashicorp/consul-template/watch"
 
 	multierror "github.com/hashicorp/go-multierror"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -32,6 +41,10 @@ const (
 	viewLimit = 128
 )
 
+// ignoredDepRetryInterval is how long after its error was ignored a
+// dependency is fetched again.
+var ignoredDepRetryInterval = 30 * time.Second
+
 // Runner responsible rendering Templates and invoking Commands.
 type Runner struct {
 	// ErrCh and DoneCh are channels where errors and finish notifications occur.
@@ -71,14 +84,33 @@ type Runner struct {
 	// dependency sets.
 	renderEventCh chan struct{}
 
-	// dependencies is the list of dependencies this runner is watching.
+	// dependencies is the list of dependencies this runner is watching, keyed
+	// by depKey.
 	dependencies map[string]dep.Dependency
 
-	// dependenciesLock is a lock around touching the dependencies map.
+	// templateDeps maps the dependencies that cannot be shared, keyed by
+	// depKey, to the instance each template watches them with. Those have a
+	// view per template, which the watcher only finds by that instance.
+	templateDeps map[string]dep.Dependency
+
+	// dependenciesLock is a lock around touching the dependencies and
+	// templateDeps maps.
 	dependenciesLock sync.Mutex
 
-	// token watcher
-	vaultTokenWatcher *watch.Watcher
+	// releaseTimer fires on releaseCh when the grace period of the next
+	// dependency that is no longer used ends, so that a run releases it.
+	releaseTimer *time.Timer
+	releaseCh    chan struct{}
+
+	// ignoredDeps maps the dependencies whose fetch errors were ignored, which
+	// templates render without data, to the time they are fetched again. A run
+	// is sent on retryCh then. It is only changed between runs.
+	ignoredDeps map[string]time.Time
+	retryCh     chan struct{}
+
+	// token watchers
+	vaultTokenWatcher  *watch.Watcher
+	consulTokenWatcher *watch.Watcher
 	// watcher is the watcher this runner is using.
 	watcher *watch.Watcher
 
@@ -114,6 +146,30 @@ type Runner struct {
 	// stopped is a boolean of whether the runner is stopped
 	stopped bool
 
+	// drainCh is used to request a final run before stopping. The channel sent
+	// on it is closed once the run is complete.
+	drainCh chan chan struct{}
+
+	// traceCtx is the parent of the spans of each run. It carries the trace
+	// context consul-template was started with, if any.
+	traceCtx context.Context
+
+	// triggerVersions maps the destination of each template with a command
+	// trigger to the brain versions of its trigger dependencies when it last
+	// rendered, to tell if any of them changed since.
+	triggerVersions     map[string]map[string]uint64
+	triggerVersionsLock sync.Mutex
+
+	// envVars maps the environment variables of the exec child process to the
+	// rendered contents of the templates with an env_var.
+	envVars     map[string]string
+	envVarsLock sync.Mutex
+
+	// healthServer serves the health endpoint on healthListener, if it is
+	// enabled. Both are guarded by the stop lock.
+	healthServer   *http.Server
+	healthListener net.Listener
+
 	// finalConfigCopy provides access to a static copy of the finalized
 	// Runner config. This prevents risk of data races when reading config for
 	// other elements started by the Runner, like template functions.
@@ -152,6 +208,10 @@ type RenderEvent struct {
 	// dependencies parsed out of the template with the current data.
 	UsedDeps *dep.Set
 
+	// ToleratedDeps is the list of used dependencies whose fetch errors the
+	// template tolerates by rendering without their data.
+	ToleratedDeps *dep.Set
+
 	// WouldRender determines if the template would have been rendered. A template
 	// would have been rendered if all the dependencies are satisfied, but may
 	// not have actually rendered if the file was already present or if an error
@@ -187,19 +247,27 @@ func NewRunner(config *config.Config, dry bool) (*Runner, error) {
 		dry, config.Once)
 
 	runner := &Runner{
-		ErrCh:         make(chan error),
-		DoneCh:        make(chan struct{}),
-		config:        config,
-		dry:           dry,
-		inStream:      os.Stdin,
-		outStream:     os.Stdout,
-		errStream:     os.Stderr,
-		renderedCh:    make(chan struct{}, 1),
-		renderEventCh: make(chan struct{}, 1),
-		dependencies:  make(map[string]dep.Dependency),
-		brain:         template.NewBrain(),
-		quiescenceMap: make(map[string]*quiescence),
-		quiescenceCh:  make(chan *template.Template),
+		ErrCh:           make(chan error),
+		DoneCh:          make(chan struct{}),
+		config:          config,
+		dry:             dry,
+		inStream:        os.Stdin,
+		outStream:       os.Stdout,
+		errStream:       os.Stderr,
+		renderedCh:      make(chan struct{}, 1),
+		renderEventCh:   make(chan struct{}, 1),
+		dependencies:    make(map[string]dep.Dependency),
+		templateDeps:    make(map[string]dep.Dependency),
+		releaseCh:       make(chan struct{}, 1),
+		ignoredDeps:     make(map[string]time.Time),
+		retryCh:         make(chan struct{}, 1),
+		brain:           template.NewBrain(),
+		quiescenceMap:   make(map[string]*quiescence),
+		quiescenceCh:    make(chan *template.Template),
+		drainCh:         make(chan chan struct{}),
+		triggerVersions: make(map[string]map[string]uint64),
+		envVars:         make(map[string]string),
+		traceCtx:        telemetry.ContextFromEnv(os.Environ()),
 	}
 
 	// Create the clientset
@@ -216,6 +284,11 @@ func NewRunner(config *config.Config, dry bool) (*Runner, error) {
 	if err := runner.init(clients); err != nil {
 		return nil, err
 	}
+	runner.consulTokenWatcher, err = watch.ConsulTokenWatcher(
+		clients, config.Consul, runner.watcher, runner.DoneCh)
+	if err != nil {
+		return nil, err
+	}
 	runner.finalConfigCopy = *runner.config.Copy()
 	return runner, nil
 }
@@ -232,6 +305,18 @@ func (r *Runner) Start() {
 		return
 	}
 
+	// Serve the health endpoint, which reports the templates as not ready
+	// until they render.
+	if err := r.startHealthEndpoint(); err != nil {
+		r.ErrCh <- fmt.Errorf("runner: health endpoint: %w", err)
+		return
+	}
+
+	// Reap orphaned processes, e.g. when running as PID 1 in a container.
+	if config.BoolVal(r.config.ReapZombies) {
+		go reapZombies(r.DoneCh)
+	}
+
 	// Start the de-duplication manager
 	var dedupCh <-chan struct{}
 	if r.dedup != nil {
@@ -320,18 +405,17 @@ func (r *Runner) Start() {
 				log.Printf("[TRACE] (runner) acquired child lock for command, spawning")
 
 				if r.child == nil {
-					env := r.config.Exec.Env.Copy()
-					env.Custom = append(r.childEnv(), env.Custom...)
 					child, err := spawnChild(&spawnChildInput{
 						Stdin:        r.inStream,
 						Stdout:       r.outStream,
 						Stderr:       r.errStream,
 						Command:      r.config.Exec.Command,
-						Env:          env.Env(),
+						Env:          r.execEnv(),
 						ReloadSignal: config.SignalVal(r.config.Exec.ReloadSignal),
 						KillSignal:   config.SignalVal(r.config.Exec.KillSignal),
 						KillTimeout:  config.TimeDurationVal(r.config.Exec.KillTimeout),
 						Splay:        config.TimeDurationVal(r.config.Exec.Splay),
+						LogOutput:    config.BoolVal(r.config.Exec.LogChildOutput),
 					})
 					if err != nil {
 						r.ErrCh <- err
@@ -411,6 +495,14 @@ func (r *Runner) Start() {
 			break OUTER
 
 		case err := <-r.watcher.ErrCh():
+			var depErr *watch.DependencyError
+			if errors.As(err, &depErr) && r.ignoreDepError(depErr.Dependency) {
+				log.Printf("[WARN] (runner) ignoring error from %s (retry after %s): %s",
+					depErr.Dependency, ignoredDepRetryInterval, err)
+				r.ignoreDep(depErr.Dependency)
+				break OUTER
+			}
+
 			// Push the error back up the stack
 			log.Printf("[ERR] (runner) watcher reported error: %s", err)
 			r.ErrCh <- err
@@ -422,6 +514,18 @@ func (r *Runner) Start() {
 			r.ErrCh <- err
 			return
 
+		case err := <-r.consulTokenWatcher.ErrCh():
+			// Push the error back up the stack
+			log.Printf("[ERR] (runner): %s", err)
+			r.ErrCh <- err
+			return
+
+		case <-r.releaseCh:
+			log.Printf("[DEBUG] (runner) releasing dependencies no longer used")
+
+		case <-r.retryCh:
+			log.Printf("[DEBUG] (runner) retrying dependencies whose errors were ignored")
+
 		case tmpl := <-r.quiescenceCh:
 			// Remove the quiescence for this template from the map. This will force
 			// the upcoming Run call to actually evaluate and render the template.
@@ -436,6 +540,14 @@ func (r *Runner) Start() {
 		case <-r.DoneCh:
 			log.Printf("[INFO] (runner) received finish")
 			return
+
+		case doneCh := <-r.drainCh:
+			log.Printf("[INFO] (runner) draining, running templates a final time")
+			if err := r.Run(); err != nil {
+				log.Printf("[ERR] (runner) final run failed: %s", err)
+			}
+			close(doneCh)
+			return
 		}
 
 		// If we got this far, that means we got new data or one of the timers
@@ -458,6 +570,31 @@ func (r *Runner) StopImmediately() {
 	r.internalStop(true)
 }
 
+// Drain renders all templates a final time with the data currently in the
+// brain, executing any commands, and then stops the runner. It blocks until
+// the final run is complete or the timeout elapses, in which case the runner is
+// stopped anyway.
+func (r *Runner) Drain(timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	doneCh := make(chan struct{})
+	select {
+	case r.drainCh <- doneCh:
+		select {
+		case <-doneCh:
+			log.Printf("[INFO] (runner) drain complete")
+		case <-timer.C:
+			log.Printf("[WARN] (runner) drain did not complete within %s", timeout)
+		}
+	case <-timer.C:
+		log.Printf("[WARN] (runner) drain did not start within %s", timeout)
+	case <-r.DoneCh:
+	}
+
+	r.StopImmediately()
+}
+
 // TemplateRenderedCh returns a channel that will be triggered when one or more
 // templates are rendered.
 func (r *Runner) TemplateRenderedCh() <-chan struct{} {
@@ -495,6 +632,7 @@ func (r *Runner) internalStop(immediately bool) {
 	r.stopDedup()
 	r.stopWatchers()
 	r.stopChild(immediately)
+	r.stopHealthEndpoint()
 
 	if err := r.deletePid(); err != nil {
 		log.Printf("[WARN] (runner) could not remove pid at %q: %s",
@@ -522,6 +660,10 @@ func (r *Runner) stopWatchers() {
 		log.Printf("[DEBUG] (runner) stopping vault token watcher")
 		r.vaultTokenWatcher.Stop()
 	}
+	if r.consulTokenWatcher != nil {
+		log.Printf("[DEBUG] (runner) stopping consul token watcher")
+		r.consulTokenWatcher.Stop()
+	}
 }
 
 func (r *Runner) stopChild(immediately bool) {
@@ -560,10 +702,27 @@ func (r *Runner) Receive(d dep.Dependency, data interface{}) {
 	//     https://github.com/hashicorp/consul-template/issues/198
 	//
 	// and by "little" bug, I mean really big bug.
-	if _, ok := r.dependencies[d.String()]; ok {
+	if r.watchingDep(d) {
 		log.Printf("[DEBUG] (runner) receiving dependency %s", d)
 		r.brain.Remember(d, data)
+		delete(r.ignoredDeps, d.String())
+	}
+}
+
+// watchingDep reports whether the given dependency is one of the dependencies
+// of the runner. Those that cannot be shared must be the instance of one of
+// the templates. It must be called with the dependencies lock held.
+func (r *Runner) watchingDep(d dep.Dependency) bool {
+	if d.CanShare() {
+		_, ok := r.dependencies[d.String()]
+		return ok
+	}
+	for _, td := range r.dependencies {
+		if td == d {
+			return true
+		}
 	}
+	return false
 }
 
 // Signal sends a signal to the child process, if it exists. Any errors that
@@ -584,19 +743,24 @@ func (r *Runner) Signal(s os.Signal) error {
 // completes successfully, the optional commands will be executed, if given.
 // Please note that all templates are rendered **and then** any commands are
 // executed.
-func (r *Runner) Run() error {
+func (r *Runner) Run() (err error) {
 	log.Printf("[DEBUG] (runner) initiating run")
 
-	var newRenderEvent, wouldRenderAny, renderedAny bool
+	ctx, span := telemetry.Tracer().Start(r.traceCtx, "render")
+	defer func() { telemetry.End(span, err) }()
+
+	var newRenderEvent, wouldRenderAny, renderedAny, renderedEnv bool
 	runCtx := &templateRunCtx{
 		depsMap: make(map[string]dep.Dependency),
 	}
 
-	for _, tmpl := range r.templates {
-		event, err := r.runTemplate(tmpl, runCtx)
-		if err != nil {
-			return err
+	r.touchDeps()
+
+	for i, result := range r.runTemplates(ctx, runCtx) {
+		if result.err != nil {
+			return result.err
 		}
+		tmpl, event := r.templates[i], result.event
 
 		// If there was a render event store it
 		if event != nil {
@@ -615,6 +779,9 @@ func (r *Runner) Run() error {
 			// Record that at least one template was rendered.
 			if event.DidRender {
 				renderedAny = true
+				if r.rendersToEnv(r.templateConfigFor(tmpl)) {
+					renderedEnv = true
+				}
 			}
 		}
 	}
@@ -628,9 +795,13 @@ func (r *Runner) Run() error {
 	for _, t := range runCtx.commands {
 		log.Printf("[INFO] (runner) executing command %q from %s",
 			fmt.Sprintf("%q", t.Exec.Command), t.Display())
+		cmdCtx, cmdSpan := telemetry.Tracer().Start(ctx, "command")
+		if cmdSpan.IsRecording() {
+			cmdSpan.SetAttributes(attribute.StringSlice("command", t.Exec.Command))
+		}
 		env := t.Exec.Env.Copy()
-		env.Custom = append(r.childEnv(), env.Custom...)
-		if _, err := spawnChild(&spawnChildInput{
+		env.Custom = append(append(r.childEnv(), telemetry.Env(cmdCtx)...), env.Custom...)
+		_, err := spawnChild(&spawnChildInput{
 			Stdin:        r.inStream,
 			Stdout:       r.outStream,
 			Stderr:       r.errStream,
@@ -641,7 +812,10 @@ func (r *Runner) Run() error {
 			KillSignal:   config.SignalVal(t.Exec.KillSignal),
 			KillTimeout:  config.TimeDurationVal(t.Exec.KillTimeout),
 			Splay:        config.TimeDurationVal(t.Exec.Splay),
-		}); err != nil {
+			LogOutput:    config.BoolVal(t.Exec.LogChildOutput),
+		})
+		telemetry.End(cmdSpan, err)
+		if err != nil {
 			s := fmt.Sprintf("failed to execute command %q from %s",
 				fmt.Sprintf("%q", t.Exec.Command), t.Display())
 			errs = append(errs, errors.Wrap(err, s))
@@ -666,10 +840,16 @@ func (r *Runner) Run() error {
 	}
 
 	// If we got this far and have a child process, we need to send the reload
-	// signal to the child process.
+	// signal to the child process. A changed environment variable can only be
+	// picked up by restarting it.
 	if renderedAny && r.child != nil {
 		r.childLock.RLock()
-		if err := r.child.Reload(); err != nil {
+		if renderedEnv {
+			log.Printf("[INFO] (runner) restarting child process for changed environment variables")
+			if err := r.child.Restart(r.execEnv()); err != nil {
+				errs = append(errs, err)
+			}
+		} else if err := r.child.Reload(); err != nil {
 			errs = append(errs, err)
 		}
 		r.childLock.RUnlock()
@@ -688,6 +868,95 @@ func (r *Runner) Run() error {
 	return nil
 }
 
+// templateResult is the outcome of running a single template.
+type templateResult struct {
+	event *RenderEvent
+	err   error
+}
+
+// runTemplates runs each template and returns the results in the order of the
+// templates, up to and including the first error. Up to MaxRenderConcurrency
+// templates run in parallel, started in the order of the templates. Each of
+// them gets its own run context, which is merged into runCtx in template order
+// once all are done, so commands still run in the order of the templates. Like
+// when they run one after the other, no template is started after one failed;
+// only those already running when it failed still finish.
+func (r *Runner) runTemplates(ctx context.Context, runCtx *templateRunCtx) []templateResult {
+	results := make([]templateResult, len(r.templates))
+
+	workers := config.IntVal(r.config.MaxRenderConcurrency)
+	if workers <= 1 {
+		for i, tmpl := range r.templates {
+			results[i].event, results[i].err = r.traceTemplate(ctx, tmpl, runCtx)
+			if results[i].err != nil {
+				return results[:i+1]
+			}
+		}
+		return results
+	}
+
+	runCtxs := make([]*templateRunCtx, len(r.templates))
+	queue := make(chan int)
+	failedCh := make(chan struct{})
+	var failOnce sync.Once
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(r.templates); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				// Templates handed out as another one failed are skipped. They
+				// come after it, so their results are never looked at.
+				select {
+				case <-failedCh:
+					continue
+				default:
+				}
+
+				runCtxs[i] = &templateRunCtx{
+					depsMap: make(map[string]dep.Dependency),
+				}
+				results[i].event, results[i].err = r.traceTemplate(ctx, r.templates[i], runCtxs[i])
+				if results[i].err != nil {
+					failOnce.Do(func() { close(failedCh) })
+				}
+			}
+		}()
+	}
+QUEUE:
+	for i := range r.templates {
+		select {
+		case queue <- i:
+		case <-failedCh:
+			break QUEUE
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	for i := range results {
+		runCtx.merge(runCtxs[i])
+		if results[i].err != nil {
+			return results[:i+1]
+		}
+	}
+	return results
+}
+
+// traceTemplate runs the template in its own span.
+func (r *Runner) traceTemplate(ctx context.Context, tmpl *template.Template, runCtx *templateRunCtx) (*RenderEvent, error) {
+	_, span := telemetry.Tracer().Start(ctx, "template")
+	event, err := r.runTemplate(tmpl, runCtx)
+	if span.IsRecording() {
+		span.SetAttributes(attribute.String("template", tmpl.ID()))
+		if event != nil {
+			span.SetAttributes(attribute.Bool("rendered", event.DidRender))
+		}
+	}
+	telemetry.End(span, err)
+	return event, err
+}
+
 type templateRunCtx struct {
 	// commands is the set of commands that will be executed after all templates
 	// have run. When adding to the commands, care should be taken not to
@@ -698,6 +967,24 @@ type templateRunCtx struct {
 	depsMap map[string]dep.Dependency
 }
 
+// merge adds the dependencies and commands of another run context, skipping
+// the commands that are already present.
+func (c *templateRunCtx) merge(o *templateRunCtx) {
+	for k, d := range o.depsMap {
+		if _, ok := c.depsMap[k]; !ok {
+			c.depsMap[k] = d
+		}
+	}
+	for _, t := range o.commands {
+		if existing := findCommand(t, c.commands); existing != nil {
+			log.Printf("[DEBUG] (runner) skipping command %q from %s (already appended from %s)",
+				t.Exec.Command, t.Display(), existing.Display())
+			continue
+		}
+		c.commands = append(c.commands, t)
+	}
+}
+
 // runTemplate is used to run a particular template. It takes as input the
 // template to run and a shared run context that allows sharing of information
 // between templates. The run returns a potentially nil render event and any
@@ -742,14 +1029,51 @@ func (r *Runner) runTemplate(tmpl *template.Template, runCtx *templateRunCtx) (*
 		}
 	}
 
-	// Attempt to render the template, returning any missing dependencies and
-	// the rendered contents. If there are any missing dependencies, the
-	// contents cannot be rendered or trusted!
-	result, err := tmpl.Execute(&template.ExecuteInput{
+	templateConfig := r.templateConfigFor(tmpl)
+	fanOut := templateConfig != nil && config.BoolVal(templateConfig.FanOut)
+
+	// Templates that render once are skipped like in once mode after their
+	// first render. Their dependencies are then no longer used, so they stop
+	// being watched unless another template uses them.
+	if templateConfig != nil && config.BoolVal(templateConfig.RenderOnce) &&
+		lastEvent != nil && (lastEvent.WouldRender || lastEvent.DidRender) {
+		log.Printf("[DEBUG] (runner) %s is rendered once and already rendered", templateConfig.Display())
+		return nil, nil
+	}
+
+	executeInput := &template.ExecuteInput{
 		Brain:  r.brain,
 		Env:    r.childEnv(),
 		Config: &r.finalConfigCopy,
-	})
+	}
+
+	// Streamed templates are executed straight into a temporary file next to
+	// the destination, which the renderer moves into place. Dry mode keeps
+	// buffering so nothing is written near the destination. Fan-out templates
+	// have no single output to stream.
+	var stream *renderer.Stream
+	if templateConfig != nil && config.BoolVal(templateConfig.Stream) && !r.dry && !fanOut {
+		var err error
+		stream, err = renderer.NewStream(config.StringVal(templateConfig.Destination))
+		if err == nil {
+			defer stream.Close()
+			executeInput.Writer, err = renderer.NewEncoder(stream,
+				config.StringVal(templateConfig.LineEnding), config.BoolVal(templateConfig.BOM))
+		}
+		if err != nil {
+			if tmpl.ErrFatal() {
+				return nil, errors.Wrap(err, "error rendering "+templateConfig.Display())
+			}
+			log.Printf("[ERR] (runner) error rendering: %s: %v", templateConfig.Display(), err)
+			event.Error = err
+			return event, nil
+		}
+	}
+
+	// Attempt to render the template, returning any missing dependencies and
+	// the rendered contents. If there are any missing dependencies, the
+	// contents cannot be rendered or trusted!
+	result, err := tmpl.Execute(executeInput)
 	if err != nil {
 		if tmpl.ErrFatal() {
 			return nil, errors.Wrap(err, tmpl.Source())
@@ -760,18 +1084,20 @@ func (r *Runner) runTemplate(tmpl *template.Template, runCtx *templateRunCtx) (*
 		if lastEvent != nil {
 			// Keep watching our dependencies so that we retry when they update.
 			for _, d := range lastEvent.UsedDeps.List() {
-				if _, ok := runCtx.depsMap[d.String()]; !ok {
-					runCtx.depsMap[d.String()] = d
+				if _, ok := runCtx.depsMap[depKey(tmpl, d)]; !ok {
+					runCtx.depsMap[depKey(tmpl, d)] = d
 				}
 			}
 			event.UsedDeps = lastEvent.UsedDeps
+			event.ToleratedDeps = lastEvent.ToleratedDeps
 		}
 
 		return event, nil
 	}
 
 	// Grab the list of used and missing dependencies.
-	missing, used := result.Missing, result.Used
+	missing, used, tolerated := result.Missing, result.Used, result.Tolerated
+	missing, used = r.instancesFor(tmpl, missing), r.instancesFor(tmpl, used)
 
 	if l := missing.Len(); l > 0 {
 		log.Printf("[DEBUG] (runner) missing data for %d dependencies", l)
@@ -789,9 +1115,27 @@ func (r *Runner) runTemplate(tmpl *template.Template, runCtx *templateRunCtx) (*
 			log.Printf("[DEBUG] (runner) add used dependency %s to missing since isLeader but do not have a watcher", d)
 			missing.Add(d)
 		}
-		if _, ok := runCtx.depsMap[d.String()]; !ok {
-			runCtx.depsMap[d.String()] = d
+		if _, ok := runCtx.depsMap[depKey(tmpl, d)]; !ok {
+			runCtx.depsMap[depKey(tmpl, d)] = d
+		}
+		r.brain.MarkUsed(d)
+	}
+
+	// Dependencies whose fetch errors are ignored have no data, so the template
+	// renders the same as before their data would have arrived.
+	if len(r.ignoredDeps) > 0 {
+		filtered := new(dep.Set)
+		for _, d := range missing.List() {
+			if retryAt, ok := r.ignoredDeps[d.String()]; ok && ignoresDepError(templateConfig, tolerated, d) {
+				log.Printf("[DEBUG] (runner) rendering without data for %s (error ignored)", d)
+				if !time.Now().Before(retryAt) && !r.watcher.Watching(d) && (isLeader || !d.CanShare()) {
+					r.watcher.Add(d)
+				}
+				continue
+			}
+			filtered.Add(d)
 		}
+		missing = filtered
 	}
 
 	// Diff any missing dependencies the template reported with dependencies
@@ -807,6 +1151,7 @@ func (r *Runner) runTemplate(tmpl *template.Template, runCtx *templateRunCtx) (*
 	event.MissingDeps = missing
 	event.UnwatchedDeps = unwatched
 	event.UsedDeps = used
+	event.ToleratedDeps = tolerated
 	event.UpdatedAt = time.Now().UTC()
 
 	// If there are unwatched dependencies, start the watcher and exit since we
@@ -846,14 +1191,28 @@ func (r *Runner) runTemplate(tmpl *template.Template, runCtx *templateRunCtx) (*
 		return event, nil
 	}
 
+	// Templates showing their render generation embed it in the output, so a
+	// change is detected against the current generation and the template is
+	// executed again with the next one.
+	if templateConfig != nil && result.UsesGeneration && stream == nil && !fanOut {
+		result, err = r.advanceGeneration(tmpl, templateConfig, executeInput, result)
+		if err != nil {
+			if tmpl.ErrFatal() {
+				return nil, errors.Wrap(err, "error rendering "+templateConfig.Display())
+			}
+			log.Printf("[ERR] (runner) error rendering: %s: %v", templateConfig.Display(), err)
+			event.Error = err
+			return event, nil
+		}
+	}
+
 	// For each template configuration that is tied to this template, attempt to
 	// render it to disk and accumulate commands for later use.
-	templateConfig := r.templateConfigFor(tmpl)
 	if templateConfig != nil {
 		log.Printf("[DEBUG] (runner) rendering %s", templateConfig.Display())
 
 		// Render the template, taking dry mode into account
-		result, err := renderer.Render(&renderer.RenderInput{
+		renderInput := &renderer.RenderInput{
 			Backup:         config.BoolVal(templateConfig.Backup),
 			Contents:       result.Output,
 			CreateDestDirs: config.BoolVal(templateConfig.CreateDestDirs),
@@ -863,7 +1222,19 @@ func (r *Runner) runTemplate(tmpl *template.Template, runCtx *templateRunCtx) (*
 			Perms:          config.FileModeVal(templateConfig.Perms),
 			User:           config.StringVal(templateConfig.User),
 			Group:          config.StringVal(templateConfig.Group),
-		})
+			Stream:         stream,
+			LineEnding:     config.StringVal(templateConfig.LineEnding),
+			BOM:            config.BoolVal(templateConfig.BOM),
+			Validate:       config.StringVal(templateConfig.Validate),
+		}
+		var rendered *renderer.RenderResult
+		if fanOut {
+			rendered, err = r.renderFanOut(renderInput, result.Emitted)
+		} else if r.rendersToEnv(templateConfig) {
+			rendered, err = r.renderEnvVar(config.StringVal(templateConfig.MapToEnvironmentVariable), result.Output)
+		} else {
+			rendered, err = renderer.Render(renderInput)
+		}
 		if err != nil {
 			if tmpl.ErrFatal() {
 				return nil, errors.Wrap(err, "error rendering "+templateConfig.Display())
@@ -875,12 +1246,21 @@ func (r *Runner) runTemplate(tmpl *template.Template, runCtx *templateRunCtx) (*
 
 		renderTime := time.Now().UTC()
 
+		// The data of every completed render is recorded, so that functions
+		// like servicesDelta report the changes since this render, however
+		// many updates arrive before the next one.
+		r.brain.SetRendered(config.StringVal(templateConfig.Destination), used.List())
+
+		// The trigger dependencies are recorded on every render, so that only
+		// their changes since the last render run the command.
+		triggered := r.commandTriggered(templateConfig, used)
+
 		// If we would have rendered this template (but we did not because the
 		// contents were the same or something), we should consider this template
 		// rendered even though the contents on disk have not been updated. We
 		// will not fire commands unless the template was _actually_ rendered to
 		// disk though.
-		if result.WouldRender {
+		if rendered.WouldRender {
 			// This event would have rendered
 			event.WouldRender = true
 			event.LastWouldRender = renderTime
@@ -888,7 +1268,7 @@ func (r *Runner) runTemplate(tmpl *template.Template, runCtx *templateRunCtx) (*
 
 		// If we _actually_ rendered the template to disk, we want to run the
 		// appropriate commands.
-		if result.DidRender {
+		if rendered.DidRender {
 			log.Printf("[INFO] (runner) rendered %s", templateConfig.Display())
 
 			// This event did render
@@ -896,7 +1276,7 @@ func (r *Runner) runTemplate(tmpl *template.Template, runCtx *templateRunCtx) (*
 			event.LastDidRender = renderTime
 
 			// Update the contents
-			event.Contents = result.Contents
+			event.Contents = rendered.Contents
 
 			if !r.dry {
 				// If the template was rendered (changed) and we are not in dry-run mode,
@@ -910,7 +1290,10 @@ func (r *Runner) runTemplate(tmpl *template.Template, runCtx *templateRunCtx) (*
 				// relative ordering and people would be unhappy.
 				if c := templateConfig.Exec.Command; !c.Empty() {
 					existing := findCommand(templateConfig, runCtx.commands)
-					if existing != nil {
+					if !triggered {
+						log.Printf("[DEBUG] (runner) skipping command %q from %s (no command trigger changed)",
+							c, templateConfig.Display())
+					} else if existing != nil {
 						log.Printf("[DEBUG] (runner) skipping command %q from %s (already appended from %s)",
 							c, templateConfig.Display(), existing.Display())
 					} else {
@@ -926,6 +1309,80 @@ func (r *Runner) runTemplate(tmpl *template.Template, runCtx *templateRunCtx) (*
 	return event, nil
 }
 
+// renderFanOut renders the files emitted by a fan-out template into the
+// directory at the input's Path, removing those its previous render emitted
+// but this one did not.
+func (r *Runner) renderFanOut(input *renderer.RenderInput, emitted map[string][]byte) (*renderer.RenderResult, error) {
+	result, err := renderer.RenderFanOut(&renderer.FanOutInput{
+		RenderInput: *input,
+		Outputs:     emitted,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Dry mode does not remove anything, it only reports what would be.
+	if !r.dry {
+		for _, name := range result.Removed {
+			log.Printf("[INFO] (runner) removed %s, which is no longer emitted",
+				filepath.Join(input.Path, name))
+		}
+	}
+
+	return &renderer.RenderResult{
+		DidRender:   result.DidRender,
+		WouldRender: result.WouldRender,
+	}, nil
+}
+
+// rendersToEnv reports if the template is rendered to an environment variable
+// of the exec child process, which is only the case in exec mode.
+func (r *Runner) rendersToEnv(tc *config.TemplateConfig) bool {
+	return tc != nil && config.StringPresent(tc.MapToEnvironmentVariable) && !r.config.Exec.Command.Empty()
+}
+
+// renderEnvVar sets the environment variable of the exec child process to the
+// rendered contents, which must be a single line with an optional line break
+// at the end. Like other renders, it did render only if the value changed and
+// not in dry mode.
+func (r *Runner) renderEnvVar(name string, contents []byte) (*renderer.RenderResult, error) {
+	value := strings.TrimSuffix(strings.TrimSuffix(string(contents), "\n"), "\r")
+	if strings.ContainsAny(value, "\r\n") {
+		return nil, fmt.Errorf("contents of environment variable %s must be a single line", name)
+	}
+
+	r.envVarsLock.Lock()
+	previous, ok := r.envVars[name]
+	r.envVars[name] = value
+	r.envVarsLock.Unlock()
+
+	return &renderer.RenderResult{
+		DidRender:   (!ok || previous != value) && !r.dry,
+		WouldRender: true,
+		Contents:    []byte(value),
+	}, nil
+}
+
+// execEnv returns the environment of the exec child process, including the
+// environment variables rendered by templates.
+func (r *Runner) execEnv() []string {
+	env := r.config.Exec.Env.Copy()
+	env.Custom = append(r.childEnv(), env.Custom...)
+
+	r.envVarsLock.Lock()
+	names := make([]string, 0, len(r.envVars))
+	for name := range r.envVars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env.Custom = append(env.Custom, name+"="+r.envVars[name])
+	}
+	r.envVarsLock.Unlock()
+
+	return env.Env()
+}
+
 // init() creates the Runner's underlying data structures and returns an error
 // if any problems occur.
 func (r *Runner) init(clients *dep.ClientSet) error {
@@ -942,6 +1399,7 @@ func (r *Runner) init(clients *dep.ClientSet) error {
 
 	dep.SetVaultDefaultLeaseDuration(config.TimeDurationVal(r.config.Vault.DefaultLeaseDuration))
 	dep.SetVaultLeaseRenewalThreshold(*r.config.Vault.LeaseRenewalThreshold)
+	dep.SetVaultPKIRenewThreshold(*r.config.Vault.PKIRenewThreshold)
 
 	// Create the watcher
 	r.watcher = newWatcher(r.config, clients)
@@ -980,6 +1438,20 @@ func (r *Runner) init(clients *dep.ClientSet) error {
 			return err
 		}
 
+		if r.rendersToEnv(ctmpl) && (config.StringPresent(ctmpl.Destination) ||
+			config.BoolVal(ctmpl.Stream) || config.BoolVal(ctmpl.FanOut)) {
+			return fmt.Errorf("runner: %s: env_var cannot be used with destination, stream or fan_out",
+				ctmpl.Display())
+		}
+
+		if path := config.StringVal(ctmpl.GenerationFile); path != "" {
+			generation, err := readGeneration(path)
+			if err != nil {
+				return err
+			}
+			r.brain.SetGeneration(config.StringVal(ctmpl.Destination), generation)
+		}
+
 		templates = append(templates, tmpl)
 	}
 
@@ -1003,8 +1475,128 @@ func (r *Runner) init(clients *dep.ClientSet) error {
 	return nil
 }
 
+// advanceGeneration bumps the render generation of the template's destination
+// when the output differs from what is on disk and returns the output of
+// executing the template again with the new generation. Output that matches
+// the destination is a no-op render and keeps the current generation.
+func (r *Runner) advanceGeneration(tmpl *template.Template, tc *config.TemplateConfig,
+	input *template.ExecuteInput, result *template.ExecuteResult,
+) (*template.ExecuteResult, error) {
+	dest := config.StringVal(tc.Destination)
+	contents, err := renderer.Encode(result.Output,
+		config.StringVal(tc.LineEnding), config.BoolVal(tc.BOM))
+	if err != nil {
+		return result, err
+	}
+	changed, err := renderer.Changed(dest, contents)
+	if err != nil || !changed {
+		return result, err
+	}
+
+	generation := r.brain.Generation(dest) + 1
+	r.brain.SetGeneration(dest, generation)
+	log.Printf("[DEBUG] (runner) %s: render generation is now %d", tc.Display(), generation)
+
+	if path := config.StringVal(tc.GenerationFile); path != "" && !r.dry {
+		if err := writeGeneration(path, generation); err != nil {
+			return nil, err
+		}
+	}
+
+	return tmpl.Execute(input)
+}
+
+// readGeneration reads a render generation persisted by writeGeneration. A
+// missing file is generation zero.
+func readGeneration(path string) (uint64, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "runner: failed reading generation file")
+	}
+	generation, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("runner: invalid generation file %q: %w", path, err)
+	}
+	return generation, nil
+}
+
+// writeGeneration atomically persists the render generation to path.
+func writeGeneration(path string, generation uint64) error {
+	contents := []byte(strconv.FormatUint(generation, 10) + "\n")
+	if err := renderer.AtomicWrite(path, true, contents, 0o644, false); err != nil {
+		return errors.Wrap(err, "runner: failed writing generation file")
+	}
+	return nil
+}
+
+// touchDeps records the last successful fetch of every dependency in the
+// brain, so that data confirmed unchanged by its fetches does not age.
+func (r *Runner) touchDeps() {
+	r.dependenciesLock.Lock()
+	defer r.dependenciesLock.Unlock()
+
+	for _, d := range r.dependencies {
+		if fetched, ok := r.watcher.FetchedAt(d); ok {
+			r.brain.Touch(d, fetched)
+		}
+	}
+}
+
+// depKey returns the key of a dependency of the given template in the
+// dependencies of the runner. Dependencies that cannot be shared are watched
+// per template, so they are keyed per template too.
+func depKey(tmpl *template.Template, d dep.Dependency) string {
+	if d.CanShare() {
+		return d.String()
+	}
+	return tmpl.ID() + " " + d.String()
+}
+
+// instancesFor returns the given set with the dependencies that cannot be
+// shared replaced by the instances the template watches them with. Every
+// execution of a template creates new instances, but only the first one seen
+// has a view.
+func (r *Runner) instancesFor(tmpl *template.Template, s *dep.Set) *dep.Set {
+	r.dependenciesLock.Lock()
+	defer r.dependenciesLock.Unlock()
+
+	instances := new(dep.Set)
+	for _, d := range s.List() {
+		if !d.CanShare() {
+			key := depKey(tmpl, d)
+			if td, ok := r.templateDeps[key]; ok {
+				d = td
+			} else {
+				r.templateDeps[key] = d
+			}
+		}
+		instances.Add(d)
+	}
+	return instances
+}
+
+// dataInUse reports whether another template still watches a dependency that
+// cannot be shared, whose data in the brain must then be kept.
+func dataInUse(depsMap map[string]dep.Dependency, d dep.Dependency) bool {
+	if d.CanShare() {
+		return false
+	}
+	for _, td := range depsMap {
+		if td.String() == d.String() {
+			return true
+		}
+	}
+	return false
+}
+
 // diffAndUpdateDeps iterates through the current map of dependencies on this
 // runner and stops the watcher for any deps that are no longer required.
+// Dependencies the latest render did not use, like those only used in a branch
+// that is no longer taken, are kept until the dependency grace period since
+// their last use ends, and a run is scheduled to release them then.
 //
 // At the end of this function, the given depsMap is converted to a slice and
 // stored on the runner.
@@ -1015,17 +1607,47 @@ func (r *Runner) diffAndUpdateDeps(depsMap map[string]dep.Dependency) {
 	// Diff and up the list of dependencies, stopping any unneeded watchers.
 	log.Printf("[DEBUG] (runner) diffing and updating dependencies")
 
+	grace := config.TimeDurationVal(r.config.DependencyGracePeriod)
+	var nextRelease time.Duration
 	for key, d := range r.dependencies {
-		if _, ok := depsMap[key]; !ok {
-			log.Printf("[DEBUG] (runner) %s is no longer needed", d)
-			r.watcher.Remove(d)
-			r.brain.Forget(d)
-		} else {
+		if _, ok := depsMap[key]; ok {
 			log.Printf("[DEBUG] (runner) %s is still needed", d)
+			continue
+		}
+
+		if lastUsed, ok := r.brain.LastUsed(d); ok && grace > 0 {
+			if remaining := grace - time.Since(lastUsed); remaining > 0 {
+				log.Printf("[DEBUG] (runner) %s is no longer used, releasing in %s", d, remaining)
+				depsMap[key] = d
+				if nextRelease == 0 || remaining < nextRelease {
+					nextRelease = remaining
+				}
+				continue
+			}
+		}
+
+		log.Printf("[DEBUG] (runner) %s is no longer needed", d)
+		r.watcher.Remove(d)
+		delete(r.templateDeps, key)
+		if !dataInUse(depsMap, d) {
+			r.brain.Forget(d)
 		}
 	}
 
 	r.dependencies = depsMap
+
+	if r.releaseTimer != nil {
+		r.releaseTimer.Stop()
+		r.releaseTimer = nil
+	}
+	if nextRelease > 0 {
+		r.releaseTimer = time.AfterFunc(nextRelease, func() {
+			select {
+			case r.releaseCh <- struct{}{}:
+			default:
+			}
+		})
+	}
 }
 
 // TemplateConfigFor returns the TemplateConfig for the given Template
@@ -1033,6 +1655,98 @@ func (r *Runner) templateConfigFor(tmpl *template.Template) *config.TemplateConf
 	return tmpl.Config()
 }
 
+// ignoreDepError reports if the fetch error of the dependency is ignored, which
+// it is when every template using the dependency ignores it.
+func (r *Runner) ignoreDepError(d dep.Dependency) bool {
+	r.renderEventsLock.RLock()
+	defer r.renderEventsLock.RUnlock()
+
+	ignored := false
+	for _, tmpl := range r.templates {
+		event, ok := r.renderEvents[tmpl.ID()]
+		if !ok || event.UsedDeps == nil || event.UsedDeps.Get(d.String()) == nil {
+			continue
+		}
+		if !ignoresDepError(r.templateConfigFor(tmpl), event.ToleratedDeps, d) {
+			return false
+		}
+		ignored = true
+	}
+	return ignored
+}
+
+// ignoreDep stops the view of a dependency whose fetch error is ignored and
+// forgets its data, so templates render without it. The view is started again
+// by the first run after the retry interval, which a timer triggers. The
+// stopped instance cannot be fetched again, so templates watching it with
+// their own instance use the next one they create instead.
+func (r *Runner) ignoreDep(d dep.Dependency) {
+	r.watcher.Remove(d)
+	r.brain.Forget(d)
+
+	r.dependenciesLock.Lock()
+	for key, td := range r.templateDeps {
+		if td == d {
+			delete(r.templateDeps, key)
+		}
+	}
+	r.dependenciesLock.Unlock()
+
+	r.ignoredDeps[d.String()] = time.Now().Add(ignoredDepRetryInterval)
+	time.AfterFunc(ignoredDepRetryInterval, func() {
+		select {
+		case r.retryCh <- struct{}{}:
+		default:
+		}
+	})
+}
+
+// ignoresDepError reports if the dependency is one the template tolerates the
+// fetch errors of, or matches one of the patterns of its ignore_dep_errors.
+func ignoresDepError(tc *config.TemplateConfig, tolerated *dep.Set, d dep.Dependency) bool {
+	if tolerated != nil && tolerated.Get(d.String()) != nil {
+		return true
+	}
+	if tc == nil {
+		return false
+	}
+	return matchesDep(tc.IgnoreDepErrors, d)
+}
+
+// matchesDep reports if the dependency matches one of the glob patterns.
+func matchesDep(patterns []string, d dep.Dependency) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, d.String()); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// commandTriggered reports if any of the used dependencies matching the
+// template's command_trigger changed since the template last rendered, and
+// records their current versions for the next render. The first render is
+// always triggered, as is every render of a template without triggers.
+func (r *Runner) commandTriggered(tc *config.TemplateConfig, used *dep.Set) bool {
+	if len(tc.CommandTrigger) == 0 {
+		return true
+	}
+
+	versions := make(map[string]uint64)
+	for _, d := range used.List() {
+		if matchesDep(tc.CommandTrigger, d) {
+			versions[d.String()] = r.brain.Version(d)
+		}
+	}
+
+	destination := config.StringVal(tc.Destination)
+	r.triggerVersionsLock.Lock()
+	defer r.triggerVersionsLock.Unlock()
+	last, ok := r.triggerVersions[destination]
+	r.triggerVersions[destination] = versions
+	return !ok || !reflect.DeepEqual(last, versions)
+}
+
 // TemplateConfigMapping returns a mapping between the template ID and the set
 // of TemplateConfig represented by the template ID
 func (r *Runner) TemplateConfigMapping() map[string][]*config.TemplateConfig {
@@ -1148,7 +1862,10 @@ func (r *Runner) childEnv() []string {
 	return e
 }
 
-// storePid is used to write out a PID file to disk.
+// storePid is used to write out a PID file to disk. It fails if the file
+// holds the PID of another running process. The file is created exclusively,
+// so when several instances start at once only one of them gets it and the
+// others fail.
 func (r *Runner) storePid() error {
 	path := config.StringVal(r.config.PidFile)
 	if path == "" {
@@ -1157,21 +1874,55 @@ func (r *Runner) storePid() error {
 
 	log.Printf("[INFO] creating pid file at %q", path)
 
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o666)
+	// Write to a temporary file and link it into place so the pid file never
+	// holds a partial PID. Unlike a rename, the link fails if the pid file
+	// already exists.
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
 	if err != nil {
 		return fmt.Errorf("runner: could not open pid file: %s", err)
 	}
-	defer f.Close()
+	defer os.Remove(f.Name())
 
-	pid := os.Getpid()
-	_, err = f.WriteString(fmt.Sprintf("%d", pid))
-	if err != nil {
+	if _, err := f.WriteString(strconv.Itoa(os.Getpid())); err != nil {
+		f.Close()
 		return fmt.Errorf("runner: could not write to pid file: %s", err)
 	}
-	return nil
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		return fmt.Errorf("runner: could not write to pid file: %s", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("runner: could not write to pid file: %s", err)
+	}
+
+	for retried := false; ; retried = true {
+		err := os.Link(f.Name(), path)
+		if err == nil {
+			return nil
+		}
+		if !os.IsExist(err) {
+			return fmt.Errorf("runner: could not write to pid file: %s", err)
+		}
+
+		pid, ok := readPid(path)
+		if ok && pid != os.Getpid() && processRunning(pid) {
+			return fmt.Errorf("runner: pid file %q is in use by running process %d", path, pid)
+		}
+		if retried {
+			// The stale file was replaced by another instance starting at the
+			// same time.
+			return fmt.Errorf("runner: pid file %q was created by another process", path)
+		}
+
+		// The file is stale or our own, so replace it.
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("runner: could not remove stale pid file: %s", err)
+		}
+	}
 }
 
-// deletePid is used to remove the PID on exit.
+// deletePid is used to remove the PID on exit. The file is left in place if it
+// holds the PID of another process.
 func (r *Runner) deletePid() error {
 	path := config.StringVal(r.config.PidFile)
 	if path == "" {
@@ -1188,6 +1939,11 @@ func (r *Runner) deletePid() error {
 		return fmt.Errorf("runner: specified pid file path is directory")
 	}
 
+	if pid, ok := readPid(path); ok && pid != os.Getpid() {
+		log.Printf("[DEBUG] not removing pid file at %q owned by process %d", path, pid)
+		return nil
+	}
+
 	err = os.Remove(path)
 	if err != nil {
 		return fmt.Errorf("runner: could not remove pid file: %s", err)
@@ -1195,6 +1951,20 @@ func (r *Runner) deletePid() error {
 	return nil
 }
 
+// readPid returns the PID stored in the pid file at the given path. It returns
+// false if the file does not exist or does not hold a PID.
+func readPid(path string) (int, bool) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+	return pid, true
+}
+
 // SetOutStream modifies runner output stream. Defaults to stdout.
 func (r *Runner) SetOutStream(out io.Writer) {
 	r.outStream = out
@@ -1217,6 +1987,10 @@ type spawnChildInput struct {
 	KillSignal   os.Signal
 	KillTimeout  time.Duration
 	Splay        time.Duration
+
+	// LogOutput sends the output of the child to the logger instead of Stdout
+	// and Stderr.
+	LogOutput bool
 }
 
 // spawnChild spawns a child process with the given inputs and returns the
@@ -1239,6 +2013,8 @@ func spawnChild(i *spawnChildInput) (*child.Child, error) {
 		KillTimeout:  i.KillTimeout,
 		Splay:        i.Splay,
 		Setpgid:      subshell, // setpgid for subshells to propagate signals
+		LogOutput:    i.LogOutput,
+		Name:         childName(i.Command),
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, "error creating child")
@@ -1250,6 +2026,20 @@ func spawnChild(i *spawnChildInput) (*child.Child, error) {
 	return child, nil
 }
 
+// childName returns the name of the command to log the output of its child
+// under. It is the base name of the program, even when the command is run in
+// a subshell.
+func childName(command []string) string {
+	if len(command) == 0 {
+		return ""
+	}
+	fields := strings.Fields(command[0])
+	if len(fields) == 0 {
+		return ""
+	}
+	return filepath.Base(fields[0])
+}
+
 // quiescence is an internal representation of a single template's quiescence
 // state.
 type quiescence struct {
@@ -1259,6 +2049,10 @@ type quiescence struct {
 	ch       chan *template.Template
 	timer    *time.Timer
 	deadline time.Time
+
+	// lock protects the timer from templates with the same contents, which
+	// share the quiescence, ticking it in parallel.
+	lock sync.Mutex
 }
 
 // newQuiescence creates a new quiescence timer for the given template.
@@ -1273,6 +2067,9 @@ func newQuiescence(ch chan *template.Template, min, max time.Duration, t *templa
 
 // tick updates the minimum quiescence timer.
 func (q *quiescence) tick() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
 	now := time.Now()
 
 	// If this is the first tick, set up the timer and calculate the max
@@ -1319,6 +2116,10 @@ func NewClientSet(c *config.Config) (*dep.ClientSet, error) {
 	if err := clients.CreateConsulClient(&dep.CreateConsulClientInput{
 		Address:                      config.StringVal(c.Consul.Address),
 		Namespace:                    config.StringVal(c.Consul.Namespace),
+		Partition:                    config.StringVal(c.Consul.Partition),
+		Proxy:                        config.StringVal(c.Consul.Proxy),
+		RateLimitQPS:                 rateLimitQPS(c.Consul.RateLimit),
+		RateLimitBurst:               config.IntVal(c.Consul.RateLimit.Burst),
 		Token:                        config.StringVal(c.Consul.Token),
 		TokenFile:                    config.StringVal(c.Consul.TokenFile),
 		AuthEnabled:                  config.BoolVal(c.Consul.Auth.Enabled),
@@ -1334,9 +2135,11 @@ func NewClientSet(c *config.Config) (*dep.ClientSet, error) {
 		TransportDialKeepAlive:       config.TimeDurationVal(c.Consul.Transport.DialKeepAlive),
 		TransportDialTimeout:         config.TimeDurationVal(c.Consul.Transport.DialTimeout),
 		TransportDisableKeepAlives:   config.BoolVal(c.Consul.Transport.DisableKeepAlives),
+		TransportEnableHTTP2:         config.BoolVal(c.Consul.Transport.EnableHTTP2),
 		TransportIdleConnTimeout:     config.TimeDurationVal(c.Consul.Transport.IdleConnTimeout),
 		TransportMaxIdleConns:        config.IntVal(c.Consul.Transport.MaxIdleConns),
 		TransportMaxIdleConnsPerHost: config.IntVal(c.Consul.Transport.MaxIdleConnsPerHost),
+		TransportMaxConnsPerHost:     config.IntVal(c.Consul.Transport.MaxConnsPerHost),
 		TransportTLSHandshakeTimeout: config.TimeDurationVal(c.Consul.Transport.TLSHandshakeTimeout),
 	}); err != nil {
 		return nil, fmt.Errorf("runner: %s", err)
@@ -1345,6 +2148,9 @@ func NewClientSet(c *config.Config) (*dep.ClientSet, error) {
 	if err := clients.CreateVaultClient(&dep.CreateVaultClientInput{
 		Address:                      config.StringVal(c.Vault.Address),
 		Namespace:                    config.StringVal(c.Vault.Namespace),
+		Proxy:                        config.StringVal(c.Vault.Proxy),
+		RateLimitQPS:                 rateLimitQPS(c.Vault.RateLimit),
+		RateLimitBurst:               config.IntVal(c.Vault.RateLimit.Burst),
 		Token:                        config.StringVal(c.Vault.Token),
 		UnwrapToken:                  config.BoolVal(c.Vault.UnwrapToken),
 		SSLEnabled:                   config.BoolVal(c.Vault.SSL.Enabled),
@@ -1360,6 +2166,7 @@ func NewClientSet(c *config.Config) (*dep.ClientSet, error) {
 		TransportDialKeepAlive:       config.TimeDurationVal(c.Vault.Transport.DialKeepAlive),
 		TransportDialTimeout:         config.TimeDurationVal(c.Vault.Transport.DialTimeout),
 		TransportDisableKeepAlives:   config.BoolVal(c.Vault.Transport.DisableKeepAlives),
+		TransportEnableHTTP2:         config.BoolVal(c.Vault.Transport.EnableHTTP2),
 		TransportIdleConnTimeout:     config.TimeDurationVal(c.Vault.Transport.IdleConnTimeout),
 		TransportMaxIdleConns:        config.IntVal(c.Vault.Transport.MaxIdleConns),
 		TransportMaxIdleConnsPerHost: config.IntVal(c.Vault.Transport.MaxIdleConnsPerHost),
@@ -1369,6 +2176,7 @@ func NewClientSet(c *config.Config) (*dep.ClientSet, error) {
 		K8SServiceAccountTokenPath:   config.StringVal(c.Vault.K8SServiceAccountTokenPath),
 		K8SServiceAccountToken:       config.StringVal(c.Vault.K8SServiceAccountToken),
 		K8SServiceMountPath:          config.StringVal(c.Vault.K8SServiceMountPath),
+		Auth:                         vaultAuth(c.Vault.Auth),
 	}); err != nil {
 		return nil, fmt.Errorf("runner: %s", err)
 	}
@@ -1390,6 +2198,7 @@ func NewClientSet(c *config.Config) (*dep.ClientSet, error) {
 		TransportDialKeepAlive:       config.TimeDurationVal(c.Nomad.Transport.DialKeepAlive),
 		TransportDialTimeout:         config.TimeDurationVal(c.Nomad.Transport.DialTimeout),
 		TransportDisableKeepAlives:   config.BoolVal(c.Nomad.Transport.DisableKeepAlives),
+		TransportEnableHTTP2:         config.BoolVal(c.Nomad.Transport.EnableHTTP2),
 		TransportIdleConnTimeout:     config.TimeDurationVal(c.Nomad.Transport.IdleConnTimeout),
 		TransportMaxIdleConns:        config.IntVal(c.Nomad.Transport.MaxIdleConns),
 		TransportMaxIdleConnsPerHost: config.IntVal(c.Nomad.Transport.MaxIdleConnsPerHost),
@@ -1401,24 +2210,62 @@ func NewClientSet(c *config.Config) (*dep.ClientSet, error) {
 	return clients, nil
 }
 
+// rateLimitQPS returns the requests per second allowed by the given rate limit,
+// or zero if it is disabled.
+func rateLimitQPS(c *config.RateLimitConfig) float64 {
+	if !config.BoolVal(c.Enabled) {
+		return 0
+	}
+	return config.Float64Val(c.QPS)
+}
+
+// vaultAuth returns the auth method to log in to Vault with, or nil if it is
+// disabled.
+func vaultAuth(c *config.VaultAuthConfig) *dep.VaultAuth {
+	if c == nil || !config.BoolVal(c.Enabled) {
+		return nil
+	}
+	return &dep.VaultAuth{
+		Method: config.StringVal(c.Method),
+		Mount:  config.StringVal(c.Mount),
+		Config: c.Config,
+	}
+}
+
 // newWatcher creates a new watcher.
 func newWatcher(c *config.Config, clients *dep.ClientSet) *watch.Watcher {
 	log.Printf("[INFO] (runner) creating watcher")
 
-	return watch.NewWatcher(&watch.NewWatcherInput{
-		Clients:             clients,
-		MaxStale:            config.TimeDurationVal(c.MaxStale),
-		Once:                c.Once,
-		BlockQueryWaitTime:  config.TimeDurationVal(c.BlockQueryWaitTime),
-		RenewVault:          clients.Vault().Token() != "" && config.BoolVal(c.Vault.RenewToken),
-		VaultAgentTokenFile: config.StringVal(c.Vault.VaultAgentTokenFile),
-		RetryFuncConsul:     watch.RetryFunc(c.Consul.Retry.RetryFunc()),
-		FailLookupErrors:    c.ErrOnFailedLookup,
+	input := &watch.NewWatcherInput{
+		Clients:                clients,
+		MaxStale:               config.TimeDurationVal(c.MaxStale),
+		ConsulDatacenter:       config.StringVal(c.Consul.Datacenter),
+		ConsulNamespace:        config.StringVal(c.Consul.Namespace),
+		ConsulPartition:        config.StringVal(c.Consul.Partition),
+		Once:                   c.Once,
+		BlockQueryWaitTime:     config.TimeDurationVal(c.BlockQueryWaitTime),
+		BlockQueryStallTimeout: config.TimeDurationVal(c.BlockQueryStallTimeout),
+		RenewVault:             clients.Vault().Token() != "" && config.BoolVal(c.Vault.RenewToken),
+		VaultAgentTokenFile:    config.StringVal(c.Vault.VaultAgentTokenFile),
+		RetryFuncConsul:        watch.RetryFunc(c.Consul.Retry.RetryFunc()),
+		FailLookupErrors:       c.ErrOnFailedLookup,
 		// TODO: Add a reasonable default retry - right now this only affects
 		// "local" dependencies like reading a file from disk.
 		RetryFuncDefault: nil,
 		RetryFuncVault:   watch.RetryFunc(c.Vault.Retry.RetryFunc()),
 		VaultToken:       clients.Vault().Token(),
 		RetryFuncNomad:   watch.RetryFunc(c.Nomad.Retry.RetryFunc()),
-	})
+	}
+
+	// Keep retrying failed fetches until the upstreams had a chance to come up.
+	if d := config.TimeDurationVal(c.WaitForReady); d > 0 {
+		log.Printf("[INFO] (runner) retrying dependency errors for up to %s", d)
+		deadline := time.Now().Add(d)
+		input.RetryFuncConsul = watch.RetryUntil(input.RetryFuncConsul, deadline)
+		input.RetryFuncDefault = watch.RetryUntil(input.RetryFuncDefault, deadline)
+		input.RetryFuncVault = watch.RetryUntil(input.RetryFuncVault, deadline)
+		input.RetryFuncNomad = watch.RetryUntil(input.RetryFuncNomad, deadline)
+	}
+
+	return watch.NewWatcher(input)
 }
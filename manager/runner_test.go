@@ -6,18 +6,32 @@ package manager
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/hashicorp/consul-template/child"
 	"github.com/hashicorp/consul-template/config"
 	dep "github.com/hashicorp/consul-template/dependency"
+	"github.com/hashicorp/consul-template/renderer"
 	"github.com/hashicorp/consul-template/template"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 func TestRunner_initTemplates(t *testing.T) {
@@ -443,61 +457,1237 @@ func TestRunner_Run(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"error_fatal_false",
+			nil,
+			&config.Config{
+				Templates: &config.TemplateConfigs{
+					&config.TemplateConfig{
+						Contents:    config.String(`{{ failIf true "boom" }}`),
+						Destination: config.String("/tmp/ct-error_fatal_false_a"),
+						ErrFatal:    config.Bool(false),
+					},
+					&config.TemplateConfig{
+						Contents:    config.String("hello"),
+						Destination: config.String("/tmp/ct-error_fatal_false_b"),
+					},
+				},
+			},
+			func(t *testing.T, r *Runner, out string) {
+				// The healthy template still renders.
+				exp := "> /tmp/ct-error_fatal_false_b\nhello"
+				if out != exp {
+					t.Errorf("\nexp: %#v\nact: %#v", exp, out)
+				}
+
+				var errs int
+				for _, e := range r.RenderEvents() {
+					if e.Error != nil {
+						errs++
+						if !strings.Contains(e.Error.Error(), "boom") {
+							t.Errorf("\nexp: %#v\nact: %#v", "boom", e.Error.Error())
+						}
+					}
+				}
+				if errs != 1 {
+					t.Errorf("\nexp: %#v\nact: %#v", 1, errs)
+				}
+			},
+			false,
+		},
+		{
+			"error_fatal_true",
+			nil,
+			&config.Config{
+				Templates: &config.TemplateConfigs{
+					&config.TemplateConfig{
+						Contents:    config.String(`{{ failIf true "boom" }}`),
+						Destination: config.String("/tmp/ct-error_fatal_true"),
+					},
+				},
+			},
+			nil,
+			true,
+		},
 		{
 			"env",
 			func(t *testing.T, r *Runner) {
 				r.dry = false
 				r.config.Consul.Address = config.String("1.2.3.4")
 			},
-			&config.Config{
-				Templates: &config.TemplateConfigs{
-					&config.TemplateConfig{
-						Contents:    config.String("hello"),
-						Command:     []string{"env"},
-						Destination: config.String("/tmp/ct-env_a"),
-					},
+			&config.Config{
+				Templates: &config.TemplateConfigs{
+					&config.TemplateConfig{
+						Contents:    config.String("hello"),
+						Command:     []string{"env"},
+						Destination: config.String("/tmp/ct-env_a"),
+					},
+				},
+			},
+			func(t *testing.T, r *Runner, out string) {
+				exp := "CONSUL_HTTP_ADDR=1.2.3.4"
+				if !strings.Contains(out, exp) {
+					t.Errorf("\nexp: %#v\nact: %#v", exp, out)
+				}
+				os.Remove("/tmp/ct-env_a")
+			},
+			false,
+		},
+	}
+
+	for i, tc := range cases {
+		tc := tc
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			var out bytes.Buffer
+
+			c := config.TestConfig(tc.c)
+			c.Once = true
+			c.Finalize()
+
+			r, err := NewRunner(c, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.outStream, r.errStream = &out, &out
+			defer r.Stop()
+
+			if tc.before != nil {
+				tc.before(t, r)
+			}
+
+			if err := r.Run(); (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+
+			if tc.after != nil {
+				tc.after(t, r, out.String())
+			}
+		})
+	}
+}
+
+func TestRunner_renderGeneration(t *testing.T) {
+	outDir, err := os.MkdirTemp("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outDir)
+	out := filepath.Join(outDir, "out")
+	genFile := filepath.Join(outDir, "out.gen")
+
+	newRunner := func(t *testing.T) *Runner {
+		t.Helper()
+		c := config.TestConfig(&config.Config{
+			Templates: &config.TemplateConfigs{
+				&config.TemplateConfig{
+					Contents:       config.String(`generation {{ renderGeneration }}`),
+					Destination:    config.String(out),
+					GenerationFile: config.String(genFile),
+				},
+			},
+		})
+		r, err := NewRunner(c, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(r.Stop)
+		return r
+	}
+
+	// expect runs the runner once and checks the rendered generation.
+	expect := func(t *testing.T, r *Runner, exp string) {
+		t.Helper()
+		if err := r.Run(); err != nil {
+			t.Fatal(err)
+		}
+		act, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(act) != exp {
+			t.Errorf("\nexp: %#v\nact: %#v", exp, string(act))
+		}
+	}
+
+	r := newRunner(t)
+	expect(t, r, "generation 1")
+
+	// Nothing changed, so the generation holds steady.
+	expect(t, r, "generation 1")
+
+	// The file on disk no longer matches, so the generation advances.
+	if err := os.WriteFile(out, []byte("modified"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, r, "generation 2")
+
+	gen, err := os.ReadFile(genFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(gen) != "2\n" {
+		t.Errorf("expected generation file to hold %q, got %q", "2\n", gen)
+	}
+
+	// A new runner picks up the persisted generation and does not advance it
+	// for a no-op render.
+	expect(t, newRunner(t), "generation 2")
+}
+
+func TestRunner_fanOut(t *testing.T) {
+	outDir, err := os.MkdirTemp("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outDir)
+
+	c := config.TestConfig(&config.Config{
+		Templates: &config.TemplateConfigs{
+			&config.TemplateConfig{
+				Contents: config.String(`{{ range split "," (env "SERVICES") }}` +
+					`{{ printf "service %s\n" . | emit (print . ".conf") }}{{ end }}`),
+				Destination: config.String(outDir),
+				FanOut:      config.Bool(true),
+			},
+		},
+	})
+
+	// newRunner returns a new runner of the fan-out template, like after a
+	// restart.
+	newRunner := func(t *testing.T) *Runner {
+		t.Helper()
+		r, err := NewRunner(c.Copy(), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(r.Stop)
+		return r
+	}
+
+	// expect runs the runner with the given services and checks the files in
+	// the destination directory.
+	expect := func(t *testing.T, r *Runner, services string, exp []string) {
+		t.Helper()
+		r.Env = map[string]string{"SERVICES": services}
+		if err := r.Run(); err != nil {
+			t.Fatal(err)
+		}
+		entries, err := os.ReadDir(outDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		act := make([]string, 0, len(entries))
+		for _, e := range entries {
+			if e.Name() == renderer.FanOutManifest {
+				continue
+			}
+			act = append(act, e.Name())
+			b, err := os.ReadFile(filepath.Join(outDir, e.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "service " + strings.TrimSuffix(e.Name(), ".conf") + "\n"; string(b) != want {
+				t.Errorf("expected %s to contain %q, got %q", e.Name(), want, b)
+			}
+		}
+		if !reflect.DeepEqual(exp, act) {
+			t.Errorf("\nexp: %#v\nact: %#v", exp, act)
+		}
+	}
+
+	r := newRunner(t)
+	expect(t, r, "a,b,c", []string{"a.conf", "b.conf", "c.conf"})
+	expect(t, r, "a,c", []string{"a.conf", "c.conf"})
+
+	// The files emitted before a restart are still removed once they are
+	// no longer emitted.
+	r.Stop()
+	expect(t, newRunner(t), "c", []string{"c.conf"})
+}
+
+// watchingDep reports whether the runner watches the given dependency. Those
+// that cannot be shared are watched with the instance of a template, which is
+// looked up by its string.
+func watchingDep(r *Runner, d dep.Dependency) bool {
+	r.dependenciesLock.Lock()
+	defer r.dependenciesLock.Unlock()
+
+	for _, td := range r.dependencies {
+		if td.String() == d.String() {
+			return r.watcher.Watching(td)
+		}
+	}
+	return r.watcher.Watching(d)
+}
+
+func TestRunner_templateDeps(t *testing.T) {
+	outDir := t.TempDir()
+	data := filepath.Join(outDir, "data")
+
+	c := config.TestConfig(&config.Config{
+		Templates: &config.TemplateConfigs{
+			&config.TemplateConfig{
+				Contents:    config.String(`a {{ file "` + data + `" }}`),
+				Destination: config.String(filepath.Join(outDir, "a")),
+			},
+			&config.TemplateConfig{
+				Contents:    config.String(`b {{ file "` + data + `" }}`),
+				Destination: config.String(filepath.Join(outDir, "b")),
+			},
+		},
+	})
+	r, err := NewRunner(c, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	// Files cannot be shared, so each template watches its own instance.
+	for i := 0; i < 2; i++ {
+		if err := r.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := r.watcher.Size(); n != 2 {
+		t.Errorf("expected a view per template, got %d", n)
+	}
+	if n := len(r.templateDeps); n != 2 {
+		t.Errorf("expected an instance per template, got %d", n)
+	}
+}
+
+func TestRunner_renderOnce(t *testing.T) {
+	outDir := t.TempDir()
+	once := filepath.Join(outDir, "once")
+	other := filepath.Join(outDir, "other")
+
+	d, err := dep.NewFileQuery(filepath.Join(outDir, "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := config.TestConfig(&config.Config{
+		Templates: &config.TemplateConfigs{
+			&config.TemplateConfig{
+				Contents:    config.String(`{{ env "VALUE" }} {{ file "` + filepath.Join(outDir, "data") + `" }}`),
+				Destination: config.String(once),
+				RenderOnce:  config.Bool(true),
+			},
+			&config.TemplateConfig{
+				Contents:    config.String(`{{ env "VALUE" }}`),
+				Destination: config.String(other),
+			},
+		},
+	})
+	r, err := NewRunner(c, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	// run runs the runner with the given value and returns the contents of
+	// both destinations.
+	run := func(t *testing.T, value string) (string, string) {
+		t.Helper()
+		r.Env = map[string]string{"VALUE": value}
+		if err := r.Run(); err != nil {
+			t.Fatal(err)
+		}
+		b1, _ := os.ReadFile(once)
+		b2, err := os.ReadFile(other)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b1), string(b2)
+	}
+
+	// The first run starts watching the file, and the template renders once
+	// its data arrives.
+	if act, _ := run(t, "1"); act != "" {
+		t.Fatalf("expected no render without data, got %q", act)
+	}
+	r.brain.Remember(d, "a")
+	if act, _ := run(t, "1"); act != "1 a" {
+		t.Fatalf("expected %q to be %q", act, "1 a")
+	}
+
+	r.brain.Remember(d, "b")
+	act, actOther := run(t, "2")
+	if act != "1 a" {
+		t.Errorf("expected the template not to render again, got %q", act)
+	}
+	if actOther != "2" {
+		t.Errorf("expected %q to be %q", actOther, "2")
+	}
+	if watchingDep(r, d) {
+		t.Errorf("expected %s to no longer be watched", d)
+	}
+}
+
+func TestRunner_dependencyGracePeriod(t *testing.T) {
+	outDir := t.TempDir()
+	out := filepath.Join(outDir, "out")
+	data := filepath.Join(outDir, "data")
+
+	d, err := dep.NewFileQuery(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := config.TestConfig(&config.Config{
+		DependencyGracePeriod: config.TimeDuration(200 * time.Millisecond),
+		Templates: &config.TemplateConfigs{
+			&config.TemplateConfig{
+				Contents:    config.String(`{{ if eq (env "BRANCH") "on" }}{{ file "` + data + `" }}{{ else }}off{{ end }}`),
+				Destination: config.String(out),
+			},
+		},
+	})
+	r, err := NewRunner(c, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	// run runs the runner with the given branch and returns the contents of
+	// the destination.
+	run := func(t *testing.T, branch string) string {
+		t.Helper()
+		r.Env = map[string]string{"BRANCH": branch}
+		if err := r.Run(); err != nil {
+			t.Fatal(err)
+		}
+		b, _ := os.ReadFile(out)
+		return string(b)
+	}
+
+	// Taking the branch starts watching the file.
+	run(t, "on")
+	r.brain.Remember(d, "a")
+	if act := run(t, "on"); act != "a" {
+		t.Fatalf("expected %q to be %q", act, "a")
+	}
+	if !watchingDep(r, d) {
+		t.Fatalf("expected %s to be watched", d)
+	}
+
+	// Once the branch is no longer taken, the file stays watched with its data
+	// until the grace period ends.
+	if act := run(t, "off"); act != "off" {
+		t.Fatalf("expected %q to be %q", act, "off")
+	}
+	if !watchingDep(r, d) {
+		t.Errorf("expected %s to still be watched during the grace period", d)
+	}
+	if _, ok := r.brain.Recall(d); !ok {
+		t.Errorf("expected the data of %s to be kept during the grace period", d)
+	}
+
+	select {
+	case <-r.releaseCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a run to be scheduled to release the dependency")
+	}
+	run(t, "off")
+	if watchingDep(r, d) {
+		t.Errorf("expected %s to be released", d)
+	}
+	if _, ok := r.brain.Recall(d); ok {
+		t.Errorf("expected the data of %s to be forgotten", d)
+	}
+
+	// Without a grace period, it is released at once.
+	r.config.DependencyGracePeriod = config.TimeDuration(0)
+	run(t, "on")
+	if !watchingDep(r, d) {
+		t.Fatalf("expected %s to be watched", d)
+	}
+	run(t, "off")
+	if watchingDep(r, d) {
+		t.Errorf("expected %s to be released at once", d)
+	}
+}
+
+func TestRunner_maxRenderConcurrency(t *testing.T) {
+	outDir, err := os.MkdirTemp("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outDir)
+
+	// track records how many templates are executing at the same time.
+	var running, maxRunning int32
+	funcs := map[string]any{
+		"track": func() string {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+			return ""
+		},
+	}
+
+	var templates config.TemplateConfigs
+	var exp []string
+	for i := 0; i < 6; i++ {
+		out := filepath.Join(outDir, strconv.Itoa(i))
+		templates = append(templates, &config.TemplateConfig{
+			Contents:    config.String(fmt.Sprintf("{{ track }}%d", i)),
+			Destination: config.String(out),
+			ExtFuncMap:  funcs,
+			Exec: &config.ExecConfig{
+				Command: []string{"echo " + strconv.Itoa(i) + " >> " + filepath.Join(outDir, "order")},
+			},
+		})
+		exp = append(exp, strconv.Itoa(i))
+	}
+
+	c := config.TestConfig(&config.Config{
+		MaxRenderConcurrency: config.Int(3),
+		Templates:            &templates,
+	})
+	r, err := NewRunner(c, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	if err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if m := atomic.LoadInt32(&maxRunning); m > 3 || m < 2 {
+		t.Errorf("expected 2 to 3 templates to render at once, got %d", m)
+	}
+	for i := 0; i < 6; i++ {
+		b, err := os.ReadFile(filepath.Join(outDir, strconv.Itoa(i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != strconv.Itoa(i) {
+			t.Errorf("expected %q, got %q", strconv.Itoa(i), b)
+		}
+	}
+
+	// Commands still run in the order of the templates.
+	b, err := os.ReadFile(filepath.Join(outDir, "order"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if act := strings.Fields(string(b)); !reflect.DeepEqual(exp, act) {
+		t.Errorf("\nexp: %#v\nact: %#v", exp, act)
+	}
+}
+
+func TestRunner_maxRenderConcurrency_fatalError(t *testing.T) {
+	outDir := t.TempDir()
+
+	funcs := map[string]any{
+		"fail": func() (string, error) {
+			time.Sleep(10 * time.Millisecond)
+			return "", fmt.Errorf("failed")
+		},
+		"slow": func() string {
+			time.Sleep(100 * time.Millisecond)
+			return ""
+		},
+	}
+	contents := []string{"{{ fail }}", "{{ slow }}1", "2", "3"}
+
+	var templates config.TemplateConfigs
+	for i, s := range contents {
+		templates = append(templates, &config.TemplateConfig{
+			Contents:    config.String(s),
+			Destination: config.String(filepath.Join(outDir, strconv.Itoa(i))),
+			ExtFuncMap:  funcs,
+		})
+	}
+
+	c := config.TestConfig(&config.Config{
+		MaxRenderConcurrency: config.Int(2),
+		Templates:            &templates,
+	})
+	r, err := NewRunner(c, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	if err := r.Run(); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	// The template running next to the failed one finishes, but none is
+	// started after the failure, like when they render one after the other.
+	for _, i := range []int{2, 3} {
+		if _, err := os.Stat(filepath.Join(outDir, strconv.Itoa(i))); !os.IsNotExist(err) {
+			t.Errorf("expected template %d not to be rendered, got %v", i, err)
+		}
+	}
+}
+
+func TestRunner_ignoreDepErrors(t *testing.T) {
+	outDir, err := os.MkdirTemp("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outDir)
+
+	// The file dependency fails to fetch right away since the file does not
+	// exist.
+	missing := filepath.Join(outDir, "missing", "secret")
+
+	run := func(t *testing.T, patterns []string) (string, error) {
+		t.Helper()
+		out := filepath.Join(outDir, filepath.Base(t.Name()))
+		c := config.TestConfig(&config.Config{
+			Once: true,
+			Templates: &config.TemplateConfigs{
+				&config.TemplateConfig{
+					Contents:        config.String(`{{ file "` + missing + `" }}rendered`),
+					Destination:     config.String(out),
+					IgnoreDepErrors: patterns,
+				},
+			},
+		})
+		r, err := NewRunner(c, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Stop()
+
+		go r.Start()
+		select {
+		case err := <-r.ErrCh:
+			return "", err
+		case <-r.DoneCh:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+		b, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b), nil
+	}
+
+	t.Run("matching", func(t *testing.T) {
+		act, err := run(t, []string{"vault.read(*)", "file(" + filepath.Join(outDir, "missing") + "/*)"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if act != "rendered" {
+			t.Errorf("expected %q to be %q", act, "rendered")
+		}
+	})
+
+	t.Run("not_matching", func(t *testing.T) {
+		_, err := run(t, []string{"file(/other/*)"})
+		if err == nil || !strings.Contains(err.Error(), "no such file") {
+			t.Errorf("expected the fetch error, got %v", err)
+		}
+	})
+}
+
+func TestRunner_ignoreDepErrors_retry(t *testing.T) {
+	defer func(d time.Duration) { ignoredDepRetryInterval = d }(ignoredDepRetryInterval)
+	ignoredDepRetryInterval = 50 * time.Millisecond
+
+	outDir := t.TempDir()
+	out := filepath.Join(outDir, "out")
+	secret := filepath.Join(outDir, "missing", "secret")
+
+	c := config.TestConfig(&config.Config{
+		Templates: &config.TemplateConfigs{
+			&config.TemplateConfig{
+				Contents:        config.String(`{{ file "` + secret + `" }}rendered`),
+				Destination:     config.String(out),
+				IgnoreDepErrors: []string{"file(" + filepath.Dir(secret) + "/*)"},
+			},
+		},
+	})
+	r, err := NewRunner(c, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	go r.Start()
+
+	// waitFor waits for the destination to have the given contents.
+	waitFor := func(t *testing.T, exp string) {
+		t.Helper()
+		deadline := time.After(5 * time.Second)
+		for {
+			if b, _ := os.ReadFile(out); string(b) == exp {
+				return
+			}
+			select {
+			case err := <-r.ErrCh:
+				t.Fatal(err)
+			case <-deadline:
+				b, _ := os.ReadFile(out)
+				t.Fatalf("expected %q to be %q", string(b), exp)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+
+	waitFor(t, "rendered")
+
+	// Once the file can be read, the retried fetch picks it up.
+	if err := os.MkdirAll(filepath.Dir(secret), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(secret, []byte("s3cr3t "), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "s3cr3t rendered")
+}
+
+func TestRunner_servicesByDC(t *testing.T) {
+	// dc2 cannot be reached, as if the WAN federation were down.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/health/service/web" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("dc") != "dc1" {
+			http.Error(w, "No path to datacenter", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("X-Consul-Index", "1")
+		fmt.Fprint(w, `[{"Node":{"Node":"node1","Address":"10.0.0.1","Datacenter":"dc1"},`+
+			`"Service":{"ID":"web","Service":"web","Port":80},"Checks":[]}]`)
+	}))
+	defer srv.Close()
+
+	out := filepath.Join(t.TempDir(), "out")
+	c := config.TestConfig(&config.Config{
+		Consul: &config.ConsulConfig{
+			Address: config.String(srv.Listener.Addr().String()),
+			Retry: &config.RetryConfig{
+				Enabled: config.Bool(false),
+			},
+		},
+		Templates: &config.TemplateConfigs{
+			&config.TemplateConfig{
+				Contents: config.String(`{{ range $dc, $s := servicesByDC "web" "dc1" "dc2" }}` +
+					`{{ $dc }}:{{ range $s }}{{ .Node }}{{ end }};{{ end }}`),
+				Destination: config.String(out),
+			},
+		},
+		Once: true,
+	})
+	r, err := NewRunner(c, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	go r.Start()
+	select {
+	case err := <-r.ErrCh:
+		t.Fatal(err)
+	case <-r.DoneCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "dc1:node1;dc2:;", string(b); act != exp {
+		t.Errorf("expected %q to be %q", act, exp)
+	}
+}
+
+func TestRunner_envVar(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	out := filepath.Join(dir, "out")
+
+	// The index of a file is its modification time in seconds, so each change
+	// is written in a new second to be seen.
+	writeToken := func(t *testing.T, token string) {
+		t.Helper()
+		time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+		if err := os.WriteFile(tokenFile, []byte(token+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeToken(t, "token-1")
+
+	c := config.TestConfig(&config.Config{
+		Exec: &config.ExecConfig{
+			Command:     []string{`echo "$APP_TOKEN" >> ` + out + ` && exec sleep 30`},
+			KillTimeout: config.TimeDuration(time.Second),
+		},
+		Templates: &config.TemplateConfigs{
+			&config.TemplateConfig{
+				Contents:                 config.String(`{{ file "` + tokenFile + `" }}`),
+				MapToEnvironmentVariable: config.String("APP_TOKEN"),
+			},
+		},
+	})
+	r, err := NewRunner(c, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetOutStream(io.Discard)
+	r.SetErrStream(io.Discard)
+	defer r.Stop()
+	go r.Start()
+
+	// waitFor waits for the child processes to have seen the given tokens.
+	waitFor := func(t *testing.T, exp string) {
+		t.Helper()
+		var act string
+		for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); {
+			select {
+			case err := <-r.ErrCh:
+				t.Fatal(err)
+			default:
+			}
+			b, _ := os.ReadFile(out)
+			if act = string(b); act == exp {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		t.Fatalf("expected %q to be %q", act, exp)
+	}
+
+	waitFor(t, "token-1\n")
+
+	// A changed token restarts the child with the new value.
+	writeToken(t, "token-2")
+	waitFor(t, "token-1\ntoken-2\n")
+
+	if _, err := os.Stat(filepath.Join(dir, "APP_TOKEN")); !os.IsNotExist(err) {
+		t.Errorf("expected no file to be written, got %v", err)
+	}
+}
+
+func TestSpawnChild_logOutput(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(io.Discard)
+
+	var stdout bytes.Buffer
+	c, err := spawnChild(&spawnChildInput{
+		Stdout:    &stdout,
+		Stderr:    &stdout,
+		Command:   []string{"/bin/echo hello && echo oops >&2"},
+		LogOutput: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	select {
+	case <-c.ExitCh():
+	case <-time.After(5 * time.Second):
+		t.Fatal("child did not exit")
+	}
+
+	for _, exp := range []string{
+		"[INFO] (child) echo (stdout): hello\n",
+		"[INFO] (child) echo (stderr): oops\n",
+	} {
+		if !strings.Contains(buf.String(), exp) {
+			t.Errorf("expected %q to contain %q", buf.String(), exp)
+		}
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("expected no output to be written, got %q", stdout.String())
+	}
+}
+
+func TestRunner_renderEnvVar(t *testing.T) {
+	r := &Runner{envVars: make(map[string]string)}
+
+	cases := []struct {
+		name      string
+		contents  string
+		value     string
+		didRender bool
+		err       bool
+	}{
+		{"first", "token-1", "token-1", true, false},
+		{"same", "token-1\n", "token-1", false, false},
+		{"changed_crlf", "token-2\r\n", "token-2", true, false},
+		{"multi_line", "token-1\ntoken-2", "", false, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := r.renderEnvVar("APP_TOKEN", []byte(tc.contents))
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error %t, got %v", tc.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if result.DidRender != tc.didRender {
+				t.Errorf("expected DidRender %t, got %t", tc.didRender, result.DidRender)
+			}
+			if act := string(result.Contents); act != tc.value {
+				t.Errorf("expected %q to be %q", act, tc.value)
+			}
+		})
+	}
+	if exp, act := "token-2", r.envVars["APP_TOKEN"]; act != exp {
+		t.Errorf("expected the last valid value %q, got %q", exp, act)
+	}
+}
+
+func TestRunner_commandTrigger(t *testing.T) {
+	outDir, err := os.MkdirTemp("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outDir)
+
+	trigger, err := dep.NewKVGetQuery("trigger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trigger.EnableBlocking()
+	other, err := dep.NewKVGetQuery("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.EnableBlocking()
+
+	out := filepath.Join(outDir, "out")
+	runs := filepath.Join(outDir, "runs")
+	c := config.TestConfig(&config.Config{
+		Templates: &config.TemplateConfigs{
+			&config.TemplateConfig{
+				Contents:       config.String(`{{ key "trigger" }} {{ key "other" }}`),
+				Destination:    config.String(out),
+				CommandTrigger: []string{trigger.String()},
+				Exec: &config.ExecConfig{
+					Command: []string{"echo run >> " + runs},
+				},
+			},
+		},
+	})
+	r, err := NewRunner(c, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+	r.watcher.ForceWatching(trigger, true)
+	r.watcher.ForceWatching(other, true)
+
+	// run renders the template after the given dependency changed and returns
+	// the rendered contents and the number of times the command ran so far.
+	run := func(t *testing.T, d dep.Dependency, data string) (string, int) {
+		t.Helper()
+		r.brain.Remember(d, data)
+		if err := r.Run(); err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b2, err := os.ReadFile(runs)
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		return string(b), len(strings.Fields(string(b2)))
+	}
+
+	r.brain.Remember(trigger, "a")
+	if act, n := run(t, other, "1"); act != "a 1" || n != 1 {
+		t.Fatalf("expected the first render to run the command, got %q and %d runs", act, n)
+	}
+
+	// A change to a dependency that is not a trigger renders the template, but
+	// does not run the command.
+	if act, n := run(t, other, "2"); act != "a 2" || n != 1 {
+		t.Fatalf("expected the command to be skipped, got %q and %d runs", act, n)
+	}
+
+	if act, n := run(t, trigger, "b"); act != "b 2" || n != 2 {
+		t.Fatalf("expected the trigger to run the command, got %q and %d runs", act, n)
+	}
+}
+
+func TestRunner_servicesDelta(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+
+	d, err := dep.NewCatalogServicesQuery("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := config.TestConfig(&config.Config{
+		Templates: &config.TemplateConfigs{
+			&config.TemplateConfig{
+				Contents:    config.String(`{{ with servicesDelta }}{{ range .Added }}+{{ . }}{{ end }}{{ range .Removed }}-{{ . }}{{ end }}{{ end }}`),
+				Destination: config.String(out),
+			},
+		},
+	})
+	r, err := NewRunner(c, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+	r.watcher.ForceWatching(d, true)
+
+	// run renders the template after the given catalog updates and returns
+	// the rendered contents.
+	run := func(t *testing.T, updates ...[]string) string {
+		t.Helper()
+		for _, names := range updates {
+			services := make([]*dep.CatalogSnippet, 0, len(names))
+			for _, name := range names {
+				services = append(services, &dep.CatalogSnippet{Name: name})
+			}
+			r.brain.Remember(d, services)
+		}
+		if err := r.Run(); err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	if act := run(t, []string{"a", "b"}); act != "+a+b" {
+		t.Fatalf("expected %q to be %q", act, "+a+b")
+	}
+
+	// Both updates before the render are reported, not just the last one.
+	if act := run(t, []string{"a", "c"}, []string{"a", "c", "d"}); act != "+c+d-b" {
+		t.Errorf("expected %q to be %q", act, "+c+d-b")
+	}
+}
+
+func TestRunner_treeChanged(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+
+	d, err := dep.NewKVListQuery("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := config.TestConfig(&config.Config{
+		Templates: &config.TemplateConfigs{
+			&config.TemplateConfig{
+				Contents:    config.String(`{{ range treeChanged "key" }}{{ .Key }}={{ .Value }};{{ end }}`),
+				Destination: config.String(out),
+			},
+		},
+	})
+	r, err := NewRunner(c, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+	r.watcher.ForceWatching(d, true)
+
+	// run renders the template after the given fetches and returns the
+	// rendered contents.
+	run := func(t *testing.T, fetches ...[]*dep.KeyPair) string {
+		t.Helper()
+		for _, pairs := range fetches {
+			r.brain.Remember(d, pairs)
+		}
+		if err := r.Run(); err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	if act := run(t, []*dep.KeyPair{
+		{Key: "a", Value: "1", ModifyIndex: 4},
+		{Key: "b", Value: "1", ModifyIndex: 5},
+	}); act != "a=1;b=1;" {
+		t.Fatalf("expected %q to be %q", act, "a=1;b=1;")
+	}
+
+	// The keys modified by both fetches before the render are returned, not
+	// just those of the last one.
+	if act := run(t, []*dep.KeyPair{
+		{Key: "a", Value: "2", ModifyIndex: 8},
+		{Key: "b", Value: "1", ModifyIndex: 5},
+	}, []*dep.KeyPair{
+		{Key: "a", Value: "2", ModifyIndex: 8},
+		{Key: "b", Value: "2", ModifyIndex: 9},
+	}); act != "a=2;b=2;" {
+		t.Errorf("expected %q to be %q", act, "a=2;b=2;")
+	}
+}
+
+func TestRunner_tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	}()
+
+	outDir, err := os.MkdirTemp("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outDir)
+	out := filepath.Join(outDir, "out")
+	traceparent := filepath.Join(outDir, "traceparent")
+
+	c := config.TestConfig(&config.Config{
+		Templates: &config.TemplateConfigs{
+			&config.TemplateConfig{
+				Contents:    config.String("hello"),
+				Destination: config.String(out),
+				Exec: &config.ExecConfig{
+					Command: []string{"printf %s \"$TRACEPARENT\" > " + traceparent},
 				},
 			},
-			func(t *testing.T, r *Runner, out string) {
-				exp := "CONSUL_HTTP_ADDR=1.2.3.4"
-				if !strings.Contains(out, exp) {
-					t.Errorf("\nexp: %#v\nact: %#v", exp, out)
-				}
-				os.Remove("/tmp/ct-env_a")
-			},
-			false,
 		},
+	})
+	r, err := NewRunner(c, false)
+	if err != nil {
+		t.Fatal(err)
 	}
+	defer r.Stop()
 
-	for i, tc := range cases {
-		tc := tc
-		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
-			var out bytes.Buffer
+	if err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
 
-			c := config.TestConfig(tc.c)
-			c.Once = true
-			c.Finalize()
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	render, tmpl, cmd := spans["render"], spans["template"], spans["command"]
+	if render == nil || tmpl == nil || cmd == nil {
+		t.Fatalf("expected render, template and command spans, got %v", spans)
+	}
 
-			r, err := NewRunner(c, true)
-			if err != nil {
-				t.Fatal(err)
-			}
-			r.outStream, r.errStream = &out, &out
-			defer r.Stop()
+	if render.Parent().IsValid() {
+		t.Errorf("expected render span to be a root span")
+	}
+	for _, s := range []sdktrace.ReadOnlySpan{tmpl, cmd} {
+		if s.Parent().SpanID() != render.SpanContext().SpanID() {
+			t.Errorf("expected %s span to be a child of the render span", s.Name())
+		}
+	}
 
-			if tc.before != nil {
-				tc.before(t, r)
-			}
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range tmpl.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	if !attrs["rendered"].AsBool() {
+		t.Errorf("expected template span to record the render, got %v", attrs)
+	}
 
-			if err := r.Run(); (err != nil) != tc.err {
-				t.Fatal(err)
-			}
+	// The command continues the trace of its span.
+	act, err := os.ReadFile(traceparent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := fmt.Sprintf("00-%s-%s-01", cmd.SpanContext().TraceID(), cmd.SpanContext().SpanID())
+	if string(act) != exp {
+		t.Errorf("\nexp: %#v\nact: %#v", exp, string(act))
+	}
+}
 
-			if tc.after != nil {
-				tc.after(t, r, out.String())
-			}
+func TestRunner_waitForReady(t *testing.T) {
+	// Consul fails the first requests, as if it were still starting up.
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/foo" {
+			http.NotFound(w, r)
+			return
+		}
+		if atomic.AddInt32(&requests, 1) <= 3 {
+			http.Error(w, "No cluster leader", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("X-Consul-Index", "1")
+		fmt.Fprint(w, `[{"Key":"foo","Value":"YmFy","CreateIndex":1,"ModifyIndex":1}]`)
+	}))
+	defer srv.Close()
+
+	run := func(t *testing.T, waitForReady time.Duration) (string, error) {
+		out := filepath.Join(t.TempDir(), "out")
+		c := config.DefaultConfig().Merge(&config.Config{
+			Consul: &config.ConsulConfig{
+				Address: config.String(srv.Listener.Addr().String()),
+				Retry: &config.RetryConfig{
+					Enabled: config.Bool(false),
+				},
+			},
+			Templates: &config.TemplateConfigs{
+				&config.TemplateConfig{
+					Contents:    config.String(`{{ key "foo" }}`),
+					Destination: config.String(out),
+				},
+			},
+			WaitForReady: config.TimeDuration(waitForReady),
+			Once:         true,
 		})
+		c.Finalize()
+
+		r, err := NewRunner(c, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go r.Start()
+		defer r.Stop()
+
+		select {
+		case err := <-r.ErrCh:
+			return "", err
+		case <-r.DoneCh:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+
+		b, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b), nil
 	}
+
+	t.Run("disabled", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		if _, err := run(t, 0); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("becomes_available", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		act, err := run(t, 3*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp := "bar"; act != exp {
+			t.Errorf("\nexp: %#v\nact: %#v", exp, act)
+		}
+	})
 }
 
 func TestRunner_Start(t *testing.T) {
@@ -541,12 +1731,101 @@ func TestRunner_Start(t *testing.T) {
 			if err != nil {
 				t.Fatal(err)
 			}
-			if l := len(c); l == 0 {
-				t.Errorf("\nexp: %#v\nact: %#v", "> 0", l)
+			if exp := strconv.Itoa(os.Getpid()); string(c) != exp {
+				t.Errorf("\nexp: %#v\nact: %#v", exp, string(c))
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout")
+		}
+
+		r.Stop()
+		if _, err := os.Stat(pid.Name()); !os.IsNotExist(err) {
+			t.Errorf("expected pid file to be removed on stop, got: %v", err)
+		}
+	})
+
+	t.Run("pid_file_in_use", func(t *testing.T) {
+		pid, err := os.CreateTemp("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(pid.Name())
+
+		// the parent test process stands in for another running instance
+		other := strconv.Itoa(os.Getppid())
+		if err := os.WriteFile(pid.Name(), []byte(other), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		c := config.DefaultConfig().Merge(&config.Config{
+			PidFile: config.String(pid.Name()),
+		})
+		c.Finalize()
+
+		r, err := NewRunner(c, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		go r.Start()
+
+		select {
+		case err := <-r.ErrCh:
+			exp := fmt.Sprintf("pid file %q is in use by running process %s", pid.Name(), other)
+			if !strings.Contains(err.Error(), exp) {
+				t.Errorf("expected error %q to contain %q", err, exp)
 			}
 		case <-time.After(2 * time.Second):
 			t.Fatal("timeout")
 		}
+
+		// stopping must not remove the other instance's pid file
+		r.Stop()
+		c2, err := os.ReadFile(pid.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(c2) != other {
+			t.Errorf("\nexp: %#v\nact: %#v", other, string(c2))
+		}
+	})
+
+	t.Run("stale_pid_file", func(t *testing.T) {
+		pid, err := os.CreateTemp("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(pid.Name())
+
+		// the pid of a process that has already exited
+		cmd := exec.Command("true")
+		if err := cmd.Run(); err != nil {
+			t.Fatal(err)
+		}
+		stale := strconv.Itoa(cmd.Process.Pid)
+		if err := os.WriteFile(pid.Name(), []byte(stale), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		c := config.DefaultConfig().Merge(&config.Config{
+			PidFile: config.String(pid.Name()),
+		})
+		c.Finalize()
+
+		r, err := NewRunner(c, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.storePid(); err != nil {
+			t.Fatal(err)
+		}
+		c2, err := os.ReadFile(pid.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp := strconv.Itoa(os.Getpid()); string(c2) != exp {
+			t.Errorf("\nexp: %#v\nact: %#v", exp, string(c2))
+		}
 	})
 
 	t.Run("run_no_deps", func(t *testing.T) {
@@ -591,6 +1870,57 @@ func TestRunner_Start(t *testing.T) {
 		}
 	})
 
+	t.Run("stream", func(t *testing.T) {
+		outDir, err := os.MkdirTemp("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(outDir)
+		out := filepath.Join(outDir, "out")
+
+		c := config.DefaultConfig().Merge(&config.Config{
+			Templates: &config.TemplateConfigs{
+				&config.TemplateConfig{
+					Contents:    config.String(`{{ range loop 3 }}line {{ . }}` + "\n" + `{{ end }}`),
+					Destination: config.String(out),
+					Stream:      config.Bool(true),
+				},
+			},
+		})
+		c.Finalize()
+
+		r, err := NewRunner(c, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		go r.Start()
+		defer r.Stop()
+
+		select {
+		case err := <-r.ErrCh:
+			t.Fatal(err)
+		case <-r.renderedCh:
+			act, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			exp := "line 0\nline 1\nline 2\n"
+			if exp != string(act) {
+				t.Errorf("\nexp: %#v\nact: %#v", exp, string(act))
+			}
+			entries, err := os.ReadDir(outDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("expected only the rendered file, got %d entries", len(entries))
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout")
+		}
+	})
+
 	t.Run("single_dependency", func(t *testing.T) {
 		testConsul.SetKVString(t, "single-dep-foo", "bar")
 
@@ -1278,3 +2608,80 @@ func TestRunner_commandPath(t *testing.T) {
 		t.Fatalf("unexpected shell: %#v\n", cmd)
 	}
 }
+
+func TestRunner_commandEnv(t *testing.T) {
+	t.Setenv("CT_TEST_KEEP", "keep")
+	t.Setenv("CT_TEST_OTHER", "other")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	cases := []struct {
+		name string
+		env  *config.EnvConfig
+		exp  string
+	}{
+		{
+			"inherit",
+			&config.EnvConfig{},
+			"keep|other|secret|custom=",
+		},
+		{
+			"pristine_custom",
+			&config.EnvConfig{
+				Pristine: config.Bool(true),
+				Custom:   []string{"CT_TEST_CUSTOM=custom"},
+			},
+			"|||custom=custom",
+		},
+		{
+			"allowlist_denylist",
+			&config.EnvConfig{
+				Allowlist: []string{"CT_TEST_KEEP", "AWS_*"},
+				Denylist:  []string{"AWS_*"},
+				Custom:    []string{"CT_TEST_CUSTOM=custom"},
+			},
+			"keep|||custom=custom",
+		},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			outDir, err := os.MkdirTemp("", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(outDir)
+			out := filepath.Join(outDir, "env")
+
+			c := config.TestConfig(&config.Config{
+				Templates: &config.TemplateConfigs{
+					&config.TemplateConfig{
+						Contents:    config.String(tc.name),
+						Destination: config.String(filepath.Join(outDir, "out")),
+						Exec: &config.ExecConfig{
+							Command: []string{`printf '%s|%s|%s|custom=%s' "$CT_TEST_KEEP" "$CT_TEST_OTHER" ` +
+								`"$AWS_SECRET_ACCESS_KEY" "$CT_TEST_CUSTOM" > ` + out},
+							Env: tc.env,
+						},
+					},
+				},
+			})
+			r, err := NewRunner(c, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Stop()
+
+			if err := r.Run(); err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tc.exp {
+				t.Errorf("\nexp: %q\nact: %q", tc.exp, b)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package renderer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+const (
+	// LineEndingLF leaves the line endings of the rendered output unchanged.
+	LineEndingLF = "lf"
+
+	// LineEndingCRLF converts line feeds to carriage return and line feed
+	// pairs.
+	LineEndingCRLF = "crlf"
+)
+
+// utf8BOM is the UTF-8 encoded byte order mark.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// encoder is a writer that applies the line ending and byte order mark of the
+// rendered output as the contents are written.
+type encoder struct {
+	w    io.Writer
+	crlf bool
+	bom  bool
+
+	// cr reports whether the last byte written was a carriage return, so
+	// CRLF pairs split across writes are not doubled.
+	cr bool
+}
+
+// NewEncoder returns a writer that converts line feeds to lineEnding and
+// prefixes the first write with a UTF-8 byte order mark if bom is set. It is
+// used for streamed contents, which Render does not encode itself.
+func NewEncoder(w io.Writer, lineEnding string, bom bool) (io.Writer, error) {
+	var crlf bool
+	switch lineEnding {
+	case "", LineEndingLF:
+	case LineEndingCRLF:
+		crlf = true
+	default:
+		return nil, fmt.Errorf("unknown line ending %q, must be %q or %q",
+			lineEnding, LineEndingLF, LineEndingCRLF)
+	}
+	if !crlf && !bom {
+		return w, nil
+	}
+	return &encoder{w: w, crlf: crlf, bom: bom}, nil
+}
+
+// Write writes p to the underlying writer, encoded.
+func (e *encoder) Write(p []byte) (int, error) {
+	if e.bom {
+		if _, err := e.w.Write(utf8BOM); err != nil {
+			return 0, err
+		}
+		e.bom = false
+	}
+
+	if !e.crlf || len(p) == 0 {
+		return e.w.Write(p)
+	}
+
+	buf := make([]byte, 0, len(p)+bytes.Count(p, []byte("\n")))
+	for _, c := range p {
+		if c == '\n' && !e.cr {
+			buf = append(buf, '\r')
+		}
+		buf = append(buf, c)
+		e.cr = c == '\r'
+	}
+	if _, err := e.w.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Encode returns contents converted to lineEnding, prefixed with a UTF-8 byte
+// order mark if bom is set. These are the bytes Render writes for Contents.
+func Encode(contents []byte, lineEnding string, bom bool) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := NewEncoder(&buf, lineEnding, bom)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := w.(*encoder); !ok {
+		return contents, nil
+	}
+	w.Write(contents)
+	return buf.Bytes(), nil
+}
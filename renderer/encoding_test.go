@@ -0,0 +1,79 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package renderer
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	cases := []struct {
+		name       string
+		contents   string
+		lineEnding string
+		bom        bool
+		exp        string
+		err        bool
+	}{
+		{"default", "a\nb\n", "", false, "a\nb\n", false},
+		{"lf", "a\r\nb\n", LineEndingLF, false, "a\r\nb\n", false},
+		{"crlf", "a\nb\n\nc", LineEndingCRLF, false, "a\r\nb\r\n\r\nc", false},
+		{"crlf_keeps_existing", "a\r\nb\n", LineEndingCRLF, false, "a\r\nb\r\n", false},
+		{"crlf_lone_cr", "a\rb\n", LineEndingCRLF, false, "a\rb\r\n", false},
+		{"bom", "a\n", "", true, "\xef\xbb\xbfa\n", false},
+		{"bom_crlf", "a\n", LineEndingCRLF, true, "\xef\xbb\xbfa\r\n", false},
+		{"bom_empty", "", "", true, "\xef\xbb\xbf", false},
+		{"unknown", "a\n", "cr", false, "", true},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			act, err := Encode([]byte(tc.contents), tc.lineEnding, tc.bom)
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+			if err == nil && string(act) != tc.exp {
+				t.Errorf("\nexp: %q\nact: %q", tc.exp, act)
+			}
+		})
+	}
+}
+
+func TestNewEncoder(t *testing.T) {
+	t.Run("split_writes", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := NewEncoder(&buf, LineEndingCRLF, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// A CRLF pair split across writes is not doubled.
+		for _, p := range []string{"a\r", "\nb", "\n", "", "c\n"} {
+			n, err := w.Write([]byte(p))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != len(p) {
+				t.Errorf("expected %d bytes written, got %d", len(p), n)
+			}
+		}
+
+		if exp, act := "\xef\xbb\xbfa\r\nb\r\nc\r\n", buf.String(); act != exp {
+			t.Errorf("\nexp: %q\nact: %q", exp, act)
+		}
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := NewEncoder(&buf, LineEndingLF, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w != &buf {
+			t.Errorf("expected the writer to be returned as is")
+		}
+	})
+}
@@ -0,0 +1,150 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package renderer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// FanOutManifest is the name of the file in the destination directory of a
+// fan-out template that lists the files its last render wrote, so the files it
+// stops emitting are removed even after a restart.
+const FanOutManifest = ".consul-template-fan-out"
+
+// FanOutInput is used as input to the RenderFanOut function.
+type FanOutInput struct {
+	// RenderInput holds the settings every file is rendered with. Its Path is
+	// the directory the files are rendered into, and its Contents and Stream
+	// are not used.
+	RenderInput
+
+	// Outputs are the contents of each file, by path relative to Path.
+	Outputs map[string][]byte
+}
+
+// FanOutResult is returned by the RenderFanOut function.
+type FanOutResult struct {
+	// DidRender indicates if any file was written or removed.
+	DidRender bool
+
+	// WouldRender indicates if the files would have rendered. Like for Render,
+	// it is true in dry mode and when every file already matches.
+	WouldRender bool
+
+	// Names are the sorted names of the rendered files. They are recorded in
+	// the manifest of the directory for the next render.
+	Names []string
+
+	// Removed are the names of the files that were removed.
+	Removed []string
+}
+
+// RenderFanOut renders each of the outputs to its own file in the directory at
+// the input's Path, and removes the files of the previous render that are not
+// in the outputs anymore. Each file is rendered like Render would. The files
+// of the previous render are read from the manifest of the directory, which
+// is then updated, except in dry mode.
+func RenderFanOut(i *FanOutInput) (*FanOutResult, error) {
+	if i.Path == "" {
+		return nil, ErrMissingDest
+	}
+
+	names := make([]string, 0, len(i.Outputs))
+	for name := range i.Outputs {
+		if !filepath.IsLocal(name) {
+			return nil, fmt.Errorf("output %q is not within %q", name, i.Path)
+		}
+		if filepath.Clean(name) == FanOutManifest {
+			return nil, fmt.Errorf("output %q is reserved for the manifest", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	manifest := filepath.Join(i.Path, FanOutManifest)
+	previous, err := readFanOutManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &FanOutResult{
+		WouldRender: true,
+		Names:       names,
+	}
+
+	for _, name := range names {
+		input := i.RenderInput
+		input.Path = filepath.Join(i.Path, name)
+		input.Contents = i.Outputs[name]
+		input.Stream = nil
+
+		r, err := Render(&input)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed rendering %q", name)
+		}
+		if r.DidRender {
+			result.DidRender = true
+		}
+	}
+
+	for _, name := range previous {
+		if _, ok := i.Outputs[name]; ok {
+			continue
+		}
+
+		path := filepath.Join(i.Path, name)
+		if i.Dry {
+			fmt.Fprintf(i.DryStream, "> %s (removed)\n", path)
+		} else if err := os.Remove(path); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "failed removing %q", name)
+		}
+		result.DidRender = true
+		result.Removed = append(result.Removed, name)
+	}
+
+	if !i.Dry && !reflect.DeepEqual(previous, names) {
+		contents, err := json.Marshal(names)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed encoding manifest")
+		}
+		if err := AtomicWrite(manifest, i.CreateDestDirs, contents, 0o644, false); err != nil {
+			return nil, errors.Wrap(err, "failed writing manifest")
+		}
+	}
+
+	return result, nil
+}
+
+// readFanOutManifest returns the names listed in the manifest at path, or none
+// if it does not exist.
+func readFanOutManifest(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed reading manifest")
+	}
+
+	var names []string
+	if err := json.Unmarshal(b, &names); err != nil {
+		return nil, errors.Wrapf(err, "invalid manifest %q", path)
+	}
+	for _, name := range names {
+		if !filepath.IsLocal(name) {
+			return nil, fmt.Errorf("invalid manifest %q: %q is not within %q", path, name, filepath.Dir(path))
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,219 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package renderer
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeManifest writes the manifest of dir with the given names.
+func writeManifest(t *testing.T, dir string, names ...string) {
+	t.Helper()
+
+	b, err := json.Marshal(names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, FanOutManifest), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readDir returns the contents of the files in dir, by name, except for the
+// manifest.
+func readDir(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string, len(entries))
+	for _, e := range entries {
+		if e.Name() == FanOutManifest {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[e.Name()] = string(b)
+	}
+	return files
+}
+
+func TestRenderFanOut(t *testing.T) {
+	t.Run("writes_and_removes", func(t *testing.T) {
+		outDir := t.TempDir()
+
+		rr, err := RenderFanOut(&FanOutInput{
+			RenderInput: RenderInput{Path: outDir},
+			Outputs: map[string][]byte{
+				"a.conf": []byte("a"),
+				"b.conf": []byte("b"),
+				"c.conf": []byte("c"),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rr.DidRender || !rr.WouldRender {
+			t.Errorf("Bad render results; would: %v, did: %v", rr.WouldRender, rr.DidRender)
+		}
+		exp := map[string]string{"a.conf": "a", "b.conf": "b", "c.conf": "c"}
+		if act := readDir(t, outDir); !reflect.DeepEqual(exp, act) {
+			t.Errorf("\nexp: %#v\nact: %#v", exp, act)
+		}
+
+		rr, err = RenderFanOut(&FanOutInput{
+			RenderInput: RenderInput{Path: outDir},
+			Outputs: map[string][]byte{
+				"a.conf": []byte("a"),
+				"c.conf": []byte("c2"),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rr.DidRender || !rr.WouldRender {
+			t.Errorf("Bad render results; would: %v, did: %v", rr.WouldRender, rr.DidRender)
+		}
+		if exp := []string{"a.conf", "c.conf"}; !reflect.DeepEqual(exp, rr.Names) {
+			t.Errorf("\nexp: %#v\nact: %#v", exp, rr.Names)
+		}
+		if exp := []string{"b.conf"}; !reflect.DeepEqual(exp, rr.Removed) {
+			t.Errorf("\nexp: %#v\nact: %#v", exp, rr.Removed)
+		}
+		exp = map[string]string{"a.conf": "a", "c.conf": "c2"}
+		if act := readDir(t, outDir); !reflect.DeepEqual(exp, act) {
+			t.Errorf("\nexp: %#v\nact: %#v", exp, act)
+		}
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		outDir := t.TempDir()
+		input := &FanOutInput{
+			RenderInput: RenderInput{Path: outDir},
+			Outputs:     map[string][]byte{"a.conf": []byte("a")},
+		}
+
+		rr, err := RenderFanOut(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr, err = RenderFanOut(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rr.DidRender || !rr.WouldRender {
+			t.Errorf("Bad render results; would: %v, did: %v", rr.WouldRender, rr.DidRender)
+		}
+	})
+
+	t.Run("previous_already_removed", func(t *testing.T) {
+		outDir := t.TempDir()
+		writeManifest(t, outDir, "gone.conf")
+
+		rr, err := RenderFanOut(&FanOutInput{
+			RenderInput: RenderInput{Path: outDir},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rr.DidRender || len(rr.Removed) != 0 {
+			t.Errorf("expected nothing to be removed, got %v", rr.Removed)
+		}
+	})
+
+	t.Run("dry", func(t *testing.T) {
+		outDir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(outDir, "b.conf"), []byte("b"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		writeManifest(t, outDir, "b.conf")
+
+		var buf bytes.Buffer
+		rr, err := RenderFanOut(&FanOutInput{
+			RenderInput: RenderInput{Path: outDir, Dry: true, DryStream: &buf},
+			Outputs:     map[string][]byte{"a.conf": []byte("a")},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rr.DidRender || !rr.WouldRender {
+			t.Errorf("Bad render results; would: %v, did: %v", rr.WouldRender, rr.DidRender)
+		}
+
+		exp := "> " + filepath.Join(outDir, "a.conf") + "\na" +
+			"> " + filepath.Join(outDir, "b.conf") + " (removed)\n"
+		if act := buf.String(); exp != act {
+			t.Errorf("\nexp: %q\nact: %q", exp, act)
+		}
+		if act := readDir(t, outDir); !reflect.DeepEqual(map[string]string{"b.conf": "b"}, act) {
+			t.Errorf("expected dry mode not to touch the directory, got %#v", act)
+		}
+		if b, _ := os.ReadFile(filepath.Join(outDir, FanOutManifest)); string(b) != `["b.conf"]` {
+			t.Errorf("expected dry mode not to touch the manifest, got %q", b)
+		}
+	})
+
+	t.Run("manifest", func(t *testing.T) {
+		outDir := t.TempDir()
+		input := &FanOutInput{
+			RenderInput: RenderInput{Path: outDir, CreateDestDirs: true},
+			Outputs:     map[string][]byte{"a.conf": []byte("a"), "sub/b.conf": []byte("b")},
+		}
+		if _, err := RenderFanOut(input); err != nil {
+			t.Fatal(err)
+		}
+
+		names, err := readFanOutManifest(filepath.Join(outDir, FanOutManifest))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp := []string{"a.conf", "sub/b.conf"}; !reflect.DeepEqual(exp, names) {
+			t.Errorf("\nexp: %#v\nact: %#v", exp, names)
+		}
+	})
+
+	t.Run("manifest_invalid", func(t *testing.T) {
+		outDir := t.TempDir()
+		writeManifest(t, outDir, "../outside.conf")
+
+		if _, err := RenderFanOut(&FanOutInput{RenderInput: RenderInput{Path: outDir}}); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("manifest_reserved", func(t *testing.T) {
+		_, err := RenderFanOut(&FanOutInput{
+			RenderInput: RenderInput{Path: t.TempDir()},
+			Outputs:     map[string][]byte{FanOutManifest: []byte("a")},
+		})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("not_local", func(t *testing.T) {
+		_, err := RenderFanOut(&FanOutInput{
+			RenderInput: RenderInput{Path: t.TempDir()},
+			Outputs:     map[string][]byte{"../a.conf": []byte("a")},
+		})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("missing_dest", func(t *testing.T) {
+		if _, err := RenderFanOut(&FanOutInput{}); err != ErrMissingDest {
+			t.Fatalf("expected %v, got %v", ErrMissingDest, err)
+		}
+	})
+}
@@ -0,0 +1,124 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package renderer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// pipeTimeout is how long a render waits for a reader to open a named
+	// pipe and read the contents written to it.
+	pipeTimeout = 30 * time.Second
+
+	// pipePollInterval is how often opening a named pipe is retried while it
+	// has no reader.
+	pipePollInterval = 100 * time.Millisecond
+
+	// pipes holds the contents last written to each named pipe. A pipe has no
+	// contents to compare to, so this is what a render is compared to instead.
+	pipes = struct {
+		sync.Mutex
+		contents map[string][]byte
+	}{contents: make(map[string][]byte)}
+)
+
+// isPipe reports whether path is a named pipe.
+func isPipe(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&os.ModeNamedPipe != 0
+}
+
+// pipeChanged reports whether the contents differ from those last written to
+// the named pipe at path.
+func pipeChanged(path string, contents []byte) bool {
+	pipes.Lock()
+	defer pipes.Unlock()
+
+	last, ok := pipes.contents[path]
+	return !ok || !bytes.Equal(last, contents)
+}
+
+// renderPipe writes the contents to the named pipe at the input's Path. The
+// pipe cannot be replaced atomically, so it is written in place, and its
+// ownership and permissions are left to whoever created it.
+func renderPipe(i *RenderInput) (*RenderResult, error) {
+	contents := i.Contents
+	if i.Stream != nil {
+		var buf bytes.Buffer
+		if err := i.Stream.copyTo(&buf); err != nil {
+			return nil, errors.Wrap(err, "failed reading streamed contents")
+		}
+		contents = buf.Bytes()
+	}
+
+	if !pipeChanged(i.Path, contents) {
+		return &RenderResult{
+			DidRender:   false,
+			WouldRender: true,
+			Contents:    contents,
+		}, nil
+	}
+
+	if i.Dry {
+		fmt.Fprintf(i.DryStream, "> %s\n%s", i.Path, contents)
+	} else {
+		if err := writePipe(i.Path, contents, pipeTimeout); err != nil {
+			return nil, errors.Wrap(err, "failed writing pipe")
+		}
+
+		pipes.Lock()
+		pipes.contents[i.Path] = contents
+		pipes.Unlock()
+	}
+
+	return &RenderResult{
+		DidRender:   true,
+		WouldRender: true,
+		Contents:    contents,
+	}, nil
+}
+
+// writePipe writes the contents to the named pipe at path. Opening a pipe for
+// writing blocks until it has a reader, so it is opened without blocking and
+// retried until a reader opens it or the timeout expires. The timeout also
+// bounds the write, in case the reader stops reading.
+func writePipe(path string, contents []byte, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	var f *os.File
+	for {
+		var err error
+		f, err = os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.ENXIO) {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("no reader opened %s within %s", path, timeout)
+		}
+		time.Sleep(pipePollInterval)
+	}
+	defer f.Close()
+
+	if err := f.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	if _, err := f.Write(contents); err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return fmt.Errorf("the reader of %s did not read within %s", path, timeout)
+		}
+		return err
+	}
+	return f.Close()
+}
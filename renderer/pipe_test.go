@@ -0,0 +1,122 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !windows
+// +build !windows
+
+package renderer
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRender_pipe(t *testing.T) {
+	mkfifo := func(t *testing.T) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "pipe")
+		if err := syscall.Mkfifo(path, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// read reads the pipe once in the background, and returns the channel the
+	// contents are sent on.
+	read := func(t *testing.T, path string) <-chan string {
+		t.Helper()
+		ch := make(chan string, 1)
+		go func() {
+			f, err := os.Open(path)
+			if err != nil {
+				ch <- err.Error()
+				return
+			}
+			defer f.Close()
+			b, err := io.ReadAll(f)
+			if err != nil {
+				ch <- err.Error()
+				return
+			}
+			ch <- string(b)
+		}()
+		return ch
+	}
+
+	t.Run("reader", func(t *testing.T) {
+		path := mkfifo(t)
+
+		for _, tc := range []struct {
+			contents  string
+			didRender bool
+		}{
+			{"first", true},
+			{"first", false},
+			{"second", true},
+		} {
+			var ch <-chan string
+			if tc.didRender {
+				ch = read(t, path)
+			}
+			result, err := Render(&RenderInput{
+				Path:     path,
+				Contents: []byte(tc.contents),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.DidRender != tc.didRender || !result.WouldRender {
+				t.Fatalf("expected DidRender %t, got %#v", tc.didRender, result)
+			}
+			if changed, err := Changed(path, []byte(tc.contents)); err != nil || changed {
+				t.Errorf("expected the pipe to be unchanged, got %t (%v)", changed, err)
+			}
+
+			if ch != nil {
+				select {
+				case act := <-ch:
+					if act != tc.contents {
+						t.Errorf("expected %q to be %q", act, tc.contents)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatal("reader did not receive the contents")
+				}
+			}
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode()&os.ModeNamedPipe == 0 {
+			t.Errorf("expected %s to still be a named pipe, got %s", path, info.Mode())
+		}
+	})
+
+	t.Run("no_reader", func(t *testing.T) {
+		path := mkfifo(t)
+
+		timeout := pipeTimeout
+		pipeTimeout = 200 * time.Millisecond
+		defer func() { pipeTimeout = timeout }()
+
+		start := time.Now()
+		if _, err := Render(&RenderInput{
+			Path:     path,
+			Contents: []byte("contents"),
+		}); err == nil {
+			t.Fatal("expected an error without a reader")
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("expected the render to time out, took %s", elapsed)
+		}
+
+		if changed, err := Changed(path, []byte("contents")); err != nil || !changed {
+			t.Errorf("expected the pipe to be changed, got %t (%v)", changed, err)
+		}
+	})
+}
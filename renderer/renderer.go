@@ -18,6 +18,10 @@ const (
 	// DefaultFilePerms are the default file permissions for files rendered onto
 	// disk when a specific file permission has not already been specified.
 	DefaultFilePerms = 0o644
+
+	// DefaultDirPerms are the permissions for parent directories created for
+	// rendered files when create_dest_dirs is enabled.
+	DefaultDirPerms = 0o700
 )
 
 var (
@@ -39,6 +43,22 @@ type RenderInput struct {
 	Path           string
 	Perms          os.FileMode
 	User, Group    string
+
+	// Stream, when set, holds the contents to render instead of Contents. The
+	// caller must close it once Render returns.
+	Stream *Stream
+
+	// LineEnding and BOM are the encoding of the rendered file, see
+	// NewEncoder. They are applied to Contents as the final transformation
+	// before writing. Streamed contents must be written through NewEncoder
+	// instead.
+	LineEnding string
+	BOM        bool
+
+	// Validate is the format the rendered contents must be well-formed in,
+	// see Validate. Contents that fail validation are not written, so the
+	// existing file is kept.
+	Validate string
 }
 
 // RenderResult is returned and stored. It contains the status of the render
@@ -55,14 +75,56 @@ type RenderResult struct {
 	WouldRender bool
 
 	// Contents are the actual contents of the resulting template from the render
-	// operation.
+	// operation. It is nil when the contents were streamed.
 	Contents []byte
 }
 
 // Render atomically renders a file contents to disk, returning a result of
-// whether it would have rendered and actually did render.
+// whether it would have rendered and actually did render. A destination that
+// is a named pipe is written in place, see renderPipe.
 func Render(i *RenderInput) (*RenderResult, error) {
-	existing, err := os.ReadFile(i.Path)
+	if i.Stream == nil {
+		contents, err := Encode(i.Contents, i.LineEnding, i.BOM)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed encoding contents")
+		}
+		encoded := *i
+		encoded.Contents = contents
+		i = &encoded
+	}
+
+	if i.Validate != "" {
+		var err error
+		if i.Stream != nil {
+			// Streamed contents are validated from the temporary file.
+			var r io.Reader
+			if r, err = i.Stream.reader(); err != nil {
+				return nil, errors.Wrap(err, "failed reading streamed contents")
+			}
+			err = ValidateReader(r, i.Validate)
+		} else {
+			err = Validate(i.Contents, i.Validate)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed validating contents")
+		}
+	}
+
+	// Reading a named pipe would consume what a reader expects, and renaming
+	// over it would replace the pipe with a file.
+	if isPipe(i.Path) {
+		return renderPipe(i)
+	}
+
+	// Streamed contents are compared by hash, so there is no need to read the
+	// existing file into memory.
+	var existing []byte
+	var err error
+	if i.Stream != nil {
+		_, err = os.Stat(i.Path)
+	} else {
+		existing, err = os.ReadFile(i.Path)
+	}
 	fileExists := !os.IsNotExist(err)
 	if err != nil && fileExists {
 		return nil, errors.Wrap(err, "failed reading file")
@@ -87,7 +149,15 @@ func Render(i *RenderInput) (*RenderResult, error) {
 		}
 	}
 
-	if bytes.Equal(existing, i.Contents) && fileExists && !chownNeeded {
+	unchanged := bytes.Equal(existing, i.Contents)
+	if i.Stream != nil && fileExists {
+		unchanged, err = i.Stream.matches(i.Path)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed reading file")
+		}
+	}
+
+	if unchanged && fileExists && !chownNeeded {
 		return &RenderResult{
 			DidRender:   false,
 			WouldRender: true,
@@ -96,9 +166,30 @@ func Render(i *RenderInput) (*RenderResult, error) {
 	}
 
 	if i.Dry {
-		fmt.Fprintf(i.DryStream, "> %s\n%s", i.Path, i.Contents)
+		if i.Stream != nil {
+			fmt.Fprintf(i.DryStream, "> %s\n", i.Path)
+			if err := i.Stream.copyTo(i.DryStream); err != nil {
+				return nil, errors.Wrap(err, "failed reading streamed contents")
+			}
+		} else {
+			fmt.Fprintf(i.DryStream, "> %s\n%s", i.Path, i.Contents)
+		}
 	} else {
-		if err := AtomicWrite(i.Path, i.CreateDestDirs, i.Contents, i.Perms, i.Backup); err != nil {
+		// Create any missing parent directories up front so they get the same
+		// ownership as the file. AtomicWrite returns ErrNoParentDir when they
+		// are missing and creation is disabled.
+		if i.CreateDestDirs {
+			if err := mkdirDestDirs(filepath.Dir(i.Path), uid, gid); err != nil {
+				return nil, errors.Wrap(err, "failed creating parent directories")
+			}
+		}
+
+		if i.Stream != nil {
+			err = i.Stream.commit(i.Path, i.CreateDestDirs, i.Perms, i.Backup)
+		} else {
+			err = AtomicWrite(i.Path, i.CreateDestDirs, i.Contents, i.Perms, i.Backup)
+		}
+		if err != nil {
 			return nil, errors.Wrap(err, "failed writing file")
 		}
 
@@ -114,11 +205,29 @@ func Render(i *RenderInput) (*RenderResult, error) {
 	}, nil
 }
 
+// Changed reports whether the file at path is missing or its contents differ
+// from the given contents. For a named pipe, the contents are compared to
+// those last rendered to it.
+func Changed(path string, contents []byte) (bool, error) {
+	if isPipe(path) {
+		return pipeChanged(path, contents), nil
+	}
+
+	existing, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed reading file")
+	}
+	return !bytes.Equal(existing, contents), nil
+}
+
 // AtomicWrite accepts a destination path and the template contents. It writes
 // the template contents to a TempFile on disk, returning if any errors occur.
 //
 // If the parent destination directory does not exist, it will be created
-// automatically with permissions 0755. To use a different permission, create
+// automatically with permissions 0700. To use a different permission, create
 // the directory first or use `chmod` in a Command.
 //
 // If the destination path exists, all attempts will be made to preserve the
@@ -134,6 +243,26 @@ func Render(i *RenderInput) (*RenderResult, error) {
 // Windows and it is impossible to rename atomically on Windows. For more on
 // this see: https://github.com/golang/go/issues/22397#issuecomment-498856679
 func AtomicWrite(path string, createDestDirs bool, contents []byte, perms os.FileMode, backup bool) error {
+	if err := ensureParentDir(path, createDestDirs); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(contents); err != nil {
+		return err
+	}
+
+	return replaceFile(f, path, perms, backup)
+}
+
+// ensureParentDir checks that the parent directory of path exists, creating it
+// when createDestDirs is set.
+func ensureParentDir(path string, createDestDirs bool) error {
 	if path == "" {
 		return ErrMissingDest
 	}
@@ -141,24 +270,19 @@ func AtomicWrite(path string, createDestDirs bool, contents []byte, perms os.Fil
 	parent := filepath.Dir(path)
 	if _, err := os.Stat(parent); os.IsNotExist(err) {
 		if createDestDirs {
-			if err := os.MkdirAll(parent, 0o755); err != nil {
+			if err := mkdirDestDirs(parent, -1, -1); err != nil {
 				return err
 			}
 		} else {
 			return ErrNoParentDir
 		}
 	}
+	return nil
+}
 
-	f, err := os.CreateTemp(parent, "")
-	if err != nil {
-		return err
-	}
-	defer os.Remove(f.Name())
-
-	if _, err := f.Write(contents); err != nil {
-		return err
-	}
-
+// replaceFile syncs and closes the temporary file f and renames it to path,
+// applying the permissions and backup described in AtomicWrite.
+func replaceFile(f *os.File, path string, perms os.FileMode, backup bool) error {
 	if err := f.Sync(); err != nil {
 		return err
 	}
@@ -217,3 +341,28 @@ func AtomicWrite(path string, createDestDirs bool, contents []byte, perms os.Fil
 func intPtr(i int) *int {
 	return &i
 }
+
+// mkdirDestDirs creates dir and any missing parents with DefaultDirPerms.
+// Each directory it creates is chowned to uid/gid; existing directories are
+// left untouched.
+func mkdirDestDirs(dir string, uid, gid int) error {
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if parent := filepath.Dir(dir); parent != dir {
+		if err := mkdirDestDirs(parent, uid, gid); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Mkdir(dir, DefaultDirPerms); err != nil {
+		if os.IsExist(err) {
+			return nil
+		}
+		return err
+	}
+	return setFileOwnership(dir, uid, gid)
+}
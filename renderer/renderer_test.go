@@ -5,6 +5,7 @@ package renderer
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path"
@@ -318,6 +319,480 @@ func TestRender(t *testing.T) {
 				rr.WouldRender, rr.DidRender)
 		}
 	})
+
+	t.Run("creates-nested-dest-dirs", func(t *testing.T) {
+		outDir, err := os.MkdirTemp("", "")
+		if err != nil {
+			t.Error(err)
+		}
+		defer os.RemoveAll(outDir)
+		if err := os.Chmod(outDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(outDir, "a", "b", "c", "no-exists")
+
+		rr, err := Render(&RenderInput{
+			Path:           path,
+			Contents:       []byte("first"),
+			CreateDestDirs: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rr.DidRender {
+			t.Errorf("expected %q to be rendered", path)
+		}
+
+		for _, dir := range []string{"a", "a/b", "a/b/c"} {
+			stat, err := os.Stat(filepath.Join(outDir, dir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if perm := stat.Mode().Perm(); perm != DefaultDirPerms {
+				t.Errorf("expected %q to have mode %q, got %q",
+					dir, os.FileMode(DefaultDirPerms), perm)
+			}
+		}
+
+		// Pre-existing directories keep their permissions.
+		stat, err := os.Stat(outDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := stat.Mode().Perm(); perm != 0o755 {
+			t.Errorf("expected %q to keep mode 0755, got %q", outDir, perm)
+		}
+	})
+
+	t.Run("no-create-dest-dirs", func(t *testing.T) {
+		outDir, err := os.MkdirTemp("", "")
+		if err != nil {
+			t.Error(err)
+		}
+		defer os.RemoveAll(outDir)
+		path := filepath.Join(outDir, "a", "b", "no-exists")
+
+		_, err = Render(&RenderInput{
+			Path:           path,
+			Contents:       []byte("first"),
+			CreateDestDirs: false,
+		})
+		if !errors.Is(err, ErrNoParentDir) {
+			t.Errorf("expected %q to be %q", err, ErrNoParentDir)
+		}
+		if _, err := os.Stat(filepath.Join(outDir, "a")); !os.IsNotExist(err) {
+			t.Errorf("expected parent directories not to be created: %v", err)
+		}
+	})
+}
+
+func TestRender_validate(t *testing.T) {
+	outDir, err := os.MkdirTemp("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outDir)
+	path := filepath.Join(outDir, "out.json")
+	old := []byte(`{"version": 1}`)
+
+	cases := []struct {
+		name   string
+		stream bool
+		in     []byte
+		err    bool
+		exp    []byte
+	}{
+		{
+			"valid",
+			false,
+			[]byte(`{"version": 2}`),
+			false,
+			[]byte(`{"version": 2}`),
+		},
+		{
+			"invalid",
+			false,
+			[]byte(`{"version": 2,}`),
+			true,
+			old,
+		},
+		{
+			"stream_valid",
+			true,
+			[]byte(`{"version": 2}`),
+			false,
+			[]byte(`{"version": 2}`),
+		},
+		{
+			"stream_invalid",
+			true,
+			[]byte(`{"version": 2,}`),
+			true,
+			old,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := os.WriteFile(path, old, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			input := &RenderInput{
+				Path:     path,
+				Contents: tc.in,
+				Validate: ValidateJSON,
+			}
+			if tc.stream {
+				s, err := NewStream(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer s.Close()
+				if _, err := s.Write(tc.in); err != nil {
+					t.Fatal(err)
+				}
+				input.Contents, input.Stream = nil, s
+			}
+
+			rr, err := Render(input)
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error: %t, got: %v", tc.err, err)
+			}
+			if err == nil && !rr.DidRender {
+				t.Errorf("expected the file to be rendered")
+			}
+
+			act, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(tc.exp, act) {
+				t.Errorf("\nexp: %q\nact: %q", tc.exp, act)
+			}
+		})
+	}
+}
+
+func TestRender_Stream(t *testing.T) {
+	// newStream returns a Stream for path holding contents.
+	newStream := func(t *testing.T, path string, contents []byte) *Stream {
+		t.Helper()
+		s, err := NewStream(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		if _, err := s.Write(contents); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	t.Run("file-exists-same-content", func(t *testing.T) {
+		outDir, err := os.MkdirTemp("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(outDir)
+		path := filepath.Join(outDir, "out")
+		if err := os.WriteFile(path, []byte("first"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		rr, err := Render(&RenderInput{
+			Path:   path,
+			Stream: newStream(t, path, []byte("first")),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rr.WouldRender || rr.DidRender {
+			t.Errorf("Bad render results; would: %v, did: %v",
+				rr.WouldRender, rr.DidRender)
+		}
+	})
+
+	t.Run("file-exists-diff-content", func(t *testing.T) {
+		outDir, err := os.MkdirTemp("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(outDir)
+		path := filepath.Join(outDir, "out")
+		if err := os.WriteFile(path, []byte("first"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		// Same size, different contents, so only the hash tells them apart.
+		rr, err := Render(&RenderInput{
+			Path:   path,
+			Stream: newStream(t, path, []byte("other")),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rr.WouldRender || !rr.DidRender {
+			t.Errorf("Bad render results; would: %v, did: %v",
+				rr.WouldRender, rr.DidRender)
+		}
+		if rr.Contents != nil {
+			t.Errorf("expected no contents for a streamed render, got %q", rr.Contents)
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "other" {
+			t.Errorf("expected %q to be %q", b, "other")
+		}
+		stat, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stat.Mode() != 0o600 {
+			t.Errorf("expected %q to be %q", stat.Mode(), os.FileMode(0o600))
+		}
+
+		entries, err := os.ReadDir(outDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Errorf("expected the temporary file to be moved into place, got %d entries", len(entries))
+		}
+	})
+
+	t.Run("large-file", func(t *testing.T) {
+		outDir, err := os.MkdirTemp("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(outDir)
+		path := filepath.Join(outDir, "out")
+
+		s, err := NewStream(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+
+		var exp bytes.Buffer
+		for i := 0; i < 100000; i++ {
+			line := fmt.Sprintf("%08d allow 10.0.%d.%d/32\n", i, i/256%256, i%256)
+			exp.WriteString(line)
+			if _, err := s.Write([]byte(line)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if _, err := Render(&RenderInput{Path: path, Stream: s}); err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(exp.Bytes(), b) {
+			t.Errorf("expected %d bytes, got %d bytes that differ", exp.Len(), len(b))
+		}
+	})
+
+	t.Run("create-dest-dirs", func(t *testing.T) {
+		outDir, err := os.MkdirTemp("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(outDir)
+		path := filepath.Join(outDir, "a", "b", "out")
+
+		_, err = Render(&RenderInput{
+			Path:           path,
+			CreateDestDirs: true,
+			Stream:         newStream(t, path, []byte("first")),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, err := os.ReadFile(path); err != nil || string(b) != "first" {
+			t.Errorf("expected %q to be rendered: %q, %v", path, b, err)
+		}
+	})
+
+	t.Run("no-create-dest-dirs", func(t *testing.T) {
+		outDir, err := os.MkdirTemp("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(outDir)
+		path := filepath.Join(outDir, "a", "out")
+
+		_, err = Render(&RenderInput{
+			Path:   path,
+			Stream: newStream(t, path, []byte("first")),
+		})
+		if !errors.Is(err, ErrNoParentDir) {
+			t.Errorf("expected %q to be %q", err, ErrNoParentDir)
+		}
+	})
+
+	t.Run("dry", func(t *testing.T) {
+		outDir, err := os.MkdirTemp("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(outDir)
+		path := filepath.Join(outDir, "out")
+
+		var dry bytes.Buffer
+		rr, err := Render(&RenderInput{
+			Path:      path,
+			Dry:       true,
+			DryStream: &dry,
+			Stream:    newStream(t, path, []byte("first")),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rr.WouldRender || !rr.DidRender {
+			t.Errorf("Bad render results; would: %v, did: %v",
+				rr.WouldRender, rr.DidRender)
+		}
+		if exp := "> " + path + "\nfirst"; dry.String() != exp {
+			t.Errorf("expected %q to be %q", dry.String(), exp)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected %q not to be written in dry mode: %v", path, err)
+		}
+	})
+
+	t.Run("close-removes-temp-file", func(t *testing.T) {
+		outDir, err := os.MkdirTemp("", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(outDir)
+
+		s, err := NewStream(filepath.Join(outDir, "out"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+		entries, err := os.ReadDir(outDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("expected the temporary file to be removed, got %d entries", len(entries))
+		}
+	})
+}
+
+func TestRender_encoding(t *testing.T) {
+	outDir, err := os.MkdirTemp("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outDir)
+	path := filepath.Join(outDir, "out")
+	exp := []byte("\xef\xbb\xbfline1\r\nline2\r\n")
+
+	for _, stream := range []bool{false, true} {
+		t.Run(fmt.Sprintf("stream_%t", stream), func(t *testing.T) {
+			os.Remove(path)
+			input := &RenderInput{
+				Path:       path,
+				Contents:   []byte("line1\nline2\n"),
+				LineEnding: LineEndingCRLF,
+				BOM:        true,
+			}
+			if stream {
+				s, err := NewStream(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer s.Close()
+				w, err := NewEncoder(s, LineEndingCRLF, true)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := w.Write(input.Contents); err != nil {
+					t.Fatal(err)
+				}
+				input.Contents, input.Stream = nil, s
+			}
+
+			rr, err := Render(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !rr.DidRender {
+				t.Errorf("expected the file to be rendered")
+			}
+
+			act, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(exp, act) {
+				t.Errorf("\nexp: %q\nact: %q", exp, act)
+			}
+		})
+	}
+
+	// The encoded contents are compared with the existing file.
+	rr, err := Render(&RenderInput{
+		Path:       path,
+		Contents:   []byte("line1\nline2\n"),
+		LineEnding: LineEndingCRLF,
+		BOM:        true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr.DidRender || !rr.WouldRender {
+		t.Errorf("Bad render results; would: %v, did: %v", rr.WouldRender, rr.DidRender)
+	}
+}
+
+func TestChanged(t *testing.T) {
+	outDir, err := os.MkdirTemp("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outDir)
+	path := filepath.Join(outDir, "out")
+
+	cases := []struct {
+		name     string
+		existing string
+		contents string
+		exp      bool
+	}{
+		{"missing", "", "first", true},
+		{"same", "first", "first", false},
+		{"different", "first", "second", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Remove(path)
+			if tc.existing != "" {
+				if err := os.WriteFile(path, []byte(tc.existing), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			changed, err := Changed(path, []byte(tc.contents))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed != tc.exp {
+				t.Errorf("expected %t to be %t", changed, tc.exp)
+			}
+		})
+	}
 }
 
 func TestRender_Chown(t *testing.T) {
@@ -514,6 +989,39 @@ func TestRender_Chown(t *testing.T) {
 		}
 	})
 
+	t.Run("sets-dir-ownership-when-creating-dest-dirs", func(t *testing.T) {
+		outDir, err := os.MkdirTemp("", "")
+		if err != nil {
+			t.Error(err)
+		}
+		defer os.RemoveAll(outDir)
+		path := filepath.Join(outDir, "a", "b", "no-exists")
+
+		rr, err := Render(&RenderInput{
+			Path:           path,
+			Contents:       []byte("first"),
+			CreateDestDirs: true,
+			Group:          strconv.Itoa(wantedGid),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rr.DidRender {
+			t.Errorf("expected %q to be rendered", path)
+		}
+
+		for _, dir := range []string{"a", "a/b"} {
+			gotUid, gotGid, err := getFileOwnership(filepath.Join(outDir, dir))
+			if err != nil {
+				t.Errorf("getFileOwnership: %s", err)
+			}
+			if gotGid != wantedGid {
+				t.Errorf("Bad dir ownership for %q; gotUid: %v, wantedGid: %v, gotGid: %v",
+					dir, gotUid, wantedGid, gotGid)
+			}
+		}
+	})
+
 	t.Run("should-be-noop-when-missing-user", func(t *testing.T) {
 		outDir, err := os.MkdirTemp("", "")
 		if err != nil {
@@ -0,0 +1,147 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package renderer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// streamBufferSize is the size of the buffer in front of the stream's
+// temporary file. The template engine writes its output in many small pieces,
+// so this batches them into fewer writes.
+const streamBufferSize = 64 * 1024
+
+// Stream is a temporary file that rendered contents are written to as they are
+// produced, so large templates are never held in memory. The contents are
+// hashed as they are written, which lets Render tell whether they differ from
+// the destination without reading either file into memory.
+//
+// A Stream is passed to Render with RenderInput.Stream and must be closed once
+// the render is done.
+type Stream struct {
+	f    *os.File
+	w    *bufio.Writer
+	hash hash.Hash
+	size int64
+}
+
+// NewStream creates a Stream for the given destination path. The temporary
+// file is created in the closest existing parent directory of the destination,
+// so it can be renamed into place without crossing filesystems.
+func NewStream(path string) (*Stream, error) {
+	if path == "" {
+		return nil, ErrMissingDest
+	}
+
+	dir := filepath.Dir(path)
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	f, err := os.CreateTemp(dir, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Stream{
+		f:    f,
+		w:    bufio.NewWriterSize(f, streamBufferSize),
+		hash: sha256.New(),
+	}, nil
+}
+
+// Write writes p to the temporary file.
+func (s *Stream) Write(p []byte) (int, error) {
+	n, err := s.w.Write(p)
+	s.hash.Write(p[:n])
+	s.size += int64(n)
+	return n, err
+}
+
+// Size returns the number of bytes written to the stream.
+func (s *Stream) Size() int64 {
+	return s.size
+}
+
+// Close closes and removes the temporary file. It is safe to call after
+// Render moved the file into place.
+func (s *Stream) Close() error {
+	s.f.Close() // ignore error, the file may already be closed
+	if err := os.Remove(s.f.Name()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// matches reports whether the file at path has the same contents as the
+// stream.
+func (s *Stream) matches(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	if info.Size() != s.size {
+		return false, nil
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return false, err
+	}
+	return bytes.Equal(h.Sum(nil), s.hash.Sum(nil)), nil
+}
+
+// reader returns a reader of the streamed contents from the start.
+func (s *Stream) reader() (io.Reader, error) {
+	if err := s.w.Flush(); err != nil {
+		return nil, err
+	}
+	if _, err := s.f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return s.f, nil
+}
+
+// copyTo copies the streamed contents to w.
+func (s *Stream) copyTo(w io.Writer) error {
+	r, err := s.reader()
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// commit moves the temporary file to path. See AtomicWrite for how the parent
+// directories, permissions and backup are handled.
+func (s *Stream) commit(path string, createDestDirs bool, perms os.FileMode, backup bool) error {
+	if err := ensureParentDir(path, createDestDirs); err != nil {
+		return err
+	}
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	return replaceFile(s.f, path, perms, backup)
+}
@@ -0,0 +1,96 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package renderer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// ValidateJSON checks that the rendered output is a single JSON value.
+	ValidateJSON = "json"
+
+	// ValidateYAML checks that every document of the rendered output is valid
+	// YAML.
+	ValidateYAML = "yaml"
+)
+
+// Validate checks that contents are well-formed in the given format. An empty
+// format accepts any contents. A leading byte order mark is ignored, so the
+// encoded contents can be checked as they are written.
+func Validate(contents []byte, format string) error {
+	return ValidateReader(bytes.NewReader(contents), format)
+}
+
+// ValidateReader is like Validate, but reads the contents from r, so that
+// streamed contents are checked without reading them into memory. JSON is
+// checked a token at a time, and YAML a document at a time.
+func ValidateReader(r io.Reader, format string) error {
+	br := bufio.NewReader(r)
+	if prefix, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(prefix, utf8BOM) {
+		br.Discard(len(utf8BOM))
+	}
+
+	switch format {
+	case "":
+		return nil
+	case ValidateJSON:
+		if err := validateJSON(br); err != nil {
+			return errors.Wrap(err, "contents are not valid JSON")
+		}
+		return nil
+	case ValidateYAML:
+		dec := yaml.NewDecoder(br)
+		for {
+			var v interface{}
+			err := dec.Decode(&v)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return errors.Wrap(err, "contents are not valid YAML")
+			}
+		}
+	default:
+		return fmt.Errorf("unknown validate format %q, must be %q or %q",
+			format, ValidateJSON, ValidateYAML)
+	}
+}
+
+// validateJSON checks that r holds a single JSON value, walking its tokens so
+// that only one token is held in memory at a time.
+func validateJSON(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	depth, done := 0, false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			if done {
+				return nil
+			}
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		if done {
+			return fmt.Errorf("invalid data after top-level value at offset %d", dec.InputOffset())
+		}
+
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		done = depth == 0
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package renderer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name     string
+		contents string
+		format   string
+		err      bool
+	}{
+		{"none", "{", "", false},
+		{"json", `{"a": [1, 2]}`, ValidateJSON, false},
+		{"json_bom", "\xef\xbb\xbf{\"a\": 1}\r\n", ValidateJSON, false},
+		{"json_trailing_comma", `{"a": 1,}`, ValidateJSON, true},
+		{"json_empty", "", ValidateJSON, true},
+		{"json_two_values", `{} {}`, ValidateJSON, true},
+		{"json_unterminated", `{"a": [1, 2]`, ValidateJSON, true},
+		{"json_scalar", `"a"`, ValidateJSON, false},
+		{"yaml", "a:\n  - 1\n  - 2\n", ValidateYAML, false},
+		{"yaml_documents", "a: 1\n---\nb: 2\n", ValidateYAML, false},
+		{"yaml_empty", "", ValidateYAML, false},
+		{"yaml_bad_indent", "a:\n  b: 1\n c: 2\n", ValidateYAML, true},
+		{"yaml_bad_second_document", "a: 1\n---\nb: [\n", ValidateYAML, true},
+		{"unknown", "a", "toml", true},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%d_%s", i, tc.name), func(t *testing.T) {
+			err := Validate([]byte(tc.contents), tc.format)
+			if (err != nil) != tc.err {
+				t.Errorf("expected error: %t, got: %v", tc.err, err)
+			}
+
+			err = ValidateReader(strings.NewReader(tc.contents), tc.format)
+			if (err != nil) != tc.err {
+				t.Errorf("reader: expected error: %t, got: %v", tc.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package telemetry exports OpenTelemetry traces of render cycles, dependency
+// fetches and command executions.
+//
+// Spans are always created through the global tracer provider. Until Setup
+// installs an exporting provider that is the OpenTelemetry no-op provider, so
+// instrumented code costs next to nothing when tracing is disabled.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/consul-template/version"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans created by consul-template.
+const instrumentationName = "github.com/hashicorp/consul-template"
+
+// Config is the configuration for the trace exporter.
+type Config struct {
+	// Enabled turns exporting on. When false, Setup does nothing.
+	Enabled bool
+
+	// Endpoint is the URL of the OTLP/HTTP collector.
+	Endpoint string
+}
+
+// Setup installs a global tracer provider that exports spans to the configured
+// collector, and the W3C trace context propagator. The returned function
+// flushes any buffered spans and must be called before exiting.
+func Setup(c *Config) (func(context.Context) error, error) {
+	if !c.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(c.Endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("telemetry: %w", err)
+	}
+
+	res := resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(version.Name),
+		semconv.ServiceVersion(version.Version+version.VersionPrerelease),
+	)
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return tp.Shutdown, nil
+}
+
+// Tracer returns the tracer used to instrument consul-template.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// End ends the span, marking it as failed with err if err is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// ContextFromEnv returns a context carrying the trace context found in the
+// given environment, e.g. a TRACEPARENT variable set by the process that
+// started consul-template. Spans started from it continue that trace.
+func ContextFromEnv(environ []string) context.Context {
+	carrier := propagation.MapCarrier{}
+	for _, kv := range environ {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		carrier[strings.ToLower(k)] = v
+	}
+	return otel.GetTextMapPropagator().Extract(context.Background(), carrier)
+}
+
+// Env returns environment variables, e.g. TRACEPARENT, that carry the trace
+// context of ctx to a child process. It returns nothing when tracing is
+// disabled.
+func Env(ctx context.Context) []string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	env := make([]string, 0, len(carrier))
+	for k, v := range carrier {
+		env = append(env, strings.ToUpper(k)+"="+v)
+	}
+	sort.Strings(env)
+	return env
+}
@@ -0,0 +1,89 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func testRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+	return recorder
+}
+
+func TestSetup_disabled(t *testing.T) {
+	shutdown, err := Setup(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	_, span := Tracer().Start(context.Background(), "test")
+	defer span.End()
+	if span.IsRecording() {
+		t.Errorf("expected spans not to be recorded when tracing is disabled")
+	}
+	if env := Env(trace.ContextWithSpan(context.Background(), span)); len(env) != 0 {
+		t.Errorf("expected no trace context in the environment, got %v", env)
+	}
+}
+
+func TestEnv(t *testing.T) {
+	testRecorder(t)
+
+	parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := ContextFromEnv([]string{"FOO=bar", "TRACEPARENT=" + parent})
+
+	ctx, span := Tracer().Start(ctx, "test")
+	defer span.End()
+
+	sc := span.SpanContext()
+	if sc.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected the span to continue the trace from the environment, got %s", sc.TraceID())
+	}
+
+	exp := []string{"TRACEPARENT=00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-01"}
+	if act := Env(ctx); !reflect.DeepEqual(exp, act) {
+		t.Errorf("\nexp: %#v\nact: %#v", exp, act)
+	}
+}
+
+func TestEnd(t *testing.T) {
+	recorder := testRecorder(t)
+
+	_, span := Tracer().Start(context.Background(), "ok")
+	End(span, nil)
+	_, span = Tracer().Start(context.Background(), "failed")
+	End(span, errors.New("boom"))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 ended spans, got %d", len(spans))
+	}
+	if s := spans[0].Status(); s.Code != codes.Unset {
+		t.Errorf("expected successful span to have no status, got %v", s)
+	}
+	if s := spans[1].Status(); s.Code != codes.Error || s.Description != "boom" {
+		t.Errorf("expected failed span to have an error status, got %v", s)
+	}
+}
@@ -4,7 +4,9 @@
 package template
 
 import (
+	"reflect"
 	"sync"
+	"time"
 
 	dep "github.com/hashicorp/consul-template/dependency"
 )
@@ -21,6 +23,27 @@ type Brain struct {
 	// receivedData is an internal tracker of which dependencies have stored data
 	// in the brain.
 	receivedData map[string]struct{}
+
+	// rendered is the data each destination last completed a render with, so
+	// that functions reporting changes, like servicesDelta, can report those
+	// since that render.
+	rendered map[string]map[string]interface{}
+
+	// generations is the render generation of each destination. It is only
+	// advanced when the rendered output of the destination changes.
+	generations map[string]uint64
+
+	// versions counts the updates to each dependency, so that callers can tell
+	// which dependencies changed since they last looked. It is kept when a
+	// dependency is forgotten, so a version is never reused.
+	versions map[string]uint64
+
+	// updated is the time data was last stored for each dependency, or its
+	// last fetch that confirmed the data unchanged.
+	updated map[string]time.Time
+
+	// used is the time each dependency was last used by a render.
+	used map[string]time.Time
 }
 
 // NewBrain creates a new Brain with empty values for each
@@ -29,6 +52,11 @@ func NewBrain() *Brain {
 	return &Brain{
 		data:         make(map[string]interface{}),
 		receivedData: make(map[string]struct{}),
+		rendered:     make(map[string]map[string]interface{}),
+		generations:  make(map[string]uint64),
+		versions:     make(map[string]uint64),
+		updated:      make(map[string]time.Time),
+		used:         make(map[string]time.Time),
 	}
 }
 
@@ -41,6 +69,8 @@ func (b *Brain) Remember(d dep.Dependency, data interface{}) {
 
 	b.data[d.String()] = data
 	b.receivedData[d.String()] = struct{}{}
+	b.versions[d.String()]++
+	b.updated[d.String()] = time.Now()
 }
 
 // Recall gets the current value for the given dependency in the Brain.
@@ -56,14 +86,96 @@ func (b *Brain) Recall(d dep.Dependency) (interface{}, bool) {
 	return b.data[d.String()], true
 }
 
+// RecallRendered gets the value the given dependency held when the given
+// destination last completed a render. It returns false if the destination
+// has not completed a render with the dependency yet.
+func (b *Brain) RecallRendered(destination string, d dep.Dependency) (interface{}, bool) {
+	b.RLock()
+	defer b.RUnlock()
+
+	data, ok := b.rendered[destination][d.String()]
+	return data, ok
+}
+
+// SetRendered records the current data of the given dependencies as the data
+// the given destination last completed a render with.
+func (b *Brain) SetRendered(destination string, deps []dep.Dependency) {
+	b.Lock()
+	defer b.Unlock()
+
+	rendered := make(map[string]interface{}, len(deps))
+	for _, d := range deps {
+		if data, ok := b.data[d.String()]; ok {
+			rendered[d.String()] = data
+		}
+	}
+	b.rendered[destination] = rendered
+}
+
 // ForceSet is used to force set the value of a dependency
 // for a given hash code
 func (b *Brain) ForceSet(hashCode string, data interface{}) {
 	b.Lock()
 	defer b.Unlock()
 
+	// The de-duplication manager sets every dependency of a template at once,
+	// so only those whose data changed get a new version.
+	if old, ok := b.data[hashCode]; !ok || !reflect.DeepEqual(old, data) {
+		b.versions[hashCode]++
+	}
 	b.data[hashCode] = data
 	b.receivedData[hashCode] = struct{}{}
+	b.updated[hashCode] = time.Now()
+}
+
+// Version returns the number of times data was stored for the given
+// dependency. It changes with every update, so comparing it to an earlier
+// version tells if the dependency changed in between.
+func (b *Brain) Version(d dep.Dependency) uint64 {
+	b.RLock()
+	defer b.RUnlock()
+
+	return b.versions[d.String()]
+}
+
+// MarkUsed records that the given dependency was used by a render.
+func (b *Brain) MarkUsed(d dep.Dependency) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.used[d.String()] = time.Now()
+}
+
+// LastUsed returns the time the given dependency was last used by a render. It
+// returns false if it was not used since it was last forgotten.
+func (b *Brain) LastUsed(d dep.Dependency) (time.Time, bool) {
+	b.RLock()
+	defer b.RUnlock()
+
+	t, ok := b.used[d.String()]
+	return t, ok
+}
+
+// Touch records that the data of the given dependency was confirmed current at
+// the given time by a fetch that did not change it. It has no effect without
+// data or if the data was stored later.
+func (b *Brain) Touch(d dep.Dependency, t time.Time) {
+	b.Lock()
+	defer b.Unlock()
+
+	if updated, ok := b.updated[d.String()]; ok && t.After(updated) {
+		b.updated[d.String()] = t
+	}
+}
+
+// UpdatedAt returns the time data was last stored for the given dependency, or
+// touched since. It returns false if there is no data for the dependency.
+func (b *Brain) UpdatedAt(d dep.Dependency) (time.Time, bool) {
+	b.RLock()
+	defer b.RUnlock()
+
+	t, ok := b.updated[d.String()]
+	return t, ok
 }
 
 // Forget accepts a dependency and removes all associated data with this
@@ -74,4 +186,26 @@ func (b *Brain) Forget(d dep.Dependency) {
 
 	delete(b.data, d.String())
 	delete(b.receivedData, d.String())
+	for _, rendered := range b.rendered {
+		delete(rendered, d.String())
+	}
+	delete(b.updated, d.String())
+	delete(b.used, d.String())
+}
+
+// Generation returns the render generation of the given destination, or zero
+// if it has not been set.
+func (b *Brain) Generation(destination string) uint64 {
+	b.RLock()
+	defer b.RUnlock()
+
+	return b.generations[destination]
+}
+
+// SetGeneration sets the render generation of the given destination.
+func (b *Brain) SetGeneration(destination string, generation uint64) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.generations[destination] = generation
 }
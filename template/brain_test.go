@@ -6,6 +6,7 @@ package template
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	dep "github.com/hashicorp/consul-template/dependency"
 )
@@ -50,6 +51,43 @@ func TestRecall(t *testing.T) {
 	}
 }
 
+func TestRecallRendered(t *testing.T) {
+	b := NewBrain()
+
+	d, err := dep.NewCatalogServicesQuery("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := []*dep.CatalogSnippet{{Name: "service1"}}
+	second := []*dep.CatalogSnippet{{Name: "service2"}}
+
+	b.Remember(d, first)
+	if _, ok := b.RecallRendered("/tmp/out", d); ok {
+		t.Fatal("expected no rendered data before a render")
+	}
+
+	// Updates after the render do not change the data it rendered with.
+	b.SetRendered("/tmp/out", []dep.Dependency{d})
+	b.Remember(d, second)
+	b.Remember(d, second)
+	data, ok := b.RecallRendered("/tmp/out", d)
+	if !ok {
+		t.Fatal("expected rendered data from brain")
+	}
+	if result := data.([]*dep.CatalogSnippet); !reflect.DeepEqual(result, first) {
+		t.Errorf("expected %#v to be %#v", result, first)
+	}
+	if _, ok := b.RecallRendered("/tmp/other", d); ok {
+		t.Fatal("expected other destination to be unaffected")
+	}
+
+	b.Forget(d)
+	if _, ok := b.RecallRendered("/tmp/out", d); ok {
+		t.Fatal("expected rendered data to be forgotten")
+	}
+}
+
 func TestForceSet(t *testing.T) {
 	b := NewBrain()
 
@@ -100,3 +138,122 @@ func TestForget(t *testing.T) {
 		t.Errorf("expected %#v to not be forgotten", d)
 	}
 }
+
+func TestGeneration(t *testing.T) {
+	b := NewBrain()
+
+	if g := b.Generation("/tmp/out"); g != 0 {
+		t.Errorf("expected unset generation to be 0, got %d", g)
+	}
+
+	b.SetGeneration("/tmp/out", 3)
+	if g := b.Generation("/tmp/out"); g != 3 {
+		t.Errorf("expected generation to be 3, got %d", g)
+	}
+	if g := b.Generation("/tmp/other"); g != 0 {
+		t.Errorf("expected other destination to be unaffected, got %d", g)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	b := NewBrain()
+
+	d, err := dep.NewKVGetQuery("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := b.Version(d); v != 0 {
+		t.Errorf("expected unknown dependency version to be 0, got %d", v)
+	}
+
+	b.Remember(d, "bar")
+	b.Remember(d, "baz")
+	if v := b.Version(d); v != 2 {
+		t.Errorf("expected version to be 2, got %d", v)
+	}
+
+	// A forgotten dependency keeps counting, so its versions are not reused.
+	b.Forget(d)
+	b.Remember(d, "bar")
+	if v := b.Version(d); v != 3 {
+		t.Errorf("expected version to be 3, got %d", v)
+	}
+
+	// Data set by the de-duplication manager only counts when it changed.
+	b.ForceSet(d.String(), "bar")
+	if v := b.Version(d); v != 3 {
+		t.Errorf("expected version to stay 3, got %d", v)
+	}
+	b.ForceSet(d.String(), "qux")
+	if v := b.Version(d); v != 4 {
+		t.Errorf("expected version to be 4, got %d", v)
+	}
+}
+
+func TestUpdatedAt(t *testing.T) {
+	b := NewBrain()
+
+	d, err := dep.NewKVGetQuery("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.Touch(d, time.Now())
+	if _, ok := b.UpdatedAt(d); ok {
+		t.Errorf("expected unknown dependency to have no update time")
+	}
+
+	b.Remember(d, "bar")
+	first, ok := b.UpdatedAt(d)
+	if !ok {
+		t.Fatalf("expected %s to have an update time", d)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	b.ForceSet(d.String(), "baz")
+	second, ok := b.UpdatedAt(d)
+	if !ok || !second.After(first) {
+		t.Errorf("expected update time to advance from %s, got %s", first, second)
+	}
+
+	// A fetch that confirms the data unchanged advances the update time, but
+	// one from before the data was stored does not.
+	b.Touch(d, first)
+	if touched, _ := b.UpdatedAt(d); !touched.Equal(second) {
+		t.Errorf("expected update time to stay %s, got %s", second, touched)
+	}
+	third := second.Add(time.Second)
+	b.Touch(d, third)
+	if touched, _ := b.UpdatedAt(d); !touched.Equal(third) {
+		t.Errorf("expected update time to be %s, got %s", third, touched)
+	}
+
+	b.Forget(d)
+	if _, ok := b.UpdatedAt(d); ok {
+		t.Errorf("expected forgotten dependency to have no update time")
+	}
+}
+
+func TestLastUsed(t *testing.T) {
+	b := NewBrain()
+
+	d, err := dep.NewKVGetQuery("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := b.LastUsed(d); ok {
+		t.Errorf("expected unused dependency to have no last use")
+	}
+
+	b.MarkUsed(d)
+	if _, ok := b.LastUsed(d); !ok {
+		t.Errorf("expected %s to have a last use", d)
+	}
+
+	b.Forget(d)
+	if _, ok := b.LastUsed(d); ok {
+		t.Errorf("expected forgotten dependency to have no last use")
+	}
+}
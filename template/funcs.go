@@ -5,14 +5,22 @@ package template
 
 import (
 	"bytes"
+	"compress/gzip"
 	"crypto/hmac"
 	"crypto/md5"
 	"crypto/sha256"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"math"
+	"math/big"
+	"net"
+	"net/url"
 	"os"
 	"os/exec"
 	"os/user"
@@ -27,6 +35,7 @@ import (
 
 	"github.com/BurntSushi/toml"
 	spewLib "github.com/davecgh/go-spew/spew"
+	"github.com/google/uuid"
 	dep "github.com/hashicorp/consul-template/dependency"
 	"github.com/hashicorp/consul/api"
 	socktmpl "github.com/hashicorp/go-sockaddr/template"
@@ -41,20 +50,33 @@ import (
 // primarily for the tests to override times.
 var now = func() time.Time { return time.Now().UTC() }
 
-// datacentersFunc returns or accumulates datacenter dependencies.
-func datacentersFunc(b *Brain, used, missing *dep.Set) func(ignore ...bool) ([]string, error) {
-	return func(i ...bool) ([]string, error) {
+// datacentersFunc returns or accumulates datacenter dependencies. The first
+// optional argument toggles ignoring inaccessible datacenters, any further
+// arguments are datacenter names (or lists of names) to exclude from the
+// result.
+func datacentersFunc(b *Brain, used, missing *dep.Set) func(i ...interface{}) ([]string, error) {
+	return func(i ...interface{}) ([]string, error) {
 		result := []string{}
 
-		var ignore bool
-		switch len(i) {
-		case 0:
-			ignore = false
-		case 1:
-			ignore = i[0]
-		default:
-			return result, fmt.Errorf("datacenters: wrong number of arguments, expected 0 or 1"+
-				", but got %d", len(i))
+		if len(i) == 0 {
+			i = []interface{}{false}
+		}
+
+		ignore, ok := i[0].(bool)
+		if !ok {
+			return result, fmt.Errorf("datacenters: first argument must be a bool, "+
+				"but got %T", i[0])
+		}
+
+		exclude := make(map[string]struct{})
+		for _, arg := range i[1:] {
+			names, err := datacenterNames(arg)
+			if err != nil {
+				return result, fmt.Errorf("datacenters: %w", err)
+			}
+			for _, n := range names {
+				exclude[n] = struct{}{}
+			}
 		}
 
 		d, err := dep.NewCatalogDatacentersQuery(ignore)
@@ -65,7 +87,16 @@ func datacentersFunc(b *Brain, used, missing *dep.Set) func(ignore ...bool) ([]s
 		used.Add(d)
 
 		if value, ok := b.Recall(d); ok {
-			return value.([]string), nil
+			dcs := value.([]string)
+			if len(exclude) == 0 {
+				return dcs, nil
+			}
+			for _, dc := range dcs {
+				if _, ok := exclude[dc]; !ok {
+					result = append(result, dc)
+				}
+			}
+			return result, nil
 		}
 
 		missing.Add(d)
@@ -74,6 +105,30 @@ func datacentersFunc(b *Brain, used, missing *dep.Set) func(ignore ...bool) ([]s
 	}
 }
 
+// datacenterNames converts a datacenter name or list of names argument into a
+// list of datacenter names.
+func datacenterNames(arg interface{}) ([]string, error) {
+	switch v := arg.(type) {
+	case string:
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		names := make([]string, 0, len(v))
+		for _, n := range v {
+			s, ok := n.(string)
+			if !ok {
+				return nil, fmt.Errorf("datacenter must be a string, but got %T", n)
+			}
+			names = append(names, s)
+		}
+		return names, nil
+	default:
+		return nil, fmt.Errorf("datacenters must be a string or a list, "+
+			"but got %T", arg)
+	}
+}
+
 // envFunc returns a function which checks the value of an environment variable.
 // Invokers can specify their own environment, which takes precedences over any
 // real environment variables
@@ -190,6 +245,95 @@ func fileFunc(b *Brain, used, missing *dep.Set, sandboxPath string) func(string)
 	}
 }
 
+// fileBase64Func returns or accumulates file dependencies like fileFunc, but
+// returns the base64 encoding of the file's raw bytes, so binary files like
+// keytabs can be embedded in text. Unlike fileFunc, a missing file is an
+// error right away.
+func fileBase64Func(b *Brain, used, missing *dep.Set, sandboxPath string) func(string) (string, error) {
+	return func(s string) (string, error) {
+		if len(s) == 0 {
+			return "", nil
+		}
+		err := pathInSandbox(sandboxPath, s)
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(s); err != nil {
+			return "", fmt.Errorf("fileBase64: %w", err)
+		}
+		d, err := dep.NewFileQuery(s)
+		if err != nil {
+			return "", err
+		}
+
+		used.Add(d)
+
+		if value, ok := b.Recall(d); ok {
+			if value == nil {
+				return "", nil
+			}
+			return base64.StdEncoding.EncodeToString([]byte(value.(string))), nil
+		}
+
+		missing.Add(d)
+
+		return "", nil
+	}
+}
+
+// httpGetFunc returns or accumulates remote HTTP endpoint dependencies.
+func httpGetFunc(b *Brain, used, missing *dep.Set) func(string) (string, error) {
+	return func(s string) (string, error) {
+		if len(s) == 0 {
+			return "", nil
+		}
+
+		d, err := dep.NewHTTPGetQuery(s)
+		if err != nil {
+			return "", err
+		}
+
+		used.Add(d)
+
+		if value, ok := b.Recall(d); ok {
+			return value.(string), nil
+		}
+
+		missing.Add(d)
+
+		return "", nil
+	}
+}
+
+// httpGetJSONFunc returns the body of the given HTTP endpoint parsed as JSON.
+// It is an error if the body is not valid JSON.
+func httpGetJSONFunc(b *Brain, used, missing *dep.Set) func(string) (interface{}, error) {
+	return func(s string) (interface{}, error) {
+		if len(s) == 0 {
+			return map[string]interface{}{}, nil
+		}
+
+		d, err := dep.NewHTTPGetQuery(s)
+		if err != nil {
+			return nil, err
+		}
+
+		used.Add(d)
+
+		if value, ok := b.Recall(d); ok {
+			data, err := parseJSON(value.(string))
+			if err != nil {
+				return nil, errors.Wrapf(err, "httpGetJSON: invalid JSON from %s", d)
+			}
+			return data, nil
+		}
+
+		missing.Add(d)
+
+		return map[string]interface{}{}, nil
+	}
+}
+
 // keyFunc returns or accumulates key dependencies.
 func keyFunc(b *Brain, used, missing *dep.Set) func(string) (string, error) {
 	return func(s string) (string, error) {
@@ -218,6 +362,44 @@ func keyFunc(b *Brain, used, missing *dep.Set) func(string) (string, error) {
 	}
 }
 
+// keyGunzipFunc returns the gzip decompressed value of the given key. Like
+// key, it blocks until the key exists. It is an error if the value is not gzip
+// compressed.
+func keyGunzipFunc(b *Brain, used, missing *dep.Set) func(string) (string, error) {
+	return func(s string) (string, error) {
+		if len(s) == 0 {
+			return "", nil
+		}
+
+		d, err := dep.NewKVGetQuery(s)
+		if err != nil {
+			return "", err
+		}
+		d.EnableBlocking()
+
+		used.Add(d)
+
+		if value, ok := b.Recall(d); ok {
+			if value == nil {
+				return "", nil
+			}
+			zr, err := gzip.NewReader(strings.NewReader(value.(string)))
+			if err != nil {
+				return "", errors.Wrapf(err, "keyGunzip: value of key %q is not gzip compressed", s)
+			}
+			uncompressed, err := io.ReadAll(zr)
+			if err != nil {
+				return "", errors.Wrapf(err, "keyGunzip: failed to decompress key %q", s)
+			}
+			return string(uncompressed), nil
+		}
+
+		missing.Add(d)
+
+		return "", nil
+	}
+}
+
 // keyExistsFunc returns true if a key exists, false otherwise.
 func keyExistsFunc(b *Brain, used, missing *dep.Set) func(string) (bool, error) {
 	return func(s string) (bool, error) {
@@ -270,6 +452,141 @@ func keyWithDefaultFunc(b *Brain, used, missing *dep.Set) func(string, string) (
 	}
 }
 
+// keyJSONFunc returns the value of the given key parsed as JSON. It is an
+// error if the key does not exist or its value is not valid JSON.
+func keyJSONFunc(b *Brain, used, missing *dep.Set) func(string) (interface{}, error) {
+	return func(s string) (interface{}, error) {
+		if len(s) == 0 {
+			return map[string]interface{}{}, nil
+		}
+
+		d, err := dep.NewKVGetQuery(s)
+		if err != nil {
+			return nil, err
+		}
+
+		used.Add(d)
+
+		if value, ok := b.Recall(d); ok {
+			if value == nil {
+				return nil, fmt.Errorf("keyJSON: key %q does not exist", s)
+			}
+			return parseKeyJSON("keyJSON", s, value.(string))
+		}
+
+		missing.Add(d)
+
+		return map[string]interface{}{}, nil
+	}
+}
+
+// keyJSONWithDefaultFunc returns the value of the given key parsed as JSON, or
+// the default if the key does not exist or is empty. It is an error if the
+// value is not valid JSON.
+func keyJSONWithDefaultFunc(b *Brain, used, missing *dep.Set) func(string, interface{}) (interface{}, error) {
+	return func(s string, def interface{}) (interface{}, error) {
+		if len(s) == 0 {
+			return def, nil
+		}
+
+		d, err := dep.NewKVGetQuery(s)
+		if err != nil {
+			return nil, err
+		}
+
+		used.Add(d)
+
+		if value, ok := b.Recall(d); ok {
+			if value == nil || value.(string) == "" {
+				return def, nil
+			}
+			return parseKeyJSON("keyJSONOrDefault", s, value.(string))
+		}
+
+		missing.Add(d)
+
+		return def, nil
+	}
+}
+
+// parseKeyJSON parses the value of a key as JSON, naming the function and key
+// in the error.
+func parseKeyJSON(name, key, value string) (interface{}, error) {
+	data, err := parseJSON(value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s: invalid JSON in key %q", name, key)
+	}
+	return data, nil
+}
+
+// kvTxnFunc returns the values of the given keys, keyed by path, read
+// atomically in a single Consul transaction. The keys may be given as separate
+// arguments or as lists, such as the result of split. Keys that do not exist
+// are absent from the map.
+func kvTxnFunc(b *Brain, used, missing *dep.Set) func(...interface{}) (map[string]string, error) {
+	return func(args ...interface{}) (map[string]string, error) {
+		var keys []string
+		for _, arg := range args {
+			switch v := arg.(type) {
+			case string:
+				keys = append(keys, v)
+			case []string:
+				keys = append(keys, v...)
+			case []interface{}:
+				for _, k := range v {
+					s, ok := k.(string)
+					if !ok {
+						return nil, fmt.Errorf("kvTxn: expected a string key, got %T", k)
+					}
+					keys = append(keys, s)
+				}
+			default:
+				return nil, fmt.Errorf("kvTxn: expected keys, got %T", arg)
+			}
+		}
+
+		d, err := dep.NewKVTxnQuery(keys)
+		if err != nil {
+			return nil, err
+		}
+
+		used.Add(d)
+
+		if value, ok := b.Recall(d); ok {
+			return value.(map[string]string), nil
+		}
+
+		missing.Add(d)
+
+		return map[string]string{}, nil
+	}
+}
+
+// lockHolderFunc returns the name of the node whose session holds the lock on
+// the given key, or an empty string if the key is not locked.
+func lockHolderFunc(b *Brain, used, missing *dep.Set) func(string) (string, error) {
+	return func(s string) (string, error) {
+		if len(s) == 0 {
+			return "", nil
+		}
+
+		d, err := dep.NewKVLockHolderQuery(s)
+		if err != nil {
+			return "", err
+		}
+
+		used.Add(d)
+
+		if value, ok := b.Recall(d); ok {
+			return value.(string), nil
+		}
+
+		missing.Add(d)
+
+		return "", nil
+	}
+}
+
 func safeLsFunc(b *Brain, used, missing *dep.Set) func(string) ([]*dep.KeyPair, error) {
 	// call lsFunc but explicitly mark that empty data set returned on monitored KV prefix is NOT safe
 	return lsFunc(b, used, missing, false)
@@ -363,6 +680,117 @@ func nodesFunc(b *Brain, used, missing *dep.Set) func(...string) ([]*dep.Node, e
 	}
 }
 
+// nodeSegmentFunc returns or accumulates the network segment of the local
+// agent.
+func nodeSegmentFunc(b *Brain, used, missing *dep.Set) func() (string, error) {
+	return func() (string, error) {
+		d, err := dep.NewAgentSelfQuery()
+		if err != nil {
+			return "", err
+		}
+
+		used.Add(d)
+
+		if value, ok := b.Recall(d); ok {
+			return value.(*dep.AgentSelf).Segment, nil
+		}
+
+		missing.Add(d)
+
+		return "", nil
+	}
+}
+
+// nodeCoordinateFunc returns or accumulates the LAN network coordinate of the
+// local agent.
+func nodeCoordinateFunc(b *Brain, used, missing *dep.Set) func() (*dep.NodeCoordinate, error) {
+	return func() (*dep.NodeCoordinate, error) {
+		result := &dep.NodeCoordinate{}
+
+		d, err := dep.NewAgentSelfQuery()
+		if err != nil {
+			return nil, err
+		}
+
+		used.Add(d)
+
+		if value, ok := b.Recall(d); ok {
+			return value.(*dep.AgentSelf).Coordinate, nil
+		}
+
+		missing.Add(d)
+
+		return result, nil
+	}
+}
+
+// agentMetricsFunc returns or accumulates the metrics of the local agent.
+func agentMetricsFunc(b *Brain, used, missing *dep.Set) func() (*dep.AgentMetrics, error) {
+	return func() (*dep.AgentMetrics, error) {
+		result := &dep.AgentMetrics{}
+
+		d, err := dep.NewAgentMetricsQuery()
+		if err != nil {
+			return nil, err
+		}
+
+		used.Add(d)
+
+		if value, ok := b.Recall(d); ok {
+			return value.(*dep.AgentMetrics), nil
+		}
+
+		missing.Add(d)
+
+		return result, nil
+	}
+}
+
+// checksFunc returns or accumulates health checks in a given state.
+func checksFunc(b *Brain, used, missing *dep.Set) func(...string) ([]*dep.HealthCheck, error) {
+	return func(s ...string) ([]*dep.HealthCheck, error) {
+		result := []*dep.HealthCheck{}
+
+		d, err := dep.NewHealthStateQuery(strings.Join(s, ""))
+		if err != nil {
+			return nil, err
+		}
+
+		used.Add(d)
+
+		if value, ok := b.Recall(d); ok {
+			return value.([]*dep.HealthCheck), nil
+		}
+
+		missing.Add(d)
+
+		return result, nil
+	}
+}
+
+// intentionsFunc returns or accumulates the intentions of a destination
+// service.
+func intentionsFunc(b *Brain, used, missing *dep.Set) func(...string) ([]*dep.Intention, error) {
+	return func(s ...string) ([]*dep.Intention, error) {
+		result := []*dep.Intention{}
+
+		d, err := dep.NewConnectIntentionsQuery(strings.Join(s, ""))
+		if err != nil {
+			return nil, err
+		}
+
+		used.Add(d)
+
+		if value, ok := b.Recall(d); ok {
+			return value.([]*dep.Intention), nil
+		}
+
+		missing.Add(d)
+
+		return result, nil
+	}
+}
+
 // peeringsFunc returns or accumulates peerings.
 func peeringsFunc(b *Brain, used, missing *dep.Set) func(...string) ([]*dep.Peering, error) {
 	return func(s ...string) ([]*dep.Peering, error) {
@@ -423,25 +851,43 @@ func pkiCertFunc(b *Brain, used, missing *dep.Set, destPath string) func(...stri
 }
 
 // secretFunc returns or accumulates secret dependencies from Vault.
-func secretFunc(b *Brain, used, missing *dep.Set) func(...string) (interface{}, error) {
-	return func(s ...string) (interface{}, error) {
+func secretFunc(b *Brain, used, missing *dep.Set) func(...interface{}) (interface{}, error) {
+	return func(s ...interface{}) (interface{}, error) {
 		if len(s) == 0 {
 			return nil, nil
 		}
 
-		path, rest := s[0], s[1:]
+		path, ok := s[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("secret: path must be a string, got %T", s[0])
+		}
+		rest := s[1:]
 		data := make(map[string]interface{})
-		for _, str := range rest {
-			if len(str) == 0 {
-				continue
-			}
-			parts := strings.SplitN(str, "=", 2)
-			if len(parts) != 2 {
-				return nil, fmt.Errorf("not k=v pair %q", str)
-			}
+		var headers map[string]string
+		for _, arg := range rest {
+			switch arg := arg.(type) {
+			case string:
+				if len(arg) == 0 {
+					continue
+				}
+				parts := strings.SplitN(arg, "=", 2)
+				if len(parts) != 2 {
+					return nil, fmt.Errorf("not k=v pair %q", arg)
+				}
 
-			k, v := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
-			data[k] = v
+				k, v := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+				data[k] = v
+			case map[string]interface{}:
+				// A map holds the request headers of a write.
+				if headers == nil {
+					headers = make(map[string]string, len(arg))
+				}
+				for k, v := range arg {
+					headers[k] = fmt.Sprint(v)
+				}
+			default:
+				return nil, fmt.Errorf("secret: unsupported argument type %T", arg)
+			}
 		}
 
 		var d dep.Dependency
@@ -451,7 +897,7 @@ func secretFunc(b *Brain, used, missing *dep.Set) func(...string) (interface{},
 		if isReadQuery {
 			d, err = dep.NewVaultReadQuery(path)
 		} else {
-			d, err = dep.NewVaultWriteQuery(path, data)
+			d, err = dep.NewVaultWriteQueryWithHeaders(path, data, headers)
 		}
 
 		if err != nil {
@@ -470,6 +916,43 @@ func secretFunc(b *Brain, used, missing *dep.Set) func(...string) (interface{},
 	}
 }
 
+// secretWrappedFunc writes the data to the given Vault path with the response
+// wrapped for the given TTL, and returns the wrapping information. The response
+// itself, and so the wrapping token, is never logged.
+func secretWrappedFunc(b *Brain, used, missing *dep.Set) func(string, map[string]interface{}, string) (*dep.SecretWrapInfo, error) {
+	return func(path string, data map[string]interface{}, ttl string) (*dep.SecretWrapInfo, error) {
+		if len(path) == 0 {
+			return nil, nil
+		}
+		if strings.TrimSpace(ttl) == "" {
+			return nil, fmt.Errorf("secretWrapped: missing wrap TTL for %q", path)
+		}
+		if data == nil {
+			data = make(map[string]interface{})
+		}
+
+		d, err := dep.NewVaultWriteQueryWithHeaders(path, data,
+			map[string]string{"X-Vault-Wrap-TTL": ttl})
+		if err != nil {
+			return nil, err
+		}
+
+		used.Add(d)
+
+		if value, ok := b.Recall(d); ok {
+			secret := value.(*dep.Secret)
+			if secret == nil || secret.WrapInfo == nil {
+				return nil, fmt.Errorf("secretWrapped: response from %q was not wrapped", path)
+			}
+			return secret.WrapInfo, nil
+		}
+
+		missing.Add(d)
+
+		return nil, nil
+	}
+}
+
 // secretsFunc returns or accumulates a list of secret dependencies from Vault.
 func secretsFunc(b *Brain, used, missing *dep.Set) func(string) ([]string, error) {
 	return func(s string) ([]string, error) {
@@ -508,68 +991,497 @@ func byMeta(meta string, services []*dep.HealthService) (groups map[string][]*de
 		if val := m[realKey]; val != "" {
 			return val
 		}
-		if strings.HasSuffix(key, "|int") {
-			return "0"
+		if strings.HasSuffix(key, "|int") {
+			return "0"
+		}
+		return fmt.Sprintf("_no_%s_", realKey)
+	}
+
+	metas := strings.Split(meta, ",")
+
+	groups = make(map[string][]*dep.HealthService)
+
+	for _, s := range services {
+		sm := s.ServiceMeta
+		keyParts := []string{}
+		for _, meta := range metas {
+			value := getOrDefault(sm, meta)
+			if strings.HasSuffix(meta, "|int") {
+				value = getOrDefault(sm, meta)
+				i, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, errors.Wrap(err, fmt.Sprintf("cannot parse %v as number ", value))
+				}
+				value = fmt.Sprintf("%05d", i)
+			}
+			keyParts = append(keyParts, normalize(value))
+		}
+		key := strings.Join(keyParts, "_")
+		groups[key] = append(groups[key], s)
+	}
+
+	return groups, nil
+}
+
+// sortByMeta returns a copy of the services sorted by the value of the given
+// ServiceMeta key, then by ID and node, so the order does not depend on the
+// order Consul returned them in. Services without the key sort last.
+func sortByMeta(meta string, services []*dep.HealthService) []*dep.HealthService {
+	sorted := make([]*dep.HealthService, len(services))
+	copy(sorted, services)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		av, aok := a.ServiceMeta[meta]
+		bv, bok := b.ServiceMeta[meta]
+		switch {
+		case aok != bok:
+			return aok
+		case av != bv:
+			return av < bv
+		case a.ID != b.ID:
+			return a.ID < b.ID
+		default:
+			return a.Node < b.Node
+		}
+	})
+	return sorted
+}
+
+// nodeHealthy returns the services whose node checks are all passing. Node
+// checks are the checks in Checks without a service ID, and a service without
+// any is healthy. The service's own checks are not looked at.
+func nodeHealthy(services []*dep.HealthService) []*dep.HealthService {
+	result := make([]*dep.HealthService, 0, len(services))
+	for _, s := range services {
+		healthy := true
+		for _, c := range s.Checks {
+			if c.ServiceID == "" && c.Status != api.HealthPassing {
+				healthy = false
+				break
+			}
+		}
+		if healthy {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// consistentShard returns the services assigned to the same shard as shardKey,
+// e.g. the name of the local node, out of shardCount shards. The key and each
+// service, identified by its node and ID, are mapped to a shard with jump
+// consistent hashing, so every host with the same inputs agrees on the
+// assignment, and removing a service or changing the shard count moves as few
+// services as possible.
+func consistentShard(services []*dep.HealthService, shardKey string, shardCount interface{}) ([]*dep.HealthService, error) {
+	f, err := toFloat("consistentShard", shardCount)
+	if err != nil {
+		return nil, err
+	}
+	n := int32(f)
+	if float64(n) != f || n < 1 {
+		return nil, fmt.Errorf("consistentShard: shard count must be a positive integer, got %v", shardCount)
+	}
+
+	shard := jumpHash(shardKey, n)
+	result := make([]*dep.HealthService, 0, len(services)/int(n)+1)
+	for _, s := range services {
+		if jumpHash(s.Node+"/"+s.ID, n) == shard {
+			result = append(result, s)
+		}
+	}
+	return result, nil
+}
+
+// jumpHash maps key to one of n buckets using the jump consistent hash of
+// Lamping and Veach, https://arxiv.org/abs/1406.2294.
+func jumpHash(key string, n int32) int32 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	k := h.Sum64()
+
+	var b, j int64 = -1, 0
+	for j < int64(n) {
+		b = j
+		k = k*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((k>>33)+1)))
+	}
+	return int32(b)
+}
+
+// hashMod returns the 64-bit FNV-1a hash of s modulo n, a stable bucket in
+// [0, n) for assigning names to a fixed number of colors or shards. Unlike
+// consistentShard, changing n moves most names to a different bucket.
+func hashMod(s string, n interface{}) (int, error) {
+	f, err := toFloat("hashMod", n)
+	if err != nil {
+		return 0, err
+	}
+	m := int64(f)
+	if float64(m) != f || m < 1 {
+		return 0, fmt.Errorf("hashMod: n must be a positive integer, got %v", n)
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return int(h.Sum64() % uint64(m)), nil
+}
+
+// weightedPickFunc returns a function that picks one of the services with a
+// chance proportional to its weight. The pick is seeded with the name of the
+// host and the destination of the template, so every call in a render, and in
+// later renders with the same services, returns the same service, while the
+// hosts of a fleet spread over the services by weight.
+func weightedPickFunc(destination string) func([]*dep.HealthService, string) (*dep.HealthService, error) {
+	hostname, _ := os.Hostname()
+	h := fnv.New64a()
+	h.Write([]byte(hostname + "\x00" + destination))
+	seed := h.Sum64()
+
+	return func(services []*dep.HealthService, key string) (*dep.HealthService, error) {
+		return weightedPick(services, key, seed)
+	}
+}
+
+// weightedPick picks one of the services using the number in the service meta
+// key as its weight, where a zero or absent weight counts as 1. Each service
+// is scored with weighted rendezvous hashing of the seed and its node and ID,
+// so the pick does not depend on the order of the services, and adding or
+// removing a service only moves the hosts that pick it. It returns nil when
+// there are no services.
+func weightedPick(services []*dep.HealthService, key string, seed uint64) (*dep.HealthService, error) {
+	var best *dep.HealthService
+	bestScore := math.Inf(1)
+	for _, s := range services {
+		weight := 1.0
+		if v := s.ServiceMeta[key]; v != "" {
+			w, err := strconv.ParseFloat(v, 64)
+			if err != nil || w < 0 || math.IsInf(w, 0) || math.IsNaN(w) {
+				return nil, fmt.Errorf("weightedPick: invalid weight %q for %s/%s", v, s.Node, s.ID)
+			}
+			if w > 0 {
+				weight = w
+			}
+		}
+
+		h := fnv.New64a()
+		h.Write([]byte(s.Node + "/" + s.ID))
+		// A uniform number in (0, 1) from the top 53 bits of the mixed hash.
+		u := (float64(mix64(h.Sum64()^seed)>>11) + 0.5) / (1 << 53)
+		score := -math.Log(u) / weight
+		if best == nil || score < bestScore {
+			best, bestScore = s, score
+		}
+	}
+	return best, nil
+}
+
+// mix64 is the finalizer of SplitMix64, which spreads every bit of x over
+// the result.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// serviceFunc returns or accumulates health service dependencies.
+func serviceFunc(b *Brain, used, missing *dep.Set) func(...string) ([]*dep.HealthService, error) {
+	return func(s ...string) ([]*dep.HealthService, error) {
+		result := []*dep.HealthService{}
+
+		if len(s) == 0 || s[0] == "" {
+			return result, nil
+		}
+
+		d, err := dep.NewHealthServiceQuery(strings.Join(s, "|"))
+		if err != nil {
+			return nil, err
+		}
+
+		used.Add(d)
+
+		if value, ok := b.Recall(d); ok {
+			return value.([]*dep.HealthService), nil
+		}
+
+		missing.Add(d)
+
+		return result, nil
+	}
+}
+
+// servicesByDCFunc returns or accumulates the health service dependencies of a
+// service in each of the given datacenters, grouped by datacenter. The service
+// is given like to service, without a datacenter, and the datacenters are names
+// or lists of names. The fetch errors of these dependencies are tolerated, so
+// a datacenter that cannot be reached is empty rather than failing the render.
+func servicesByDCFunc(b *Brain, used, missing, tolerated *dep.Set) func(string, ...interface{}) (map[string][]*dep.HealthService, error) {
+	return func(s string, i ...interface{}) (map[string][]*dep.HealthService, error) {
+		result := make(map[string][]*dep.HealthService)
+
+		name, filter, _ := strings.Cut(s, "|")
+		name, near, _ := strings.Cut(name, "~")
+		if strings.Contains(name, "@") {
+			return nil, fmt.Errorf("servicesByDC: service %q must not have a datacenter", s)
+		}
+
+		for _, arg := range i {
+			dcs, err := datacenterNames(arg)
+			if err != nil {
+				return nil, fmt.Errorf("servicesByDC: %w", err)
+			}
+			for _, dc := range dcs {
+				query := name + "@" + dc
+				if near != "" {
+					query += "~" + near
+				}
+				if filter != "" {
+					query += "|" + filter
+				}
+				d, err := dep.NewHealthServiceQuery(query)
+				if err != nil {
+					return nil, errors.Wrap(err, "servicesByDC")
+				}
+
+				used.Add(d)
+				tolerated.Add(d)
+
+				result[dc] = []*dep.HealthService{}
+				if value, ok := b.Recall(d); ok {
+					result[dc] = value.([]*dep.HealthService)
+					continue
+				}
+
+				missing.Add(d)
+			}
+		}
+
+		return result, nil
+	}
+}
+
+// srvRecordsFunc returns or accumulates health service dependencies and
+// converts the instances into SRV-style records. It takes the same arguments
+// as service, so only passing instances are returned unless a filter is given.
+func srvRecordsFunc(b *Brain, used, missing *dep.Set) func(...string) ([]*dep.SRVRecord, error) {
+	return func(s ...string) ([]*dep.SRVRecord, error) {
+		services, err := serviceFunc(b, used, missing)(s...)
+		if err != nil {
+			return nil, errors.Wrap(err, "srvRecords")
+		}
+
+		result := make([]*dep.SRVRecord, 0, len(services))
+		for _, svc := range services {
+			result = append(result, &dep.SRVRecord{
+				Target:   svc.Node,
+				Port:     svc.Port,
+				Priority: dep.DefaultSRVPriority,
+				Weight:   srvWeight(svc),
+			})
+		}
+		return result, nil
+	}
+}
+
+// srvWeight returns the weight of the service instance for its health status,
+// the same way Consul DNS picks it. Instances registered without weights get
+// Consul's default weight of 1.
+func srvWeight(svc *dep.HealthService) int {
+	w := svc.Weights
+	if w.Passing == 0 && w.Warning == 0 {
+		w.Passing, w.Warning = 1, 1
+	}
+	switch svc.Status {
+	case dep.HealthPassing:
+		return w.Passing
+	case dep.HealthWarning:
+		return w.Warning
+	default:
+		return 0
+	}
+}
+
+// prometheusTargetsFunc returns or accumulates health service dependencies and
+// converts the instances into Prometheus scrape static configs, one for each
+// instance. It takes the same arguments as service. Each instance is labeled
+// with its service metadata, and with its service name, node and tags, which
+// are joined like the __meta_consul_tags label of Consul service discovery.
+func prometheusTargetsFunc(b *Brain, used, missing *dep.Set) func(...string) ([]*dep.PrometheusStaticConfig, error) {
+	return func(s ...string) ([]*dep.PrometheusStaticConfig, error) {
+		services, err := serviceFunc(b, used, missing)(s...)
+		if err != nil {
+			return nil, errors.Wrap(err, "prometheusTargets")
+		}
+
+		result := make([]*dep.PrometheusStaticConfig, 0, len(services))
+		for _, svc := range services {
+			labels := make(map[string]string, len(svc.ServiceMeta)+3)
+			for k, v := range svc.ServiceMeta {
+				labels[prometheusLabelName(k)] = v
+			}
+			labels["service"] = svc.Name
+			labels["node"] = svc.Node
+			if len(svc.Tags) > 0 {
+				labels["tags"] = "," + strings.Join(svc.Tags, ",") + ","
+			}
+
+			result = append(result, &dep.PrometheusStaticConfig{
+				Targets: []string{net.JoinHostPort(svc.Address, strconv.Itoa(svc.Port))},
+				Labels:  labels,
+			})
+		}
+		return result, nil
+	}
+}
+
+// prometheusLabelName sanitizes the name into a valid Prometheus label name,
+// replacing each character other than ASCII letters, digits and underscores
+// with an underscore, and prefixing an underscore to names that start with a
+// digit. Names starting with "__" are reserved for Prometheus itself, so their
+// leading underscores are reduced to one.
+func prometheusLabelName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			b[i] = '_'
+		}
+	}
+	name = string(b)
+
+	if strings.HasPrefix(name, "__") {
+		name = "_" + strings.TrimLeft(name, "_")
+	}
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// renderGenerationFunc returns the render generation of the template's
+// destination. The generation only advances when the rendered output changes,
+// which lets consumers of the file tell real changes from no-op renders.
+func renderGenerationFunc(b *Brain, destination string, usesGeneration *bool) func() uint64 {
+	return func() uint64 {
+		*usesGeneration = true
+		return b.Generation(destination)
+	}
+}
+
+// emitFunc returns a function that records the contents of a file rendered by
+// a fan-out template. The name is a path relative to the template's
+// destination directory, and each name can only be emitted once. It returns
+// an empty string so it does not add to the template's output.
+func emitFunc(emitted map[string][]byte) func(string, string) (string, error) {
+	return func(name, contents string) (string, error) {
+		if emitted == nil {
+			return "", fmt.Errorf("emit: template is not in fan_out mode")
+		}
+		if !filepath.IsLocal(name) {
+			return "", fmt.Errorf("emit: %q must be a relative path within the destination", name)
+		}
+		name = filepath.Clean(name)
+		if _, ok := emitted[name]; ok {
+			return "", fmt.Errorf("emit: %q was already emitted", name)
+		}
+		emitted[name] = []byte(contents)
+		return "", nil
+	}
+}
+
+// dependencyAgeFunc returns how long ago data was last fetched for the
+// dependency with the given string, like "catalog.service(web)". The
+// dependency must be used earlier in the template; until its data arrives the
+// age is zero.
+func dependencyAgeFunc(b *Brain, used *dep.Set) func(string) (time.Duration, error) {
+	return func(s string) (time.Duration, error) {
+		d := used.Get(s)
+		if d == nil {
+			return 0, fmt.Errorf("dependencyAge: unknown dependency %q", s)
+		}
+
+		if updated, ok := b.UpdatedAt(d); ok {
+			return time.Since(updated), nil
 		}
-		return fmt.Sprintf("_no_%s_", realKey)
+		return 0, nil
 	}
+}
 
-	metas := strings.Split(meta, ",")
+// servicesFunc returns or accumulates catalog services dependencies.
+func servicesFunc(b *Brain, used, missing *dep.Set) func(...string) ([]*dep.CatalogSnippet, error) {
+	return func(s ...string) ([]*dep.CatalogSnippet, error) {
+		result := []*dep.CatalogSnippet{}
 
-	groups = make(map[string][]*dep.HealthService)
+		d, err := dep.NewCatalogServicesQuery(strings.Join(s, ""))
+		if err != nil {
+			return nil, err
+		}
 
-	for _, s := range services {
-		sm := s.ServiceMeta
-		keyParts := []string{}
-		for _, meta := range metas {
-			value := getOrDefault(sm, meta)
-			if strings.HasSuffix(meta, "|int") {
-				value = getOrDefault(sm, meta)
-				i, err := strconv.Atoi(value)
-				if err != nil {
-					return nil, errors.Wrap(err, fmt.Sprintf("cannot parse %v as number ", value))
-				}
-				value = fmt.Sprintf("%05d", i)
-			}
-			keyParts = append(keyParts, normalize(value))
+		used.Add(d)
+
+		if value, ok := b.Recall(d); ok {
+			return value.([]*dep.CatalogSnippet), nil
 		}
-		key := strings.Join(keyParts, "_")
-		groups[key] = append(groups[key], s)
-	}
 
-	return groups, nil
+		missing.Add(d)
+
+		return result, nil
+	}
 }
 
-// serviceFunc returns or accumulates health service dependencies.
-func serviceFunc(b *Brain, used, missing *dep.Set) func(...string) ([]*dep.HealthService, error) {
-	return func(s ...string) ([]*dep.HealthService, error) {
-		result := []*dep.HealthService{}
+// treeChangedFunc returns the non-empty top-level keys of the given prefix
+// whose ModifyIndex is higher than any key the prefix held when the
+// destination last completed a render. On the first render every key is
+// returned.
+func treeChangedFunc(b *Brain, used, missing *dep.Set, destination string) func(string) ([]*dep.KeyPair, error) {
+	return func(s string) ([]*dep.KeyPair, error) {
+		result := []*dep.KeyPair{}
 
-		if len(s) == 0 || s[0] == "" {
+		if len(s) == 0 {
 			return result, nil
 		}
 
-		d, err := dep.NewHealthServiceQuery(strings.Join(s, "|"))
+		d, err := dep.NewKVListQuery(s)
 		if err != nil {
-			return nil, err
+			return result, err
 		}
 
 		used.Add(d)
 
-		if value, ok := b.Recall(d); ok {
-			return value.([]*dep.HealthService), nil
+		value, ok := b.Recall(d)
+		if !ok {
+			missing.Add(d)
+			return result, nil
 		}
 
-		missing.Add(d)
+		var watermark uint64
+		if rendered, ok := b.RecallRendered(destination, d); ok {
+			for _, pair := range rendered.([]*dep.KeyPair) {
+				if pair.ModifyIndex > watermark {
+					watermark = pair.ModifyIndex
+				}
+			}
+		}
+		for _, pair := range value.([]*dep.KeyPair) {
+			parts := strings.Split(pair.Key, "/")
+			if parts[len(parts)-1] != "" && pair.ModifyIndex > watermark {
+				result = append(result, pair)
+			}
+		}
 
 		return result, nil
 	}
 }
 
-// servicesFunc returns or accumulates catalog services dependencies.
-func servicesFunc(b *Brain, used, missing *dep.Set) func(...string) ([]*dep.CatalogSnippet, error) {
-	return func(s ...string) ([]*dep.CatalogSnippet, error) {
-		result := []*dep.CatalogSnippet{}
+// servicesDeltaFunc returns the names of the catalog services added and
+// removed since the destination last completed a render. On the first render
+// every service is reported as added.
+func servicesDeltaFunc(b *Brain, used, missing *dep.Set, destination string) func(...string) (*dep.CatalogServicesDelta, error) {
+	return func(s ...string) (*dep.CatalogServicesDelta, error) {
+		result := &dep.CatalogServicesDelta{Added: []string{}, Removed: []string{}}
 
 		d, err := dep.NewCatalogServicesQuery(strings.Join(s, ""))
 		if err != nil {
@@ -578,11 +1490,36 @@ func servicesFunc(b *Brain, used, missing *dep.Set) func(...string) ([]*dep.Cata
 
 		used.Add(d)
 
-		if value, ok := b.Recall(d); ok {
-			return value.([]*dep.CatalogSnippet), nil
+		value, ok := b.Recall(d)
+		if !ok {
+			missing.Add(d)
+			return result, nil
 		}
 
-		missing.Add(d)
+		current := make(map[string]struct{})
+		for _, svc := range value.([]*dep.CatalogSnippet) {
+			current[svc.Name] = struct{}{}
+		}
+
+		previous := make(map[string]struct{})
+		if value, ok := b.RecallRendered(destination, d); ok {
+			for _, svc := range value.([]*dep.CatalogSnippet) {
+				previous[svc.Name] = struct{}{}
+			}
+		}
+
+		for name := range current {
+			if _, ok := previous[name]; !ok {
+				result.Added = append(result.Added, name)
+			}
+		}
+		for name := range previous {
+			if _, ok := current[name]; !ok {
+				result.Removed = append(result.Removed, name)
+			}
+		}
+		sort.Strings(result.Added)
+		sort.Strings(result.Removed)
 
 		return result, nil
 	}
@@ -695,6 +1632,89 @@ func treeFunc(b *Brain, used, missing *dep.Set, emptyIsSafe bool) func(string) (
 	}
 }
 
+// treeMapValueKey is the default key under which treeMap stores the value of a
+// key that also has keys nested beneath it.
+const treeMapValueKey = "_value"
+
+// treeMapFunc returns the keys under the prefix nested into a map, splitting
+// the keys on "/". When a key has both a value and nested keys, the value is
+// stored in the nested map under treeMapValueKey, or under the key given as the
+// optional second argument.
+func treeMapFunc(b *Brain, used, missing *dep.Set) func(string, ...string) (map[string]interface{}, error) {
+	return func(s string, args ...string) (map[string]interface{}, error) {
+		result := make(map[string]interface{})
+
+		if len(s) == 0 {
+			return result, nil
+		}
+
+		valueKey := treeMapValueKey
+		switch len(args) {
+		case 0:
+		case 1:
+			if args[0] == "" {
+				return nil, fmt.Errorf("treeMap: value key must not be empty")
+			}
+			valueKey = args[0]
+		default:
+			return nil, fmt.Errorf("treeMap: wrong number of arguments, expected "+
+				"1 or 2, but got %d", len(args)+1)
+		}
+
+		d, err := dep.NewKVListQuery(s)
+		if err != nil {
+			return nil, err
+		}
+
+		used.Add(d)
+
+		value, ok := b.Recall(d)
+		if !ok {
+			missing.Add(d)
+			return result, nil
+		}
+
+		for _, pair := range value.([]*dep.KeyPair) {
+			treeMapInsert(result, strings.Split(pair.Key, "/"), pair.Value, valueKey)
+		}
+		return result, nil
+	}
+}
+
+// treeMapInsert stores the value at the path given by parts in m, creating
+// nested maps as needed.
+func treeMapInsert(m map[string]interface{}, parts []string, v, valueKey string) {
+	for _, part := range parts[:len(parts)-1] {
+		if part == "" {
+			continue
+		}
+		switch cur := m[part].(type) {
+		case map[string]interface{}:
+			m = cur
+		case string:
+			// The key already has a value, keep it alongside the nested keys.
+			nest := map[string]interface{}{valueKey: cur}
+			m[part] = nest
+			m = nest
+		default:
+			nest := make(map[string]interface{})
+			m[part] = nest
+			m = nest
+		}
+	}
+
+	// Keys ending in "/" are folders and only create the nested map.
+	last := parts[len(parts)-1]
+	if last == "" {
+		return
+	}
+	if nest, ok := m[last].(map[string]interface{}); ok {
+		nest[valueKey] = v
+		return
+	}
+	m[last] = v
+}
+
 // base64Decode decodes the given string as a base64 string, returning an error
 // if it fails.
 func base64Decode(s string) (string, error) {
@@ -724,6 +1744,52 @@ func base64URLEncode(s string) (string, error) {
 	return base64.URLEncoding.EncodeToString([]byte(s)), nil
 }
 
+// queryEscape escapes the given string so it can be safely placed inside a URL
+// query.
+func queryEscape(s string) string {
+	return url.QueryEscape(s)
+}
+
+// queryUnescape reverses queryEscape. It is an error if the string has a
+// malformed percent-encoding.
+func queryUnescape(s string) (string, error) {
+	v, err := url.QueryUnescape(s)
+	if err != nil {
+		return "", errors.Wrap(err, "queryUnescape")
+	}
+	return v, nil
+}
+
+// buildQuery returns the URL-encoded query string for the given map, sorted by
+// key. A list value adds the key once for each of its items.
+func buildQuery(m interface{}) (string, error) {
+	values := url.Values{}
+	switch m := m.(type) {
+	case map[string]string:
+		for k, v := range m {
+			values.Add(k, v)
+		}
+	case map[string]interface{}:
+		for k, v := range m {
+			switch v := v.(type) {
+			case []string:
+				for _, item := range v {
+					values.Add(k, item)
+				}
+			case []interface{}:
+				for _, item := range v {
+					values.Add(k, fmt.Sprint(item))
+				}
+			default:
+				values.Add(k, fmt.Sprint(v))
+			}
+		}
+	default:
+		return "", fmt.Errorf("buildQuery: expected a map, got %T", m)
+	}
+	return values.Encode(), nil
+}
+
 // byKey accepts a slice of KV pairs and returns a map of the top-level
 // key to all its subkeys. For example:
 //
@@ -811,6 +1877,24 @@ func byTag(in interface{}) (map[string][]interface{}, error) {
 	return m, nil
 }
 
+// allTags returns the sorted list of every distinct tag on any of the given
+// services.
+func allTags(services []*dep.HealthService) []string {
+	set := make(map[string]struct{})
+	for _, s := range services {
+		for _, t := range s.Tags {
+			set[t] = struct{}{}
+		}
+	}
+
+	tags := make([]string, 0, len(set))
+	for t := range set {
+		tags = append(tags, t)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
 // contains is a function that have reverse arguments of "in" and is designed to
 // be used as a pipe instead of a function:
 //
@@ -852,6 +1936,181 @@ func mergeMapWithOverride(dstMap map[string]interface{}, srcMap map[string]inter
 	return mergeMap(dstMap, srcMap, mergo.WithOverride)
 }
 
+// filterKeys returns a new map of the entries of m whose keys match the
+// regular expression.
+func filterKeys(m map[string]interface{}, re string) (map[string]interface{}, error) {
+	compiled, err := regexp.Compile(re)
+	if err != nil {
+		return nil, fmt.Errorf("filterKeys: %w", err)
+	}
+
+	result := make(map[string]interface{})
+	for k, v := range m {
+		if compiled.MatchString(k) {
+			result[k] = v
+		}
+	}
+	return result, nil
+}
+
+// mapDiff returns the keys of newMap that were added or changed relative to
+// oldMap. Keys that were deleted are set to nil, so that the result rendered
+// with toJSON is a JSON merge patch (RFC 7396) from oldMap to newMap. Nested
+// maps are diffed recursively and left out when nothing in them changed.
+func mapDiff(oldMap, newMap map[string]interface{}) map[string]interface{} {
+	diff := make(map[string]interface{})
+	for k, nv := range newMap {
+		ov, ok := oldMap[k]
+		if !ok {
+			diff[k] = nv
+			continue
+		}
+
+		om, oIsMap := ov.(map[string]interface{})
+		nm, nIsMap := nv.(map[string]interface{})
+		if oIsMap && nIsMap {
+			if d := mapDiff(om, nm); len(d) > 0 {
+				diff[k] = d
+			}
+			continue
+		}
+
+		if !reflect.DeepEqual(ov, nv) {
+			diff[k] = nv
+		}
+	}
+
+	for k := range oldMap {
+		if _, ok := newMap[k]; !ok {
+			diff[k] = nil
+		}
+	}
+	return diff
+}
+
+// setUnion returns the sorted elements that are in either of the given lists.
+func setUnion(a, b interface{}) (interface{}, error) {
+	return setOp("setUnion", a, b, func(inA, inB bool) bool { return inA || inB })
+}
+
+// setIntersect returns the sorted elements that are in both of the given
+// lists.
+func setIntersect(a, b interface{}) (interface{}, error) {
+	return setOp("setIntersect", a, b, func(inA, inB bool) bool { return inA && inB })
+}
+
+// setDifference returns the sorted elements of a that are not in b.
+func setDifference(a, b interface{}) (interface{}, error) {
+	return setOp("setDifference", a, b, func(inA, inB bool) bool { return inA && !inB })
+}
+
+// setOp returns the sorted, deduplicated elements of a and b for which keep
+// returns true. Nil lists are empty sets. Elements must all be strings, all
+// integers or all floats, and the result is a []string, []int64 or []float64
+// to match, or an empty []string if both sets are empty.
+func setOp(name string, a, b interface{}, keep func(inA, inB bool) bool) (interface{}, error) {
+	as, aKind, err := toSet(name, a, reflect.Invalid)
+	if err != nil {
+		return nil, err
+	}
+	bs, kind, err := toSet(name, b, aKind)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []interface{}
+	for k := range as {
+		if _, inB := bs[k]; keep(true, inB) {
+			result = append(result, k)
+		}
+	}
+	for k := range bs {
+		if _, inA := as[k]; !inA && keep(false, true) {
+			result = append(result, k)
+		}
+	}
+
+	switch kind {
+	case reflect.Int64:
+		ints := make([]int64, 0, len(result))
+		for _, v := range result {
+			ints = append(ints, v.(int64))
+		}
+		sort.Slice(ints, func(i, j int) bool { return ints[i] < ints[j] })
+		return ints, nil
+	case reflect.Float64:
+		floats := make([]float64, 0, len(result))
+		for _, v := range result {
+			floats = append(floats, v.(float64))
+		}
+		sort.Float64s(floats)
+		return floats, nil
+	default:
+		strs := make([]string, 0, len(result))
+		for _, v := range result {
+			strs = append(strs, v.(string))
+		}
+		sort.Strings(strs)
+		return strs, nil
+	}
+}
+
+// toSet converts a list into a set keyed by its elements, normalized to a
+// string, int64 or float64. The kind of the elements is returned, and must
+// match kind unless kind is reflect.Invalid, which matches anything.
+func toSet(name string, list interface{}, kind reflect.Kind) (map[interface{}]struct{}, reflect.Kind, error) {
+	set := make(map[interface{}]struct{})
+	if list == nil {
+		return set, kind, nil
+	}
+
+	v := reflect.ValueOf(list)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+	default:
+		return nil, kind, fmt.Errorf("%s: unknown type for %q (%T)", name, v, list)
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		e := v.Index(i)
+		if e.Kind() == reflect.Interface {
+			e = e.Elem()
+		}
+
+		var key interface{}
+		var k reflect.Kind
+		switch e.Kind() {
+		case reflect.String:
+			key, k = e.String(), reflect.String
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			key, k = e.Int(), reflect.Int64
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			key, k = int64(e.Uint()), reflect.Int64
+		case reflect.Float32, reflect.Float64:
+			key, k = e.Float(), reflect.Float64
+		default:
+			return nil, kind, fmt.Errorf("%s: unknown type at index %d (%s)", name, i, e.Kind())
+		}
+
+		if kind == reflect.Invalid {
+			kind = k
+		} else if k != kind {
+			return nil, kind, fmt.Errorf("%s: mixed element types at index %d (%s and %s)", name, i, kind, k)
+		}
+		set[key] = struct{}{}
+	}
+	return set, kind, nil
+}
+
+// failIf aborts the template with the given message as an error when the
+// condition is truthy, using the same truthiness rules as `if`.
+func failIf(cond interface{}, msg string) (string, error) {
+	if truth, _ := template.IsTrue(cond); truth {
+		return "", errors.New(msg)
+	}
+	return "", nil
+}
+
 // explode is used to expand a list of keypairs into a deeply-nested hash.
 func explode(pairs []*dep.KeyPair) (map[string]interface{}, error) {
 	m := make(map[string]interface{})
@@ -994,6 +2253,19 @@ func indent(spaces int, s string) (string, error) {
 //			for _, i := range loop(5, 8) {
 //				print(i)
 //			}
+//
+// An optional third parameter is the step. A negative step counts down from the
+// first parameter to, but not including, the second parameter.
+//
+//	   // Prints 8000 8002 8004
+//			for _, i := range loop(8000, 8006, 2) {
+//				print(i)
+//			}
+//
+//	   // Prints 3 2 1
+//			for _, i := range loop(3, 0, -1) {
+//				print(i)
+//			}
 func loop(ifaces ...interface{}) (<-chan int64, error) {
 	to64 := func(i interface{}) (int64, error) {
 		v := reflect.ValueOf(i)
@@ -1010,15 +2282,17 @@ func loop(ifaces ...interface{}) (<-chan int64, error) {
 		return 0, fmt.Errorf("loop: bad argument type: %T", i)
 	}
 
-	var i1, i2 interface{}
+	var i1, i2, i3 interface{} = nil, nil, 1
 	switch len(ifaces) {
 	case 1:
 		i1, i2 = 0, ifaces[0]
 	case 2:
 		i1, i2 = ifaces[0], ifaces[1]
+	case 3:
+		i1, i2, i3 = ifaces[0], ifaces[1], ifaces[2]
 	default:
 		return nil, fmt.Errorf("loop: wrong number of arguments, expected "+
-			"1 or 2, but got %d", len(ifaces))
+			"1, 2 or 3, but got %d", len(ifaces))
 	}
 
 	start, err := to64(i1)
@@ -1029,12 +2303,25 @@ func loop(ifaces ...interface{}) (<-chan int64, error) {
 	if err != nil {
 		return nil, err
 	}
+	step, err := to64(i3)
+	if err != nil {
+		return nil, err
+	}
+	if step == 0 {
+		return nil, fmt.Errorf("loop: step must not be zero")
+	}
 
 	ch := make(chan int64)
 
 	go func() {
-		for i := start; i < stop; i++ {
-			ch <- i
+		if step > 0 {
+			for i := start; i < stop; i += step {
+				ch <- i
+			}
+		} else {
+			for i := start; i > stop; i += step {
+				ch <- i
+			}
 		}
 		close(ch)
 	}()
@@ -1057,27 +2344,98 @@ func trimPrefix(prefix string, s string) (string, error) {
 	return strings.TrimPrefix(s, prefix), nil
 }
 
-// trimSuffix is a version of strings.TrimSuffix that can be piped
-func trimSuffix(suffix string, s string) (string, error) {
-	return strings.TrimSuffix(s, suffix), nil
-}
+// trimSuffix is a version of strings.TrimSuffix that can be piped
+func trimSuffix(suffix string, s string) (string, error) {
+	return strings.TrimSuffix(s, suffix), nil
+}
+
+// TrimSpace is a version of strings.TrimSpace that can be piped
+func trimSpace(s string) (string, error) {
+	return strings.TrimSpace(s), nil
+}
+
+// parseBool parses a string into a boolean
+func parseBool(s string) (bool, error) {
+	if s == "" {
+		return false, nil
+	}
+
+	result, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, errors.Wrap(err, "parseBool")
+	}
+	return result, nil
+}
+
+// Certificate is the fields of an X.509 certificate returned by parseCert.
+type Certificate struct {
+	Subject     string
+	Issuer      string
+	NotBefore   time.Time
+	NotAfter    time.Time
+	DNSNames    []string
+	IPAddresses []string
+}
+
+// parseCert parses the first certificate in a PEM encoded string, like the
+// certificate issued by a Vault PKI secret engine. Blocks that are not
+// certificates, like a private key, are skipped.
+func parseCert(s string) (*Certificate, error) {
+	rest := []byte(s)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return nil, fmt.Errorf("parseCert: no PEM encoded certificate found")
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "parseCert")
+		}
+		ips := make([]string, 0, len(cert.IPAddresses))
+		for _, ip := range cert.IPAddresses {
+			ips = append(ips, ip.String())
+		}
+		return &Certificate{
+			Subject:     cert.Subject.String(),
+			Issuer:      cert.Issuer.String(),
+			NotBefore:   cert.NotBefore,
+			NotAfter:    cert.NotAfter,
+			DNSNames:    cert.DNSNames,
+			IPAddresses: ips,
+		}, nil
+	}
+}
+
+// parseDuration parses a string such as "30s" or "1h30m" into a duration
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
 
-// TrimSpace is a version of strings.TrimSpace that can be piped
-func trimSpace(s string) (string, error) {
-	return strings.TrimSpace(s), nil
+	result, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, errors.Wrap(err, "parseDuration")
+	}
+	return result, nil
 }
 
-// parseBool parses a string into a boolean
-func parseBool(s string) (bool, error) {
+// durationSeconds parses a string such as "5m" into a whole number of seconds,
+// truncating any fraction of a second
+func durationSeconds(s string) (int64, error) {
 	if s == "" {
-		return false, nil
+		return 0, nil
 	}
 
-	result, err := strconv.ParseBool(s)
+	result, err := time.ParseDuration(s)
 	if err != nil {
-		return false, errors.Wrap(err, "parseBool")
+		return 0, errors.Wrap(err, "durationSeconds")
 	}
-	return result, nil
+	return int64(result / time.Second), nil
 }
 
 // parseFloat parses a string into a floating-point number with 64-bit precision
@@ -1251,20 +2609,96 @@ func splitToMap(sep1, sep2, s string) (map[string]string, error) {
 	return m, nil
 }
 
+// byteUnits are the IEC units used by humanizeBytes.
+var byteUnits = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+// humanizeBytes formats a number of bytes with binary units, e.g. 1536 as
+// "1.5 KiB".
+func humanizeBytes(v interface{}) (string, error) {
+	n, err := toFloat("humanizeBytes", v)
+	if err != nil {
+		return "", err
+	}
+
+	sign := ""
+	if n < 0 {
+		sign, n = "-", -n
+	}
+
+	unit := 0
+	for unit < len(byteUnits)-1 && math.Round(n*10)/10 >= 1024 {
+		n /= 1024
+		unit++
+	}
+	f := strings.TrimSuffix(strconv.FormatFloat(n, 'f', 1, 64), ".0")
+	return sign + f + " " + byteUnits[unit], nil
+}
+
+// humanizeDuration formats a number of seconds as a duration, e.g. 3661 as
+// "1h1m1s".
+func humanizeDuration(v interface{}) (string, error) {
+	n, err := toFloat("humanizeDuration", v)
+	if err != nil {
+		return "", err
+	}
+
+	d := n * float64(time.Second)
+	if math.Abs(d) >= math.MaxInt64 {
+		return "", fmt.Errorf("humanizeDuration: %v seconds is out of range", v)
+	}
+	return time.Duration(d).String(), nil
+}
+
+// toFloat converts an integer, a float or a numeric string to a float64.
+func toFloat(name string, v interface{}) (float64, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return 0, fmt.Errorf("%s: invalid number %v", name, f)
+		}
+		return f, nil
+	case reflect.String:
+		f, err := strconv.ParseFloat(strings.TrimSpace(rv.String()), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return 0, fmt.Errorf("%s: invalid number %q", name, rv.String())
+		}
+		return f, nil
+	default:
+		return 0, fmt.Errorf("%s: unknown type for %q (%T)", name, v, v)
+	}
+}
+
 // timestamp returns the current UNIX timestamp in UTC. If an argument is
-// specified, it will be used to format the timestamp.
+// specified, it will be used to format the timestamp. A second argument names
+// the IANA timezone to convert the timestamp to before formatting.
 func timestamp(s ...string) (string, error) {
-	switch len(s) {
-	case 0:
-		return now().Format(time.RFC3339), nil
-	case 1:
-		if s[0] == "unix" {
-			return strconv.FormatInt(now().Unix(), 10), nil
+	if len(s) > 2 {
+		return "", fmt.Errorf("timestamp: wrong number of arguments, expected 0, 1 or 2"+
+			", but got %d", len(s))
+	}
+
+	t := now()
+	if len(s) == 2 {
+		loc, err := time.LoadLocation(s[1])
+		if err != nil {
+			return "", errors.Wrap(err, "timestamp")
 		}
-		return now().Format(s[0]), nil
+		t = t.In(loc)
+	}
+
+	switch {
+	case len(s) == 0:
+		return t.Format(time.RFC3339), nil
+	case s[0] == "unix":
+		return strconv.FormatInt(t.Unix(), 10), nil
 	default:
-		return "", fmt.Errorf("timestamp: wrong number of arguments, expected 0 or 1"+
-			", but got %d", len(s))
+		return t.Format(s[0]), nil
 	}
 }
 
@@ -1292,6 +2726,22 @@ func toJSONPretty(m interface{}) (string, error) {
 	return string(bytes.TrimSpace(result)), err
 }
 
+// jsonEscape escapes the given string for use inside a JSON string literal.
+// Unlike toJSON, the result is not surrounded by quotes, so it can be spliced
+// into a hand-written JSON document, e.g. "{{ jsonEscape .Value }}". Quotes,
+// backslashes and control characters are escaped, while HTML characters and
+// other unicode are kept as is.
+func jsonEscape(s string) (string, error) {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(s); err != nil {
+		return "", errors.Wrap(err, "jsonEscape")
+	}
+	b := bytes.TrimRight(buf.Bytes(), "\n")
+	return string(b[1 : len(b)-1]), nil
+}
+
 // toUnescapedJSON converts the given structure into a deeply nested JSON string without HTML escaping.
 func toUnescapedJSON(i interface{}) (string, error) {
 	buf := &bytes.Buffer{}
@@ -1486,6 +2936,10 @@ func divide(b, a interface{}) (interface{}, error) {
 	av := reflect.ValueOf(a)
 	bv := reflect.ValueOf(b)
 
+	if isZeroNumber(bv) {
+		return nil, fmt.Errorf("divide: division by zero")
+	}
+
 	switch av.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		switch bv.Kind() {
@@ -1530,6 +2984,10 @@ func modulo(b, a interface{}) (interface{}, error) {
 	av := reflect.ValueOf(a)
 	bv := reflect.ValueOf(b)
 
+	if isZeroNumber(bv) {
+		return nil, fmt.Errorf("modulo: division by zero")
+	}
+
 	switch av.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		switch bv.Kind() {
@@ -1696,6 +3154,92 @@ func maximum(b, a interface{}) (interface{}, error) {
 	}
 }
 
+// sub returns a minus b. Unlike subtract, which takes its arguments in
+// pipeline order, it takes them in the order they are written.
+func sub(a, b interface{}) (interface{}, error) {
+	return subtract(b, a)
+}
+
+// mul returns the product of a and b.
+func mul(a, b interface{}) (interface{}, error) {
+	return multiply(b, a)
+}
+
+// div returns a divided by b. Unlike divide, which takes its arguments in
+// pipeline order, it takes them in the order they are written.
+func div(a, b interface{}) (interface{}, error) {
+	return divide(b, a)
+}
+
+// mod returns a modulo b. Unlike modulo, which takes its arguments in
+// pipeline order, it takes them in the order they are written.
+func mod(a, b interface{}) (interface{}, error) {
+	return modulo(b, a)
+}
+
+// sum returns the sum of a slice of numbers. Numeric strings, such as values
+// read from Consul KV, are parsed. The result is an int64 unless any of the
+// values is a float, in which case it is a float64.
+func sum(values interface{}) (interface{}, error) {
+	v := reflect.ValueOf(values)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+	default:
+		return nil, fmt.Errorf("sum: unknown type for %q (%T)", v, values)
+	}
+
+	var isum int64
+	var fsum float64
+	var isFloat bool
+	for i := 0; i < v.Len(); i++ {
+		e := v.Index(i)
+		if e.Kind() == reflect.Interface {
+			e = e.Elem()
+		}
+
+		switch e.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			isum += e.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			isum += int64(e.Uint())
+		case reflect.Float32, reflect.Float64:
+			fsum += e.Float()
+			isFloat = true
+		case reflect.String:
+			s := strings.TrimSpace(e.String())
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				isum += n
+			} else if f, err := strconv.ParseFloat(s, 64); err == nil {
+				fsum += f
+				isFloat = true
+			} else {
+				return nil, fmt.Errorf("sum: cannot parse %q as a number", s)
+			}
+		default:
+			return nil, fmt.Errorf("sum: unknown type at index %d (%s)", i, e.Kind())
+		}
+	}
+
+	if isFloat {
+		return float64(isum) + fsum, nil
+	}
+	return isum, nil
+}
+
+// isZeroNumber reports whether v is a numeric zero, used to reject division
+// by zero.
+func isZeroNumber(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	}
+	return false
+}
+
 // denied always returns an error, to be used in place of denied template functions
 func denied(...string) (string, error) {
 	return "", errors.New("function is disabled")
@@ -1709,17 +3253,292 @@ func pathInSandbox(sandbox, path string) error {
 		if err != nil {
 			return err
 		}
-		s, err = filepath.Rel(sandbox, s)
+		// Resolve the sandbox too, so a sandbox reached through a symlink is
+		// compared against the same real path as the file.
+		root, err := filepath.EvalSymlinks(sandbox)
+		if err != nil {
+			return err
+		}
+		s, err = filepath.Rel(root, s)
 		if err != nil {
 			return err
 		}
-		if strings.HasPrefix(s, "..") {
+		// Only a leading ".." path element escapes, not a name like "..data".
+		if s == ".." || strings.HasPrefix(s, ".."+string(filepath.Separator)) {
 			return fmt.Errorf("'%s' is outside of sandbox", path)
 		}
 	}
 	return nil
 }
 
+// addrPort joins an address and a port into a "host:port" string, wrapping
+// IPv6 addresses in brackets. It takes either a *HealthService or an address
+// and a port, where the port is a string or an integer.
+func addrPort(args ...interface{}) (string, error) {
+	var addr string
+	var port interface{}
+
+	switch len(args) {
+	case 1:
+		s, ok := args[0].(*dep.HealthService)
+		if !ok {
+			return "", fmt.Errorf("addrPort: expected a service, got %T", args[0])
+		}
+		addr, port = s.Address, s.Port
+	case 2:
+		a, ok := args[0].(string)
+		if !ok {
+			return "", fmt.Errorf("addrPort: expected a string address, got %T", args[0])
+		}
+		addr, port = a, args[1]
+	default:
+		return "", fmt.Errorf("addrPort: wrong number of arguments, expected 1 or 2"+
+			", but got %d", len(args))
+	}
+
+	var p uint64
+	var err error
+	v := reflect.ValueOf(port)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() < 0 {
+			err = fmt.Errorf("negative port")
+		}
+		p = uint64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		p = v.Uint()
+	case reflect.String:
+		p, err = strconv.ParseUint(v.String(), 10, 16)
+	default:
+		return "", fmt.Errorf("addrPort: unknown port type %T", port)
+	}
+	if err != nil || p > 65535 {
+		return "", fmt.Errorf("addrPort: invalid port %v", port)
+	}
+
+	// Accept addresses that are already bracketed.
+	addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	return net.JoinHostPort(addr, strconv.FormatUint(p, 10)), nil
+}
+
+// parseCIDR parses an IP prefix in CIDR notation, returning its network. The
+// host bits of the address are cleared, so "10.0.0.5/24" is "10.0.0.0/24".
+func parseCIDR(name, prefix string) (*net.IPNet, error) {
+	_, network, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid prefix %q", name, prefix)
+	}
+	return network, nil
+}
+
+// toInteger converts a number, or a numeric string, that has no fractional
+// part to an int64.
+func toInteger(name string, v interface{}) (int64, error) {
+	f, err := toFloat(name, v)
+	if err != nil {
+		return 0, err
+	}
+	n := int64(f)
+	if float64(n) != f {
+		return 0, fmt.Errorf("%s: expected an integer, got %v", name, v)
+	}
+	return n, nil
+}
+
+// ipFromInt returns the IP address of the given length in bytes with the
+// numeric value n.
+func ipFromInt(n *big.Int, length int) net.IP {
+	return net.IP(n.FillBytes(make([]byte, length)))
+}
+
+// cidrHost returns the address of the given host number in the IP prefix,
+// like the Terraform function of the same name. A negative host number counts
+// back from the end of the prefix, so -1 is its last address.
+func cidrHost(prefix string, hostnum interface{}) (string, error) {
+	network, err := parseCIDR("cidrHost", prefix)
+	if err != nil {
+		return "", err
+	}
+	n, err := toInteger("cidrHost", hostnum)
+	if err != nil {
+		return "", err
+	}
+
+	ones, bits := network.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	num := big.NewInt(n)
+	if n < 0 {
+		num.Add(num, size)
+	}
+	if num.Sign() < 0 || num.Cmp(size) >= 0 {
+		return "", fmt.Errorf("cidrHost: prefix %s has no host number %d", prefix, n)
+	}
+
+	num.Add(num, new(big.Int).SetBytes(network.IP))
+	return ipFromInt(num, len(network.IP)).String(), nil
+}
+
+// cidrHostsLimit is the default maximum number of addresses cidrHosts returns.
+const cidrHostsLimit = 4096
+
+// cidrHosts returns the usable host addresses of the IP prefix. For IPv4
+// prefixes shorter than /31 these exclude the network and broadcast addresses.
+// The prefix is checked against a maximum number of addresses, 4096 unless
+// given, before any are generated, so that a large prefix, such as most IPv6
+// ones, errors instead of exhausting memory.
+func cidrHosts(prefix string, limit ...interface{}) ([]string, error) {
+	network, err := parseCIDR("cidrHosts", prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	max := int64(cidrHostsLimit)
+	switch len(limit) {
+	case 0:
+	case 1:
+		if max, err = toInteger("cidrHosts", limit[0]); err != nil {
+			return nil, err
+		}
+		if max < 1 {
+			return nil, fmt.Errorf("cidrHosts: invalid limit %d", max)
+		}
+	default:
+		return nil, fmt.Errorf("cidrHosts: wrong number of arguments, expected 1 or 2, but got %d", len(limit)+1)
+	}
+
+	ones, bits := network.Mask.Size()
+	first := new(big.Int).SetBytes(network.IP)
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	if bits == 8*net.IPv4len && bits-ones > 1 {
+		first.Add(first, big.NewInt(1))
+		size.Sub(size, big.NewInt(2))
+	}
+	if size.Cmp(big.NewInt(max)) > 0 {
+		return nil, fmt.Errorf("cidrHosts: prefix %s has %s addresses, more than the limit of %d",
+			prefix, size, max)
+	}
+
+	n := size.Int64()
+	result := make([]string, 0, n)
+	for i := int64(0); i < n; i++ {
+		result = append(result, ipFromInt(first, len(network.IP)).String())
+		first.Add(first, big.NewInt(1))
+	}
+	return result, nil
+}
+
+// cidrNetmask returns the netmask of the IP prefix in address notation, like
+// "255.255.255.0" for a /24 IPv4 prefix.
+func cidrNetmask(prefix string) (string, error) {
+	network, err := parseCIDR("cidrNetmask", prefix)
+	if err != nil {
+		return "", err
+	}
+	return net.IP(network.Mask).String(), nil
+}
+
+// cidrSubnets allocates consecutive subnets of the IP prefix, one for each of
+// the given numbers of bits to extend the prefix by, like the Terraform
+// function of the same name. Each subnet starts at the first address after the
+// previous one that is aligned to its size.
+func cidrSubnets(prefix string, newbits ...interface{}) ([]string, error) {
+	network, err := parseCIDR("cidrSubnets", prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	one := big.NewInt(1)
+	ones, bits := network.Mask.Size()
+	next := new(big.Int).SetBytes(network.IP)
+	end := new(big.Int).Add(next, new(big.Int).Lsh(one, uint(bits-ones)))
+
+	result := make([]string, 0, len(newbits))
+	for _, v := range newbits {
+		n, err := toInteger("cidrSubnets", v)
+		if err != nil {
+			return nil, err
+		}
+		if n < 1 || int64(ones)+n > int64(bits) {
+			return nil, fmt.Errorf("cidrSubnets: cannot extend prefix %s by %d bits", prefix, n)
+		}
+		length := ones + int(n)
+
+		// Round up to the next multiple of the subnet size.
+		size := new(big.Int).Lsh(one, uint(bits-length))
+		start := new(big.Int).Add(next, size)
+		start.Sub(start, one)
+		start.Div(start, size)
+		start.Mul(start, size)
+
+		next = new(big.Int).Add(start, size)
+		if next.Cmp(end) > 0 {
+			return nil, fmt.Errorf("cidrSubnets: not enough address space in %s for the subnets", prefix)
+		}
+
+		subnet := &net.IPNet{IP: ipFromInt(start, len(network.IP)), Mask: net.CIDRMask(length, bits)}
+		result = append(result, subnet.String())
+	}
+	return result, nil
+}
+
+// ipInCIDR reports whether the IP address is in the IP prefix.
+func ipInCIDR(ip, prefix string) (bool, error) {
+	network, err := parseCIDR("ipInCIDR", prefix)
+	if err != nil {
+		return false, err
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false, fmt.Errorf("ipInCIDR: invalid IP address %q", ip)
+	}
+	return network.Contains(addr), nil
+}
+
+// consulDNS returns the Consul DNS name of a service, in the form
+// "[tag.]name.service[.datacenter].domain". The service is given as
+// "[tag.]name", like for the service function, and it is followed by an
+// optional datacenter and an optional domain, which defaults to "consul".
+func consulDNS(service string, args ...string) (string, error) {
+	var dc, domain string
+	switch len(args) {
+	case 0:
+	case 1:
+		dc = args[0]
+	case 2:
+		dc, domain = args[0], args[1]
+	default:
+		return "", fmt.Errorf("consulDNS: wrong number of arguments, expected 1 to 3"+
+			", but got %d", len(args)+1)
+	}
+
+	tag, name := "", service
+	if i := strings.LastIndex(service, "."); i != -1 {
+		tag, name = service[:i], service[i+1:]
+		if tag == "" {
+			return "", fmt.Errorf("consulDNS: empty tag in %q", service)
+		}
+	}
+	if name == "" {
+		return "", fmt.Errorf("consulDNS: empty service name in %q", service)
+	}
+
+	domain = strings.Trim(domain, ".")
+	if domain == "" {
+		domain = "consul"
+	}
+
+	labels := make([]string, 0, 5)
+	if tag != "" {
+		labels = append(labels, tag)
+	}
+	labels = append(labels, name, "service")
+	if dc != "" {
+		labels = append(labels, dc)
+	}
+	labels = append(labels, domain)
+	return strings.Join(labels, "."), nil
+}
+
 // sockaddr wraps go-sockaddr templating
 func sockaddr(args ...string) (string, error) {
 	t := fmt.Sprintf("{{ %s }}", strings.Join(args, " "))
@@ -1743,6 +3562,26 @@ func md5sum(item string) (string, error) {
 	return fmt.Sprintf("%x", md5.Sum([]byte(item))), nil
 }
 
+// uuidv4 returns a random RFC 4122 UUID.
+func uuidv4() (string, error) {
+	u, err := uuid.NewRandom()
+	if err != nil {
+		return "", fmt.Errorf("uuidv4: %w", err)
+	}
+	return u.String(), nil
+}
+
+// uuidv5 returns the RFC 4122 name-based UUID of the name in the given
+// namespace, which is itself a UUID. The same arguments always return the
+// same UUID.
+func uuidv5(namespace, name string) (string, error) {
+	ns, err := uuid.Parse(namespace)
+	if err != nil {
+		return "", fmt.Errorf("uuidv5: invalid namespace %q: %w", namespace, err)
+	}
+	return uuid.NewSHA1(ns, []byte(name)).String(), nil
+}
+
 // hmacSHA256Hex returns the HMAC-SHA256 hash in hexadecimal format of the
 // given message by using the provided key
 func hmacSHA256Hex(message, key string) (string, error) {
@@ -1865,6 +3704,148 @@ func writeToFile(path, username, groupName, permissions string, args ...string)
 	return "", nil
 }
 
+// writeBinary decodes the base64 content and writes the resulting bytes to a
+// file with permissions, username (or UID) and group name (or GID). Whitespace
+// in the encoded content is ignored, and the decoded bytes are written exactly
+// as-is, so it is suitable for binary data like keystores.
+//
+// For example:
+//
+//	.Data.data.keystore | writeBinary "/my/keystore.jks" "" "" "0600"
+func writeBinary(path, username, groupName, permissions, content string) (string, error) {
+	encoded := strings.Join(strings.Fields(content), "")
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("writeBinary: %w", err)
+	}
+	return writeToFile(path, username, groupName, permissions, string(decoded))
+}
+
+// typeOf returns the Go type name of the given value.
+func typeOf(v interface{}) string {
+	if v == nil {
+		return "<nil>"
+	}
+	return reflect.TypeOf(v).String()
+}
+
+// dumpSecretKeys are substrings of map keys and struct field names whose
+// values are redacted by dump.
+var dumpSecretKeys = []string{"password", "passwd", "secret", "token"}
+
+// dumpMaxDepth bounds how deep dump descends into nested values.
+const dumpMaxDepth = 32
+
+// dump returns a human-readable, deep representation of the given value.
+// Values of map keys and struct fields that look like they hold secrets are
+// replaced with "[redacted]".
+func dump(v interface{}) string {
+	var b strings.Builder
+	dumpValue(&b, reflect.ValueOf(v), 0)
+	return b.String()
+}
+
+func dumpValue(b *strings.Builder, v reflect.Value, depth int) {
+	if !v.IsValid() {
+		b.WriteString("<nil>")
+		return
+	}
+	if depth > dumpMaxDepth {
+		b.WriteString("...")
+		return
+	}
+
+	indent := strings.Repeat("  ", depth+1)
+	closing := strings.Repeat("  ", depth)
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			b.WriteString("<nil>")
+			return
+		}
+		if v.Kind() == reflect.Pointer {
+			b.WriteString("&")
+		}
+		dumpValue(b, v.Elem(), depth)
+	case reflect.Map:
+		if v.IsNil() {
+			b.WriteString("<nil>")
+			return
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		fmt.Fprintf(b, "%s{\n", v.Type())
+		for _, k := range keys {
+			b.WriteString(indent)
+			dumpValue(b, k, depth+1)
+			b.WriteString(": ")
+			if dumpIsSecret(fmt.Sprint(k.Interface())) {
+				b.WriteString("[redacted]")
+			} else {
+				dumpValue(b, v.MapIndex(k), depth+1)
+			}
+			b.WriteString(",\n")
+		}
+		fmt.Fprintf(b, "%s}", closing)
+	case reflect.Struct:
+		t := v.Type()
+		fmt.Fprintf(b, "%s{\n", t)
+		for i := 0; i < v.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			fmt.Fprintf(b, "%s%s: ", indent, f.Name)
+			if dumpIsSecret(f.Name) {
+				b.WriteString("[redacted]")
+			} else {
+				dumpValue(b, v.Field(i), depth+1)
+			}
+			b.WriteString(",\n")
+		}
+		fmt.Fprintf(b, "%s}", closing)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			b.WriteString("<nil>")
+			return
+		}
+		fmt.Fprintf(b, "%s{", v.Type())
+		if v.Len() == 0 {
+			b.WriteString("}")
+			return
+		}
+		b.WriteString("\n")
+		for i := 0; i < v.Len(); i++ {
+			b.WriteString(indent)
+			dumpValue(b, v.Index(i), depth+1)
+			b.WriteString(",\n")
+		}
+		fmt.Fprintf(b, "%s}", closing)
+	case reflect.String:
+		fmt.Fprintf(b, "%q", v.String())
+	default:
+		if v.CanInterface() {
+			fmt.Fprintf(b, "%v", v.Interface())
+		} else {
+			fmt.Fprintf(b, "%v", v)
+		}
+	}
+}
+
+// dumpIsSecret reports whether the given key looks like it holds a secret.
+func dumpIsSecret(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range dumpSecretKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
 func spewSdump(args ...interface{}) (string, error) {
 	return spewLib.Sdump(args...), nil
 }
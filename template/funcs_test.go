@@ -4,11 +4,22 @@
 package template
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"math/big"
+	"net"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"runtime"
 	"testing"
+	"time"
 
 	dep "github.com/hashicorp/consul-template/dependency"
 )
@@ -71,6 +82,25 @@ func TestFileSandbox(t *testing.T) {
 			fmt.Errorf("'%s' is outside of sandbox",
 				filepath.Join(sandboxDir, "path/to/bad-symlink")),
 		},
+		{
+			"dot_dot_name_in_sandbox",
+			sandboxDir,
+			filepath.Join(sandboxDir, "path/to/..data"),
+			nil,
+		},
+		{
+			"symlinked_sandbox",
+			sandboxDir + "-link",
+			filepath.Join(sandboxDir, "path/to/file"),
+			nil,
+		},
+		{
+			"absolute_path_escaping_symlinked_sandbox",
+			sandboxDir + "-link",
+			filepath.Join(filepath.Dir(sandboxDir), "..", "funcs_test.go"),
+			fmt.Errorf("'%s' is outside of sandbox",
+				filepath.Join(filepath.Dir(sandboxDir), "..", "funcs_test.go")),
+		},
 	}
 
 	for i, tc := range cases {
@@ -203,6 +233,60 @@ func Test_byMeta(t *testing.T) {
 	}
 }
 
+func Test_sortByMeta(t *testing.T) {
+	svc := func(id, node string, meta map[string]string) *dep.HealthService {
+		return &dep.HealthService{ID: id, Node: node, ServiceMeta: meta}
+	}
+	services := []*dep.HealthService{
+		svc("web-3", "node1", map[string]string{"zone": "b"}),
+		svc("web-1", "node2", map[string]string{"zone": "b"}),
+		svc("web-2", "node1", map[string]string{"zone": "a"}),
+		svc("web-5", "node1", nil),
+		svc("web-4", "node1", map[string]string{"other": "a"}),
+		svc("web-1", "node1", map[string]string{"zone": "b"}),
+		svc("web-6", "node1", map[string]string{"zone": ""}),
+	}
+	exp := []string{
+		"web-6@node1", // an empty value is still present
+		"web-2@node1",
+		"web-1@node1",
+		"web-1@node2",
+		"web-3@node1",
+		"web-4@node1", // missing the key
+		"web-5@node1",
+	}
+
+	ids := func(services []*dep.HealthService) []string {
+		result := make([]string, 0, len(services))
+		for _, s := range services {
+			result = append(result, s.ID+"@"+s.Node)
+		}
+		return result
+	}
+
+	// Every rotation and the reverse of the input sort the same way.
+	for i := range services {
+		input := append(append([]*dep.HealthService{}, services[i:]...), services[:i]...)
+		t.Run(fmt.Sprintf("rotation_%d", i), func(t *testing.T) {
+			if act := ids(sortByMeta("zone", input)); !reflect.DeepEqual(exp, act) {
+				t.Errorf("\nexp: %#v\nact: %#v", exp, act)
+			}
+		})
+	}
+	reversed := make([]*dep.HealthService, 0, len(services))
+	for i := len(services) - 1; i >= 0; i-- {
+		reversed = append(reversed, services[i])
+	}
+	if act := ids(sortByMeta("zone", reversed)); !reflect.DeepEqual(exp, act) {
+		t.Errorf("reversed\nexp: %#v\nact: %#v", exp, act)
+	}
+
+	// The input is left untouched.
+	if act := ids(services)[0]; act != "web-3@node1" {
+		t.Errorf("expected the input to be unchanged, got %q first", act)
+	}
+}
+
 func Test_sha256Hex(t *testing.T) {
 	type args struct {
 		item string
@@ -303,3 +387,413 @@ func Test_hmacSHA256Hex(t *testing.T) {
 		})
 	}
 }
+
+func Test_uuidv5(t *testing.T) {
+	// The DNS namespace example from RFC 4122 errata 3476.
+	const ns = "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+	tests := []struct {
+		name      string
+		namespace string
+		value     string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "Should return the name-based UUID",
+			namespace: ns,
+			value:     "www.example.com",
+			want:      "2ed6657d-e927-568b-95e1-2665a8aea6a2",
+		},
+		{
+			name:      "Should depend on the name",
+			namespace: ns,
+			value:     "web.service.consul",
+			want:      "9bfe276c-6888-5f0a-88ca-cc338a736981",
+		},
+		{
+			name:      "Should reject an invalid namespace",
+			namespace: "not-a-uuid",
+			value:     "www.example.com",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 2; i++ {
+				got, err := uuidv5(tt.namespace, tt.value)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("uuidv5() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if got != tt.want {
+					t.Fatalf("uuidv5() got = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func Test_uuidv4(t *testing.T) {
+	re := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	seen := make(map[string]struct{})
+	for i := 0; i < 100; i++ {
+		got, err := uuidv4()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !re.MatchString(got) {
+			t.Fatalf("expected %q to be a version 4 UUID", got)
+		}
+		if _, ok := seen[got]; ok {
+			t.Fatalf("expected %q to be unique", got)
+		}
+		seen[got] = struct{}{}
+	}
+}
+
+func Test_jsonEscape(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{"plain", "hello world", "hello world"},
+		{"empty", "", ""},
+		{"quotes", `say "hi"`, `say \"hi\"`},
+		{"backslash", `C:\temp`, `C:\\temp`},
+		{"newlines", "line1\nline2\r\n", `line1\nline2\r\n`},
+		{"control", "a\tb\x00c\x1f", `a\tb\u0000c\u001f`},
+		{"html", "<a href='x'>&</a>", "<a href='x'>&</a>"},
+		{"unicode", "héllo 世界 🚀", "héllo 世界 🚀"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := jsonEscape(tt.s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("jsonEscape() got = %q, want %q", got, tt.want)
+			}
+
+			// The result must be valid inside a JSON string literal.
+			var decoded string
+			if err := json.Unmarshal([]byte(`"`+got+`"`), &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if decoded != tt.s {
+				t.Errorf("decoded %q, want %q", decoded, tt.s)
+			}
+		})
+	}
+}
+
+func Test_hashMod(t *testing.T) {
+	t.Run("deterministic", func(t *testing.T) {
+		a, err := hashMod("web", 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 5; i++ {
+			b, err := hashMod("web", 8)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a != b {
+				t.Fatalf("expected %d, got %d", a, b)
+			}
+		}
+	})
+
+	t.Run("stable", func(t *testing.T) {
+		// Buckets must not change between releases, or dashboards reshuffle.
+		for s, exp := range map[string]int{"web": 1, "api": 7, "db": 3} {
+			act, err := hashMod(s, 8)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if act != exp {
+				t.Errorf("expected %q in bucket %d, got %d", s, exp, act)
+			}
+		}
+	})
+
+	t.Run("distribution", func(t *testing.T) {
+		counts := make([]int, 4)
+		for i := 0; i < 400; i++ {
+			b, err := hashMod(fmt.Sprintf("service-%d", i), 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b < 0 || b >= 4 {
+				t.Fatalf("expected a bucket in [0, 4), got %d", b)
+			}
+			counts[b]++
+		}
+		for b, c := range counts {
+			if c < 60 || c > 140 {
+				t.Errorf("expected about 100 names in bucket %d, got %d", b, c)
+			}
+		}
+	})
+
+	t.Run("invalid_n", func(t *testing.T) {
+		for _, n := range []interface{}{0, -1, 1.5, "many"} {
+			if _, err := hashMod("web", n); err == nil {
+				t.Errorf("expected an error for n %v", n)
+			}
+		}
+	})
+}
+
+func Test_consistentShard(t *testing.T) {
+	services := make([]*dep.HealthService, 0, 20)
+	for i := 0; i < 20; i++ {
+		services = append(services, &dep.HealthService{
+			Node: fmt.Sprintf("node%d", i%5),
+			ID:   fmt.Sprintf("web-%d", i),
+		})
+	}
+	// shardOf returns the shard of each service, asking one host per shard.
+	shardOf := func(t *testing.T, services []*dep.HealthService, count int) map[*dep.HealthService]int32 {
+		t.Helper()
+		shards := make(map[*dep.HealthService]int32)
+		covered := make(map[int32]bool)
+		for i := 0; len(covered) < count; i++ {
+			host := fmt.Sprintf("host-%d", i)
+			shard := jumpHash(host, int32(count))
+			if covered[shard] {
+				continue
+			}
+			covered[shard] = true
+
+			assigned, err := consistentShard(services, host, count)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range assigned {
+				if _, ok := shards[s]; ok {
+					t.Fatalf("expected %s/%s to be assigned to a single shard", s.Node, s.ID)
+				}
+				shards[s] = shard
+			}
+		}
+		if len(shards) != len(services) {
+			t.Fatalf("expected all %d services to be assigned, got %d", len(services), len(shards))
+		}
+		return shards
+	}
+
+	t.Run("deterministic", func(t *testing.T) {
+		a, err := consistentShard(services, "host-a", 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(a) == 0 || len(a) == len(services) {
+			t.Fatalf("expected a subset of the services, got %d", len(a))
+		}
+		for i := 0; i < 5; i++ {
+			b, err := consistentShard(services, "host-a", 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(a, b) {
+				t.Fatalf("\nexp: %v\nact: %v", a, b)
+			}
+		}
+	})
+
+	t.Run("hosts_on_same_shard_agree", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			host := fmt.Sprintf("host-%d", i)
+			if jumpHash(host, 3) != jumpHash("host-a", 3) {
+				continue
+			}
+			a, _ := consistentShard(services, "host-a", 3)
+			b, _ := consistentShard(services, host, 3)
+			if !reflect.DeepEqual(a, b) {
+				t.Errorf("\nexp: %v\nact: %v", a, b)
+			}
+		}
+	})
+
+	t.Run("instance_removed", func(t *testing.T) {
+		before := shardOf(t, services, 3)
+		removed := services[7]
+		after := shardOf(t, append(append([]*dep.HealthService{}, services[:7]...), services[8:]...), 3)
+
+		for s, shard := range after {
+			if before[s] != shard {
+				t.Errorf("expected %s/%s to stay on shard %d, moved to %d", s.Node, s.ID, before[s], shard)
+			}
+		}
+		if _, ok := after[removed]; ok {
+			t.Errorf("expected the removed instance not to be assigned")
+		}
+	})
+
+	t.Run("shard_added", func(t *testing.T) {
+		before := shardOf(t, services, 3)
+		after := shardOf(t, services, 4)
+
+		// Instances only move to the new shard, never between existing ones.
+		for s, shard := range after {
+			if shard != 3 && before[s] != shard {
+				t.Errorf("expected %s/%s to stay on shard %d or move to 3, got %d", s.Node, s.ID, before[s], shard)
+			}
+		}
+	})
+
+	t.Run("invalid_count", func(t *testing.T) {
+		for _, count := range []interface{}{0, -1, 1.5, "many"} {
+			if _, err := consistentShard(services, "host-a", count); err == nil {
+				t.Errorf("expected an error for shard count %v", count)
+			}
+		}
+	})
+}
+
+func Test_weightedPick(t *testing.T) {
+	services := []*dep.HealthService{
+		{Node: "node1", ID: "web-1", ServiceMeta: map[string]string{"weight": "1"}},
+		{Node: "node2", ID: "web-2", ServiceMeta: map[string]string{"weight": "3"}},
+		{Node: "node3", ID: "web-3", ServiceMeta: map[string]string{"weight": "0"}},
+		{Node: "node4", ID: "web-4"},
+	}
+
+	t.Run("same_seed_same_pick", func(t *testing.T) {
+		reversed := []*dep.HealthService{services[3], services[2], services[1], services[0]}
+		for seed := uint64(0); seed < 100; seed++ {
+			a, err := weightedPick(services, "weight", seed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, _ := weightedPick(services, "weight", seed)
+			c, _ := weightedPick(reversed, "weight", seed)
+			if a != b || a != c {
+				t.Fatalf("seed %d: expected %s every time, got %s and %s", seed, a.ID, b.ID, c.ID)
+			}
+		}
+	})
+
+	t.Run("proportional", func(t *testing.T) {
+		// Zero and absent weights count as 1, for a total weight of 6.
+		const seeds = 60000
+		counts := make(map[string]int)
+		for seed := uint64(0); seed < seeds; seed++ {
+			s, err := weightedPick(services, "weight", mix64(seed))
+			if err != nil {
+				t.Fatal(err)
+			}
+			counts[s.ID]++
+		}
+		exp := map[string]float64{"web-1": 1, "web-2": 3, "web-3": 1, "web-4": 1}
+		for id, w := range exp {
+			share := float64(counts[id]) / seeds
+			if share < w/6-0.01 || share > w/6+0.01 {
+				t.Errorf("expected %s to be picked %.3f of the time, got %.3f", id, w/6, share)
+			}
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		s, err := weightedPick(nil, "weight", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != nil {
+			t.Errorf("expected no pick, got %s", s.ID)
+		}
+	})
+
+	t.Run("invalid_weight", func(t *testing.T) {
+		for _, w := range []string{"-1", "heavy", "Inf"} {
+			bad := []*dep.HealthService{{ID: "web-1", ServiceMeta: map[string]string{"weight": w}}}
+			if _, err := weightedPick(bad, "weight", 1); err == nil {
+				t.Errorf("expected an error for weight %q", w)
+			}
+		}
+	})
+}
+
+func Test_parseCert(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notBefore := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	notAfter := notBefore.Add(90 * 24 * time.Hour)
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "web.example.com", Organization: []string{"Example"}},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		DNSNames:     []string{"web.example.com", "web.service.consul"},
+		IPAddresses:  []net.IP{net.ParseIP("10.0.0.1")},
+	}, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Example CA"},
+	}, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+
+	exp := &Certificate{
+		Subject:     "CN=web.example.com,O=Example",
+		Issuer:      "CN=Example CA",
+		NotBefore:   notBefore,
+		NotAfter:    notAfter,
+		DNSNames:    []string{"web.example.com", "web.service.consul"},
+		IPAddresses: []string{"10.0.0.1"},
+	}
+
+	t.Run("fields", func(t *testing.T) {
+		// The key comes first in a bundle like the one of pkiCert.
+		for _, s := range []string{certPEM, keyPEM + certPEM} {
+			act, err := parseCert(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(exp, act) {
+				t.Errorf("\nexp: %#v\nact: %#v", exp, act)
+			}
+		}
+	})
+
+	t.Run("template", func(t *testing.T) {
+		tpl, err := NewTemplate(&NewTemplateInput{
+			Contents: fmt.Sprintf(`{{ with parseCert %q }}{{ .Subject }} {{ index .DNSNames 1 }} `+
+				`{{ .NotAfter.Format "2006-01-02" }}{{ end }}`, certPEM),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := tpl.Execute(&ExecuteInput{Brain: NewBrain()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp, act := "CN=web.example.com,O=Example web.service.consul 2024-04-01", string(result.Output); act != exp {
+			t.Errorf("expected %q to be %q", act, exp)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		invalid := []string{
+			"",
+			"not a certificate",
+			keyPEM,
+			string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("garbage")})),
+		}
+		for _, s := range invalid {
+			if _, err := parseCert(s); err == nil {
+				t.Errorf("expected an error for %q", s)
+			}
+		}
+	})
+}
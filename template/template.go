@@ -8,6 +8,7 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"text/template"
@@ -210,6 +211,10 @@ type ExecuteInput struct {
 	// provided to allow for functions that might need to adapt based on certain
 	// configuration values
 	Config *config.Config
+
+	// Writer, when set, receives the rendered output as it is produced instead
+	// of it being buffered into the result's Output.
+	Writer io.Writer
 }
 
 // ExecuteResult is the result of the template execution.
@@ -220,8 +225,23 @@ type ExecuteResult struct {
 	// Missing is the set of dependencies that were missing.
 	Missing *dep.Set
 
-	// Output is the rendered result.
+	// Tolerated is the set of used dependencies whose fetch errors the template
+	// tolerates by rendering without their data, like the datacenters of
+	// servicesByDC.
+	Tolerated *dep.Set
+
+	// Output is the rendered result. It is nil when the output was written to
+	// the input's Writer.
 	Output []byte
+
+	// UsesGeneration reports whether the template called renderGeneration, so
+	// its output depends on the render generation of its destination.
+	UsesGeneration bool
+
+	// Emitted are the contents of the files the template wrote with emit, by
+	// name relative to its destination directory. It is only set for fan-out
+	// templates.
+	Emitted map[string][]byte
 }
 
 // Execute evaluates this template in the provided context.
@@ -230,7 +250,13 @@ func (t *Template) Execute(i *ExecuteInput) (*ExecuteResult, error) {
 		i = &ExecuteInput{}
 	}
 
-	var used, missing dep.Set
+	var used, missing, tolerated dep.Set
+	var usesGeneration bool
+
+	var emitted map[string][]byte
+	if t.config != nil && config.BoolVal(t.config.FanOut) {
+		emitted = make(map[string][]byte)
+	}
 
 	tmpl := template.New("")
 	tmpl.Delims(t.leftDelim, t.rightDelim)
@@ -241,6 +267,9 @@ func (t *Template) Execute(i *ExecuteInput) (*ExecuteResult, error) {
 		env:              i.Env,
 		used:             &used,
 		missing:          &missing,
+		tolerated:        &tolerated,
+		usesGeneration:   &usesGeneration,
+		emitted:          emitted,
 		extFuncMap:       t.extFuncMap,
 		functionDenylist: t.functionDenylist,
 		sandboxPath:      t.sandboxPath,
@@ -261,15 +290,25 @@ func (t *Template) Execute(i *ExecuteInput) (*ExecuteResult, error) {
 
 	// Execute the template into the writer
 	var b bytes.Buffer
-	if err := tmpl.Execute(&b, nil); err != nil {
+	w := i.Writer
+	if w == nil {
+		w = &b
+	}
+	if err := tmpl.Execute(w, nil); err != nil {
 		return nil, errors.Wrap(redactinator(&used, i.Brain, err), "execute")
 	}
 
-	return &ExecuteResult{
-		Used:    &used,
-		Missing: &missing,
-		Output:  b.Bytes(),
-	}, nil
+	result := &ExecuteResult{
+		Used:           &used,
+		Missing:        &missing,
+		Tolerated:      &tolerated,
+		UsesGeneration: usesGeneration,
+		Emitted:        emitted,
+	}
+	if i.Writer == nil {
+		result.Output = b.Bytes()
+	}
+	return result, nil
 }
 
 func redactinator(used *dep.Set, b *Brain, err error) error {
@@ -302,6 +341,9 @@ type funcMapInput struct {
 	destination      string
 	used             *dep.Set
 	missing          *dep.Set
+	tolerated        *dep.Set
+	usesGeneration   *bool
+	emitted          map[string][]byte
 	config           *config.Config
 }
 
@@ -319,26 +361,52 @@ func funcMap(i *funcMapInput) template.FuncMap {
 
 	r := template.FuncMap{
 		// API functions
-		"datacenters":  datacentersFunc(i.brain, i.used, i.missing),
-		"file":         fileFunc(i.brain, i.used, i.missing, i.sandboxPath),
-		"key":          keyFunc(i.brain, i.used, i.missing),
-		"keyExists":    keyExistsFunc(i.brain, i.used, i.missing),
-		"keyOrDefault": keyWithDefaultFunc(i.brain, i.used, i.missing),
-		"ls":           lsFunc(i.brain, i.used, i.missing, true),
-		"safeLs":       safeLsFunc(i.brain, i.used, i.missing),
-		"node":         nodeFunc(i.brain, i.used, i.missing),
-		"nodes":        nodesFunc(i.brain, i.used, i.missing),
-		"peerings":     peeringsFunc(i.brain, i.used, i.missing),
-		"secret":       secretFunc(i.brain, i.used, i.missing),
-		"secrets":      secretsFunc(i.brain, i.used, i.missing),
-		"service":      serviceFunc(i.brain, i.used, i.missing),
-		"connect":      connectFunc(i.brain, i.used, i.missing),
-		"services":     servicesFunc(i.brain, i.used, i.missing),
-		"tree":         treeFunc(i.brain, i.used, i.missing, true),
-		"safeTree":     safeTreeFunc(i.brain, i.used, i.missing),
-		"caRoots":      connectCARootsFunc(i.brain, i.used, i.missing),
-		"caLeaf":       connectLeafFunc(i.brain, i.used, i.missing),
-		"pkiCert":      pkiCertFunc(i.brain, i.used, i.missing, i.destination),
+		"datacenters":      datacentersFunc(i.brain, i.used, i.missing),
+		"file":             fileFunc(i.brain, i.used, i.missing, i.sandboxPath),
+		"fileBase64":       fileBase64Func(i.brain, i.used, i.missing, i.sandboxPath),
+		"httpGet":          httpGetFunc(i.brain, i.used, i.missing),
+		"httpGetJSON":      httpGetJSONFunc(i.brain, i.used, i.missing),
+		"key":              keyFunc(i.brain, i.used, i.missing),
+		"keyExists":        keyExistsFunc(i.brain, i.used, i.missing),
+		"keyOrDefault":     keyWithDefaultFunc(i.brain, i.used, i.missing),
+		"keyGunzip":        keyGunzipFunc(i.brain, i.used, i.missing),
+		"keyJSON":          keyJSONFunc(i.brain, i.used, i.missing),
+		"keyJSONOrDefault": keyJSONWithDefaultFunc(i.brain, i.used, i.missing),
+		"kvTxn":            kvTxnFunc(i.brain, i.used, i.missing),
+		"lockHolder":       lockHolderFunc(i.brain, i.used, i.missing),
+		"ls":               lsFunc(i.brain, i.used, i.missing, true),
+		"safeLs":           safeLsFunc(i.brain, i.used, i.missing),
+		"node":             nodeFunc(i.brain, i.used, i.missing),
+		"nodes":            nodesFunc(i.brain, i.used, i.missing),
+		"nodeSegment":      nodeSegmentFunc(i.brain, i.used, i.missing),
+		"nodeCoordinate":   nodeCoordinateFunc(i.brain, i.used, i.missing),
+		"agentMetrics":     agentMetricsFunc(i.brain, i.used, i.missing),
+		"peerings":         peeringsFunc(i.brain, i.used, i.missing),
+		"secret":           secretFunc(i.brain, i.used, i.missing),
+		"secretWrapped":    secretWrappedFunc(i.brain, i.used, i.missing),
+		"secrets":          secretsFunc(i.brain, i.used, i.missing),
+		"service":          serviceFunc(i.brain, i.used, i.missing),
+		"srvRecords":       srvRecordsFunc(i.brain, i.used, i.missing),
+		"connect":          connectFunc(i.brain, i.used, i.missing),
+		"services":         servicesFunc(i.brain, i.used, i.missing),
+		"servicesByDC":     servicesByDCFunc(i.brain, i.used, i.missing, i.tolerated),
+		"servicesDelta":    servicesDeltaFunc(i.brain, i.used, i.missing, i.destination),
+		"tree":             treeFunc(i.brain, i.used, i.missing, true),
+		"treeMap":          treeMapFunc(i.brain, i.used, i.missing),
+		"safeTree":         safeTreeFunc(i.brain, i.used, i.missing),
+		"treeChanged":      treeChangedFunc(i.brain, i.used, i.missing, i.destination),
+		"caRoots":          connectCARootsFunc(i.brain, i.used, i.missing),
+		"caLeaf":           connectLeafFunc(i.brain, i.used, i.missing),
+		"checks":           checksFunc(i.brain, i.used, i.missing),
+		"intentions":       intentionsFunc(i.brain, i.used, i.missing),
+		"pkiCert":          pkiCertFunc(i.brain, i.used, i.missing, i.destination),
+		"renderGeneration": renderGenerationFunc(i.brain, i.destination, i.usesGeneration),
+		"emit":             emitFunc(i.emitted),
+		"weightedPick":     weightedPickFunc(i.destination),
+		"dependencyAge":    dependencyAgeFunc(i.brain, i.used),
+
+		// Prometheus service discovery, from the service instances.
+		"prometheusTargets": prometheusTargetsFunc(i.brain, i.used, i.missing),
 
 		// Nomad Functions.
 		"nomadServices":    nomadServicesFunc(i.brain, i.used, i.missing),
@@ -352,12 +420,20 @@ func funcMap(i *funcMapInput) template.FuncMap {
 		"scratch": func() *Scratch { return &scratch },
 
 		// Helper functions
+		"addrPort":              addrPort,
 		"base64Decode":          base64Decode,
 		"base64Encode":          base64Encode,
 		"base64URLDecode":       base64URLDecode,
 		"base64URLEncode":       base64URLEncode,
 		"byKey":                 byKey,
 		"byTag":                 byTag,
+		"allTags":               allTags,
+		"cidrHost":              cidrHost,
+		"cidrHosts":             cidrHosts,
+		"cidrNetmask":           cidrNetmask,
+		"cidrSubnets":           cidrSubnets,
+		"consistentShard":       consistentShard,
+		"consulDNS":             consulDNS,
 		"contains":              contains,
 		"containsAll":           containsSomeFunc(true, true),
 		"containsAny":           containsSomeFunc(false, false),
@@ -369,29 +445,48 @@ func funcMap(i *funcMapInput) template.FuncMap {
 		"executeTemplate":       executeTemplateFunc(i.newTmpl),
 		"explode":               explode,
 		"explodeMap":            explodeMap,
+		"failIf":                failIf,
+		"filterKeys":            filterKeys,
+		"ipInCIDR":              ipInCIDR,
+		"mapDiff":               mapDiff,
+		"setUnion":              setUnion,
+		"setIntersect":          setIntersect,
+		"setDifference":         setDifference,
 		"mergeMap":              mergeMap,
 		"mergeMapWithOverride":  mergeMapWithOverride,
+		"humanizeBytes":         humanizeBytes,
+		"humanizeDuration":      humanizeDuration,
 		"in":                    in,
 		"indent":                indent,
 		"loop":                  loop,
 		"join":                  join,
+		"jsonEscape":            jsonEscape,
 		"trim":                  trim,
 		"trimPrefix":            trimPrefix,
 		"trimSuffix":            trimSuffix,
 		"trimSpace":             trimSpace,
 		"parseBool":             parseBool,
+		"parseCert":             parseCert,
+		"parseDuration":         parseDuration,
+		"durationSeconds":       durationSeconds,
 		"parseFloat":            parseFloat,
 		"parseInt":              parseInt,
 		"parseJSON":             parseJSON,
 		"parseUint":             parseUint,
 		"parseYAML":             parseYAML,
 		"plugin":                plugin,
+		"queryEscape":           queryEscape,
+		"queryUnescape":         queryUnescape,
+		"buildQuery":            buildQuery,
 		"regexReplaceAll":       regexReplaceAll,
 		"regexMatch":            regexMatch,
 		"replaceAll":            replaceAll,
 		"sha256Hex":             sha256Hex,
 		"md5sum":                md5sum,
 		"hmacSHA256Hex":         hmacSHA256Hex,
+		"hashMod":               hashMod,
+		"uuidv4":                uuidv4,
+		"uuidv5":                uuidv5,
 		"timestamp":             timestamp,
 		"toLower":               toLower,
 		"toJSON":                toJSON,
@@ -405,8 +500,11 @@ func funcMap(i *funcMapInput) template.FuncMap {
 		"split":                 split,
 		"splitToMap":            splitToMap,
 		"byMeta":                byMeta,
+		"sortByMeta":            sortByMeta,
+		"nodeHealthy":           nodeHealthy,
 		"sockaddr":              sockaddr,
 		"writeToFile":           writeToFile,
+		"writeBinary":           writeBinary,
 
 		// Math functions
 		"add":      add,
@@ -416,11 +514,18 @@ func funcMap(i *funcMapInput) template.FuncMap {
 		"modulo":   modulo,
 		"minimum":  minimum,
 		"maximum":  maximum,
+		"sum":      sum,
+		"sub":      sub,
+		"mul":      mul,
+		"div":      div,
+		"mod":      mod,
 		// Debug functions
 		"spew_dump":    spewDump,
 		"spew_printf":  spewPrintf,
 		"spew_sdump":   spewSdump,
 		"spew_sprintf": spewSprintf,
+		"dump":         dump,
+		"typeOf":       typeOf,
 	}
 
 	// Add the Sprig functions to the funcmap
@@ -5,10 +5,16 @@ package template
 
 import (
 	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
+	"hash"
 	"os"
 	"os/user"
+	"path/filepath"
 	"reflect"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,6 +22,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/hashicorp/consul-template/config"
 	dep "github.com/hashicorp/consul-template/dependency"
 	"github.com/hashicorp/consul/api"
 	"github.com/stretchr/testify/require"
@@ -301,6 +308,77 @@ func TestTemplate_Execute(t *testing.T) {
 			"[dc1 dc2]",
 			false,
 		},
+		{
+			"func_datacenters_exclude",
+			&NewTemplateInput{
+				Contents: `{{ datacenters false "dc2" }} {{ datacenters false (split "," "dc4,dc1") }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewCatalogDatacentersQuery(false)
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []string{"dc1", "dc2", "dc3", "dc4"})
+					return b
+				}(),
+			},
+			"[dc1 dc3 dc4] [dc2 dc3]",
+			false,
+		},
+		{
+			"func_datacenters_ignore_exclude",
+			&NewTemplateInput{
+				Contents: `{{ range datacenters true "dc-old" "dc-retired" }}{{ . }},{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewCatalogDatacentersQuery(true)
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []string{"dc-a", "dc-b", "dc-old", "dc-retired", "dc-z"})
+					return b
+				}(),
+			},
+			"dc-a,dc-b,dc-z,",
+			false,
+		},
+		{
+			"func_datacenters_bad_first_arg",
+			&NewTemplateInput{
+				Contents: `{{ datacenters "dc1" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"",
+			true,
+		},
+		{
+			"func_dependencyAge_missing",
+			&NewTemplateInput{
+				Contents: `{{ key "foo" }}{{ dependencyAge "kv.block(foo)" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"0s",
+			false,
+		},
+		{
+			"func_dependencyAge_unknown",
+			&NewTemplateInput{
+				Contents: `{{ dependencyAge "catalog.service(web)" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"",
+			true,
+		},
 		{
 			"func_envOrDefault",
 			&NewTemplateInput{
@@ -337,6 +415,96 @@ func TestTemplate_Execute(t *testing.T) {
 			"content",
 			false,
 		},
+		{
+			"func_fileBase64",
+			&NewTemplateInput{
+				Contents: `{{ fileBase64 "testdata/keytab.bin" }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewFileQuery("testdata/keytab.bin")
+					if err != nil {
+						t.Fatal(err)
+					}
+					data, err := os.ReadFile("testdata/keytab.bin")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, string(data))
+					return b
+				}(),
+			},
+			"BQIA//4QgA==",
+			false,
+		},
+		{
+			"func_fileBase64_missing",
+			&NewTemplateInput{
+				Contents: `{{ fileBase64 "testdata/missing.bin" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"",
+			true,
+		},
+		{
+			"func_fileBase64_sandbox_escape",
+			&NewTemplateInput{
+				Contents:    `{{ fileBase64 "testdata/keytab.bin" }}`,
+				SandboxPath: "testdata/sandbox",
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"",
+			true,
+		},
+		{
+			"func_file_sandbox",
+			&NewTemplateInput{
+				Contents:    `{{ file "testdata/sandbox/path/to/ok-symlink" }}`,
+				SandboxPath: "testdata/sandbox",
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewFileQuery("testdata/sandbox/path/to/ok-symlink")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, "content")
+					return b
+				}(),
+			},
+			"content",
+			false,
+		},
+		{
+			"func_file_sandbox_escape",
+			&NewTemplateInput{
+				Contents:    `{{ file "testdata/sandbox/path/to/bad-symlink" }}`,
+				SandboxPath: "testdata/sandbox",
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"",
+			true,
+		},
+		{
+			"func_file_sandbox_absolute_escape",
+			&NewTemplateInput{
+				Contents:    `{{ file "/etc/hosts" }}`,
+				SandboxPath: "testdata/sandbox",
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"",
+			true,
+		},
 		{
 			"func_key",
 			&NewTemplateInput{
@@ -396,1277 +564,3340 @@ func TestTemplate_Execute(t *testing.T) {
 			false,
 		},
 		{
-			"func_ls",
+			"func_httpGet",
 			&NewTemplateInput{
-				Contents: `{{ range ls "list" }}{{ .Key }}={{ .Value }}{{ end }}`,
+				Contents: `{{ httpGet "https://internal/api/config" }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewKVListQuery("list")
+					d, err := dep.NewHTTPGetQuery("https://internal/api/config")
 					if err != nil {
 						t.Fatal(err)
 					}
-					b.Remember(d, []*dep.KeyPair{
-						{Key: "", Value: ""},
-						{Key: "foo", Value: "bar"},
-						{Key: "foo/zip", Value: "zap"},
-					})
+					b.Remember(d, "hello")
 					return b
 				}(),
 			},
-			"foo=bar",
+			"hello",
 			false,
 		},
 		{
-			"func_node",
+			"func_httpGetJSON",
 			&NewTemplateInput{
-				Contents: `{{ with node }}{{ .Node.Node }}{{ range .Services }}{{ .Service }}{{ end }}{{ end }}`,
+				Contents: `{{ with httpGetJSON "https://internal/api/config" }}{{ .name }}:{{ index .ports 1 }}{{ end }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewCatalogNodeQuery("")
+					d, err := dep.NewHTTPGetQuery("https://internal/api/config")
 					if err != nil {
 						t.Fatal(err)
 					}
-					b.Remember(d, &dep.CatalogNode{
-						Node: &dep.Node{Node: "node1"},
-						Services: []*dep.CatalogNodeService{
-							{
-								Service: "service1",
-							},
-						},
-					})
+					b.Remember(d, `{"name": "web", "ports": [80, 443]}`)
 					return b
 				}(),
 			},
-			"node1service1",
-			false,
-		},
-		{
-			"func_node_nil_pointer_evaluation",
-			&NewTemplateInput{
-				Contents: `{{ $v := node }}{{ $v.Node }}`,
-			},
-			&ExecuteInput{
-				Brain: NewBrain(),
-			},
-			"<no value>",
+			"web:443",
 			false,
 		},
 		{
-			"func_nodes",
+			"func_httpGetJSON_invalid",
 			&NewTemplateInput{
-				Contents: `{{ range nodes }}{{ .Node }}{{ end }}`,
+				Contents: `{{ httpGetJSON "https://internal/api/config" }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewCatalogNodesQuery("")
+					d, err := dep.NewHTTPGetQuery("https://internal/api/config")
 					if err != nil {
 						t.Fatal(err)
 					}
-					b.Remember(d, []*dep.Node{
-						{Node: "node1"},
-						{Node: "node2"},
-					})
+					b.Remember(d, "not json")
 					return b
 				}(),
 			},
-			"node1node2",
-			false,
+			"",
+			true,
 		},
 		{
-			"func_peerings",
+			"func_keyGunzip",
 			&NewTemplateInput{
-				Contents: `{{ range peerings }}{{ .Name }}{{ end }}`,
+				Contents: `{{ keyGunzip "key" }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewListPeeringQuery("")
+					d, err := dep.NewKVGetQuery("key")
 					if err != nil {
 						t.Fatal(err)
 					}
-					b.Remember(d, []*dep.Peering{
-						{Name: "cluster-01"},
-						{Name: "cluster-02"},
-					})
+					d.EnableBlocking()
+					var buf bytes.Buffer
+					zw := gzip.NewWriter(&buf)
+					if _, err := zw.Write([]byte("large\nvalue")); err != nil {
+						t.Fatal(err)
+					}
+					if err := zw.Close(); err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, buf.String())
 					return b
 				}(),
 			},
-			"cluster-01cluster-02",
+			"large\nvalue",
 			false,
 		},
 		{
-			"func_secret_read",
+			"func_keyGunzip_not_gzip",
 			&NewTemplateInput{
-				Contents: `{{ with secret "secret/foo" }}{{ .Data.zip }}{{ end }}`,
+				Contents: `{{ keyGunzip "key" }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewVaultReadQuery("secret/foo")
+					d, err := dep.NewKVGetQuery("key")
 					if err != nil {
 						t.Fatal(err)
 					}
-					b.Remember(d, &dep.Secret{
-						LeaseID:       "abcd1234",
-						LeaseDuration: 120,
-						Renewable:     true,
-						Data:          map[string]interface{}{"zip": "zap"},
-					})
+					d.EnableBlocking()
+					b.Remember(d, "plain value")
 					return b
 				}(),
 			},
-			"zap",
-			false,
+			"",
+			true,
 		},
 		{
-			"func_secret_nil_pointer_evaluation",
+			"func_keyGunzip_missing",
 			&NewTemplateInput{
-				Contents: `{{ $v := secret "secret/foo" }}{{ $v.Data.zip }}`,
+				Contents: `{{ keyGunzip "key" }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"<no value>",
+			"",
 			false,
 		},
 		{
-			"func_secret_read_versions",
+			"func_kvTxn",
 			&NewTemplateInput{
-				Contents: `{{with secret "secret/foo"}}{{.Data.zip}}{{end}}:{{with secret "secret/foo?version=1"}}{{.Data.zip}}{{end}}`,
+				Contents: `{{ with kvTxn "app/host" "app/port" "app/missing" }}{{ index . "app/host" }}:{{ index . "app/port" }} {{ len . }}{{ end }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewVaultReadQuery("secret/foo")
-					if err != nil {
-						t.Fatal(err)
-					}
-					b.Remember(d, &dep.Secret{
-						Data: map[string]interface{}{"zip": "zap"},
-					})
-					d1, err := dep.NewVaultReadQuery("secret/foo?version=1")
+					d, err := dep.NewKVTxnQuery([]string{"app/host", "app/port", "app/missing"})
 					if err != nil {
 						t.Fatal(err)
 					}
-					b.Remember(d1, &dep.Secret{
-						Data: map[string]interface{}{"zip": "zed"},
+					b.Remember(d, map[string]string{
+						"app/host": "10.0.0.1",
+						"app/port": "8080",
 					})
 					return b
 				}(),
 			},
-			"zap:zed",
+			"10.0.0.1:8080 2",
 			false,
 		},
 		{
-			"func_secret_read_no_exist",
+			"func_kvTxn_list",
 			&NewTemplateInput{
-				Contents: `{{ with secret "secret/nope" }}{{ .Data.zip }}{{ end }}`,
+				Contents: `{{ range $k, $v := kvTxn (sprig_list "app/port") (split "," "app/host") }}{{ $k }}={{ $v }};{{ end }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
-					return NewBrain()
+					b := NewBrain()
+					d, err := dep.NewKVTxnQuery([]string{"app/host", "app/port"})
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, map[string]string{
+						"app/host": "10.0.0.1",
+						"app/port": "8080",
+					})
+					return b
 				}(),
 			},
-			"",
+			"app/host=10.0.0.1;app/port=8080;",
 			false,
 		},
 		{
-			"func_secret_read_no_exist_falsey",
+			"func_kvTxn_no_keys",
 			&NewTemplateInput{
-				Contents: `{{ if secret "secret/nope" }}yes{{ else }}no{{ end }}`,
+				Contents: `{{ kvTxn }}`,
 			},
 			&ExecuteInput{
-				Brain: func() *Brain {
-					return NewBrain()
-				}(),
+				Brain: NewBrain(),
 			},
-			"no",
-			false,
+			"",
+			true,
 		},
 		{
-			"func_secret_write",
+			"func_lockHolder",
 			&NewTemplateInput{
-				Contents: `{{ with secret "transit/encrypt/foo" "plaintext=a" }}{{ .Data.ciphertext }}{{ end }}`,
+				Contents: `{{ if eq (lockHolder "service/web/leader") "node1" }}active{{ else }}standby{{ end }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewVaultWriteQuery("transit/encrypt/foo", map[string]interface{}{
-						"plaintext": "a",
-					})
+					d, err := dep.NewKVLockHolderQuery("service/web/leader")
 					if err != nil {
 						t.Fatal(err)
 					}
-					b.Remember(d, &dep.Secret{
-						LeaseID:       "abcd1234",
-						LeaseDuration: 120,
-						Renewable:     true,
-						Data:          map[string]interface{}{"ciphertext": "encrypted"},
-					})
+					b.Remember(d, "node1")
 					return b
 				}(),
 			},
-			"encrypted",
+			"active",
 			false,
 		},
 		{
-			"func_secret_write_empty",
+			"func_lockHolder_unlocked",
 			&NewTemplateInput{
-				Contents: `{{ with secret "transit/encrypt/foo" "" }}{{ .Data.ciphertext }}{{ end }}`,
+				Contents: `[{{ lockHolder "service/web/leader" }}]`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewVaultWriteQuery("transit/encrypt/foo", nil)
+					d, err := dep.NewKVLockHolderQuery("service/web/leader")
 					if err != nil {
 						t.Fatal(err)
 					}
-					b.Remember(d, &dep.Secret{
-						Data: map[string]interface{}{"ciphertext": "encrypted"},
-					})
+					b.Remember(d, "")
 					return b
 				}(),
 			},
-			"encrypted",
+			"[]",
 			false,
 		},
 		{
-			"func_secret_write_no_exist",
+			"func_keyJSON",
 			&NewTemplateInput{
-				Contents: `{{ with secret "secret/nope" "a=b" }}{{ .Data.zip }}{{ end }}`,
-			},
-			&ExecuteInput{
-				Brain: func() *Brain {
-					return NewBrain()
-				}(),
-			},
-			"",
-			false,
-		},
-		{
-			"func_secret_write_no_exist_falsey",
-			&NewTemplateInput{
-				Contents: `{{ if secret "secret/nope" "a=b" }}yes{{ else }}no{{ end }}`,
+				Contents: `{{ with keyJSON "key" }}{{ .name }}:{{ index .ports 1 }}{{ end }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
-					return NewBrain()
+					b := NewBrain()
+					d, err := dep.NewKVGetQuery("key")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, `{"name": "web", "ports": [80, 443]}`)
+					return b
 				}(),
 			},
-			"no",
+			"web:443",
 			false,
 		},
 		{
-			"func_secret_no_exist_falsey_with",
+			"func_keyJSON_missing",
 			&NewTemplateInput{
-				Contents: `{{ with secret "secret/nope" }}{{ .Data.foo.bar }}{{ end }}`,
+				Contents: `{{ keyJSON "key" }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
-					return NewBrain()
+					b := NewBrain()
+					d, err := dep.NewKVGetQuery("key")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, nil)
+					return b
 				}(),
 			},
 			"",
-			false,
+			true,
 		},
 		{
-			"func_secrets",
+			"func_keyJSON_invalid",
 			&NewTemplateInput{
-				Contents: `{{ secrets "secret/" }}`,
+				Contents: `{{ keyJSON "key" }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewVaultListQuery("secret/")
+					d, err := dep.NewKVGetQuery("key")
 					if err != nil {
 						t.Fatal(err)
 					}
-					b.Remember(d, []string{"bar", "foo"})
+					b.Remember(d, `{"name": `)
 					return b
 				}(),
 			},
-			"[bar foo]",
-			false,
+			"",
+			true,
 		},
 		{
-			"func_secrets_no_exist",
+			"func_keyJSONOrDefault",
 			&NewTemplateInput{
-				Contents: `{{ secrets "secret/" }}`,
+				Contents: `{{ with keyJSONOrDefault "key" "" }}{{ .name }}{{ end }} {{ keyJSONOrDefault "no_key" "none" }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
-					return NewBrain()
+					b := NewBrain()
+					d, err := dep.NewKVGetQuery("key")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, `{"name": "web"}`)
+					d, err = dep.NewKVGetQuery("no_key")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, nil)
+					return b
 				}(),
 			},
-			"[]",
+			"web none",
 			false,
 		},
 		{
-			"func_secrets_no_exist_falsey",
+			"func_keyJSONOrDefault_invalid",
 			&NewTemplateInput{
-				Contents: `{{ if secrets "secret/" }}yes{{ else }}no{{ end }}`,
+				Contents: `{{ keyJSONOrDefault "key" "none" }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
-					return NewBrain()
+					b := NewBrain()
+					d, err := dep.NewKVGetQuery("key")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, `not json`)
+					return b
 				}(),
 			},
-			"no",
-			false,
+			"",
+			true,
 		},
 		{
-			"func_secrets_no_exist_falsey_with",
+			"func_ls",
 			&NewTemplateInput{
-				Contents: `{{ with secrets "secret/" }}{{ . }}{{ end }}`,
+				Contents: `{{ range ls "list" }}{{ .Key }}={{ .Value }}{{ end }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
-					return NewBrain()
+					b := NewBrain()
+					d, err := dep.NewKVListQuery("list")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.KeyPair{
+						{Key: "", Value: ""},
+						{Key: "foo", Value: "bar"},
+						{Key: "foo/zip", Value: "zap"},
+					})
+					return b
 				}(),
 			},
-			"",
+			"foo=bar",
 			false,
 		},
 		{
-			"func_service",
+			"func_node",
 			&NewTemplateInput{
-				Contents: `{{ range service "webapp" }}{{ .Address }}{{ end }}`,
+				Contents: `{{ with node }}{{ .Node.Node }}{{ range .Services }}{{ .Service }}{{ end }}{{ end }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewHealthServiceQuery("webapp")
+					d, err := dep.NewCatalogNodeQuery("")
 					if err != nil {
 						t.Fatal(err)
 					}
-					b.Remember(d, []*dep.HealthService{
-						{
-							Node:    "node1",
-							Address: "1.2.3.4",
-						},
-						{
-							Node:    "node2",
-							Address: "5.6.7.8",
+					b.Remember(d, &dep.CatalogNode{
+						Node: &dep.Node{Node: "node1"},
+						Services: []*dep.CatalogNodeService{
+							{
+								Service: "service1",
+							},
 						},
 					})
 					return b
 				}(),
 			},
-			"1.2.3.45.6.7.8",
+			"node1service1",
 			false,
 		},
 		{
-			"func_service_filter",
+			"func_node_nil_pointer_evaluation",
 			&NewTemplateInput{
-				Contents: `{{ range service "webapp" "passing,any" }}{{ .Address }}{{ end }}`,
+				Contents: `{{ $v := node }}{{ $v.Node }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"<no value>",
+			false,
+		},
+		{
+			"func_nodes",
+			&NewTemplateInput{
+				Contents: `{{ range nodes }}{{ .Node }}{{ end }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewHealthServiceQuery("webapp|passing,any")
+					d, err := dep.NewCatalogNodesQuery("")
 					if err != nil {
 						t.Fatal(err)
 					}
-					b.Remember(d, []*dep.HealthService{
-						{
-							Node:    "node1",
-							Address: "1.2.3.4",
-						},
-						{
-							Node:    "node2",
-							Address: "5.6.7.8",
-						},
+					b.Remember(d, []*dep.Node{
+						{Node: "node1"},
+						{Node: "node2"},
 					})
 					return b
 				}(),
 			},
-			"1.2.3.45.6.7.8",
+			"node1node2",
 			false,
 		},
 		{
-			"func_services",
+			"func_node_segment_coordinate",
 			&NewTemplateInput{
-				Contents: `{{ range services }}{{ .Name }}{{ end }}`,
+				Contents: `{{ nodeSegment }}: {{ with nodeCoordinate }}{{ index .Vec 0 }} {{ .Height }}{{ end }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewCatalogServicesQuery("")
+					d, err := dep.NewAgentSelfQuery()
 					if err != nil {
 						t.Fatal(err)
 					}
-					b.Remember(d, []*dep.CatalogSnippet{
-						{
-							Name: "service1",
-						},
-						{
-							Name: "service2",
+					b.Remember(d, &dep.AgentSelf{
+						Segment: "alpha",
+						Coordinate: &dep.NodeCoordinate{
+							Vec:    []float64{0.25, -0.5},
+							Height: 0.001,
 						},
 					})
 					return b
 				}(),
 			},
-			"service1service2",
+			"alpha: 0.25 0.001",
 			false,
 		},
 		{
-			"func_tree",
+			"func_node_segment_coordinate_missing",
 			&NewTemplateInput{
-				Contents: `{{ range tree "key" }}{{ .Key }}={{ .Value }}{{ end }}`,
+				Contents: `[{{ nodeSegment }}] {{ len nodeCoordinate.Vec }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"[] 0",
+			false,
+		},
+		{
+			"func_agentMetrics",
+			&NewTemplateInput{
+				Contents: `{{ range agentMetrics.Gauges }}{{ .Name }}={{ .Value }};{{ end }}{{ range agentMetrics.Counters }}{{ .Name }}={{ .Count }};{{ end }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewKVListQuery("key")
+					d, err := dep.NewAgentMetricsQuery()
 					if err != nil {
 						t.Fatal(err)
 					}
-					b.Remember(d, []*dep.KeyPair{
-						{Key: "", Value: ""},
-						{Key: "admin/port", Value: "1134"},
-						{Key: "maxconns", Value: "5"},
-						{Key: "minconns", Value: "2"},
+					b.Remember(d, &dep.AgentMetrics{
+						Gauges: []*dep.MetricGauge{
+							{Name: "consul.runtime.num_goroutines", Value: 42},
+						},
+						Counters: []*dep.MetricSample{
+							{Name: "consul.rpc.request", Count: 3},
+						},
 					})
 					return b
 				}(),
 			},
-			"admin/port=1134maxconns=5minconns=2",
+			"consul.runtime.num_goroutines=42;consul.rpc.request=3;",
 			false,
 		},
-
-		// scratch
 		{
-			"scratch.Key",
+			"func_agentMetrics_missing",
 			&NewTemplateInput{
-				Contents: `{{ scratch.Set "a" "2" }}{{ scratch.Key "a" }}`,
+				Contents: `{{ len agentMetrics.Gauges }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"true",
+			"0",
 			false,
 		},
 		{
-			"scratch.Get",
+			"func_checks",
 			&NewTemplateInput{
-				Contents: `{{ scratch.Set "a" "2" }}{{ scratch.Get "a" }}`,
+				Contents: `{{ range checks "critical@dc1" }}{{ .Node }}/{{ .ServiceName }}: {{ .Output }};{{ end }}`,
 			},
 			&ExecuteInput{
-				Brain: NewBrain(),
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthStateQuery("critical@dc1")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.HealthCheck{
+						{Node: "node1", ServiceName: "web", Output: "timeout"},
+						{Node: "node2", ServiceName: "db", Output: "refused"},
+					})
+					return b
+				}(),
 			},
-			"2",
+			"node1/web: timeout;node2/db: refused;",
 			false,
 		},
 		{
-			"scratch.SetX",
+			"func_checks_invalid_state",
 			&NewTemplateInput{
-				Contents: `{{ scratch.SetX "a" "2" }}{{ scratch.SetX "a" "1" }}{{ scratch.Get "a" }}`,
+				Contents: `{{ checks "failing" }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"2",
-			false,
+			"",
+			true,
 		},
 		{
-			"scratch.MapSet",
+			"func_intentions",
 			&NewTemplateInput{
-				Contents: `{{ scratch.MapSet "a" "foo" "bar" }}{{ scratch.MapValues "a" }}`,
+				Contents: `{{ range intentions "web@dc1" }}{{ .SourceName }}={{ or .Action "l7" }};{{ end }}`,
 			},
 			&ExecuteInput{
-				Brain: NewBrain(),
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewConnectIntentionsQuery("web@dc1")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.Intention{
+						{SourceName: "api", DestinationName: "web", Action: "deny"},
+						{SourceName: "*", DestinationName: "web", Permissions: 2},
+					})
+					return b
+				}(),
 			},
-			"[bar]",
+			"api=deny;*=l7;",
 			false,
 		},
 		{
-			"scratch.MapSetX",
+			"func_intentions_invalid",
 			&NewTemplateInput{
-				Contents: `{{ scratch.MapSetX "a" "foo" "bar" }}{{ scratch.MapSetX "a" "foo" "baz" }}{{ scratch.MapValues "a" }}`,
+				Contents: `{{ intentions "web?unsupported=foo" }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"[bar]",
-			false,
+			"",
+			true,
 		},
-
-		// helpers
 		{
-			"helper_by_key",
+			"func_peerings",
 			&NewTemplateInput{
-				Contents: `{{ range $key, $pairs := tree "list" | byKey }}{{ $key }}:{{ range $pairs }}{{ .Key }}={{ .Value }}{{ end }}{{ end }}`,
+				Contents: `{{ range peerings }}{{ .Name }}{{ end }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewKVListQuery("list")
+					d, err := dep.NewListPeeringQuery("")
 					if err != nil {
 						t.Fatal(err)
 					}
-					b.Remember(d, []*dep.KeyPair{
-						{Key: "", Value: ""},
-						{Key: "foo/bar", Value: "a"},
-						{Key: "zip/zap", Value: "b"},
+					b.Remember(d, []*dep.Peering{
+						{Name: "cluster-01"},
+						{Name: "cluster-02"},
 					})
 					return b
 				}(),
 			},
-			"foo:bar=azip:zap=b",
+			"cluster-01cluster-02",
 			false,
 		},
 		{
-			"helper_by_tag",
+			"func_secret_read",
 			&NewTemplateInput{
-				Contents: `{{ range $tag, $services := service "webapp" | byTag }}{{ $tag }}:{{ range $services }}{{ .Address }}{{ end }}{{ end }}`,
+				Contents: `{{ with secret "secret/foo" }}{{ .Data.zip }}{{ end }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewHealthServiceQuery("webapp")
+					d, err := dep.NewVaultReadQuery("secret/foo")
 					if err != nil {
 						t.Fatal(err)
 					}
-					b.Remember(d, []*dep.HealthService{
-						{
-							Address: "1.2.3.4",
-							Tags:    []string{"prod", "staging"},
-						},
-						{
-							Address: "5.6.7.8",
-							Tags:    []string{"staging"},
-						},
+					b.Remember(d, &dep.Secret{
+						LeaseID:       "abcd1234",
+						LeaseDuration: 120,
+						Renewable:     true,
+						Data:          map[string]interface{}{"zip": "zap"},
 					})
 					return b
 				}(),
 			},
-			"prod:1.2.3.4staging:1.2.3.45.6.7.8",
+			"zap",
 			false,
 		},
 		{
-			"helper_contains",
+			"func_secret_nil_pointer_evaluation",
 			&NewTemplateInput{
-				Contents: `{{ range service "webapp" }}{{ if .Tags | contains "prod" }}{{ .Address }}{{ end }}{{ end }}`,
+				Contents: `{{ $v := secret "secret/foo" }}{{ $v.Data.zip }}`,
 			},
 			&ExecuteInput{
-				Brain: func() *Brain {
-					b := NewBrain()
-					d, err := dep.NewHealthServiceQuery("webapp")
-					if err != nil {
-						t.Fatal(err)
-					}
-					b.Remember(d, []*dep.HealthService{
-						{
-							Address: "1.2.3.4",
-							Tags:    []string{"prod", "staging"},
-						},
-						{
-							Address: "5.6.7.8",
-							Tags:    []string{"staging"},
-						},
-					})
-					return b
-				}(),
+				Brain: NewBrain(),
 			},
-			"1.2.3.4",
+			"<no value>",
 			false,
 		},
 		{
-			"helper_containsAll",
+			"func_secret_read_versions",
 			&NewTemplateInput{
-				Contents: `{{ $requiredTags := parseJSON "[\"prod\",\"us-realm\"]" }}{{ range service "webapp" }}{{ if .Tags | containsAll $requiredTags }}{{ .Address }}{{ end }}{{ end }}`,
+				Contents: `{{with secret "secret/foo"}}{{.Data.zip}}{{end}}:{{with secret "secret/foo?version=1"}}{{.Data.zip}}{{end}}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewHealthServiceQuery("webapp")
+					d, err := dep.NewVaultReadQuery("secret/foo")
 					if err != nil {
 						t.Fatal(err)
 					}
-					b.Remember(d, []*dep.HealthService{
-						{
-							Address: "1.2.3.4",
-							Tags:    []string{"prod", "us-realm"},
-						},
-						{
-							Address: "5.6.7.8",
-							Tags:    []string{"prod", "ca-realm"},
-						},
+					b.Remember(d, &dep.Secret{
+						Data: map[string]interface{}{"zip": "zap"},
+					})
+					d1, err := dep.NewVaultReadQuery("secret/foo?version=1")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d1, &dep.Secret{
+						Data: map[string]interface{}{"zip": "zed"},
 					})
 					return b
 				}(),
 			},
-			"1.2.3.4",
+			"zap:zed",
 			false,
 		},
 		{
-			"helper_containsAll__empty",
+			"func_secret_read_no_exist",
 			&NewTemplateInput{
-				Contents: `{{ $requiredTags := parseJSON "[]" }}{{ range service "webapp" }}{{ if .Tags | containsAll $requiredTags }}{{ .Address }}{{ end }}{{ end }}`,
+				Contents: `{{ with secret "secret/nope" }}{{ .Data.zip }}{{ end }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
-					b := NewBrain()
-					d, err := dep.NewHealthServiceQuery("webapp")
-					if err != nil {
-						t.Fatal(err)
-					}
-					b.Remember(d, []*dep.HealthService{
-						{
-							Address: "1.2.3.4",
-							Tags:    []string{"prod", "us-realm"},
-						},
-						{
-							Address: "5.6.7.8",
-							Tags:    []string{"prod", "ca-realm"},
-						},
-					})
-					return b
+					return NewBrain()
 				}(),
 			},
-			"1.2.3.45.6.7.8",
+			"",
 			false,
 		},
 		{
-			"helper_containsAny",
+			"func_secret_read_no_exist_falsey",
 			&NewTemplateInput{
-				Contents: `{{ $acceptableTags := parseJSON "[\"v2\",\"v3\"]" }}{{ range service "webapp" }}{{ if .Tags | containsAny $acceptableTags }}{{ .Address }}{{ end }}{{ end }}`,
+				Contents: `{{ if secret "secret/nope" }}yes{{ else }}no{{ end }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
-					b := NewBrain()
-					d, err := dep.NewHealthServiceQuery("webapp")
-					if err != nil {
-						t.Fatal(err)
-					}
-					b.Remember(d, []*dep.HealthService{
-						{
-							Address: "1.2.3.4",
-							Tags:    []string{"prod", "v1"},
-						},
-						{
-							Address: "5.6.7.8",
-							Tags:    []string{"prod", "v2"},
-						},
-					})
-					return b
+					return NewBrain()
 				}(),
 			},
-			"5.6.7.8",
+			"no",
 			false,
 		},
 		{
-			"helper_containsAny__empty",
+			"func_secret_write",
 			&NewTemplateInput{
-				Contents: `{{ $acceptableTags := parseJSON "[]" }}{{ range service "webapp" }}{{ if .Tags | containsAny $acceptableTags }}{{ .Address }}{{ end }}{{ end }}`,
+				Contents: `{{ with secret "transit/encrypt/foo" "plaintext=a" }}{{ .Data.ciphertext }}{{ end }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewHealthServiceQuery("webapp")
+					d, err := dep.NewVaultWriteQuery("transit/encrypt/foo", map[string]interface{}{
+						"plaintext": "a",
+					})
 					if err != nil {
 						t.Fatal(err)
 					}
-					b.Remember(d, []*dep.HealthService{
-						{
-							Address: "1.2.3.4",
-							Tags:    []string{"prod", "v1"},
-						},
-						{
-							Address: "5.6.7.8",
-							Tags:    []string{"prod", "v2"},
-						},
+					b.Remember(d, &dep.Secret{
+						LeaseID:       "abcd1234",
+						LeaseDuration: 120,
+						Renewable:     true,
+						Data:          map[string]interface{}{"ciphertext": "encrypted"},
 					})
 					return b
 				}(),
 			},
-			"",
+			"encrypted",
 			false,
 		},
 		{
-			"helper_containsNone",
+			"func_secret_write_headers",
 			&NewTemplateInput{
-				Contents: `{{ $forbiddenTags := parseJSON "[\"devel\",\"staging\"]" }}{{ range service "webapp" }}{{ if .Tags | containsNone $forbiddenTags }}{{ .Address }}{{ end }}{{ end }}`,
+				Contents: `{{ with secret "transit/encrypt/foo" "plaintext=a" (sprig_dict "X-Vault-Wrap-TTL" "60s") }}{{ .WrapInfo.Token }}{{ end }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewHealthServiceQuery("webapp")
+					d, err := dep.NewVaultWriteQueryWithHeaders("transit/encrypt/foo",
+						map[string]interface{}{"plaintext": "a"},
+						map[string]string{"X-Vault-Wrap-TTL": "60s"})
 					if err != nil {
 						t.Fatal(err)
 					}
-					b.Remember(d, []*dep.HealthService{
-						{
-							Address: "1.2.3.4",
-							Tags:    []string{"prod", "v1"},
-						},
-						{
-							Address: "5.6.7.8",
-							Tags:    []string{"devel", "v2"},
-						},
+					b.Remember(d, &dep.Secret{
+						WrapInfo: &dep.SecretWrapInfo{Token: "wrapped"},
 					})
 					return b
 				}(),
 			},
-			"1.2.3.4",
+			"wrapped",
 			false,
 		},
 		{
-			"helper_containsNone__empty",
+			"func_secretWrapped",
 			&NewTemplateInput{
-				Contents: `{{ $forbiddenTags := parseJSON "[]" }}{{ range service "webapp" }}{{ if .Tags | containsNone $forbiddenTags }}{{ .Address }}{{ end }}{{ end }}`,
+				Contents: `{{ with secretWrapped "auth/token/create" (sprig_dict "policies" "default") "5m" }}{{ .Token }}:{{ .TTL }}:{{ .CreationPath }}{{ end }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewHealthServiceQuery("webapp")
+					d, err := dep.NewVaultWriteQueryWithHeaders("auth/token/create",
+						map[string]interface{}{"policies": "default"},
+						map[string]string{"X-Vault-Wrap-TTL": "5m"})
 					if err != nil {
 						t.Fatal(err)
 					}
-					b.Remember(d, []*dep.HealthService{
-						{
-							Address: "1.2.3.4",
-							Tags:    []string{"staging", "v1"},
-						},
-						{
-							Address: "5.6.7.8",
-							Tags:    []string{"devel", "v2"},
+					b.Remember(d, &dep.Secret{
+						WrapInfo: &dep.SecretWrapInfo{
+							Token:        "wrapped",
+							TTL:          300,
+							CreationPath: "auth/token/create",
 						},
 					})
 					return b
 				}(),
 			},
-			"1.2.3.45.6.7.8",
+			"wrapped:300:auth/token/create",
 			false,
 		},
 		{
-			"helper_containsNotAll",
+			"func_secretWrapped_not_wrapped",
 			&NewTemplateInput{
-				Contents: `{{ $excludingTags := parseJSON "[\"es-v1\",\"es-v2\"]" }}{{ range service "webapp" }}{{ if .Tags | containsNotAll $excludingTags }}{{ .Address }}{{ end }}{{ end }}`,
+				Contents: `{{ secretWrapped "auth/token/create" (sprig_dict) "5m" }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewHealthServiceQuery("webapp")
+					d, err := dep.NewVaultWriteQueryWithHeaders("auth/token/create",
+						map[string]interface{}{},
+						map[string]string{"X-Vault-Wrap-TTL": "5m"})
 					if err != nil {
 						t.Fatal(err)
 					}
-					b.Remember(d, []*dep.HealthService{
-						{
-							Address: "1.2.3.4",
-							Tags:    []string{"prod", "es-v1"},
-						},
-						{
-							Address: "5.6.7.8",
-							Tags:    []string{"prod", "hybrid", "es-v1", "es-v2"},
-						},
-					})
+					b.Remember(d, &dep.Secret{})
 					return b
 				}(),
 			},
-			"1.2.3.4",
-			false,
+			"",
+			true,
 		},
 		{
-			"helper_containsNotAll__empty",
+			"func_secretWrapped_missing_ttl",
 			&NewTemplateInput{
-				Contents: `{{ $excludingTags := parseJSON "[]" }}{{ range service "webapp" }}{{ if .Tags | containsNotAll $excludingTags }}{{ .Address }}{{ end }}{{ end }}`,
+				Contents: `{{ secretWrapped "auth/token/create" (sprig_dict) "" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"",
+			true,
+		},
+		{
+			"func_secret_write_headers_token",
+			&NewTemplateInput{
+				Contents: `{{ secret "transit/encrypt/foo" "plaintext=a" (sprig_dict "X-Vault-Token" "s.abcd") }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"",
+			true,
+		},
+		{
+			"func_secret_write_empty",
+			&NewTemplateInput{
+				Contents: `{{ with secret "transit/encrypt/foo" "" }}{{ .Data.ciphertext }}{{ end }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewHealthServiceQuery("webapp")
+					d, err := dep.NewVaultWriteQuery("transit/encrypt/foo", nil)
 					if err != nil {
 						t.Fatal(err)
 					}
-					b.Remember(d, []*dep.HealthService{
-						{
-							Address: "1.2.3.4",
-							Tags:    []string{"prod", "es-v1"},
-						},
-						{
-							Address: "5.6.7.8",
-							Tags:    []string{"prod", "hybrid", "es-v1", "es-v2"},
-						},
+					b.Remember(d, &dep.Secret{
+						Data: map[string]interface{}{"ciphertext": "encrypted"},
 					})
 					return b
 				}(),
 			},
-			"",
+			"encrypted",
 			false,
 		},
 		{
-			"helper_env",
+			"func_secret_write_no_exist",
 			&NewTemplateInput{
-				Contents: `{{ env "CT_TEST" }}`,
+				Contents: `{{ with secret "secret/nope" "a=b" }}{{ .Data.zip }}{{ end }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
-					// Cheat and use the brain callback here to set the env.
-					if err := os.Setenv("CT_TEST", "1"); err != nil {
-						t.Fatal(err)
-					}
 					return NewBrain()
 				}(),
 			},
-			"1",
+			"",
 			false,
 		},
 		{
-			"helper_mustEnv",
+			"func_secret_write_no_exist_falsey",
 			&NewTemplateInput{
-				Contents: `{{ mustEnv "CT_TEST" }}`,
+				Contents: `{{ if secret "secret/nope" "a=b" }}yes{{ else }}no{{ end }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
-					// Cheat and use the brain callback here to set the env.
-					if err := os.Setenv("CT_TEST", "1"); err != nil {
-						t.Fatal(err)
-					}
 					return NewBrain()
 				}(),
 			},
-			"1",
+			"no",
 			false,
 		},
 		{
-			"helper_mustEnv_negative",
+			"func_secret_no_exist_falsey_with",
 			&NewTemplateInput{
-				Contents: `{{ mustEnv "CT_TEST_NONEXISTENT" }}`,
+				Contents: `{{ with secret "secret/nope" }}{{ .Data.foo.bar }}{{ end }}`,
 			},
 			&ExecuteInput{
-				Brain: NewBrain(),
+				Brain: func() *Brain {
+					return NewBrain()
+				}(),
 			},
 			"",
-			true,
-		},
-		{
-			"helper_env__override",
-			&NewTemplateInput{
-				Contents: `{{ env "CT_TEST" }}`,
-			},
-			&ExecuteInput{
-				Env: []string{
-					"CT_TEST=2",
-				},
-				Brain: NewBrain(),
-			},
-			"2",
 			false,
 		},
 		{
-			"helper_executeTemplate",
+			"func_secrets",
 			&NewTemplateInput{
-				Contents: `{{ define "custom" }}{{ key "foo" }}{{ end }}{{ executeTemplate "custom" }}`,
+				Contents: `{{ secrets "secret/" }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewKVGetQuery("foo")
+					d, err := dep.NewVaultListQuery("secret/")
 					if err != nil {
 						t.Fatal(err)
 					}
-					d.EnableBlocking()
-					b.Remember(d, "bar")
+					b.Remember(d, []string{"bar", "foo"})
 					return b
 				}(),
 			},
-			"bar",
+			"[bar foo]",
 			false,
 		},
 		{
-			"helper_executeTemplate__dot",
+			"func_secrets_no_exist",
 			&NewTemplateInput{
-				Contents: `{{ define "custom" }}{{ key . }}{{ end }}{{ executeTemplate "custom" "foo" }}`,
+				Contents: `{{ secrets "secret/" }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
-					b := NewBrain()
-					d, err := dep.NewKVGetQuery("foo")
-					if err != nil {
-						t.Fatal(err)
+					return NewBrain()
+				}(),
+			},
+			"[]",
+			false,
+		},
+		{
+			"func_secrets_no_exist_falsey",
+			&NewTemplateInput{
+				Contents: `{{ if secrets "secret/" }}yes{{ else }}no{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					return NewBrain()
+				}(),
+			},
+			"no",
+			false,
+		},
+		{
+			"func_secrets_no_exist_falsey_with",
+			&NewTemplateInput{
+				Contents: `{{ with secrets "secret/" }}{{ . }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					return NewBrain()
+				}(),
+			},
+			"",
+			false,
+		},
+		{
+			"func_service",
+			&NewTemplateInput{
+				Contents: `{{ range service "webapp" }}{{ .Address }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthServiceQuery("webapp")
+					if err != nil {
+						t.Fatal(err)
 					}
-					d.EnableBlocking()
-					b.Remember(d, "bar")
+					b.Remember(d, []*dep.HealthService{
+						{
+							Node:    "node1",
+							Address: "1.2.3.4",
+						},
+						{
+							Node:    "node2",
+							Address: "5.6.7.8",
+						},
+					})
 					return b
 				}(),
 			},
-			"bar",
+			"1.2.3.45.6.7.8",
 			false,
 		},
 		{
-			"helper_mergeMap",
+			"func_renderGeneration",
 			&NewTemplateInput{
-				Contents: `{{ $base := "{\"voo\":{\"bar\":\"v\"}}" | parseJSON}}{{ $role := tree "list" | explode | mergeMap $base}}{{ range $k, $v := $role }}{{ $k }}{{ $v }}{{ end }}`,
+				Contents:    `generation {{ renderGeneration }}`,
+				Destination: "/tmp/out",
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewKVListQuery("list")
+					b.SetGeneration("/tmp/out", 7)
+					b.SetGeneration("/tmp/other", 2)
+					return b
+				}(),
+			},
+			"generation 7",
+			false,
+		},
+		{
+			"func_renderGeneration_unset",
+			&NewTemplateInput{
+				Contents:    `generation {{ renderGeneration }}`,
+				Destination: "/tmp/out",
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"generation 0",
+			false,
+		},
+		{
+			"func_prometheusTargets",
+			&NewTemplateInput{
+				Contents: `{{ range prometheusTargets "webapp" }}{{ index .Targets 0 }} {{ .Labels.service }} {{ .Labels.node }} {{ .Labels.tags }};{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthServiceQuery("webapp")
 					if err != nil {
 						t.Fatal(err)
 					}
-					b.Remember(d, []*dep.KeyPair{
-						{Key: "", Value: ""},
-						{Key: "foo/bar", Value: "a"},
-						{Key: "zip/zap", Value: "b"},
+					b.Remember(d, []*dep.HealthService{
+						{
+							Node:    "node1",
+							Name:    "webapp",
+							Address: "10.0.0.1",
+							Port:    8080,
+							Tags:    dep.ServiceTags{"primary", "v1"},
+						},
+						{
+							Node:    "node2",
+							Name:    "webapp",
+							Address: "2001:db8::1",
+							Port:    8080,
+						},
+					})
+					return b
+				}(),
+			},
+			"10.0.0.1:8080 webapp node1 ,primary,v1,;[2001:db8::1]:8080 webapp node2 ;",
+			false,
+		},
+		{
+			"func_prometheusTargets_labels",
+			&NewTemplateInput{
+				Contents: `{{ prometheusTargets "webapp" | toJSON }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthServiceQuery("webapp")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.HealthService{
+						{
+							Node:    "node1",
+							Name:    "webapp",
+							Address: "10.0.0.1",
+							Port:    9100,
+							ServiceMeta: map[string]string{
+								"env":          "prod",
+								"app.version":  "1.2",
+								"9lives":       "yes",
+								"__address__":  "evil:1",
+								"team-name/ui": "web",
+							},
+						},
+					})
+					return b
+				}(),
+			},
+			`[{"targets":["10.0.0.1:9100"],"labels":{"_9lives":"yes","_address__":"evil:1","app_version":"1.2","env":"prod","node":"node1","service":"webapp","team_name_ui":"web"}}]`,
+			false,
+		},
+		{
+			"func_prometheusTargets_yaml",
+			&NewTemplateInput{
+				Contents: `{{ prometheusTargets "webapp" | toYAML }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthServiceQuery("webapp")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.HealthService{
+						{
+							Node:    "node1",
+							Name:    "webapp",
+							Address: "10.0.0.1",
+							Port:    9100,
+						},
+					})
+					return b
+				}(),
+			},
+			"- targets:\n  - 10.0.0.1:9100\n  labels:\n    node: node1\n    service: webapp",
+			false,
+		},
+		{
+			"func_srvRecords",
+			&NewTemplateInput{
+				Contents: `{{ range srvRecords "webapp" }}{{ .Target }} {{ .Port }} {{ .Priority }} {{ .Weight }};{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthServiceQuery("webapp")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.HealthService{
+						{
+							Node:    "node1",
+							Port:    8080,
+							Status:  dep.HealthPassing,
+							Weights: api.AgentWeights{Passing: 10, Warning: 1},
+						},
+						{
+							Node:   "node2",
+							Port:   8081,
+							Status: dep.HealthPassing,
+						},
+					})
+					return b
+				}(),
+			},
+			"node1 8080 1 10;node2 8081 1 1;",
+			false,
+		},
+		{
+			"func_srvRecords_filter",
+			&NewTemplateInput{
+				Contents: `{{ range srvRecords "webapp" "any" }}{{ .Target }} {{ .Port }} {{ .Weight }};{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthServiceQuery("webapp|any")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.HealthService{
+						{
+							Node:    "node1",
+							Port:    8080,
+							Status:  dep.HealthPassing,
+							Weights: api.AgentWeights{Passing: 10, Warning: 2},
+						},
+						{
+							Node:    "node2",
+							Port:    8080,
+							Status:  dep.HealthWarning,
+							Weights: api.AgentWeights{Passing: 10, Warning: 2},
+						},
+						{
+							Node:    "node3",
+							Port:    8080,
+							Status:  dep.HealthCritical,
+							Weights: api.AgentWeights{Passing: 10, Warning: 2},
+						},
+					})
+					return b
+				}(),
+			},
+			"node1 8080 10;node2 8080 2;node3 8080 0;",
+			false,
+		},
+		{
+			"func_srvRecords_no_data",
+			&NewTemplateInput{
+				Contents: `{{ srvRecords "webapp" | len }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"0",
+			false,
+		},
+		{
+			"func_service_filter",
+			&NewTemplateInput{
+				Contents: `{{ range service "webapp" "passing,any" }}{{ .Address }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthServiceQuery("webapp|passing,any")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.HealthService{
+						{
+							Node:    "node1",
+							Address: "1.2.3.4",
+						},
+						{
+							Node:    "node2",
+							Address: "5.6.7.8",
+						},
+					})
+					return b
+				}(),
+			},
+			"1.2.3.45.6.7.8",
+			false,
+		},
+		{
+			"func_services",
+			&NewTemplateInput{
+				Contents: `{{ range services }}{{ .Name }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewCatalogServicesQuery("")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.CatalogSnippet{
+						{
+							Name: "service1",
+						},
+						{
+							Name: "service2",
+						},
 					})
 					return b
 				}(),
 			},
-			"foomap[bar:a]voomap[bar:v]zipmap[zap:b]",
+			"service1service2",
+			false,
+		},
+		{
+			"func_servicesDelta_first",
+			&NewTemplateInput{
+				Contents: `{{ with servicesDelta }}{{ .Added }}{{ .Removed }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewCatalogServicesQuery("")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.CatalogSnippet{
+						{Name: "service2"},
+						{Name: "service1"},
+					})
+					return b
+				}(),
+			},
+			"[service1 service2][]",
+			false,
+		},
+		{
+			"func_servicesDelta",
+			&NewTemplateInput{
+				Contents:    `{{ with servicesDelta }}{{ range .Added }}+{{ . }}{{ end }}{{ range .Removed }}-{{ . }}{{ end }}{{ end }}`,
+				Destination: "/tmp/out",
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewCatalogServicesQuery("")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.CatalogSnippet{
+						{Name: "service1"},
+						{Name: "service2"},
+					})
+					b.SetRendered("/tmp/out", []dep.Dependency{d})
+					b.Remember(d, []*dep.CatalogSnippet{
+						{Name: "service1"},
+						{Name: "service3"},
+					})
+					return b
+				}(),
+			},
+			"+service3-service2",
+			false,
+		},
+		{
+			"func_servicesDelta_missing",
+			&NewTemplateInput{
+				Contents: `{{ with servicesDelta "@dc1" }}{{ len .Added }}{{ len .Removed }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"00",
+			false,
+		},
+		{
+			"func_treeChanged_first",
+			&NewTemplateInput{
+				Contents: `{{ range treeChanged "key" }}{{ .Key }}={{ .Value }};{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewKVListQuery("key")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.KeyPair{
+						{Key: "", Value: "", ModifyIndex: 3},
+						{Key: "maxconns", Value: "5", ModifyIndex: 4},
+						{Key: "minconns", Value: "2", ModifyIndex: 5},
+					})
+					return b
+				}(),
+			},
+			"maxconns=5;minconns=2;",
+			false,
+		},
+		{
+			"func_treeChanged",
+			&NewTemplateInput{
+				Contents:    `{{ range treeChanged "key" }}{{ .Key }}={{ .Value }};{{ end }}`,
+				Destination: "/tmp/out",
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewKVListQuery("key")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.KeyPair{
+						{Key: "admin/port", Value: "1134", ModifyIndex: 4},
+						{Key: "maxconns", Value: "5", ModifyIndex: 5},
+						{Key: "minconns", Value: "2", ModifyIndex: 6},
+					})
+					b.SetRendered("/tmp/out", []dep.Dependency{d})
+					b.Remember(d, []*dep.KeyPair{
+						{Key: "admin/port", Value: "1134", ModifyIndex: 4},
+						{Key: "maxconns", Value: "10", ModifyIndex: 9},
+						{Key: "minconns", Value: "2", ModifyIndex: 6},
+					})
+					return b
+				}(),
+			},
+			"maxconns=10;",
+			false,
+		},
+		{
+			"func_treeChanged_missing",
+			&NewTemplateInput{
+				Contents: `{{ len (treeChanged "key") }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"0",
+			false,
+		},
+		{
+			"func_tree",
+			&NewTemplateInput{
+				Contents: `{{ range tree "key" }}{{ .Key }}={{ .Value }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewKVListQuery("key")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.KeyPair{
+						{Key: "", Value: ""},
+						{Key: "admin/port", Value: "1134"},
+						{Key: "maxconns", Value: "5"},
+						{Key: "minconns", Value: "2"},
+					})
+					return b
+				}(),
+			},
+			"admin/port=1134maxconns=5minconns=2",
+			false,
+		},
+		{
+			"func_treeMap",
+			&NewTemplateInput{
+				Contents: `{{ treeMap "key" | toJSON }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewKVListQuery("key")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.KeyPair{
+						{Key: "", Value: ""},
+						{Key: "admin/", Value: ""},
+						{Key: "admin/port", Value: "1134"},
+						{Key: "admin/tls/enabled", Value: "true"},
+						{Key: "db", Value: "primary"},
+						{Key: "db/replicas/east", Value: "2"},
+						{Key: "empty/", Value: ""},
+						{Key: "maxconns", Value: "5"},
+					})
+					return b
+				}(),
+			},
+			`{"admin":{"port":"1134","tls":{"enabled":"true"}},` +
+				`"db":{"_value":"primary","replicas":{"east":"2"}},` +
+				`"empty":{},"maxconns":"5"}`,
+			false,
+		},
+		{
+			"func_treeMap_value_key",
+			&NewTemplateInput{
+				Contents: `{{ with treeMap "key" "@" }}{{ index .db "@" }} {{ .db.replicas.east }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewKVListQuery("key")
+					if err != nil {
+						t.Fatal(err)
+					}
+					// The nested key comes first, the value is still kept.
+					b.Remember(d, []*dep.KeyPair{
+						{Key: "db/replicas/east", Value: "2"},
+						{Key: "db", Value: "primary"},
+					})
+					return b
+				}(),
+			},
+			"primary 2",
+			false,
+		},
+		{
+			"func_treeMap_missing",
+			&NewTemplateInput{
+				Contents: `{{ treeMap "key" | len }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"0",
+			false,
+		},
+
+		// scratch
+		{
+			"scratch.Key",
+			&NewTemplateInput{
+				Contents: `{{ scratch.Set "a" "2" }}{{ scratch.Key "a" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"true",
+			false,
+		},
+		{
+			"scratch.Get",
+			&NewTemplateInput{
+				Contents: `{{ scratch.Set "a" "2" }}{{ scratch.Get "a" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"2",
+			false,
+		},
+		{
+			"scratch.SetX",
+			&NewTemplateInput{
+				Contents: `{{ scratch.SetX "a" "2" }}{{ scratch.SetX "a" "1" }}{{ scratch.Get "a" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"2",
+			false,
+		},
+		{
+			"scratch.MapSet",
+			&NewTemplateInput{
+				Contents: `{{ scratch.MapSet "a" "foo" "bar" }}{{ scratch.MapValues "a" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"[bar]",
+			false,
+		},
+		{
+			"scratch.MapSetX",
+			&NewTemplateInput{
+				Contents: `{{ scratch.MapSetX "a" "foo" "bar" }}{{ scratch.MapSetX "a" "foo" "baz" }}{{ scratch.MapValues "a" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"[bar]",
+			false,
+		},
+
+		// helpers
+		{
+			"helper_by_key",
+			&NewTemplateInput{
+				Contents: `{{ range $key, $pairs := tree "list" | byKey }}{{ $key }}:{{ range $pairs }}{{ .Key }}={{ .Value }}{{ end }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewKVListQuery("list")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.KeyPair{
+						{Key: "", Value: ""},
+						{Key: "foo/bar", Value: "a"},
+						{Key: "zip/zap", Value: "b"},
+					})
+					return b
+				}(),
+			},
+			"foo:bar=azip:zap=b",
+			false,
+		},
+		{
+			"helper_by_tag",
+			&NewTemplateInput{
+				Contents: `{{ range $tag, $services := service "webapp" | byTag }}{{ $tag }}:{{ range $services }}{{ .Address }}{{ end }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthServiceQuery("webapp")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.HealthService{
+						{
+							Address: "1.2.3.4",
+							Tags:    []string{"prod", "staging"},
+						},
+						{
+							Address: "5.6.7.8",
+							Tags:    []string{"staging"},
+						},
+					})
+					return b
+				}(),
+			},
+			"prod:1.2.3.4staging:1.2.3.45.6.7.8",
+			false,
+		},
+		{
+			"helper_allTags",
+			&NewTemplateInput{
+				Contents: `{{ service "webapp" | allTags | join "," }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthServiceQuery("webapp")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.HealthService{
+						{
+							Address: "1.2.3.4",
+							Tags:    []string{"staging", "prod", "v2"},
+						},
+						{
+							Address: "5.6.7.8",
+							Tags:    []string{"v2", "canary", "staging"},
+						},
+						{
+							Address: "9.10.11.12",
+						},
+					})
+					return b
+				}(),
+			},
+			"canary,prod,staging,v2",
+			false,
+		},
+		{
+			"helper_allTags_empty",
+			&NewTemplateInput{
+				Contents: `{{ $tags := service "webapp" | allTags }}{{ len $tags }}{{ printf "%#v" $tags }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthServiceQuery("webapp")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.HealthService{})
+					return b
+				}(),
+			},
+			"0[]string{}",
+			false,
+		},
+		{
+			"helper_contains",
+			&NewTemplateInput{
+				Contents: `{{ range service "webapp" }}{{ if .Tags | contains "prod" }}{{ .Address }}{{ end }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthServiceQuery("webapp")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.HealthService{
+						{
+							Address: "1.2.3.4",
+							Tags:    []string{"prod", "staging"},
+						},
+						{
+							Address: "5.6.7.8",
+							Tags:    []string{"staging"},
+						},
+					})
+					return b
+				}(),
+			},
+			"1.2.3.4",
+			false,
+		},
+		{
+			"helper_containsAll",
+			&NewTemplateInput{
+				Contents: `{{ $requiredTags := parseJSON "[\"prod\",\"us-realm\"]" }}{{ range service "webapp" }}{{ if .Tags | containsAll $requiredTags }}{{ .Address }}{{ end }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthServiceQuery("webapp")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.HealthService{
+						{
+							Address: "1.2.3.4",
+							Tags:    []string{"prod", "us-realm"},
+						},
+						{
+							Address: "5.6.7.8",
+							Tags:    []string{"prod", "ca-realm"},
+						},
+					})
+					return b
+				}(),
+			},
+			"1.2.3.4",
+			false,
+		},
+		{
+			"helper_containsAll__empty",
+			&NewTemplateInput{
+				Contents: `{{ $requiredTags := parseJSON "[]" }}{{ range service "webapp" }}{{ if .Tags | containsAll $requiredTags }}{{ .Address }}{{ end }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthServiceQuery("webapp")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.HealthService{
+						{
+							Address: "1.2.3.4",
+							Tags:    []string{"prod", "us-realm"},
+						},
+						{
+							Address: "5.6.7.8",
+							Tags:    []string{"prod", "ca-realm"},
+						},
+					})
+					return b
+				}(),
+			},
+			"1.2.3.45.6.7.8",
+			false,
+		},
+		{
+			"helper_containsAny",
+			&NewTemplateInput{
+				Contents: `{{ $acceptableTags := parseJSON "[\"v2\",\"v3\"]" }}{{ range service "webapp" }}{{ if .Tags | containsAny $acceptableTags }}{{ .Address }}{{ end }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthServiceQuery("webapp")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.HealthService{
+						{
+							Address: "1.2.3.4",
+							Tags:    []string{"prod", "v1"},
+						},
+						{
+							Address: "5.6.7.8",
+							Tags:    []string{"prod", "v2"},
+						},
+					})
+					return b
+				}(),
+			},
+			"5.6.7.8",
+			false,
+		},
+		{
+			"helper_containsAny__empty",
+			&NewTemplateInput{
+				Contents: `{{ $acceptableTags := parseJSON "[]" }}{{ range service "webapp" }}{{ if .Tags | containsAny $acceptableTags }}{{ .Address }}{{ end }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthServiceQuery("webapp")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.HealthService{
+						{
+							Address: "1.2.3.4",
+							Tags:    []string{"prod", "v1"},
+						},
+						{
+							Address: "5.6.7.8",
+							Tags:    []string{"prod", "v2"},
+						},
+					})
+					return b
+				}(),
+			},
+			"",
+			false,
+		},
+		{
+			"helper_containsNone",
+			&NewTemplateInput{
+				Contents: `{{ $forbiddenTags := parseJSON "[\"devel\",\"staging\"]" }}{{ range service "webapp" }}{{ if .Tags | containsNone $forbiddenTags }}{{ .Address }}{{ end }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthServiceQuery("webapp")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.HealthService{
+						{
+							Address: "1.2.3.4",
+							Tags:    []string{"prod", "v1"},
+						},
+						{
+							Address: "5.6.7.8",
+							Tags:    []string{"devel", "v2"},
+						},
+					})
+					return b
+				}(),
+			},
+			"1.2.3.4",
+			false,
+		},
+		{
+			"helper_containsNone__empty",
+			&NewTemplateInput{
+				Contents: `{{ $forbiddenTags := parseJSON "[]" }}{{ range service "webapp" }}{{ if .Tags | containsNone $forbiddenTags }}{{ .Address }}{{ end }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthServiceQuery("webapp")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.HealthService{
+						{
+							Address: "1.2.3.4",
+							Tags:    []string{"staging", "v1"},
+						},
+						{
+							Address: "5.6.7.8",
+							Tags:    []string{"devel", "v2"},
+						},
+					})
+					return b
+				}(),
+			},
+			"1.2.3.45.6.7.8",
+			false,
+		},
+		{
+			"helper_containsNotAll",
+			&NewTemplateInput{
+				Contents: `{{ $excludingTags := parseJSON "[\"es-v1\",\"es-v2\"]" }}{{ range service "webapp" }}{{ if .Tags | containsNotAll $excludingTags }}{{ .Address }}{{ end }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthServiceQuery("webapp")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.HealthService{
+						{
+							Address: "1.2.3.4",
+							Tags:    []string{"prod", "es-v1"},
+						},
+						{
+							Address: "5.6.7.8",
+							Tags:    []string{"prod", "hybrid", "es-v1", "es-v2"},
+						},
+					})
+					return b
+				}(),
+			},
+			"1.2.3.4",
+			false,
+		},
+		{
+			"helper_containsNotAll__empty",
+			&NewTemplateInput{
+				Contents: `{{ $excludingTags := parseJSON "[]" }}{{ range service "webapp" }}{{ if .Tags | containsNotAll $excludingTags }}{{ .Address }}{{ end }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthServiceQuery("webapp")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.HealthService{
+						{
+							Address: "1.2.3.4",
+							Tags:    []string{"prod", "es-v1"},
+						},
+						{
+							Address: "5.6.7.8",
+							Tags:    []string{"prod", "hybrid", "es-v1", "es-v2"},
+						},
+					})
+					return b
+				}(),
+			},
+			"",
+			false,
+		},
+		{
+			"helper_env",
+			&NewTemplateInput{
+				Contents: `{{ env "CT_TEST" }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					// Cheat and use the brain callback here to set the env.
+					if err := os.Setenv("CT_TEST", "1"); err != nil {
+						t.Fatal(err)
+					}
+					return NewBrain()
+				}(),
+			},
+			"1",
+			false,
+		},
+		{
+			"helper_mustEnv",
+			&NewTemplateInput{
+				Contents: `{{ mustEnv "CT_TEST" }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					// Cheat and use the brain callback here to set the env.
+					if err := os.Setenv("CT_TEST", "1"); err != nil {
+						t.Fatal(err)
+					}
+					return NewBrain()
+				}(),
+			},
+			"1",
+			false,
+		},
+		{
+			"helper_mustEnv_negative",
+			&NewTemplateInput{
+				Contents: `{{ mustEnv "CT_TEST_NONEXISTENT" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"",
+			true,
+		},
+		{
+			"helper_env__override",
+			&NewTemplateInput{
+				Contents: `{{ env "CT_TEST" }}`,
+			},
+			&ExecuteInput{
+				Env: []string{
+					"CT_TEST=2",
+				},
+				Brain: NewBrain(),
+			},
+			"2",
+			false,
+		},
+		{
+			"helper_executeTemplate",
+			&NewTemplateInput{
+				Contents: `{{ define "custom" }}{{ key "foo" }}{{ end }}{{ executeTemplate "custom" }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewKVGetQuery("foo")
+					if err != nil {
+						t.Fatal(err)
+					}
+					d.EnableBlocking()
+					b.Remember(d, "bar")
+					return b
+				}(),
+			},
+			"bar",
+			false,
+		},
+		{
+			"helper_executeTemplate__dot",
+			&NewTemplateInput{
+				Contents: `{{ define "custom" }}{{ key . }}{{ end }}{{ executeTemplate "custom" "foo" }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewKVGetQuery("foo")
+					if err != nil {
+						t.Fatal(err)
+					}
+					d.EnableBlocking()
+					b.Remember(d, "bar")
+					return b
+				}(),
+			},
+			"bar",
+			false,
+		},
+		{
+			"helper_mergeMap",
+			&NewTemplateInput{
+				Contents: `{{ $base := "{\"voo\":{\"bar\":\"v\"}}" | parseJSON}}{{ $role := tree "list" | explode | mergeMap $base}}{{ range $k, $v := $role }}{{ $k }}{{ $v }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewKVListQuery("list")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.KeyPair{
+						{Key: "", Value: ""},
+						{Key: "foo/bar", Value: "a"},
+						{Key: "zip/zap", Value: "b"},
+					})
+					return b
+				}(),
+			},
+			"foomap[bar:a]voomap[bar:v]zipmap[zap:b]",
+			false,
+		},
+		{
+			"helper_filterKeys",
+			&NewTemplateInput{
+				Contents: `{{ $m := "{\"app_name\":\"web\",\"app_port\":8080,\"db_name\":\"pg\"}" | parseJSON }}{{ filterKeys $m "^app_" | toJSON }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			`{"app_name":"web","app_port":8080}`,
+			false,
+		},
+		{
+			"helper_filterKeys_no_match",
+			&NewTemplateInput{
+				Contents: `{{ $m := "{\"db_name\":\"pg\"}" | parseJSON }}{{ filterKeys $m "^app_" | toJSON }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			`{}`,
+			false,
+		},
+		{
+			"helper_filterKeys_nil",
+			&NewTemplateInput{
+				Contents: `{{ filterKeys nil "^app_" | toJSON }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			`{}`,
+			false,
+		},
+		{
+			"helper_filterKeys_invalid",
+			&NewTemplateInput{
+				Contents: `{{ $m := "{\"app_name\":\"web\"}" | parseJSON }}{{ filterKeys $m "app_(" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"",
+			true,
+		},
+		{
+			"helper_mapDiff_added",
+			&NewTemplateInput{
+				Contents: `{{ $old := "{\"a\":\"1\"}" | parseJSON }}{{ "{\"a\":\"1\",\"b\":\"2\"}" | parseJSON | mapDiff $old | toJSON }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			`{"b":"2"}`,
+			false,
+		},
+		{
+			"helper_mapDiff_changed",
+			&NewTemplateInput{
+				Contents: `{{ $old := "{\"a\":\"1\",\"b\":\"2\"}" | parseJSON }}{{ "{\"a\":\"1\",\"b\":\"3\"}" | parseJSON | mapDiff $old | toJSON }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			`{"b":"3"}`,
+			false,
+		},
+		{
+			"helper_mapDiff_deleted",
+			&NewTemplateInput{
+				Contents: `{{ $old := "{\"a\":\"1\",\"b\":\"2\"}" | parseJSON }}{{ "{\"a\":\"1\"}" | parseJSON | mapDiff $old | toJSON }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			`{"b":null}`,
+			false,
+		},
+		{
+			"helper_mapDiff_unchanged",
+			&NewTemplateInput{
+				Contents: `{{ $old := "{\"a\":\"1\",\"b\":[1,2]}" | parseJSON }}{{ "{\"a\":\"1\",\"b\":[1,2]}" | parseJSON | mapDiff $old | toJSON }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			`{}`,
+			false,
+		},
+		{
+			"helper_mapDiff_nested",
+			&NewTemplateInput{
+				Contents: `{{ $old := "{\"db\":{\"host\":\"a\",\"port\":5432,\"user\":\"x\"},\"log\":{\"level\":\"info\"}}" | parseJSON }}{{ "{\"db\":{\"host\":\"b\",\"port\":5432},\"log\":{\"level\":\"info\"}}" | parseJSON | mapDiff $old | toJSON }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			`{"db":{"host":"b","user":null}}`,
+			false,
+		},
+		{
+			"helper_setUnion",
+			&NewTemplateInput{
+				Contents: `{{ $a := "b,a,c,a" | split "," }}{{ $b := "d,c" | split "," }}{{ setUnion $a $b | join "," }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"a,b,c,d",
+			false,
+		},
+		{
+			"helper_setUnion_disjoint",
+			&NewTemplateInput{
+				Contents: `{{ $a := "b,a" | split "," }}{{ $b := "d,c" | split "," }}{{ setUnion $a $b | join "," }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"a,b,c,d",
+			false,
+		},
+		{
+			"helper_setUnion_nil",
+			&NewTemplateInput{
+				Contents: `{{ $a := "b,a,b" | split "," }}{{ setUnion $a nil | join "," }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"a,b",
+			false,
+		},
+		{
+			"helper_setUnion_ints",
+			&NewTemplateInput{
+				Contents: `{{ setUnion (sprig_list 3 1 2) (sprig_list 10 2) }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"[1 2 3 10]",
+			false,
+		},
+		{
+			"helper_setUnion_mixed",
+			&NewTemplateInput{
+				Contents: `{{ setUnion (sprig_list "a") (sprig_list 1) }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"",
+			true,
+		},
+		{
+			"helper_setIntersect",
+			&NewTemplateInput{
+				Contents: `{{ $a := "b,a,c,a" | split "," }}{{ $b := "d,c,a" | split "," }}{{ setIntersect $a $b | join "," }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"a,c",
+			false,
+		},
+		{
+			"helper_setIntersect_disjoint",
+			&NewTemplateInput{
+				Contents: `{{ $a := "b,a" | split "," }}{{ $b := "d,c" | split "," }}{{ setIntersect $a $b | len }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"0",
+			false,
+		},
+		{
+			"helper_setIntersect_nil",
+			&NewTemplateInput{
+				Contents: `{{ $a := "b,a" | split "," }}{{ setIntersect nil $a | len }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"0",
+			false,
+		},
+		{
+			"helper_setDifference",
+			&NewTemplateInput{
+				Contents: `{{ $desired := "web,api,db,api" | split "," }}{{ $actual := "db,cache" | split "," }}{{ setDifference $desired $actual | join "," }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"api,web",
+			false,
+		},
+		{
+			"helper_setDifference_disjoint",
+			&NewTemplateInput{
+				Contents: `{{ $a := "b,a" | split "," }}{{ $b := "d,c" | split "," }}{{ setDifference $a $b | join "," }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"a,b",
+			false,
+		},
+		{
+			"helper_setDifference_nil",
+			&NewTemplateInput{
+				Contents: `{{ $a := "b,a" | split "," }}{{ setDifference nil $a | len }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"0",
+			false,
+		},
+		{
+			"helper_setDifference_invalid",
+			&NewTemplateInput{
+				Contents: `{{ setDifference "a" "b" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"",
+			true,
+		},
+		{
+			"helper_mapDiff_type_change",
+			&NewTemplateInput{
+				Contents: `{{ $old := "{\"a\":{\"b\":\"1\"}}" | parseJSON }}{{ "{\"a\":\"1\"}" | parseJSON | mapDiff $old | toJSON }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			`{"a":"1"}`,
+			false,
+		},
+		{
+			"helper_mergeMapWithOverride",
+			&NewTemplateInput{
+				Contents: `{{ $base := "{\"zip\":{\"zap\":\"t\"},\"voo\":{\"bar\":\"v\"}}" | parseJSON}}{{ $role := tree "list" | explode | mergeMapWithOverride $base}}{{ range $k, $v := $role }}{{ $k }}{{ $v }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewKVListQuery("list")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.KeyPair{
+						{Key: "", Value: ""},
+						{Key: "foo/bar", Value: "a"},
+						{Key: "zip/zap", Value: "b"},
+					})
+					return b
+				}(),
+			},
+			"foomap[bar:a]voomap[bar:v]zipmap[zap:b]",
+			false,
+		},
+		{
+			"helper_explode",
+			&NewTemplateInput{
+				Contents: `{{ range $k, $v := tree "list" | explode }}{{ $k }}{{ $v }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewKVListQuery("list")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.KeyPair{
+						{Key: "", Value: ""},
+						{Key: "foo/bar", Value: "a"},
+						{Key: "zip/zap", Value: "b"},
+					})
+					return b
+				}(),
+			},
+			"foomap[bar:a]zipmap[zap:b]",
+			false,
+		},
+		{
+			"helper_explodemap",
+			&NewTemplateInput{
+				Contents: `{{ scratch.MapSet "explode-test" "foo/bar" "a"}}{{ scratch.MapSet "explode-test" "qux" "c"}}{{ scratch.MapSet "explode-test" "zip/zap" "d"}}{{ scratch.Get "explode-test" | explodeMap }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"map[foo:map[bar:a] qux:c zip:map[zap:d]]",
+			false,
+		},
+		{
+			"helper_failIf",
+			&NewTemplateInput{
+				Contents: `before{{ failIf (eq 1 1) "ports collide" }}after`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"",
+			true,
+		},
+		{
+			"helper_failIf__falsy",
+			&NewTemplateInput{
+				Contents: `before{{ failIf (eq 1 2) "ports collide" }}{{ failIf "" "empty" }}{{ failIf (list) "empty" }}after`,
+				ExtFuncMap: map[string]interface{}{
+					"list": func() []string { return nil },
+				},
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"beforeafter",
+			false,
+		},
+		{
+			"helper_typeOf",
+			&NewTemplateInput{
+				Contents: `{{ typeOf "a" }} {{ typeOf 1 }} {{ typeOf (parseJSON "{}") }} {{ typeOf (split "," "a,b") }} {{ typeOf nil }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"string int map[string]interface {} []string <nil>",
+			false,
+		},
+		{
+			"helper_dump",
+			&NewTemplateInput{
+				Contents: `{{ parseJSON "{\"db\":{\"user\":\"admin\",\"password\":\"hunter2\",\"ports\":[1,2]},\"api_token\":\"abc\"}" | dump }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"map[string]interface {}{\n" +
+				"  \"api_token\": [redacted],\n" +
+				"  \"db\": map[string]interface {}{\n" +
+				"    \"password\": [redacted],\n" +
+				"    \"ports\": []interface {}{\n" +
+				"      1,\n" +
+				"      2,\n" +
+				"    },\n" +
+				"    \"user\": \"admin\",\n" +
+				"  },\n" +
+				"}",
+			false,
+		},
+		{
+			"helper_dump_struct",
+			&NewTemplateInput{
+				Contents: `{{ with secret "secret/foo" }}{{ dump .Auth }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewVaultReadQuery("secret/foo")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, &dep.Secret{
+						Auth: &dep.SecretAuth{ClientToken: "s.xyz", Policies: []string{"default"}},
+					})
+					return b
+				}(),
+			},
+			"&dependency.SecretAuth{\n" +
+				"  ClientToken: [redacted],\n" +
+				"  Accessor: \"\",\n" +
+				"  Policies: []string{\n" +
+				"    \"default\",\n" +
+				"  },\n" +
+				"  Metadata: <nil>,\n" +
+				"  LeaseDuration: 0,\n" +
+				"  Renewable: false,\n" +
+				"}",
+			false,
+		},
+		{
+			"helper_in",
+			&NewTemplateInput{
+				Contents: `{{ range service "webapp" }}{{ if "prod" | in .Tags }}{{ .Address }}{{ end }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthServiceQuery("webapp")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.HealthService{
+						{
+							Address: "1.2.3.4",
+							Tags:    []string{"prod", "staging"},
+						},
+						{
+							Address: "5.6.7.8",
+							Tags:    []string{"staging"},
+						},
+					})
+					return b
+				}(),
+			},
+			"1.2.3.4",
+			false,
+		},
+		{
+			"helper_indent",
+			&NewTemplateInput{
+				Contents: `{{ "hello\nhello\r\nHELLO\r\nhello\nHELLO" | indent 4 }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"    hello\n    hello\r\n    HELLO\r\n    hello\n    HELLO",
+			false,
+		},
+		{
+			"helper_indent_negative",
+			&NewTemplateInput{
+				Contents: `{{ "hello\nhello\r\nHELLO\r\nhello\nHELLO" | indent -4 }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"    hello\n    hello\r\n    HELLO\r\n    hello\n    HELLO",
+			true,
+		},
+		{
+			"helper_indent_zero",
+			&NewTemplateInput{
+				Contents: `{{ "hello\nhello\r\nHELLO\r\nhello\nHELLO" | indent 0 }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"hello\nhello\r\nHELLO\r\nhello\nHELLO",
+			false,
+		},
+		{
+			"helper_loop",
+			&NewTemplateInput{
+				Contents: `{{ range loop 3 }}1{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"111",
+			false,
+		},
+		{
+			"helper_loop__i",
+			&NewTemplateInput{
+				Contents: `{{ range $i := loop 3 }}{{ $i }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"012",
+			false,
+		},
+		{
+			"helper_loop_start",
+			&NewTemplateInput{
+				Contents: `{{ range loop 1 3 }}1{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"11",
+			false,
+		},
+		{
+			"helper_loop_step",
+			&NewTemplateInput{
+				Contents: `{{ range $i := loop 8000 8010 2 }}{{ $i }} {{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"8000 8002 8004 8006 8008 ",
+			false,
+		},
+		{
+			"helper_loop_step_negative",
+			&NewTemplateInput{
+				Contents: `{{ range $i := loop 5 0 -2 }}{{ $i }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"531",
+			false,
+		},
+		{
+			"helper_loop_step_zero",
+			&NewTemplateInput{
+				Contents: `{{ range loop 1 3 0 }}1{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"",
+			true,
+		},
+		{
+			"helper_loop_text",
+			&NewTemplateInput{
+				Contents: `{{ range loop 1 "3" }}1{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"11",
+			false,
+		},
+		{
+			"helper_loop_parseInt",
+			&NewTemplateInput{
+				Contents: `{{ $i := print "3" | parseInt }}{{ range loop 1 $i }}1{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"11",
+			false,
+		},
+		{
+			// GH-1143
+			"helper_loop_var",
+			&NewTemplateInput{
+				Contents: `{{$n := 3 }}` +
+					`{{ range $i := loop $n }}{{ $i }}{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"012",
+			false,
+		},
+		{
+			"helper_join",
+			&NewTemplateInput{
+				Contents: `{{ "a,b,c" | split "," | join ";" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"a;b;c",
+			false,
+		},
+		{
+			"helper_jsonEscape",
+			&NewTemplateInput{
+				Contents: `{"value": "{{ "say \"hi\"\n" | jsonEscape }}"}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			`{"value": "say \"hi\"\n"}`,
+			false,
+		},
+		{
+			"helper_trim",
+			&NewTemplateInput{
+				Contents: `{{ "!!hello world!!" | trim "!!" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"hello world",
+			false,
+		},
+		{
+			"helper_trimPrefix",
+			&NewTemplateInput{
+				Contents: `{{ "hello world!!" | trimPrefix "hello " }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"world!!",
+			false,
+		},
+		{
+			"helper_trimSuffix",
+			&NewTemplateInput{
+				Contents: `{{ "hello world!!" | trimSuffix " world!!" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"hello",
+			false,
+		},
+		{
+			"helper_parseBool",
+			&NewTemplateInput{
+				Contents: `{{ "true" | parseBool }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"true",
+			false,
+		},
+		{
+			"helper_parseDuration",
+			&NewTemplateInput{
+				Contents: `{{ "30s" | parseDuration }},{{ "1.5h" | parseDuration }},{{ ("2m" | parseDuration).Seconds }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"30s,1h30m0s,120",
+			false,
+		},
+		{
+			"helper_parseDuration_invalid",
+			&NewTemplateInput{
+				Contents: `{{ "30" | parseDuration }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"",
+			true,
+		},
+		{
+			"helper_durationSeconds",
+			&NewTemplateInput{
+				Contents: `{{ "5m" | durationSeconds }},{{ "1h1m1s" | durationSeconds }},{{ "-30s" | durationSeconds }},{{ "" | durationSeconds }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"300,3661,-30,0",
+			false,
+		},
+		{
+			"helper_durationSeconds_fractional",
+			&NewTemplateInput{
+				Contents: `{{ "1.5m" | durationSeconds }},{{ "2.9s" | durationSeconds }},{{ "500ms" | durationSeconds }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"90,2,0",
+			false,
+		},
+		{
+			"helper_durationSeconds_invalid",
+			&NewTemplateInput{
+				Contents: `{{ "five minutes" | durationSeconds }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"",
+			true,
+		},
+		{
+			"helper_parseFloat",
+			&NewTemplateInput{
+				Contents: `{{ "1.2" | parseFloat }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"1.2",
+			false,
+		},
+		{
+			"helper_parseFloat_format",
+			&NewTemplateInput{
+				Contents: `{{ "1.0" | parseFloat | printf "%.1f"}}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"1.0",
+			false,
+		},
+		{
+			"helper_parseInt",
+			&NewTemplateInput{
+				Contents: `{{ "-1" | parseInt }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"-1",
+			false,
+		},
+		{
+			"helper_parseJSON",
+			&NewTemplateInput{
+				Contents: `{{ "{\"foo\": \"bar\"}" | parseJSON }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"map[foo:bar]",
+			false,
+		},
+		{
+			"helper_parseUint",
+			&NewTemplateInput{
+				Contents: `{{ "1" | parseUint }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"1",
+			false,
+		},
+		{
+			"helper_parseYAML",
+			&NewTemplateInput{
+				Contents: `{{ "foo: bar" | parseYAML }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"map[foo:bar]",
+			false,
+		},
+		{
+			"helper_parseYAMLv2",
+			&NewTemplateInput{
+				Contents: `{{ "foo: bar\nbaz: \"foo\"" | parseYAML }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"map[baz:foo foo:bar]",
+			false,
+		},
+		{
+			"helper_parseYAMLnested",
+			&NewTemplateInput{
+				Contents: `{{ "foo:\n  bar: \"baz\"\n  baz: 7" | parseYAML }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"map[foo:map[bar:baz baz:7]]",
+			false,
+		},
+		{
+			"helper_plugin",
+			&NewTemplateInput{
+				Contents: `{{ "1" | plugin "echo" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"1",
+			false,
+		},
+		{
+			"helper_plugin_disabled",
+			&NewTemplateInput{
+				Contents:         `{{ "1" | plugin "echo" }}`,
+				FunctionDenylist: []string{"plugin"},
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"",
+			true,
+		},
+		{
+			"helper_queryEscape",
+			&NewTemplateInput{
+				Contents: `{{ "a b&c=d/e?f+g%" | queryEscape }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"a+b%26c%3Dd%2Fe%3Ff%2Bg%25",
+			false,
+		},
+		{
+			"helper_queryUnescape",
+			&NewTemplateInput{
+				Contents: `{{ "a+b%26c%3Dd%2Fe%3Ff%2Bg%25" | queryUnescape }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"a b&c=d/e?f+g%",
+			false,
+		},
+		{
+			"helper_queryUnescape_malformed",
+			&NewTemplateInput{
+				Contents: `{{ "100%zz" | queryUnescape }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"",
+			true,
+		},
+		{
+			"helper_buildQuery",
+			&NewTemplateInput{
+				Contents: `{{ buildQuery (sprig_dict "zone" "us east" "a&b" "1=2" "tag" (sprig_list "web" "v2")) }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"a%26b=1%3D2&tag=web&tag=v2&zone=us+east",
+			false,
+		},
+		{
+			"helper_buildQuery_not_map",
+			&NewTemplateInput{
+				Contents: `{{ buildQuery "foo=bar" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"",
+			true,
+		},
+		{
+			"helper_regexMatch",
+			&NewTemplateInput{
+				Contents: `{{ "foo" | regexMatch "[a-z]+" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"true",
+			false,
+		},
+		{
+			"helper_regexReplaceAll",
+			&NewTemplateInput{
+				Contents: `{{ "foo" | regexReplaceAll "\\w" "x" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"xxx",
+			false,
+		},
+		{
+			"helper_replaceAll",
+			&NewTemplateInput{
+				Contents: `{{ "hello my hello" | regexReplaceAll "hello" "bye" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"bye my bye",
+			false,
+		},
+		{
+			"helper_split",
+			&NewTemplateInput{
+				Contents: `{{ "a,b,c" | split "," }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"[a b c]",
+			false,
+		},
+		{
+			"helper_splitToMap",
+			&NewTemplateInput{
+				Contents: `{{ "a:x,b:y,c:z" | splitToMap "," ":" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"map[a:x b:y c:z]",
+			false,
+		},
+		{
+			"helper_timestamp",
+			&NewTemplateInput{
+				Contents: `{{ timestamp }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"1970-01-01T00:00:00Z",
+			false,
+		},
+		{
+			"helper_helper_timestamp__formatted",
+			&NewTemplateInput{
+				Contents: `{{ timestamp "2006-01-02" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"1970-01-01",
+			false,
+		},
+		{
+			"helper_timestamp_timezone",
+			&NewTemplateInput{
+				Contents: `{{ timestamp "2006-01-02T15:04:05Z07:00" "America/New_York" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"1969-12-31T19:00:00-05:00",
+			false,
+		},
+		{
+			"helper_timestamp_timezone_utc",
+			&NewTemplateInput{
+				Contents: `{{ timestamp "Jan 2 15:04 MST" "UTC" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"Jan 1 00:00 UTC",
+			false,
+		},
+		{
+			"helper_timestamp_timezone_invalid",
+			&NewTemplateInput{
+				Contents: `{{ timestamp "2006-01-02" "Mars/Olympus_Mons" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"",
+			true,
+		},
+		{
+			"helper_toJSON",
+			&NewTemplateInput{
+				Contents: `{{ "a,b,c" | split "," | toJSON }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"[\"a\",\"b\",\"c\"]",
+			false,
+		},
+		{
+			"helper_toUnescapedJSON",
+			&NewTemplateInput{
+				Contents: `{{ "a?b&c,x?y&z" | split "," | toUnescapedJSON }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"[\"a?b&c\",\"x?y&z\"]",
+			false,
+		},
+		{
+			"helper_toUnescapedJSONPretty",
+			&NewTemplateInput{
+				Contents: `{{ tree "key" | explode | toUnescapedJSONPretty }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewKVListQuery("key")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.KeyPair{
+						{Key: "a", Value: "b&c"},
+						{Key: "x", Value: "y&z"},
+						{Key: "k", Value: "<>&&"},
+					})
+					return b
+				}(),
+			},
+			`{
+  "a": "b&c",
+  "k": "<>&&",
+  "x": "y&z"
+}`,
+			false,
+		},
+		{
+			"helper_toLower",
+			&NewTemplateInput{
+				Contents: `{{ "HI" | toLower }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"hi",
+			false,
+		},
+		{
+			"helper_toTitle",
+			&NewTemplateInput{
+				Contents: `{{ "this is a sentence" | toTitle }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"This Is A Sentence",
+			false,
+		},
+		{
+			"helper_toTitle_unicode",
+			&NewTemplateInput{
+				Contents: `{{ "this is a sentence\u2026and another sentence\u2026with a \xf0\x9f\x9a\x80rocket" | toTitle }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"This Is A Sentence\u2026And Another Sentence\u2026With A \xf0\x9f\x9a\x80Rocket",
+			false,
+		},
+		{
+			"helper_toTOML",
+			&NewTemplateInput{
+				Contents: `{{ "{\"foo\":\"bar\"}" | parseJSON | toTOML }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"foo = \"bar\"",
+			false,
+		},
+		{
+			"helper_toUpper",
+			&NewTemplateInput{
+				Contents: `{{ "hi" | toUpper }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"HI",
+			false,
+		},
+		{
+			"helper_toYAML",
+			&NewTemplateInput{
+				Contents: `{{ "{\"foo\":\"bar\"}" | parseJSON | toYAML }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"foo: bar",
+			false,
+		},
+		{
+			"helper_trimSpace",
+			&NewTemplateInput{
+				Contents: `{{ "\t hi\n " | trimSpace }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"hi",
+			false,
+		},
+		{
+			"helper_sockaddr",
+			&NewTemplateInput{
+				Contents: `{{ sockaddr "GetAllInterfaces | include \"flag\" \"loopback\" | include \"type\" \"IPv4\" | sort \"address\" | limit 1 | attr \"address\""}}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"127.0.0.1",
+			false,
+		},
+		{
+			"helper_consulDNS_plain",
+			&NewTemplateInput{
+				Contents: `{{ consulDNS "web" "dc1" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"web.service.dc1.consul",
+			false,
+		},
+		{
+			"helper_consulDNS_tagged",
+			&NewTemplateInput{
+				Contents: `{{ consulDNS "v1.web" "dc1" }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"v1.web.service.dc1.consul",
+			false,
+		},
+		{
+			"helper_consulDNS_domain",
+			&NewTemplateInput{
+				Contents: `{{ consulDNS "web" "dc1" "example.com." }}`,
+			},
+			&ExecuteInput{
+				Brain: NewBrain(),
+			},
+			"web.service.dc1.example.com",
 			false,
 		},
 		{
-			"helper_mergeMapWithOverride",
+			"helper_consulDNS_no_datacenter",
 			&NewTemplateInput{
-				Contents: `{{ $base := "{\"zip\":{\"zap\":\"t\"},\"voo\":{\"bar\":\"v\"}}" | parseJSON}}{{ $role := tree "list" | explode | mergeMapWithOverride $base}}{{ range $k, $v := $role }}{{ $k }}{{ $v }}{{ end }}`,
+				Contents: `{{ consulDNS "web" }} {{ consulDNS "web" "" "example.com" }}`,
 			},
 			&ExecuteInput{
-				Brain: func() *Brain {
-					b := NewBrain()
-					d, err := dep.NewKVListQuery("list")
-					if err != nil {
-						t.Fatal(err)
-					}
-					b.Remember(d, []*dep.KeyPair{
-						{Key: "", Value: ""},
-						{Key: "foo/bar", Value: "a"},
-						{Key: "zip/zap", Value: "b"},
-					})
-					return b
-				}(),
+				Brain: NewBrain(),
 			},
-			"foomap[bar:a]voomap[bar:v]zipmap[zap:b]",
+			"web.service.consul web.service.example.com",
 			false,
 		},
 		{
-			"helper_explode",
+			"helper_consulDNS_empty_name",
 			&NewTemplateInput{
-				Contents: `{{ range $k, $v := tree "list" | explode }}{{ $k }}{{ $v }}{{ end }}`,
+				Contents: `{{ consulDNS "v1." "dc1" }}`,
 			},
 			&ExecuteInput{
-				Brain: func() *Brain {
-					b := NewBrain()
-					d, err := dep.NewKVListQuery("list")
-					if err != nil {
-						t.Fatal(err)
-					}
-					b.Remember(d, []*dep.KeyPair{
-						{Key: "", Value: ""},
-						{Key: "foo/bar", Value: "a"},
-						{Key: "zip/zap", Value: "b"},
-					})
-					return b
-				}(),
+				Brain: NewBrain(),
 			},
-			"foomap[bar:a]zipmap[zap:b]",
-			false,
+			"",
+			true,
 		},
 		{
-			"helper_explodemap",
+			"helper_consulDNS_arguments",
 			&NewTemplateInput{
-				Contents: `{{ scratch.MapSet "explode-test" "foo/bar" "a"}}{{ scratch.MapSet "explode-test" "qux" "c"}}{{ scratch.MapSet "explode-test" "zip/zap" "d"}}{{ scratch.Get "explode-test" | explodeMap }}`,
+				Contents: `{{ consulDNS "web" "dc1" "consul" "extra" }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"map[foo:map[bar:a] qux:c zip:map[zap:d]]",
-			false,
+			"",
+			true,
 		},
 		{
-			"helper_in",
+			"helper_cidrHost",
 			&NewTemplateInput{
-				Contents: `{{ range service "webapp" }}{{ if "prod" | in .Tags }}{{ .Address }}{{ end }}{{ end }}`,
+				Contents: `{{ cidrHost "10.12.112.0/20" 16 }} {{ cidrHost "10.12.112.0/20" 268 }} {{ cidrHost "10.12.112.0/20" -1 }}`,
 			},
 			&ExecuteInput{
-				Brain: func() *Brain {
-					b := NewBrain()
-					d, err := dep.NewHealthServiceQuery("webapp")
-					if err != nil {
-						t.Fatal(err)
-					}
-					b.Remember(d, []*dep.HealthService{
-						{
-							Address: "1.2.3.4",
-							Tags:    []string{"prod", "staging"},
-						},
-						{
-							Address: "5.6.7.8",
-							Tags:    []string{"staging"},
-						},
-					})
-					return b
-				}(),
+				Brain: NewBrain(),
 			},
-			"1.2.3.4",
+			"10.12.112.16 10.12.113.12 10.12.127.255",
 			false,
 		},
 		{
-			"helper_indent",
+			"helper_cidrHost_ipv6",
 			&NewTemplateInput{
-				Contents: `{{ "hello\nhello\r\nHELLO\r\nhello\nHELLO" | indent 4 }}`,
+				Contents: `{{ cidrHost "fd00:fd12:3456:7890::/56" 16 }} {{ cidrHost "fd00:fd12:3456:7890::/56" -1 }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"    hello\n    hello\r\n    HELLO\r\n    hello\n    HELLO",
+			"fd00:fd12:3456:7800::10 fd00:fd12:3456:78ff:ffff:ffff:ffff:ffff",
 			false,
 		},
 		{
-			"helper_indent_negative",
+			"helper_cidrHost_out_of_range",
 			&NewTemplateInput{
-				Contents: `{{ "hello\nhello\r\nHELLO\r\nhello\nHELLO" | indent -4 }}`,
+				Contents: `{{ cidrHost "10.0.0.0/30" 4 }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"    hello\n    hello\r\n    HELLO\r\n    hello\n    HELLO",
+			"",
 			true,
 		},
 		{
-			"helper_indent_zero",
+			"helper_cidrHost_invalid",
 			&NewTemplateInput{
-				Contents: `{{ "hello\nhello\r\nHELLO\r\nhello\nHELLO" | indent 0 }}`,
+				Contents: `{{ cidrHost "10.0.0.0" 1 }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"hello\nhello\r\nHELLO\r\nhello\nHELLO",
-			false,
+			"",
+			true,
 		},
 		{
-			"helper_loop",
+			"helper_cidrHosts",
 			&NewTemplateInput{
-				Contents: `{{ range loop 3 }}1{{ end }}`,
+				Contents: `{{ range cidrHosts "192.168.1.16/29" }}{{ . }} {{ end }}{{ cidrHosts "10.0.0.0/31" }} {{ cidrHosts "10.0.0.7/32" }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"111",
+			"192.168.1.17 192.168.1.18 192.168.1.19 192.168.1.20 192.168.1.21 192.168.1.22 [10.0.0.0 10.0.0.1] [10.0.0.7]",
 			false,
 		},
 		{
-			"helper_loop__i",
+			"helper_cidrHosts_ipv6",
 			&NewTemplateInput{
-				Contents: `{{ range $i := loop 3 }}{{ $i }}{{ end }}`,
+				Contents: `{{ cidrHosts "fd00::/126" }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"012",
+			"[fd00:: fd00::1 fd00::2 fd00::3]",
 			false,
 		},
 		{
-			"helper_loop_start",
+			"helper_cidrHosts_limit",
 			&NewTemplateInput{
-				Contents: `{{ range loop 1 3 }}1{{ end }}`,
+				Contents: `{{ len (cidrHosts "10.0.0.0/20") }} {{ len (cidrHosts "10.0.0.0/16" 65534) }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"11",
+			"4094 65534",
 			false,
 		},
 		{
-			"helper_loop_text",
+			"helper_cidrHosts_limit_exceeded",
 			&NewTemplateInput{
-				Contents: `{{ range loop 1 "3" }}1{{ end }}`,
+				Contents: `{{ cidrHosts "10.0.0.0/24" 16 }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"11",
-			false,
+			"",
+			true,
 		},
 		{
-			"helper_loop_parseInt",
+			"helper_cidrHosts_ipv6_limit_exceeded",
 			&NewTemplateInput{
-				Contents: `{{ $i := print "3" | parseInt }}{{ range loop 1 $i }}1{{ end }}`,
+				Contents: `{{ cidrHosts "fd00::/64" }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"11",
-			false,
+			"",
+			true,
 		},
 		{
-			// GH-1143
-			"helper_loop_var",
+			"helper_cidrNetmask",
 			&NewTemplateInput{
-				Contents: `{{$n := 3 }}` +
-					`{{ range $i := loop $n }}{{ $i }}{{ end }}`,
+				Contents: `{{ cidrNetmask "172.16.0.0/12" }} {{ cidrNetmask "10.0.0.0/32" }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"012",
+			"255.240.0.0 255.255.255.255",
 			false,
 		},
 		{
-			"helper_join",
+			"helper_cidrNetmask_ipv6",
 			&NewTemplateInput{
-				Contents: `{{ "a,b,c" | split "," | join ";" }}`,
+				Contents: `{{ cidrNetmask "fd00::/56" }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"a;b;c",
+			"ffff:ffff:ffff:ff00::",
 			false,
 		},
 		{
-			"helper_trim",
+			"helper_cidrSubnets",
 			&NewTemplateInput{
-				Contents: `{{ "!!hello world!!" | trim "!!" }}`,
+				Contents: `{{ range cidrSubnets "10.1.0.0/16" 4 4 8 4 }}{{ . }};{{ end }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"hello world",
+			"10.1.0.0/20;10.1.16.0/20;10.1.32.0/24;10.1.48.0/20;",
 			false,
 		},
 		{
-			"helper_trimPrefix",
+			"helper_cidrSubnets_ipv6",
 			&NewTemplateInput{
-				Contents: `{{ "hello world!!" | trimPrefix "hello " }}`,
+				Contents: `{{ range cidrSubnets "fd00:fd12:3456:7890::/56" 16 16 16 32 }}{{ . }};{{ end }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"world!!",
+			"fd00:fd12:3456:7800::/72;fd00:fd12:3456:7800:100::/72;fd00:fd12:3456:7800:200::/72;fd00:fd12:3456:7800:300::/88;",
 			false,
 		},
 		{
-			"helper_trimSuffix",
+			"helper_cidrSubnets_no_space",
 			&NewTemplateInput{
-				Contents: `{{ "hello world!!" | trimSuffix " world!!" }}`,
+				Contents: `{{ cidrSubnets "10.0.0.0/24" 1 1 1 }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"hello",
-			false,
+			"",
+			true,
 		},
 		{
-			"helper_parseBool",
+			"helper_cidrSubnets_invalid_bits",
 			&NewTemplateInput{
-				Contents: `{{ "true" | parseBool }}`,
+				Contents: `{{ cidrSubnets "10.0.0.0/24" 9 }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"true",
-			false,
+			"",
+			true,
 		},
 		{
-			"helper_parseFloat",
+			"helper_ipInCIDR",
 			&NewTemplateInput{
-				Contents: `{{ "1.2" | parseFloat }}`,
+				Contents: `{{ ipInCIDR "10.0.1.5" "10.0.0.0/16" }} {{ ipInCIDR "10.1.1.5" "10.0.0.0/16" }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"1.2",
+			"true false",
 			false,
 		},
 		{
-			"helper_parseFloat_format",
+			"helper_ipInCIDR_ipv6",
 			&NewTemplateInput{
-				Contents: `{{ "1.0" | parseFloat | printf "%.1f"}}`,
+				Contents: `{{ ipInCIDR "fd00::1" "fd00::/8" }} {{ ipInCIDR "10.0.0.1" "fd00::/8" }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"1.0",
+			"true false",
 			false,
 		},
 		{
-			"helper_parseInt",
+			"helper_ipInCIDR_invalid",
 			&NewTemplateInput{
-				Contents: `{{ "-1" | parseInt }}`,
+				Contents: `{{ ipInCIDR "10.0.0" "10.0.0.0/16" }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"-1",
-			false,
+			"",
+			true,
 		},
 		{
-			"helper_parseJSON",
+			"helper_addrPort_ipv4",
 			&NewTemplateInput{
-				Contents: `{{ "{\"foo\": \"bar\"}" | parseJSON }}`,
+				Contents: `{{ addrPort "10.0.0.1" 8080 }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"map[foo:bar]",
+			"10.0.0.1:8080",
 			false,
 		},
 		{
-			"helper_parseUint",
+			"helper_addrPort_ipv6",
 			&NewTemplateInput{
-				Contents: `{{ "1" | parseUint }}`,
+				Contents: `{{ addrPort "2001:db8::1" "8080" }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"1",
+			"[2001:db8::1]:8080",
 			false,
 		},
 		{
-			"helper_parseYAML",
+			"helper_addrPort_ipv6_bracketed",
 			&NewTemplateInput{
-				Contents: `{{ "foo: bar" | parseYAML }}`,
+				Contents: `{{ addrPort "[::1]" 8080 }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"map[foo:bar]",
+			"[::1]:8080",
 			false,
 		},
 		{
-			"helper_parseYAMLv2",
+			"helper_addrPort_hostname",
 			&NewTemplateInput{
-				Contents: `{{ "foo: bar\nbaz: \"foo\"" | parseYAML }}`,
+				Contents: `{{ addrPort "web.service.consul" "443" }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"map[baz:foo foo:bar]",
+			"web.service.consul:443",
 			false,
 		},
 		{
-			"helper_parseYAMLnested",
+			"helper_addrPort_service",
 			&NewTemplateInput{
-				Contents: `{{ "foo:\n  bar: \"baz\"\n  baz: 7" | parseYAML }}`,
+				Contents: `{{ range service "webapp" }}{{ addrPort . }};{{ end }}`,
 			},
 			&ExecuteInput{
-				Brain: NewBrain(),
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthServiceQuery("webapp")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.HealthService{
+						{Address: "10.0.0.1", Port: 8080},
+						{Address: "2001:db8::1", Port: 8081},
+					})
+					return b
+				}(),
 			},
-			"map[foo:map[bar:baz baz:7]]",
+			"10.0.0.1:8080;[2001:db8::1]:8081;",
 			false,
 		},
 		{
-			"helper_plugin",
+			"helper_addrPort_invalid_port",
 			&NewTemplateInput{
-				Contents: `{{ "1" | plugin "echo" }}`,
+				Contents: `{{ addrPort "10.0.0.1" "http" }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"1",
-			false,
+			"",
+			true,
 		},
 		{
-			"helper_plugin_disabled",
+			"helper_addrPort_wrong_type",
 			&NewTemplateInput{
-				Contents:         `{{ "1" | plugin "echo" }}`,
-				FunctionDenylist: []string{"plugin"},
+				Contents: `{{ addrPort "10.0.0.1" }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
@@ -1675,273 +3906,359 @@ func TestTemplate_Execute(t *testing.T) {
 			true,
 		},
 		{
-			"helper_regexMatch",
+			"helper_humanizeBytes",
 			&NewTemplateInput{
-				Contents: `{{ "foo" | regexMatch "[a-z]+" }}`,
+				Contents: `{{ humanizeBytes 0 }},{{ humanizeBytes 1023 }},{{ humanizeBytes 1024 }},{{ humanizeBytes 1536 }},{{ humanizeBytes 1048575 }},{{ humanizeBytes 5368709120 }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"true",
+			"0 B,1023 B,1 KiB,1.5 KiB,1 MiB,5 GiB",
 			false,
 		},
 		{
-			"helper_regexReplaceAll",
+			"helper_humanizeBytes_string_float",
 			&NewTemplateInput{
-				Contents: `{{ "foo" | regexReplaceAll "\\w" "x" }}`,
+				Contents: `{{ humanizeBytes "1536" }},{{ humanizeBytes 2560.0 }},{{ "1024" | humanizeBytes }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"xxx",
+			"1.5 KiB,2.5 KiB,1 KiB",
 			false,
 		},
 		{
-			"helper_replaceAll",
+			"helper_humanizeBytes_negative",
 			&NewTemplateInput{
-				Contents: `{{ "hello my hello" | regexReplaceAll "hello" "bye" }}`,
+				Contents: `{{ humanizeBytes -1536 }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"bye my bye",
+			"-1.5 KiB",
 			false,
 		},
 		{
-			"helper_split",
+			"helper_humanizeBytes_invalid",
 			&NewTemplateInput{
-				Contents: `{{ "a,b,c" | split "," }}`,
+				Contents: `{{ humanizeBytes "lots" }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"[a b c]",
-			false,
+			"",
+			true,
 		},
 		{
-			"helper_splitToMap",
+			"helper_humanizeDuration",
 			&NewTemplateInput{
-				Contents: `{{ "a:x,b:y,c:z" | splitToMap "," ":" }}`,
+				Contents: `{{ humanizeDuration 0 }},{{ humanizeDuration 59 }},{{ humanizeDuration 3661 }},{{ humanizeDuration "90" }},{{ humanizeDuration 1.5 }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"map[a:x b:y c:z]",
+			"0s,59s,1h1m1s,1m30s,1.5s",
 			false,
 		},
 		{
-			"helper_timestamp",
+			"helper_humanizeDuration_negative",
 			&NewTemplateInput{
-				Contents: `{{ timestamp }}`,
+				Contents: `{{ humanizeDuration -3661 }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"1970-01-01T00:00:00Z",
+			"-1h1m1s",
 			false,
 		},
 		{
-			"helper_helper_timestamp__formatted",
+			"helper_humanizeDuration_invalid",
 			&NewTemplateInput{
-				Contents: `{{ timestamp "2006-01-02" }}`,
+				Contents: `{{ humanizeDuration "1h" }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"1970-01-01",
+			"",
+			true,
+		},
+		{
+			"helper_consistentShard",
+			&NewTemplateInput{
+				Contents: `{{ range consistentShard (service "webapp") "host-a" 1 }}{{ .Address }};{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthServiceQuery("webapp")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.HealthService{
+						{Address: "10.0.0.1", Port: 8080},
+						{Address: "2001:db8::1", Port: 8081},
+					})
+					return b
+				}(),
+			},
+			"10.0.0.1;2001:db8::1;",
 			false,
 		},
 		{
-			"helper_toJSON",
+			"helper_nodeHealthy",
 			&NewTemplateInput{
-				Contents: `{{ "a,b,c" | split "," | toJSON }}`,
+				Contents: `{{ range service "webapp|any" | nodeHealthy }}{{ .Node }};{{ end }}`,
 			},
 			&ExecuteInput{
-				Brain: NewBrain(),
+				Brain: func() *Brain {
+					b := NewBrain()
+					d, err := dep.NewHealthServiceQuery("webapp|any")
+					if err != nil {
+						t.Fatal(err)
+					}
+					b.Remember(d, []*dep.HealthService{
+						{
+							Node: "healthy",
+							Checks: api.HealthChecks{
+								{CheckID: "serfHealth", Status: api.HealthPassing},
+								{CheckID: "service:web", ServiceID: "web", Status: api.HealthPassing},
+							},
+						},
+						{
+							Node: "node-failing",
+							Checks: api.HealthChecks{
+								{CheckID: "serfHealth", Status: api.HealthPassing},
+								{CheckID: "disk", Status: api.HealthCritical},
+								{CheckID: "service:web", ServiceID: "web", Status: api.HealthPassing},
+							},
+						},
+						{
+							Node: "no-node-checks",
+							Checks: api.HealthChecks{
+								{CheckID: "service:web", ServiceID: "web", Status: api.HealthPassing},
+							},
+						},
+						{
+							Node: "service-failing",
+							Checks: api.HealthChecks{
+								{CheckID: "serfHealth", Status: api.HealthPassing},
+								{CheckID: "service:web", ServiceID: "web", Status: api.HealthCritical},
+							},
+						},
+					})
+					return b
+				}(),
+			},
+			"healthy;no-node-checks;service-failing;",
+			false,
+		},
+		{
+			"helper_servicesByDC",
+			&NewTemplateInput{
+				Contents: `{{ range $dc, $s := servicesByDC "web|any" "dc1" (split "," "dc2,dc3") }}` +
+					`{{ $dc }}:{{ range $s }}{{ .Node }},{{ end }};{{ end }}`,
+			},
+			&ExecuteInput{
+				Brain: func() *Brain {
+					b := NewBrain()
+					for dc, nodes := range map[string][]string{"dc1": {"a", "b"}, "dc3": {"c"}} {
+						d, err := dep.NewHealthServiceQuery("web@" + dc + "|any")
+						if err != nil {
+							t.Fatal(err)
+						}
+						services := []*dep.HealthService{}
+						for _, n := range nodes {
+							services = append(services, &dep.HealthService{Node: n})
+						}
+						b.Remember(d, services)
+					}
+					return b
+				}(),
 			},
-			"[\"a\",\"b\",\"c\"]",
+			"dc1:a,b,;dc2:;dc3:c,;",
 			false,
 		},
 		{
-			"helper_toUnescapedJSON",
+			"helper_servicesByDC_datacenter",
 			&NewTemplateInput{
-				Contents: `{{ "a?b&c,x?y&z" | split "," | toUnescapedJSON }}`,
+				Contents: `{{ servicesByDC "web@dc1" "dc2" }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"[\"a?b&c\",\"x?y&z\"]",
-			false,
+			"",
+			true,
 		},
 		{
-			"helper_toUnescapedJSONPretty",
+			"helper_weightedPick",
 			&NewTemplateInput{
-				Contents: `{{ tree "key" | explode | toUnescapedJSONPretty }}`,
+				Contents: `{{ $a := weightedPick (service "webapp") "weight" }}` +
+					`{{ $b := weightedPick (service "webapp") "weight" }}` +
+					`{{ $c := weightedPick (service "webapp") "weight" }}` +
+					`{{ and (eq $a.Node $b.Node) (eq $a.Node $c.Node) }}`,
 			},
 			&ExecuteInput{
 				Brain: func() *Brain {
 					b := NewBrain()
-					d, err := dep.NewKVListQuery("key")
+					d, err := dep.NewHealthServiceQuery("webapp")
 					if err != nil {
 						t.Fatal(err)
 					}
-					b.Remember(d, []*dep.KeyPair{
-						{Key: "a", Value: "b&c"},
-						{Key: "x", Value: "y&z"},
-						{Key: "k", Value: "<>&&"},
+					b.Remember(d, []*dep.HealthService{
+						{Node: "node1", ID: "web", ServiceMeta: map[string]string{"weight": "2"}},
+						{Node: "node2", ID: "web", ServiceMeta: map[string]string{"weight": "0"}},
+						{Node: "node3", ID: "web"},
 					})
 					return b
 				}(),
 			},
-			`{
-  "a": "b&c",
-  "k": "<>&&",
-  "x": "y&z"
-}`,
+			"true",
 			false,
 		},
 		{
-			"helper_toLower",
+			"math_add",
 			&NewTemplateInput{
-				Contents: `{{ "HI" | toLower }}`,
+				Contents: `{{ 2 | add 2 }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"hi",
+			"4",
 			false,
 		},
 		{
-			"helper_toTitle",
+			"math_subtract",
 			&NewTemplateInput{
-				Contents: `{{ "this is a sentence" | toTitle }}`,
+				Contents: `{{ 2 | subtract 2 }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"This Is A Sentence",
+			"0",
 			false,
 		},
 		{
-			"helper_toTitle_unicode",
+			"math_multiply",
 			&NewTemplateInput{
-				Contents: `{{ "this is a sentence\u2026and another sentence\u2026with a \xf0\x9f\x9a\x80rocket" | toTitle }}`,
+				Contents: `{{ 2 | multiply 2 }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"This Is A Sentence\u2026And Another Sentence\u2026With A \xf0\x9f\x9a\x80Rocket",
+			"4",
 			false,
 		},
 		{
-			"helper_toTOML",
+			"math_divide",
 			&NewTemplateInput{
-				Contents: `{{ "{\"foo\":\"bar\"}" | parseJSON | toTOML }}`,
+				Contents: `{{ 2 | divide 2 }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"foo = \"bar\"",
+			"1",
 			false,
 		},
 		{
-			"helper_toUpper",
+			"math_modulo",
 			&NewTemplateInput{
-				Contents: `{{ "hi" | toUpper }}`,
+				Contents: `{{ 3 | modulo 2 }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"HI",
+			"1",
 			false,
 		},
 		{
-			"helper_toYAML",
+			"math_aliases",
 			&NewTemplateInput{
-				Contents: `{{ "{\"foo\":\"bar\"}" | parseJSON | toYAML }}`,
+				Contents: `{{ sub 5 2 }} {{ mul 2 5 }} {{ div 5 2 }} {{ mod 5 2 }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"foo: bar",
+			"3 10 2 1",
 			false,
 		},
 		{
-			"helper_trimSpace",
+			// The aliases take their arguments in the order they are written,
+			// so a piped value is the second operand, unlike for the long names.
+			"math_aliases_pipe",
 			&NewTemplateInput{
-				Contents: `{{ "\t hi\n " | trimSpace }}`,
+				Contents: `{{ 2 | sub 10 }} {{ 2 | div 10 }} {{ 3 | mod 10 }} {{ 10 | div 2 }} {{ 10 | divide 2 }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"hi",
+			"8 5 1 0 5",
 			false,
 		},
 		{
-			"helper_sockaddr",
+			"math_int_float",
 			&NewTemplateInput{
-				Contents: `{{ sockaddr "GetAllInterfaces | include \"flag\" \"loopback\" | include \"type\" \"IPv4\" | sort \"address\" | limit 1 | attr \"address\""}}`,
+				Contents: `{{ add 1 2.5 }} {{ mul 2 1.5 }} {{ div 5.0 2 }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"127.0.0.1",
+			"3.5 3 2.5",
 			false,
 		},
 		{
-			"math_add",
+			"math_divide_by_zero",
 			&NewTemplateInput{
-				Contents: `{{ 2 | add 2 }}`,
+				Contents: `{{ 5 | divide 0 }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"4",
-			false,
+			"",
+			true,
 		},
 		{
-			"math_subtract",
+			"math_div_by_zero_float",
 			&NewTemplateInput{
-				Contents: `{{ 2 | subtract 2 }}`,
+				Contents: `{{ div 5.0 0.0 }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"0",
-			false,
+			"",
+			true,
 		},
 		{
-			"math_multiply",
+			"math_mod_by_zero",
 			&NewTemplateInput{
-				Contents: `{{ 2 | multiply 2 }}`,
+				Contents: `{{ mod 5 0 }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"4",
-			false,
+			"",
+			true,
 		},
 		{
-			"math_divide",
+			"math_sum",
 			&NewTemplateInput{
-				Contents: `{{ 2 | divide 2 }}`,
+				Contents: `{{ sum (sprig_list 1 2 3) }} {{ sum (sprig_list 1 2.5) }} {{ "10,20,5" | split "," | sum }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"1",
+			"6 3.5 35",
 			false,
 		},
 		{
-			"math_modulo",
+			"math_sum_invalid",
 			&NewTemplateInput{
-				Contents: `{{ 3 | modulo 2 }}`,
+				Contents: `{{ "1,two" | split "," | sum }}`,
 			},
 			&ExecuteInput{
 				Brain: NewBrain(),
 			},
-			"1",
-			false,
+			"",
+			true,
 		},
 		{
 			"math_minimum",
@@ -2341,6 +4658,182 @@ func TestTemplate_Execute(t *testing.T) {
 	}
 }
 
+func TestTemplate_ExecuteUsesGeneration(t *testing.T) {
+	cases := []struct {
+		name     string
+		contents string
+		exp      bool
+	}{
+		{"used", `{{ renderGeneration }}`, true},
+		{"not_used", `{{ "static" }}`, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tpl, err := NewTemplate(&NewTemplateInput{Contents: tc.contents})
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := tpl.Execute(&ExecuteInput{Brain: NewBrain()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.UsesGeneration != tc.exp {
+				t.Errorf("expected %t to be %t", result.UsesGeneration, tc.exp)
+			}
+		})
+	}
+}
+
+func TestTemplate_ExecuteDependencyAge(t *testing.T) {
+	tpl, err := NewTemplate(&NewTemplateInput{
+		Contents: `{{ $web := service "web" }}{{ (dependencyAge "health.service(web|passing)").Nanoseconds }}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := dep.NewHealthServiceQuery("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	brain := NewBrain()
+	brain.Remember(d, []*dep.HealthService{})
+
+	// age renders the template and returns the age it reports.
+	age := func() time.Duration {
+		t.Helper()
+		result, err := tpl.Execute(&ExecuteInput{Brain: brain})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ns, err := strconv.ParseInt(string(result.Output), 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return time.Duration(ns)
+	}
+
+	first := age()
+	time.Sleep(10 * time.Millisecond)
+	second := age()
+	if second-first < 10*time.Millisecond {
+		t.Errorf("expected the age to increase by at least 10ms, got %s then %s", first, second)
+	}
+}
+
+func TestTemplate_ExecuteTolerated(t *testing.T) {
+	tpl, err := NewTemplate(&NewTemplateInput{
+		Contents: `{{ servicesByDC "web" "dc1" "dc2" }}{{ service "db" }}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := tpl.Execute(&ExecuteInput{Brain: NewBrain()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{"health.service(web@dc1|passing)", "health.service(web@dc2|passing)"}
+	if act := result.Tolerated.String(); act != strings.Join(exp, ", ") {
+		t.Errorf("expected tolerated %q, got %q", strings.Join(exp, ", "), act)
+	}
+	if l := result.Missing.Len(); l != 3 {
+		t.Errorf("expected 3 missing dependencies, got %d", l)
+	}
+}
+
+func TestTemplate_ExecuteEmit(t *testing.T) {
+	fanOut := &config.TemplateConfig{FanOut: config.Bool(true)}
+
+	cases := []struct {
+		name     string
+		contents string
+		config   *config.TemplateConfig
+		exp      map[string][]byte
+		err      bool
+	}{
+		{
+			"emitted",
+			`{{ range $s := split "," "a,b,c" }}{{ printf "name %s\n" . | emit (print $s ".conf") }}{{ end }}`,
+			fanOut,
+			map[string][]byte{
+				"a.conf": []byte("name a\n"),
+				"b.conf": []byte("name b\n"),
+				"c.conf": []byte("name c\n"),
+			},
+			false,
+		},
+		{
+			"subdirectory",
+			`{{ emit "./sub//a.conf" "a" }}`,
+			fanOut,
+			map[string][]byte{filepath.Join("sub", "a.conf"): []byte("a")},
+			false,
+		},
+		{
+			"none",
+			`{{ range $s := split "," "" }}{{ end }}`,
+			fanOut,
+			map[string][]byte{},
+			false,
+		},
+		{
+			"duplicate",
+			`{{ emit "a.conf" "a" }}{{ emit "a.conf" "b" }}`,
+			fanOut,
+			nil,
+			true,
+		},
+		{
+			"outside_destination",
+			`{{ emit "../a.conf" "a" }}`,
+			fanOut,
+			nil,
+			true,
+		},
+		{
+			"absolute",
+			`{{ emit "/etc/a.conf" "a" }}`,
+			fanOut,
+			nil,
+			true,
+		},
+		{
+			"not_fan_out",
+			`{{ emit "a.conf" "a" }}`,
+			nil,
+			nil,
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tpl, err := NewTemplate(&NewTemplateInput{
+				Contents: tc.contents,
+				Config:   tc.config,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := tpl.Execute(&ExecuteInput{Brain: NewBrain()})
+			if (err != nil) != tc.err {
+				t.Fatal(err)
+			}
+			if err != nil {
+				return
+			}
+			if len(result.Output) != 0 {
+				t.Errorf("expected no output, got %q", result.Output)
+			}
+			if !reflect.DeepEqual(tc.exp, result.Emitted) {
+				t.Errorf("\nexp: %q\nact: %q", tc.exp, result.Emitted)
+			}
+		})
+	}
+}
+
 func TestTemplate_error_secret_leak(t *testing.T) {
 	tmplinput := &NewTemplateInput{
 		Contents: `{{ with secret "secret/foo" }}
@@ -2422,6 +4915,93 @@ func TestTemplate_error_secret_leak_SV(t *testing.T) {
 	require.ErrorContains(t, err, "[redacted]")
 }
 
+func TestTemplate_failIf_message(t *testing.T) {
+	tpl, err := NewTemplate(&NewTemplateInput{
+		Contents: `{{ failIf true "duplicate port 8080" }}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = tpl.Execute(&ExecuteInput{Brain: NewBrain()})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), "duplicate port 8080") {
+		t.Errorf("expected error to contain the message, got %q", err)
+	}
+}
+
+// heapSampler is a writer that hashes what it is given and records the peak
+// heap in use while it is being written to.
+type heapSampler struct {
+	hash    hash.Hash
+	n, next int
+	maxHeap uint64
+}
+
+func (w *heapSampler) Write(p []byte) (int, error) {
+	w.hash.Write(p)
+	w.n += len(p)
+	if w.n >= w.next {
+		runtime.GC()
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		if m.HeapAlloc > w.maxHeap {
+			w.maxHeap = m.HeapAlloc
+		}
+		w.next += 8 << 20
+	}
+	return len(p), nil
+}
+
+func TestTemplate_ExecuteWriter(t *testing.T) {
+	const lines = 1 << 19
+	padding := strings.Repeat("x", 120)
+
+	tpl, err := NewTemplate(&NewTemplateInput{
+		Contents: `{{ range loop ` + strconv.Itoa(lines) + ` }}{{ printf "%06d" . }} ` + padding + "\n" + `{{ end }}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expHash := sha256.New()
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(expHash, "%06d %s\n", i, padding)
+	}
+	size := lines * (len(padding) + 8)
+
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	w := &heapSampler{hash: sha256.New()}
+	result, err := tpl.Execute(&ExecuteInput{
+		Brain:  NewBrain(),
+		Writer: w,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Output != nil {
+		t.Errorf("expected no buffered output, got %d bytes", len(result.Output))
+	}
+	if w.n != size {
+		t.Errorf("expected %d bytes to be written, got %d", size, w.n)
+	}
+	if !bytes.Equal(expHash.Sum(nil), w.hash.Sum(nil)) {
+		t.Error("written output does not match the expected output")
+	}
+
+	// The output is 64MB. Buffering it would grow the heap by at least that
+	// much, so allow a quarter of it for the template engine itself.
+	if grown := int64(w.maxHeap) - int64(before.HeapAlloc); grown > int64(size/4) {
+		t.Errorf("expected heap to stay below %d bytes while writing, grew by %d", size/4, grown)
+	}
+}
+
 func Test_writeToFile(t *testing.T) {
 	// Use current user and its primary group for input
 	currentUser, err := user.Current()
@@ -2635,6 +5215,77 @@ func Test_writeToFile(t *testing.T) {
 	}
 }
 
+func Test_writeBinary(t *testing.T) {
+	// A blob with NUL bytes, CRLF/LF line endings and non-UTF-8 bytes which
+	// must survive rendering untouched.
+	blob := []byte{0x00, 0xfe, 0xed, 0xfe, 0xed, '\r', '\n', 0xff, 0x00, '\n', 0x80}
+	encoded := base64.StdEncoding.EncodeToString(blob)
+
+	cases := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			"writeBinary",
+			encoded,
+			false,
+		},
+		{
+			"writeBinary_wrapped",
+			encoded[:8] + "\n" + encoded[8:] + "\n",
+			false,
+		},
+		{
+			"writeBinary_invalid",
+			"not base64!",
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			outDir, err := os.MkdirTemp("", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(outDir)
+			outputFilePath := filepath.Join(outDir, "out.bin")
+
+			tpl, err := NewTemplate(&NewTemplateInput{
+				Contents: fmt.Sprintf(`{{ %q | writeBinary %q "" "" "0600" }}`, tc.content, outputFilePath),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			a, err := tpl.Execute(&ExecuteInput{
+				Brain: NewBrain(),
+			})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("writeBinary() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				if _, err := os.Stat(outputFilePath); !os.IsNotExist(err) {
+					t.Errorf("writeBinary() expected no file to be written")
+				}
+				return
+			}
+			if !bytes.Equal([]byte(""), a.Output) {
+				t.Errorf("writeBinary() template = %q, want empty string", a.Output)
+			}
+
+			got, err := os.ReadFile(outputFilePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, blob) {
+				t.Errorf("writeBinary() got = %v, want %v", got, blob)
+			}
+		})
+	}
+}
+
 const testCert = `
 -----BEGIN CERTIFICATE-----
 MIIDWTCCAkGgAwIBAgIUUARA+vQExU8zjdsX/YXMMu1K5FkwDQYJKoZIhvcNAQEL
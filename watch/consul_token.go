@@ -0,0 +1,75 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package watch
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/consul-template/config"
+	dep "github.com/hashicorp/consul-template/dependency"
+)
+
+// ConsulTokenWatcher monitors the Consul token file for updates, so that a
+// rotated ACL token is used without a restart. Each new token is set on the
+// clients, and the Consul queries of the views in the given watcher are sent
+// again with it.
+func ConsulTokenWatcher(
+	clients *dep.ClientSet, c *config.ConsulConfig, views *Watcher, doneCh chan struct{},
+) (*Watcher, error) {
+	tokenFile := strings.TrimSpace(config.StringVal(c.TokenFile))
+	if tokenFile == "" {
+		return nil, nil
+	}
+
+	// The client read the token file when it was created, so start from the
+	// same token rather than resending every query on the first read.
+	data, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("consultokenwatcher: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	clients.SetConsulToken(token)
+
+	d, err := dep.NewFileQuery(tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("consultokenwatcher: %w", err)
+	}
+
+	w := NewWatcher(&NewWatcherInput{
+		Clients:          clients,
+		RetryFuncDefault: RetryFunc(c.Retry.RetryFunc()),
+	})
+	if _, err := w.Add(d); err != nil {
+		w.Stop()
+		return nil, fmt.Errorf("consultokenwatcher: %w", err)
+	}
+
+	go func() {
+		for {
+			select {
+			case v := <-w.DataCh():
+				newToken := strings.TrimSpace(v.Data().(string))
+				switch newToken {
+				case token:
+				case "":
+					// The file may be truncated while the token is being rotated,
+					// so keep the current token until the new one is written.
+					log.Printf("[WARN] (consultokenwatcher) %s is empty, keeping the current token", tokenFile)
+				default:
+					log.Printf("[INFO] (consultokenwatcher) %s changed, updating the Consul token", tokenFile)
+					token = newToken
+					clients.SetConsulToken(token)
+					views.Resend(dep.TypeConsul)
+				}
+			case <-doneCh:
+				return
+			}
+		}
+	}()
+
+	return w, nil
+}
@@ -0,0 +1,111 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package watch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-template/config"
+	dep "github.com/hashicorp/consul-template/dependency"
+)
+
+func init() {
+	dep.FileQuerySleepTime = 10 * time.Millisecond
+}
+
+func TestConsulTokenWatcher(t *testing.T) {
+	var lock sync.Mutex
+	var lastToken string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		lastToken = r.Header.Get("X-Consul-Token")
+		lock.Unlock()
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	// writeToken writes the token file in a new second, since the index of
+	// file queries is the time in seconds and a change in the same second is
+	// treated as no new data.
+	writeToken := func(token string) {
+		time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+		if err := os.WriteFile(tokenFile, []byte(token), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// requestToken returns the token sent with a new request to Consul.
+	requestToken := func(clients *dep.ClientSet) string {
+		if _, err := clients.Consul().Catalog().Datacenters(); err != nil {
+			t.Fatal(err)
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		return lastToken
+	}
+
+	t.Run("no_token_file", func(t *testing.T) {
+		watcher, err := ConsulTokenWatcher(dep.NewClientSet(), config.DefaultConsulConfig(), nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if watcher != nil {
+			t.Error("watcher should be nil")
+		}
+	})
+
+	t.Run("rotate", func(t *testing.T) {
+		writeToken("token-1\n")
+
+		clients := dep.NewClientSet()
+		if err := clients.CreateConsulClient(&dep.CreateConsulClientInput{
+			Address:   server.URL,
+			TokenFile: tokenFile,
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		conf := config.DefaultConsulConfig()
+		conf.TokenFile = config.String(tokenFile)
+		conf.Finalize()
+
+		doneCh := make(chan struct{})
+		defer close(doneCh)
+		watcher, err := ConsulTokenWatcher(clients, conf, nil, doneCh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer watcher.Stop()
+
+		if token := requestToken(clients); token != "token-1" {
+			t.Fatalf("expected %q, got %q", "token-1", token)
+		}
+
+		// A file emptied during rotation keeps the current token.
+		writeToken("")
+		time.Sleep(200 * time.Millisecond)
+		if token := requestToken(clients); token != "token-1" {
+			t.Fatalf("expected %q, got %q", "token-1", token)
+		}
+
+		writeToken("token-2")
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			token := requestToken(clients)
+			if token == "token-2" {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %q, got %q", "token-2", token)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
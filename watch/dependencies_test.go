@@ -6,6 +6,7 @@ package watch
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	dep "github.com/hashicorp/consul-template/dependency"
@@ -161,6 +162,33 @@ func (d *TestDepRetry) Type() dep.Type {
 	return dep.TypeLocal
 }
 
+// TestDepDatacenter is a dependency of the given upstream type that returns
+// the datacenter it would query, merging its own datacenter into the options
+// like the Consul dependencies do.
+type TestDepDatacenter struct {
+	dc  string
+	typ dep.Type
+}
+
+func (d *TestDepDatacenter) Fetch(clients *dep.ClientSet, opts *dep.QueryOptions) (interface{}, *dep.ResponseMetadata, error) {
+	opts = opts.Merge(&dep.QueryOptions{Datacenter: d.dc})
+	return opts.Datacenter, &dep.ResponseMetadata{LastIndex: 1}, nil
+}
+
+func (d *TestDepDatacenter) CanShare() bool {
+	return true
+}
+
+func (d *TestDepDatacenter) String() string {
+	return fmt.Sprintf("test_dep_datacenter(%d@%s)", d.typ, d.dc)
+}
+
+func (d *TestDepDatacenter) Stop() {}
+
+func (d *TestDepDatacenter) Type() dep.Type {
+	return d.typ
+}
+
 // TestDepBlock is a dependency that think's its blocking
 type TestDepBlock struct {
 	TestDep
@@ -174,3 +202,107 @@ func (d *TestDepBlock) Fetch(clients *dep.ClientSet, opts *dep.QueryOptions) (in
 func (d *TestDepBlock) String() string {
 	return "test_dep_block"
 }
+
+// TestDepCounter is a dependency that counts the number of times it is
+// fetched. The counter is a pointer so that separate instances of the same
+// dependency can share it.
+type TestDepCounter struct {
+	name    string
+	share   bool
+	fetches *int32
+}
+
+func (d *TestDepCounter) Fetch(clients *dep.ClientSet, opts *dep.QueryOptions) (interface{}, *dep.ResponseMetadata, error) {
+	atomic.AddInt32(d.fetches, 1)
+	time.Sleep(time.Millisecond)
+	data := "this is some data"
+	rm := &dep.ResponseMetadata{LastIndex: 1}
+	return data, rm, nil
+}
+
+func (d *TestDepCounter) CanShare() bool {
+	return d.share
+}
+
+func (d *TestDepCounter) String() string {
+	return fmt.Sprintf("test_dep_counter(%s)", d.name)
+}
+
+func (d *TestDepCounter) Stop() {}
+
+func (d *TestDepCounter) Type() dep.Type {
+	return dep.TypeLocal
+}
+
+var _ dep.Dependency = (*TestDepTypedFetchError)(nil)
+
+// TestDepTypedFetchError is a dependency of the given upstream type that always
+// fails to fetch. It counts the number of fetch attempts.
+type TestDepTypedFetchError struct {
+	name    string
+	typ     dep.Type
+	fetches int32
+}
+
+func (d *TestDepTypedFetchError) Fetch(clients *dep.ClientSet, opts *dep.QueryOptions) (interface{}, *dep.ResponseMetadata, error) {
+	atomic.AddInt32(&d.fetches, 1)
+	return nil, nil, fmt.Errorf("failed to contact server")
+}
+
+func (d *TestDepTypedFetchError) CanShare() bool {
+	return true
+}
+
+func (d *TestDepTypedFetchError) String() string {
+	return fmt.Sprintf("test_dep_typed_fetch_error(%s)", d.name)
+}
+
+func (d *TestDepTypedFetchError) Stop() {}
+
+func (d *TestDepTypedFetchError) Type() dep.Type {
+	return d.typ
+}
+
+var _ dep.Dependency = (*TestDepStall)(nil)
+
+// TestDepStall is a Consul dependency whose second fetch never gets a response,
+// like a blocking query on a dead connection. Every fetch after it blocks
+// briefly and returns the first response again at a new index.
+type TestDepStall struct {
+	fetches int32
+	stopCh  chan struct{}
+}
+
+func (d *TestDepStall) Fetch(clients *dep.ClientSet, opts *dep.QueryOptions) (interface{}, *dep.ResponseMetadata, error) {
+	data := "this is some data"
+	switch atomic.AddInt32(&d.fetches, 1) {
+	case 1:
+		return data, &dep.ResponseMetadata{LastIndex: 1}, nil
+	case 2:
+		<-d.stopCh
+		return nil, nil, dep.ErrStopped
+	default:
+		select {
+		case <-d.stopCh:
+			return nil, nil, dep.ErrStopped
+		case <-time.After(5 * time.Millisecond):
+		}
+		return data, &dep.ResponseMetadata{LastIndex: 2}, nil
+	}
+}
+
+func (d *TestDepStall) CanShare() bool {
+	return true
+}
+
+func (d *TestDepStall) String() string {
+	return "test_dep_stall"
+}
+
+func (d *TestDepStall) Stop() {
+	close(d.stopCh)
+}
+
+func (d *TestDepStall) Type() dep.Type {
+	return dep.TypeConsul
+}
@@ -24,6 +24,11 @@ func VaultTokenWatcher(
 	// tokens are not being used.
 	raw_token := strings.TrimSpace(config.StringVal(c.Token))
 	if raw_token == "" {
+		// Without a token, the clients may have logged in with an auth
+		// method instead, whose token needs to be kept alive.
+		if secret := clients.VaultAuthSecret(); secret != nil {
+			return vaultAuthWatcher(clients, c, secret)
+		}
 		return nil, nil
 	}
 
@@ -77,6 +82,27 @@ func VaultTokenWatcher(
 	return watcher, nil
 }
 
+// vaultAuthWatcher renews the token the clients logged in with and logs in
+// again when it can no longer be renewed.
+func vaultAuthWatcher(
+	clients *dep.ClientSet, c *config.VaultConfig, secret *api.Secret,
+) (*Watcher, error) {
+	w := NewWatcher(&NewWatcherInput{
+		Clients:        clients,
+		RetryFuncVault: RetryFunc(c.Retry.RetryFunc()),
+	})
+	va, err := dep.NewVaultAuthQuery(secret)
+	if err != nil {
+		w.Stop()
+		return nil, fmt.Errorf("vaultwatcher: %w", err)
+	}
+	if _, err := w.Add(va); err != nil {
+		w.Stop()
+		return nil, fmt.Errorf("vaultwatcher: %w", err)
+	}
+	return w, nil
+}
+
 func watchTokenFile(
 	w *Watcher, tokenFile, raw_token string, unwrap bool, doneCh chan struct{},
 ) (func(), error) {
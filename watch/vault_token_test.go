@@ -6,7 +6,11 @@ package watch
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -242,6 +246,168 @@ func TestVaultTokenGetToken(t *testing.T) {
 	})
 }
 
+// Unwraps the configured token against a mock Vault unwrap endpoint, so it
+// runs without the Vault test server.
+func TestVaultTokenWatcher_unwrapMock(t *testing.T) {
+	const wrappedToken, clientToken = "s.wrapped", "s.unwrapped"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/sys/wrapping/unwrap" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("X-Vault-Token") != wrappedToken {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"errors":["wrapping token is not valid or does not exist"]}`)
+			return
+		}
+		fmt.Fprintf(w, `{"auth":{"client_token":%q}}`, clientToken)
+	}))
+	defer srv.Close()
+
+	newConf := func(token string) *config.VaultConfig {
+		conf := config.DefaultVaultConfig()
+		conf.Token = config.String(token)
+		conf.UnwrapToken = config.Bool(true)
+		conf.RenewToken = config.Bool(false)
+		return conf
+	}
+
+	t.Run("unwraps", func(t *testing.T) {
+		clients := dep.NewClientSet()
+		if err := clients.CreateVaultClient(&dep.CreateVaultClientInput{
+			Address: srv.URL,
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		watcher, err := VaultTokenWatcher(clients, newConf(wrappedToken), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if watcher != nil {
+			t.Error("watcher should be nil")
+		}
+		if token := clients.Vault().Token(); token != clientToken {
+			t.Errorf("expected token %q, got %q", clientToken, token)
+		}
+	})
+
+	t.Run("unwrap_fails", func(t *testing.T) {
+		clients := dep.NewClientSet()
+		if err := clients.CreateVaultClient(&dep.CreateVaultClientInput{
+			Address: srv.URL,
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := VaultTokenWatcher(clients, newConf("s.invalid"), nil)
+		if err == nil {
+			t.Fatal("expected unwrap error")
+		}
+		if !strings.Contains(err.Error(), "vault unwrap") {
+			t.Errorf("expected unwrap error, got: %s", err)
+		}
+	})
+}
+
+// Logs in with the approle method against a mock Vault, renews the token and
+// logs in again once renewing fails.
+func TestVaultTokenWatcher_authMock(t *testing.T) {
+	secretIDFile := filepath.Join(t.TempDir(), "secret-id")
+	if err := os.WriteFile(secretIDFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var logins, renewals int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.URL.Path {
+		case "/v1/auth/ci/login":
+			var data map[string]string
+			json.NewDecoder(r.Body).Decode(&data)
+			if data["role_id"] != "role" || data["secret_id"] != "secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"errors":["invalid role or secret ID"]}`)
+				return
+			}
+			logins++
+			fmt.Fprintf(w, `{"auth":{"client_token":"s.login-%d","renewable":true,"lease_duration":1}}`, logins)
+		case "/v1/auth/token/renew-self":
+			// The first token can be renewed once, the next one forever.
+			token := r.Header.Get("X-Vault-Token")
+			if token == "s.login-1" && renewals > 0 {
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `{"errors":["permission denied"]}`)
+				return
+			}
+			renewals++
+			fmt.Fprintf(w, `{"auth":{"client_token":%q,"renewable":true,"lease_duration":1}}`, token)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	conf := config.DefaultVaultConfig()
+	conf.Auth = &config.VaultAuthConfig{
+		Method: config.String("approle"),
+		Mount:  config.String("ci"),
+		Config: map[string]string{
+			"role_id":        "role",
+			"secret_id_file": secretIDFile,
+		},
+	}
+	conf.Finalize()
+
+	clients := dep.NewClientSet()
+	if err := clients.CreateVaultClient(&dep.CreateVaultClientInput{
+		Address: srv.URL,
+		Auth: &dep.VaultAuth{
+			Method: config.StringVal(conf.Auth.Method),
+			Mount:  config.StringVal(conf.Auth.Mount),
+			Config: conf.Auth.Config,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if token := clients.Vault().Token(); token != "s.login-1" {
+		t.Fatalf("expected token %q, got %q", "s.login-1", token)
+	}
+
+	watcher, err := VaultTokenWatcher(clients, conf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if watcher == nil {
+		t.Fatal("watcher should not be nil")
+	}
+	defer watcher.Stop()
+
+	deadline := time.After(5 * time.Second)
+	for clients.Vault().Token() != "s.login-2" {
+		select {
+		case err := <-watcher.ErrCh():
+			t.Fatal(err)
+		case <-deadline:
+			t.Fatalf("expected a new login, token is %q", clients.Vault().Token())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if logins != 2 {
+		t.Errorf("expected 2 logins, got %d", logins)
+	}
+	if renewals == 0 {
+		t.Error("expected the token to be renewed")
+	}
+}
+
 type setTokenFaker struct {
 	Token string
 }
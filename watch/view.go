@@ -4,6 +4,7 @@
 package watch
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -13,10 +14,28 @@ import (
 	"time"
 
 	dep "github.com/hashicorp/consul-template/dependency"
+	"github.com/hashicorp/consul-template/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var errLookup = fmt.Errorf("lookup error")
 
+// DependencyError is the error reported by a view when its dependency fails to
+// fetch, once it is out of retries.
+type DependencyError struct {
+	Dependency dep.Dependency
+	Err        error
+}
+
+func (e *DependencyError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DependencyError) Unwrap() error {
+	return e.Err
+}
+
 // View is a representation of a Dependency and the most recent data it has
 // received from Consul.
 type View struct {
@@ -34,12 +53,26 @@ type View struct {
 	receivedData bool
 	lastIndex    uint64
 
+	// fetchedAt is the time of the last successful response, including those
+	// that did not change the data.
+	fetchedAt time.Time
+
 	// blockQueryWaitTime is amount of time in seconds to do a blocking query for
 	blockQueryWaitTime time.Duration
 
+	// stallTimeout is how long past blockQueryWaitTime a blocking query may go
+	// unanswered before the view gives up on it and sends it again.
+	stallTimeout time.Duration
+
 	// maxStale is the maximum amount of time to allow a query to be stale.
 	maxStale time.Duration
 
+	// datacenter, namespace and partition are the Consul datacenter, namespace
+	// and admin partition to query when the dependency does not name its own.
+	datacenter string
+	namespace  string
+	partition  string
+
 	// once determines if this view should receive data exactly once.
 	once bool
 	// failLookupErrors triggers error when a dependency Fetch fails to
@@ -50,6 +83,9 @@ type View struct {
 	// should be attempted.
 	retryFunc RetryFunc
 
+	// resendCh is used to make this View send its query again
+	resendCh chan struct{}
+
 	// stopCh is used to stop polling on this View
 	stopCh chan struct{}
 }
@@ -66,10 +102,24 @@ type NewViewInput struct {
 	// BlockQueryWaitTime is amount of time in seconds to do a blocking query for
 	BlockQueryWaitTime time.Duration
 
+	// StallTimeout is how long past BlockQueryWaitTime a blocking query to
+	// Consul or Nomad may go unanswered before it is considered stalled, like
+	// on a dead connection, and sent again. Zero disables the check.
+	StallTimeout time.Duration
+
 	// MaxStale is the maximum amount a time a query response is allowed to be
 	// stale before forcing a read from the leader.
 	MaxStale time.Duration
 
+	// Datacenter is the datacenter to query when the dependency does not name
+	// one. The datacenter of the dependency takes precedence.
+	Datacenter string
+
+	// Namespace and Partition are the Consul namespace and admin partition to
+	// query when the dependency does not name its own, which take precedence.
+	Namespace string
+	Partition string
+
 	// Once indicates this view should poll for data exactly one time.
 	Once bool
 
@@ -88,10 +138,15 @@ func NewView(i *NewViewInput) (*View, error) {
 		dependency:         i.Dependency,
 		clients:            i.Clients,
 		blockQueryWaitTime: i.BlockQueryWaitTime,
+		stallTimeout:       i.StallTimeout,
 		maxStale:           i.MaxStale,
+		datacenter:         i.Datacenter,
+		namespace:          i.Namespace,
+		partition:          i.Partition,
 		once:               i.Once,
 		failLookupErrors:   i.FailLookupErrors,
 		retryFunc:          i.RetryFunc,
+		resendCh:           make(chan struct{}, 1),
 		stopCh:             make(chan struct{}, 1),
 	}, nil
 }
@@ -117,6 +172,14 @@ func (v *View) DataAndLastIndex() (interface{}, uint64) {
 	return v.data, v.lastIndex
 }
 
+// FetchedAt returns the time of the last successful response for this view,
+// even if its data was unchanged. It is zero until the first response.
+func (v *View) FetchedAt() time.Time {
+	v.dataLock.RLock()
+	defer v.dataLock.RUnlock()
+	return v.fetchedAt
+}
+
 // poll queries the Consul instance for data using the fetch function, but also
 // accounts for interrupts on the interrupt channel. This allows the poll
 // function to be fired in a goroutine, but then halted even if the fetch
@@ -128,9 +191,11 @@ func (v *View) poll(viewCh chan<- *View, errCh chan<- error) {
 		doneCh := make(chan struct{}, 1)
 		successCh := make(chan struct{}, 1)
 		fetchErrCh := make(chan error, 1)
-		go v.fetch(doneCh, successCh, fetchErrCh)
+		abandonCh := make(chan struct{})
+		go v.fetch(doneCh, successCh, fetchErrCh, abandonCh)
 
 	WAIT:
+		stallCh := v.stallCh()
 		select {
 		case <-doneCh:
 			// Reset the retry to avoid exponentially incrementing retries when we
@@ -159,6 +224,23 @@ func (v *View) poll(viewCh chan<- *View, errCh chan<- error) {
 			log.Printf("[TRACE] (view) %s successful contact, resetting retries", v.dependency)
 			retries = 0
 			goto WAIT
+		case <-stallCh:
+			// The query has gone unanswered for longer than it should block, so
+			// it is likely stuck on a dead connection. Leave it behind and send it
+			// again from the same index, which a healthy connection answers
+			// without a change unless the data really changed.
+			log.Printf("[WARN] (view) %s no response after %q, sending the query again",
+				v.dependency, v.blockQueryWaitTime+v.stallTimeout)
+			v.dataLock.Lock()
+			close(abandonCh)
+			v.dataLock.Unlock()
+			continue
+		case <-v.resendCh:
+			log.Printf("[DEBUG] (view) %s sending the query again", v.dependency)
+			v.dataLock.Lock()
+			close(abandonCh)
+			v.dataLock.Unlock()
+			continue
 		case err := <-fetchErrCh:
 			if !errors.Is(err, errLookup) && v.retryFunc != nil {
 				retry, sleep := v.retryFunc(retries)
@@ -180,7 +262,7 @@ func (v *View) poll(viewCh chan<- *View, errCh chan<- error) {
 			select {
 			case <-v.stopCh:
 				return
-			case errCh <- err:
+			case errCh <- &DependencyError{Dependency: v.dependency, Err: err}:
 				return
 			}
 		case <-v.stopCh:
@@ -190,12 +272,32 @@ func (v *View) poll(viewCh chan<- *View, errCh chan<- error) {
 	}
 }
 
+// stallCh returns a channel that fires once a query sent now has gone
+// unanswered for longer than it should block, or nil if the view does not
+// check for stalled queries. Only Consul and Nomad queries block, other
+// dependencies may legitimately take longer to return.
+func (v *View) stallCh() <-chan time.Time {
+	if v.stallTimeout <= 0 {
+		return nil
+	}
+
+	switch v.dependency.Type() {
+	case dep.TypeConsul, dep.TypeNomad:
+		return time.After(v.blockQueryWaitTime + v.stallTimeout)
+	default:
+		return nil
+	}
+}
+
 // fetch queries the Consul instance for the attached dependency. This API
 // promises that either data will be written to doneCh or an error will be
 // written to errCh. It is designed to be run in a goroutine that selects the
 // result of doneCh and errCh. It is assumed that only one instance of fetch
-// is running per View and therefore no locking or mutexes are used.
-func (v *View) fetch(doneCh, successCh chan<- struct{}, errCh chan<- error) {
+// is running per View and therefore no locking or mutexes are used. The one
+// exception is a fetch abandoned by closing abandonCh, which may still be
+// waiting on its query; it returns without touching the view once it gets a
+// response, so the fetch that replaced it is the only one updating the view.
+func (v *View) fetch(doneCh, successCh chan<- struct{}, errCh chan<- error, abandonCh <-chan struct{}) {
 	log.Printf("[TRACE] (view) %s starting fetch", v.dependency)
 
 	var allowStale bool
@@ -210,16 +312,38 @@ func (v *View) fetch(doneCh, successCh chan<- struct{}, errCh chan<- error) {
 		select {
 		case <-v.stopCh:
 			return
+		case <-abandonCh:
+			return
 		default:
 		}
 
 		start := time.Now() // for rateLimiter below
 
+		_, span := telemetry.Tracer().Start(context.Background(), "dependency.fetch")
+		if span.IsRecording() {
+			span.SetAttributes(attribute.String("dependency", v.dependency.String()))
+		}
 		data, rm, err := v.dependency.Fetch(v.clients, &dep.QueryOptions{
-			AllowStale: allowStale,
-			WaitTime:   v.blockQueryWaitTime,
-			WaitIndex:  v.lastIndex,
+			AllowStale:      allowStale,
+			Datacenter:      v.datacenter,
+			ConsulNamespace: v.namespace,
+			ConsulPartition: v.partition,
+			WaitTime:        v.blockQueryWaitTime,
+			WaitIndex:       v.lastIndex,
 		})
+		if err == dep.ErrStopped {
+			span.End()
+		} else {
+			telemetry.End(span, err)
+		}
+
+		select {
+		case <-abandonCh:
+			log.Printf("[TRACE] (view) %s abandoned fetch returned", v.dependency)
+			return
+		default:
+		}
+
 		if err != nil {
 			if err == dep.ErrStopped {
 				log.Printf("[TRACE] (view) %s reported stop", v.dependency)
@@ -254,6 +378,11 @@ func (v *View) fetch(doneCh, successCh chan<- struct{}, errCh chan<- error) {
 			allowStale = true
 		}
 
+		// Responses that leave the data unchanged still confirm it is current.
+		v.dataLock.Lock()
+		v.fetchedAt = time.Now()
+		v.dataLock.Unlock()
+
 		if v.failLookupErrors && !firstLoop && !v.receivedData {
 			errCh <- errLookup
 			return
@@ -272,6 +401,13 @@ func (v *View) fetch(doneCh, successCh chan<- struct{}, errCh chan<- error) {
 		}
 
 		v.dataLock.Lock()
+		select {
+		case <-abandonCh:
+			v.dataLock.Unlock()
+			return
+		default:
+		}
+
 		if rm.LastIndex < v.lastIndex {
 			log.Printf("[TRACE] (view) %s had a lower index, resetting", v.dependency)
 			v.lastIndex = 0
@@ -316,6 +452,15 @@ func rateLimiter(start time.Time) time.Duration {
 	return 0
 }
 
+// resend makes this view send its query again, without waiting for the
+// response to the one in flight.
+func (v *View) resend() {
+	select {
+	case v.resendCh <- struct{}{}:
+	default:
+	}
+}
+
 // stop halts polling of this view.
 func (v *View) stop() {
 	v.dependency.Stop()
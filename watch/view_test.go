@@ -6,6 +6,7 @@ package watch
 import (
 	"errors"
 	"reflect"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -56,6 +57,10 @@ func TestPoll_returnsErrCh(t *testing.T) {
 		if err.Error() != expected {
 			t.Errorf("expected %q to be %q", err.Error(), expected)
 		}
+		var depErr *DependencyError
+		if !errors.As(err, &depErr) || depErr.Dependency != view.Dependency() {
+			t.Errorf("expected %#v to be a DependencyError for %s", err, view.Dependency())
+		}
 	case <-view.stopCh:
 		t.Errorf("poll received premature stop")
 	}
@@ -155,6 +160,77 @@ func TestPoll_retries(t *testing.T) {
 	}
 }
 
+func TestPoll_stalled(t *testing.T) {
+	d := &TestDepStall{stopCh: make(chan struct{})}
+	view, err := NewView(&NewViewInput{
+		Dependency:         d,
+		BlockQueryWaitTime: 10 * time.Millisecond,
+		StallTimeout:       10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	viewCh := make(chan *View)
+	errCh := make(chan error)
+
+	go view.poll(viewCh, errCh)
+	defer view.stop()
+
+	select {
+	case <-viewCh:
+	case err := <-errCh:
+		t.Fatalf("error while polling: %s", err)
+	case <-time.After(time.Second):
+		t.Fatal("did not receive the first view")
+	}
+
+	// The second fetch stalls, so the query must be sent again once the wait
+	// time and stall timeout pass. The data is unchanged, so nothing is sent.
+	timeout := time.After(time.Second)
+	for atomic.LoadInt32(&d.fetches) < 4 {
+		select {
+		case <-viewCh:
+			t.Fatal("expected no update for unchanged data")
+		case err := <-errCh:
+			t.Fatalf("error while polling: %s", err)
+		case <-timeout:
+			t.Fatalf("expected the stalled query to be sent again, got %d fetches",
+				atomic.LoadInt32(&d.fetches))
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	view.dataLock.RLock()
+	defer view.dataLock.RUnlock()
+	if view.lastIndex != 2 {
+		t.Errorf("expected last index 2, got %d", view.lastIndex)
+	}
+}
+
+func TestPoll_stallTimeoutDisabled(t *testing.T) {
+	d := &TestDepStall{stopCh: make(chan struct{})}
+	view, err := NewView(&NewViewInput{
+		Dependency:         d,
+		BlockQueryWaitTime: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	viewCh := make(chan *View)
+	errCh := make(chan error)
+
+	go view.poll(viewCh, errCh)
+	defer view.stop()
+
+	<-viewCh
+	time.Sleep(50 * time.Millisecond)
+	if fetches := atomic.LoadInt32(&d.fetches); fetches != 2 {
+		t.Errorf("expected 2 fetches, got %d", fetches)
+	}
+}
+
 func TestFetch_resetRetries(t *testing.T) {
 	view, err := NewView(&NewViewInput{
 		Dependency: &TestDepSameIndex{},
@@ -167,7 +243,7 @@ func TestFetch_resetRetries(t *testing.T) {
 	successCh := make(chan struct{})
 	errCh := make(chan error)
 
-	go view.fetch(doneCh, successCh, errCh)
+	go view.fetch(doneCh, successCh, errCh, nil)
 
 	select {
 	case <-successCh:
@@ -178,6 +254,49 @@ func TestFetch_resetRetries(t *testing.T) {
 	}
 }
 
+func TestFetch_unchangedFetchedAt(t *testing.T) {
+	view, err := NewView(&NewViewInput{
+		Dependency: &TestDepSameIndex{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer view.stop()
+
+	// The view already has the data, so every response leaves it unchanged.
+	view.lastIndex = 100
+	view.receivedData = true
+
+	doneCh := make(chan struct{})
+	successCh := make(chan struct{}, 1)
+	errCh := make(chan error)
+
+	go view.fetch(doneCh, successCh, errCh, nil)
+
+	// waitFetched waits for the view to record a response after the given time.
+	waitFetched := func(t *testing.T, after time.Time) time.Time {
+		t.Helper()
+		deadline := time.After(5 * time.Second)
+		for {
+			if fetched := view.FetchedAt(); fetched.After(after) {
+				return fetched
+			}
+			select {
+			case <-doneCh:
+				t.Fatal("should not be done")
+			case err := <-errCh:
+				t.Fatalf("error while fetching: %s", err)
+			case <-deadline:
+				t.Fatal("expected the unchanged response to be recorded")
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+
+	first := waitFetched(t, time.Time{})
+	waitFetched(t, first)
+}
+
 func TestFetch_failedLookupError(t *testing.T) {
 	view, err := NewView(&NewViewInput{
 		Dependency:       &TestDepBlock{},
@@ -191,7 +310,7 @@ func TestFetch_failedLookupError(t *testing.T) {
 	successCh := make(chan struct{})
 	errCh := make(chan error)
 
-	go view.fetch(doneCh, successCh, errCh)
+	go view.fetch(doneCh, successCh, errCh, nil)
 
 	select {
 	case <-time.After(time.Millisecond):
@@ -218,7 +337,7 @@ func TestFetch_maxStale(t *testing.T) {
 	successCh := make(chan struct{})
 	errCh := make(chan error)
 
-	go view.fetch(doneCh, successCh, errCh)
+	go view.fetch(doneCh, successCh, errCh, nil)
 
 	select {
 	case <-doneCh:
@@ -243,7 +362,7 @@ func TestFetch_savesView(t *testing.T) {
 	successCh := make(chan struct{})
 	errCh := make(chan error)
 
-	go view.fetch(doneCh, successCh, errCh)
+	go view.fetch(doneCh, successCh, errCh, nil)
 
 	select {
 	case <-doneCh:
@@ -268,7 +387,7 @@ func TestFetch_returnsErrCh(t *testing.T) {
 	successCh := make(chan struct{})
 	errCh := make(chan error)
 
-	go view.fetch(doneCh, successCh, errCh)
+	go view.fetch(doneCh, successCh, errCh, nil)
 
 	select {
 	case <-doneCh:
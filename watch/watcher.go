@@ -4,6 +4,7 @@
 package watch
 
 import (
+	"fmt"
 	"log"
 	"sync"
 	"time"
@@ -15,8 +16,31 @@ import (
 // dataBufferSize is the default number of views to process in a batch.
 const dataBufferSize = 2048
 
+// retryUntilInterval is how long RetryUntil sleeps between attempts once the
+// wrapped retry function has given up.
+const retryUntilInterval = 250 * time.Millisecond
+
 type RetryFunc func(int) (bool, time.Duration)
 
+// RetryUntil returns a RetryFunc that defers to f, but keeps retrying until
+// the deadline once f gives up (or when f is nil). After the deadline the
+// decision is f's alone.
+func RetryUntil(f RetryFunc, deadline time.Time) RetryFunc {
+	return func(retry int) (bool, time.Duration) {
+		if f != nil {
+			if ok, sleep := f(retry); ok {
+				return ok, sleep
+			}
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return false, 0
+		}
+		return true, min(retryUntilInterval, remaining)
+	}
+}
+
 // Watcher is a top-level manager for views that poll Consul for data.
 type Watcher struct {
 	sync.Mutex
@@ -33,17 +57,28 @@ type Watcher struct {
 	// blockQueryWaitTime is amount of time in seconds to do a blocking query for
 	blockQueryWaitTime time.Duration
 
+	// blockQueryStallTimeout is how long past blockQueryWaitTime a blocking
+	// query may go unanswered before it is sent again.
+	blockQueryStallTimeout time.Duration
+
 	// failLookupErrors triggers error when a dependency Fetch fails to
 	// return data after the first pass.
 	failLookupErrors bool
 
 	// depViewMap is a map of Templates to Views. Templates are keyed by
-	// their string.
+	// their viewKey.
 	depViewMap map[string]*View
 
 	// maxStale specifies the maximum staleness of a query response.
 	maxStale time.Duration
 
+	// consulDatacenter, consulNamespace and consulPartition are the
+	// datacenter, namespace and admin partition of the Consul queries that do
+	// not name their own.
+	consulDatacenter string
+	consulNamespace  string
+	consulPartition  string
+
 	// once signals if this watcher should tell views to retrieve data exactly
 	// one time instead of polling infinitely.
 	once bool
@@ -62,12 +97,26 @@ type NewWatcherInput struct {
 	// MaxStale is the maximum staleness of a query.
 	MaxStale time.Duration
 
+	// ConsulDatacenter is the datacenter of the Consul queries that do not name
+	// one. If empty, the datacenter of the Consul agent is used.
+	ConsulDatacenter string
+
+	// ConsulNamespace and ConsulPartition are the Consul Enterprise namespace
+	// and admin partition of the Consul queries that do not name their own
+	// with "?ns=" or "?partition=".
+	ConsulNamespace string
+	ConsulPartition string
+
 	// Once specifies this watcher should tell views to poll exactly once.
 	Once bool
 
 	// WaitTime is amount of time in seconds to do a blocking query for
 	BlockQueryWaitTime time.Duration
 
+	// BlockQueryStallTimeout is how long past BlockQueryWaitTime a blocking
+	// query may go unanswered before it is sent again. Zero disables it.
+	BlockQueryStallTimeout time.Duration
+
 	// FailLookupErrors triggers error when a dependency Fetch fails to
 	// return data after the first pass.
 	FailLookupErrors bool
@@ -91,18 +140,22 @@ type NewWatcherInput struct {
 // NewWatcher creates a new watcher using the given API client.
 func NewWatcher(i *NewWatcherInput) *Watcher {
 	w := &Watcher{
-		clients:            i.Clients,
-		depViewMap:         make(map[string]*View),
-		dataCh:             make(chan *View, dataBufferSize),
-		errCh:              make(chan error),
-		maxStale:           i.MaxStale,
-		once:               i.Once,
-		blockQueryWaitTime: i.BlockQueryWaitTime,
-		failLookupErrors:   i.FailLookupErrors,
-		retryFuncConsul:    i.RetryFuncConsul,
-		retryFuncDefault:   i.RetryFuncDefault,
-		retryFuncVault:     i.RetryFuncVault,
-		retryFuncNomad:     i.RetryFuncNomad,
+		clients:                i.Clients,
+		depViewMap:             make(map[string]*View),
+		dataCh:                 make(chan *View, dataBufferSize),
+		errCh:                  make(chan error),
+		maxStale:               i.MaxStale,
+		consulDatacenter:       i.ConsulDatacenter,
+		consulNamespace:        i.ConsulNamespace,
+		consulPartition:        i.ConsulPartition,
+		once:                   i.Once,
+		blockQueryWaitTime:     i.BlockQueryWaitTime,
+		blockQueryStallTimeout: i.BlockQueryStallTimeout,
+		failLookupErrors:       i.FailLookupErrors,
+		retryFuncConsul:        i.RetryFuncConsul,
+		retryFuncDefault:       i.RetryFuncDefault,
+		retryFuncVault:         i.RetryFuncVault,
+		retryFuncNomad:         i.RetryFuncNomad,
 	}
 	return w
 }
@@ -125,6 +178,13 @@ func (w *Watcher) ErrCh() <-chan error {
 // and start the associated view. If the dependency already exists, no action is
 // taken.
 //
+// Views of dependencies that can be shared are keyed by the dependency's
+// String(), so identical dependencies used by several templates are coalesced
+// into a single view and a single blocking query. Dependencies that cannot be
+// shared get a view per instance instead, so every consumer adding its own
+// instance has its own query, and must keep using that instance to refer to
+// the view.
+//
 // If the Dependency already existed, it this function will return false. If the
 // view was successfully created, it will return true. If an error occurs while
 // creating the view, it will be returned here (but future errors returned by
@@ -135,16 +195,20 @@ func (w *Watcher) Add(d dep.Dependency) (bool, error) {
 
 	log.Printf("[DEBUG] (watcher) adding %s", d)
 
-	if _, ok := w.depViewMap[d.String()]; ok {
+	if _, ok := w.depViewMap[viewKey(d)]; ok {
 		log.Printf("[TRACE] (watcher) %s already exists, skipping", d)
 		return false, nil
 	}
 
 	// Choose the correct retry function based off of the dependency's type.
 	var retryFunc RetryFunc
+	var datacenter, namespace, partition string
 	switch d.Type() {
 	case dep.TypeConsul:
 		retryFunc = w.retryFuncConsul
+		datacenter = w.consulDatacenter
+		namespace = w.consulNamespace
+		partition = w.consulPartition
 	case dep.TypeVault:
 		retryFunc = w.retryFuncVault
 	case dep.TypeNomad:
@@ -157,7 +221,11 @@ func (w *Watcher) Add(d dep.Dependency) (bool, error) {
 		Dependency:         d,
 		Clients:            w.clients,
 		MaxStale:           w.maxStale,
+		Datacenter:         datacenter,
+		Namespace:          namespace,
+		Partition:          partition,
 		BlockQueryWaitTime: w.blockQueryWaitTime,
+		StallTimeout:       w.blockQueryStallTimeout,
 		FailLookupErrors:   w.failLookupErrors,
 		Once:               w.once,
 		RetryFunc:          retryFunc,
@@ -168,21 +236,46 @@ func (w *Watcher) Add(d dep.Dependency) (bool, error) {
 
 	log.Printf("[TRACE] (watcher) %s starting", d)
 
-	w.depViewMap[d.String()] = v
+	w.depViewMap[viewKey(d)] = v
 	go v.poll(w.dataCh, w.errCh)
 
 	return true, nil
 }
 
+// viewKey returns the key of the view of the given dependency. Dependencies
+// that cannot be shared are told apart by their address, since identical ones
+// of different consumers must not share a view.
+func viewKey(d dep.Dependency) string {
+	if d.CanShare() {
+		return d.String()
+	}
+	return fmt.Sprintf("%s@%p", d, d)
+}
+
 // Watching determines if the given dependency is being watched.
 func (w *Watcher) Watching(d dep.Dependency) bool {
 	w.Lock()
 	defer w.Unlock()
 
-	_, ok := w.depViewMap[d.String()]
+	_, ok := w.depViewMap[viewKey(d)]
 	return ok
 }
 
+// FetchedAt returns the time of the last successful response for the given
+// dependency, even if its data was unchanged. It returns false if the
+// dependency is not watched or has not received a response yet.
+func (w *Watcher) FetchedAt(d dep.Dependency) (time.Time, bool) {
+	w.Lock()
+	defer w.Unlock()
+
+	view, ok := w.depViewMap[viewKey(d)]
+	if !ok || view == nil {
+		return time.Time{}, false
+	}
+	t := view.FetchedAt()
+	return t, !t.IsZero()
+}
+
 // ForceWatching is used to force setting the internal state of watching
 // a dependency. This is only used for unit testing purposes.
 func (w *Watcher) ForceWatching(d dep.Dependency, enabled bool) {
@@ -190,9 +283,9 @@ func (w *Watcher) ForceWatching(d dep.Dependency, enabled bool) {
 	defer w.Unlock()
 
 	if enabled {
-		w.depViewMap[d.String()] = nil
+		w.depViewMap[viewKey(d)] = nil
 	} else {
-		delete(w.depViewMap, d.String())
+		delete(w.depViewMap, viewKey(d))
 	}
 }
 
@@ -206,10 +299,10 @@ func (w *Watcher) Remove(d dep.Dependency) bool {
 
 	log.Printf("[DEBUG] (watcher) removing %s", d)
 
-	if view, ok := w.depViewMap[d.String()]; ok {
+	if view, ok := w.depViewMap[viewKey(d)]; ok {
 		log.Printf("[TRACE] (watcher) actually removing %s", d)
 		view.stop()
-		delete(w.depViewMap, d.String())
+		delete(w.depViewMap, viewKey(d))
 		return true
 	}
 
@@ -217,6 +310,24 @@ func (w *Watcher) Remove(d dep.Dependency) bool {
 	return false
 }
 
+// Resend makes the views of all dependencies of the given type send their
+// queries again, leaving behind any query already waiting for a response. It
+// is used when the credentials those queries were sent with change.
+func (w *Watcher) Resend(t dep.Type) {
+	if w == nil {
+		return
+	}
+	w.Lock()
+	defer w.Unlock()
+
+	for _, view := range w.depViewMap {
+		if view == nil || view.Dependency().Type() != t {
+			continue
+		}
+		view.resend()
+	}
+}
+
 // Size returns the number of views this watcher is watching.
 func (w *Watcher) Size() int {
 	w.Lock()
@@ -5,8 +5,15 @@ package watch
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/consul-template/config"
 	dep "github.com/hashicorp/consul-template/dependency"
 )
 
@@ -69,6 +76,247 @@ func TestAdd_startsViewPoll(t *testing.T) {
 	}
 }
 
+func TestAdd_coalescesIdentical(t *testing.T) {
+	for _, share := range []bool{true, false} {
+		t.Run(fmt.Sprintf("can_share_%t", share), func(t *testing.T) {
+			w := NewWatcher(&NewWatcherInput{
+				Clients: dep.NewClientSet(),
+				Once:    true,
+			})
+
+			// Two templates using the same dependency produce two separate
+			// instances with the same String().
+			var fetches int32
+			d1 := &TestDepCounter{name: "web", share: share, fetches: &fetches}
+			d2 := &TestDepCounter{name: "web", share: share, fetches: &fetches}
+
+			// Only dependencies that can be shared are coalesced, the others
+			// get a view per instance.
+			views := 1
+			if !share {
+				views = 2
+			}
+
+			if added, err := w.Add(d1); err != nil || !added {
+				t.Fatalf("expected first Add to start a view: %t, %v", added, err)
+			}
+			if added, err := w.Add(d2); err != nil || added != !share {
+				t.Fatalf("expected second Add to return %t: %t, %v", !share, added, err)
+			}
+			if added, err := w.Add(d2); err != nil || added {
+				t.Fatalf("expected to reuse the view of the same instance: %t, %v", added, err)
+			}
+
+			if w.Size() != views {
+				t.Errorf("expected %d views, got %d", views, w.Size())
+			}
+			if !w.Watching(d2) {
+				t.Errorf("expected second instance to be watched")
+			}
+
+			for i := 0; i < views; i++ {
+				select {
+				case err := <-w.errCh:
+					t.Fatal(err)
+				case <-w.dataCh:
+				}
+			}
+
+			if n := atomic.LoadInt32(&fetches); n != int32(views) {
+				t.Errorf("expected %d fetches, got %d", views, n)
+			}
+
+			// Removing one consumer's view leaves the other one running.
+			if !share {
+				w.Remove(d1)
+				if w.Watching(d1) || !w.Watching(d2) {
+					t.Errorf("expected only the view of the second instance to remain")
+				}
+			}
+		})
+	}
+}
+
+func TestAdd_retryFuncByType(t *testing.T) {
+	// retryPolicy returns the retry function of a finalized retry config with
+	// the given number of attempts and a tiny backoff.
+	retryPolicy := func(attempts int) RetryFunc {
+		c := &config.RetryConfig{
+			Attempts: config.Int(attempts),
+			Backoff:  config.TimeDuration(time.Millisecond),
+		}
+		c.Finalize()
+		return RetryFunc(c.RetryFunc())
+	}
+
+	w := NewWatcher(&NewWatcherInput{
+		Clients:         dep.NewClientSet(),
+		RetryFuncConsul: retryPolicy(1),
+		RetryFuncVault:  retryPolicy(3),
+		RetryFuncNomad:  retryPolicy(2),
+	})
+	defer w.Stop()
+
+	cases := []struct {
+		name string
+		typ  dep.Type
+		exp  int32
+	}{
+		{"consul", dep.TypeConsul, 2},
+		{"vault", dep.TypeVault, 4},
+		{"nomad", dep.TypeNomad, 3},
+		// Local dependencies have no retry policy.
+		{"local", dep.TypeLocal, 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &TestDepTypedFetchError{name: tc.name, typ: tc.typ}
+			if _, err := w.Add(d); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case <-w.ErrCh():
+			case <-time.After(2 * time.Second):
+				t.Fatal("timeout")
+			}
+
+			// Each policy allows its attempts as retries after the first fetch.
+			if n := atomic.LoadInt32(&d.fetches); n != tc.exp {
+				t.Errorf("expected %d fetches, got %d", tc.exp, n)
+			}
+			w.Remove(d)
+		})
+	}
+}
+
+func TestAdd_consulDatacenter(t *testing.T) {
+	w := NewWatcher(&NewWatcherInput{
+		Clients:          dep.NewClientSet(),
+		ConsulDatacenter: "dc2",
+		Once:             true,
+	})
+	defer w.Stop()
+
+	cases := []struct {
+		name string
+		d    *TestDepDatacenter
+		exp  string
+	}{
+		{"default", &TestDepDatacenter{typ: dep.TypeConsul}, "dc2"},
+		{"explicit", &TestDepDatacenter{dc: "dc3", typ: dep.TypeConsul}, "dc3"},
+		// The default is only for Consul dependencies.
+		{"nomad", &TestDepDatacenter{typ: dep.TypeNomad}, ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := w.Add(tc.d); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case v := <-w.DataCh():
+				if act := v.Data(); act != tc.exp {
+					t.Errorf("expected %q to be %q", act, tc.exp)
+				}
+			case err := <-w.ErrCh():
+				t.Fatal(err)
+			case <-time.After(2 * time.Second):
+				t.Fatal("timeout")
+			}
+		})
+	}
+}
+
+func TestAdd_consulNamespacePartition(t *testing.T) {
+	// The server records the query of each request by path.
+	var mu sync.Mutex
+	queries := make(map[string]url.Values)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries[r.URL.Path] = r.URL.Query()
+		mu.Unlock()
+
+		w.Header().Set("X-Consul-Index", "1")
+		fmt.Fprint(w, `[]`)
+	}))
+	defer server.Close()
+
+	clients := dep.NewClientSet()
+	if err := clients.CreateConsulClient(&dep.CreateConsulClientInput{
+		Address: server.URL,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewWatcher(&NewWatcherInput{
+		Clients:         clients,
+		ConsulNamespace: "team",
+		ConsulPartition: "p1",
+		Once:            true,
+	})
+	defer w.Stop()
+
+	kv := func(s string) dep.Dependency {
+		d, err := dep.NewKVGetQuery(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	health := func(s string) dep.Dependency {
+		d, err := dep.NewHealthServiceQuery(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	cases := []struct {
+		name      string
+		d         dep.Dependency
+		path      string
+		namespace string
+		partition string
+	}{
+		{"kv_inherits", kv("a"), "/v1/kv/a", "team", "p1"},
+		{"kv_overrides", kv("b?ns=other&partition=p2"), "/v1/kv/b", "other", "p2"},
+		{"health_inherits", health("web"), "/v1/health/service/web", "team", "p1"},
+		{"health_overrides_namespace", health("api?ns=other"), "/v1/health/service/api", "other", "p1"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := w.Add(tc.d); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case <-w.DataCh():
+			case err := <-w.ErrCh():
+				t.Fatal(err)
+			case <-time.After(2 * time.Second):
+				t.Fatal("timeout")
+			}
+
+			mu.Lock()
+			query, ok := queries[tc.path]
+			mu.Unlock()
+			if !ok {
+				t.Fatalf("expected a request to %s", tc.path)
+			}
+			if act := query.Get("ns"); act != tc.namespace {
+				t.Errorf("expected namespace %q to be %q", act, tc.namespace)
+			}
+			if act := query.Get("partition"); act != tc.partition {
+				t.Errorf("expected partition %q to be %q", act, tc.partition)
+			}
+		})
+	}
+}
+
 func TestWatching_notExists(t *testing.T) {
 	w := NewWatcher(&NewWatcherInput{
 		Clients: dep.NewClientSet(),
@@ -130,6 +378,37 @@ func TestRemove_doesNotExist(t *testing.T) {
 	}
 }
 
+func TestResend_byType(t *testing.T) {
+	w := NewWatcher(&NewWatcherInput{
+		Clients: dep.NewClientSet(),
+	})
+	defer w.Stop()
+
+	d := &TestDepStall{stopCh: make(chan struct{})}
+	if _, err := w.Add(d); err != nil {
+		t.Fatal(err)
+	}
+	<-w.dataCh
+
+	// The second fetch never returns, so a third one is only sent by Resend.
+	w.Resend(dep.TypeVault)
+	time.Sleep(20 * time.Millisecond)
+	if fetches := atomic.LoadInt32(&d.fetches); fetches != 2 {
+		t.Fatalf("expected 2 fetches, got %d", fetches)
+	}
+
+	w.Resend(dep.TypeConsul)
+	timeout := time.After(time.Second)
+	for atomic.LoadInt32(&d.fetches) < 3 {
+		select {
+		case <-timeout:
+			t.Fatalf("expected the query to be sent again, got %d fetches",
+				atomic.LoadInt32(&d.fetches))
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
 func TestSize_empty(t *testing.T) {
 	w := NewWatcher(&NewWatcherInput{
 		Clients: dep.NewClientSet(),
@@ -158,3 +437,30 @@ func TestSize_returnsNumViews(t *testing.T) {
 		t.Errorf("expected %d to be %d", w.Size(), 10)
 	}
 }
+
+func TestRetryUntil(t *testing.T) {
+	never := func(int) (bool, time.Duration) { return false, 0 }
+	always := func(int) (bool, time.Duration) { return true, time.Minute }
+
+	cases := []struct {
+		name     string
+		f        RetryFunc
+		deadline time.Time
+		retry    bool
+		sleep    time.Duration
+	}{
+		{"defers_to_f", always, time.Now().Add(time.Hour), true, time.Minute},
+		{"before_deadline", never, time.Now().Add(time.Hour), true, retryUntilInterval},
+		{"nil_before_deadline", nil, time.Now().Add(time.Hour), true, retryUntilInterval},
+		{"after_deadline", never, time.Now().Add(-time.Second), false, 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			retry, sleep := RetryUntil(tc.f, tc.deadline)(0)
+			if retry != tc.retry || sleep != tc.sleep {
+				t.Errorf("\nexp: %t, %s\nact: %t, %s", tc.retry, tc.sleep, retry, sleep)
+			}
+		})
+	}
+}